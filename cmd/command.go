@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"idm/inner/assignment"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/ldapsync"
+	"idm/inner/orgunit"
+	"idm/inner/permission"
+	"idm/inner/provisioning"
+	"idm/inner/role"
+	"idm/inner/sod"
+	"idm/inner/validator"
+	"idm/inner/webhook"
+	"os"
+)
+
+// ldapSyncActor автор изменений импорта в журнале аудита
+const ldapSyncActor = "system:ldap-sync"
+
+const usage = `usage:
+  idm                          start http server
+  idm sync ldap [-dry-run]     import employees and roles from LDAP directory`
+
+// runCommand выполнить команду командной строки и вернуть код завершения процесса
+func runCommand(args []string, cfg common.Config, db *sqlx.DB, logger *common.Logger) int {
+	if len(args) >= 2 && args[0] == "sync" && args[1] == "ldap" {
+		return syncLdap(args[2:], cfg, db, logger)
+	}
+	_, _ = fmt.Fprintln(os.Stderr, usage)
+	return 2
+}
+
+// syncLdap импорт из каталога LDAP; с -dry-run только выводит, что было бы изменено
+func syncLdap(args []string, cfg common.Config, db *sqlx.DB, logger *common.Logger) int {
+	flags := flag.NewFlagSet("sync ldap", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report changes without applying them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	settings, err := ldapsync.SettingsFromConfig(cfg)
+	if err != nil {
+		logger.Error("ldap sync: invalid configuration", zap.Error(err))
+		return 1
+	}
+	employeeRepo := employee.NewRepository(db)
+	roleRepo := role.NewRepository(db)
+	assignmentRepo := assignment.NewRepository(db)
+	vld := validator.New()
+	connectors, err := buildConnectors(cfg)
+	if err != nil {
+		logger.Error("ldap sync: invalid provisioning configuration", zap.Error(err))
+		return 1
+	}
+	// импортированные изменения выгружаются и публикуются так же, как сделанные через API;
+	// выполнят их обработчик и диспетчер сервера
+	auditor := webhook.NewOutbox(provisioning.NewRecorder(
+		audit.NewService(audit.NewRepository(db), vld), provisioning.NewRepository(db), provisioning.Names(connectors),
+	), webhook.NewRepository(db))
+	sodRepo := sod.NewRepository(db)
+	assignmentService := assignment.NewService(assignmentRepo, employeeRepo, roleRepo, sodRepo, auditor, vld)
+	attributeRepo := attribute.NewRepository(db)
+	// импортированные сотрудники получают и теряют роли по правилам назначения так же, как изменённые через API
+	rules := birthright.NewService(
+		birthright.NewRepository(db), roleRepo, attributeRepo, assignmentService, auditor, vld,
+	)
+	// импорт запускает оператор сервера, поэтому смена логинов и ролей разрешена ему без ролей в IDM
+	permissions := permission.NewService(
+		permission.NewRepository(db), employeeRepo, roleRepo, assignmentRepo, []string{ldapSyncActor}, auditor, vld,
+	)
+	employeeService := employee.NewService(
+		employeeRepo, roleRepo, orgunit.NewRepository(db), attributeRepo,
+		assignmentRepo, assignmentService, rules, sodRepo, permissions, auditor, vld,
+	)
+	service := ldapsync.NewService(
+		ldapsync.NewLdapDirectory(settings),
+		settings.Mapping,
+		employeeService,
+		role.NewService(roleRepo, auditor, vld),
+		assignmentService,
+		logger,
+	)
+	ctx := common.WithActor(context.Background(), ldapSyncActor)
+	report, err := service.Sync(ctx, *dryRun)
+	if err != nil {
+		logger.Error("ldap sync: failed", zap.Error(err))
+		return 1
+	}
+	if err = report.Print(os.Stdout); err != nil {
+		logger.Error("ldap sync: error printing report", zap.Error(err))
+		return 1
+	}
+	if len(report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -4,13 +4,31 @@ import (
 	"context"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"idm/inner/accessrequest"
+	"idm/inner/apikey"
+	"idm/inner/assignment"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/auth"
+	"idm/inner/birthright"
+	"idm/inner/certification"
 	"idm/inner/common"
 	"idm/inner/database"
 	"idm/inner/employee"
 	"idm/inner/info"
+	"idm/inner/oauth"
+	"idm/inner/orgunit"
+	"idm/inner/permission"
+	"idm/inner/provisioning"
+	"idm/inner/purge"
 	"idm/inner/role"
+	"idm/inner/scim"
+	"idm/inner/sod"
 	"idm/inner/validator"
 	"idm/inner/web"
+	"idm/inner/webhook"
+	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
@@ -29,7 +47,44 @@ func main() {
 			logger.Error("error closing db: %v", zap.Error(err))
 		}
 	}()
-	server := build(cfg, db, logger)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], cfg, db, logger))
+	}
+	connectors, err := buildConnectors(cfg)
+	if err != nil {
+		logger.Panic("provisioning setup error", zap.Error(err))
+	}
+	server, employeeService, accessRequestService, certificationService := build(cfg, db, connectors, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if len(connectors) > 0 {
+		policy := common.RetryPolicy{
+			MaxAttempts: cfg.ProvisioningMaxAttempts,
+			Backoff:     cfg.ProvisioningBackoff,
+			MaxBackoff:  cfg.ProvisioningMaxBackoff,
+		}
+		worker := provisioning.NewWorker(provisioning.NewRepository(db), connectors, policy, logger)
+		go worker.Run(ctx, cfg.ProvisioningInterval)
+	}
+	webhookPolicy := common.RetryPolicy{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Backoff:     cfg.WebhookBackoff,
+		MaxBackoff:  cfg.WebhookMaxBackoff,
+	}
+	dispatcher := webhook.NewDispatcher(
+		webhook.NewRepository(db), &http.Client{Timeout: cfg.WebhookTimeout}, webhookPolicy, logger,
+	)
+	go dispatcher.Run(ctx, cfg.WebhookInterval)
+	go accessrequest.NewExpirer(accessRequestService, logger).Run(ctx, cfg.AccessRequestExpiryInterval)
+	go certification.NewCloser(certificationService, logger).Run(ctx, cfg.CertificationInterval)
+	go employee.NewScheduler(employeeService, logger).Run(ctx, cfg.EmployeeLifecycleInterval)
+	if cfg.PurgeRetention > 0 {
+		purger := purge.NewPurger(cfg.PurgeRetention, logger,
+			purge.Target{Name: "employee", Repo: employee.NewRepository(db)},
+			purge.Target{Name: "role", Repo: role.NewRepository(db)},
+		)
+		go purger.Run(ctx, cfg.PurgeInterval)
+	}
 	go func() {
 		err := server.App.Listen(":8080")
 		if err != nil {
@@ -57,18 +112,143 @@ func gracefulShutdown(server *web.Server, wg *sync.WaitGroup, logger *common.Log
 	logger.Info("Server exiting")
 }
 
-func build(cfg common.Config, db *sqlx.DB, logger *common.Logger) *web.Server {
+// build собрать сервер; сервисы сотрудников, запросов доступа и кампаний пересмотра возвращаются отдельно
+// для фоновой проверки их сроков
+func build(
+	cfg common.Config,
+	db *sqlx.DB,
+	connectors map[string]provisioning.Connector,
+	logger *common.Logger,
+) (*web.Server, *employee.Service, *accessrequest.Service, *certification.Service) {
 	server := web.NewServer()
+	authenticator, err := auth.NewAuthenticator(cfg, logger)
+	if err != nil {
+		logger.Panic("authentication setup error", zap.Error(err))
+	}
+	// middleware группы должен быть зарегистрирован раньше маршрутов, иначе он их не защитит
+	server.GroupApiV1.Use(authenticator.Middleware)
+	// ошибки SCIM, в том числе отказы аутентификации, клиенты ждут в формате SCIM
+	server.GroupScim.Use(scim.ErrorResponses, authenticator.Middleware)
 	employeeRepo := employee.NewRepository(db)
 	roleRepo := role.NewRepository(db)
+	assignmentRepo := assignment.NewRepository(db)
+	permissionRepo := permission.NewRepository(db)
+	auditRepo := audit.NewRepository(db)
+	apiKeyRepo := apikey.NewRepository(db)
 	vld := validator.New()
-	employeeService := employee.NewService(employeeRepo, vld)
-	roleService := role.NewService(roleRepo, vld)
+	provisioningRepo := provisioning.NewRepository(db)
+	webhookRepo := webhook.NewRepository(db)
+	sodRepo := sod.NewRepository(db)
+	orgUnitRepo := orgunit.NewRepository(db)
+	attributeRepo := attribute.NewRepository(db)
+	auditService := audit.NewService(auditRepo, vld)
+	// изменения сотрудников и назначений, кроме журнала, ставят в очередь операции выгрузки
+	recorder := provisioning.NewRecorder(auditService, provisioningRepo, provisioning.Names(connectors))
+	// и публикуют события для подписчиков webhook в той же транзакции
+	outbox := webhook.NewOutbox(recorder, webhookRepo)
+	assignmentService := assignment.NewService(assignmentRepo, employeeRepo, roleRepo, sodRepo, outbox, vld)
+	// роли по правилам назначения выдаются и отзываются тоже через сервис назначений
+	ruleService := birthright.NewService(
+		birthright.NewRepository(db), roleRepo, attributeRepo, assignmentService, outbox, vld,
+	)
+	permissionService := permission.NewService(
+		permissionRepo, employeeRepo, roleRepo, assignmentRepo, cfg.AuthAdmins, auditService, vld,
+	)
+	// при увольнении роли отзываются сервисом назначений, чтобы каждый отзыв попал в журнал и выгрузку
+	employeeService := employee.NewService(
+		employeeRepo, roleRepo, orgUnitRepo, attributeRepo, assignmentRepo, assignmentService, ruleService, sodRepo,
+		permissionService, outbox, vld,
+	)
+	roleService := role.NewService(roleRepo, outbox, vld)
+	// Authorizer задаётся раньше любого маршрута: Require без него не регистрирует маршрут
+	server.Authorizer = auth.NewAuthorizer(permissionService, logger)
+	apiKeyService := apikey.NewService(apiKeyRepo, permissionService, auditService, vld)
+	// роль по одобренному запросу назначается сервисом назначений: с журналом, выгрузкой и событиями
+	accessRequestService := accessrequest.NewService(
+		accessrequest.NewRepository(db), employeeRepo, roleRepo, assignmentService, permissionService, auditService, vld,
+		cfg.AccessRequestTtl,
+	)
+	authenticator.AcceptApiKeys(apiKeyService)
+	// без ключей подписи IDM не выдаёт токены и не подписывает отчёты кампаний пересмотра
+	var reportSigner certification.Signer
+	if cfg.OAuthSigningKeysDir != "" {
+		signingKeys, err := auth.NewSigningKeys(cfg.OAuthSigningKeysDir, cfg.AuthJwksRefresh)
+		if err != nil {
+			logger.Panic("oauth signing keys error", zap.Error(err))
+		}
+		reportSigner = signingKeys
+		oauthService := buildOAuth(cfg, db, signingKeys, permissionService, auditService, vld, authenticator, logger)
+		oauth.NewController(server, oauthService, logger).RegisterRoutes()
+	}
+	// роль по итогам пересмотра отзывается сервисом назначений, как и назначается по запросу доступа
+	certificationService := certification.NewService(
+		certification.NewRepository(db), roleRepo, orgUnitRepo, assignmentService, auditService, reportSigner,
+		certification.Settings{AutoRevoke: cfg.CertificationAutoRevoke, Issuer: cfg.OAuthIssuer}, vld,
+	)
 	employeeController := employee.NewController(server, employeeService, logger)
 	roleController := role.NewController(server, roleService, logger)
+	assignmentController := assignment.NewController(server, assignmentService, logger)
+	permissionController := permission.NewController(server, permissionService, logger)
+	auditController := audit.NewController(server, auditService, logger)
+	apiKeyController := apikey.NewController(server, apiKeyService, logger)
+	provisioningController := provisioning.NewController(server, provisioning.NewService(provisioningRepo, vld), logger)
+	webhookController := webhook.NewController(server, webhook.NewService(webhookRepo, auditService, vld), logger)
+	accessRequestController := accessrequest.NewController(server, accessRequestService, logger)
+	certificationController := certification.NewController(server, certificationService, logger)
+	sodController := sod.NewController(server, sod.NewService(sodRepo, roleRepo, auditService, vld), logger)
+	orgUnitController := orgunit.NewController(server, orgunit.NewService(orgUnitRepo, auditService, vld), logger)
+	attributeController := attribute.NewController(server, attribute.NewService(attributeRepo, auditService, vld), logger)
+	ruleController := birthright.NewController(server, ruleService, logger)
+	scimController := scim.NewController(server, scim.NewService(employeeService, roleService, assignmentService), logger)
 	employeeController.RegisterRoutes()
 	roleController.RegisterRoutes()
+	assignmentController.RegisterRoutes()
+	permissionController.RegisterRoutes()
+	auditController.RegisterRoutes()
+	apiKeyController.RegisterRoutes()
+	provisioningController.RegisterRoutes()
+	webhookController.RegisterRoutes()
+	accessRequestController.RegisterRoutes()
+	certificationController.RegisterRoutes()
+	sodController.RegisterRoutes()
+	orgUnitController.RegisterRoutes()
+	attributeController.RegisterRoutes()
+	ruleController.RegisterRoutes()
+	scimController.RegisterRoutes()
 	infoController := info.NewController(server, cfg, db, logger)
 	infoController.RegisterRoutes()
-	return server
+	return server, employeeService, accessRequestService, certificationService
+}
+
+// buildConnectors коннекторы выгрузки из PROVISIONING_CONFIG; без файла выгрузка отключена
+func buildConnectors(cfg common.Config) (map[string]provisioning.Connector, error) {
+	if cfg.ProvisioningConfig == "" {
+		return nil, nil
+	}
+	configs, err := provisioning.LoadConfig(cfg.ProvisioningConfig)
+	if err != nil {
+		return nil, err
+	}
+	registry := provisioning.NewRegistry()
+	registry.Register(provisioning.FileConnectorType, provisioning.NewFileConnector)
+	return registry.Build(configs)
+}
+
+// buildOAuth выдача токенов IDM по client_credentials: токены подписываются ключами из каталога,
+// и API принимает их наряду с токенами внешнего издателя
+func buildOAuth(
+	cfg common.Config,
+	db *sqlx.DB,
+	signingKeys *auth.SigningKeys,
+	permissionService *permission.Service,
+	auditService *audit.Service,
+	vld *validator.Validator,
+	authenticator *auth.Authenticator,
+	logger *common.Logger,
+) *oauth.Service {
+	if err := authenticator.AcceptLocalTokens(signingKeys, cfg.OAuthIssuer, cfg.AuthAudience); err != nil {
+		logger.Panic("oauth setup error", zap.Error(err))
+	}
+	settings := oauth.TokenSettings{Issuer: cfg.OAuthIssuer, Audience: cfg.AuthAudience, Ttl: cfg.OAuthTokenTtl}
+	return oauth.NewService(oauth.NewRepository(db), permissionService, signingKeys, settings, auditService, vld)
 }
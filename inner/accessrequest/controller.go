@@ -0,0 +1,225 @@
+package accessrequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты запросов доступа
+const (
+	permissionCreate  = "access_requests:create"
+	permissionRead    = "access_requests:read"
+	permissionApprove = "access_requests:approve"
+	permissionManage  = "access_requests:manage"
+)
+
+type Controller struct {
+	server               *web.Server
+	accessRequestService Svc
+	logger               *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll(request ListRequest) (common.Page[Response], error)
+	Approve(ctx context.Context, request DecisionRequest) (Response, error)
+	Reject(ctx context.Context, request DecisionRequest) (Response, error)
+	Cancel(ctx context.Context, request IdRequest) (Response, error)
+	AssignApprovers(ctx context.Context, request ApproversRequest) (Response, error)
+}
+
+func NewController(server *web.Server, accessRequestService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:               server,
+		accessRequestService: accessRequestService,
+		logger:               logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/access-requests", c.server.Require(permissionCreate), c.CreateAccessRequest)
+	c.server.GroupApiV1.Get("/access-requests", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/access-requests/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Post("/access-requests/:id/approve", c.server.Require(permissionApprove), c.Approve)
+	c.server.GroupApiV1.Post("/access-requests/:id/reject", c.server.Require(permissionApprove), c.Reject)
+	c.server.GroupApiV1.Post("/access-requests/:id/cancel", c.server.Require(permissionCreate), c.Cancel)
+	c.server.GroupApiV1.Put("/access-requests/:id/approvers", c.server.Require(permissionManage), c.AssignApprovers)
+}
+
+func (c *Controller) CreateAccessRequest(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create access request: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create access request: received request",
+		zap.Int64("role_id", request.RoleId), zap.Strings("approvers", request.Approvers))
+	response, err := c.accessRequestService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create access request: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create access request: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		c.logger.Error("find access requests: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find access requests: received request", zap.Any("request", request))
+	page, err := c.accessRequestService.FindAll(request)
+	if err != nil {
+		c.logger.Error("find access requests: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find access requests: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find access request by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find access request by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.accessRequestService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find access request by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find access request by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Approve(ctx *fiber.Ctx) error {
+	return c.decide(ctx, "approve", c.accessRequestService.Approve)
+}
+
+func (c *Controller) Reject(ctx *fiber.Ctx) error {
+	return c.decide(ctx, "reject", c.accessRequestService.Reject)
+}
+
+// decide тело запроса с комментарием необязательно
+func (c *Controller) decide(
+	ctx *fiber.Ctx,
+	name string,
+	decide func(ctx context.Context, request DecisionRequest) (Response, error),
+) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug(name+" access request: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error(name+" access request: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request DecisionRequest
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&request); err != nil {
+			c.logger.Error(name+" access request: failed to parse request body", zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		}
+	}
+	request.Id = id
+	response, err := decide(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error(name+" access request: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug(name+" access request: success", zap.Int64("id", id), zap.String("status", response.Status))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Cancel(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("cancel access request: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("cancel access request: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.accessRequestService.Cancel(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("cancel access request: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("cancel access request: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) AssignApprovers(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("assign access request approvers: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("assign access request approvers: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request ApproversRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("assign access request approvers: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.accessRequestService.AssignApprovers(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("assign access request approvers: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("assign access request approvers: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+// parseListRequest прочитать фильтры запросов из query: status, employee_id, role_id, approver
+func parseListRequest(ctx *fiber.Ctx) (request ListRequest, err error) {
+	if request.PageRequest, err = common.ParsePageRequest(ctx); err != nil {
+		return request, err
+	}
+	request.Status = ctx.Query("status")
+	request.Approver = ctx.Query("approver")
+	if request.EmployeeId, err = parseOptionalId(ctx, "employee_id"); err != nil {
+		return request, err
+	}
+	if request.RoleId, err = parseOptionalId(ctx, "role_id"); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+func parseOptionalId(ctx *fiber.Ctx, name string) (*int64, error) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return &id, nil
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.ForbiddenError{}):
+		return fiber.StatusForbidden
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.SodConflictError{}):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
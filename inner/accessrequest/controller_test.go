@@ -0,0 +1,213 @@
+package accessrequest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
+}
+
+func (svc *MockService) Approve(ctx context.Context, request DecisionRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Reject(ctx context.Context, request DecisionRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Cancel(ctx context.Context, request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) AssignApprovers(ctx context.Context, request ApproversRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func TestControllerCreateAccessRequest(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return created request", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		request := CreateRequest{RoleId: 2, Justification: "need access to deploy", Approvers: []string{"bob"}}
+		svc.On("Create", request).Return(Response{Id: 5, RoleId: 2, Status: StatusPending}, nil)
+
+		body := `{"role_id":2,"justification":"need access to deploy","approvers":["bob"]}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/access-requests", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(5), responseBody.Data.Id)
+		a.Equal(StatusPending, responseBody.Data.Status)
+	})
+
+	t.Run("should return bad request for duplicate request", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.Anything).
+			Return(Response{}, common.AlreadyExistsError{Message: "employee 1 already has a pending request for role 2"})
+
+		body := `{"role_id":2,"justification":"again","approvers":["bob"]}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/access-requests", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters from query", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		roleId := int64(2)
+		svc.On("FindAll", mock.MatchedBy(func(r ListRequest) bool {
+			return r.Status == StatusPending && r.Approver == "bob" && r.RoleId != nil && *r.RoleId == roleId && r.EmployeeId == nil
+		})).Return(common.Page[Response]{Items: []Response{{Id: 5}}, PageInfo: common.PageInfo{Total: 1}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/access-requests?status=pending&approver=bob&role_id=2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("should return bad request for invalid employee_id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/access-requests?employee_id=abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
+
+func TestControllerDecide(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should approve with comment", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Approve", DecisionRequest{Id: 5, Comment: "ok"}).Return(Response{Id: 5, Status: StatusApproved}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/access-requests/5/approve", strings.NewReader(`{"comment":"ok"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should reject without body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Reject", DecisionRequest{Id: 5}).Return(Response{Id: 5, Status: StatusRejected}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/access-requests/5/reject", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return forbidden for actor who is not approver", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Approve", DecisionRequest{Id: 5}).
+			Return(Response{}, common.ForbiddenError{Message: "mallory is not a pending approver of access request 5"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/access-requests/5/approve", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestControllerAssignApprovers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should take id from path", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("AssignApprovers", ApproversRequest{Id: 5, Approvers: []string{"dave"}}).
+			Return(Response{Id: 5, Status: StatusPending}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/access-requests/5/approvers", strings.NewReader(`{"approvers":["dave"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+}
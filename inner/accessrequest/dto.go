@@ -0,0 +1,129 @@
+package accessrequest
+
+import "time"
+
+// Состояния запроса доступа; все, кроме pending, конечные
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+// Решения согласующего
+const (
+	DecisionApproved = "approved"
+	DecisionRejected = "rejected"
+)
+
+// Entity запрос роли для сотрудника. ValidFrom и ValidTo - срок будущего назначения:
+// без ValidFrom роль назначается с момента одобрения
+type Entity struct {
+	Id            int64      `db:"id"`
+	EmployeeId    int64      `db:"employee_id"`
+	RoleId        int64      `db:"role_id"`
+	Justification string     `db:"justification"`
+	ValidFrom     *time.Time `db:"valid_from"`
+	ValidTo       *time.Time `db:"valid_to"`
+	Status        string     `db:"status"`
+	RequestedBy   string     `db:"requested_by"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	DecidedBy     *string    `db:"decided_by"`
+	DecidedAt     *time.Time `db:"decided_at"`
+	AssignmentId  *int64     `db:"assignment_id"`
+	CreatedAt     time.Time  `db:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+}
+
+// ApproverEntity согласующий запроса и его решение; Decision равен nil, пока согласующий не ответил
+type ApproverEntity struct {
+	RequestId int64      `db:"request_id"`
+	Approver  string     `db:"approver"`
+	Decision  *string    `db:"decision"`
+	Comment   *string    `db:"comment"`
+	DecidedAt *time.Time `db:"decided_at"`
+}
+
+func (e *Entity) toResponse(approvers []ApproverEntity) Response {
+	response := Response{
+		Id:            e.Id,
+		EmployeeId:    e.EmployeeId,
+		RoleId:        e.RoleId,
+		Justification: e.Justification,
+		ValidFrom:     e.ValidFrom,
+		ValidTo:       e.ValidTo,
+		Status:        e.Status,
+		RequestedBy:   e.RequestedBy,
+		ExpiresAt:     e.ExpiresAt,
+		DecidedBy:     e.DecidedBy,
+		DecidedAt:     e.DecidedAt,
+		AssignmentId:  e.AssignmentId,
+		Approvers:     make([]ApproverResponse, 0, len(approvers)),
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+	}
+	for _, a := range approvers {
+		response.Approvers = append(response.Approvers, ApproverResponse{
+			Approver:  a.Approver,
+			Decision:  a.Decision,
+			Comment:   a.Comment,
+			DecidedAt: a.DecidedAt,
+		})
+	}
+	return response
+}
+
+// auditSnapshot состояние запроса в журнале аудита
+type auditSnapshot struct {
+	Id           int64      `json:"id"`
+	EmployeeId   int64      `json:"employee_id"`
+	RoleId       int64      `json:"role_id"`
+	Status       string     `json:"status"`
+	Approvers    []string   `json:"approvers,omitempty"`
+	DecidedBy    *string    `json:"decided_by"`
+	DecidedAt    *time.Time `json:"decided_at"`
+	AssignmentId *int64     `json:"assignment_id"`
+}
+
+func (e *Entity) auditSnapshot(approvers []ApproverEntity) auditSnapshot {
+	snapshot := auditSnapshot{
+		Id:           e.Id,
+		EmployeeId:   e.EmployeeId,
+		RoleId:       e.RoleId,
+		Status:       e.Status,
+		Approvers:    make([]string, 0, len(approvers)),
+		DecidedBy:    e.DecidedBy,
+		DecidedAt:    e.DecidedAt,
+		AssignmentId: e.AssignmentId,
+	}
+	for _, a := range approvers {
+		snapshot.Approvers = append(snapshot.Approvers, a.Approver)
+	}
+	return snapshot
+}
+
+type Response struct {
+	Id            int64              `json:"id"`
+	EmployeeId    int64              `json:"employee_id"`
+	RoleId        int64              `json:"role_id"`
+	Justification string             `json:"justification"`
+	ValidFrom     *time.Time         `json:"valid_from,omitempty"`
+	ValidTo       *time.Time         `json:"valid_to,omitempty"`
+	Status        string             `json:"status"`
+	RequestedBy   string             `json:"requested_by"`
+	ExpiresAt     time.Time          `json:"expires_at"`
+	DecidedBy     *string            `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time         `json:"decided_at,omitempty"`
+	AssignmentId  *int64             `json:"assignment_id,omitempty"`
+	Approvers     []ApproverResponse `json:"approvers"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type ApproverResponse struct {
+	Approver  string     `json:"approver"`
+	Decision  *string    `json:"decision,omitempty"`
+	Comment   *string    `json:"comment,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
@@ -0,0 +1,48 @@
+package accessrequest
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"time"
+)
+
+// expiryActor автор истечения запросов в журнале аудита
+const expiryActor = "system:access-request-expiry"
+
+type ExpireSvc interface {
+	Expire(ctx context.Context, now time.Time) (int, error)
+}
+
+// Expirer переводит в expired запросы доступа, которые не получили решения до своего срока
+type Expirer struct {
+	svc    ExpireSvc
+	logger *common.Logger
+}
+
+func NewExpirer(svc ExpireSvc, logger *common.Logger) *Expirer {
+	return &Expirer{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+// Run проверять сроки запросов сразу и затем каждые interval, пока не отменён ctx
+func (e *Expirer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		expired, err := e.svc.Expire(common.WithActor(ctx, expiryActor), time.Now())
+		if err != nil {
+			e.logger.Error("access requests: expiring failed", zap.Error(err))
+		}
+		if expired > 0 {
+			e.logger.Info("access requests: expired", zap.Int("count", expired))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
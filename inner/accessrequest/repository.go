@@ -0,0 +1,140 @@
+package accessrequest
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into access_request (employee_id, role_id, justification, valid_from, valid_to, requested_by, expires_at)
+		values ($1, $2, $3, $4, $5, $6, $7) returning *`
+	err = tx.Get(&saved, query, e.EmployeeId, e.RoleId, e.Justification, e.ValidFrom, e.ValidTo, e.RequestedBy, e.ExpiresAt)
+	return saved, err
+}
+
+// ExistsPendingTx есть ли у сотрудника нерассмотренный запрос этой роли
+func (r *Repository) ExistsPendingTx(tx *sqlx.Tx, employeeId, roleId int64) (exists bool, err error) {
+	query := "select exists(select 1 from access_request where employee_id = $1 and role_id = $2 and status = 'pending')"
+	err = tx.Get(&exists, query, employeeId, roleId)
+	return exists, err
+}
+
+// ReplaceApproversTx оставить у запроса согласующих approvers: не ответившие согласующие, которых нет
+// в списке, удаляются, новые добавляются; ответившие остаются в любом случае
+func (r *Repository) ReplaceApproversTx(tx *sqlx.Tx, requestId int64, approvers []string) error {
+	query := "delete from access_request_approver where request_id = $1 and decision is null and approver <> all($2)"
+	if _, err := tx.Exec(query, requestId, pq.Array(approvers)); err != nil {
+		return err
+	}
+	query = `insert into access_request_approver (request_id, approver)
+		select $1, unnest($2::text[])
+		on conflict (request_id, approver) do nothing`
+	_, err := tx.Exec(query, requestId, pq.Array(approvers))
+	return err
+}
+
+// DecideTx записать решение согласующего; false, если он не согласующий запроса или уже ответил
+func (r *Repository) DecideTx(tx *sqlx.Tx, requestId int64, approver, decision, comment string, at time.Time) (bool, error) {
+	query := `update access_request_approver set decision = $3, comment = nullif($4, ''), decided_at = $5
+		where request_id = $1 and approver = $2 and decision is null`
+	result, err := tx.Exec(query, requestId, approver, decision, comment, at)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FindApproversTx согласующие запроса в порядке логинов
+func (r *Repository) FindApproversTx(tx *sqlx.Tx, requestId int64) (approvers []ApproverEntity, err error) {
+	query := "select * from access_request_approver where request_id = $1 order by approver"
+	err = tx.Select(&approvers, query, requestId)
+	return approvers, err
+}
+
+// FindApprovers согласующие запросов requestIds
+func (r *Repository) FindApprovers(requestIds []int64) (approvers []ApproverEntity, err error) {
+	query := "select * from access_request_approver where request_id = any($1) order by request_id, approver"
+	err = r.db.Select(&approvers, query, pq.Array(requestIds))
+	return approvers, err
+}
+
+// UpdateStatusTx сохранить состояние запроса, автора и время решения и созданное назначение
+func (r *Repository) UpdateStatusTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := `update access_request set status = $2, decided_by = $3, decided_at = $4, assignment_id = $5
+		where id = $1 returning *`
+	err = tx.Get(&updated, query, e.Id, e.Status, e.DecidedBy, e.DecidedAt, e.AssignmentId)
+	return updated, err
+}
+
+// ExpireTx перевести в expired нерассмотренные запросы, срок которых истёк к моменту now
+func (r *Repository) ExpireTx(tx *sqlx.Tx, now time.Time) (expired []Entity, err error) {
+	query := `update access_request set status = 'expired', decided_at = $1
+		where status = 'pending' and expires_at <= $1
+		returning *`
+	err = tx.Select(&expired, query, now)
+	return expired, err
+}
+
+func (r *Repository) FindById(id int64) (request Entity, err error) {
+	query := "select * from access_request where id = $1"
+	err = r.db.Get(&request, query, id)
+	return request, err
+}
+
+// FindByIdForUpdateTx найти запрос и заблокировать его до конца транзакции: решения согласующих
+// по одному запросу принимаются по очереди
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (request Entity, err error) {
+	query := "select * from access_request where id = $1 for update"
+	err = tx.Get(&request, query, id)
+	return request, err
+}
+
+// FindPage страница запросов по фильтру, начиная после курсора after
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (requests []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	query := "select * from access_request" + conditions.Where() + order
+	err = r.db.Select(&requests, query, conditions.Args()...)
+	return requests, err
+}
+
+// Count количество запросов, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from access_request" + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.Status != "" {
+		conditions.Add("status = ?", request.Status)
+	}
+	if request.EmployeeId != nil {
+		conditions.Add("employee_id = ?", *request.EmployeeId)
+	}
+	if request.RoleId != nil {
+		conditions.Add("role_id = ?", *request.RoleId)
+	}
+	if request.Approver != "" {
+		conditions.Add("id in (select request_id from access_request_approver where approver = ?)", request.Approver)
+	}
+	return conditions
+}
@@ -0,0 +1,43 @@
+package accessrequest
+
+import (
+	"idm/inner/common"
+	"time"
+)
+
+// CreateRequest запросить роль. Без EmployeeId роль запрашивается для сотрудника, от имени которого
+// выполняется запрос. Approvers - логины согласующих: роль будет назначена, когда одобрят все
+type CreateRequest struct {
+	EmployeeId    *int64     `json:"employee_id" validate:"omitempty,gt=0"`
+	RoleId        int64      `json:"role_id" validate:"required,gt=0"`
+	Justification string     `json:"justification" validate:"required,max=2000"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to"`
+	Approvers     []string   `json:"approvers" validate:"required,min=1,max=5,unique,dive,required,max=255"`
+}
+
+// DecisionRequest одобрить или отклонить запрос; Comment сохраняется вместе с решением
+type DecisionRequest struct {
+	Id      int64  `json:"id" validate:"required,gt=0"`
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+// ApproversRequest заменить согласующих, которые ещё не ответили; принятые решения сохраняются
+type ApproversRequest struct {
+	Id        int64    `json:"id" validate:"required,gt=0"`
+	Approvers []string `json:"approvers" validate:"required,min=1,max=5,unique,dive,required,max=255"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// ListRequest фильтры запросов доступа. Запросы всегда упорядочены по id, по умолчанию от новых к старым.
+// Approver - запросы, в которых указан этот согласующий
+type ListRequest struct {
+	common.PageRequest
+	Status     string `json:"status" validate:"omitempty,oneof=pending approved rejected expired cancelled"`
+	EmployeeId *int64 `json:"employee_id" validate:"omitempty,gt=0"`
+	RoleId     *int64 `json:"role_id" validate:"omitempty,gt=0"`
+	Approver   string `json:"approver" validate:"max=255"`
+}
@@ -0,0 +1,551 @@
+package accessrequest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "access_request"
+
+// Действия с запросом в журнале аудита, кроме создания
+const (
+	auditActionApprove         = "approve"
+	auditActionReject          = "reject"
+	auditActionCancel          = "cancel"
+	auditActionExpire          = "expire"
+	auditActionAssignApprovers = "assign_approvers"
+)
+
+type Service struct {
+	repo         Repo
+	employeeRepo EmployeeRepo
+	roleRepo     RoleRepo
+	granter      Granter
+	permissions  PermissionChecker
+	auditor      Auditor
+	validator    Validator
+	ttl          time.Duration
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExistsPendingTx(tx *sqlx.Tx, employeeId, roleId int64) (bool, error)
+	ReplaceApproversTx(tx *sqlx.Tx, requestId int64, approvers []string) error
+	DecideTx(tx *sqlx.Tx, requestId int64, approver, decision, comment string, at time.Time) (bool, error)
+	FindApproversTx(tx *sqlx.Tx, requestId int64) ([]ApproverEntity, error)
+	FindApprovers(requestIds []int64) ([]ApproverEntity, error)
+	UpdateStatusTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExpireTx(tx *sqlx.Tx, now time.Time) ([]Entity, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
+}
+
+type EmployeeRepo interface {
+	FindById(id int64) (employee.Entity, error)
+	FindByLogin(login string) (employee.Entity, error)
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+}
+
+// Granter назначает роль в транзакции одобрения запроса - так же, как при назначении через API
+type Granter interface {
+	GrantTx(ctx context.Context, tx *sqlx.Tx, request assignment.GrantRequest) (int64, error)
+}
+
+// PermissionChecker проверяет разрешения сотрудника по логину; неработающий сотрудник разрешений не имеет
+type PermissionChecker interface {
+	HasPermission(subject, name string) (bool, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// NewService ttl - сколько запрос ждёт решения, прежде чем истечь
+func NewService(
+	repo Repo,
+	employeeRepo EmployeeRepo,
+	roleRepo RoleRepo,
+	granter Granter,
+	permissions PermissionChecker,
+	auditor Auditor,
+	validator Validator,
+	ttl time.Duration,
+) *Service {
+	return &Service{
+		repo:         repo,
+		employeeRepo: employeeRepo,
+		roleRepo:     roleRepo,
+		granter:      granter,
+		permissions:  permissions,
+		auditor:      auditor,
+		validator:    validator,
+		ttl:          ttl,
+	}
+}
+
+// Create запросить роль для сотрудника. Согласующим не может быть ни автор запроса, ни сам сотрудник
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if request.ValidFrom != nil && request.ValidTo != nil && !request.ValidTo.After(*request.ValidFrom) {
+		return Response{}, common.RequestValidationError{Message: "valid_to must be after valid_from"}
+	}
+	actor := common.ActorFrom(ctx)
+	subject, err := svc.findSubject(actor, request.EmployeeId)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err = svc.roleRepo.FindById(request.RoleId); err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.RoleId, err),
+		}
+	}
+	if err = svc.checkApprovers(request.Approvers, actor, subject); err != nil {
+		return Response{}, err
+	}
+
+	var saved Entity
+	var approvers []ApproverEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating access request", func(tx *sqlx.Tx) error {
+		exists, err := svc.repo.ExistsPendingTx(tx, subject.Id, request.RoleId)
+		if err != nil {
+			return fmt.Errorf("error checking access requests of employee %d: %w", subject.Id, err)
+		}
+		if exists {
+			return common.AlreadyExistsError{
+				Message: fmt.Sprintf("employee %d already has a pending request for role %d", subject.Id, request.RoleId),
+			}
+		}
+		saved, err = svc.repo.SaveTx(tx, Entity{
+			EmployeeId:    subject.Id,
+			RoleId:        request.RoleId,
+			Justification: request.Justification,
+			ValidFrom:     request.ValidFrom,
+			ValidTo:       request.ValidTo,
+			RequestedBy:   actor,
+			ExpiresAt:     time.Now().Add(svc.ttl),
+		})
+		if err != nil {
+			return fmt.Errorf("error saving access request for role %d: %w", request.RoleId, err)
+		}
+		if approvers, err = svc.replaceApproversTx(tx, saved.Id, request.Approvers); err != nil {
+			return err
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(approvers),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(approvers), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding access request with id %d: %v", request.Id, err),
+		}
+	}
+	responses, err := svc.toResponses([]Entity{entity})
+	if err != nil {
+		return Response{}, err
+	}
+	return responses[0], nil
+}
+
+// FindAll найти страницу запросов доступа по фильтру
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Sort = "id"
+	if request.Order == "" {
+		request.Order = "desc"
+	}
+	request.Defaults()
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	requests, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error retrieving access requests: %w", err)
+	}
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting access requests: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(requests) > request.Limit {
+		requests = requests[:request.Limit]
+		last := requests[len(requests)-1]
+		page.NextCursor = request.Next(strconv.FormatInt(last.Id, 10), last.Id)
+	}
+	if page.Items, err = svc.toResponses(requests); err != nil {
+		return common.Page[Response]{}, err
+	}
+	return page, nil
+}
+
+// Approve одобрить запрос от имени согласующего. Когда одобрили все согласующие, роль назначается
+// сотруднику в той же транзакции; если назначить роль нельзя, решение не сохраняется
+func (svc *Service) Approve(ctx context.Context, request DecisionRequest) (Response, error) {
+	return svc.decide(ctx, request, DecisionApproved)
+}
+
+// Reject отклонить запрос от имени согласующего; одного отказа достаточно, чтобы отклонить запрос
+func (svc *Service) Reject(ctx context.Context, request DecisionRequest) (Response, error) {
+	return svc.decide(ctx, request, DecisionRejected)
+}
+
+func (svc *Service) decide(ctx context.Context, request DecisionRequest, decision string) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	actor := common.ActorFrom(ctx)
+	var updated Entity
+	var approvers []ApproverEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "deciding access request", func(tx *sqlx.Tx) error {
+		now := time.Now()
+		before, err := svc.findPendingTx(tx, request.Id, now)
+		if err != nil {
+			return err
+		}
+		beforeApprovers, err := svc.repo.FindApproversTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error finding approvers of access request %d: %w", request.Id, err)
+		}
+		decided, err := svc.repo.DecideTx(tx, request.Id, actor, decision, request.Comment, now)
+		if err != nil {
+			return fmt.Errorf("error saving decision on access request %d: %w", request.Id, err)
+		}
+		if !decided {
+			return common.ForbiddenError{
+				Message: fmt.Sprintf("%s is not a pending approver of access request %d", actor, request.Id),
+			}
+		}
+		if approvers, err = svc.repo.FindApproversTx(tx, request.Id); err != nil {
+			return fmt.Errorf("error finding approvers of access request %d: %w", request.Id, err)
+		}
+		if updated, err = svc.settleTx(ctx, tx, before, approvers, actor, now); err != nil {
+			return err
+		}
+		action := auditActionApprove
+		if decision == DecisionRejected {
+			action = auditActionReject
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     action,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(beforeApprovers),
+			After:      updated.auditSnapshot(approvers),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(approvers), nil
+}
+
+// Cancel отозвать запрос; отозвать его может только тот, кто его создал
+func (svc *Service) Cancel(ctx context.Context, request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	actor := common.ActorFrom(ctx)
+	var updated Entity
+	var approvers []ApproverEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "cancelling access request", func(tx *sqlx.Tx) error {
+		now := time.Now()
+		before, err := svc.findPendingTx(tx, request.Id, now)
+		if err != nil {
+			return err
+		}
+		if before.RequestedBy != actor {
+			return common.ForbiddenError{
+				Message: fmt.Sprintf("access request %d can be cancelled only by %s", request.Id, before.RequestedBy),
+			}
+		}
+		if approvers, err = svc.repo.FindApproversTx(tx, request.Id); err != nil {
+			return fmt.Errorf("error finding approvers of access request %d: %w", request.Id, err)
+		}
+		updated, err = svc.finishTx(tx, before, StatusCancelled, actor, now, nil)
+		if err != nil {
+			return err
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionCancel,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(approvers),
+			After:      updated.auditSnapshot(approvers),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(approvers), nil
+}
+
+// AssignApprovers заменить согласующих нерассмотренного запроса. Если после замены все оставшиеся
+// согласующие уже одобрили запрос, он одобряется и роль назначается
+func (svc *Service) AssignApprovers(ctx context.Context, request ApproversRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	actor := common.ActorFrom(ctx)
+	var updated Entity
+	var approvers []ApproverEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "assigning access request approvers", func(tx *sqlx.Tx) error {
+		now := time.Now()
+		before, err := svc.findPendingTx(tx, request.Id, now)
+		if err != nil {
+			return err
+		}
+		subject, err := svc.employeeRepo.FindById(before.EmployeeId)
+		if err != nil {
+			return fmt.Errorf("error finding employee with id %d: %w", before.EmployeeId, err)
+		}
+		if err = svc.checkApprovers(request.Approvers, before.RequestedBy, subject); err != nil {
+			return err
+		}
+		beforeApprovers, err := svc.repo.FindApproversTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error finding approvers of access request %d: %w", request.Id, err)
+		}
+		if approvers, err = svc.replaceApproversTx(tx, request.Id, request.Approvers); err != nil {
+			return err
+		}
+		if updated, err = svc.settleTx(ctx, tx, before, approvers, actor, now); err != nil {
+			return err
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionAssignApprovers,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(beforeApprovers),
+			After:      updated.auditSnapshot(approvers),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(approvers), nil
+}
+
+// Expire перевести в expired запросы, не получившие решения до своего срока; возвращает их число
+func (svc *Service) Expire(ctx context.Context, now time.Time) (int, error) {
+	var expired []Entity
+	err := database.InTransaction(svc.repo.BeginTransaction, "expiring access requests", func(tx *sqlx.Tx) (err error) {
+		if expired, err = svc.repo.ExpireTx(tx, now); err != nil {
+			return fmt.Errorf("error expiring access requests: %w", err)
+		}
+		for _, after := range expired {
+			before := after
+			before.Status = StatusPending
+			before.DecidedAt = nil
+			err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+				Action:     auditActionExpire,
+				EntityType: auditEntityType,
+				EntityId:   after.Id,
+				Before:     before.auditSnapshot(nil),
+				After:      after.auditSnapshot(nil),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// settleTx перевести запрос в конечное состояние по решениям согласующих: отказ любого - rejected,
+// одобрение всех - approved с назначением роли; иначе запрос остаётся pending
+func (svc *Service) settleTx(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	entity Entity,
+	approvers []ApproverEntity,
+	actor string,
+	now time.Time,
+) (Entity, error) {
+	approved := len(approvers) > 0
+	for _, a := range approvers {
+		if a.Decision != nil && *a.Decision == DecisionRejected {
+			return svc.finishTx(tx, entity, StatusRejected, actor, now, nil)
+		}
+		approved = approved && a.Decision != nil && *a.Decision == DecisionApproved
+	}
+	if !approved {
+		return entity, nil
+	}
+	assignmentId, err := svc.granter.GrantTx(ctx, tx, assignment.GrantRequest{
+		EmployeeId: entity.EmployeeId,
+		RoleId:     entity.RoleId,
+		ValidFrom:  entity.ValidFrom,
+		ValidTo:    entity.ValidTo,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+	return svc.finishTx(tx, entity, StatusApproved, actor, now, &assignmentId)
+}
+
+func (svc *Service) finishTx(tx *sqlx.Tx, entity Entity, status, actor string, now time.Time, assignmentId *int64) (Entity, error) {
+	entity.Status = status
+	entity.DecidedBy = &actor
+	entity.DecidedAt = &now
+	entity.AssignmentId = assignmentId
+	updated, err := svc.repo.UpdateStatusTx(tx, entity)
+	if err != nil {
+		return Entity{}, fmt.Errorf("error updating access request %d: %w", entity.Id, err)
+	}
+	return updated, nil
+}
+
+// findPendingTx найти и заблокировать запрос, по которому ещё можно принимать решения
+func (svc *Service) findPendingTx(tx *sqlx.Tx, id int64, now time.Time) (Entity, error) {
+	entity, err := svc.repo.FindByIdForUpdateTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("access request with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding access request with id %d: %w", id, err)
+	}
+	if entity.Status != StatusPending {
+		return Entity{}, common.RequestValidationError{
+			Message: fmt.Sprintf("access request %d is already %s", id, entity.Status),
+		}
+	}
+	if !entity.ExpiresAt.After(now) {
+		return Entity{}, common.RequestValidationError{Message: fmt.Sprintf("access request %d has expired", id)}
+	}
+	return entity, nil
+}
+
+func (svc *Service) replaceApproversTx(tx *sqlx.Tx, requestId int64, approvers []string) ([]ApproverEntity, error) {
+	if err := svc.repo.ReplaceApproversTx(tx, requestId, approvers); err != nil {
+		return nil, fmt.Errorf("error saving approvers of access request %d: %w", requestId, err)
+	}
+	saved, err := svc.repo.FindApproversTx(tx, requestId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding approvers of access request %d: %w", requestId, err)
+	}
+	return saved, nil
+}
+
+// findSubject сотрудник, которому запрашивается роль: указанный в запросе или сам автор запроса
+func (svc *Service) findSubject(actor string, employeeId *int64) (employee.Entity, error) {
+	if employeeId != nil {
+		found, err := svc.employeeRepo.FindById(*employeeId)
+		if err != nil {
+			return employee.Entity{}, common.NotFoundError{
+				Message: fmt.Sprintf("error finding employee with id %d: %v", *employeeId, err),
+			}
+		}
+		return found, nil
+	}
+	found, err := svc.employeeRepo.FindByLogin(actor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return employee.Entity{}, common.RequestValidationError{
+			Message: fmt.Sprintf("employee_id is required: no employee with login %q", actor),
+		}
+	}
+	if err != nil {
+		return employee.Entity{}, fmt.Errorf("error finding employee with login %s: %w", actor, err)
+	}
+	return found, nil
+}
+
+// checkApprovers запрос нельзя согласовать самому себе; каждый согласующий - работающий сотрудник
+// с разрешением на согласование запросов
+func (svc *Service) checkApprovers(approvers []string, requestedBy string, subject employee.Entity) error {
+	if slices.Contains(approvers, requestedBy) {
+		return common.RequestValidationError{Message: fmt.Sprintf("%s cannot approve own request", requestedBy)}
+	}
+	if subject.Login != nil && slices.Contains(approvers, *subject.Login) {
+		return common.RequestValidationError{
+			Message: fmt.Sprintf("%s cannot approve access request for themselves", *subject.Login),
+		}
+	}
+	for _, approver := range approvers {
+		allowed, err := svc.permissions.HasPermission(approver, permissionApprove)
+		if err != nil {
+			return fmt.Errorf("error checking permissions of approver %s: %w", approver, err)
+		}
+		if !allowed {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("approver %s must be an active employee with permission %s", approver, permissionApprove),
+			}
+		}
+	}
+	return nil
+}
+
+// toResponses запросы вместе с их согласующими
+func (svc *Service) toResponses(entities []Entity) ([]Response, error) {
+	responses := make([]Response, 0, len(entities))
+	if len(entities) == 0 {
+		return responses, nil
+	}
+	ids := make([]int64, 0, len(entities))
+	for _, e := range entities {
+		ids = append(ids, e.Id)
+	}
+	approvers, err := svc.repo.FindApprovers(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error finding approvers of access requests: %w", err)
+	}
+	byRequest := make(map[int64][]ApproverEntity, len(entities))
+	for _, a := range approvers {
+		byRequest[a.RequestId] = append(byRequest[a.RequestId], a)
+	}
+	for _, e := range entities {
+		responses = append(responses, e.toResponse(byRequest[e.Id]))
+	}
+	return responses, nil
+}
@@ -0,0 +1,583 @@
+package accessrequest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"slices"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	if fn, ok := args.Get(0).(func(*sqlx.Tx, Entity) (Entity, error)); ok {
+		return fn(tx, e)
+	}
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsPendingTx(tx *sqlx.Tx, employeeId, roleId int64) (bool, error) {
+	args := m.Called(tx, employeeId, roleId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) ReplaceApproversTx(tx *sqlx.Tx, requestId int64, approvers []string) error {
+	args := m.Called(tx, requestId, approvers)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DecideTx(tx *sqlx.Tx, requestId int64, approver, decision, comment string, at time.Time) (bool, error) {
+	args := m.Called(tx, requestId, approver, decision, comment)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindApproversTx(tx *sqlx.Tx, requestId int64) ([]ApproverEntity, error) {
+	args := m.Called(tx, requestId)
+	return args.Get(0).([]ApproverEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindApprovers(requestIds []int64) ([]ApproverEntity, error) {
+	args := m.Called(requestIds)
+	return args.Get(0).([]ApproverEntity), args.Error(1)
+}
+
+// UpdateStatusTx возвращает сохранённый запрос как есть, как update ... returning *
+func (m *MockRepo) UpdateStatusTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e.Id, e.Status)
+	return e, args.Error(0)
+}
+
+func (m *MockRepo) ExpireTx(tx *sqlx.Tx, now time.Time) ([]Entity, error) {
+	args := m.Called(tx, now)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockEmployeeRepo struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeRepo) FindById(id int64) (employee.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(employee.Entity), args.Error(1)
+}
+
+func (m *MockEmployeeRepo) FindByLogin(login string) (employee.Entity, error) {
+	args := m.Called(login)
+	return args.Get(0).(employee.Entity), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+type MockGranter struct {
+	mock.Mock
+}
+
+func (m *MockGranter) GrantTx(ctx context.Context, tx *sqlx.Tx, request assignment.GrantRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+// StubPermissions разрешает согласование всем, кроме перечисленных в denied
+type StubPermissions struct {
+	denied []string
+}
+
+func (p *StubPermissions) HasPermission(subject, name string) (bool, error) {
+	return !slices.Contains(p.denied, subject), nil
+}
+
+const ttl = 14 * 24 * time.Hour
+
+func login(value string) *string {
+	return &value
+}
+
+func decision(value string) *string {
+	return &value
+}
+
+func asActor(actor string) context.Context {
+	return common.WithActor(context.Background(), actor)
+}
+
+func pending(id int64, requestedBy string) Entity {
+	return Entity{
+		Id:            id,
+		EmployeeId:    1,
+		RoleId:        2,
+		Justification: "need access to deploy",
+		Status:        StatusPending,
+		RequestedBy:   requestedBy,
+		ExpiresAt:     time.Now().Add(time.Hour),
+	}
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should request role for actor and assign approvers", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, new(MockGranter), new(StubPermissions), auditor, validator.New(), ttl)
+
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsPendingTx", noTx, int64(1), int64(2)).Return(false, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			e.Id = 5
+			e.Status = StatusPending
+			stored = e
+			return e, nil
+		})
+		repo.On("ReplaceApproversTx", noTx, int64(5), []string{"bob", "carol"}).Return(nil)
+		repo.On("FindApproversTx", noTx, int64(5)).
+			Return([]ApproverEntity{{RequestId: 5, Approver: "bob"}, {RequestId: 5, Approver: "carol"}}, nil)
+
+		before := time.Now()
+		response, err := svc.Create(asActor("alice"), CreateRequest{
+			RoleId:        2,
+			Justification: "need access to deploy",
+			Approvers:     []string{"bob", "carol"},
+		})
+		a.NoError(err)
+		a.Equal(int64(5), response.Id)
+		a.Equal(StatusPending, response.Status)
+		a.Equal("alice", stored.RequestedBy)
+		a.Equal(int64(1), stored.EmployeeId)
+		a.WithinDuration(before.Add(ttl), stored.ExpiresAt, time.Minute)
+		a.Len(response.Approvers, 2)
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionCreate, auditor.events[0].Action)
+		a.Equal(auditEntityType, auditor.events[0].EntityType)
+	})
+
+	t.Run("should require employee when actor is not an employee", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		employees.On("FindByLogin", "robot").Return(employee.Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Create(asActor("robot"), CreateRequest{RoleId: 2, Justification: "sync", Approvers: []string{"bob"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "employee_id is required")
+	})
+
+	t.Run("should forbid self-approval", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+
+		employeeId := int64(1)
+		_, err := svc.Create(asActor("manager"), CreateRequest{
+			EmployeeId: &employeeId, RoleId: 2, Justification: "new team", Approvers: []string{"alice"},
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		_, err = svc.Create(asActor("manager"), CreateRequest{
+			EmployeeId: &employeeId, RoleId: 2, Justification: "new team", Approvers: []string{"manager"},
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should require approvers to be active employees allowed to approve", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		permissions := &StubPermissions{denied: []string{"mallory"}}
+		svc := NewService(repo, employees, roles, new(MockGranter), permissions, new(StubAuditor), validator.New(), ttl)
+
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			RoleId: 2, Justification: "need it", Approvers: []string{"bob", "mallory"},
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "approver mallory must be an active employee with permission access_requests:approve")
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should reject duplicate pending request", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsPendingTx", noTx, int64(1), int64(2)).Return(true, nil)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{RoleId: 2, Justification: "again", Approvers: []string{"bob"}})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should require justification and approvers", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{RoleId: 2, Approvers: []string{"bob"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		_, err = svc.Create(asActor("alice"), CreateRequest{RoleId: 2, Justification: "need it"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		_, err = svc.Create(asActor("alice"), CreateRequest{RoleId: 2, Justification: "need it", Approvers: []string{"bob", "bob"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceApprove(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should keep request pending until all approvers approve", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(MockGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), granter, new(StubPermissions), auditor, validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{{Approver: "bob"}, {Approver: "carol"}}, nil).Once()
+		repo.On("DecideTx", noTx, int64(5), "bob", DecisionApproved, "ok").Return(true, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).
+			Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionApproved)}, {Approver: "carol"}}, nil)
+
+		response, err := svc.Approve(asActor("bob"), DecisionRequest{Id: 5, Comment: "ok"})
+		a.NoError(err)
+		a.Equal(StatusPending, response.Status)
+		a.True(granter.AssertNotCalled(t, "GrantTx", mock.Anything))
+		a.True(repo.AssertNotCalled(t, "UpdateStatusTx", mock.Anything, mock.Anything, mock.Anything))
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionApprove, auditor.events[0].Action)
+	})
+
+	t.Run("should grant role on final approval", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(MockGranter)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), granter, new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		from := time.Now().Add(24 * time.Hour)
+		request := pending(5, "alice")
+		request.ValidFrom = &from
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(request, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).
+			Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionApproved)}, {Approver: "carol"}}, nil).Once()
+		repo.On("DecideTx", noTx, int64(5), "carol", DecisionApproved, "").Return(true, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{
+			{Approver: "bob", Decision: decision(DecisionApproved)},
+			{Approver: "carol", Decision: decision(DecisionApproved)},
+		}, nil)
+		granter.On("GrantTx", assignment.GrantRequest{EmployeeId: 1, RoleId: 2, ValidFrom: &from}).Return(int64(40), nil)
+		repo.On("UpdateStatusTx", noTx, int64(5), StatusApproved).Return(nil)
+
+		response, err := svc.Approve(asActor("carol"), DecisionRequest{Id: 5})
+		a.NoError(err)
+		a.Equal(StatusApproved, response.Status)
+		a.Equal(int64(40), *response.AssignmentId)
+		a.Equal("carol", *response.DecidedBy)
+		a.NotNil(response.DecidedAt)
+	})
+
+	t.Run("should fail approval if role cannot be granted", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(MockGranter)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), granter, new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		repo.On("DecideTx", noTx, int64(5), "bob", DecisionApproved, "").Return(true, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionApproved)}}, nil)
+		granter.On("GrantTx", mock.Anything).
+			Return(int64(0), common.AlreadyExistsError{Message: "employee 1 already has role 2 within the requested period"})
+
+		_, err := svc.Approve(asActor("bob"), DecisionRequest{Id: 5})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "UpdateStatusTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should forbid decision of actor who is not pending approver", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{{Approver: "bob"}}, nil)
+		repo.On("DecideTx", noTx, int64(5), "mallory", DecisionApproved, "").Return(false, nil)
+
+		_, err := svc.Approve(asActor("mallory"), DecisionRequest{Id: 5})
+		a.ErrorAs(err, &common.ForbiddenError{})
+	})
+
+	t.Run("should not decide request that is expired or already decided", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		overdue := pending(5, "alice")
+		overdue.ExpiresAt = time.Now().Add(-time.Minute)
+		rejected := pending(6, "alice")
+		rejected.Status = StatusRejected
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(overdue, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(6)).Return(rejected, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(7)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Approve(asActor("bob"), DecisionRequest{Id: 5})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "expired")
+		_, err = svc.Approve(asActor("bob"), DecisionRequest{Id: 6})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "already rejected")
+		_, err = svc.Approve(asActor("bob"), DecisionRequest{Id: 7})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceReject(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should reject request on first refusal", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(MockGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), granter, new(StubPermissions), auditor, validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		repo.On("DecideTx", noTx, int64(5), "bob", DecisionRejected, "not needed").Return(true, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).
+			Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionRejected)}, {Approver: "carol"}}, nil)
+		repo.On("UpdateStatusTx", noTx, int64(5), StatusRejected).Return(nil)
+
+		response, err := svc.Reject(asActor("bob"), DecisionRequest{Id: 5, Comment: "not needed"})
+		a.NoError(err)
+		a.Equal(StatusRejected, response.Status)
+		a.Equal("bob", *response.DecidedBy)
+		a.True(granter.AssertNotCalled(t, "GrantTx", mock.Anything))
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionReject, auditor.events[0].Action)
+	})
+}
+
+func TestServiceCancel(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should cancel own request", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), auditor, validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{{Approver: "bob"}}, nil)
+		repo.On("UpdateStatusTx", noTx, int64(5), StatusCancelled).Return(nil)
+
+		response, err := svc.Cancel(asActor("alice"), IdRequest{Id: 5})
+		a.NoError(err)
+		a.Equal(StatusCancelled, response.Status)
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionCancel, auditor.events[0].Action)
+	})
+
+	t.Run("should forbid cancelling request of someone else", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+
+		_, err := svc.Cancel(asActor("bob"), IdRequest{Id: 5})
+		a.ErrorAs(err, &common.ForbiddenError{})
+	})
+}
+
+func TestServiceAssignApprovers(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should approve request when remaining approvers already approved", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		granter := new(MockGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, new(MockRoleRepo), granter, new(StubPermissions), auditor, validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "alice"), nil)
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+		repo.On("FindApproversTx", noTx, int64(5)).
+			Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionApproved)}, {Approver: "carol"}}, nil).Once()
+		repo.On("ReplaceApproversTx", noTx, int64(5), []string{"bob"}).Return(nil)
+		repo.On("FindApproversTx", noTx, int64(5)).Return([]ApproverEntity{{Approver: "bob", Decision: decision(DecisionApproved)}}, nil)
+		granter.On("GrantTx", assignment.GrantRequest{EmployeeId: 1, RoleId: 2}).Return(int64(40), nil)
+		repo.On("UpdateStatusTx", noTx, int64(5), StatusApproved).Return(nil)
+
+		response, err := svc.AssignApprovers(asActor("admin"), ApproversRequest{Id: 5, Approvers: []string{"bob"}})
+		a.NoError(err)
+		a.Equal(StatusApproved, response.Status)
+		a.Equal("admin", *response.DecidedBy)
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionAssignApprovers, auditor.events[0].Action)
+	})
+
+	t.Run("should forbid assigning employee as own approver", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "manager"), nil)
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+
+		_, err := svc.AssignApprovers(asActor("admin"), ApproversRequest{Id: 5, Approvers: []string{"alice"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "ReplaceApproversTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should forbid assigning approver without approve permission", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		permissions := &StubPermissions{denied: []string{"dave"}}
+		svc := NewService(repo, employees, new(MockRoleRepo), new(MockGranter), permissions, new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(pending(5, "manager"), nil)
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1, Login: login("alice")}, nil)
+
+		_, err := svc.AssignApprovers(asActor("admin"), ApproversRequest{Id: 5, Approvers: []string{"bob", "dave"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "approver dave")
+		a.True(repo.AssertNotCalled(t, "ReplaceApproversTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+}
+
+func TestServiceExpire(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should record every expired request", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), auditor, validator.New(), ttl)
+
+		now := time.Now()
+		first := pending(5, "alice")
+		first.Status = StatusExpired
+		second := pending(6, "bob")
+		second.Status = StatusExpired
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExpireTx", noTx, now).Return([]Entity{first, second}, nil)
+
+		expired, err := svc.Expire(context.Background(), now)
+		a.NoError(err)
+		a.Equal(2, expired)
+		a.Len(auditor.events, 2)
+		a.Equal(auditActionExpire, auditor.events[1].Action)
+		a.Equal(int64(6), auditor.events[1].EntityId)
+	})
+
+	t.Run("should return error if requests were not expired", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExpireTx", noTx, mock.Anything).Return([]Entity(nil), errors.New("database is down"))
+
+		_, err := svc.Expire(context.Background(), time.Now())
+		a.ErrorContains(err, "database is down")
+	})
+}
+
+func TestServiceFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return newest requests first with their approvers", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockGranter), new(StubPermissions), new(StubAuditor), validator.New(), ttl)
+
+		expected := ListRequest{
+			PageRequest: common.PageRequest{Limit: 1, Sort: "id", Order: "desc"},
+			Approver:    "bob",
+		}
+		repo.On("FindPage", expected, (*common.Cursor)(nil)).Return([]Entity{pending(6, "alice"), pending(5, "alice")}, nil)
+		repo.On("Count", expected).Return(int64(2), nil)
+		repo.On("FindApprovers", []int64{6}).Return([]ApproverEntity{{RequestId: 6, Approver: "bob"}}, nil)
+
+		page, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Limit: 1}, Approver: "bob"})
+		a.NoError(err)
+		a.Len(page.Items, 1)
+		a.Equal(int64(6), page.Items[0].Id)
+		a.Equal("bob", page.Items[0].Approvers[0].Approver)
+		a.Equal(int64(2), page.Total)
+		a.NotEmpty(page.NextCursor)
+	})
+}
@@ -0,0 +1,148 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты ключей доступа
+const (
+	permissionRead   = "apikeys:read"
+	permissionManage = "apikeys:manage"
+)
+
+type Controller struct {
+	server        *web.Server
+	apiKeyService Svc
+	logger        *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (SecretResponse, error)
+	Rotate(ctx context.Context, request RotateRequest) (SecretResponse, error)
+	Revoke(ctx context.Context, request IdRequest) error
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+}
+
+func NewController(server *web.Server, apiKeyService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:        server,
+		apiKeyService: apiKeyService,
+		logger:        logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/api-keys", c.server.Require(permissionManage), c.CreateApiKey)
+	c.server.GroupApiV1.Get("/api-keys/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/api-keys", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Post("/api-keys/:id/rotate", c.server.Require(permissionManage), c.Rotate)
+	c.server.GroupApiV1.Delete("/api-keys/:id", c.server.Require(permissionManage), c.Revoke)
+}
+
+func (c *Controller) CreateApiKey(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create api key: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create api key: received request",
+		zap.String("account", request.Account), zap.Strings("permissions", request.Permissions))
+	response, err := c.apiKeyService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create api key: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create api key: success", zap.Int64("id", response.Id), zap.String("prefix", response.Prefix))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find api key by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find api key by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.apiKeyService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find api key by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find api key by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all api keys: received request")
+	responses, err := c.apiKeyService.FindAll()
+	if err != nil {
+		c.logger.Error("find all api keys: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all api keys: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+// Rotate тело запроса необязательно: без него старый ключ перестаёт действовать сразу
+func (c *Controller) Rotate(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("rotate api key: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("rotate api key: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request RotateRequest
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&request); err != nil {
+			c.logger.Error("rotate api key: failed to parse request body", zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		}
+	}
+	request.Id = id
+	response, err := c.apiKeyService.Rotate(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("rotate api key: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("rotate api key: success", zap.Int64("id", id), zap.Int64("new_id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Revoke(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("revoke api key: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("revoke api key: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.apiKeyService.Revoke(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("revoke api key: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("revoke api key: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.ForbiddenError{}):
+		return fiber.StatusForbidden
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,214 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(SecretResponse), args.Error(1)
+}
+
+func (svc *MockService) Rotate(ctx context.Context, request RotateRequest) (SecretResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(SecretResponse), args.Error(1)
+}
+
+func (svc *MockService) Revoke(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func TestControllerCreateApiKey(t *testing.T) {
+	a := assert.New(t)
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should return key with secret", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		request := CreateRequest{Account: "hr-sync", Permissions: []string{"employees:read"}, ExpiresAt: expiresAt}
+		svc.On("Create", request).Return(SecretResponse{
+			Response: Response{Id: 5, Prefix: "0011223344556677", Account: "hr-sync"},
+			Key:      "idm_0011223344556677.secret",
+		}, nil)
+
+		body := `{"account":"hr-sync","permissions":["employees:read"],"expires_at":"2030-01-01T00:00:00Z"}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/api-keys", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[SecretResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(5), responseBody.Data.Id)
+		a.Equal("idm_0011223344556677.secret", responseBody.Data.Key)
+	})
+
+	t.Run("should return bad request for unknown permission", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.Anything).
+			Return(SecretResponse{}, common.RequestValidationError{Message: "unknown permission employees:purge"})
+
+		body := `{"account":"hr-sync","permissions":["employees:purge"],"expires_at":"2030-01-01T00:00:00Z"}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/api-keys", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerRotate(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass overlap from body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Rotate", RotateRequest{Id: 3, OverlapSeconds: 3600}).
+			Return(SecretResponse{Response: Response{Id: 4}, Key: "idm_8899aabbccddeeff.secret"}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/api-keys/3/rotate", strings.NewReader(`{"overlap_seconds":3600}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[SecretResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal("idm_8899aabbccddeeff.secret", responseBody.Data.Key)
+	})
+
+	t.Run("should rotate without body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Rotate", RotateRequest{Id: 3}).Return(SecretResponse{Response: Response{Id: 4}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/api-keys/3/rotate", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Rotate", RotateRequest{Id: 9}).Return(SecretResponse{}, common.NotFoundError{Message: "not found"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/api-keys/9/rotate", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerRevoke(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should revoke key", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Revoke", IdRequest{Id: 3}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/api-keys/3", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request for invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/api-keys/abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Revoke", mock.Anything))
+	})
+}
+
+func TestControllerFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should list keys without secrets", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll").Return([]Response{{Id: 3, Account: "hr-sync"}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/api-keys", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.NotContains(string(bytesData), `"key"`)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+	})
+}
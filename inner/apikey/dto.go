@@ -0,0 +1,77 @@
+package apikey
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+type Entity struct {
+	Id          int64          `db:"id"`
+	Prefix      string         `db:"prefix"`
+	Account     string         `db:"account"`
+	SecretHash  []byte         `db:"secret_hash"`
+	Salt        []byte         `db:"salt"`
+	Permissions pq.StringArray `db:"permissions"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+	RevokedAt   *time.Time     `db:"revoked_at"`
+	RotatedFrom *int64         `db:"rotated_from"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// activeAt ключ не отозван и не истёк в момент at
+func (e *Entity) activeAt(at time.Time) bool {
+	return e.RevokedAt == nil && at.Before(e.ExpiresAt)
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:          e.Id,
+		Prefix:      e.Prefix,
+		Account:     e.Account,
+		Permissions: append([]string{}, e.Permissions...),
+		ExpiresAt:   e.ExpiresAt,
+		RevokedAt:   e.RevokedAt,
+		RotatedFrom: e.RotatedFrom,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+// auditSnapshot состояние ключа в журнале аудита; хеш и соль в журнал не попадают
+type auditSnapshot struct {
+	Id          int64      `json:"id"`
+	Prefix      string     `json:"prefix"`
+	Account     string     `json:"account"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	RotatedFrom *int64     `json:"rotated_from"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{
+		Id:          e.Id,
+		Prefix:      e.Prefix,
+		Account:     e.Account,
+		Permissions: e.Permissions,
+		ExpiresAt:   e.ExpiresAt,
+		RevokedAt:   e.RevokedAt,
+		RotatedFrom: e.RotatedFrom,
+	}
+}
+
+type Response struct {
+	Id          int64      `json:"id"`
+	Prefix      string     `json:"prefix"`
+	Account     string     `json:"account"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RotatedFrom *int64     `json:"rotated_from,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SecretResponse ключ вместе с секретом; секрет возвращается только при выпуске ключа и больше нигде
+type SecretResponse struct {
+	Response
+	Key string `json:"key"`
+}
@@ -0,0 +1,64 @@
+package apikey
+
+import (
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into api_key (prefix, account, secret_hash, salt, permissions, expires_at, rotated_from)
+		values ($1, $2, $3, $4, $5, $6, $7) returning *`
+	err = tx.Get(&saved, query, e.Prefix, e.Account, e.SecretHash, e.Salt, e.Permissions, e.ExpiresAt, e.RotatedFrom)
+	return saved, err
+}
+
+func (r *Repository) FindById(id int64) (key Entity, err error) {
+	query := "select * from api_key where id = $1"
+	err = r.db.Get(&key, query, id)
+	return key, err
+}
+
+// FindByIdForUpdateTx найти ключ и заблокировать его до конца транзакции
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (key Entity, err error) {
+	query := "select * from api_key where id = $1 for update"
+	err = tx.Get(&key, query, id)
+	return key, err
+}
+
+func (r *Repository) FindByPrefix(prefix string) (key Entity, err error) {
+	query := "select * from api_key where prefix = $1"
+	err = r.db.Get(&key, query, prefix)
+	return key, err
+}
+
+func (r *Repository) FindAll() (keys []Entity, err error) {
+	query := "select * from api_key order by id"
+	err = r.db.Select(&keys, query)
+	return keys, err
+}
+
+// ExpireTx сократить срок действия ключа до at
+func (r *Repository) ExpireTx(tx *sqlx.Tx, id int64, at time.Time) (updated Entity, err error) {
+	query := "update api_key set expires_at = $2 where id = $1 returning *"
+	err = tx.Get(&updated, query, id, at)
+	return updated, err
+}
+
+// RevokeTx отозвать ключ; уже отозванный ключ не найдётся (sql.ErrNoRows)
+func (r *Repository) RevokeTx(tx *sqlx.Tx, id int64) (revoked Entity, err error) {
+	query := "update api_key set revoked_at = now() where id = $1 and revoked_at is null returning *"
+	err = tx.Get(&revoked, query, id)
+	return revoked, err
+}
@@ -0,0 +1,21 @@
+package apikey
+
+import "time"
+
+type CreateRequest struct {
+	Account     string    `json:"account" validate:"required,min=2,max=100"`
+	Permissions []string  `json:"permissions" validate:"required,min=1,dive,required"`
+	ExpiresAt   time.Time `json:"expires_at" validate:"required"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// RotateRequest выпустить замену ключа; старый ключ действует ещё OverlapSeconds секунд,
+// чтобы клиенты успели перейти на новый (не дольше 30 дней). Без ExpiresAt новый ключ получает срок жизни старого.
+type RotateRequest struct {
+	Id             int64      `json:"id" validate:"required,gt=0"`
+	OverlapSeconds int64      `json:"overlap_seconds" validate:"gte=0,lte=2592000"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,49 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"idm/inner/common"
+	"strings"
+)
+
+// keyScheme начало каждого ключа: по нему ключ легко узнать в конфигурации и в утечках
+const keyScheme = "idm_"
+
+const (
+	prefixBytes = 8
+	secretBytes = 32
+)
+
+// generateKey новый ключ вида idm_<prefix>.<secret>: prefix открыт и служит для поиска ключа,
+// secret хранится только в виде хеша
+func generateKey() (prefix, secret string, err error) {
+	raw := make([]byte, prefixBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret, err = common.NewSecret(secretBytes)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(raw), secret, nil
+}
+
+func formatKey(prefix, secret string) string {
+	return keyScheme + prefix + "." + secret
+}
+
+// parseKey разобрать ключ на открытую часть и секрет
+func parseKey(key string) (prefix, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, keyScheme)
+	if !found {
+		return "", "", false
+	}
+	prefix, secret, found = strings.Cut(rest, ".")
+	return prefix, secret, found && prefix != "" && secret != ""
+}
+
+// secretMatches сравнение за постоянное время, чтобы не подсказывать секрет по времени ответа
+func secretMatches(e Entity, secret string) bool {
+	return common.SecretMatches(e.SecretHash, e.Salt, secret)
+}
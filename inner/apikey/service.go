@@ -0,0 +1,262 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "api_key"
+
+// Действия с ключами в журнале аудита
+const (
+	auditActionRotate = "rotate"
+	auditActionRevoke = "revoke"
+)
+
+// subjectPrefix отличает сервисные учётные записи от логинов сотрудников в контексте запроса и журнале аудита
+const subjectPrefix = "service:"
+
+type Service struct {
+	repo        Repo
+	permissions PermissionChecker
+	auditor     Auditor
+	validator   Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindByPrefix(prefix string) (Entity, error)
+	FindAll() ([]Entity, error)
+	ExpireTx(tx *sqlx.Tx, id int64, at time.Time) (Entity, error)
+	RevokeTx(tx *sqlx.Tx, id int64) (Entity, error)
+}
+
+// PermissionChecker ключ может получить только существующие разрешения, которые есть у автора запроса
+type PermissionChecker interface {
+	CheckGrantable(ctx context.Context, names []string) error
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, permissions PermissionChecker, auditor Auditor, validator Validator) *Service {
+	return &Service{
+		repo:        repo,
+		permissions: permissions,
+		auditor:     auditor,
+		validator:   validator,
+	}
+}
+
+// Create выпустить ключ для сервисной учётной записи; секрет есть только в ответе
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return SecretResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		return SecretResponse{}, common.RequestValidationError{Message: "expires_at must be in the future"}
+	}
+	if err = svc.permissions.CheckGrantable(ctx, request.Permissions); err != nil {
+		return SecretResponse{}, err
+	}
+	var response SecretResponse
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating api key", func(tx *sqlx.Tx) error {
+		response, err = svc.issueTx(ctx, tx, Entity{
+			Account:     request.Account,
+			Permissions: request.Permissions,
+			ExpiresAt:   request.ExpiresAt,
+		})
+		return err
+	})
+	if err != nil {
+		return SecretResponse{}, err
+	}
+	return response, nil
+}
+
+// Rotate выпустить замену ключа с теми же учётной записью и разрешениями;
+// старый ключ продолжает действовать до конца периода перекрытия
+func (svc *Service) Rotate(ctx context.Context, request RotateRequest) (SecretResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return SecretResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	now := time.Now()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		return SecretResponse{}, common.RequestValidationError{Message: "expires_at must be in the future"}
+	}
+	var response SecretResponse
+	err = database.InTransaction(svc.repo.BeginTransaction, "rotating api key", func(tx *sqlx.Tx) error {
+		old, err := svc.repo.FindByIdForUpdateTx(tx, request.Id)
+		if err != nil {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("error finding api key with id %d: %v", request.Id, err),
+			}
+		}
+		if !old.activeAt(now) {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("api key with id %d is revoked or expired", request.Id),
+			}
+		}
+		// новый секрет получает автор запроса, поэтому разрешения ключа он должен иметь сам
+		if err = svc.permissions.CheckGrantable(ctx, old.Permissions); err != nil {
+			return err
+		}
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		if request.ExpiresAt != nil {
+			expiresAt = *request.ExpiresAt
+		}
+		response, err = svc.issueTx(ctx, tx, Entity{
+			Account:     old.Account,
+			Permissions: old.Permissions,
+			ExpiresAt:   expiresAt,
+			RotatedFrom: &old.Id,
+		})
+		if err != nil {
+			return err
+		}
+		overlapEnd := now.Add(time.Duration(request.OverlapSeconds) * time.Second)
+		if !overlapEnd.Before(old.ExpiresAt) {
+			return nil
+		}
+		expired, err := svc.repo.ExpireTx(tx, old.Id, overlapEnd)
+		if err != nil {
+			return fmt.Errorf("error shortening api key with id %d: %w", old.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionRotate,
+			EntityType: auditEntityType,
+			EntityId:   old.Id,
+			Before:     old.auditSnapshot(),
+			After:      expired.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return SecretResponse{}, err
+	}
+	return response, nil
+}
+
+// Revoke отозвать ключ немедленно
+func (svc *Service) Revoke(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "revoking api key", func(tx *sqlx.Tx) error {
+		revoked, err := svc.repo.RevokeTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("api key with id %d not found or already revoked", request.Id),
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error revoking api key with id %d: %w", request.Id, err)
+		}
+		before := revoked
+		before.RevokedAt = nil
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionRevoke,
+			EntityType: auditEntityType,
+			EntityId:   revoked.Id,
+			Before:     before.auditSnapshot(),
+			After:      revoked.auditSnapshot(),
+		})
+	})
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding api key with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all api keys: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// VerifyApiKey проверить ключ из заголовка Authorization: ApiKey и вернуть субъект
+// сервисной учётной записи и разрешения, которыми ограничен ключ
+func (svc *Service) VerifyApiKey(key string) (subject string, scopes []string, err error) {
+	prefix, secret, ok := parseKey(key)
+	if !ok {
+		return "", nil, errors.New("malformed api key")
+	}
+	entity, err := svc.repo.FindByPrefix(prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, fmt.Errorf("unknown api key %s", prefix)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error finding api key %s: %w", prefix, err)
+	}
+	if !secretMatches(entity, secret) {
+		return "", nil, fmt.Errorf("wrong secret of api key %s", prefix)
+	}
+	if !entity.activeAt(time.Now()) {
+		return "", nil, fmt.Errorf("api key %s is revoked or expired", prefix)
+	}
+	return subjectPrefix + entity.Account, append([]string{}, entity.Permissions...), nil
+}
+
+// issueTx сгенерировать секрет, сохранить ключ и записать его выпуск в журнал аудита
+func (svc *Service) issueTx(ctx context.Context, tx *sqlx.Tx, entity Entity) (SecretResponse, error) {
+	prefix, secret, err := generateKey()
+	if err != nil {
+		return SecretResponse{}, fmt.Errorf("error generating api key: %w", err)
+	}
+	salt, err := common.NewSalt()
+	if err != nil {
+		return SecretResponse{}, fmt.Errorf("error generating api key: %w", err)
+	}
+	entity.Prefix = prefix
+	entity.Salt = salt
+	entity.SecretHash = common.HashSecret(salt, secret)
+	saved, err := svc.repo.SaveTx(tx, entity)
+	if err != nil {
+		return SecretResponse{}, fmt.Errorf("error saving api key of account %s: %w", entity.Account, err)
+	}
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionCreate,
+		EntityType: auditEntityType,
+		EntityId:   saved.Id,
+		After:      saved.auditSnapshot(),
+	})
+	if err != nil {
+		return SecretResponse{}, err
+	}
+	return SecretResponse{Response: saved.toResponse(), Key: formatKey(prefix, secret)}, nil
+}
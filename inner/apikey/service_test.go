@@ -0,0 +1,435 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+// SaveTx результат можно задать функцией, чтобы вернуть ключ с тем секретом, который сгенерировал сервис
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	if fn, ok := args.Get(0).(func(*sqlx.Tx, Entity) (Entity, error)); ok {
+		return fn(tx, e)
+	}
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByPrefix(prefix string) (Entity, error) {
+	args := m.Called(prefix)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExpireTx(tx *sqlx.Tx, id int64, at time.Time) (Entity, error) {
+	args := m.Called(tx, id, at)
+	if fn, ok := args.Get(0).(func(*sqlx.Tx, int64, time.Time) (Entity, error)); ok {
+		return fn(tx, id, at)
+	}
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) RevokeTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+type MockPermissionChecker struct {
+	mock.Mock
+}
+
+func (m *MockPermissionChecker) CheckGrantable(ctx context.Context, names []string) error {
+	args := m.Called(names)
+	return args.Error(0)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+// saved имитирует insert ... returning *: ключ получает идентификатор и время создания
+func saved(e Entity, id int64) Entity {
+	e.Id = id
+	e.CreatedAt = time.Now()
+	return e
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	expiresAt := time.Now().Add(30 * 24 * time.Hour)
+
+	t.Run("should issue key and store only hash of secret", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, permissions, auditor, validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			stored = saved(e, 5)
+			return stored, nil
+		})
+
+		response, err := svc.Create(context.Background(), CreateRequest{
+			Account:     "hr-sync",
+			Permissions: []string{"employees:read"},
+			ExpiresAt:   expiresAt,
+		})
+		a.NoError(err)
+		a.Equal(int64(5), response.Id)
+		a.Equal("hr-sync", response.Account)
+		a.Equal([]string{"employees:read"}, response.Permissions)
+
+		prefix, secret, ok := parseKey(response.Key)
+		a.True(ok)
+		a.Equal(stored.Prefix, prefix)
+		a.Equal(response.Prefix, prefix)
+		a.NotContains(string(stored.SecretHash), secret)
+		a.True(secretMatches(stored, secret))
+		a.False(secretMatches(stored, secret+"x"))
+
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionCreate, auditor.events[0].Action)
+		a.Equal(auditEntityType, auditor.events[0].EntityType)
+		a.Equal(int64(5), auditor.events[0].EntityId)
+	})
+
+	t.Run("should generate different secrets and salts", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		var stored []Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			stored = append(stored, e)
+			return e, nil
+		})
+		request := CreateRequest{Account: "ci", Permissions: []string{"employees:read"}, ExpiresAt: expiresAt}
+
+		first, err := svc.Create(context.Background(), request)
+		a.NoError(err)
+		second, err := svc.Create(context.Background(), request)
+		a.NoError(err)
+		a.NotEqual(first.Key, second.Key)
+		a.NotEqual(stored[0].Salt, stored[1].Salt)
+		a.NotEqual(stored[0].Prefix, stored[1].Prefix)
+	})
+
+	t.Run("should reject unknown permission", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read", "employees:purge"}).
+			Return(common.RequestValidationError{Message: "unknown permission employees:purge"})
+
+		_, err := svc.Create(context.Background(), CreateRequest{
+			Account:     "hr-sync",
+			Permissions: []string{"employees:read", "employees:purge"},
+			ExpiresAt:   expiresAt,
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "employees:purge")
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should reject permission the caller does not hold", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"apikeys:manage"}).
+			Return(common.ForbiddenError{Message: "cannot grant permission apikeys:manage: alice does not hold it"})
+
+		_, err := svc.Create(common.WithActor(context.Background(), "alice"), CreateRequest{
+			Account:     "hr-sync",
+			Permissions: []string{"apikeys:manage"},
+			ExpiresAt:   expiresAt,
+		})
+		a.ErrorAs(err, &common.ForbiddenError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should require expiry in future and at least one permission", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{
+			Account:     "hr-sync",
+			Permissions: []string{"employees:read"},
+			ExpiresAt:   time.Now().Add(-time.Hour),
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		_, err = svc.Create(context.Background(), CreateRequest{Account: "hr-sync", ExpiresAt: expiresAt})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceRotate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	createdAt := time.Now().Add(-10 * 24 * time.Hour)
+	old := Entity{
+		Id:          3,
+		Prefix:      "0011223344556677",
+		Account:     "hr-sync",
+		Permissions: []string{"employees:read"},
+		ExpiresAt:   createdAt.Add(90 * 24 * time.Hour),
+		CreatedAt:   createdAt,
+	}
+
+	t.Run("should issue replacement and keep old key for overlap period", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, permissions, auditor, validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(old, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			stored = saved(e, 4)
+			return stored, nil
+		})
+		var overlapEnd time.Time
+		repo.On("ExpireTx", noTx, int64(3), mock.Anything).Return(func(tx *sqlx.Tx, id int64, at time.Time) (Entity, error) {
+			overlapEnd = at
+			expired := old
+			expired.ExpiresAt = at
+			return expired, nil
+		})
+
+		before := time.Now()
+		response, err := svc.Rotate(context.Background(), RotateRequest{Id: 3, OverlapSeconds: 3600})
+		a.NoError(err)
+		a.Equal(int64(4), response.Id)
+		a.Equal(int64(3), *response.RotatedFrom)
+		a.Equal(old.Account, stored.Account)
+		a.Equal(old.Permissions, stored.Permissions)
+		a.WithinDuration(before.Add(90*24*time.Hour), stored.ExpiresAt, time.Minute)
+		a.WithinDuration(before.Add(time.Hour), overlapEnd, time.Minute)
+
+		_, secret, ok := parseKey(response.Key)
+		a.True(ok)
+		a.True(secretMatches(stored, secret))
+
+		a.Len(auditor.events, 2)
+		a.Equal(audit.ActionCreate, auditor.events[0].Action)
+		a.Equal(int64(4), auditor.events[0].EntityId)
+		a.Equal(auditActionRotate, auditor.events[1].Action)
+		a.Equal(int64(3), auditor.events[1].EntityId)
+	})
+
+	t.Run("should not extend old key beyond its expiry", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		expiring := old
+		expiring.ExpiresAt = time.Now().Add(time.Hour)
+		requestedExpiry := time.Now().Add(365 * 24 * time.Hour)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(expiring, nil)
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			return saved(e, 4), nil
+		})
+
+		response, err := svc.Rotate(context.Background(), RotateRequest{Id: 3, OverlapSeconds: 86400, ExpiresAt: &requestedExpiry})
+		a.NoError(err)
+		a.True(requestedExpiry.Equal(response.ExpiresAt))
+		a.True(repo.AssertNotCalled(t, "ExpireTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should not rotate key with permissions the caller does not hold", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).
+			Return(common.ForbiddenError{Message: "cannot grant permission employees:read: bob does not hold it"})
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(old, nil)
+
+		_, err := svc.Rotate(common.WithActor(context.Background(), "bob"), RotateRequest{Id: 3})
+		a.ErrorAs(err, &common.ForbiddenError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should not rotate revoked key", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		revokedAt := time.Now().Add(-time.Minute)
+		revoked := old
+		revoked.RevokedAt = &revokedAt
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(revoked, nil)
+
+		_, err := svc.Rotate(context.Background(), RotateRequest{Id: 3})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(9)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Rotate(context.Background(), RotateRequest{Id: 9})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("should reject too long overlap", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		_, err := svc.Rotate(context.Background(), RotateRequest{Id: 3, OverlapSeconds: 31 * 24 * 3600})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceRevoke(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke key and record audit event", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockPermissionChecker), auditor, validator.New())
+
+		revokedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RevokeTx", noTx, int64(3)).Return(Entity{Id: 3, Account: "hr-sync", RevokedAt: &revokedAt}, nil)
+
+		a.NoError(svc.Revoke(context.Background(), IdRequest{Id: 3}))
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionRevoke, auditor.events[0].Action)
+		a.Nil(auditor.events[0].Before.(auditSnapshot).RevokedAt)
+		a.Equal(&revokedAt, auditor.events[0].After.(auditSnapshot).RevokedAt)
+	})
+
+	t.Run("should return not found error for revoked key", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RevokeTx", noTx, int64(3)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.Revoke(context.Background(), IdRequest{Id: 3})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceVerifyApiKey(t *testing.T) {
+	a := assert.New(t)
+
+	salt := []byte("0123456789abcdef")
+	key := formatKey("0011223344556677", "secret")
+	active := Entity{
+		Id:          3,
+		Prefix:      "0011223344556677",
+		Account:     "hr-sync",
+		Salt:        salt,
+		SecretHash:  common.HashSecret(salt, "secret"),
+		Permissions: []string{"employees:read"},
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	t.Run("should return service account and scopes of key", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		repo.On("FindByPrefix", "0011223344556677").Return(active, nil)
+
+		subject, scopes, err := svc.VerifyApiKey(key)
+		a.NoError(err)
+		a.Equal("service:hr-sync", subject)
+		a.Equal([]string{"employees:read"}, scopes)
+	})
+
+	t.Run("should reject wrong, expired and revoked keys", func(t *testing.T) {
+		revokedAt := time.Now().Add(-time.Minute)
+		revoked := active
+		revoked.RevokedAt = &revokedAt
+		expired := active
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+		cases := map[string]struct {
+			key    string
+			entity Entity
+			err    error
+		}{
+			"wrong secret": {key: formatKey("0011223344556677", "guess"), entity: active},
+			"revoked":      {key: key, entity: revoked},
+			"expired":      {key: key, entity: expired},
+			"unknown":      {key: key, err: sql.ErrNoRows},
+			"database":     {key: key, err: errors.New("database error")},
+		}
+		for name, c := range cases {
+			repo := new(MockRepo)
+			svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+			repo.On("FindByPrefix", "0011223344556677").Return(c.entity, c.err)
+
+			_, _, err := svc.VerifyApiKey(c.key)
+			a.Error(err, name)
+		}
+	})
+
+	t.Run("should reject malformed key without database lookup", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubAuditor), validator.New())
+
+		for _, malformed := range []string{"", "secret", "idm_", "idm_0011223344556677", "idm_.secret", strings.TrimPrefix(key, keyScheme)} {
+			_, _, err := svc.VerifyApiKey(malformed)
+			a.Error(err, malformed)
+		}
+		a.True(repo.AssertNotCalled(t, "FindByPrefix", mock.Anything))
+	})
+}
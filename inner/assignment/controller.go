@@ -0,0 +1,176 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты назначений
+const (
+	permissionRead   = "assignments:read"
+	permissionCreate = "assignments:create"
+	permissionDelete = "assignments:delete"
+	// permissionSodOverride назначение вопреки правилам разделения обязанностей
+	permissionSodOverride = "sod:override"
+)
+
+type Controller struct {
+	server            *web.Server
+	assignmentService Svc
+	logger            *common.Logger
+}
+
+type Svc interface {
+	Grant(ctx context.Context, request GrantRequest) (int64, error)
+	GrantOverride(ctx context.Context, request OverrideRequest) (int64, error)
+	Revoke(ctx context.Context, request RevokeRequest) error
+	FindByEmployee(request EmployeeRequest) ([]Response, error)
+	FindByRole(request RoleRequest) ([]Response, error)
+}
+
+func NewController(server *web.Server, assignmentService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:            server,
+		assignmentService: assignmentService,
+		logger:            logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/employees/:id/roles", c.server.Require(permissionCreate), c.Grant)
+	c.server.GroupApiV1.Post("/employees/:id/roles/sod-override",
+		c.server.Require(permissionCreate), c.server.Require(permissionSodOverride), c.GrantOverride)
+	c.server.GroupApiV1.Get("/employees/:id/roles", c.server.Require(permissionRead), c.FindByEmployee)
+	c.server.GroupApiV1.Delete("/employees/:id/roles/:roleId", c.server.Require(permissionDelete), c.Revoke)
+	c.server.GroupApiV1.Get("/roles/:id/employees", c.server.Require(permissionRead), c.FindByRole)
+}
+
+func (c *Controller) Grant(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("grant role: received employee id", zap.String("id", idStr))
+	employeeId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("grant role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request GrantRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("grant role: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.EmployeeId = employeeId
+	c.logger.Debug("grant role: received request", zap.Any("request", request))
+	id, err := c.assignmentService.Grant(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("grant role: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("grant role: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, id)
+}
+
+// GrantOverride назначить роль вопреки правилам разделения обязанностей с обоснованием в теле запроса
+func (c *Controller) GrantOverride(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("grant role with sod override: received employee id", zap.String("id", idStr))
+	employeeId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("grant role with sod override: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request OverrideRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("grant role with sod override: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.EmployeeId = employeeId
+	c.logger.Debug("grant role with sod override: received request", zap.Any("request", request))
+	id, err := c.assignmentService.GrantOverride(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("grant role with sod override: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("grant role with sod override: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, id)
+}
+
+func (c *Controller) Revoke(ctx *fiber.Ctx) error {
+	idStr, roleIdStr := ctx.Params("id"), ctx.Params("roleId")
+	c.logger.Debug("revoke role: received ids", zap.String("id", idStr), zap.String("role_id", roleIdStr))
+	employeeId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("revoke role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	roleId, err := strconv.ParseInt(roleIdStr, 10, 64)
+	if err != nil {
+		c.logger.Error("revoke role: invalid role id parameter", zap.String("role_id", roleIdStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid role id parameter")
+	}
+	request := RevokeRequest{EmployeeId: employeeId, RoleId: roleId}
+	err = c.assignmentService.Revoke(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("revoke role: service error", zap.Any("request", request), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("revoke role: success", zap.Any("request", request))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) FindByEmployee(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee roles: received id", zap.String("id", idStr))
+	employeeId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee roles: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := EmployeeRequest{
+		EmployeeId: employeeId,
+		All:        ctx.QueryBool("all"),
+		Inherited:  ctx.QueryBool("inherited"),
+	}
+	responses, err := c.assignmentService.FindByEmployee(request)
+	if err != nil {
+		c.logger.Error("find employee roles: service error", zap.Int64("id", employeeId), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee roles: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) FindByRole(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find role employees: received id", zap.String("id", idStr))
+	roleId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find role employees: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := RoleRequest{RoleId: roleId, All: ctx.QueryBool("all")}
+	responses, err := c.assignmentService.FindByRole(request)
+	if err != nil {
+		c.logger.Error("find role employees: service error", zap.Int64("id", roleId), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find role employees: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.SodConflictError{}):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
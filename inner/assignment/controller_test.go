@@ -0,0 +1,271 @@
+package assignment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Grant(ctx context.Context, request GrantRequest) (int64, error) {
+	args := svc.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (svc *MockService) GrantOverride(ctx context.Context, request OverrideRequest) (int64, error) {
+	args := svc.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (svc *MockService) Revoke(ctx context.Context, request RevokeRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindByEmployee(request EmployeeRequest) ([]Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) FindByRole(request RoleRequest) ([]Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func TestControllerGrant(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1/roles"
+
+	t.Run("should return created assignment id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		svc.On("Grant", GrantRequest{EmployeeId: 1, RoleId: 2, ValidFrom: &from}).Return(int64(5), nil)
+
+		body := strings.NewReader(`{"role_id":2,"valid_from":"2025-01-01T00:00:00Z"}`)
+		req := httptest.NewRequest(fiber.MethodPost, url, body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[int64]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.True(responseBody.Success)
+		a.Equal(int64(5), responseBody.Data)
+	})
+
+	t.Run("should return bad request when assignment overlaps", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Grant", GrantRequest{EmployeeId: 1, RoleId: 2}).
+			Return(int64(0), common.AlreadyExistsError{Message: "already granted"})
+
+		req := httptest.NewRequest(fiber.MethodPost, url, strings.NewReader(`{"role_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, new(MockService), logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/abc/roles", strings.NewReader(`{"role_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerGrantSod(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return conflict when grant violates sod rule", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Grant", GrantRequest{EmployeeId: 1, RoleId: 2}).
+			Return(int64(0), common.SodConflictError{Message: "violates sod rules"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/roles", strings.NewReader(`{"role_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("should pass justification to override", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("GrantOverride", OverrideRequest{
+			GrantRequest:  GrantRequest{EmployeeId: 1, RoleId: 2},
+			Justification: "covering for vacation",
+		}).Return(int64(5), nil)
+
+		body := strings.NewReader(`{"role_id":2,"justification":"covering for vacation"}`)
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/roles/sod-override", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+}
+
+func TestControllerRevoke(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should revoke role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Revoke", RevokeRequest{EmployeeId: 1, RoleId: 2}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/1/roles/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Revoke", RevokeRequest{EmployeeId: 1, RoleId: 2}).Return(common.NotFoundError{Message: "no assignment"})
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/1/roles/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid role id", func(t *testing.T) {
+		server := webtest.NewServer()
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, new(MockService), logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/1/roles/abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerFindByEmployee(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass all flag to service", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+		assignments := []Response{{Id: 1, EmployeeId: 1, RoleId: 2, RoleName: "Accountant"}}
+		svc.On("FindByEmployee", EmployeeRequest{EmployeeId: 1, All: true}).Return(assignments, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/roles?all=true", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal("Accountant", responseBody.Data[0].RoleName)
+	})
+
+	t.Run("should return internal server error on generic error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindByEmployee", EmployeeRequest{EmployeeId: 1}).Return([]Response{}, errors.New("db down"))
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/roles", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestControllerFindByRole(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return employees of role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindByRole", RoleRequest{RoleId: 2}).Return([]Response{{Id: 1, EmployeeId: 1, RoleId: 2}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/roles/2/employees", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindByRole", RoleRequest{RoleId: 2}).Return([]Response{}, common.NotFoundError{Message: "no role"})
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/roles/2/employees", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
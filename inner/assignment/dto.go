@@ -0,0 +1,81 @@
+package assignment
+
+import "time"
+
+// Entity назначение роли сотруднику, действующее в интервале [ValidFrom, ValidTo).
+// ValidTo, равный nil, означает бессрочное назначение.
+type Entity struct {
+	Id           int64      `db:"id"`
+	EmployeeId   int64      `db:"employee_id"`
+	EmployeeName string     `db:"employee_name"`
+	RoleId       int64      `db:"role_id"`
+	RoleName     string     `db:"role_name"`
+	ValidFrom    time.Time  `db:"valid_from"`
+	ValidTo      *time.Time `db:"valid_to"`
+	RuleId       *int64     `db:"rule_id"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:           e.Id,
+		EmployeeId:   e.EmployeeId,
+		EmployeeName: e.EmployeeName,
+		RoleId:       e.RoleId,
+		RoleName:     e.RoleName,
+		ValidFrom:    e.ValidFrom,
+		ValidTo:      e.ValidTo,
+		RuleId:       e.RuleId,
+		CreatedAt:    e.CreatedAt,
+	}
+}
+
+// auditSnapshot состояние назначения в журнале аудита
+type auditSnapshot struct {
+	Id         int64      `json:"id"`
+	EmployeeId int64      `json:"employee_id"`
+	RoleId     int64      `json:"role_id"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ValidTo    *time.Time `json:"valid_to"`
+	RuleId     *int64     `json:"rule_id,omitempty"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{
+		Id:         e.Id,
+		EmployeeId: e.EmployeeId,
+		RoleId:     e.RoleId,
+		ValidFrom:  e.ValidFrom,
+		ValidTo:    e.ValidTo,
+		RuleId:     e.RuleId,
+	}
+}
+
+// overrideSnapshot назначение вопреки правилу разделения обязанностей в журнале аудита
+type overrideSnapshot struct {
+	RuleId        int64  `json:"rule_id"`
+	RuleName      string `json:"rule_name"`
+	EmployeeId    int64  `json:"employee_id"`
+	AssignmentId  int64  `json:"assignment_id"`
+	Justification string `json:"justification"`
+}
+
+// IsEffectiveAt действует ли назначение в момент at
+func (e *Entity) IsEffectiveAt(at time.Time) bool {
+	return !e.ValidFrom.After(at) && (e.ValidTo == nil || e.ValidTo.After(at))
+}
+
+type Response struct {
+	Id           int64      `json:"id"`
+	EmployeeId   int64      `json:"employee_id"`
+	EmployeeName string     `json:"employee_name"`
+	RoleId       int64      `json:"role_id"`
+	RoleName     string     `json:"role_name"`
+	ValidFrom    time.Time  `json:"valid_from"`
+	ValidTo      *time.Time `json:"valid_to"`
+	// RuleId правило, по которому выдано назначение; такое назначение отзывает только само правило
+	RuleId    *int64    `json:"rule_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// InheritedFrom роль из назначения, через которую унаследована данная роль; nil для прямых назначений
+	InheritedFrom *int64 `json:"inherited_from,omitempty"`
+}
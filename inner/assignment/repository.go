@@ -0,0 +1,156 @@
+package assignment
+
+import (
+	"github.com/jmoiron/sqlx"
+	"idm/inner/role"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+const selectAssignments = `select er.id, er.employee_id, e.name as employee_name, er.role_id, r.name as role_name,
+	er.valid_from, er.valid_to, er.rule_id, er.created_at
+	from employee_role er
+	join employee e on e.id = er.employee_id
+	join role r on r.id = er.role_id`
+
+const effectiveAt = "er.valid_from <= $2 and (er.valid_to is null or er.valid_to > $2)"
+
+// notDeleted назначения мягко удалённых сотрудников и ролей не действуют, но остаются в истории
+const notDeleted = "e.deleted_at is null and r.deleted_at is null"
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (id int64, err error) {
+	query := `insert into employee_role (employee_id, role_id, valid_from, valid_to, rule_id)
+		values ($1, $2, $3, $4, $5) returning id`
+	err = tx.QueryRowx(query, e.EmployeeId, e.RoleId, e.ValidFrom, e.ValidTo, e.RuleId).Scan(&id)
+	return id, err
+}
+
+// ExistsOverlappingTx есть ли у сотрудника назначение той же роли, пересекающееся с интервалом [from, to)
+func (r *Repository) ExistsOverlappingTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) (exists bool, err error) {
+	query := `select exists(select 1 from employee_role
+		where employee_id = $1 and role_id = $2
+		and (valid_to is null or valid_to > $3)
+		and ($4::timestamptz is null or valid_from < $4))`
+	err = tx.Get(&exists, query, employeeId, roleId, from, to)
+	return exists, err
+}
+
+func (r *Repository) FindByEmployeeId(employeeId int64) (assignments []Entity, err error) {
+	query := selectAssignments + " where er.employee_id = $1 order by er.valid_from"
+	err = r.db.Select(&assignments, query, employeeId)
+	return assignments, err
+}
+
+func (r *Repository) FindEffectiveByEmployeeId(employeeId int64, at time.Time) (assignments []Entity, err error) {
+	query := selectAssignments + " where er.employee_id = $1 and " + effectiveAt + " and " + notDeleted +
+		" order by er.valid_from"
+	err = r.db.Select(&assignments, query, employeeId, at)
+	return assignments, err
+}
+
+func (r *Repository) FindByRoleId(roleId int64) (assignments []Entity, err error) {
+	query := selectAssignments + " where er.role_id = $1 order by er.valid_from"
+	err = r.db.Select(&assignments, query, roleId)
+	return assignments, err
+}
+
+func (r *Repository) FindEffectiveByRoleId(roleId int64, at time.Time) (assignments []Entity, err error) {
+	query := selectAssignments + " where er.role_id = $1 and " + effectiveAt + " and " + notDeleted +
+		" order by er.valid_from"
+	err = r.db.Select(&assignments, query, roleId, at)
+	return assignments, err
+}
+
+// FindEffectiveRoles роли, действующие у сотрудника в момент at
+func (r *Repository) FindEffectiveRoles(employeeId int64, at time.Time) (roles []role.Entity, err error) {
+	query := `select distinct r.* from role r
+		join employee_role er on er.role_id = r.id
+		where er.employee_id = $1 and r.deleted_at is null and ` + effectiveAt + ` order by r.id`
+	err = r.db.Select(&roles, query, employeeId, at)
+	return roles, err
+}
+
+// FindEffectiveRolesAsOf роли, действовавшие у сотрудника в момент at так, как это было записано
+// в тот момент: назначения и роли берутся из версий employee_role_history и role_history
+func (r *Repository) FindEffectiveRolesAsOf(employeeId int64, at time.Time) (roles []role.Entity, err error) {
+	var versions []role.HistoryEntity
+	query := `select distinct r.* from role_history r
+		join employee_role_history er on er.role_id = r.id
+		where er.employee_id = $1 and r.deleted_at is null and ` + effectiveAt + `
+		and er.version_from <= $2 and (er.version_to is null or er.version_to > $2)
+		and r.version_from <= $2 and (r.version_to is null or r.version_to > $2)
+		order by r.id`
+	err = r.db.Select(&versions, query, employeeId, at)
+	return role.EntitiesOf(versions), err
+}
+
+// ExistsByRuleTx есть ли у сотрудника действующее или будущее назначение роли, выданное правилом
+func (r *Repository) ExistsByRuleTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) (exists bool, err error) {
+	query := `select exists(select 1 from employee_role
+		where employee_id = $1 and role_id = $2 and rule_id is not null and (valid_to is null or valid_to > $3))`
+	err = tx.Get(&exists, query, employeeId, roleId, at)
+	return exists, err
+}
+
+// Условия отбора назначений для revokeTx: $1 - сотрудник, $2 - роль или правило
+const (
+	// byRole роль $2 или, если $2 равен null, все роли
+	byRole = "employee_id = $1 and ($2::bigint is null or role_id = $2)"
+	// manualByRole назначения роли $2, выданные вручную
+	manualByRole = "employee_id = $1 and role_id = $2 and rule_id is null"
+	// byRule назначения, выданные правилом $2
+	byRule = "employee_id = $1 and rule_id = $2"
+)
+
+// RevokeTx отозвать выданную вручную роль у сотрудника в момент at: действующие назначения закрываются,
+// а ещё не вступившие в силу удаляются. Возвращает затронутые назначения в состоянии до отзыва.
+func (r *Repository) RevokeTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) ([]Entity, error) {
+	return r.revokeTx(tx, manualByRole, employeeId, &roleId, at)
+}
+
+// RevokeAllTx отозвать у сотрудника все роли в момент at так же, как RevokeTx, включая выданные правилами
+func (r *Repository) RevokeAllTx(tx *sqlx.Tx, employeeId int64, at time.Time) ([]Entity, error) {
+	return r.revokeTx(tx, byRole, employeeId, nil, at)
+}
+
+// RevokeByRuleTx отозвать у сотрудника назначения, выданные правилом ruleId, так же, как RevokeTx
+func (r *Repository) RevokeByRuleTx(tx *sqlx.Tx, employeeId, ruleId int64, at time.Time) ([]Entity, error) {
+	return r.revokeTx(tx, byRule, employeeId, &ruleId, at)
+}
+
+// revokeTx where - одно из условий отбора назначений, key - его параметр $2
+func (r *Repository) revokeTx(
+	tx *sqlx.Tx,
+	where string,
+	employeeId int64,
+	key *int64,
+	at time.Time,
+) (revoked []Entity, err error) {
+	selectQuery := `select id, employee_id, role_id, valid_from, valid_to, rule_id, created_at from employee_role
+		where ` + where + ` and (valid_to is null or valid_to > $3)
+		order by valid_from, id for update`
+	if err = tx.Select(&revoked, selectQuery, employeeId, key, at); err != nil || len(revoked) == 0 {
+		return nil, err
+	}
+	closeQuery := `update employee_role set valid_to = $3
+		where ` + where + ` and valid_from <= $3 and (valid_to is null or valid_to > $3)`
+	if _, err = tx.Exec(closeQuery, employeeId, key, at); err != nil {
+		return nil, err
+	}
+	deleteQuery := "delete from employee_role where " + where + " and valid_from > $3"
+	if _, err = tx.Exec(deleteQuery, employeeId, key, at); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
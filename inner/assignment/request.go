@@ -0,0 +1,36 @@
+package assignment
+
+import "time"
+
+type GrantRequest struct {
+	EmployeeId int64      `json:"-" validate:"required,gt=0"`
+	RoleId     int64      `json:"role_id" validate:"required,gt=0"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidTo    *time.Time `json:"valid_to"`
+	// RuleId правило, по которому выдаётся назначение; через API не передаётся
+	RuleId *int64 `json:"-" validate:"omitempty,gt=0"`
+}
+
+// OverrideRequest назначить роль вопреки правилам разделения обязанностей; обоснование обязательно
+type OverrideRequest struct {
+	GrantRequest
+	Justification string `json:"justification" validate:"required,min=10,max=2000"`
+}
+
+type RevokeRequest struct {
+	EmployeeId int64 `json:"employee_id" validate:"required,gt=0"`
+	RoleId     int64 `json:"role_id" validate:"required,gt=0"`
+}
+
+type EmployeeRequest struct {
+	EmployeeId int64 `json:"employee_id" validate:"required,gt=0"`
+	// All вернуть также истёкшие и ещё не вступившие в силу назначения
+	All bool `json:"all"`
+	// Inherited дополнить список ролями, унаследованными через иерархию ролей
+	Inherited bool `json:"inherited"`
+}
+
+type RoleRequest struct {
+	RoleId int64 `json:"role_id" validate:"required,gt=0"`
+	All    bool  `json:"all"`
+}
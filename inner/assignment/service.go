@@ -0,0 +1,462 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/sod"
+	"strings"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "assignment"
+
+// auditEntityTypeSodOverride назначение вопреки правилу разделения обязанностей в журнале аудита
+const auditEntityTypeSodOverride = "sod_override"
+
+type Service struct {
+	repo         Repo
+	employeeRepo EmployeeRepo
+	roleRepo     RoleRepo
+	sod          SodChecker
+	auditor      Auditor
+	validator    Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (int64, error)
+	ExistsOverlappingTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) (bool, error)
+	FindByEmployeeId(employeeId int64) ([]Entity, error)
+	FindEffectiveByEmployeeId(employeeId int64, at time.Time) ([]Entity, error)
+	FindByRoleId(roleId int64) ([]Entity, error)
+	FindEffectiveByRoleId(roleId int64, at time.Time) ([]Entity, error)
+	RevokeTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) ([]Entity, error)
+	RevokeAllTx(tx *sqlx.Tx, employeeId int64, at time.Time) ([]Entity, error)
+	RevokeByRuleTx(tx *sqlx.Tx, employeeId, ruleId int64, at time.Time) ([]Entity, error)
+	ExistsByRuleTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) (bool, error)
+}
+
+type EmployeeRepo interface {
+	FindById(id int64) (employee.Entity, error)
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+	FindDescendants(id int64) ([]role.Entity, error)
+}
+
+// SodChecker правила разделения обязанностей, которые проверяются при каждом назначении
+type SodChecker interface {
+	FindConflictsTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) ([]sod.Entity, error)
+	SaveOverrideTx(tx *sqlx.Tx, e sod.OverrideEntity) (int64, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(
+	repo Repo,
+	employeeRepo EmployeeRepo,
+	roleRepo RoleRepo,
+	sod SodChecker,
+	auditor Auditor,
+	validator Validator,
+) *Service {
+	return &Service{
+		repo:         repo,
+		employeeRepo: employeeRepo,
+		roleRepo:     roleRepo,
+		sod:          sod,
+		auditor:      auditor,
+		validator:    validator,
+	}
+}
+
+// Grant назначить роль сотруднику. Если ValidFrom не указан, назначение действует с текущего момента.
+// Назначение, нарушающее правила разделения обязанностей, отклоняется с SodConflictError.
+func (svc *Service) Grant(ctx context.Context, request GrantRequest) (int64, error) {
+	validFrom, err := svc.checkGrant(request)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = database.InTransaction(svc.repo.BeginTransaction, "granting role", func(tx *sqlx.Tx) error {
+		id, err = svc.grantTx(ctx, tx, request, validFrom, "")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GrantOverride назначить роль вопреки правилам разделения обязанностей. Для каждого нарушенного правила
+// обоснование сохраняется вместе с назначением и попадает в журнал аудита.
+func (svc *Service) GrantOverride(ctx context.Context, request OverrideRequest) (int64, error) {
+	if err := svc.validator.Validate(request); err != nil {
+		return 0, common.RequestValidationError{Message: err.Error()}
+	}
+	validFrom, err := svc.checkGrant(request.GrantRequest)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = database.InTransaction(svc.repo.BeginTransaction, "granting role with sod override", func(tx *sqlx.Tx) error {
+		id, err = svc.grantTx(ctx, tx, request.GrantRequest, validFrom, request.Justification)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GrantTx назначить роль в транзакции вызывающего кода: назначение фиксируется вместе с изменением,
+// которое к нему привело, например с одобрением запроса доступа
+func (svc *Service) GrantTx(ctx context.Context, tx *sqlx.Tx, request GrantRequest) (int64, error) {
+	validFrom, err := svc.checkGrant(request)
+	if err != nil {
+		return 0, err
+	}
+	return svc.grantTx(ctx, tx, request, validFrom, "")
+}
+
+// GrantByRuleTx назначить роль по правилу ruleId в транзакции изменения сотрудника или правила.
+// Сотрудника правило уже проверило и, возможно, только что создало в этой же транзакции, поэтому
+// он не перечитывается. Ручное назначение той же роли не мешает: назначения живут и отзываются независимо.
+func (svc *Service) GrantByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, roleId, ruleId int64) (int64, error) {
+	request := GrantRequest{EmployeeId: employeeId, RoleId: roleId, RuleId: &ruleId}
+	if err := svc.validator.Validate(request); err != nil {
+		return 0, common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.grantTx(ctx, tx, request, time.Now(), "")
+}
+
+// checkGrant проверить запрос назначения и вернуть момент, с которого оно действует
+func (svc *Service) checkGrant(request GrantRequest) (time.Time, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return time.Time{}, common.RequestValidationError{Message: err.Error()}
+	}
+	validFrom := time.Now()
+	if request.ValidFrom != nil {
+		validFrom = *request.ValidFrom
+	}
+	if request.ValidTo != nil && !request.ValidTo.After(validFrom) {
+		return time.Time{}, common.RequestValidationError{Message: "valid_to must be after valid_from"}
+	}
+	if err = svc.checkEmployeeAndRole(request.EmployeeId, request.RoleId); err != nil {
+		return time.Time{}, err
+	}
+	return validFrom, nil
+}
+
+// grantTx сохранить назначение; с непустым обоснованием назначение выдаётся и при нарушении правил
+// разделения обязанностей. Пересечение с другими назначениями той же роли проверяется только для ручных
+// назначений: повторную выдачу по правилу исключает само правило
+func (svc *Service) grantTx(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	request GrantRequest,
+	validFrom time.Time,
+	justification string,
+) (int64, error) {
+	if request.RuleId == nil {
+		exists, err := svc.repo.ExistsOverlappingTx(tx, request.EmployeeId, request.RoleId, validFrom, request.ValidTo)
+		if err != nil {
+			return 0, fmt.Errorf("error checking assignments of employee %d: %w", request.EmployeeId, err)
+		}
+		if exists {
+			return 0, common.AlreadyExistsError{
+				Message: fmt.Sprintf("employee %d already has role %d within the requested period", request.EmployeeId, request.RoleId),
+			}
+		}
+	}
+	conflicts, err := svc.sod.FindConflictsTx(tx, request.EmployeeId, request.RoleId, validFrom, request.ValidTo)
+	if err != nil {
+		return 0, fmt.Errorf("error checking sod rules for employee %d: %w", request.EmployeeId, err)
+	}
+	if len(conflicts) > 0 && justification == "" {
+		return 0, common.SodConflictError{
+			Message: fmt.Sprintf("granting role %d to employee %d violates sod rules: %s",
+				request.RoleId, request.EmployeeId, describeRules(conflicts)),
+		}
+	}
+	entity := Entity{
+		EmployeeId: request.EmployeeId,
+		RoleId:     request.RoleId,
+		ValidFrom:  validFrom,
+		ValidTo:    request.ValidTo,
+		RuleId:     request.RuleId,
+	}
+	id, err := svc.repo.SaveTx(tx, entity)
+	if err != nil {
+		return 0, fmt.Errorf("error granting role %d to employee %d: %w", request.RoleId, request.EmployeeId, err)
+	}
+	entity.Id = id
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionCreate,
+		EntityType: auditEntityType,
+		EntityId:   id,
+		After:      entity.auditSnapshot(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, rule := range conflicts {
+		if err = svc.overrideTx(ctx, tx, rule, entity, justification); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// overrideTx записать назначение вопреки правилу вместе с обоснованием
+func (svc *Service) overrideTx(ctx context.Context, tx *sqlx.Tx, rule sod.Entity, entity Entity, justification string) error {
+	override := sod.OverrideEntity{
+		RuleId:        rule.Id,
+		EmployeeId:    entity.EmployeeId,
+		AssignmentId:  &entity.Id,
+		Justification: justification,
+		CreatedBy:     common.ActorFrom(ctx),
+	}
+	id, err := svc.sod.SaveOverrideTx(tx, override)
+	if err != nil {
+		return fmt.Errorf("error saving sod override of rule %d for employee %d: %w", rule.Id, entity.EmployeeId, err)
+	}
+	return svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionCreate,
+		EntityType: auditEntityTypeSodOverride,
+		EntityId:   id,
+		After: overrideSnapshot{
+			RuleId:        rule.Id,
+			RuleName:      rule.Name,
+			EmployeeId:    entity.EmployeeId,
+			AssignmentId:  entity.Id,
+			Justification: justification,
+		},
+	})
+}
+
+// describeRules перечислить нарушенные правила для сообщения об ошибке
+func describeRules(rules []sod.Entity) string {
+	descriptions := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		descriptions = append(descriptions, fmt.Sprintf("%s (roles %d and %d)", rule.Name, rule.RoleAId, rule.RoleBId))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// Revoke отозвать роль у сотрудника с текущего момента. В журнал попадает каждое затронутое
+// назначение: закрытое как изменение, ещё не вступившее в силу как удаление. Назначения, выданные
+// правилом, вручную не отзываются.
+func (svc *Service) Revoke(ctx context.Context, request RevokeRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "revoking role", func(tx *sqlx.Tx) error {
+		return svc.revokeTx(ctx, tx, request)
+	})
+}
+
+// RevokeTx отозвать роль в транзакции вызывающего кода, например вместе с решением по итогам пересмотра доступа
+func (svc *Service) RevokeTx(ctx context.Context, tx *sqlx.Tx, request RevokeRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.revokeTx(ctx, tx, request)
+}
+
+func (svc *Service) revokeTx(ctx context.Context, tx *sqlx.Tx, request RevokeRequest) error {
+	now := time.Now()
+	revoked, err := svc.repo.RevokeTx(tx, request.EmployeeId, request.RoleId, now)
+	if err != nil {
+		return fmt.Errorf("error revoking role %d from employee %d: %w", request.RoleId, request.EmployeeId, err)
+	}
+	if len(revoked) == 0 {
+		byRule, err := svc.repo.ExistsByRuleTx(tx, request.EmployeeId, request.RoleId, now)
+		if err != nil {
+			return fmt.Errorf("error checking assignments of employee %d: %w", request.EmployeeId, err)
+		}
+		if byRule {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("role %d of employee %d is granted by an assignment rule and cannot be revoked manually",
+					request.RoleId, request.EmployeeId),
+			}
+		}
+		return common.NotFoundError{
+			Message: fmt.Sprintf("employee %d has no active assignment of role %d", request.EmployeeId, request.RoleId),
+		}
+	}
+	return svc.recordRevokedTx(ctx, tx, revoked, now)
+}
+
+// RevokeByRuleTx отозвать назначения, выданные сотруднику правилом ruleId, когда он перестал подходить
+// под правило или правило отключено. Отсутствие назначений ошибкой не считается.
+func (svc *Service) RevokeByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, ruleId int64) error {
+	now := time.Now()
+	revoked, err := svc.repo.RevokeByRuleTx(tx, employeeId, ruleId, now)
+	if err != nil {
+		return fmt.Errorf("error revoking roles of rule %d from employee %d: %w", ruleId, employeeId, err)
+	}
+	return svc.recordRevokedTx(ctx, tx, revoked, now)
+}
+
+// RevokeAllTx отозвать все роли сотрудника в транзакции вызывающего кода, например при увольнении.
+// Отсутствие назначений ошибкой не считается.
+func (svc *Service) RevokeAllTx(ctx context.Context, tx *sqlx.Tx, employeeId int64) error {
+	now := time.Now()
+	revoked, err := svc.repo.RevokeAllTx(tx, employeeId, now)
+	if err != nil {
+		return fmt.Errorf("error revoking roles from employee %d: %w", employeeId, err)
+	}
+	return svc.recordRevokedTx(ctx, tx, revoked, now)
+}
+
+// recordRevokedTx закрытое назначение попадает в журнал как изменение, ещё не вступившее в силу как удаление
+func (svc *Service) recordRevokedTx(ctx context.Context, tx *sqlx.Tx, revoked []Entity, now time.Time) error {
+	for _, before := range revoked {
+		event := audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   before.Id,
+			Before:     before.auditSnapshot(),
+		}
+		if !before.ValidFrom.After(now) {
+			after := before
+			after.ValidTo = &now
+			event.Action = audit.ActionUpdate
+			event.After = after.auditSnapshot()
+		}
+		if err := svc.auditor.RecordTx(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindByEmployee назначения сотрудника; по умолчанию только действующие в текущий момент.
+// С флагом Inherited к назначениям добавляются роли, которые включены в назначенные роли.
+func (svc *Service) FindByEmployee(request EmployeeRequest) ([]Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.employeeRepo.FindById(request.EmployeeId); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee with id %d: %v", request.EmployeeId, err),
+		}
+	}
+	var entities []Entity
+	if request.All {
+		entities, err = svc.repo.FindByEmployeeId(request.EmployeeId)
+	} else {
+		entities, err = svc.repo.FindEffectiveByEmployeeId(request.EmployeeId, time.Now())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving assignments of employee %d: %w", request.EmployeeId, err)
+	}
+	responses := toResponses(entities)
+	if request.Inherited {
+		return svc.appendInherited(responses)
+	}
+	return responses, nil
+}
+
+// FindByRole назначения роли; по умолчанию только действующие в текущий момент
+func (svc *Service) FindByRole(request RoleRequest) ([]Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.roleRepo.FindById(request.RoleId); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.RoleId, err),
+		}
+	}
+	var entities []Entity
+	if request.All {
+		entities, err = svc.repo.FindByRoleId(request.RoleId)
+	} else {
+		entities, err = svc.repo.FindEffectiveByRoleId(request.RoleId, time.Now())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving assignments of role %d: %w", request.RoleId, err)
+	}
+	return toResponses(entities), nil
+}
+
+// checkEmployeeAndRole уволенному сотруднику роли не назначаются; до выхода на работу назначать можно
+func (svc *Service) checkEmployeeAndRole(employeeId, roleId int64) error {
+	found, err := svc.employeeRepo.FindById(employeeId)
+	if err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee with id %d: %v", employeeId, err),
+		}
+	}
+	if found.Status == employee.StatusTerminated {
+		return common.RequestValidationError{
+			Message: fmt.Sprintf("employee with id %d is terminated", employeeId),
+		}
+	}
+	if _, err := svc.roleRepo.FindById(roleId); err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", roleId, err),
+		}
+	}
+	return nil
+}
+
+// appendInherited добавить к прямым назначениям унаследованные роли. Унаследованная роль получает
+// период действия назначения, через которое она получена; роли, назначенные напрямую, не дублируются.
+func (svc *Service) appendInherited(direct []Response) ([]Response, error) {
+	type key struct{ roleId, sourceId int64 }
+	assigned := make(map[int64]bool, len(direct))
+	for _, response := range direct {
+		assigned[response.RoleId] = true
+	}
+	seen := make(map[key]bool)
+	responses := direct
+	for _, source := range direct {
+		descendants, err := svc.roleRepo.FindDescendants(source.RoleId)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving roles inherited from role %d: %w", source.RoleId, err)
+		}
+		for _, descendant := range descendants {
+			k := key{roleId: descendant.Id, sourceId: source.Id}
+			if assigned[descendant.Id] || seen[k] {
+				continue
+			}
+			seen[k] = true
+			inherited := source
+			inherited.RoleId = descendant.Id
+			inherited.RoleName = descendant.Name
+			inherited.InheritedFrom = &source.RoleId
+			responses = append(responses, inherited)
+		}
+	}
+	return responses, nil
+}
+
+func toResponses(entities []Entity) []Response {
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses
+}
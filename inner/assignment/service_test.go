@@ -0,0 +1,629 @@
+package assignment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/sod"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (int64, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ExistsOverlappingTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) (bool, error) {
+	args := m.Called(tx, employeeId, roleId, from, to)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindByEmployeeId(employeeId int64) ([]Entity, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindEffectiveByEmployeeId(employeeId int64, at time.Time) ([]Entity, error) {
+	args := m.Called(employeeId, at)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByRoleId(roleId int64) ([]Entity, error) {
+	args := m.Called(roleId)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindEffectiveByRoleId(roleId int64, at time.Time) ([]Entity, error) {
+	args := m.Called(roleId, at)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) RevokeTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) ([]Entity, error) {
+	args := m.Called(tx, employeeId, roleId, at)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) RevokeAllTx(tx *sqlx.Tx, employeeId int64, at time.Time) ([]Entity, error) {
+	args := m.Called(tx, employeeId, at)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) RevokeByRuleTx(tx *sqlx.Tx, employeeId, ruleId int64, at time.Time) ([]Entity, error) {
+	args := m.Called(tx, employeeId, ruleId, at)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsByRuleTx(tx *sqlx.Tx, employeeId, roleId int64, at time.Time) (bool, error) {
+	args := m.Called(tx, employeeId, roleId, at)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockEmployeeRepo struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeRepo) FindById(id int64) (employee.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(employee.Entity), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+func (m *MockRoleRepo) FindDescendants(id int64) ([]role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+// StubSod возвращает заданные нарушения правил и запоминает сохранённые обоснования
+type StubSod struct {
+	conflicts []sod.Entity
+	overrides []sod.OverrideEntity
+}
+
+func (s *StubSod) FindConflictsTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) ([]sod.Entity, error) {
+	return s.conflicts, nil
+}
+
+func (s *StubSod) SaveOverrideTx(tx *sqlx.Tx, e sod.OverrideEntity) (int64, error) {
+	s.overrides = append(s.overrides, e)
+	return int64(len(s.overrides)), nil
+}
+
+func TestServiceGrant(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should grant role from now when valid_from is omitted", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, new(StubSod), auditor, validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.AnythingOfType("time.Time"), (*time.Time)(nil)).
+			Return(false, nil)
+		repo.On("SaveTx", noTx, mock.MatchedBy(func(e Entity) bool {
+			return e.EmployeeId == 1 && e.RoleId == 2 && time.Since(e.ValidFrom) < time.Minute && e.ValidTo == nil
+		})).Return(int64(10), nil)
+
+		id, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.NoError(err)
+		a.Equal(int64(10), id)
+		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionCreate, auditor.events[0].Action)
+		a.Equal(int64(10), auditor.events[0].EntityId)
+	})
+
+	t.Run("should reject period that ends before it starts", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		from := time.Now()
+		to := from.Add(-time.Hour)
+		_, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2, ValidFrom: &from, ValidTo: &to})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return not found when role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(StubSod), new(StubAuditor), validator.New())
+
+		dbErr := errors.New("no rows")
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{}, dbErr)
+
+		_, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.Equal(common.NotFoundError{Message: fmt.Sprintf("error finding role with id 2: %v", dbErr)}, err)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return already exists when periods overlap", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(StubSod), new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.Anything, mock.Anything).Return(true, nil)
+
+		_, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+
+	t.Run("should reject grant to terminated employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1, Status: employee.StatusTerminated}, nil)
+
+		_, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+}
+
+func TestServiceGrantSod(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	rule := sod.Entity{Id: 4, Name: "payments", RoleAId: 2, RoleBId: 3}
+
+	t.Run("should return sod conflict and not save assignment", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		checker := &StubSod{conflicts: []sod.Entity{rule}}
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, checker, auditor, validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.Anything, mock.Anything).Return(false, nil)
+
+		_, err := svc.Grant(context.Background(), GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.Equal(common.SodConflictError{
+			Message: "granting role 2 to employee 1 violates sod rules: payments (roles 2 and 3)",
+		}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should grant with override and record justification for each rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		checker := &StubSod{conflicts: []sod.Entity{rule}}
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, checker, auditor, validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.Anything, mock.Anything).Return(false, nil)
+		repo.On("SaveTx", noTx, mock.Anything).Return(int64(10), nil)
+
+		ctx := common.WithActor(context.Background(), "alice")
+		id, err := svc.GrantOverride(ctx, OverrideRequest{
+			GrantRequest:  GrantRequest{EmployeeId: 1, RoleId: 2},
+			Justification: "covering for vacation until Friday",
+		})
+		a.NoError(err)
+		a.Equal(int64(10), id)
+		a.Len(checker.overrides, 1)
+		a.Equal(int64(4), checker.overrides[0].RuleId)
+		a.Equal(int64(10), *checker.overrides[0].AssignmentId)
+		a.Equal("alice", checker.overrides[0].CreatedBy)
+		a.Len(auditor.events, 2)
+		a.Equal(auditEntityTypeSodOverride, auditor.events[1].EntityType)
+		a.Equal("covering for vacation until Friday", auditor.events[1].After.(overrideSnapshot).Justification)
+	})
+
+	t.Run("should reject override without justification", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		_, err := svc.GrantOverride(context.Background(), OverrideRequest{GrantRequest: GrantRequest{EmployeeId: 1, RoleId: 2}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should not record overrides when grant has no conflicts", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		checker := new(StubSod)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, checker, auditor, validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.Anything, mock.Anything).Return(false, nil)
+		repo.On("SaveTx", noTx, mock.Anything).Return(int64(10), nil)
+
+		_, err := svc.GrantOverride(context.Background(), OverrideRequest{
+			GrantRequest:  GrantRequest{EmployeeId: 1, RoleId: 2},
+			Justification: "covering for vacation until Friday",
+		})
+		a.NoError(err)
+		a.Empty(checker.overrides)
+		a.Len(auditor.events, 1)
+	})
+}
+
+func TestServiceGrantTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should grant role in caller transaction", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, roles, new(StubSod), auditor, validator.New())
+
+		from := time.Now().Add(time.Hour)
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), from, (*time.Time)(nil)).Return(false, nil)
+		repo.On("SaveTx", noTx, Entity{EmployeeId: 1, RoleId: 2, ValidFrom: from}).Return(int64(10), nil)
+
+		id, err := svc.GrantTx(context.Background(), noTx, GrantRequest{EmployeeId: 1, RoleId: 2, ValidFrom: &from})
+		a.NoError(err)
+		a.Equal(int64(10), id)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+		a.Len(auditor.events, 1)
+	})
+
+	t.Run("should return audit error to caller", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(StubSod), &StubAuditor{err: errors.New("audit is down")}, validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("ExistsOverlappingTx", noTx, int64(1), int64(2), mock.Anything, mock.Anything).Return(false, nil)
+		repo.On("SaveTx", noTx, mock.Anything).Return(int64(10), nil)
+
+		_, err := svc.GrantTx(context.Background(), noTx, GrantRequest{EmployeeId: 1, RoleId: 2})
+		a.ErrorContains(err, "audit is down")
+	})
+}
+
+func TestServiceRevokeTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke role in caller transaction", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		active := Entity{Id: 10, EmployeeId: 1, RoleId: 2, ValidFrom: time.Now().Add(-time.Hour)}
+		repo.On("RevokeTx", noTx, int64(1), int64(2), mock.AnythingOfType("time.Time")).Return([]Entity{active}, nil)
+
+		err := svc.RevokeTx(context.Background(), noTx, RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.NoError(err)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionUpdate, auditor.events[0].Action)
+	})
+
+	t.Run("should return not found when role is not assigned", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		repo.On("RevokeTx", noTx, int64(1), int64(2), mock.Anything).Return([]Entity(nil), nil)
+		repo.On("ExistsByRuleTx", noTx, int64(1), int64(2), mock.Anything).Return(false, nil)
+
+		err := svc.RevokeTx(context.Background(), noTx, RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("should refuse to revoke role granted by rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		repo.On("RevokeTx", noTx, int64(1), int64(2), mock.Anything).Return([]Entity(nil), nil)
+		repo.On("ExistsByRuleTx", noTx, int64(1), int64(2), mock.Anything).Return(true, nil)
+
+		err := svc.RevokeTx(context.Background(), noTx, RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.Equal(common.RequestValidationError{
+			Message: "role 2 of employee 1 is granted by an assignment rule and cannot be revoked manually",
+		}, err)
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceGrantByRuleTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should grant role marked with rule without rereading employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		ruleId := int64(5)
+		repo.On("SaveTx", noTx, mock.MatchedBy(func(e Entity) bool {
+			return e.EmployeeId == 1 && e.RoleId == 2 && e.RuleId != nil && *e.RuleId == ruleId && e.ValidTo == nil
+		})).Return(int64(10), nil)
+
+		id, err := svc.GrantByRuleTx(context.Background(), noTx, 1, 2, ruleId)
+		a.NoError(err)
+		a.Equal(int64(10), id)
+		a.True(repo.AssertNotCalled(t, "ExistsOverlappingTx"))
+		a.True(employees.AssertNotCalled(t, "FindById"))
+		a.Len(auditor.events, 1)
+		a.Equal(&ruleId, auditor.events[0].After.(auditSnapshot).RuleId)
+	})
+
+	t.Run("should reject grant conflicting with sod rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		checker := &StubSod{conflicts: []sod.Entity{{Id: 3, Name: "Pay and approve", RoleAId: 2, RoleBId: 4}}}
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), checker, new(StubAuditor), validator.New())
+
+		_, err := svc.GrantByRuleTx(context.Background(), noTx, 1, 2, 5)
+		a.ErrorAs(err, &common.SodConflictError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+}
+
+func TestServiceRevokeByRuleTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke assignments of rule and audit them", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		ruleId := int64(5)
+		active := Entity{Id: 10, EmployeeId: 1, RoleId: 2, ValidFrom: time.Now().Add(-time.Hour), RuleId: &ruleId}
+		repo.On("RevokeByRuleTx", noTx, int64(1), ruleId, mock.AnythingOfType("time.Time")).Return([]Entity{active}, nil)
+
+		err := svc.RevokeByRuleTx(context.Background(), noTx, 1, ruleId)
+		a.NoError(err)
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionUpdate, auditor.events[0].Action)
+	})
+}
+
+func TestServiceRevokeAllTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke all roles and audit each assignment", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		active := Entity{Id: 10, EmployeeId: 1, RoleId: 2, ValidFrom: time.Now().Add(-time.Hour)}
+		future := Entity{Id: 11, EmployeeId: 1, RoleId: 3, ValidFrom: time.Now().Add(time.Hour)}
+		repo.On("RevokeAllTx", noTx, int64(1), mock.AnythingOfType("time.Time")).Return([]Entity{active, future}, nil)
+
+		err := svc.RevokeAllTx(context.Background(), noTx, 1)
+		a.NoError(err)
+		a.Len(auditor.events, 2)
+		a.Equal(audit.ActionUpdate, auditor.events[0].Action)
+		a.Equal(audit.ActionDelete, auditor.events[1].Action)
+	})
+
+	t.Run("should not fail when employee has no assignments", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		repo.On("RevokeAllTx", noTx, int64(1), mock.Anything).Return([]Entity(nil), nil)
+
+		err := svc.RevokeAllTx(context.Background(), noTx, 1)
+		a.NoError(err)
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceRevoke(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke role and audit closed and removed assignments", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), auditor, validator.New())
+
+		active := Entity{Id: 10, EmployeeId: 1, RoleId: 2, ValidFrom: time.Now().Add(-time.Hour)}
+		future := Entity{Id: 11, EmployeeId: 1, RoleId: 2, ValidFrom: time.Now().Add(time.Hour)}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RevokeTx", noTx, int64(1), int64(2), mock.AnythingOfType("time.Time")).
+			Return([]Entity{active, future}, nil)
+
+		err := svc.Revoke(context.Background(), RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.NoError(err)
+		a.Len(auditor.events, 2)
+		a.Equal(audit.ActionUpdate, auditor.events[0].Action)
+		a.Equal(int64(10), auditor.events[0].EntityId)
+		a.NotNil(auditor.events[0].After.(auditSnapshot).ValidTo)
+		a.Equal(audit.ActionDelete, auditor.events[1].Action)
+		a.Equal(int64(11), auditor.events[1].EntityId)
+		a.Nil(auditor.events[1].After)
+	})
+
+	t.Run("should return not found when nothing to revoke", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RevokeTx", noTx, int64(1), int64(2), mock.AnythingOfType("time.Time")).Return([]Entity(nil), nil)
+		repo.On("ExistsByRuleTx", noTx, int64(1), int64(2), mock.AnythingOfType("time.Time")).Return(false, nil)
+
+		err := svc.Revoke(context.Background(), RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("should return wrapped error when transaction begin fails", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		dbErr := errors.New("transaction begin error")
+		repo.On("BeginTransaction").Return(noTx, dbErr)
+
+		err := svc.Revoke(context.Background(), RevokeRequest{EmployeeId: 1, RoleId: 2})
+		a.EqualError(err, fmt.Errorf("error creating transaction: %w", dbErr).Error())
+	})
+}
+
+func TestServiceFindByEmployee(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return only effective assignments by default", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		entities := []Entity{{Id: 1, EmployeeId: 1, RoleId: 2, RoleName: "Accountant"}}
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		repo.On("FindEffectiveByEmployeeId", int64(1), mock.AnythingOfType("time.Time")).Return(entities, nil)
+
+		got, err := svc.FindByEmployee(EmployeeRequest{EmployeeId: 1})
+		a.NoError(err)
+		a.Equal([]Response{entities[0].toResponse()}, got)
+		a.True(repo.AssertNotCalled(t, "FindByEmployeeId", int64(1)))
+	})
+
+	t.Run("should return all assignments when requested", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		repo.On("FindByEmployeeId", int64(1)).Return([]Entity{{Id: 1}, {Id: 2}}, nil)
+
+		got, err := svc.FindByEmployee(EmployeeRequest{EmployeeId: 1, All: true})
+		a.NoError(err)
+		a.Len(got, 2)
+	})
+
+	t.Run("should expand inherited roles when requested", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, employees, roles, new(StubSod), new(StubAuditor), validator.New())
+
+		senior := Entity{Id: 1, EmployeeId: 1, RoleId: 10, RoleName: "Senior Accountant"}
+		auditor := Entity{Id: 2, EmployeeId: 1, RoleId: 30, RoleName: "Auditor"}
+		employees.On("FindById", int64(1)).Return(employee.Entity{Id: 1}, nil)
+		repo.On("FindEffectiveByEmployeeId", int64(1), mock.AnythingOfType("time.Time")).
+			Return([]Entity{senior, auditor}, nil)
+		// Senior Accountant включает Accountant и Auditor, который уже назначен напрямую
+		roles.On("FindDescendants", int64(10)).Return([]role.Entity{
+			{Id: 20, Name: "Accountant"},
+			{Id: 30, Name: "Auditor"},
+		}, nil)
+		roles.On("FindDescendants", int64(30)).Return([]role.Entity{}, nil)
+
+		got, err := svc.FindByEmployee(EmployeeRequest{EmployeeId: 1, Inherited: true})
+		a.NoError(err)
+		a.Len(got, 3)
+		a.Equal(int64(20), got[2].RoleId)
+		a.Equal("Accountant", got[2].RoleName)
+		a.Equal(senior.Id, got[2].Id)
+		a.NotNil(got[2].InheritedFrom)
+		a.Equal(int64(10), *got[2].InheritedFrom)
+		a.Nil(got[0].InheritedFrom)
+	})
+
+	t.Run("should return not found when employee does not exist", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(1)).Return(employee.Entity{}, errors.New("no rows"))
+
+		_, err := svc.FindByEmployee(EmployeeRequest{EmployeeId: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceFindByRole(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return effective assignments of role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), roles, new(StubSod), new(StubAuditor), validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("FindEffectiveByRoleId", int64(2), mock.AnythingOfType("time.Time")).Return([]Entity{{Id: 1}}, nil)
+
+		got, err := svc.FindByRole(RoleRequest{RoleId: 2})
+		a.NoError(err)
+		a.Len(got, 1)
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockEmployeeRepo), new(MockRoleRepo), new(StubSod), new(StubAuditor), validator.New())
+
+		_, err := svc.FindByRole(RoleRequest{})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestEntityIsEffectiveAt(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	end := now.Add(time.Hour)
+
+	a.True((&Entity{ValidFrom: now}).IsEffectiveAt(now))
+	a.False((&Entity{ValidFrom: now.Add(time.Second)}).IsEffectiveAt(now))
+	a.True((&Entity{ValidFrom: now, ValidTo: &end}).IsEffectiveAt(end.Add(-time.Second)))
+	a.False((&Entity{ValidFrom: now, ValidTo: &end}).IsEffectiveAt(end))
+}
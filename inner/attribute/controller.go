@@ -0,0 +1,142 @@
+package attribute
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты схемы атрибутов сотрудников
+const (
+	permissionRead   = "employee_attributes:read"
+	permissionManage = "employee_attributes:manage"
+)
+
+type Controller struct {
+	server           *web.Server
+	attributeService Svc
+	logger           *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Delete(ctx context.Context, request IdRequest) error
+}
+
+func NewController(server *web.Server, attributeService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:           server,
+		attributeService: attributeService,
+		logger:           logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/employee-attributes", c.server.Require(permissionManage), c.CreateAttribute)
+	c.server.GroupApiV1.Get("/employee-attributes", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/employee-attributes/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Put("/employee-attributes/:id", c.server.Require(permissionManage), c.UpdateAttribute)
+	c.server.GroupApiV1.Delete("/employee-attributes/:id", c.server.Require(permissionManage), c.DeleteAttribute)
+}
+
+func (c *Controller) CreateAttribute(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create employee attribute: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create employee attribute: received request", zap.Any("request", request))
+	response, err := c.attributeService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create employee attribute: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create employee attribute: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee attribute by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee attribute by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.attributeService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find employee attribute by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee attribute by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all employee attributes: received request")
+	responses, err := c.attributeService.FindAll()
+	if err != nil {
+		c.logger.Error("find all employee attributes: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all employee attributes: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) UpdateAttribute(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update employee attribute: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update employee attribute: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update employee attribute: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.attributeService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update employee attribute: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update employee attribute: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DeleteAttribute(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete employee attribute: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete employee attribute: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.attributeService.Delete(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete employee attribute: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete employee attribute: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,158 @@
+package attribute
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Delete(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func TestControllerCreateAttribute(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should create attribute", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Key: "cost_center", Type: "string", Required: true}).
+			Return(Response{Id: 1, Key: "cost_center", Type: "string", Required: true}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employee-attributes",
+			strings.NewReader(`{"key":"cost_center","type":"string","required":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.True(responseBody.Success)
+		a.Equal("cost_center", responseBody.Data.Key)
+	})
+
+	t.Run("should return bad request when key is taken", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Key: "cost_center", Type: "string"}).
+			Return(Response{}, common.AlreadyExistsError{Message: "employee attribute cost_center already exists"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employee-attributes",
+			strings.NewReader(`{"key":"cost_center","type":"string"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerDeleteAttribute(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Delete", IdRequest{Id: 2}).Return(common.NotFoundError{Message: "employee attribute with id 2 not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, "/api/v1/employee-attributes/2", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// DenyAuthorizer отклоняет любой запрос и сообщает, какое разрешение потребовал маршрут
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	routes := []struct {
+		method     string
+		url        string
+		permission string
+	}{
+		{fiber.MethodPost, "/api/v1/employee-attributes", "employee_attributes:manage"},
+		{fiber.MethodGet, "/api/v1/employee-attributes", "employee_attributes:read"},
+		{fiber.MethodGet, "/api/v1/employee-attributes/1", "employee_attributes:read"},
+		{fiber.MethodPut, "/api/v1/employee-attributes/1", "employee_attributes:manage"},
+		{fiber.MethodDelete, "/api/v1/employee-attributes/1", "employee_attributes:manage"},
+	}
+
+	t.Run("should require permission on every route", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = DenyAuthorizer{}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, route := range routes {
+			resp, err := server.App.Test(httptest.NewRequest(route.method, route.url, nil))
+			a.Nil(err)
+			a.Equal(http.StatusForbidden, resp.StatusCode, route.url)
+
+			bytesData, err := io.ReadAll(resp.Body)
+			a.Nil(err)
+			var responseBody common.Response[any]
+			a.Nil(json.Unmarshal(bytesData, &responseBody))
+			a.Equal("missing permission "+route.permission, responseBody.Message, route.method+" "+route.url)
+		}
+		a.Empty(svc.Calls)
+	})
+}
@@ -0,0 +1,57 @@
+package attribute
+
+import (
+	"idm/inner/validator"
+	"time"
+)
+
+// Entity определение пользовательского атрибута сотрудников: ключ в employee.attributes и тип его значения
+type Entity struct {
+	Id          int64     `db:"id"`
+	Key         string    `db:"key"`
+	Type        string    `db:"type"`
+	Required    bool      `db:"required"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:          e.Id,
+		Key:         e.Key,
+		Type:        e.Type,
+		Required:    e.Required,
+		Description: e.Description,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// Rule правило, по которому validator проверяет значение атрибута
+func (e *Entity) Rule() validator.Attribute {
+	return validator.Attribute{Key: e.Key, Type: e.Type, Required: e.Required}
+}
+
+// auditSnapshot состояние определения в журнале аудита
+type auditSnapshot struct {
+	Id          int64  `json:"id"`
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Key: e.Key, Type: e.Type, Required: e.Required, Description: e.Description}
+}
+
+type Response struct {
+	Id          int64     `json:"id"`
+	Key         string    `json:"key"`
+	Type        string    `json:"type"`
+	Required    bool      `json:"required"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,87 @@
+package attribute
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into employee_attribute (key, type, required, description) values ($1, $2, $3, $4)
+		returning *`
+	err = tx.Get(&saved, query, e.Key, e.Type, e.Required, e.Description)
+	return saved, err
+}
+
+func (r *Repository) ExistsTx(tx *sqlx.Tx, key string) (exists bool, err error) {
+	err = tx.Get(&exists, "select exists(select 1 from employee_attribute where key = $1)", key)
+	return exists, err
+}
+
+func (r *Repository) FindById(id int64) (definition Entity, err error) {
+	err = r.db.Get(&definition, "select * from employee_attribute where id = $1", id)
+	return definition, err
+}
+
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (definition Entity, err error) {
+	err = tx.Get(&definition, "select * from employee_attribute where id = $1 for update", id)
+	return definition, err
+}
+
+func (r *Repository) FindAll() (definitions []Entity, err error) {
+	err = r.db.Select(&definitions, "select * from employee_attribute order by key")
+	return definitions, err
+}
+
+// FindAllTx схема атрибутов для проверки значений в транзакции изменения сотрудника. Блокировка
+// на чтение не даёт изменить определения до конца транзакции
+func (r *Repository) FindAllTx(tx *sqlx.Tx) (definitions []Entity, err error) {
+	err = tx.Select(&definitions, "select * from employee_attribute order by key for share")
+	return definitions, err
+}
+
+// FindRuleNamesTx имена правил назначения, условия которых ссылаются на атрибут key
+func (r *Repository) FindRuleNamesTx(tx *sqlx.Tx, key string) (names []string, err error) {
+	query := `select name from assignment_rule
+		where conditions @> jsonb_build_array(jsonb_build_object('attribute', 'attributes.' || $1::text))
+		order by name`
+	err = tx.Select(&names, query, key)
+	return names, err
+}
+
+// CountMissingTx число сотрудников, кроме удалённых, у которых нет значения атрибута key
+func (r *Repository) CountMissingTx(tx *sqlx.Tx, key string) (count int64, err error) {
+	err = tx.Get(&count, "select count(*) from employee where deleted_at is null and not attributes ? $1", key)
+	return count, err
+}
+
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := "update employee_attribute set required = $2, description = $3 where id = $1 returning *"
+	err = tx.Get(&updated, query, e.Id, e.Required, e.Description)
+	return updated, err
+}
+
+func (r *Repository) DeleteTx(tx *sqlx.Tx, id int64) (deleted Entity, err error) {
+	err = tx.Get(&deleted, "delete from employee_attribute where id = $1 returning *", id)
+	return deleted, err
+}
+
+// RemoveValuesTx убрать значения атрибута key у всех сотрудников, в том числе удалённых,
+// чтобы их следующее изменение не споткнулось о неизвестный ключ. Возвращает число затронутых сотрудников
+func (r *Repository) RemoveValuesTx(tx *sqlx.Tx, key string) (removed int64, err error) {
+	result, err := tx.Exec("update employee set attributes = attributes - $1::text where attributes ? $1", key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
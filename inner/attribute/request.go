@@ -0,0 +1,22 @@
+package attribute
+
+// CreateRequest определить атрибут. Key - ключ в объекте attributes сотрудника: строчные латинские буквы,
+// цифры и подчёркивание, начиная с буквы
+type CreateRequest struct {
+	Key         string `json:"key" validate:"required,min=2,max=64"`
+	Type        string `json:"type" validate:"required,oneof=string number boolean date"`
+	Required    bool   `json:"required"`
+	Description string `json:"description" validate:"max=2000"`
+}
+
+// UpdateRequest изменить обязательность и описание атрибута. Ключ и тип не меняются:
+// у сотрудников уже сохранены значения под этим ключом и этого типа
+type UpdateRequest struct {
+	Id          int64  `json:"id" validate:"required,gt=0"`
+	Required    bool   `json:"required"`
+	Description string `json:"description" validate:"max=2000"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
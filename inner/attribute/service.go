@@ -0,0 +1,222 @@
+package attribute
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"regexp"
+	"strings"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "employee_attribute"
+
+// keyPattern допустимый ключ атрибута: snake_case, начиная с буквы
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type Service struct {
+	repo      Repo
+	auditor   Auditor
+	validator Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExistsTx(tx *sqlx.Tx, key string) (bool, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, id int64) (Entity, error)
+	RemoveValuesTx(tx *sqlx.Tx, key string) (int64, error)
+	CountMissingTx(tx *sqlx.Tx, key string) (int64, error)
+	FindRuleNamesTx(tx *sqlx.Tx, key string) ([]string, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, auditor Auditor, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		auditor:   auditor,
+		validator: validator,
+	}
+}
+
+// Create определить атрибут. Обязательный атрибут не проверяется у уже сохранённых сотрудников,
+// пока их не изменят
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if !keyPattern.MatchString(request.Key) {
+		return Response{}, common.RequestValidationError{
+			Message: fmt.Sprintf("attribute key %q must be lowercase letters, digits and underscores", request.Key),
+		}
+	}
+	entity := Entity{Key: request.Key, Type: request.Type, Required: request.Required, Description: request.Description}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating employee attribute", func(tx *sqlx.Tx) error {
+		exists, err := svc.repo.ExistsTx(tx, request.Key)
+		if err != nil {
+			return fmt.Errorf("error finding employee attribute %s: %w", request.Key, err)
+		}
+		if exists {
+			return common.AlreadyExistsError{
+				Message: fmt.Sprintf("employee attribute %s already exists", request.Key),
+			}
+		}
+		if entity.Required {
+			if err = svc.checkRequired(tx, entity.Key); err != nil {
+				return err
+			}
+		}
+		saved, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving employee attribute %s: %w", request.Key, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee attribute with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all employee attributes: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating employee attribute", func(tx *sqlx.Tx) error {
+		before, err := svc.repo.FindByIdForUpdateTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("employee attribute with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding employee attribute with id %d: %w", request.Id, err)
+		}
+		after := before
+		after.Required = request.Required
+		after.Description = request.Description
+		if after.Required && !before.Required {
+			if err = svc.checkRequired(tx, after.Key); err != nil {
+				return err
+			}
+		}
+		updated, err = svc.repo.UpdateTx(tx, after)
+		if err != nil {
+			return fmt.Errorf("error updating employee attribute with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// checkRequired обязательным можно сделать только атрибут, который заполнен у всех сотрудников:
+// иначе любое изменение сотрудника без значения было бы отклонено
+func (svc *Service) checkRequired(tx *sqlx.Tx, key string) error {
+	missing, err := svc.repo.CountMissingTx(tx, key)
+	if err != nil {
+		return fmt.Errorf("error counting employees without attribute %s: %w", key, err)
+	}
+	if missing > 0 {
+		return common.RequestValidationError{
+			Message: fmt.Sprintf("attribute %s cannot be required: %d employees have no value", key, missing),
+		}
+	}
+	return nil
+}
+
+// Delete удалить определение вместе со значениями атрибута у всех сотрудников;
+// прежние значения остаются в истории сотрудников. Атрибут, на который ссылаются правила
+// назначения, удалить нельзя, пока правила не изменены
+func (svc *Service) Delete(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting employee attribute", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("employee attribute with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting employee attribute with id %d: %w", request.Id, err)
+		}
+		rules, err := svc.repo.FindRuleNamesTx(tx, deleted.Key)
+		if err != nil {
+			return fmt.Errorf("error finding assignment rules using attribute %s: %w", deleted.Key, err)
+		}
+		if len(rules) > 0 {
+			// без атрибута условие правила не выполнялось бы ни для кого, и правило молча отозвало бы роли
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("attribute %s is used by assignment rules: %s", deleted.Key, strings.Join(rules, ", ")),
+			}
+		}
+		if _, err = svc.repo.RemoveValuesTx(tx, deleted.Key); err != nil {
+			return fmt.Errorf("error removing values of employee attribute %s: %w", deleted.Key, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted.Id,
+			Before:     deleted.auditSnapshot(),
+		})
+	})
+}
@@ -0,0 +1,263 @@
+package attribute
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"testing"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsTx(tx *sqlx.Tx, key string) (bool, error) {
+	args := m.Called(tx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) RemoveValuesTx(tx *sqlx.Tx, key string) (int64, error) {
+	args := m.Called(tx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) FindRuleNamesTx(tx *sqlx.Tx, key string) ([]string, error) {
+	args := m.Called(tx, key)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) CountMissingTx(tx *sqlx.Tx, key string) (int64, error) {
+	args := m.Called(tx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should create attribute and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		entity := Entity{Key: "cost_center", Type: "string", Required: true}
+		saved := Entity{Id: 1, Key: "cost_center", Type: "string", Required: true}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, "cost_center").Return(false, nil)
+		repo.On("CountMissingTx", noTx, "cost_center").Return(int64(0), nil)
+		repo.On("SaveTx", noTx, entity).Return(saved, nil)
+
+		got, err := svc.Create(context.Background(), CreateRequest{Key: "cost_center", Type: "string", Required: true})
+		a.NoError(err)
+		a.Equal(saved.toResponse(), got)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "employee_attribute",
+			EntityId:   1,
+			After:      auditSnapshot{Id: 1, Key: "cost_center", Type: "string", Required: true},
+		}}, auditor.events)
+	})
+
+	t.Run("should reject unknown type", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Key: "shoe_size", Type: "integer"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should reject key that is not snake_case", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Key: "Cost-Center", Type: "string"})
+		a.Equal(common.RequestValidationError{
+			Message: `attribute key "Cost-Center" must be lowercase letters, digits and underscores`,
+		}, err)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return already exists error for taken key", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, "cost_center").Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Key: "cost_center", Type: "string"})
+		a.Equal(common.AlreadyExistsError{Message: "employee attribute cost_center already exists"}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should keep key and type when updating", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		before := Entity{Id: 1, Key: "cost_center", Type: "string"}
+		after := Entity{Id: 1, Key: "cost_center", Type: "string", Required: true, Description: "Cost center code"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("CountMissingTx", noTx, "cost_center").Return(int64(0), nil)
+		repo.On("UpdateTx", noTx, after).Return(after, nil)
+
+		got, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Required: true, Description: "Cost center code"})
+		a.NoError(err)
+		a.Equal(after.toResponse(), got)
+		a.Len(auditor.events, 1)
+		a.Equal(before.auditSnapshot(), auditor.events[0].Before)
+	})
+
+	t.Run("should reject making attribute required while employees lack a value", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(Entity{Id: 1, Key: "cost_center", Type: "string"}, nil)
+		repo.On("CountMissingTx", noTx, "cost_center").Return(int64(3), nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Required: true})
+		a.Equal(common.RequestValidationError{
+			Message: "attribute cost_center cannot be required: 3 employees have no value",
+		}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should not count values when attribute already required", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		before := Entity{Id: 1, Key: "cost_center", Type: "string", Required: true}
+		after := Entity{Id: 1, Key: "cost_center", Type: "string", Required: true, Description: "Cost center"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("UpdateTx", noTx, after).Return(after, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Required: true, Description: "Cost center"})
+		a.NoError(err)
+		a.True(repo.AssertNotCalled(t, "CountMissingTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(7)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 7})
+		a.Equal(common.NotFoundError{Message: "employee attribute with id 7 not found"}, err)
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should remove attribute values from employees", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		deleted := Entity{Id: 1, Key: "cost_center", Type: "string"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(1)).Return(deleted, nil)
+		repo.On("FindRuleNamesTx", noTx, "cost_center").Return([]string(nil), nil)
+		repo.On("RemoveValuesTx", noTx, "cost_center").Return(int64(3), nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "RemoveValuesTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionDelete,
+			EntityType: "employee_attribute",
+			EntityId:   1,
+			Before:     deleted.auditSnapshot(),
+		}}, auditor.events)
+	})
+
+	t.Run("should reject attribute used by assignment rules", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(1)).Return(Entity{Id: 1, Key: "cost_center", Type: "string"}, nil)
+		repo.On("FindRuleNamesTx", noTx, "cost_center").Return([]string{"finance", "sales"}, nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 1})
+		a.Equal(common.RequestValidationError{
+			Message: "attribute cost_center is used by assignment rules: finance, sales",
+		}, err)
+		a.True(repo.AssertNotCalled(t, "RemoveValuesTx", mock.Anything, mock.Anything))
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(7)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 7})
+		a.Equal(common.NotFoundError{Message: "employee attribute with id 7 not found"}, err)
+		a.True(repo.AssertNotCalled(t, "RemoveValuesTx"))
+	})
+}
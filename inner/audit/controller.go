@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// permissionRead разрешение на просмотр журнала аудита
+const permissionRead = "audit:read"
+
+type Controller struct {
+	server       *web.Server
+	auditService Svc
+	logger       *common.Logger
+}
+
+type Svc interface {
+	FindAll(request ListRequest) (common.Page[Response], error)
+}
+
+func NewController(server *web.Server, auditService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:       server,
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Get("/audit", c.server.Require(permissionRead), c.FindAll)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		c.logger.Error("find audit log: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find audit log: received request", zap.Any("request", request))
+	page, err := c.auditService.FindAll(request)
+	if err != nil {
+		c.logger.Error("find audit log: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find audit log: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+// parseListRequest прочитать фильтры журнала из query: entity_type, entity_id, actor, from, to
+func parseListRequest(ctx *fiber.Ctx) (request ListRequest, err error) {
+	if request.PageRequest, err = common.ParsePageRequest(ctx); err != nil {
+		return request, err
+	}
+	request.EntityType = ctx.Query("entity_type")
+	request.Actor = ctx.Query("actor")
+	if raw := ctx.Query("entity_id"); raw != "" {
+		entityId, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return request, fmt.Errorf("invalid entity_id %q", raw)
+		}
+		request.EntityId = &entityId
+	}
+	if request.From, err = common.QueryTime(ctx, "from"); err != nil {
+		return request, err
+	}
+	request.To, err = common.QueryTime(ctx, "to")
+	return request, err
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
+}
+
+func TestControllerFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters and return page of records", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		entityId := int64(7)
+		from := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC)
+		request := ListRequest{
+			PageRequest: common.PageRequest{Limit: 10},
+			EntityType:  "employee",
+			EntityId:    &entityId,
+			Actor:       "alice",
+			From:        &from,
+			To:          &to,
+		}
+		page := common.Page[Response]{
+			Items: []Response{{
+				Id:         1,
+				Actor:      "alice",
+				Action:     ActionUpdate,
+				EntityType: "employee",
+				EntityId:   7,
+				Before:     json.RawMessage(`{"name":"Bob"}`),
+				After:      json.RawMessage(`{"name":"Bobby"}`),
+			}},
+			PageInfo: common.PageInfo{Total: 1},
+		}
+		svc.On("FindAll", request).Return(page, nil)
+
+		url := "/api/v1/audit?limit=10&entity_type=employee&entity_id=7&actor=alice" +
+			"&from=2025-08-01T00:00:00Z&to=2025-08-02T00:00:00Z"
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, url, nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.JSONEq(`{"name":"Bobby"}`, string(responseBody.Data[0].After))
+		a.Equal(int64(1), responseBody.Page.Total)
+	})
+
+	t.Run("should return bad request on invalid entity id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/audit?entity_id=abc", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+
+	t.Run("should return bad request on invalid time", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/audit?from=yesterday", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return internal server error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll", mock.AnythingOfType("audit.ListRequest")).
+			Return(common.Page[Response]{}, errors.New("database error"))
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/audit", nil))
+		a.Nil(err)
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Действия, общие для всех сущностей; остальные действия задаются там, где происходят
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Event изменение, которое нужно записать в журнал. Before и After сериализуются в JSON;
+// nil означает, что состояния нет (до создания или после удаления).
+type Event struct {
+	Action     string
+	EntityType string
+	EntityId   int64
+	Before     any
+	After      any
+}
+
+type Entity struct {
+	Id         int64     `db:"id"`
+	Actor      string    `db:"actor"`
+	Action     string    `db:"action"`
+	EntityType string    `db:"entity_type"`
+	EntityId   int64     `db:"entity_id"`
+	Before     []byte    `db:"before"`
+	After      []byte    `db:"after"`
+	RequestId  string    `db:"request_id"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:         e.Id,
+		Actor:      e.Actor,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityId:   e.EntityId,
+		Before:     e.Before,
+		After:      e.After,
+		RequestId:  e.RequestId,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+type Response struct {
+	Id         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityId   int64           `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestId  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"github.com/jmoiron/sqlx"
+	"idm/inner/common"
+	"idm/inner/database"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (id int64, err error) {
+	query := `insert into audit_log (actor, action, entity_type, entity_id, before, after, request_id)
+		values ($1, $2, $3, $4, $5, $6, $7) returning id`
+	err = tx.QueryRowx(query, e.Actor, e.Action, e.EntityType, e.EntityId, e.Before, e.After, e.RequestId).Scan(&id)
+	return id, err
+}
+
+// FindPage найти страницу журнала по фильтру; возвращает до Limit+1 записей,
+// лишняя запись означает, что есть следующая страница
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (entries []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	query := "select * from audit_log" + conditions.Where() + order
+	err = r.db.Select(&entries, query, conditions.Args()...)
+	return entries, err
+}
+
+// Count количество записей журнала, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from audit_log" + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.EntityType != "" {
+		conditions.Add("entity_type = ?", request.EntityType)
+	}
+	if request.EntityId != nil {
+		conditions.Add("entity_id = ?", *request.EntityId)
+	}
+	if request.Actor != "" {
+		conditions.Add("actor = ?", request.Actor)
+	}
+	if request.From != nil {
+		conditions.Add("created_at >= ?", *request.From)
+	}
+	if request.To != nil {
+		conditions.Add("created_at < ?", *request.To)
+	}
+	return conditions
+}
@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"idm/inner/common"
+	"time"
+)
+
+// ListRequest фильтры журнала аудита. Записи всегда упорядочены по id, по умолчанию от новых к старым.
+type ListRequest struct {
+	common.PageRequest
+	EntityType string     `json:"entity_type" validate:"max=50"`
+	EntityId   *int64     `json:"entity_id" validate:"omitempty,gt=0"`
+	Actor      string     `json:"actor" validate:"max=255"`
+	From       *time.Time `json:"from"`
+	To         *time.Time `json:"to"`
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/common"
+	"strconv"
+)
+
+// anonymousActor записывается, если изменение сделано без аутентификации
+const anonymousActor = "anonymous"
+
+type Service struct {
+	repo      Repo
+	validator Validator
+}
+
+type Repo interface {
+	SaveTx(tx *sqlx.Tx, e Entity) (int64, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		validator: validator,
+	}
+}
+
+// RecordTx записать событие в журнал в транзакции tx, в которой выполняется само изменение:
+// если изменение откатится, запись журнала откатится вместе с ним.
+// Кто выполнил изменение и в каком запросе, берётся из ctx.
+func (svc *Service) RecordTx(ctx context.Context, tx *sqlx.Tx, event Event) error {
+	before, err := marshalSnapshot(event.Before)
+	if err != nil {
+		return fmt.Errorf("error serializing audit snapshot of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	after, err := marshalSnapshot(event.After)
+	if err != nil {
+		return fmt.Errorf("error serializing audit snapshot of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	actor := common.ActorFrom(ctx)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	_, err = svc.repo.SaveTx(tx, Entity{
+		Actor:      actor,
+		Action:     event.Action,
+		EntityType: event.EntityType,
+		EntityId:   event.EntityId,
+		Before:     before,
+		After:      after,
+		RequestId:  common.RequestIdFrom(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("error saving audit record of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	return nil
+}
+
+func marshalSnapshot(snapshot any) ([]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+// FindAll найти страницу журнала по фильтру
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Sort = "id"
+	if request.Order == "" {
+		request.Order = "desc"
+	}
+	request.Defaults()
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entries, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error retrieving audit log: %w", err)
+	}
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting audit log: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(entries) > request.Limit {
+		entries = entries[:request.Limit]
+		last := entries[len(entries)-1]
+		page.NextCursor = request.Next(strconv.FormatInt(last.Id, 10), last.Id)
+	}
+	page.Items = make([]Response, 0, len(entries))
+	for _, entry := range entries {
+		page.Items = append(page.Items, entry.toResponse())
+	}
+	return page, nil
+}
@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (int64, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type snapshot struct {
+	Name string `json:"name"`
+}
+
+func TestServiceRecordTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should save actor, request id and serialized snapshots", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		ctx := common.WithRequestId(common.WithActor(context.Background(), "alice"), "req-1")
+		repo.On("SaveTx", noTx, Entity{
+			Actor:      "alice",
+			Action:     ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     []byte(`{"name":"Bob"}`),
+			After:      []byte(`{"name":"Bobby"}`),
+			RequestId:  "req-1",
+		}).Return(int64(10), nil)
+
+		err := svc.RecordTx(ctx, noTx, Event{
+			Action:     ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     snapshot{Name: "Bob"},
+			After:      snapshot{Name: "Bobby"},
+		})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+	})
+
+	t.Run("should record anonymous actor and null snapshot", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		repo.On("SaveTx", noTx, Entity{
+			Actor:      "anonymous",
+			Action:     ActionCreate,
+			EntityType: "role",
+			EntityId:   2,
+			After:      []byte(`{"name":"Admin"}`),
+		}).Return(int64(11), nil)
+
+		err := svc.RecordTx(context.Background(), noTx, Event{
+			Action:     ActionCreate,
+			EntityType: "role",
+			EntityId:   2,
+			After:      snapshot{Name: "Admin"},
+		})
+		a.NoError(err)
+	})
+
+	t.Run("should return wrapped error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		dbErr := errors.New("database error")
+		repo.On("SaveTx", noTx, mock.AnythingOfType("audit.Entity")).Return(int64(0), dbErr)
+
+		err := svc.RecordTx(context.Background(), noTx, Event{Action: ActionDelete, EntityType: "role", EntityId: 2})
+		a.ErrorIs(err, dbErr)
+		a.ErrorContains(err, "role 2")
+	})
+}
+
+func TestServiceFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return newest records first with next cursor", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		entityId := int64(1)
+		request := ListRequest{
+			PageRequest: common.PageRequest{Limit: 2, Sort: "id", Order: "desc"},
+			EntityType:  "employee",
+			EntityId:    &entityId,
+		}
+		createdAt := time.Date(2025, 8, 10, 10, 0, 0, 0, time.UTC)
+		repo.On("FindPage", request, (*common.Cursor)(nil)).Return([]Entity{
+			{Id: 30, Action: ActionDelete, EntityType: "employee", EntityId: 1, CreatedAt: createdAt},
+			{Id: 20, Action: ActionUpdate, EntityType: "employee", EntityId: 1, CreatedAt: createdAt},
+			{Id: 10, Action: ActionCreate, EntityType: "employee", EntityId: 1, CreatedAt: createdAt},
+		}, nil)
+		repo.On("Count", request).Return(int64(3), nil)
+
+		got, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Limit: 2}, EntityType: "employee", EntityId: &entityId})
+		a.NoError(err)
+		a.Len(got.Items, 2)
+		a.Equal(int64(20), got.Items[1].Id)
+		a.Equal(int64(3), got.Total)
+		a.Equal(request.Next("20", 20), got.NextCursor)
+	})
+
+	t.Run("should ignore requested sort", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		request := ListRequest{PageRequest: common.PageRequest{Limit: common.DefaultPageLimit, Sort: "id", Order: "asc"}}
+		repo.On("FindPage", request, (*common.Cursor)(nil)).Return([]Entity{}, nil)
+		repo.On("Count", request).Return(int64(0), nil)
+
+		got, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Sort: "name", Order: "asc"}})
+		a.NoError(err)
+		a.Empty(got.Items)
+		a.NotNil(got.Items)
+	})
+
+	t.Run("should reject malformed cursor", func(t *testing.T) {
+		svc := NewService(new(MockRepo), validator.New())
+
+		_, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Cursor: "not a cursor"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"strings"
+	"time"
+)
+
+// Схемы заголовка Authorization
+const (
+	schemeBearer = "Bearer"
+	schemeApiKey = "ApiKey"
+)
+
+// leeway допустимое расхождение часов с издателем при проверке exp и nbf
+const leeway = 30 * time.Second
+
+// ApiKeyVerifier проверка ключей доступа сервисных учётных записей
+type ApiKeyVerifier interface {
+	VerifyApiKey(key string) (subject string, scopes []string, err error)
+}
+
+// KeySource открытые ключи проверки подписи по kid
+type KeySource interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// localTokens проверка токенов, которые выпускает сама IDM
+type localTokens struct {
+	issuer string
+	keys   KeySource
+	parser *jwt.Parser
+}
+
+// scopedClaims claims токенов IDM: scope - разрешения через пробел, которыми ограничен токен
+type scopedClaims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+// Authenticator проверяет JWT из заголовка Authorization: Bearer и, если подключены,
+// ключи доступа из заголовка Authorization: ApiKey
+type Authenticator struct {
+	keys    *KeySet
+	issuer  string
+	parser  *jwt.Parser
+	local   *localTokens
+	apiKeys ApiKeyVerifier
+	logger  *common.Logger
+}
+
+// NewAuthenticator настроить проверку токенов по конфигурации: JWKS из файла или по адресу,
+// ожидаемые издатель и получатель обязательны
+func NewAuthenticator(cfg common.Config, logger *common.Logger) (*Authenticator, error) {
+	if cfg.AuthIssuer == "" || cfg.AuthAudience == "" {
+		return nil, errors.New("AUTH_ISSUER and AUTH_AUDIENCE must be set")
+	}
+	var keys *KeySet
+	var err error
+	switch {
+	case cfg.AuthJwksFile != "":
+		keys, err = NewFileKeySet(cfg.AuthJwksFile, cfg.AuthJwksRefresh)
+	case cfg.AuthJwksUrl != "":
+		keys, err = NewUrlKeySet(cfg.AuthJwksUrl, cfg.AuthJwksRefresh)
+	default:
+		return nil, errors.New("AUTH_JWKS_FILE or AUTH_JWKS_URL must be set")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthenticatorWithKeys(keys, cfg.AuthIssuer, cfg.AuthAudience, logger), nil
+}
+
+// NewAuthenticatorWithKeys проверка токенов по готовому набору ключей
+func NewAuthenticatorWithKeys(keys *KeySet, issuer, audience string, logger *common.Logger) *Authenticator {
+	return &Authenticator{
+		keys:   keys,
+		issuer: issuer,
+		parser: newParser(issuer, audience),
+		logger: logger,
+	}
+}
+
+func newParser(issuer, audience string) *jwt.Parser {
+	return jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	)
+}
+
+// AcceptLocalTokens принимать наряду с токенами внешнего издателя токены, которые выпускает сама IDM
+// с издателем issuer; такие токены ограничены разрешениями из claim scope
+func (a *Authenticator) AcceptLocalTokens(keys KeySource, issuer, audience string) error {
+	if issuer == "" || issuer == a.issuer {
+		return errors.New("issuer of local tokens must be set and differ from AUTH_ISSUER")
+	}
+	a.local = &localTokens{issuer: issuer, keys: keys, parser: newParser(issuer, audience)}
+	return nil
+}
+
+// Verify проверить подпись и claims токена и вернуть субъект (claim sub)
+func (a *Authenticator) Verify(token string) (string, error) {
+	parsed, err := a.parser.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.keys.Key(kid)
+	})
+	if err != nil {
+		return "", err
+	}
+	return subjectOf(parsed.Claims)
+}
+
+// verifyLocal проверить токен IDM и вернуть субъект и разрешения из claim scope
+func (a *Authenticator) verifyLocal(token string) (string, []string, error) {
+	var claims scopedClaims
+	_, err := a.local.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.local.keys.Key(kid)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	subject, err := subjectOf(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return subject, strings.Fields(claims.Scope), nil
+}
+
+func subjectOf(claims jwt.Claims) (string, error) {
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return "", err
+	}
+	if subject == "" {
+		return "", errors.New("token has no subject")
+	}
+	return subject, nil
+}
+
+// isLocal выпущен ли токен самой IDM; подпись здесь не проверяется, только выбирается способ проверки
+func (a *Authenticator) isLocal(token string) bool {
+	if a.local == nil {
+		return false
+	}
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+	return claims.Issuer == a.local.issuer
+}
+
+// AcceptApiKeys принимать наряду с токенами ключи доступа
+func (a *Authenticator) AcceptApiKeys(verifier ApiKeyVerifier) {
+	a.apiKeys = verifier
+}
+
+// Middleware пропускает запрос дальше только с действительным токеном или ключом доступа; субъект
+// попадает в контекст запроса как автор изменений (common.WithActor), а разрешения ключа или токена IDM - как
+// ограничение запроса (common.WithScopes)
+func (a *Authenticator) Middleware(c *fiber.Ctx) error {
+	scheme, credentials := authorization(c.Get(fiber.HeaderAuthorization))
+	switch {
+	case strings.EqualFold(scheme, schemeBearer):
+		return a.bearer(c, credentials)
+	case strings.EqualFold(scheme, schemeApiKey) && a.apiKeys != nil:
+		return a.apiKey(c, credentials)
+	}
+	a.logger.Debug("authentication: missing credentials", zap.String("path", c.Path()))
+	c.Set(fiber.HeaderWWWAuthenticate, a.challenge())
+	return common.ErrResponse(c, fiber.StatusUnauthorized, "missing credentials")
+}
+
+func (a *Authenticator) bearer(c *fiber.Ctx, token string) error {
+	if a.isLocal(token) {
+		return a.localBearer(c, token)
+	}
+	subject, err := a.Verify(token)
+	if err != nil {
+		a.logger.Warn("authentication: invalid token",
+			zap.String("path", c.Path()),
+			zap.String("request_id", common.RequestIdFrom(c.UserContext())),
+			zap.Error(err))
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return common.ErrResponse(c, fiber.StatusUnauthorized, "invalid bearer token")
+	}
+	a.logger.Debug("authentication: success", zap.String("actor", subject), zap.String("path", c.Path()))
+	c.SetUserContext(common.WithActor(c.UserContext(), subject))
+	return c.Next()
+}
+
+func (a *Authenticator) localBearer(c *fiber.Ctx, token string) error {
+	subject, scopes, err := a.verifyLocal(token)
+	if err != nil {
+		a.logger.Warn("authentication: invalid local token",
+			zap.String("path", c.Path()),
+			zap.String("request_id", common.RequestIdFrom(c.UserContext())),
+			zap.Error(err))
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return common.ErrResponse(c, fiber.StatusUnauthorized, "invalid bearer token")
+	}
+	a.logger.Debug("authentication: success with local token", zap.String("actor", subject), zap.String("path", c.Path()))
+	ctx := common.WithActor(c.UserContext(), subject)
+	c.SetUserContext(common.WithScopes(ctx, scopes))
+	return c.Next()
+}
+
+func (a *Authenticator) apiKey(c *fiber.Ctx, key string) error {
+	subject, scopes, err := a.apiKeys.VerifyApiKey(key)
+	if err != nil {
+		a.logger.Warn("authentication: invalid api key",
+			zap.String("path", c.Path()),
+			zap.String("request_id", common.RequestIdFrom(c.UserContext())),
+			zap.Error(err))
+		c.Set(fiber.HeaderWWWAuthenticate, schemeApiKey)
+		return common.ErrResponse(c, fiber.StatusUnauthorized, "invalid api key")
+	}
+	a.logger.Debug("authentication: success with api key", zap.String("actor", subject), zap.String("path", c.Path()))
+	ctx := common.WithActor(c.UserContext(), subject)
+	c.SetUserContext(common.WithScopes(ctx, scopes))
+	return c.Next()
+}
+
+// challenge схемы, которые принимает сервер, для заголовка WWW-Authenticate
+func (a *Authenticator) challenge() string {
+	if a.apiKeys != nil {
+		return schemeBearer + ", " + schemeApiKey
+	}
+	return schemeBearer
+}
+
+// authorization разобрать значение заголовка Authorization на схему и учётные данные;
+// без учётных данных схема не возвращается
+func authorization(header string) (scheme, credentials string) {
+	scheme, credentials, found := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+	if !found || credentials == "" {
+		return "", ""
+	}
+	return scheme, credentials
+}
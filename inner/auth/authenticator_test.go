@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	issuer   = "https://issuer.example.com"
+	audience = "idm"
+)
+
+func rsaJwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJwk(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeJwks(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": issuer,
+		"aud": audience,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+type MockApiKeyVerifier struct {
+	mock.Mock
+}
+
+func (m *MockApiKeyVerifier) VerifyApiKey(key string) (string, []string, error) {
+	args := m.Called(key)
+	return args.String(0), args.Get(1).([]string), args.Error(2)
+}
+
+// newApp приложение с защищённым маршрутом, который возвращает автора запроса из контекста
+func newApp(authenticator *Authenticator) *fiber.App {
+	app := fiber.New()
+	app.Use(authenticator.Middleware)
+	app.Get("/whoami", func(c *fiber.Ctx) error {
+		return c.SendString(common.ActorFrom(c.UserContext()))
+	})
+	return app
+}
+
+func call(t *testing.T, app *fiber.App, authorization string) *http.Response {
+	req := httptest.NewRequest(fiber.MethodGet, "/whoami", nil)
+	if authorization != "" {
+		req.Header.Set(fiber.HeaderAuthorization, authorization)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAuthenticatorMiddleware(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	path := writeJwks(t, jwks(t, rsaJwk("rsa-1", &rsaKey.PublicKey), ecJwk("ec-1", &ecKey.PublicKey)))
+
+	authenticator, err := NewAuthenticator(common.Config{
+		AuthJwksFile:    path,
+		AuthJwksRefresh: time.Hour,
+		AuthIssuer:      issuer,
+		AuthAudience:    audience,
+	}, logger)
+	a.NoError(err)
+	app := newApp(authenticator)
+
+	t.Run("should put subject of RS256 token into context", func(t *testing.T) {
+		resp := call(t, app, "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()))
+		a.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		a.Equal("alice", string(body))
+	})
+
+	t.Run("should accept ES256 token", func(t *testing.T) {
+		resp := call(t, app, "Bearer "+sign(t, jwt.SigningMethodES256, "ec-1", ecKey, validClaims()))
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should reject request without token", func(t *testing.T) {
+		resp := call(t, app, "")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal("Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+		resp = call(t, app, "Basic YWxpY2U6c2VjcmV0")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		expired := validClaims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		noExpiry := validClaims()
+		delete(noExpiry, "exp")
+		wrongIssuer := validClaims()
+		wrongIssuer["iss"] = "https://other.example.com"
+		wrongAudience := validClaims()
+		wrongAudience["aud"] = "other"
+		noSubject := validClaims()
+		delete(noSubject, "sub")
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		a.NoError(err)
+
+		tokens := map[string]string{
+			"expired":        sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, expired),
+			"without exp":    sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, noExpiry),
+			"wrong issuer":   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongIssuer),
+			"wrong audience": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongAudience),
+			"without sub":    sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, noSubject),
+			"foreign key":    sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims()),
+			"unknown kid":    sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims()),
+			"key of ec type": sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey, validClaims()),
+			"hmac":           sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), validClaims()),
+			"garbage":        "not.a.token",
+		}
+		for name, token := range tokens {
+			resp := call(t, app, "Bearer "+token)
+			a.Equal(http.StatusUnauthorized, resp.StatusCode, name)
+			a.Equal(`Bearer error="invalid_token"`, resp.Header.Get(fiber.HeaderWWWAuthenticate), name)
+		}
+	})
+}
+
+func TestAuthenticatorApiKeys(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	keys, err := NewFileKeySet(writeJwks(t, jwks(t, rsaJwk("rsa-1", &rsaKey.PublicKey))), time.Hour)
+	a.NoError(err)
+
+	// newScopedApp маршрут возвращает автора запроса и разрешения ключа
+	newScopedApp := func(verifier ApiKeyVerifier) *fiber.App {
+		authenticator := NewAuthenticatorWithKeys(keys, issuer, audience, logger)
+		authenticator.AcceptApiKeys(verifier)
+		app := fiber.New()
+		app.Use(authenticator.Middleware)
+		app.Get("/whoami", func(c *fiber.Ctx) error {
+			scopes, ok := common.ScopesFrom(c.UserContext())
+			if !ok {
+				return c.SendString(common.ActorFrom(c.UserContext()))
+			}
+			return c.SendString(common.ActorFrom(c.UserContext()) + " " + strings.Join(scopes, ","))
+		})
+		return app
+	}
+
+	t.Run("should put service account and scopes of key into context", func(t *testing.T) {
+		verifier := new(MockApiKeyVerifier)
+		verifier.On("VerifyApiKey", "idm_0011.secret").Return("service:hr-sync", []string{"employees:read"}, nil)
+
+		resp := call(t, newScopedApp(verifier), "ApiKey idm_0011.secret")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		a.Equal("service:hr-sync employees:read", string(body))
+	})
+
+	t.Run("should keep accepting bearer tokens without scopes", func(t *testing.T) {
+		verifier := new(MockApiKeyVerifier)
+
+		resp := call(t, newScopedApp(verifier), "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()))
+		a.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		a.Equal("alice", string(body))
+		a.True(verifier.AssertNotCalled(t, "VerifyApiKey", mock.Anything))
+	})
+
+	t.Run("should reject invalid key", func(t *testing.T) {
+		verifier := new(MockApiKeyVerifier)
+		verifier.On("VerifyApiKey", "idm_0011.guess").Return("", []string(nil), errors.New("wrong secret"))
+
+		resp := call(t, newScopedApp(verifier), "ApiKey idm_0011.guess")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal("ApiKey", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	})
+
+	t.Run("should offer both schemes to request without credentials", func(t *testing.T) {
+		resp := call(t, newScopedApp(new(MockApiKeyVerifier)), "")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal("Bearer, ApiKey", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	})
+
+	t.Run("should ignore api keys unless enabled", func(t *testing.T) {
+		resp := call(t, newApp(NewAuthenticatorWithKeys(keys, issuer, audience, logger)), "ApiKey idm_0011.secret")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal("Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	})
+}
+
+func TestAuthenticatorLocalTokens(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+	const localIssuer = "https://idm.example.com"
+
+	externalKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	external, err := NewFileKeySet(writeJwks(t, jwks(t, rsaJwk("rsa-1", &externalKey.PublicKey))), time.Hour)
+	a.NoError(err)
+	localKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	local, err := NewFileKeySet(writeJwks(t, jwks(t, ecJwk("idm-1", &localKey.PublicKey))), time.Hour)
+	a.NoError(err)
+
+	authenticator := NewAuthenticatorWithKeys(external, issuer, audience, logger)
+	a.NoError(authenticator.AcceptLocalTokens(local, localIssuer, audience))
+	app := fiber.New()
+	app.Use(authenticator.Middleware)
+	app.Get("/whoami", func(c *fiber.Ctx) error {
+		scopes, ok := common.ScopesFrom(c.UserContext())
+		if !ok {
+			return c.SendString(common.ActorFrom(c.UserContext()))
+		}
+		return c.SendString(common.ActorFrom(c.UserContext()) + " " + strings.Join(scopes, ","))
+	})
+
+	localClaims := func() jwt.MapClaims {
+		claims := validClaims()
+		claims["iss"] = localIssuer
+		claims["sub"] = "client:reports"
+		claims["scope"] = "employees:read roles:read"
+		return claims
+	}
+
+	t.Run("should limit local token to its scopes", func(t *testing.T) {
+		resp := call(t, app, "Bearer "+sign(t, jwt.SigningMethodES256, "idm-1", localKey, localClaims()))
+		a.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		a.Equal("client:reports employees:read,roles:read", string(body))
+	})
+
+	t.Run("should keep accepting external tokens without scopes", func(t *testing.T) {
+		resp := call(t, app, "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", externalKey, validClaims()))
+		a.Equal(http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err)
+		a.Equal("alice", string(body))
+	})
+
+	t.Run("should not accept local issuer signed by external key", func(t *testing.T) {
+		resp := call(t, app, "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", externalKey, localClaims()))
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+		expired := localClaims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		resp = call(t, app, "Bearer "+sign(t, jwt.SigningMethodES256, "idm-1", localKey, expired))
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should require distinct local issuer", func(t *testing.T) {
+		a.Error(NewAuthenticatorWithKeys(external, issuer, audience, logger).AcceptLocalTokens(local, issuer, audience))
+		a.Error(NewAuthenticatorWithKeys(external, issuer, audience, logger).AcceptLocalTokens(local, "", audience))
+	})
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+
+	t.Run("should require jwks source, issuer and audience", func(t *testing.T) {
+		_, err := NewAuthenticator(common.Config{AuthIssuer: issuer, AuthAudience: audience}, logger)
+		a.ErrorContains(err, "AUTH_JWKS_FILE")
+
+		_, err = NewAuthenticator(common.Config{AuthJwksFile: "jwks.json", AuthAudience: audience}, logger)
+		a.ErrorContains(err, "AUTH_ISSUER")
+	})
+
+	t.Run("should fail on unreadable jwks", func(t *testing.T) {
+		_, err := NewAuthenticator(common.Config{
+			AuthJwksFile: filepath.Join(t.TempDir(), "missing.json"),
+			AuthIssuer:   issuer,
+			AuthAudience: audience,
+		}, logger)
+		a.Error(err)
+	})
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+)
+
+// PermissionChecker источник разрешений автора запроса: разрешения ключа или токена, администраторы
+// из конфигурации и роли сотрудников в IDM. Те же правила действуют при выдаче разрешений ключам и клиентам.
+type PermissionChecker interface {
+	Holds(ctx context.Context, permission string) (bool, error)
+}
+
+// Authorizer проверяет, что у автора запроса есть разрешение, которое требует маршрут
+type Authorizer struct {
+	checker PermissionChecker
+	logger  *common.Logger
+}
+
+func NewAuthorizer(checker PermissionChecker, logger *common.Logger) *Authorizer {
+	return &Authorizer{checker: checker, logger: logger}
+}
+
+// Require обработчик, который пропускает запрос дальше, только если у автора есть разрешение permission.
+// Должен стоять после Authenticator.Middleware, который кладёт автора в контекст.
+func (a *Authorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actor := common.ActorFrom(c.UserContext())
+		if actor == "" {
+			return common.ErrResponse(c, fiber.StatusUnauthorized, "request is not authenticated")
+		}
+		allowed, err := a.checker.Holds(c.UserContext(), permission)
+		if err != nil {
+			a.logger.Error("authorization: permission check failed",
+				zap.String("actor", actor), zap.String("permission", permission), zap.Error(err))
+			return common.ErrResponse(c, fiber.StatusInternalServerError, "error checking permissions")
+		}
+		if !allowed {
+			a.logger.Warn("authorization: permission denied",
+				zap.String("actor", actor), zap.String("permission", permission), zap.String("path", c.Path()))
+			return common.ErrResponse(c, fiber.StatusForbidden, fmt.Sprintf("missing permission %s", permission))
+		}
+		return c.Next()
+	}
+}
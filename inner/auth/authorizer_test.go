@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockChecker struct {
+	mock.Mock
+}
+
+// Holds ожидания задаются по автору запроса из контекста
+func (m *MockChecker) Holds(ctx context.Context, permission string) (bool, error) {
+	args := m.Called(common.ActorFrom(ctx), permission)
+	return args.Bool(0), args.Error(1)
+}
+
+// newProtectedApp приложение, где маршрут требует employees:delete, а автор запроса и разрешения
+// ключа доступа берутся из заголовков
+func newProtectedApp(authorizer *Authorizer) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if actor := c.Get("X-Actor"); actor != "" {
+			c.SetUserContext(common.WithActor(c.UserContext(), actor))
+		}
+		if scopes := c.Get("X-Scopes"); scopes != "" {
+			c.SetUserContext(common.WithScopes(c.UserContext(), strings.Split(scopes, ",")))
+		}
+		return c.Next()
+	})
+	app.Delete("/employees/:id", authorizer.Require("employees:delete"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func deleteAs(t *testing.T, app *fiber.App, actor string) *http.Response {
+	req := httptest.NewRequest(fiber.MethodDelete, "/employees/1", nil)
+	if actor != "" {
+		req.Header.Set("X-Actor", actor)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAuthorizerRequire(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+
+	t.Run("should pass caller with permission", func(t *testing.T) {
+		checker := new(MockChecker)
+		checker.On("Holds", "alice", "employees:delete").Return(true, nil)
+
+		resp := deleteAs(t, newProtectedApp(NewAuthorizer(checker, logger)), "alice")
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return forbidden with missing permission", func(t *testing.T) {
+		checker := new(MockChecker)
+		checker.On("Holds", "bob", "employees:delete").Return(false, nil)
+
+		resp := deleteAs(t, newProtectedApp(NewAuthorizer(checker, logger)), "bob")
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[any]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal("missing permission employees:delete", responseBody.Message)
+	})
+
+	t.Run("should reject unauthenticated request", func(t *testing.T) {
+		checker := new(MockChecker)
+
+		resp := deleteAs(t, newProtectedApp(NewAuthorizer(checker, logger)), "")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should return internal server error if check fails", func(t *testing.T) {
+		checker := new(MockChecker)
+		checker.On("Holds", "alice", "employees:delete").Return(false, errors.New("database error"))
+
+		resp := deleteAs(t, newProtectedApp(NewAuthorizer(checker, logger)), "alice")
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval не чаще этого перечитываем JWKS из-за неизвестного kid,
+// чтобы токены с выдуманным kid не превращались в поток запросов к издателю
+const minRefreshInterval = 10 * time.Second
+
+// jwk открытый ключ в формате RFC 7517; поддерживаются RSA и EC P-256
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// ParseKeySet разобрать JWKS и получить ключи подписи по kid. Ключи шифрования
+// и ключи неподдерживаемых типов пропускаются.
+func ParseKeySet(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			key, err = k.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no supported signing keys")
+	}
+	return keys, nil
+}
+
+func (k *jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("malformed rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func (k *jwk) ecKey() (*ecdsa.PublicKey, error) {
+	if k.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("malformed ec key")
+	}
+	// ecdh проверяет, что точка лежит на кривой
+	if _, err = ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// KeySet ключи проверки подписи из JWKS. Набор перечитывается раз в refresh,
+// а также когда приходит токен с неизвестным kid: так подхватывается ротация ключей.
+type KeySet struct {
+	load     func() ([]byte, error)
+	refresh  time.Duration
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewFileKeySet набор ключей из файла JWKS
+func NewFileKeySet(path string, refresh time.Duration) (*KeySet, error) {
+	return newKeySet(func() ([]byte, error) { return os.ReadFile(path) }, refresh)
+}
+
+// NewUrlKeySet набор ключей, который загружается по адресу JWKS издателя
+func NewUrlKeySet(url string, refresh time.Duration) (*KeySet, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return newKeySet(func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching jwks from %s: unexpected status %d", url, resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}, refresh)
+}
+
+func newKeySet(load func() ([]byte, error), refresh time.Duration) (*KeySet, error) {
+	set := &KeySet{load: load, refresh: refresh}
+	if err := set.reload(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Key ключ с идентификатором kid. Пустой kid допускается, только если ключ в наборе один.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	age := time.Since(s.loadedAt)
+	if age > s.refresh || (s.find(kid) == nil && age > minRefreshInterval) {
+		// при недоступном источнике продолжаем работать с прежними ключами
+		_ = s.reload()
+	}
+	if key := s.find(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (s *KeySet) find(kid string) crypto.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+// reload перечитать набор ключей; вызывается под s.mu либо до публикации набора
+func (s *KeySet) reload() error {
+	data, err := s.load()
+	if err == nil {
+		var keys map[string]crypto.PublicKey
+		if keys, err = ParseKeySet(data); err == nil {
+			s.keys = keys
+		}
+	}
+	// время фиксируем и при ошибке, чтобы не повторять загрузку на каждом запросе
+	s.loadedAt = time.Now()
+	return err
+}
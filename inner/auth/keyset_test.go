@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseKeySet(t *testing.T) {
+	a := assert.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+
+	t.Run("should skip encryption and unsupported keys", func(t *testing.T) {
+		encryption := rsaJwk("enc", &rsaKey.PublicKey)
+		encryption["use"] = "enc"
+		symmetric := map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}
+
+		keys, err := ParseKeySet(jwks(t, rsaJwk("rsa-1", &rsaKey.PublicKey), ecJwk("ec-1", &ecKey.PublicKey), encryption, symmetric))
+		a.NoError(err)
+		a.Len(keys, 2)
+		a.Equal(&rsaKey.PublicKey, keys["rsa-1"])
+		a.True(ecKey.PublicKey.Equal(keys["ec-1"]))
+	})
+
+	t.Run("should reject point not on curve", func(t *testing.T) {
+		broken := ecJwk("ec-1", &ecKey.PublicKey)
+		broken["y"] = broken["x"]
+
+		_, err := ParseKeySet(jwks(t, broken))
+		a.ErrorContains(err, "ec-1")
+	})
+
+	t.Run("should reject set without signing keys", func(t *testing.T) {
+		_, err := ParseKeySet([]byte(`{"keys":[]}`))
+		a.Error(err)
+		_, err = ParseKeySet([]byte(`not json`))
+		a.Error(err)
+	})
+}
+
+func TestUrlKeySet(t *testing.T) {
+	a := assert.New(t)
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+
+	t.Run("should reload keys on unknown kid", func(t *testing.T) {
+		var body atomic.Value
+		body.Store(jwks(t, rsaJwk("k1", &first.PublicKey)))
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			_, _ = w.Write(body.Load().([]byte))
+		}))
+		defer server.Close()
+
+		set, err := NewUrlKeySet(server.URL, time.Hour)
+		a.NoError(err)
+		key, err := set.Key("")
+		a.NoError(err)
+		a.Equal(&first.PublicKey, key)
+
+		// издатель добавил ключ; прежняя загрузка ещё свежая, поэтому имитируем, что она была давно
+		body.Store(jwks(t, rsaJwk("k1", &first.PublicKey), rsaJwk("k2", &second.PublicKey)))
+		set.loadedAt = time.Now().Add(-time.Minute)
+		key, err = set.Key("k2")
+		a.NoError(err)
+		a.Equal(&second.PublicKey, key)
+		a.Equal(int32(2), requests.Load())
+
+		// неизвестный kid сразу после загрузки не приводит к новому запросу
+		_, err = set.Key("k3")
+		a.Error(err)
+		a.Equal(int32(2), requests.Load())
+	})
+
+	t.Run("should keep previous keys if issuer is unavailable", func(t *testing.T) {
+		var healthy atomic.Bool
+		healthy.Store(true)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write(jwks(t, rsaJwk("k1", &first.PublicKey)))
+		}))
+		defer server.Close()
+
+		set, err := NewUrlKeySet(server.URL, time.Minute)
+		a.NoError(err)
+		healthy.Store(false)
+		set.loadedAt = time.Now().Add(-time.Hour)
+
+		key, err := set.Key("k1")
+		a.NoError(err)
+		a.Equal(&first.PublicKey, key)
+	})
+
+	t.Run("should fail on startup if issuer is unavailable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		_, err := NewUrlKeySet(server.URL, time.Minute)
+		a.ErrorContains(err, "404")
+	})
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRsaBits ключи RSA короче этого не принимаются
+const minRsaBits = 2048
+
+// signingKey закрытый ключ, которым IDM подписывает свои токены; kid - имя файла без расширения
+type signingKey struct {
+	kid    string
+	key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// SigningKeys ключи подписи токенов IDM из каталога с PEM-файлами (*.pem, RSA от 2048 бит или EC P-256).
+// Подписывает ключ, имя файла которого последнее по алфавиту; проверяются и публикуются в JWKS все ключи.
+// Каталог перечитывается раз в refresh, поэтому ротация - это новый файл с более поздним именем,
+// а старый файл удаляют, когда истекут подписанные им токены.
+type SigningKeys struct {
+	dir      string
+	refresh  time.Duration
+	mu       sync.Mutex
+	keys     []signingKey
+	loadedAt time.Time
+}
+
+// NewSigningKeys загрузить ключи подписи; каталог без ключей считается ошибкой конфигурации
+func NewSigningKeys(dir string, refresh time.Duration) (*SigningKeys, error) {
+	s := &SigningKeys{dir: dir, refresh: refresh}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sign подписать claims действующим ключом
+func (s *SigningKeys) Sign(claims jwt.Claims) (string, error) {
+	s.mu.Lock()
+	s.reloadIfStale(false)
+	active := s.keys[len(s.keys)-1]
+	s.mu.Unlock()
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.key)
+}
+
+// Key открытый ключ с идентификатором kid для проверки подписи
+func (s *SigningKeys) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadIfStale(s.find(kid) == nil)
+	if k := s.find(kid); k != nil {
+		return k.key.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Jwks открытые ключи всех загруженных ключей подписи в формате JWKS
+func (s *SigningKeys) Jwks() ([]byte, error) {
+	s.mu.Lock()
+	s.reloadIfStale(false)
+	keys := s.keys
+	s.mu.Unlock()
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: make([]jwk, 0, len(keys))}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, publicJwk(k))
+	}
+	return json.Marshal(set)
+}
+
+func (s *SigningKeys) find(kid string) *signingKey {
+	for i := range s.keys {
+		if s.keys[i].kid == kid {
+			return &s.keys[i]
+		}
+	}
+	return nil
+}
+
+// reloadIfStale перечитать каталог, если загрузка устарела или ищется неизвестный ключ; вызывается под s.mu
+func (s *SigningKeys) reloadIfStale(unknownKid bool) {
+	age := time.Since(s.loadedAt)
+	if age > s.refresh || (unknownKid && age > minRefreshInterval) {
+		// если каталог испорчен, продолжаем подписывать прежними ключами
+		_ = s.reload()
+	}
+}
+
+func (s *SigningKeys) reload() error {
+	keys, err := loadSigningKeys(s.dir)
+	if err == nil {
+		s.keys = keys
+	}
+	s.loadedAt = time.Now()
+	return err
+}
+
+// loadSigningKeys прочитать все *.pem из каталога, упорядочив по имени файла
+func loadSigningKeys(dir string) ([]signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	keys := make([]signingKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, method, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		keys = append(keys, signingKey{kid: kid, key: key, method: method})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys (*.pem) in %s", dir)
+	}
+	return keys, nil
+}
+
+// parsePrivateKey разобрать закрытый ключ PEM в форматах PKCS#8, PKCS#1 или SEC 1
+func parsePrivateKey(data []byte) (crypto.Signer, jwt.SigningMethod, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no pem block")
+	}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported pem block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRsaBits {
+			return nil, nil, fmt.Errorf("rsa key is shorter than %d bits", minRsaBits)
+		}
+		return key, jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, nil, errors.New("only P-256 ec keys are supported")
+		}
+		return key, jwt.SigningMethodES256, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// publicJwk открытая часть ключа подписи в формате RFC 7517
+func publicJwk(k signingKey) jwk {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch public := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{
+			Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+			N: encode(public.N.Bytes()),
+			E: encode(big.NewInt(int64(public.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return jwk{
+			Kty: "EC", Kid: k.kid, Use: "sig", Alg: k.method.Alg(), Crv: "P-256",
+			X: encode(public.X.FillBytes(make([]byte, 32))),
+			Y: encode(public.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return jwk{Kid: k.kid}
+}
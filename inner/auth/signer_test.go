@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePem(t *testing.T, dir, name, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	a := assert.New(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	rsaDer, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	a.NoError(err)
+	ecDer, err := x509.MarshalECPrivateKey(ecKey)
+	a.NoError(err)
+
+	t.Run("should sign with last key and publish all keys", func(t *testing.T) {
+		dir := t.TempDir()
+		writePem(t, dir, "2025-01.pem", "PRIVATE KEY", rsaDer)
+		writePem(t, dir, "2025-02.pem", "EC PRIVATE KEY", ecDer)
+		writePem(t, dir, "readme.txt", "NOTE", []byte("ignored"))
+
+		keys, err := NewSigningKeys(dir, time.Hour)
+		a.NoError(err)
+		signed, err := keys.Sign(jwt.MapClaims{"sub": "client:reports"})
+		a.NoError(err)
+
+		parsed, err := jwt.Parse(signed, func(t *jwt.Token) (any, error) {
+			a.Equal("2025-02", t.Header["kid"])
+			return keys.Key(t.Header["kid"].(string))
+		}, jwt.WithValidMethods([]string{"ES256"}))
+		a.NoError(err)
+		a.True(parsed.Valid)
+
+		data, err := keys.Jwks()
+		a.NoError(err)
+		published, err := ParseKeySet(data)
+		a.NoError(err)
+		a.Len(published, 2)
+		a.Equal(&rsaKey.PublicKey, published["2025-01"])
+		a.True(ecKey.PublicKey.Equal(published["2025-02"]))
+		a.NotContains(string(data), `"d"`)
+	})
+
+	t.Run("should pick up rotated key after refresh", func(t *testing.T) {
+		dir := t.TempDir()
+		writePem(t, dir, "2025-01.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+		keys, err := NewSigningKeys(dir, time.Minute)
+		a.NoError(err)
+		writePem(t, dir, "2025-02.pem", "EC PRIVATE KEY", ecDer)
+		keys.loadedAt = time.Now().Add(-time.Hour)
+
+		signed, err := keys.Sign(jwt.MapClaims{"sub": "client:reports"})
+		a.NoError(err)
+		token, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+		a.NoError(err)
+		a.Equal("2025-02", token.Header["kid"])
+
+		// старым ключом подписанные токены по-прежнему проверяются
+		_, err = keys.Key("2025-01")
+		a.NoError(err)
+	})
+
+	t.Run("should reject weak and missing keys", func(t *testing.T) {
+		_, err := NewSigningKeys(t.TempDir(), time.Hour)
+		a.ErrorContains(err, "no signing keys")
+
+		weak, err := rsa.GenerateKey(rand.Reader, 1024)
+		a.NoError(err)
+		dir := t.TempDir()
+		writePem(t, dir, "weak.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak))
+		_, err = NewSigningKeys(dir, time.Hour)
+		a.ErrorContains(err, "2048")
+
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		a.NoError(err)
+		p384Der, err := x509.MarshalECPrivateKey(p384)
+		a.NoError(err)
+		dir = t.TempDir()
+		writePem(t, dir, "p384.pem", "EC PRIVATE KEY", p384Der)
+		_, err = NewSigningKeys(dir, time.Hour)
+		a.ErrorContains(err, "P-256")
+	})
+}
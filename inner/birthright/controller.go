@@ -0,0 +1,203 @@
+package birthright
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты правил назначения
+const (
+	permissionRead   = "assignment_rules:read"
+	permissionManage = "assignment_rules:manage"
+)
+
+type Controller struct {
+	server      *web.Server
+	ruleService Svc
+	logger      *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Enable(ctx context.Context, request IdRequest) (Response, error)
+	Disable(ctx context.Context, request IdRequest) (Response, error)
+	Delete(ctx context.Context, request IdRequest) error
+	Preview(request IdRequest) (PreviewResponse, error)
+}
+
+func NewController(server *web.Server, ruleService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:      server,
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/assignment-rules", c.server.Require(permissionManage), c.CreateRule)
+	c.server.GroupApiV1.Get("/assignment-rules", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/assignment-rules/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Put("/assignment-rules/:id", c.server.Require(permissionManage), c.UpdateRule)
+	c.server.GroupApiV1.Delete("/assignment-rules/:id", c.server.Require(permissionManage), c.DeleteRule)
+	c.server.GroupApiV1.Post("/assignment-rules/:id/enable", c.server.Require(permissionManage), c.EnableRule)
+	c.server.GroupApiV1.Post("/assignment-rules/:id/disable", c.server.Require(permissionManage), c.DisableRule)
+	c.server.GroupApiV1.Get("/assignment-rules/:id/preview", c.server.Require(permissionRead), c.Preview)
+}
+
+func (c *Controller) CreateRule(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create assignment rule: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create assignment rule: received request", zap.Any("request", request))
+	response, err := c.ruleService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create assignment rule: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create assignment rule: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find assignment rule by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find assignment rule by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.ruleService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find assignment rule by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find assignment rule by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all assignment rules: received request")
+	responses, err := c.ruleService.FindAll()
+	if err != nil {
+		c.logger.Error("find all assignment rules: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all assignment rules: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) UpdateRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update assignment rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update assignment rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update assignment rule: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.ruleService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update assignment rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update assignment rule: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DeleteRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete assignment rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete assignment rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.ruleService.Delete(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete assignment rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete assignment rule: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) EnableRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("enable assignment rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("enable assignment rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.ruleService.Enable(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("enable assignment rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("enable assignment rule: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DisableRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("disable assignment rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("disable assignment rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.ruleService.Disable(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("disable assignment rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("disable assignment rule: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+// Preview сотрудники, которых затронет включение правила
+func (c *Controller) Preview(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("preview assignment rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("preview assignment rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.ruleService.Preview(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("preview assignment rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("preview assignment rule: success", zap.Int64("id", id),
+		zap.Int("grant", len(response.Grant)), zap.Int("revoke", len(response.Revoke)))
+	return common.OkResponse(ctx, response)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.SodConflictError{}):
+		return fiber.StatusConflict
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
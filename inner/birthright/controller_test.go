@@ -0,0 +1,195 @@
+package birthright
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Enable(ctx context.Context, request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Disable(ctx context.Context, request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Delete(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) Preview(request IdRequest) (PreviewResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(PreviewResponse), args.Error(1)
+}
+
+func TestControllerCreateRule(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return created rule", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		conditions := []Condition{{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}}}
+		svc.On("Create", CreateRequest{Name: "Finance readers", RoleId: 2, Conditions: conditions}).
+			Return(Response{Id: 1, Name: "Finance readers", RoleId: 2, Conditions: conditions}, nil)
+
+		body := strings.NewReader(`{"name":"Finance readers","role_id":2,` +
+			`"conditions":[{"attribute":"org_unit_id","operator":"equals","values":["3"]}]}`)
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/assignment-rules", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(1), responseBody.Data.Id)
+		a.False(responseBody.Data.Enabled)
+	})
+}
+
+func TestControllerEnableRule(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return conflict when grant violates sod rule", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Enable", IdRequest{Id: 1}).Return(Response{}, common.SodConflictError{Message: "conflict"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/assignment-rules/1/enable", nil))
+		a.Nil(err)
+		a.Equal(http.StatusConflict, resp.StatusCode)
+	})
+}
+
+func TestControllerDeleteRule(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Delete", IdRequest{Id: 1}).Return(common.NotFoundError{Message: "assignment rule with id 1 not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, "/api/v1/assignment-rules/1", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerPreview(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return bad request for invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/assignment-rules/abc/preview", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Preview", mock.Anything))
+	})
+}
+
+// DenyAuthorizer отклоняет любой запрос и сообщает, какое разрешение потребовал маршрут
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	routes := []struct {
+		method     string
+		url        string
+		permission string
+	}{
+		{fiber.MethodPost, "/api/v1/assignment-rules", "assignment_rules:manage"},
+		{fiber.MethodGet, "/api/v1/assignment-rules", "assignment_rules:read"},
+		{fiber.MethodGet, "/api/v1/assignment-rules/1", "assignment_rules:read"},
+		{fiber.MethodPut, "/api/v1/assignment-rules/1", "assignment_rules:manage"},
+		{fiber.MethodDelete, "/api/v1/assignment-rules/1", "assignment_rules:manage"},
+		{fiber.MethodPost, "/api/v1/assignment-rules/1/enable", "assignment_rules:manage"},
+		{fiber.MethodPost, "/api/v1/assignment-rules/1/disable", "assignment_rules:manage"},
+		{fiber.MethodGet, "/api/v1/assignment-rules/1/preview", "assignment_rules:read"},
+	}
+
+	t.Run("should require permission on every route", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = DenyAuthorizer{}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, route := range routes {
+			resp, err := server.App.Test(httptest.NewRequest(route.method, route.url, nil))
+			a.Nil(err)
+			a.Equal(http.StatusForbidden, resp.StatusCode, route.url)
+
+			bytesData, err := io.ReadAll(resp.Body)
+			a.Nil(err)
+			var responseBody common.Response[any]
+			a.Nil(json.Unmarshal(bytesData, &responseBody))
+			a.Equal("missing permission "+route.permission, responseBody.Message, route.method+" "+route.url)
+		}
+		a.Empty(svc.Calls)
+	})
+}
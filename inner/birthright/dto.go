@@ -0,0 +1,211 @@
+package birthright
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"idm/inner/employee"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Операторы условий: equals и not_equals сравнивают с единственным значением, in и not_in - с любым из списка
+const (
+	OperatorEquals    = "equals"
+	OperatorNotEquals = "not_equals"
+	OperatorIn        = "in"
+	OperatorNotIn     = "not_in"
+)
+
+// Атрибуты сотрудника, на которые можно ссылаться в условиях; пользовательский атрибут указывается
+// как attributes.<ключ> из схемы пользовательских атрибутов
+const (
+	AttributeOrgUnit        = "org_unit_id"
+	AttributeManager        = "manager_id"
+	AttributeStatus         = "status"
+	AttributeTitle          = "title"
+	AttributeEmployeeNumber = "employee_number"
+	customAttributePrefix   = "attributes."
+)
+
+// Condition условие на атрибут сотрудника. Незаданный атрибут не равен ни одному значению
+type Condition struct {
+	Attribute string   `json:"attribute" validate:"required,max=100"`
+	Operator  string   `json:"operator" validate:"oneof=equals not_equals in not_in"`
+	Values    []string `json:"values" validate:"required,min=1,max=100,dive,max=255"`
+}
+
+// matches подходит ли сотрудник под условие
+func (c *Condition) matches(candidate employee.Entity) bool {
+	value, ok := valueOf(candidate, c.Attribute)
+	found := ok && slices.Contains(c.Values, value)
+	if c.Operator == OperatorNotEquals || c.Operator == OperatorNotIn {
+		return !found
+	}
+	return found
+}
+
+// valueOf значение атрибута сотрудника в том виде, в каком его записывают в условиях; false - атрибут не задан
+func valueOf(candidate employee.Entity, attribute string) (string, bool) {
+	switch attribute {
+	case AttributeOrgUnit:
+		return formatId(candidate.OrgUnitId)
+	case AttributeManager:
+		return formatId(candidate.ManagerId)
+	case AttributeStatus:
+		return candidate.Status, true
+	case AttributeTitle:
+		return formatString(candidate.Title)
+	case AttributeEmployeeNumber:
+		return formatString(candidate.EmployeeNumber)
+	}
+	key, custom := strings.CutPrefix(attribute, customAttributePrefix)
+	if !custom {
+		return "", false
+	}
+	switch value := candidate.Attributes[key].(type) {
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+func formatId(id *int64) (string, bool) {
+	if id == nil {
+		return "", false
+	}
+	return strconv.FormatInt(*id, 10), true
+}
+
+func formatString(value *string) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+	return *value, true
+}
+
+// Conditions условия правила; хранятся в колонке jsonb
+type Conditions []Condition
+
+func (c Conditions) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *Conditions) Scan(src any) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, c)
+	case string:
+		return json.Unmarshal([]byte(value), c)
+	case nil:
+		*c = nil
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into conditions", src)
+}
+
+// Entity правило назначения: сотрудник, подходящий под все условия включённого правила, получает роль RoleId
+type Entity struct {
+	Id          int64      `db:"id"`
+	Name        string     `db:"name"`
+	Description string     `db:"description"`
+	RoleId      int64      `db:"role_id"`
+	Conditions  Conditions `db:"conditions"`
+	Enabled     bool       `db:"enabled"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+// Matches подходит ли сотрудник под все условия правила; правило без условий подходит всем.
+// Уволенный или удалённый сотрудник не подходит ни под одно правило
+func (e *Entity) Matches(candidate employee.Entity) bool {
+	if candidate.Status == employee.StatusTerminated || candidate.DeletedAt != nil {
+		return false
+	}
+	for _, condition := range e.Conditions {
+		if !condition.matches(candidate) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Entity) toResponse() Response {
+	conditions := e.Conditions
+	if conditions == nil {
+		conditions = Conditions{}
+	}
+	return Response{
+		Id:          e.Id,
+		Name:        e.Name,
+		Description: e.Description,
+		RoleId:      e.RoleId,
+		Conditions:  conditions,
+		Enabled:     e.Enabled,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние правила в журнале аудита
+type auditSnapshot struct {
+	Id          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	RoleId      int64      `json:"role_id"`
+	Conditions  Conditions `json:"conditions"`
+	Enabled     bool       `json:"enabled"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{
+		Id:          e.Id,
+		Name:        e.Name,
+		Description: e.Description,
+		RoleId:      e.RoleId,
+		Conditions:  e.Conditions,
+		Enabled:     e.Enabled,
+	}
+}
+
+type Response struct {
+	Id          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	RoleId      int64      `json:"role_id"`
+	Conditions  Conditions `json:"conditions"`
+	Enabled     bool       `json:"enabled"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// EmployeeResponse сотрудник, которому правило выдаст или у которого отзовёт роль
+type EmployeeResponse struct {
+	Id    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Login *string `json:"login,omitempty"`
+}
+
+func toEmployeeResponses(entities []employee.Entity) []EmployeeResponse {
+	responses := make([]EmployeeResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, EmployeeResponse{Id: entity.Id, Name: entity.Name, Login: entity.Login})
+	}
+	return responses
+}
+
+// PreviewResponse что изменится, если включить правило или пересчитать включённое прямо сейчас
+type PreviewResponse struct {
+	RuleId int64              `json:"rule_id"`
+	Grant  []EmployeeResponse `json:"grant"`
+	Revoke []EmployeeResponse `json:"revoke"`
+}
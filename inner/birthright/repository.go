@@ -0,0 +1,121 @@
+package birthright
+
+import (
+	"github.com/jmoiron/sqlx"
+	"idm/inner/employee"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+// rulesLockKey ключ advisory-блокировки пересчёта назначений по правилам
+const rulesLockKey = 20251015
+
+// selectCandidates сотрудники, под которых могут подходить правила
+const selectCandidates = "select * from employee where deleted_at is null and status <> 'terminated' order by id"
+
+// selectHolders сотрудники с действующим или будущим назначением по правилу $1 в момент $2. Удалённые
+// тоже учитываются: иначе их назначения пережили бы правило и после восстановления стали бы ручными
+const selectHolders = `select e.* from employee e
+	where exists(select 1 from employee_role er
+		where er.employee_id = e.id and er.rule_id = $1 and (er.valid_to is null or er.valid_to > $2))
+	order by e.id`
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+// LockRulesTx сериализовать пересчёт назначений: изменение сотрудника и включение правила
+// не должны одновременно выдать одну и ту же роль
+func (r *Repository) LockRulesTx(tx *sqlx.Tx) error {
+	_, err := tx.Exec("select pg_advisory_xact_lock($1)", rulesLockKey)
+	return err
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into assignment_rule (name, description, role_id, conditions) values ($1, $2, $3, $4)
+		returning *`
+	err = tx.Get(&saved, query, e.Name, e.Description, e.RoleId, e.Conditions)
+	return saved, err
+}
+
+// ExistsTx есть ли, кроме правила exceptId, правило с тем же именем
+func (r *Repository) ExistsTx(tx *sqlx.Tx, name string, exceptId int64) (exists bool, err error) {
+	query := "select exists(select 1 from assignment_rule where name = $1 and id <> $2)"
+	err = tx.Get(&exists, query, name, exceptId)
+	return exists, err
+}
+
+func (r *Repository) FindById(id int64) (rule Entity, err error) {
+	err = r.db.Get(&rule, "select * from assignment_rule where id = $1", id)
+	return rule, err
+}
+
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (rule Entity, err error) {
+	err = tx.Get(&rule, "select * from assignment_rule where id = $1 for update", id)
+	return rule, err
+}
+
+func (r *Repository) FindAll() (rules []Entity, err error) {
+	err = r.db.Select(&rules, "select * from assignment_rule order by id")
+	return rules, err
+}
+
+func (r *Repository) FindEnabledTx(tx *sqlx.Tx) (rules []Entity, err error) {
+	err = tx.Select(&rules, "select * from assignment_rule where enabled order by id")
+	return rules, err
+}
+
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := `update assignment_rule set name = $2, description = $3, role_id = $4, conditions = $5
+		where id = $1 returning *`
+	err = tx.Get(&updated, query, e.Id, e.Name, e.Description, e.RoleId, e.Conditions)
+	return updated, err
+}
+
+func (r *Repository) SetEnabledTx(tx *sqlx.Tx, id int64, enabled bool) (updated Entity, err error) {
+	err = tx.Get(&updated, "update assignment_rule set enabled = $2 where id = $1 returning *", id, enabled)
+	return updated, err
+}
+
+func (r *Repository) DeleteTx(tx *sqlx.Tx, id int64) (deleted Entity, err error) {
+	err = tx.Get(&deleted, "delete from assignment_rule where id = $1 returning *", id)
+	return deleted, err
+}
+
+// FindCandidates неудалённые и неуволенные сотрудники
+func (r *Repository) FindCandidates() (employees []employee.Entity, err error) {
+	err = r.db.Select(&employees, selectCandidates)
+	return employees, err
+}
+
+func (r *Repository) FindCandidatesTx(tx *sqlx.Tx) (employees []employee.Entity, err error) {
+	err = tx.Select(&employees, selectCandidates)
+	return employees, err
+}
+
+// FindHolders сотрудники, которым правило ruleId выдало роль, действующую в момент at или позже
+func (r *Repository) FindHolders(ruleId int64, at time.Time) (employees []employee.Entity, err error) {
+	err = r.db.Select(&employees, selectHolders, ruleId, at)
+	return employees, err
+}
+
+func (r *Repository) FindHoldersTx(tx *sqlx.Tx, ruleId int64, at time.Time) (employees []employee.Entity, err error) {
+	err = tx.Select(&employees, selectHolders, ruleId, at)
+	return employees, err
+}
+
+// FindHeldRuleIdsTx правила, по которым у сотрудника есть назначение, действующее в момент at или позже
+func (r *Repository) FindHeldRuleIdsTx(tx *sqlx.Tx, employeeId int64, at time.Time) (ids []int64, err error) {
+	query := `select distinct rule_id from employee_role
+		where employee_id = $1 and rule_id is not null and (valid_to is null or valid_to > $2)
+		order by rule_id`
+	err = tx.Select(&ids, query, employeeId, at)
+	return ids, err
+}
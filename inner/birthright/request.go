@@ -0,0 +1,21 @@
+package birthright
+
+// CreateRequest завести правило; новое правило выключено, пока его не включат после предпросмотра
+type CreateRequest struct {
+	Name        string      `json:"name" validate:"required,min=2,max=155"`
+	Description string      `json:"description" validate:"max=2000"`
+	RoleId      int64       `json:"role_id" validate:"required,gt=0"`
+	Conditions  []Condition `json:"conditions" validate:"max=20,dive"`
+}
+
+type UpdateRequest struct {
+	Id          int64       `json:"id" validate:"required,gt=0"`
+	Name        string      `json:"name" validate:"required,min=2,max=155"`
+	Description string      `json:"description" validate:"max=2000"`
+	RoleId      int64       `json:"role_id" validate:"required,gt=0"`
+	Conditions  []Condition `json:"conditions" validate:"max=20,dive"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
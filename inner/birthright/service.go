@@ -0,0 +1,483 @@
+package birthright
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+	"strings"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "assignment_rule"
+
+// Действия журнала аудита при включении и выключении правила
+const (
+	auditActionEnable  = "enable"
+	auditActionDisable = "disable"
+)
+
+type Service struct {
+	repo          Repo
+	roleRepo      RoleRepo
+	attributeRepo AttributeRepo
+	granter       Granter
+	auditor       Auditor
+	validator     Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	LockRulesTx(tx *sqlx.Tx) error
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExistsTx(tx *sqlx.Tx, name string, exceptId int64) (bool, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	FindEnabledTx(tx *sqlx.Tx) ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	SetEnabledTx(tx *sqlx.Tx, id int64, enabled bool) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindCandidates() ([]employee.Entity, error)
+	FindCandidatesTx(tx *sqlx.Tx) ([]employee.Entity, error)
+	FindHolders(ruleId int64, at time.Time) ([]employee.Entity, error)
+	FindHoldersTx(tx *sqlx.Tx, ruleId int64, at time.Time) ([]employee.Entity, error)
+	FindHeldRuleIdsTx(tx *sqlx.Tx, employeeId int64, at time.Time) ([]int64, error)
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+}
+
+// AttributeRepo схема пользовательских атрибутов, на которые ссылаются условия
+type AttributeRepo interface {
+	FindAll() ([]attribute.Entity, error)
+}
+
+// Granter выдаёт и отзывает назначения, помеченные правилом, в транзакции вызывающего кода
+type Granter interface {
+	GrantByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, roleId, ruleId int64) (int64, error)
+	RevokeByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, ruleId int64) error
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(
+	repo Repo,
+	roleRepo RoleRepo,
+	attributeRepo AttributeRepo,
+	granter Granter,
+	auditor Auditor,
+	validator Validator,
+) *Service {
+	return &Service{
+		repo:          repo,
+		roleRepo:      roleRepo,
+		attributeRepo: attributeRepo,
+		granter:       granter,
+		auditor:       auditor,
+		validator:     validator,
+	}
+}
+
+// Create завести выключенное правило: назначения оно начнёт выдавать только после Enable
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity := Entity{
+		Name:        request.Name,
+		Description: request.Description,
+		RoleId:      request.RoleId,
+		Conditions:  request.Conditions,
+	}
+	if err = svc.checkRule(entity); err != nil {
+		return Response{}, err
+	}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating assignment rule", func(tx *sqlx.Tx) error {
+		if err := svc.checkUnique(tx, request.Name, 0); err != nil {
+			return err
+		}
+		saved, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving assignment rule %s: %w", request.Name, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding assignment rule with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all assignment rules: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// Update заменить правило. Включённое правило сразу пересчитывается: роль получают новые подходящие
+// сотрудники и теряют переставшие подходить; при смене роли прежняя роль отзывается у всех
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity := Entity{
+		Id:          request.Id,
+		Name:        request.Name,
+		Description: request.Description,
+		RoleId:      request.RoleId,
+		Conditions:  request.Conditions,
+	}
+	if err = svc.checkRule(entity); err != nil {
+		return Response{}, err
+	}
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating assignment rule", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		if err = svc.checkUnique(tx, request.Name, request.Id); err != nil {
+			return err
+		}
+		updated, err = svc.repo.UpdateTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error updating assignment rule with id %d: %w", request.Id, err)
+		}
+		if updated.Enabled {
+			if err = svc.reconcileTx(ctx, tx, updated, before.RoleId != updated.RoleId); err != nil {
+				return err
+			}
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// Enable включить правило и выдать роль всем подходящим сотрудникам. Если хотя бы одно назначение
+// нарушает правила разделения обязанностей, правило остаётся выключенным
+func (svc *Service) Enable(ctx context.Context, request IdRequest) (Response, error) {
+	return svc.setEnabled(ctx, request, true)
+}
+
+// Disable выключить правило и отозвать все выданные им назначения
+func (svc *Service) Disable(ctx context.Context, request IdRequest) (Response, error) {
+	return svc.setEnabled(ctx, request, false)
+}
+
+func (svc *Service) setEnabled(ctx context.Context, request IdRequest, enabled bool) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	action, operation := auditActionEnable, "enabling assignment rule"
+	if !enabled {
+		action, operation = auditActionDisable, "disabling assignment rule"
+	}
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, operation, func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		if before.Enabled == enabled {
+			updated = before
+			return nil
+		}
+		updated, err = svc.repo.SetEnabledTx(tx, request.Id, enabled)
+		if err != nil {
+			return fmt.Errorf("error %s with id %d: %w", operation, request.Id, err)
+		}
+		if enabled {
+			err = svc.reconcileTx(ctx, tx, updated, false)
+		} else {
+			err = svc.revokeHoldersTx(ctx, tx, updated.Id)
+		}
+		if err != nil {
+			return err
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     action,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// Delete удалить правило, предварительно отозвав выданные им назначения
+func (svc *Service) Delete(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting assignment rule", func(tx *sqlx.Tx) error {
+		if _, err := svc.lock(tx, request.Id); err != nil {
+			return err
+		}
+		if err := svc.revokeHoldersTx(ctx, tx, request.Id); err != nil {
+			return err
+		}
+		deleted, err := svc.repo.DeleteTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error deleting assignment rule with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted.Id,
+			Before:     deleted.auditSnapshot(),
+		})
+	})
+}
+
+// Preview сотрудники, которые получат роль и потеряют её, если включить правило или пересчитать
+// уже включённое. Ничего не меняет
+func (svc *Service) Preview(request IdRequest) (PreviewResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return PreviewResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	rule, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return PreviewResponse{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding assignment rule with id %d: %v", request.Id, err),
+		}
+	}
+	candidates, err := svc.repo.FindCandidates()
+	if err != nil {
+		return PreviewResponse{}, fmt.Errorf("error retrieving employees for assignment rule %d: %w", request.Id, err)
+	}
+	holders, err := svc.repo.FindHolders(request.Id, time.Now())
+	if err != nil {
+		return PreviewResponse{}, fmt.Errorf("error retrieving employees of assignment rule %d: %w", request.Id, err)
+	}
+	grant, revoke := plan(rule, candidates, holders)
+	return PreviewResponse{
+		RuleId: rule.Id,
+		Grant:  toEmployeeResponses(grant),
+		Revoke: toEmployeeResponses(revoke),
+	}, nil
+}
+
+// ApplyTx пересчитать назначения по включённым правилам для изменённого сотрудника в транзакции изменения:
+// подходящий сотрудник получает роль правила, переставший подходить - теряет её
+func (svc *Service) ApplyTx(ctx context.Context, tx *sqlx.Tx, candidate employee.Entity) error {
+	if err := svc.repo.LockRulesTx(tx); err != nil {
+		return fmt.Errorf("error locking assignment rules: %w", err)
+	}
+	rules, err := svc.repo.FindEnabledTx(tx)
+	if err != nil {
+		return fmt.Errorf("error retrieving enabled assignment rules: %w", err)
+	}
+	held, err := svc.repo.FindHeldRuleIdsTx(tx, candidate.Id, time.Now())
+	if err != nil {
+		return fmt.Errorf("error retrieving rule assignments of employee %d: %w", candidate.Id, err)
+	}
+	for _, rule := range rules {
+		matches, holds := rule.Matches(candidate), slices.Contains(held, rule.Id)
+		switch {
+		case matches && !holds:
+			if _, err = svc.granter.GrantByRuleTx(ctx, tx, candidate.Id, rule.RoleId, rule.Id); err != nil {
+				return err
+			}
+		case !matches && holds:
+			if err = svc.granter.RevokeByRuleTx(ctx, tx, candidate.Id, rule.Id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileTx привести назначения включённого правила в соответствие с его условиями;
+// revokeAll - сначала отозвать всё, что правило выдало раньше, например при смене роли
+func (svc *Service) reconcileTx(ctx context.Context, tx *sqlx.Tx, rule Entity, revokeAll bool) error {
+	if revokeAll {
+		if err := svc.revokeHoldersTx(ctx, tx, rule.Id); err != nil {
+			return err
+		}
+	}
+	candidates, err := svc.repo.FindCandidatesTx(tx)
+	if err != nil {
+		return fmt.Errorf("error retrieving employees for assignment rule %d: %w", rule.Id, err)
+	}
+	holders, err := svc.repo.FindHoldersTx(tx, rule.Id, time.Now())
+	if err != nil {
+		return fmt.Errorf("error retrieving employees of assignment rule %d: %w", rule.Id, err)
+	}
+	grant, revoke := plan(rule, candidates, holders)
+	for _, holder := range revoke {
+		if err = svc.granter.RevokeByRuleTx(ctx, tx, holder.Id, rule.Id); err != nil {
+			return err
+		}
+	}
+	for _, candidate := range grant {
+		if _, err = svc.granter.GrantByRuleTx(ctx, tx, candidate.Id, rule.RoleId, rule.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeHoldersTx отозвать все назначения, выданные правилом
+func (svc *Service) revokeHoldersTx(ctx context.Context, tx *sqlx.Tx, ruleId int64) error {
+	holders, err := svc.repo.FindHoldersTx(tx, ruleId, time.Now())
+	if err != nil {
+		return fmt.Errorf("error retrieving employees of assignment rule %d: %w", ruleId, err)
+	}
+	for _, holder := range holders {
+		if err = svc.granter.RevokeByRuleTx(ctx, tx, holder.Id, ruleId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plan кому из candidates правило должно выдать роль и у кого из holders, уже получивших её по правилу,
+// роль нужно отозвать
+func plan(rule Entity, candidates, holders []employee.Entity) (grant, revoke []employee.Entity) {
+	held := make(map[int64]bool, len(holders))
+	for _, holder := range holders {
+		held[holder.Id] = true
+		if !rule.Matches(holder) {
+			revoke = append(revoke, holder)
+		}
+	}
+	for _, candidate := range candidates {
+		if !held[candidate.Id] && rule.Matches(candidate) {
+			grant = append(grant, candidate)
+		}
+	}
+	return grant, revoke
+}
+
+// lock заблокировать пересчёт назначений и само правило до конца транзакции
+func (svc *Service) lock(tx *sqlx.Tx, id int64) (Entity, error) {
+	if err := svc.repo.LockRulesTx(tx); err != nil {
+		return Entity{}, fmt.Errorf("error locking assignment rules: %w", err)
+	}
+	rule, err := svc.repo.FindByIdForUpdateTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("assignment rule with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding assignment rule with id %d: %w", id, err)
+	}
+	return rule, nil
+}
+
+// checkRule проверить, что роль существует, а условия ссылаются на известные атрибуты
+func (svc *Service) checkRule(rule Entity) error {
+	if _, err := svc.roleRepo.FindById(rule.RoleId); err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", rule.RoleId, err),
+		}
+	}
+	var custom []string
+	for _, condition := range rule.Conditions {
+		single := condition.Operator == OperatorEquals || condition.Operator == OperatorNotEquals
+		if single && len(condition.Values) != 1 {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("operator %s on attribute %q takes exactly one value", condition.Operator, condition.Attribute),
+			}
+		}
+		switch condition.Attribute {
+		case AttributeOrgUnit, AttributeManager, AttributeStatus, AttributeTitle, AttributeEmployeeNumber:
+			continue
+		}
+		key, ok := strings.CutPrefix(condition.Attribute, customAttributePrefix)
+		if !ok {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("attribute %q cannot be used in assignment rules", condition.Attribute),
+			}
+		}
+		custom = append(custom, key)
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+	definitions, err := svc.attributeRepo.FindAll()
+	if err != nil {
+		return fmt.Errorf("error retrieving employee attributes: %w", err)
+	}
+	for _, key := range custom {
+		defined := slices.ContainsFunc(definitions, func(definition attribute.Entity) bool {
+			return definition.Key == key
+		})
+		if !defined {
+			return common.RequestValidationError{Message: fmt.Sprintf("attribute %q is not defined", key)}
+		}
+	}
+	return nil
+}
+
+func (svc *Service) checkUnique(tx *sqlx.Tx, name string, exceptId int64) error {
+	exists, err := svc.repo.ExistsTx(tx, name, exceptId)
+	if err != nil {
+		return fmt.Errorf("error finding assignment rule %s: %w", name, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{Message: fmt.Sprintf("assignment rule named %s already exists", name)}
+	}
+	return nil
+}
@@ -0,0 +1,577 @@
+package birthright
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) LockRulesTx(tx *sqlx.Tx) error {
+	args := m.Called(tx)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsTx(tx *sqlx.Tx, name string, exceptId int64) (bool, error) {
+	args := m.Called(tx, name, exceptId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindEnabledTx(tx *sqlx.Tx) ([]Entity, error) {
+	args := m.Called(tx)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) SetEnabledTx(tx *sqlx.Tx, id int64, enabled bool) (Entity, error) {
+	args := m.Called(tx, id, enabled)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindCandidates() ([]employee.Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]employee.Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindCandidatesTx(tx *sqlx.Tx) ([]employee.Entity, error) {
+	args := m.Called(tx)
+	return args.Get(0).([]employee.Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindHolders(ruleId int64, at time.Time) ([]employee.Entity, error) {
+	args := m.Called(ruleId, at)
+	return args.Get(0).([]employee.Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindHoldersTx(tx *sqlx.Tx, ruleId int64, at time.Time) ([]employee.Entity, error) {
+	args := m.Called(tx, ruleId, at)
+	return args.Get(0).([]employee.Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindHeldRuleIdsTx(tx *sqlx.Tx, employeeId int64, at time.Time) ([]int64, error) {
+	args := m.Called(tx, employeeId, at)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+// StubAttributeRepo схема пользовательских атрибутов; по умолчанию пустая
+type StubAttributeRepo struct {
+	definitions []attribute.Entity
+}
+
+func (r *StubAttributeRepo) FindAll() ([]attribute.Entity, error) {
+	return r.definitions, nil
+}
+
+// grant назначение, выданное правилом в тесте
+type grant struct {
+	employeeId, roleId, ruleId int64
+}
+
+// StubGranter запоминает выданные и отозванные по правилам назначения
+type StubGranter struct {
+	grants  []grant
+	revokes []grant
+	err     error
+}
+
+func (g *StubGranter) GrantByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, roleId, ruleId int64) (int64, error) {
+	if g.err != nil {
+		return 0, g.err
+	}
+	g.grants = append(g.grants, grant{employeeId: employeeId, roleId: roleId, ruleId: ruleId})
+	return int64(len(g.grants)), nil
+}
+
+func (g *StubGranter) RevokeByRuleTx(ctx context.Context, tx *sqlx.Tx, employeeId, ruleId int64) error {
+	g.revokes = append(g.revokes, grant{employeeId: employeeId, ruleId: ruleId})
+	return nil
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestEntityMatches(t *testing.T) {
+	a := assert.New(t)
+	finance, sales := int64(3), int64(4)
+	title := "Analyst"
+	rule := Entity{Conditions: Conditions{
+		{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}},
+		{Attribute: "title", Operator: OperatorNotIn, Values: []string{"Intern", "Contractor"}},
+		{Attribute: "attributes.remote", Operator: OperatorEquals, Values: []string{"false"}},
+		{Attribute: "attributes.grade", Operator: OperatorIn, Values: []string{"7", "8"}},
+	}}
+	matching := employee.Entity{
+		Id:         1,
+		Status:     employee.StatusActive,
+		OrgUnitId:  &finance,
+		Title:      &title,
+		Attributes: employee.Attributes{"remote": false, "grade": float64(7)},
+	}
+
+	t.Run("should match employee meeting every condition", func(t *testing.T) {
+		a.True(rule.Matches(matching))
+	})
+
+	t.Run("should not match employee from another org unit", func(t *testing.T) {
+		other := matching
+		other.OrgUnitId = &sales
+		a.False(rule.Matches(other))
+	})
+
+	t.Run("should treat missing attribute as not equal to any value", func(t *testing.T) {
+		missing := matching
+		missing.Attributes = employee.Attributes{"remote": false}
+		a.False(rule.Matches(missing))
+
+		untitled := matching
+		untitled.Title = nil
+		a.True(rule.Matches(untitled))
+	})
+
+	t.Run("should not match terminated employee", func(t *testing.T) {
+		terminated := matching
+		terminated.Status = employee.StatusTerminated
+		a.False(rule.Matches(terminated))
+	})
+
+	t.Run("should match everyone without conditions", func(t *testing.T) {
+		everyone := Entity{}
+		a.True(everyone.Matches(employee.Entity{Id: 2, Status: employee.StatusPreHire}))
+	})
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	conditions := []Condition{{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}}}
+
+	t.Run("should create disabled rule and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roles, new(StubAttributeRepo), new(StubGranter), auditor, validator.New())
+
+		entity := Entity{Name: "Finance readers", RoleId: 2, Conditions: conditions}
+		saved := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Conditions: conditions}
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2, Name: "Finance Reader"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, "Finance readers", int64(0)).Return(false, nil)
+		repo.On("SaveTx", noTx, entity).Return(saved, nil)
+
+		got, err := svc.Create(context.Background(), CreateRequest{Name: "Finance readers", RoleId: 2, Conditions: conditions})
+		a.NoError(err)
+		a.Equal(saved.toResponse(), got)
+		a.False(got.Enabled)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "assignment_rule",
+			EntityId:   1,
+			After:      saved.auditSnapshot(),
+		}}, auditor.events)
+	})
+
+	t.Run("should reject condition on unsupported attribute", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(StubAttributeRepo), new(StubGranter), new(StubAuditor), validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "By phone", RoleId: 2, Conditions: []Condition{
+			{Attribute: "phone", Operator: OperatorEquals, Values: []string{"+15550100"}},
+		}})
+		a.Equal(common.RequestValidationError{Message: `attribute "phone" cannot be used in assignment rules`}, err)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should reject custom attribute missing from schema", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		schema := &StubAttributeRepo{definitions: []attribute.Entity{{Id: 1, Key: "cost_center", Type: "string"}}}
+		svc := NewService(repo, roles, schema, new(StubGranter), new(StubAuditor), validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Remote", RoleId: 2, Conditions: []Condition{
+			{Attribute: "attributes.cost_center", Operator: OperatorIn, Values: []string{"CC-1", "CC-2"}},
+			{Attribute: "attributes.remote", Operator: OperatorEquals, Values: []string{"true"}},
+		}})
+		a.Equal(common.RequestValidationError{Message: `attribute "remote" is not defined`}, err)
+	})
+
+	t.Run("should reject equals with several values", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(StubAttributeRepo), new(StubGranter), new(StubAuditor), validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Finance", RoleId: 2, Conditions: []Condition{
+			{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3", "4"}},
+		}})
+		a.Equal(common.RequestValidationError{Message: `operator equals on attribute "org_unit_id" takes exactly one value`}, err)
+	})
+
+	t.Run("should reject unknown operator", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), new(StubGranter), new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Finance", RoleId: 2, Conditions: []Condition{
+			{Attribute: "org_unit_id", Operator: "like", Values: []string{"3"}},
+		}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceEnable(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	finance := int64(3)
+	conditions := Conditions{{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}}}
+
+	t.Run("should grant role to matching employees and audit enabling", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, auditor, validator.New())
+
+		before := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Conditions: conditions}
+		after := before
+		after.Enabled = true
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("SetEnabledTx", noTx, int64(1), true).Return(after, nil)
+		repo.On("FindCandidatesTx", noTx).Return([]employee.Entity{
+			{Id: 10, Name: "Alice", Status: employee.StatusActive, OrgUnitId: &finance},
+			{Id: 11, Name: "Bob", Status: employee.StatusActive},
+		}, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).Return([]employee.Entity{}, nil)
+
+		got, err := svc.Enable(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(got.Enabled)
+		a.Equal([]grant{{employeeId: 10, roleId: 2, ruleId: 1}}, granter.grants)
+		a.Len(auditor.events, 1)
+		a.Equal("enable", auditor.events[0].Action)
+	})
+
+	t.Run("should keep rule disabled when grant conflicts with sod rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := &StubGranter{err: common.SodConflictError{Message: "granting role 2 to employee 10 violates sod rules"}}
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, auditor, validator.New())
+
+		before := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Conditions: conditions}
+		after := before
+		after.Enabled = true
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("SetEnabledTx", noTx, int64(1), true).Return(after, nil)
+		repo.On("FindCandidatesTx", noTx).Return([]employee.Entity{
+			{Id: 10, Name: "Alice", Status: employee.StatusActive, OrgUnitId: &finance},
+		}, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).Return([]employee.Entity{}, nil)
+
+		_, err := svc.Enable(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.SodConflictError{})
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should not touch already enabled rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		enabled := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Enabled: true}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(enabled, nil)
+
+		got, err := svc.Enable(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(got.Enabled)
+		a.True(repo.AssertNotCalled(t, "SetEnabledTx"))
+		a.Empty(granter.grants)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), new(StubGranter), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(7)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Enable(context.Background(), IdRequest{Id: 7})
+		a.Equal(common.NotFoundError{Message: "assignment rule with id 7 not found"}, err)
+	})
+}
+
+func TestServiceDisable(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke every assignment of the rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, auditor, validator.New())
+
+		before := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Enabled: true}
+		after := before
+		after.Enabled = false
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("SetEnabledTx", noTx, int64(1), false).Return(after, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).
+			Return([]employee.Entity{{Id: 10}, {Id: 11}}, nil)
+
+		got, err := svc.Disable(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.False(got.Enabled)
+		a.Equal([]grant{{employeeId: 10, ruleId: 1}, {employeeId: 11, ruleId: 1}}, granter.revokes)
+		a.Equal("disable", auditor.events[0].Action)
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	finance, sales := int64(3), int64(4)
+
+	t.Run("should regrant new role when role of enabled rule changes", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, roles, new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		conditions := Conditions{{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}}}
+		before := Entity{Id: 1, Name: "Finance", RoleId: 2, Conditions: conditions, Enabled: true}
+		entity := Entity{Id: 1, Name: "Finance", RoleId: 5, Conditions: conditions}
+		updated := entity
+		updated.Enabled = true
+		alice := employee.Entity{Id: 10, Name: "Alice", Status: employee.StatusActive, OrgUnitId: &finance}
+		roles.On("FindById", int64(5)).Return(role.Entity{Id: 5}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("ExistsTx", noTx, "Finance", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, entity).Return(updated, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).
+			Return([]employee.Entity{alice}, nil).Once()
+		repo.On("FindCandidatesTx", noTx).Return([]employee.Entity{alice}, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).
+			Return([]employee.Entity{}, nil).Once()
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Finance", RoleId: 5, Conditions: conditions})
+		a.NoError(err)
+		a.Equal([]grant{{employeeId: 10, ruleId: 1}}, granter.revokes)
+		a.Equal([]grant{{employeeId: 10, roleId: 5, ruleId: 1}}, granter.grants)
+	})
+
+	t.Run("should revoke role from employees no longer matching", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, roles, new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		conditions := Conditions{{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"4"}}}
+		before := Entity{Id: 1, Name: "Finance", RoleId: 2, Enabled: true}
+		entity := Entity{Id: 1, Name: "Finance", RoleId: 2, Conditions: conditions}
+		updated := entity
+		updated.Enabled = true
+		alice := employee.Entity{Id: 10, Name: "Alice", Status: employee.StatusActive, OrgUnitId: &finance}
+		bob := employee.Entity{Id: 11, Name: "Bob", Status: employee.StatusActive, OrgUnitId: &sales}
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("ExistsTx", noTx, "Finance", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, entity).Return(updated, nil)
+		repo.On("FindCandidatesTx", noTx).Return([]employee.Entity{alice, bob}, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).
+			Return([]employee.Entity{alice}, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Finance", RoleId: 2, Conditions: conditions})
+		a.NoError(err)
+		a.Equal([]grant{{employeeId: 10, ruleId: 1}}, granter.revokes)
+		a.Equal([]grant{{employeeId: 11, roleId: 2, ruleId: 1}}, granter.grants)
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke assignments before deleting rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, auditor, validator.New())
+
+		rule := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Enabled: true}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(rule, nil)
+		repo.On("FindHoldersTx", noTx, int64(1), mock.AnythingOfType("time.Time")).
+			Return([]employee.Entity{{Id: 10}}, nil)
+		repo.On("DeleteTx", noTx, int64(1)).Return(rule, nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal([]grant{{employeeId: 10, ruleId: 1}}, granter.revokes)
+		a.Equal(audit.ActionDelete, auditor.events[0].Action)
+	})
+}
+
+func TestServicePreview(t *testing.T) {
+	a := assert.New(t)
+	finance, sales := int64(3), int64(4)
+
+	t.Run("should list employees gaining and losing the role", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		login := "carol"
+		rule := Entity{Id: 1, Name: "Finance readers", RoleId: 2, Enabled: true, Conditions: Conditions{
+			{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}},
+		}}
+		alice := employee.Entity{Id: 10, Name: "Alice", Status: employee.StatusActive, OrgUnitId: &finance}
+		bob := employee.Entity{Id: 11, Name: "Bob", Status: employee.StatusActive, OrgUnitId: &sales}
+		carol := employee.Entity{Id: 12, Name: "Carol", Login: &login, Status: employee.StatusActive, OrgUnitId: &finance}
+		repo.On("FindById", int64(1)).Return(rule, nil)
+		repo.On("FindCandidates").Return([]employee.Entity{alice, bob, carol}, nil)
+		repo.On("FindHolders", int64(1), mock.AnythingOfType("time.Time")).Return([]employee.Entity{alice, bob}, nil)
+
+		got, err := svc.Preview(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal(PreviewResponse{
+			RuleId: 1,
+			Grant:  []EmployeeResponse{{Id: 12, Name: "Carol", Login: &login}},
+			Revoke: []EmployeeResponse{{Id: 11, Name: "Bob"}},
+		}, got)
+		a.Empty(granter.grants)
+		a.Empty(granter.revokes)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), new(StubGranter), new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(7)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Preview(IdRequest{Id: 7})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceApplyTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	finance := int64(3)
+	rules := []Entity{
+		{Id: 1, RoleId: 2, Enabled: true, Conditions: Conditions{
+			{Attribute: "org_unit_id", Operator: OperatorEquals, Values: []string{"3"}},
+		}},
+		{Id: 2, RoleId: 5, Enabled: true, Conditions: Conditions{
+			{Attribute: "title", Operator: OperatorEquals, Values: []string{"Manager"}},
+		}},
+	}
+
+	t.Run("should grant matched rules and revoke unmatched ones", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindEnabledTx", noTx).Return(rules, nil)
+		repo.On("FindHeldRuleIdsTx", noTx, int64(10), mock.AnythingOfType("time.Time")).Return([]int64{2}, nil)
+
+		err := svc.ApplyTx(context.Background(), noTx, employee.Entity{Id: 10, Status: employee.StatusActive, OrgUnitId: &finance})
+		a.NoError(err)
+		a.Equal([]grant{{employeeId: 10, roleId: 2, ruleId: 1}}, granter.grants)
+		a.Equal([]grant{{employeeId: 10, ruleId: 2}}, granter.revokes)
+	})
+
+	t.Run("should not grant again rule already held", func(t *testing.T) {
+		repo := new(MockRepo)
+		granter := new(StubGranter)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAttributeRepo), granter, new(StubAuditor), validator.New())
+
+		repo.On("LockRulesTx", noTx).Return(nil)
+		repo.On("FindEnabledTx", noTx).Return(rules, nil)
+		repo.On("FindHeldRuleIdsTx", noTx, int64(10), mock.AnythingOfType("time.Time")).Return([]int64{1}, nil)
+
+		err := svc.ApplyTx(context.Background(), noTx, employee.Entity{Id: 10, Status: employee.StatusActive, OrgUnitId: &finance})
+		a.NoError(err)
+		a.Empty(granter.grants)
+		a.Empty(granter.revokes)
+	})
+}
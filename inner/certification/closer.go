@@ -0,0 +1,48 @@
+package certification
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"time"
+)
+
+// closeActor автор решений, принятых после срока кампании, в журнале аудита и отчёте
+const closeActor = "system:certification-deadline"
+
+type CloseSvc interface {
+	Close(ctx context.Context, now time.Time) (int, error)
+}
+
+// Closer завершает кампании, срок которых наступил, и отзывает роли без решения
+type Closer struct {
+	svc    CloseSvc
+	logger *common.Logger
+}
+
+func NewCloser(svc CloseSvc, logger *common.Logger) *Closer {
+	return &Closer{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+// Run проверять сроки кампаний сразу и затем каждые interval, пока не отменён ctx
+func (c *Closer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		closed, err := c.svc.Close(common.WithActor(ctx, closeActor), time.Now())
+		if err != nil {
+			c.logger.Error("certification campaigns: closing failed", zap.Error(err))
+		}
+		if closed > 0 {
+			c.logger.Info("certification campaigns: closed", zap.Int("count", closed))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
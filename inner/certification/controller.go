@@ -0,0 +1,247 @@
+package certification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты кампаний пересмотра доступа
+const (
+	permissionRead   = "certifications:read"
+	permissionReview = "certifications:review"
+	permissionManage = "certifications:manage"
+)
+
+// headerReportSignature заголовок ответа с подписью отчёта кампании
+const headerReportSignature = "X-Report-Signature"
+
+type Controller struct {
+	server               *web.Server
+	certificationService Svc
+	logger               *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll(request ListRequest) (common.Page[Response], error)
+	FindItems(request ItemListRequest) (common.Page[ItemResponse], error)
+	Certify(ctx context.Context, request DecisionRequest) (ItemResponse, error)
+	Revoke(ctx context.Context, request DecisionRequest) (ItemResponse, error)
+	AssignReviewer(ctx context.Context, request ReviewerRequest) ([]ItemResponse, error)
+	Report(request IdRequest) (Report, error)
+}
+
+func NewController(server *web.Server, certificationService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:               server,
+		certificationService: certificationService,
+		logger:               logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/certifications", c.server.Require(permissionManage), c.CreateCampaign)
+	c.server.GroupApiV1.Get("/certifications", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/certifications/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/certifications/:id/items", c.server.Require(permissionRead), c.FindItems)
+	c.server.GroupApiV1.Post("/certifications/:id/items/:itemId/certify", c.server.Require(permissionReview), c.Certify)
+	c.server.GroupApiV1.Post("/certifications/:id/items/:itemId/revoke", c.server.Require(permissionReview), c.Revoke)
+	c.server.GroupApiV1.Put("/certifications/:id/reviewer", c.server.Require(permissionManage), c.AssignReviewer)
+	c.server.GroupApiV1.Get("/certifications/:id/report", c.server.Require(permissionRead), c.Report)
+}
+
+func (c *Controller) CreateCampaign(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create certification campaign: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create certification campaign: received request",
+		zap.String("name", request.Name), zap.Int64s("role_ids", request.RoleIds))
+	response, err := c.certificationService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create certification campaign: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create certification campaign: success",
+		zap.Int64("id", response.Id), zap.Int64("items", response.Progress.Total))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	pageRequest, err := common.ParsePageRequest(ctx)
+	if err != nil {
+		c.logger.Error("find certification campaigns: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request := ListRequest{PageRequest: pageRequest, Status: ctx.Query("status")}
+	c.logger.Debug("find certification campaigns: received request", zap.Any("request", request))
+	page, err := c.certificationService.FindAll(request)
+	if err != nil {
+		c.logger.Error("find certification campaigns: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find certification campaigns: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find certification campaign by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find certification campaign by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.certificationService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find certification campaign by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find certification campaign by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+// FindItems позиции кампании; фильтры в query: reviewer, decision (pending, certified, revoked)
+func (c *Controller) FindItems(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find certification items: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find certification items: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	pageRequest, err := common.ParsePageRequest(ctx)
+	if err != nil {
+		c.logger.Error("find certification items: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request := ItemListRequest{
+		PageRequest: pageRequest,
+		Id:          id,
+		Reviewer:    ctx.Query("reviewer"),
+		Decision:    ctx.Query("decision"),
+	}
+	page, err := c.certificationService.FindItems(request)
+	if err != nil {
+		c.logger.Error("find certification items: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find certification items: success", zap.Int64("id", id), zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+func (c *Controller) Certify(ctx *fiber.Ctx) error {
+	return c.decide(ctx, "certify", c.certificationService.Certify)
+}
+
+func (c *Controller) Revoke(ctx *fiber.Ctx) error {
+	return c.decide(ctx, "revoke", c.certificationService.Revoke)
+}
+
+// decide тело запроса с комментарием необязательно
+func (c *Controller) decide(
+	ctx *fiber.Ctx,
+	name string,
+	decide func(ctx context.Context, request DecisionRequest) (ItemResponse, error),
+) error {
+	campaignId, itemId, err := parseItemPath(ctx)
+	if err != nil {
+		c.logger.Error(name+" certification item: invalid path parameter", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	var request DecisionRequest
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&request); err != nil {
+			c.logger.Error(name+" certification item: failed to parse request body", zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+		}
+	}
+	request.CampaignId = campaignId
+	request.ItemId = itemId
+	response, err := decide(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error(name+" certification item: service error",
+			zap.Int64("campaign_id", campaignId), zap.Int64("item_id", itemId), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug(name+" certification item: success", zap.Int64("campaign_id", campaignId), zap.Int64("item_id", itemId))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) AssignReviewer(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("assign certification reviewer: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("assign certification reviewer: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request ReviewerRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("assign certification reviewer: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.certificationService.AssignReviewer(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("assign certification reviewer: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("assign certification reviewer: success", zap.Int64("id", id), zap.Int("count", len(response)))
+	return common.OkResponse(ctx, response)
+}
+
+// Report отчёт кампании в CSV; подпись отчёта передаётся в заголовке X-Report-Signature
+func (c *Controller) Report(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("certification report: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("certification report: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	report, err := c.certificationService.Report(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("certification report: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("certification report: success", zap.Int64("id", id), zap.Int("bytes", len(report.Content)))
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, report.Filename))
+	ctx.Set(headerReportSignature, report.Signature)
+	return ctx.Send(report.Content)
+}
+
+// parseItemPath прочитать из пути id кампании и id её позиции
+func parseItemPath(ctx *fiber.Ctx) (campaignId, itemId int64, err error) {
+	if campaignId, err = strconv.ParseInt(ctx.Params("id"), 10, 64); err != nil {
+		return 0, 0, errors.New("invalid id parameter")
+	}
+	if itemId, err = strconv.ParseInt(ctx.Params("itemId"), 10, 64); err != nil {
+		return 0, 0, errors.New("invalid itemId parameter")
+	}
+	return campaignId, itemId, nil
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.ForbiddenError{}):
+		return fiber.StatusForbidden
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.PreconditionFailedError{}):
+		return fiber.StatusPreconditionFailed
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
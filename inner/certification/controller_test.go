@@ -0,0 +1,245 @@
+package certification
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
+}
+
+func (svc *MockService) FindItems(request ItemListRequest) (common.Page[ItemResponse], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[ItemResponse]), args.Error(1)
+}
+
+func (svc *MockService) Certify(ctx context.Context, request DecisionRequest) (ItemResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(ItemResponse), args.Error(1)
+}
+
+func (svc *MockService) Revoke(ctx context.Context, request DecisionRequest) (ItemResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(ItemResponse), args.Error(1)
+}
+
+func (svc *MockService) AssignReviewer(ctx context.Context, request ReviewerRequest) ([]ItemResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]ItemResponse), args.Error(1)
+}
+
+func (svc *MockService) Report(request IdRequest) (Report, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Report), args.Error(1)
+}
+
+func TestControllerCreateCampaign(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return created campaign", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.MatchedBy(func(r CreateRequest) bool {
+			return r.Name == "Q3 review" && len(r.RoleIds) == 1 && r.RoleIds[0] == 2 && r.AutoRevoke != nil && !*r.AutoRevoke
+		})).Return(Response{Id: 5, Status: StatusActive, Progress: ProgressResponse{Total: 3, Pending: 3}}, nil)
+
+		body := `{"name":"Q3 review","role_ids":[2],"reviewers":["bob"],"deadline":"2030-01-01T00:00:00Z","auto_revoke":false}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(5), responseBody.Data.Id)
+		a.Equal(int64(3), responseBody.Data.Progress.Total)
+	})
+
+	t.Run("should return bad request for campaign without assignments", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.Anything).
+			Return(Response{}, common.RequestValidationError{Message: "roles in scope have no active assignments to review"})
+
+		body := `{"name":"Q3 review","role_ids":[2],"reviewers":["bob"],"deadline":"2030-01-01T00:00:00Z"}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerFindItems(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters from query", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindItems", mock.MatchedBy(func(r ItemListRequest) bool {
+			return r.Id == 5 && r.Reviewer == "bob" && r.Decision == DecisionPending && r.Limit == 20
+		})).Return(common.Page[ItemResponse]{Items: []ItemResponse{{Id: 1}}, PageInfo: common.PageInfo{Total: 1}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/certifications/5/items?reviewer=bob&decision=pending&limit=20", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+}
+
+func TestControllerDecide(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should revoke item with comment", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Revoke", DecisionRequest{CampaignId: 5, ItemId: 1, Comment: "left the team"}).
+			Return(ItemResponse{Id: 1, Decision: decision(DecisionRevoked)}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications/5/items/1/revoke",
+			strings.NewReader(`{"comment":"left the team"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should certify without body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Certify", DecisionRequest{CampaignId: 5, ItemId: 1}).
+			Return(ItemResponse{Id: 1, Decision: decision(DecisionCertified)}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications/5/items/1/certify", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return forbidden for actor who is not reviewer", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Certify", mock.Anything).
+			Return(ItemResponse{}, common.ForbiddenError{Message: "mallory is not the reviewer of certification item 1"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications/5/items/1/certify", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("should return bad request for invalid item id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/certifications/5/items/abc/certify", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Certify", mock.Anything))
+	})
+}
+
+func TestControllerReport(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should send csv with signature header", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		content := []byte("campaign_id,campaign_name\n5,Q3 review\n")
+		svc.On("Report", IdRequest{Id: 5}).Return(Report{
+			Filename:  "certification-campaign-5.csv",
+			Content:   content,
+			Signature: "eyJhbGciOiJFUzI1NiJ9.e30.c2ln",
+		}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/certifications/5/report", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal("text/csv; charset=utf-8", resp.Header.Get(fiber.HeaderContentType))
+		a.Equal(`attachment; filename="certification-campaign-5.csv"`, resp.Header.Get(fiber.HeaderContentDisposition))
+		a.Equal("eyJhbGciOiJFUzI1NiJ9.e30.c2ln", resp.Header.Get(headerReportSignature))
+		body, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.Equal(content, body)
+	})
+
+	t.Run("should return precondition failed without signing keys", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Report", IdRequest{Id: 5}).
+			Return(Report{}, common.PreconditionFailedError{Message: "report signing keys are not configured"})
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/certifications/5/report", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
@@ -0,0 +1,220 @@
+package certification
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Состояния кампании: active, пока рецензенты принимают решения, и completed, когда решения приняты
+// по всем позициям или наступил срок
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+)
+
+// Решения рецензента по позиции; DecisionPending в фильтрах и отчёте означает, что решения ещё нет
+const (
+	DecisionCertified = "certified"
+	DecisionRevoked   = "revoked"
+	DecisionPending   = "pending"
+)
+
+// Entity кампания пересмотра доступа к ролям RoleIds
+type Entity struct {
+	Id          int64         `db:"id"`
+	Name        string        `db:"name"`
+	RoleIds     pq.Int64Array `db:"role_ids"`
+	OrgUnitId   *int64        `db:"org_unit_id"`
+	Status      string        `db:"status"`
+	Deadline    time.Time     `db:"deadline"`
+	AutoRevoke  bool          `db:"auto_revoke"`
+	CreatedBy   string        `db:"created_by"`
+	CompletedAt *time.Time    `db:"completed_at"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+}
+
+// ItemEntity позиция кампании: назначение роли в том виде, в каком оно было при создании кампании
+type ItemEntity struct {
+	Id            int64      `db:"id"`
+	CampaignId    int64      `db:"campaign_id"`
+	AssignmentId  int64      `db:"assignment_id"`
+	EmployeeId    int64      `db:"employee_id"`
+	EmployeeName  string     `db:"employee_name"`
+	EmployeeLogin *string    `db:"employee_login"`
+	RoleId        int64      `db:"role_id"`
+	RoleName      string     `db:"role_name"`
+	ValidFrom     time.Time  `db:"valid_from"`
+	ValidTo       *time.Time `db:"valid_to"`
+	Reviewer      string     `db:"reviewer"`
+	Decision      *string    `db:"decision"`
+	Comment       *string    `db:"comment"`
+	DecidedBy     *string    `db:"decided_by"`
+	DecidedAt     *time.Time `db:"decided_at"`
+}
+
+// ProgressEntity сколько позиций кампании всего и сколько из них подтверждено и отозвано
+type ProgressEntity struct {
+	CampaignId int64 `db:"campaign_id"`
+	Total      int64 `db:"total"`
+	Certified  int64 `db:"certified"`
+	Revoked    int64 `db:"revoked"`
+}
+
+func (e *Entity) toResponse(progress ProgressEntity) Response {
+	return Response{
+		Id:          e.Id,
+		Name:        e.Name,
+		RoleIds:     append([]int64{}, e.RoleIds...),
+		OrgUnitId:   e.OrgUnitId,
+		Status:      e.Status,
+		Deadline:    e.Deadline,
+		AutoRevoke:  e.AutoRevoke,
+		CreatedBy:   e.CreatedBy,
+		CompletedAt: e.CompletedAt,
+		Progress: ProgressResponse{
+			Total:     progress.Total,
+			Certified: progress.Certified,
+			Revoked:   progress.Revoked,
+			Pending:   progress.Total - progress.Certified - progress.Revoked,
+		},
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние кампании в журнале аудита
+type auditSnapshot struct {
+	Id          int64      `json:"id"`
+	Name        string     `json:"name"`
+	RoleIds     []int64    `json:"role_ids"`
+	OrgUnitId   *int64     `json:"org_unit_id"`
+	Status      string     `json:"status"`
+	Deadline    time.Time  `json:"deadline"`
+	AutoRevoke  bool       `json:"auto_revoke"`
+	Items       int        `json:"items,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func (e *Entity) auditSnapshot(items int) auditSnapshot {
+	return auditSnapshot{
+		Id:          e.Id,
+		Name:        e.Name,
+		RoleIds:     e.RoleIds,
+		OrgUnitId:   e.OrgUnitId,
+		Status:      e.Status,
+		Deadline:    e.Deadline,
+		AutoRevoke:  e.AutoRevoke,
+		Items:       items,
+		CompletedAt: e.CompletedAt,
+	}
+}
+
+func (e *ItemEntity) toResponse() ItemResponse {
+	return ItemResponse{
+		Id:            e.Id,
+		CampaignId:    e.CampaignId,
+		AssignmentId:  e.AssignmentId,
+		EmployeeId:    e.EmployeeId,
+		EmployeeName:  e.EmployeeName,
+		EmployeeLogin: e.EmployeeLogin,
+		RoleId:        e.RoleId,
+		RoleName:      e.RoleName,
+		ValidFrom:     e.ValidFrom,
+		ValidTo:       e.ValidTo,
+		Reviewer:      e.Reviewer,
+		Decision:      e.Decision,
+		Comment:       e.Comment,
+		DecidedBy:     e.DecidedBy,
+		DecidedAt:     e.DecidedAt,
+	}
+}
+
+// itemAuditSnapshot состояние позиции в журнале аудита
+type itemAuditSnapshot struct {
+	Id         int64      `json:"id"`
+	CampaignId int64      `json:"campaign_id"`
+	EmployeeId int64      `json:"employee_id"`
+	RoleId     int64      `json:"role_id"`
+	Reviewer   string     `json:"reviewer"`
+	Decision   *string    `json:"decision"`
+	DecidedBy  *string    `json:"decided_by"`
+	DecidedAt  *time.Time `json:"decided_at"`
+}
+
+func (e *ItemEntity) auditSnapshot() itemAuditSnapshot {
+	return itemAuditSnapshot{
+		Id:         e.Id,
+		CampaignId: e.CampaignId,
+		EmployeeId: e.EmployeeId,
+		RoleId:     e.RoleId,
+		Reviewer:   e.Reviewer,
+		Decision:   e.Decision,
+		DecidedBy:  e.DecidedBy,
+		DecidedAt:  e.DecidedAt,
+	}
+}
+
+// decisionOf решение по позиции для фильтров и отчёта
+func (e *ItemEntity) decisionOf() string {
+	if e.Decision == nil {
+		return DecisionPending
+	}
+	return *e.Decision
+}
+
+type Response struct {
+	Id          int64            `json:"id"`
+	Name        string           `json:"name"`
+	RoleIds     []int64          `json:"role_ids"`
+	OrgUnitId   *int64           `json:"org_unit_id,omitempty"`
+	Status      string           `json:"status"`
+	Deadline    time.Time        `json:"deadline"`
+	AutoRevoke  bool             `json:"auto_revoke"`
+	CreatedBy   string           `json:"created_by"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Progress    ProgressResponse `json:"progress"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+type ProgressResponse struct {
+	Total     int64 `json:"total"`
+	Certified int64 `json:"certified"`
+	Revoked   int64 `json:"revoked"`
+	Pending   int64 `json:"pending"`
+}
+
+type ItemResponse struct {
+	Id            int64      `json:"id"`
+	CampaignId    int64      `json:"campaign_id"`
+	AssignmentId  int64      `json:"assignment_id"`
+	EmployeeId    int64      `json:"employee_id"`
+	EmployeeName  string     `json:"employee_name"`
+	EmployeeLogin *string    `json:"employee_login,omitempty"`
+	RoleId        int64      `json:"role_id"`
+	RoleName      string     `json:"role_name"`
+	ValidFrom     time.Time  `json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to,omitempty"`
+	Reviewer      string     `json:"reviewer"`
+	Decision      *string    `json:"decision,omitempty"`
+	Comment       *string    `json:"comment,omitempty"`
+	DecidedBy     *string    `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
+
+// Report отчёт кампании в CSV и подпись к нему. Signature - JWT, подписанный ключом IDM (проверяется
+// по JWKS); claim report_sha256 содержит SHA-256 содержимого Content в hex
+type Report struct {
+	Filename  string
+	Content   []byte
+	Signature string
+}
+
+// reportClaims claims подписи отчёта; sub - кампания, status - её состояние на момент выгрузки
+type reportClaims struct {
+	jwt.RegisteredClaims
+	ReportSha256 string `json:"report_sha256"`
+	Status       string `json:"status"`
+}
@@ -0,0 +1,200 @@
+package certification
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into certification_campaign (name, role_ids, org_unit_id, deadline, auto_revoke, created_by)
+		values ($1, $2, $3, $4, $5, $6) returning *`
+	err = tx.Get(&saved, query, e.Name, e.RoleIds, e.OrgUnitId, e.Deadline, e.AutoRevoke, e.CreatedBy)
+	return saved, err
+}
+
+// FindSourcesTx назначения ролей roleIds, действующие в момент at, в виде позиций без кампании и рецензента;
+// с orgUnitId - только сотрудников этого подразделения и вложенных в него.
+// Назначения удалённых сотрудников и ролей не действуют и в кампанию не попадают. Назначения по правилам
+// тоже не пересматриваются: их выдаёт и отзывает правило. Основная роль сотрудника (employee.role_id)
+// не назначение: у неё нет срока и позиции, которую отзывает решение, она меняется вместе с карточкой
+// сотрудника, поэтому в кампанию не попадает
+func (r *Repository) FindSourcesTx(tx *sqlx.Tx, roleIds []int64, orgUnitId *int64, at time.Time) (items []ItemEntity, err error) {
+	query := `with recursive scope(id) as (
+			select id from org_unit where id = $3
+			union all
+			select u.id from org_unit u join scope s on u.parent_id = s.id
+		)
+		select er.id as assignment_id, er.employee_id, e.name as employee_name, e.login as employee_login,
+		er.role_id, r.name as role_name, er.valid_from, er.valid_to
+		from employee_role er
+		join employee e on e.id = er.employee_id
+		join role r on r.id = er.role_id
+		where er.role_id = any($1) and er.valid_from <= $2 and (er.valid_to is null or er.valid_to > $2)
+		and er.rule_id is null and e.deleted_at is null and r.deleted_at is null
+		and ($3::bigint is null or e.org_unit_id in (select id from scope))
+		order by er.role_id, er.employee_id`
+	err = tx.Select(&items, query, pq.Array(roleIds), at, orgUnitId)
+	return items, err
+}
+
+// SaveItemsTx сохранить позиции кампании campaignId
+func (r *Repository) SaveItemsTx(tx *sqlx.Tx, campaignId int64, items []ItemEntity) error {
+	query := `insert into certification_item (campaign_id, assignment_id, employee_id, employee_name, employee_login,
+		role_id, role_name, valid_from, valid_to, reviewer)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	for _, i := range items {
+		_, err := tx.Exec(query, campaignId, i.AssignmentId, i.EmployeeId, i.EmployeeName, i.EmployeeLogin,
+			i.RoleId, i.RoleName, i.ValidFrom, i.ValidTo, i.Reviewer)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) FindById(id int64) (campaign Entity, err error) {
+	query := "select * from certification_campaign where id = $1"
+	err = r.db.Get(&campaign, query, id)
+	return campaign, err
+}
+
+// FindByIdForUpdateTx найти кампанию и заблокировать её до конца транзакции: решения по позициям
+// и завершение кампании выполняются по очереди
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (campaign Entity, err error) {
+	query := "select * from certification_campaign where id = $1 for update"
+	err = tx.Get(&campaign, query, id)
+	return campaign, err
+}
+
+// FindOverdue активные кампании, срок которых наступил к моменту now
+func (r *Repository) FindOverdue(now time.Time) (campaigns []Entity, err error) {
+	query := "select * from certification_campaign where status = 'active' and deadline <= $1 order by id"
+	err = r.db.Select(&campaigns, query, now)
+	return campaigns, err
+}
+
+// CompleteTx завершить кампанию в момент at
+func (r *Repository) CompleteTx(tx *sqlx.Tx, id int64, at time.Time) (completed Entity, err error) {
+	query := "update certification_campaign set status = 'completed', completed_at = $2 where id = $1 returning *"
+	err = tx.Get(&completed, query, id, at)
+	return completed, err
+}
+
+// FindPage страница кампаний по фильтру, начиная после курсора after
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (campaigns []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	query := "select * from certification_campaign" + conditions.Where() + order
+	err = r.db.Select(&campaigns, query, conditions.Args()...)
+	return campaigns, err
+}
+
+// Count количество кампаний, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from certification_campaign" + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+// FindProgress ход кампаний campaignIds; кампании без позиций в результат не попадают
+func (r *Repository) FindProgress(campaignIds []int64) (progress []ProgressEntity, err error) {
+	query := `select campaign_id, count(*) as total,
+		count(*) filter (where decision = 'certified') as certified,
+		count(*) filter (where decision = 'revoked') as revoked
+		from certification_item where campaign_id = any($1)
+		group by campaign_id`
+	err = r.db.Select(&progress, query, pq.Array(campaignIds))
+	return progress, err
+}
+
+// FindItems страница позиций кампании по фильтру, начиная после курсора after
+func (r *Repository) FindItems(request ItemListRequest, after *common.Cursor) (items []ItemEntity, err error) {
+	conditions := itemConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	query := "select * from certification_item" + conditions.Where() + order
+	err = r.db.Select(&items, query, conditions.Args()...)
+	return items, err
+}
+
+// CountItems количество позиций кампании, подходящих под фильтр, без учёта курсора
+func (r *Repository) CountItems(request ItemListRequest) (total int64, err error) {
+	conditions := itemConditions(request)
+	query := "select count(*) from certification_item" + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+// FindAllItems все позиции кампании в порядке id
+func (r *Repository) FindAllItems(campaignId int64) (items []ItemEntity, err error) {
+	query := "select * from certification_item where campaign_id = $1 order by id"
+	err = r.db.Select(&items, query, campaignId)
+	return items, err
+}
+
+// FindItemsTx позиции itemIds кампании campaignId; позиций другой кампании в результате нет
+func (r *Repository) FindItemsTx(tx *sqlx.Tx, campaignId int64, itemIds []int64) (items []ItemEntity, err error) {
+	query := "select * from certification_item where campaign_id = $1 and id = any($2) order by id"
+	err = tx.Select(&items, query, campaignId, pq.Array(itemIds))
+	return items, err
+}
+
+// FindUndecidedTx позиции кампании, по которым ещё нет решения
+func (r *Repository) FindUndecidedTx(tx *sqlx.Tx, campaignId int64) (items []ItemEntity, err error) {
+	query := "select * from certification_item where campaign_id = $1 and decision is null order by id"
+	err = tx.Select(&items, query, campaignId)
+	return items, err
+}
+
+// DecideItemTx сохранить решение по позиции, его автора, время и комментарий
+func (r *Repository) DecideItemTx(tx *sqlx.Tx, e ItemEntity) (updated ItemEntity, err error) {
+	query := `update certification_item set decision = $2, comment = $3, decided_by = $4, decided_at = $5
+		where id = $1 returning *`
+	err = tx.Get(&updated, query, e.Id, e.Decision, e.Comment, e.DecidedBy, e.DecidedAt)
+	return updated, err
+}
+
+// UpdateReviewerTx передать позиции itemIds рецензенту reviewer
+func (r *Repository) UpdateReviewerTx(tx *sqlx.Tx, itemIds []int64, reviewer string) error {
+	query := "update certification_item set reviewer = $2 where id = any($1)"
+	_, err := tx.Exec(query, pq.Array(itemIds), reviewer)
+	return err
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.Status != "" {
+		conditions.Add("status = ?", request.Status)
+	}
+	return conditions
+}
+
+func itemConditions(request ItemListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	conditions.Add("campaign_id = ?", request.Id)
+	if request.Reviewer != "" {
+		conditions.Add("reviewer = ?", request.Reviewer)
+	}
+	switch request.Decision {
+	case DecisionPending:
+		conditions.Add("decision is null")
+	case DecisionCertified, DecisionRevoked:
+		conditions.Add("decision = ?", request.Decision)
+	}
+	return conditions
+}
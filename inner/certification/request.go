@@ -0,0 +1,50 @@
+package certification
+
+import (
+	"idm/inner/common"
+	"time"
+)
+
+// CreateRequest начать кампанию по ролям RoleIds. Позиции распределяются между рецензентами Reviewers
+// по очереди. AutoRevoke - отозвать ли после Deadline роли без решения; по умолчанию берётся из настроек
+type CreateRequest struct {
+	Name    string  `json:"name" validate:"required,max=255"`
+	RoleIds []int64 `json:"role_ids" validate:"required,min=1,max=50,unique,dive,gt=0"`
+	// OrgUnitId ограничить кампанию сотрудниками подразделения и вложенных в него
+	OrgUnitId  *int64    `json:"org_unit_id" validate:"omitempty,gt=0"`
+	Reviewers  []string  `json:"reviewers" validate:"required,min=1,max=50,unique,dive,required,max=255"`
+	Deadline   time.Time `json:"deadline" validate:"required"`
+	AutoRevoke *bool     `json:"auto_revoke"`
+}
+
+// DecisionRequest подтвердить или отозвать позицию кампании; Comment сохраняется вместе с решением
+type DecisionRequest struct {
+	CampaignId int64  `json:"campaign_id" validate:"required,gt=0"`
+	ItemId     int64  `json:"item_id" validate:"required,gt=0"`
+	Comment    string `json:"comment" validate:"max=2000"`
+}
+
+// ReviewerRequest передать позиции ItemIds рецензенту Reviewer; позиции с принятым решением не передаются
+type ReviewerRequest struct {
+	Id       int64   `json:"id" validate:"required,gt=0"`
+	ItemIds  []int64 `json:"item_ids" validate:"required,min=1,max=500,unique,dive,gt=0"`
+	Reviewer string  `json:"reviewer" validate:"required,max=255"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// ListRequest фильтры кампаний. Кампании всегда упорядочены по id, по умолчанию от новых к старым
+type ListRequest struct {
+	common.PageRequest
+	Status string `json:"status" validate:"omitempty,oneof=active completed"`
+}
+
+// ItemListRequest фильтры позиций кампании Id. Позиции упорядочены по id от первых к последним
+type ItemListRequest struct {
+	common.PageRequest
+	Id       int64  `json:"id" validate:"required,gt=0"`
+	Reviewer string `json:"reviewer" validate:"max=255"`
+	Decision string `json:"decision" validate:"omitempty,oneof=pending certified revoked"`
+}
@@ -0,0 +1,685 @@
+package certification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/orgunit"
+	"idm/inner/role"
+	"strconv"
+	"time"
+)
+
+// Типы сущностей в журнале аудита: кампания и её позиция
+const (
+	auditEntityType     = "certification_campaign"
+	itemAuditEntityType = "certification_item"
+)
+
+// Действия в журнале аудита, кроме создания кампании
+const (
+	auditActionCertify        = "certify"
+	auditActionRevoke         = "revoke"
+	auditActionAssignReviewer = "assign_reviewer"
+	auditActionComplete       = "complete"
+)
+
+// deadlineComment комментарий к решению, принятому за рецензента после срока кампании
+const deadlineComment = "not reviewed before the campaign deadline"
+
+// csvHeader колонки отчёта кампании
+var csvHeader = []string{
+	"campaign_id", "campaign_name", "item_id", "employee_id", "employee_name", "employee_login",
+	"role_id", "role_name", "valid_from", "valid_to", "reviewer", "decision", "decided_by", "decided_at", "comment",
+}
+
+type Service struct {
+	repo        Repo
+	roleRepo    RoleRepo
+	orgUnitRepo OrgUnitRepo
+	revoker     Revoker
+	auditor     Auditor
+	signer      Signer
+	settings    Settings
+	validator   Validator
+}
+
+// Settings AutoRevoke - отзывать ли после срока роли без решения, если кампания не задала это сама;
+// Issuer - издатель подписи отчётов (claim iss)
+type Settings struct {
+	AutoRevoke bool
+	Issuer     string
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	FindSourcesTx(tx *sqlx.Tx, roleIds []int64, orgUnitId *int64, at time.Time) ([]ItemEntity, error)
+	SaveItemsTx(tx *sqlx.Tx, campaignId int64, items []ItemEntity) error
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindOverdue(now time.Time) ([]Entity, error)
+	CompleteTx(tx *sqlx.Tx, id int64, at time.Time) (Entity, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
+	FindProgress(campaignIds []int64) ([]ProgressEntity, error)
+	FindItems(request ItemListRequest, after *common.Cursor) ([]ItemEntity, error)
+	CountItems(request ItemListRequest) (int64, error)
+	FindAllItems(campaignId int64) ([]ItemEntity, error)
+	FindItemsTx(tx *sqlx.Tx, campaignId int64, itemIds []int64) ([]ItemEntity, error)
+	FindUndecidedTx(tx *sqlx.Tx, campaignId int64) ([]ItemEntity, error)
+	DecideItemTx(tx *sqlx.Tx, e ItemEntity) (ItemEntity, error)
+	UpdateReviewerTx(tx *sqlx.Tx, itemIds []int64, reviewer string) error
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+}
+
+// OrgUnitRepo подразделения, которыми ограничивается кампания
+type OrgUnitRepo interface {
+	FindById(id int64) (orgunit.Entity, error)
+}
+
+// Revoker отзывает роль в транзакции решения по позиции - так же, как при отзыве через API
+type Revoker interface {
+	RevokeTx(ctx context.Context, tx *sqlx.Tx, request assignment.RevokeRequest) error
+}
+
+// Signer ключи подписи IDM; отчёты подписываются теми же ключами, что и токены
+type Signer interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// NewService signer может быть nil: тогда кампании работают, но отчёты не выгружаются
+func NewService(
+	repo Repo,
+	roleRepo RoleRepo,
+	orgUnitRepo OrgUnitRepo,
+	revoker Revoker,
+	auditor Auditor,
+	signer Signer,
+	settings Settings,
+	validator Validator,
+) *Service {
+	return &Service{
+		repo:        repo,
+		roleRepo:    roleRepo,
+		orgUnitRepo: orgUnitRepo,
+		revoker:     revoker,
+		auditor:     auditor,
+		signer:      signer,
+		settings:    settings,
+		validator:   validator,
+	}
+}
+
+// Create начать кампанию: действующие назначения ролей из RoleIds становятся позициями кампании
+// и распределяются между рецензентами. Кампания без позиций не создаётся
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	now := time.Now()
+	if !request.Deadline.After(now) {
+		return Response{}, common.RequestValidationError{Message: "deadline must be in the future"}
+	}
+	for _, roleId := range request.RoleIds {
+		if _, err = svc.roleRepo.FindById(roleId); err != nil {
+			return Response{}, common.NotFoundError{
+				Message: fmt.Sprintf("error finding role with id %d: %v", roleId, err),
+			}
+		}
+	}
+	if request.OrgUnitId != nil {
+		if _, err = svc.orgUnitRepo.FindById(*request.OrgUnitId); err != nil {
+			return Response{}, common.NotFoundError{
+				Message: fmt.Sprintf("error finding org unit with id %d: %v", *request.OrgUnitId, err),
+			}
+		}
+	}
+	autoRevoke := svc.settings.AutoRevoke
+	if request.AutoRevoke != nil {
+		autoRevoke = *request.AutoRevoke
+	}
+
+	var saved Entity
+	var items []ItemEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating certification campaign", func(tx *sqlx.Tx) error {
+		if items, err = svc.repo.FindSourcesTx(tx, request.RoleIds, request.OrgUnitId, now); err != nil {
+			return fmt.Errorf("error retrieving assignments of roles %v: %w", request.RoleIds, err)
+		}
+		if len(items) == 0 {
+			return common.RequestValidationError{Message: "roles in scope have no active assignments to review"}
+		}
+		if err = assignReviewers(items, request.Reviewers); err != nil {
+			return err
+		}
+		saved, err = svc.repo.SaveTx(tx, Entity{
+			Name:       request.Name,
+			RoleIds:    request.RoleIds,
+			OrgUnitId:  request.OrgUnitId,
+			Deadline:   request.Deadline,
+			AutoRevoke: autoRevoke,
+			CreatedBy:  common.ActorFrom(ctx),
+		})
+		if err != nil {
+			return fmt.Errorf("error saving certification campaign %s: %w", request.Name, err)
+		}
+		if err = svc.repo.SaveItemsTx(tx, saved.Id, items); err != nil {
+			return fmt.Errorf("error saving items of certification campaign %d: %w", saved.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(len(items)),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(ProgressEntity{Total: int64(len(items))}), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.findCampaign(request.Id)
+	if err != nil {
+		return Response{}, err
+	}
+	responses, err := svc.toResponses([]Entity{entity})
+	if err != nil {
+		return Response{}, err
+	}
+	return responses[0], nil
+}
+
+// FindAll найти страницу кампаний по фильтру
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Sort = "id"
+	if request.Order == "" {
+		request.Order = "desc"
+	}
+	request.Defaults()
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	campaigns, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error retrieving certification campaigns: %w", err)
+	}
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting certification campaigns: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(campaigns) > request.Limit {
+		campaigns = campaigns[:request.Limit]
+		last := campaigns[len(campaigns)-1]
+		page.NextCursor = request.Next(strconv.FormatInt(last.Id, 10), last.Id)
+	}
+	if page.Items, err = svc.toResponses(campaigns); err != nil {
+		return common.Page[Response]{}, err
+	}
+	return page, nil
+}
+
+// FindItems найти страницу позиций кампании по рецензенту и решению
+func (svc *Service) FindItems(request ItemListRequest) (common.Page[ItemResponse], error) {
+	request.Sort = "id"
+	request.Defaults()
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.Page[ItemResponse]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[ItemResponse]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.findCampaign(request.Id); err != nil {
+		return common.Page[ItemResponse]{}, err
+	}
+	items, err := svc.repo.FindItems(request, after)
+	if err != nil {
+		return common.Page[ItemResponse]{}, fmt.Errorf("error retrieving items of certification campaign %d: %w", request.Id, err)
+	}
+	total, err := svc.repo.CountItems(request)
+	if err != nil {
+		return common.Page[ItemResponse]{}, fmt.Errorf("error counting items of certification campaign %d: %w", request.Id, err)
+	}
+	page := common.Page[ItemResponse]{
+		Items:    make([]ItemResponse, 0, len(items)),
+		PageInfo: common.PageInfo{Total: total},
+	}
+	if len(items) > request.Limit {
+		items = items[:request.Limit]
+		last := items[len(items)-1]
+		page.NextCursor = request.Next(strconv.FormatInt(last.Id, 10), last.Id)
+	}
+	for _, item := range items {
+		page.Items = append(page.Items, item.toResponse())
+	}
+	return page, nil
+}
+
+// Certify подтвердить, что доступ по позиции нужен; решение принимает только рецензент позиции
+func (svc *Service) Certify(ctx context.Context, request DecisionRequest) (ItemResponse, error) {
+	return svc.decide(ctx, request, DecisionCertified)
+}
+
+// Revoke отозвать роль по позиции в той же транзакции, что и решение; если роль уже отозвана
+// другим способом, решение всё равно сохраняется
+func (svc *Service) Revoke(ctx context.Context, request DecisionRequest) (ItemResponse, error) {
+	return svc.decide(ctx, request, DecisionRevoked)
+}
+
+func (svc *Service) decide(ctx context.Context, request DecisionRequest, decision string) (ItemResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return ItemResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	actor := common.ActorFrom(ctx)
+	var updated ItemEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "deciding certification item", func(tx *sqlx.Tx) error {
+		now := time.Now()
+		campaign, err := svc.findActiveTx(tx, request.CampaignId, now)
+		if err != nil {
+			return err
+		}
+		found, err := svc.repo.FindItemsTx(tx, request.CampaignId, []int64{request.ItemId})
+		if err != nil {
+			return fmt.Errorf("error finding item %d of certification campaign %d: %w", request.ItemId, request.CampaignId, err)
+		}
+		if len(found) == 0 {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("certification campaign %d has no item %d", request.CampaignId, request.ItemId),
+			}
+		}
+		item := found[0]
+		if item.Reviewer != actor {
+			return common.ForbiddenError{
+				Message: fmt.Sprintf("%s is not the reviewer of certification item %d", actor, item.Id),
+			}
+		}
+		if item.Decision != nil {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("certification item %d is already %s", item.Id, *item.Decision),
+			}
+		}
+		if updated, err = svc.decideItemTx(ctx, tx, item, decision, actor, request.Comment, now); err != nil {
+			return err
+		}
+		undecided, err := svc.repo.FindUndecidedTx(tx, campaign.Id)
+		if err != nil {
+			return fmt.Errorf("error finding undecided items of certification campaign %d: %w", campaign.Id, err)
+		}
+		if len(undecided) == 0 {
+			return svc.completeTx(ctx, tx, campaign, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return ItemResponse{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// AssignReviewer передать позиции другому рецензенту. Рецензент не может пересматривать собственный доступ
+func (svc *Service) AssignReviewer(ctx context.Context, request ReviewerRequest) ([]ItemResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	var items []ItemEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "assigning certification reviewer", func(tx *sqlx.Tx) error {
+		if _, err := svc.findActiveTx(tx, request.Id, time.Now()); err != nil {
+			return err
+		}
+		if items, err = svc.repo.FindItemsTx(tx, request.Id, request.ItemIds); err != nil {
+			return fmt.Errorf("error finding items of certification campaign %d: %w", request.Id, err)
+		}
+		if len(items) != len(request.ItemIds) {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("certification campaign %d has no items with some of ids %v", request.Id, request.ItemIds),
+			}
+		}
+		for _, item := range items {
+			if item.Decision != nil {
+				return common.RequestValidationError{
+					Message: fmt.Sprintf("certification item %d is already %s", item.Id, *item.Decision),
+				}
+			}
+			if item.EmployeeLogin != nil && *item.EmployeeLogin == request.Reviewer {
+				return common.RequestValidationError{
+					Message: fmt.Sprintf("%s cannot review own access in certification item %d", request.Reviewer, item.Id),
+				}
+			}
+		}
+		if err := svc.repo.UpdateReviewerTx(tx, request.ItemIds, request.Reviewer); err != nil {
+			return fmt.Errorf("error updating reviewer of certification campaign %d: %w", request.Id, err)
+		}
+		for i := range items {
+			before := items[i]
+			items[i].Reviewer = request.Reviewer
+			err := svc.auditor.RecordTx(ctx, tx, audit.Event{
+				Action:     auditActionAssignReviewer,
+				EntityType: itemAuditEntityType,
+				EntityId:   before.Id,
+				Before:     before.auditSnapshot(),
+				After:      items[i].auditSnapshot(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]ItemResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, item.toResponse())
+	}
+	return responses, nil
+}
+
+// Close завершить кампании, срок которых наступил к моменту now. Позиции без решения отзываются,
+// если так задано в кампании, иначе остаются без решения. Каждая кампания завершается в своей
+// транзакции: ошибка в одной не мешает остальным. Возвращает число завершённых кампаний
+func (svc *Service) Close(ctx context.Context, now time.Time) (int, error) {
+	overdue, err := svc.repo.FindOverdue(now)
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving overdue certification campaigns: %w", err)
+	}
+	closed := 0
+	var errs []error
+	for _, campaign := range overdue {
+		err = database.InTransaction(svc.repo.BeginTransaction, "closing certification campaign", func(tx *sqlx.Tx) error {
+			return svc.closeTx(ctx, tx, campaign.Id, now)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("certification campaign %d: %w", campaign.Id, err))
+			continue
+		}
+		closed++
+	}
+	return closed, errors.Join(errs...)
+}
+
+// Report отчёт кампании в CSV с подписью ключом IDM. Отчёт активной кампании - промежуточный:
+// её состояние указано в подписи
+func (svc *Service) Report(request IdRequest) (Report, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Report{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if svc.signer == nil {
+		return Report{}, common.PreconditionFailedError{Message: "report signing keys are not configured"}
+	}
+	campaign, err := svc.findCampaign(request.Id)
+	if err != nil {
+		return Report{}, err
+	}
+	items, err := svc.repo.FindAllItems(campaign.Id)
+	if err != nil {
+		return Report{}, fmt.Errorf("error retrieving items of certification campaign %d: %w", campaign.Id, err)
+	}
+	content, err := writeCsv(campaign, items)
+	if err != nil {
+		return Report{}, fmt.Errorf("error writing report of certification campaign %d: %w", campaign.Id, err)
+	}
+	sum := sha256.Sum256(content)
+	now := time.Now()
+	signature, err := svc.signer.Sign(reportClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   svc.settings.Issuer,
+			Subject:  auditEntityType + ":" + strconv.FormatInt(campaign.Id, 10),
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+		ReportSha256: hex.EncodeToString(sum[:]),
+		Status:       campaign.Status,
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("error signing report of certification campaign %d: %w", campaign.Id, err)
+	}
+	return Report{
+		Filename:  fmt.Sprintf("certification-campaign-%d.csv", campaign.Id),
+		Content:   content,
+		Signature: signature,
+	}, nil
+}
+
+// closeTx завершить кампанию после срока; кампанию, уже завершённую параллельно, пропустить
+func (svc *Service) closeTx(ctx context.Context, tx *sqlx.Tx, id int64, now time.Time) error {
+	campaign, err := svc.repo.FindByIdForUpdateTx(tx, id)
+	if err != nil {
+		return fmt.Errorf("error finding certification campaign with id %d: %w", id, err)
+	}
+	if campaign.Status != StatusActive {
+		return nil
+	}
+	if campaign.AutoRevoke {
+		undecided, err := svc.repo.FindUndecidedTx(tx, campaign.Id)
+		if err != nil {
+			return fmt.Errorf("error finding undecided items of certification campaign %d: %w", campaign.Id, err)
+		}
+		actor := common.ActorFrom(ctx)
+		for _, item := range undecided {
+			if _, err = svc.decideItemTx(ctx, tx, item, DecisionRevoked, actor, deadlineComment, now); err != nil {
+				return err
+			}
+		}
+	}
+	return svc.completeTx(ctx, tx, campaign, now)
+}
+
+// decideItemTx сохранить решение по позиции и, если роль отзывается, отозвать её
+func (svc *Service) decideItemTx(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	item ItemEntity,
+	decision, actor, comment string,
+	now time.Time,
+) (ItemEntity, error) {
+	if decision == DecisionRevoked {
+		err := svc.revoker.RevokeTx(ctx, tx, assignment.RevokeRequest{EmployeeId: item.EmployeeId, RoleId: item.RoleId})
+		if err != nil && !errors.As(err, &common.NotFoundError{}) {
+			return ItemEntity{}, err
+		}
+	}
+	decided := item
+	decided.Decision = &decision
+	decided.DecidedBy = &actor
+	decided.DecidedAt = &now
+	if comment != "" {
+		decided.Comment = &comment
+	}
+	updated, err := svc.repo.DecideItemTx(tx, decided)
+	if err != nil {
+		return ItemEntity{}, fmt.Errorf("error saving decision on certification item %d: %w", item.Id, err)
+	}
+	action := auditActionCertify
+	if decision == DecisionRevoked {
+		action = auditActionRevoke
+	}
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     action,
+		EntityType: itemAuditEntityType,
+		EntityId:   item.Id,
+		Before:     item.auditSnapshot(),
+		After:      updated.auditSnapshot(),
+	})
+	if err != nil {
+		return ItemEntity{}, err
+	}
+	return updated, nil
+}
+
+func (svc *Service) completeTx(ctx context.Context, tx *sqlx.Tx, campaign Entity, now time.Time) error {
+	completed, err := svc.repo.CompleteTx(tx, campaign.Id, now)
+	if err != nil {
+		return fmt.Errorf("error completing certification campaign %d: %w", campaign.Id, err)
+	}
+	return svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     auditActionComplete,
+		EntityType: auditEntityType,
+		EntityId:   campaign.Id,
+		Before:     campaign.auditSnapshot(0),
+		After:      completed.auditSnapshot(0),
+	})
+}
+
+// findActiveTx найти и заблокировать кампанию, в которой ещё можно принимать решения
+func (svc *Service) findActiveTx(tx *sqlx.Tx, id int64, now time.Time) (Entity, error) {
+	campaign, err := svc.repo.FindByIdForUpdateTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("certification campaign with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding certification campaign with id %d: %w", id, err)
+	}
+	if campaign.Status != StatusActive {
+		return Entity{}, common.RequestValidationError{
+			Message: fmt.Sprintf("certification campaign %d is already %s", id, campaign.Status),
+		}
+	}
+	if !campaign.Deadline.After(now) {
+		return Entity{}, common.RequestValidationError{
+			Message: fmt.Sprintf("certification campaign %d is past its deadline", id),
+		}
+	}
+	return campaign, nil
+}
+
+func (svc *Service) findCampaign(id int64) (Entity, error) {
+	campaign, err := svc.repo.FindById(id)
+	if err != nil {
+		return Entity{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding certification campaign with id %d: %v", id, err),
+		}
+	}
+	return campaign, nil
+}
+
+// toResponses кампании вместе с их ходом
+func (svc *Service) toResponses(entities []Entity) ([]Response, error) {
+	responses := make([]Response, 0, len(entities))
+	if len(entities) == 0 {
+		return responses, nil
+	}
+	ids := make([]int64, 0, len(entities))
+	for _, e := range entities {
+		ids = append(ids, e.Id)
+	}
+	progress, err := svc.repo.FindProgress(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving progress of certification campaigns: %w", err)
+	}
+	byCampaign := make(map[int64]ProgressEntity, len(progress))
+	for _, p := range progress {
+		byCampaign[p.CampaignId] = p
+	}
+	for _, e := range entities {
+		responses = append(responses, e.toResponse(byCampaign[e.Id]))
+	}
+	return responses, nil
+}
+
+// assignReviewers распределить позиции между рецензентами по очереди. Свой доступ рецензент
+// не пересматривает: его позиция достаётся следующему рецензенту
+func assignReviewers(items []ItemEntity, reviewers []string) error {
+	next := 0
+	for i := range items {
+		for tried := 0; items[i].Reviewer == ""; tried++ {
+			if tried == len(reviewers) {
+				return common.RequestValidationError{
+					Message: fmt.Sprintf("no reviewer other than the employee %d to review own access", items[i].EmployeeId),
+				}
+			}
+			reviewer := reviewers[next%len(reviewers)]
+			next++
+			if items[i].EmployeeLogin == nil || *items[i].EmployeeLogin != reviewer {
+				items[i].Reviewer = reviewer
+			}
+		}
+	}
+	return nil
+}
+
+// writeCsv отчёт кампании: одна строка на позицию, время в RFC 3339 UTC, пустые значения - пустые ячейки
+func writeCsv(campaign Entity, items []ItemEntity) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		record := []string{
+			strconv.FormatInt(campaign.Id, 10),
+			campaign.Name,
+			strconv.FormatInt(item.Id, 10),
+			strconv.FormatInt(item.EmployeeId, 10),
+			item.EmployeeName,
+			orEmpty(item.EmployeeLogin),
+			strconv.FormatInt(item.RoleId, 10),
+			item.RoleName,
+			formatTime(&item.ValidFrom),
+			formatTime(item.ValidTo),
+			item.Reviewer,
+			item.decisionOf(),
+			orEmpty(item.DecidedBy),
+			formatTime(item.DecidedAt),
+			orEmpty(item.Comment),
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func orEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
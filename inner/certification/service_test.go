@@ -0,0 +1,692 @@
+package certification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/orgunit"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	if fn, ok := args.Get(0).(func(*sqlx.Tx, Entity) (Entity, error)); ok {
+		return fn(tx, e)
+	}
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindSourcesTx(tx *sqlx.Tx, roleIds []int64, orgUnitId *int64, at time.Time) ([]ItemEntity, error) {
+	args := m.Called(tx, roleIds, orgUnitId)
+	return args.Get(0).([]ItemEntity), args.Error(1)
+}
+
+func (m *MockRepo) SaveItemsTx(tx *sqlx.Tx, campaignId int64, items []ItemEntity) error {
+	args := m.Called(tx, campaignId, items)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindOverdue(now time.Time) ([]Entity, error) {
+	args := m.Called(now)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+// CompleteTx возвращает завершённую кампанию, как update ... returning *
+func (m *MockRepo) CompleteTx(tx *sqlx.Tx, id int64, at time.Time) (Entity, error) {
+	args := m.Called(tx, id)
+	return Entity{Id: id, Status: StatusCompleted, CompletedAt: &at}, args.Error(0)
+}
+
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) FindProgress(campaignIds []int64) ([]ProgressEntity, error) {
+	args := m.Called(campaignIds)
+	return args.Get(0).([]ProgressEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindItems(request ItemListRequest, after *common.Cursor) ([]ItemEntity, error) {
+	args := m.Called(request, after)
+	return args.Get(0).([]ItemEntity), args.Error(1)
+}
+
+func (m *MockRepo) CountItems(request ItemListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) FindAllItems(campaignId int64) ([]ItemEntity, error) {
+	args := m.Called(campaignId)
+	return args.Get(0).([]ItemEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindItemsTx(tx *sqlx.Tx, campaignId int64, itemIds []int64) ([]ItemEntity, error) {
+	args := m.Called(tx, campaignId, itemIds)
+	return args.Get(0).([]ItemEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindUndecidedTx(tx *sqlx.Tx, campaignId int64) ([]ItemEntity, error) {
+	args := m.Called(tx, campaignId)
+	return args.Get(0).([]ItemEntity), args.Error(1)
+}
+
+// DecideItemTx возвращает сохранённую позицию как есть, как update ... returning *
+func (m *MockRepo) DecideItemTx(tx *sqlx.Tx, e ItemEntity) (ItemEntity, error) {
+	args := m.Called(tx, e.Id, *e.Decision)
+	return e, args.Error(0)
+}
+
+func (m *MockRepo) UpdateReviewerTx(tx *sqlx.Tx, itemIds []int64, reviewer string) error {
+	args := m.Called(tx, itemIds, reviewer)
+	return args.Error(0)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+type MockOrgUnitRepo struct {
+	mock.Mock
+}
+
+func (m *MockOrgUnitRepo) FindById(id int64) (orgunit.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(orgunit.Entity), args.Error(1)
+}
+
+type MockRevoker struct {
+	mock.Mock
+}
+
+func (m *MockRevoker) RevokeTx(ctx context.Context, tx *sqlx.Tx, request assignment.RevokeRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+// StubSigner запоминает подписанные claims и возвращает фиксированную подпись
+type StubSigner struct {
+	claims []jwt.Claims
+}
+
+func (s *StubSigner) Sign(claims jwt.Claims) (string, error) {
+	s.claims = append(s.claims, claims)
+	return "signed", nil
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+var settings = Settings{AutoRevoke: true, Issuer: "https://idm.example.com"}
+
+func login(value string) *string {
+	return &value
+}
+
+func decision(value string) *string {
+	return &value
+}
+
+func asActor(actor string) context.Context {
+	return common.WithActor(context.Background(), actor)
+}
+
+func active(id int64) Entity {
+	return Entity{
+		Id:         id,
+		Name:       "Q3 review",
+		RoleIds:    []int64{2},
+		Status:     StatusActive,
+		Deadline:   time.Now().Add(time.Hour),
+		AutoRevoke: true,
+	}
+}
+
+func item(id int64, reviewer string) ItemEntity {
+	return ItemEntity{
+		Id:           id,
+		CampaignId:   5,
+		AssignmentId: 100 + id,
+		EmployeeId:   id,
+		EmployeeName: "Employee",
+		RoleId:       2,
+		RoleName:     "devs",
+		Reviewer:     reviewer,
+	}
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should snapshot assignments and distribute them between reviewers", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roles, new(MockOrgUnitRepo), new(MockRevoker), auditor, nil, settings, validator.New())
+
+		deadline := time.Now().Add(24 * time.Hour)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindSourcesTx", noTx, []int64{2}, (*int64)(nil)).Return([]ItemEntity{
+			{AssignmentId: 10, EmployeeId: 1, EmployeeLogin: login("bob")},
+			{AssignmentId: 11, EmployeeId: 2, EmployeeLogin: login("dave")},
+			{AssignmentId: 12, EmployeeId: 3},
+		}, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			e.Id = 5
+			e.Status = StatusActive
+			stored = e
+			return e, nil
+		})
+		var items []ItemEntity
+		repo.On("SaveItemsTx", noTx, int64(5), mock.Anything).Run(func(args mock.Arguments) {
+			items = args.Get(2).([]ItemEntity)
+		}).Return(nil)
+
+		response, err := svc.Create(asActor("alice"), CreateRequest{
+			Name:      "Q3 review",
+			RoleIds:   []int64{2},
+			Reviewers: []string{"bob", "carol"},
+			Deadline:  deadline,
+		})
+		a.NoError(err)
+		a.Equal(int64(5), response.Id)
+		a.Equal(int64(3), response.Progress.Total)
+		a.Equal(int64(3), response.Progress.Pending)
+		a.Equal("alice", stored.CreatedBy)
+		a.True(stored.AutoRevoke)
+		a.Equal([]string{"carol", "bob", "carol"}, []string{items[0].Reviewer, items[1].Reviewer, items[2].Reviewer})
+		a.Len(auditor.events, 1)
+		a.Equal(3, auditor.events[0].After.(auditSnapshot).Items)
+	})
+
+	t.Run("should keep undecided access when campaign disables auto revoke", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindSourcesTx", noTx, []int64{2}, (*int64)(nil)).Return([]ItemEntity{{AssignmentId: 10, EmployeeId: 1}}, nil)
+		repo.On("SaveTx", noTx, mock.MatchedBy(func(e Entity) bool { return !e.AutoRevoke })).Return(active(5), nil)
+		repo.On("SaveItemsTx", noTx, int64(5), mock.Anything).Return(nil)
+
+		keep := false
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{2}, Reviewers: []string{"bob"}, Deadline: time.Now().Add(time.Hour), AutoRevoke: &keep,
+		})
+		a.NoError(err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should return validation error when only reviewer holds the role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindSourcesTx", noTx, []int64{2}, (*int64)(nil)).
+			Return([]ItemEntity{{AssignmentId: 10, EmployeeId: 1, EmployeeLogin: login("bob")}}, nil)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{2}, Reviewers: []string{"bob"}, Deadline: time.Now().Add(time.Hour),
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return validation error when roles have no assignments", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindSourcesTx", noTx, []int64{2}, (*int64)(nil)).Return([]ItemEntity(nil), nil)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{2}, Reviewers: []string{"bob"}, Deadline: time.Now().Add(time.Hour),
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should limit campaign to org unit", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		units := new(MockOrgUnitRepo)
+		svc := NewService(repo, roles, units, new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		unitId := int64(8)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		units.On("FindById", unitId).Return(orgunit.Entity{Id: unitId, Name: "Sales"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindSourcesTx", noTx, []int64{2}, &unitId).Return([]ItemEntity{{AssignmentId: 10, EmployeeId: 1}}, nil)
+		repo.On("SaveTx", noTx, mock.MatchedBy(func(e Entity) bool { return *e.OrgUnitId == unitId })).Return(active(5), nil)
+		repo.On("SaveItemsTx", noTx, int64(5), mock.Anything).Return(nil)
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Sales review", RoleIds: []int64{2}, OrgUnitId: &unitId, Reviewers: []string{"bob"},
+			Deadline: time.Now().Add(time.Hour),
+		})
+		a.NoError(err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("should return not found error for unknown org unit", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		units := new(MockOrgUnitRepo)
+		svc := NewService(repo, roles, units, new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		unitId := int64(8)
+		roles.On("FindById", int64(2)).Return(role.Entity{Id: 2}, nil)
+		units.On("FindById", unitId).Return(orgunit.Entity{}, errors.New("no rows"))
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Sales review", RoleIds: []int64{2}, OrgUnitId: &unitId, Reviewers: []string{"bob"},
+			Deadline: time.Now().Add(time.Hour),
+		})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return validation error for past deadline", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{2}, Reviewers: []string{"bob"}, Deadline: time.Now().Add(-time.Hour),
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return not found for unknown role", func(t *testing.T) {
+		roles := new(MockRoleRepo)
+		svc := NewService(new(MockRepo), roles, new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		roles.On("FindById", int64(2)).Return(role.Entity{}, errors.New("sql: no rows in result set"))
+
+		_, err := svc.Create(asActor("alice"), CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{2}, Reviewers: []string{"bob"}, Deadline: time.Now().Add(time.Hour),
+		})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceDecide(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should certify item and keep campaign active", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, auditor, nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{item(1, "bob")}, nil)
+		repo.On("DecideItemTx", noTx, int64(1), DecisionCertified).Return(nil)
+		repo.On("FindUndecidedTx", noTx, int64(5)).Return([]ItemEntity{item(2, "carol")}, nil)
+
+		response, err := svc.Certify(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1, Comment: "still needed"})
+		a.NoError(err)
+		a.Equal(DecisionCertified, *response.Decision)
+		a.Equal("bob", *response.DecidedBy)
+		a.Equal("still needed", *response.Comment)
+		a.True(revoker.AssertNotCalled(t, "RevokeTx", mock.Anything))
+		a.True(repo.AssertNotCalled(t, "CompleteTx", mock.Anything, mock.Anything))
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionCertify, auditor.events[0].Action)
+		a.Equal(itemAuditEntityType, auditor.events[0].EntityType)
+	})
+
+	t.Run("should revoke role and complete campaign on last decision", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, auditor, nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{item(1, "bob")}, nil)
+		revoker.On("RevokeTx", assignment.RevokeRequest{EmployeeId: 1, RoleId: 2}).Return(nil)
+		repo.On("DecideItemTx", noTx, int64(1), DecisionRevoked).Return(nil)
+		repo.On("FindUndecidedTx", noTx, int64(5)).Return([]ItemEntity(nil), nil)
+		repo.On("CompleteTx", noTx, int64(5)).Return(nil)
+
+		response, err := svc.Revoke(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.NoError(err)
+		a.Equal(DecisionRevoked, *response.Decision)
+		a.Nil(response.Comment)
+		revoker.AssertExpectations(t)
+		a.Len(auditor.events, 2)
+		a.Equal(auditActionComplete, auditor.events[1].Action)
+		a.Equal(StatusCompleted, auditor.events[1].After.(auditSnapshot).Status)
+	})
+
+	t.Run("should save revocation when role is already revoked", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{item(1, "bob")}, nil)
+		revoker.On("RevokeTx", mock.Anything).
+			Return(common.NotFoundError{Message: "employee 1 has no active assignment of role 2"})
+		repo.On("DecideItemTx", noTx, int64(1), DecisionRevoked).Return(nil)
+		repo.On("FindUndecidedTx", noTx, int64(5)).Return([]ItemEntity{item(2, "carol")}, nil)
+
+		_, err := svc.Revoke(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.NoError(err)
+	})
+
+	t.Run("should return revoke error", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{item(1, "bob")}, nil)
+		revoker.On("RevokeTx", mock.Anything).Return(errors.New("database is down"))
+
+		_, err := svc.Revoke(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.ErrorContains(err, "database is down")
+		a.True(repo.AssertNotCalled(t, "DecideItemTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return forbidden for actor who is not reviewer", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{item(1, "bob")}, nil)
+
+		_, err := svc.Certify(asActor("mallory"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.ErrorAs(err, &common.ForbiddenError{})
+	})
+
+	t.Run("should return validation error for decided item", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		decided := item(1, "bob")
+		decided.Decision = decision(DecisionCertified)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{decided}, nil)
+
+		_, err := svc.Revoke(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should return validation error after deadline", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		overdue := active(5)
+		overdue.Deadline = time.Now().Add(-time.Minute)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(overdue, nil)
+
+		_, err := svc.Certify(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 1})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should return not found for item of another campaign", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{9}).Return([]ItemEntity(nil), nil)
+
+		_, err := svc.Certify(asActor("bob"), DecisionRequest{CampaignId: 5, ItemId: 9})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceAssignReviewer(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should reassign undecided items", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), auditor, nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1, 2}).
+			Return([]ItemEntity{item(1, "bob"), item(2, "carol")}, nil)
+		repo.On("UpdateReviewerTx", noTx, []int64{1, 2}, "dave").Return(nil)
+
+		responses, err := svc.AssignReviewer(asActor("alice"), ReviewerRequest{Id: 5, ItemIds: []int64{1, 2}, Reviewer: "dave"})
+		a.NoError(err)
+		a.Len(responses, 2)
+		a.Equal("dave", responses[1].Reviewer)
+		a.Len(auditor.events, 2)
+		a.Equal("carol", auditor.events[1].Before.(itemAuditSnapshot).Reviewer)
+	})
+
+	t.Run("should return validation error when reviewer holds the access", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		own := item(1, "bob")
+		own.EmployeeLogin = login("dave")
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1}).Return([]ItemEntity{own}, nil)
+
+		_, err := svc.AssignReviewer(asActor("alice"), ReviewerRequest{Id: 5, ItemIds: []int64{1}, Reviewer: "dave"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "UpdateReviewerTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return not found when some items are missing", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(active(5), nil)
+		repo.On("FindItemsTx", noTx, int64(5), []int64{1, 9}).Return([]ItemEntity{item(1, "bob")}, nil)
+
+		_, err := svc.AssignReviewer(asActor("alice"), ReviewerRequest{Id: 5, ItemIds: []int64{1, 9}, Reviewer: "dave"})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceClose(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should revoke undecided items and complete overdue campaign", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, auditor, nil, settings, validator.New())
+
+		now := time.Now()
+		overdue := active(5)
+		overdue.Deadline = now.Add(-time.Minute)
+		repo.On("FindOverdue", now).Return([]Entity{overdue}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(overdue, nil)
+		repo.On("FindUndecidedTx", noTx, int64(5)).Return([]ItemEntity{item(1, "bob")}, nil)
+		revoker.On("RevokeTx", assignment.RevokeRequest{EmployeeId: 1, RoleId: 2}).Return(nil)
+		repo.On("DecideItemTx", noTx, int64(1), DecisionRevoked).Return(nil)
+		repo.On("CompleteTx", noTx, int64(5)).Return(nil)
+
+		closed, err := svc.Close(asActor(closeActor), now)
+		a.NoError(err)
+		a.Equal(1, closed)
+		a.Len(auditor.events, 2)
+		revoked := auditor.events[0].After.(itemAuditSnapshot)
+		a.Equal(closeActor, *revoked.DecidedBy)
+		a.Equal(auditActionComplete, auditor.events[1].Action)
+	})
+
+	t.Run("should keep undecided items when auto revoke is off", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(MockRevoker)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), revoker, new(StubAuditor), nil, settings, validator.New())
+
+		now := time.Now()
+		overdue := active(5)
+		overdue.AutoRevoke = false
+		repo.On("FindOverdue", now).Return([]Entity{overdue}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(overdue, nil)
+		repo.On("CompleteTx", noTx, int64(5)).Return(nil)
+
+		closed, err := svc.Close(asActor(closeActor), now)
+		a.NoError(err)
+		a.Equal(1, closed)
+		a.True(repo.AssertNotCalled(t, "FindUndecidedTx", mock.Anything, mock.Anything))
+		a.True(revoker.AssertNotCalled(t, "RevokeTx", mock.Anything))
+	})
+
+	t.Run("should close other campaigns when one fails", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		now := time.Now()
+		first, second := active(5), active(6)
+		first.AutoRevoke, second.AutoRevoke = false, false
+		repo.On("FindOverdue", now).Return([]Entity{first, second}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(5)).Return(Entity{}, errors.New("lock timeout"))
+		repo.On("FindByIdForUpdateTx", noTx, int64(6)).Return(second, nil)
+		repo.On("CompleteTx", noTx, int64(6)).Return(nil)
+
+		closed, err := svc.Close(asActor(closeActor), now)
+		a.ErrorContains(err, "lock timeout")
+		a.Equal(1, closed)
+	})
+}
+
+func TestServiceReport(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should sign csv report with its digest", func(t *testing.T) {
+		repo := new(MockRepo)
+		signer := new(StubSigner)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), signer, settings, validator.New())
+
+		decidedAt := time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC)
+		revoked := item(2, "bob")
+		revoked.Decision = decision(DecisionRevoked)
+		revoked.DecidedBy = login("bob")
+		revoked.DecidedAt = &decidedAt
+		revoked.Comment = login("left the team, moved to ops")
+		repo.On("FindById", int64(5)).Return(active(5), nil)
+		repo.On("FindAllItems", int64(5)).Return([]ItemEntity{item(1, "bob"), revoked}, nil)
+
+		report, err := svc.Report(IdRequest{Id: 5})
+		a.NoError(err)
+		a.Equal("certification-campaign-5.csv", report.Filename)
+		a.Equal("signed", report.Signature)
+
+		records, err := csv.NewReader(strings.NewReader(string(report.Content))).ReadAll()
+		a.NoError(err)
+		a.Len(records, 3)
+		a.Equal(csvHeader, records[0])
+		a.Equal(DecisionPending, records[1][11])
+		a.Equal([]string{DecisionRevoked, "bob", "2025-09-01T10:00:00Z", "left the team, moved to ops"}, records[2][11:])
+
+		sum := sha256.Sum256(report.Content)
+		claims := signer.claims[0].(reportClaims)
+		a.Equal(hex.EncodeToString(sum[:]), claims.ReportSha256)
+		a.Equal("certification_campaign:5", claims.Subject)
+		a.Equal(settings.Issuer, claims.Issuer)
+		a.Equal(StatusActive, claims.Status)
+	})
+
+	t.Run("should return precondition failed without signing keys", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		_, err := svc.Report(IdRequest{Id: 5})
+		a.ErrorAs(err, &common.PreconditionFailedError{})
+		a.True(repo.AssertNotCalled(t, "FindById", mock.Anything))
+	})
+}
+
+func TestServiceFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should attach progress to campaigns", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(MockRevoker), new(StubAuditor), nil, settings, validator.New())
+
+		repo.On("FindPage", mock.Anything, (*common.Cursor)(nil)).Return([]Entity{active(6), active(5)}, nil)
+		repo.On("Count", mock.Anything).Return(int64(2), nil)
+		repo.On("FindProgress", []int64{6, 5}).
+			Return([]ProgressEntity{{CampaignId: 5, Total: 4, Certified: 1, Revoked: 2}}, nil)
+
+		page, err := svc.FindAll(ListRequest{})
+		a.NoError(err)
+		a.Len(page.Items, 2)
+		a.Equal(ProgressResponse{}, page.Items[0].Progress)
+		a.Equal(ProgressResponse{Total: 4, Certified: 1, Revoked: 2, Pending: 1}, page.Items[1].Progress)
+	})
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config общая конфигурация всего приложения
@@ -18,6 +21,75 @@ type Config struct {
 	AppVersion     string `validate:"required"`
 	LogLevel       string
 	LogDevelopMode bool
+	// PurgeRetention сколько хранить мягко удалённые записи; 0 отключает очистку
+	PurgeRetention time.Duration
+	// PurgeInterval как часто запускать очистку
+	PurgeInterval time.Duration
+	// AuthJwksFile файл JWKS с открытыми ключами для проверки токенов доступа
+	AuthJwksFile string
+	// AuthJwksUrl адрес JWKS; используется, если AuthJwksFile не задан
+	AuthJwksUrl string
+	// AuthJwksRefresh как часто перечитывать JWKS, чтобы подхватить новые ключи
+	AuthJwksRefresh time.Duration
+	// AuthIssuer ожидаемый издатель токенов (claim iss)
+	AuthIssuer string
+	// AuthAudience ожидаемый получатель токенов (claim aud)
+	AuthAudience string
+	// AuthAdmins субъекты токенов, которым разрешено всё; нужны, чтобы выдать первые роли
+	AuthAdmins []string
+	// OAuthSigningKeysDir каталог с закрытыми ключами (*.pem), которыми IDM подписывает свои токены;
+	// пустое значение отключает выдачу токенов
+	OAuthSigningKeysDir string
+	// OAuthIssuer издатель токенов IDM (claim iss); должен отличаться от AuthIssuer
+	OAuthIssuer string
+	// OAuthTokenTtl срок жизни токенов IDM
+	OAuthTokenTtl time.Duration
+	// LdapUrl адрес каталога LDAP для импорта (ldap:// или ldaps://); пустое значение отключает импорт
+	LdapUrl string
+	// LdapBindDn и LdapBindPassword учётная запись для чтения каталога; без LdapBindDn - анонимно
+	LdapBindDn       string
+	LdapBindPassword string
+	// LdapBaseDn и LdapFilter где и какие записи импортировать как сотрудников
+	LdapBaseDn string
+	LdapFilter string
+	// LdapPageSize размер страницы поиска (RFC 2696)
+	LdapPageSize int
+	// LdapNameAttr, LdapLoginAttr и LdapGroupsAttr атрибуты с именем, логином и группами сотрудника
+	LdapNameAttr   string
+	LdapLoginAttr  string
+	LdapGroupsAttr string
+	// LdapGroupRoles соответствие групп ролям: "группа=роль" через точку с запятой, где группа - её cn
+	// или полный DN; пустое значение - каждая группа становится ролью с именем из cn
+	LdapGroupRoles string
+	// ProvisioningConfig JSON-файл с коннекторами внешних систем; пустое значение отключает выгрузку
+	ProvisioningConfig string
+	// ProvisioningInterval как часто обработчик проверяет очередь выгрузки
+	ProvisioningInterval time.Duration
+	// ProvisioningMaxAttempts сколько раз выполнять операцию, прежде чем признать её неудавшейся
+	ProvisioningMaxAttempts int
+	// ProvisioningBackoff и ProvisioningMaxBackoff первая пауза перед повтором и её предел; пауза удваивается
+	ProvisioningBackoff    time.Duration
+	ProvisioningMaxBackoff time.Duration
+	// WebhookInterval как часто диспетчер рассылает события подписчикам
+	WebhookInterval time.Duration
+	// WebhookMaxAttempts сколько раз пытаться доставить событие, прежде чем отправить доставку в dead
+	WebhookMaxAttempts int
+	// WebhookBackoff и WebhookMaxBackoff первая пауза перед повторной доставкой и её предел; пауза удваивается
+	WebhookBackoff    time.Duration
+	WebhookMaxBackoff time.Duration
+	// WebhookTimeout сколько ждать ответа подписчика
+	WebhookTimeout time.Duration
+	// AccessRequestTtl сколько запрос доступа ждёт решения согласующих, прежде чем истечь
+	AccessRequestTtl time.Duration
+	// AccessRequestExpiryInterval как часто проверяются сроки запросов доступа
+	AccessRequestExpiryInterval time.Duration
+	// CertificationAutoRevoke отзывать ли после срока кампании роли, по которым рецензент не принял решения;
+	// кампания может задать это сама
+	CertificationAutoRevoke bool
+	// CertificationInterval как часто проверяются сроки кампаний пересмотра доступа
+	CertificationInterval time.Duration
+	// EmployeeLifecycleInterval как часто применяются переходы сотрудников, дата которых наступила
+	EmployeeLifecycleInterval time.Duration
 }
 
 // GetConfig получение конфигурации из .env файла или переменных окружения
@@ -27,12 +99,55 @@ func GetConfig(envFile string) Config {
 		log.Info("Error loading .env file: %v\n", zap.Error(err))
 	}
 	var cfg = Config{
-		DbDriverName:   os.Getenv("DB_DRIVER_NAME"),
-		Dsn:            os.Getenv("DB_DSN"),
-		AppName:        os.Getenv("APP_NAME"),
-		AppVersion:     os.Getenv("APP_VERSION"),
-		LogLevel:       os.Getenv("LOG_LEVEL"),
-		LogDevelopMode: os.Getenv("LOG_DEVELOP_MODE") == "true",
+		DbDriverName:    os.Getenv("DB_DRIVER_NAME"),
+		Dsn:             os.Getenv("DB_DSN"),
+		AppName:         os.Getenv("APP_NAME"),
+		AppVersion:      os.Getenv("APP_VERSION"),
+		LogLevel:        os.Getenv("LOG_LEVEL"),
+		LogDevelopMode:  os.Getenv("LOG_DEVELOP_MODE") == "true",
+		PurgeRetention:  durationEnv("PURGE_RETENTION", 0),
+		PurgeInterval:   durationEnv("PURGE_INTERVAL", 24*time.Hour),
+		AuthJwksFile:    os.Getenv("AUTH_JWKS_FILE"),
+		AuthJwksUrl:     os.Getenv("AUTH_JWKS_URL"),
+		AuthJwksRefresh: durationEnv("AUTH_JWKS_REFRESH", 10*time.Minute),
+		AuthIssuer:      os.Getenv("AUTH_ISSUER"),
+		AuthAudience:    os.Getenv("AUTH_AUDIENCE"),
+		AuthAdmins:      listEnv("AUTH_ADMINS"),
+
+		OAuthSigningKeysDir: os.Getenv("OAUTH_SIGNING_KEYS_DIR"),
+		OAuthIssuer:         os.Getenv("OAUTH_ISSUER"),
+		OAuthTokenTtl:       durationEnv("OAUTH_TOKEN_TTL", 15*time.Minute),
+
+		LdapUrl:          os.Getenv("LDAP_URL"),
+		LdapBindDn:       os.Getenv("LDAP_BIND_DN"),
+		LdapBindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		LdapBaseDn:       os.Getenv("LDAP_BASE_DN"),
+		LdapFilter:       stringEnv("LDAP_FILTER", "(objectClass=person)"),
+		LdapPageSize:     intEnv("LDAP_PAGE_SIZE", 500),
+		LdapNameAttr:     stringEnv("LDAP_NAME_ATTR", "cn"),
+		LdapLoginAttr:    stringEnv("LDAP_LOGIN_ATTR", "mail"),
+		LdapGroupsAttr:   stringEnv("LDAP_GROUPS_ATTR", "memberOf"),
+		LdapGroupRoles:   os.Getenv("LDAP_GROUP_ROLES"),
+
+		ProvisioningConfig:      os.Getenv("PROVISIONING_CONFIG"),
+		ProvisioningInterval:    durationEnv("PROVISIONING_INTERVAL", 10*time.Second),
+		ProvisioningMaxAttempts: intEnv("PROVISIONING_MAX_ATTEMPTS", 8),
+		ProvisioningBackoff:     durationEnv("PROVISIONING_BACKOFF", 30*time.Second),
+		ProvisioningMaxBackoff:  durationEnv("PROVISIONING_MAX_BACKOFF", time.Hour),
+
+		WebhookInterval:    durationEnv("WEBHOOK_INTERVAL", 5*time.Second),
+		WebhookMaxAttempts: intEnv("WEBHOOK_MAX_ATTEMPTS", 10),
+		WebhookBackoff:     durationEnv("WEBHOOK_BACKOFF", 30*time.Second),
+		WebhookMaxBackoff:  durationEnv("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+		WebhookTimeout:     durationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+
+		AccessRequestTtl:            durationEnv("ACCESS_REQUEST_TTL", 14*24*time.Hour),
+		AccessRequestExpiryInterval: durationEnv("ACCESS_REQUEST_EXPIRY_INTERVAL", time.Hour),
+
+		CertificationAutoRevoke: os.Getenv("CERTIFICATION_AUTO_REVOKE") != "false",
+		CertificationInterval:   durationEnv("CERTIFICATION_INTERVAL", time.Hour),
+
+		EmployeeLifecycleInterval: durationEnv("EMPLOYEE_LIFECYCLE_INTERVAL", time.Minute),
 	}
 	err = validator.New().Struct(cfg)
 	if err != nil {
@@ -43,3 +158,48 @@ func GetConfig(envFile string) Config {
 	}
 	return cfg
 }
+
+// durationEnv прочитать длительность в формате time.ParseDuration (например, 720h) или вернуть def
+func durationEnv(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		panic(fmt.Sprintf("config validation error: %s: %v", key, err))
+	}
+	return value
+}
+
+// intEnv прочитать целое число или вернуть def
+func intEnv(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		panic(fmt.Sprintf("config validation error: %s: %v", key, err))
+	}
+	return value
+}
+
+// stringEnv прочитать строку или вернуть def, если переменная не задана
+func stringEnv(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// listEnv прочитать список значений через запятую, пропуская пустые
+func listEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,45 @@
+package common
+
+import "context"
+
+type contextKey string
+
+const (
+	actorKey     contextKey = "actor"
+	requestIdKey contextKey = "request_id"
+	scopesKey    contextKey = "scopes"
+)
+
+// WithActor запомнить в контексте, кто выполняет запрос
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom кто выполняет запрос; пустая строка, если запрос не аутентифицирован
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithRequestId запомнить в контексте идентификатор HTTP-запроса
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+// RequestIdFrom идентификатор HTTP-запроса или пустая строка вне запроса
+func RequestIdFrom(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey).(string)
+	return requestId
+}
+
+// WithScopes ограничить запрос набором разрешений: так аутентифицируются ключи доступа,
+// права которых задаются самим ключом, а не ролями сотрудника
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// ScopesFrom разрешения, которыми ограничен запрос; ok == false, если запрос не ограничен ключом
+func ScopesFrom(ctx context.Context) (scopes []string, ok bool) {
+	scopes, ok = ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout формат календарной даты в API
+const DateLayout = "2006-01-02"
+
+// Date календарная дата без времени: в JSON - строка "2006-01-02", в базе - колонка date
+type Date struct {
+	time.Time
+}
+
+// ParseDate разобрать дату в формате DateLayout
+func ParseDate(value string) (Date, error) {
+	parsed, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return Date{Time: parsed}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid date %s, expected YYYY-MM-DD", data)
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Date) Scan(src any) error {
+	switch value := src.(type) {
+	case time.Time:
+		*d = Date{Time: time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)}
+		return nil
+	case string:
+		parsed, err := ParseDate(value)
+		*d = parsed
+		return err
+	case []byte:
+		parsed, err := ParseDate(string(value))
+		*d = parsed
+		return err
+	}
+	return fmt.Errorf("cannot scan %T into date", src)
+}
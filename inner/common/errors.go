@@ -23,3 +23,30 @@ type NotFoundError struct {
 func (err NotFoundError) Error() string {
 	return err.Message
 }
+
+// PreconditionFailedError ресурс изменился с момента, указанного клиентом в If-Match
+type PreconditionFailedError struct {
+	Message string
+}
+
+func (err PreconditionFailedError) Error() string {
+	return err.Message
+}
+
+// ForbiddenError у автора запроса есть разрешение на маршрут, но не на это действие с этим ресурсом
+type ForbiddenError struct {
+	Message string
+}
+
+func (err ForbiddenError) Error() string {
+	return err.Message
+}
+
+// SodConflictError назначение роли нарушает правило разделения обязанностей
+type SodConflictError struct {
+	Message string
+}
+
+func (err SodConflictError) Error() string {
+	return err.Message
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETag построить сильный ETag из времени последнего изменения ресурса.
+// Postgres хранит timestamptz с точностью до микросекунд, поэтому берём именно их.
+func ETag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 10) + `"`
+}
+
+// ParseIfMatch разобрать заголовок If-Match в ожидаемое время последнего изменения ресурса.
+// Пустой заголовок и "*" означают отсутствие условия, тогда возвращается nil.
+func ParseIfMatch(header string) (*time.Time, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return nil, errors.New("invalid If-Match header")
+	}
+	micros, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid If-Match header")
+	}
+	version := time.UnixMicro(micros)
+	return &version, nil
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Match фрагмент текста, совпавший с поисковым запросом: [Start, End) в символах (рунах) исходной строки
+type Match struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// similarityThreshold минимальная схожесть слова с опечаткой, при которой оно подсвечивается целиком;
+// совпадает с порогом pg_trgm.similarity_threshold по умолчанию
+const similarityThreshold = 0.3
+
+// foldRune привести символ к виду, в котором сравнивает поиск: нижний регистр, без диакритики, ё как е.
+// Повторяет функцию normalize_name в базе данных: у кириллицы снимается только ё, чтобы й не превращалась в и.
+func foldRune(r rune) string {
+	r = unicode.ToLower(r)
+	if r == 'ё' {
+		return "е"
+	}
+	if unicode.Is(unicode.Cyrillic, r) {
+		return string(r)
+	}
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// folded нормализованная строка и для каждой её руны индекс руны исходной строки
+type folded struct {
+	runes  []rune
+	origin []int
+}
+
+func fold(text string) folded {
+	var f folded
+	for i, r := range []rune(text) {
+		for _, d := range foldRune(r) {
+			f.runes = append(f.runes, d)
+			f.origin = append(f.origin, i)
+		}
+	}
+	return f
+}
+
+// Highlight найти в text фрагменты, совпавшие со словами query. Слово запроса подсвечивается
+// везде, где оно входит в text как подстрока; если таких вхождений нет (опечатка), подсвечиваются
+// слова text, похожие на него по триграммам. Пересекающиеся фрагменты объединяются.
+func Highlight(text, query string) []Match {
+	source := fold(text)
+	var matches []Match
+	for _, word := range strings.FieldsFunc(query, isSeparator) {
+		needle := fold(word).runes
+		if len(needle) == 0 {
+			continue
+		}
+		found := false
+		for i := 0; i+len(needle) <= len(source.runes); i++ {
+			if equalRunes(source.runes[i:i+len(needle)], needle) {
+				matches = append(matches, source.match(i, i+len(needle)))
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		for _, w := range source.words() {
+			if trigramSimilarity(source.runes[w.Start:w.End], needle) >= similarityThreshold {
+				matches = append(matches, source.match(w.Start, w.End))
+			}
+		}
+	}
+	return mergeMatches(matches)
+}
+
+// match перевести границы в нормализованной строке в границы исходной
+func (f folded) match(start, end int) Match {
+	return Match{Start: f.origin[start], End: f.origin[end-1] + 1}
+}
+
+// words границы слов нормализованной строки
+func (f folded) words() []Match {
+	var words []Match
+	start := -1
+	for i, r := range f.runes {
+		if isSeparator(r) {
+			if start >= 0 {
+				words = append(words, Match{Start: start, End: i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, Match{Start: start, End: len(f.runes)})
+	}
+	return words
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// trigramSimilarity схожесть слов так же, как её считает pg_trgm: доля общих триграмм слов,
+// дополненных двумя пробелами в начале и одним в конце
+func trigramSimilarity(a, b []rune) float64 {
+	left, right := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range left {
+		if right[t] {
+			shared++
+		}
+	}
+	total := len(left) + len(right) - shared
+	if total == 0 {
+		return 0
+	}
+	return float64(shared) / float64(total)
+}
+
+func trigrams(word []rune) map[string]bool {
+	padded := append(append([]rune("  "), word...), ' ')
+	result := make(map[string]bool, len(padded))
+	for i := 0; i+3 <= len(padded); i++ {
+		result[string(padded[i:i+3])] = true
+	}
+	return result
+}
+
+func mergeMatches(matches []Match) []Match {
+	if len(matches) == 0 {
+		return []Match{}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	merged := []Match{matches[0]}
+	for _, m := range matches[1:] {
+		last := &merged[len(merged)-1]
+		if m.Start <= last.End {
+			last.End = max(last.End, m.End)
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
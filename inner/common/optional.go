@@ -0,0 +1,32 @@
+package common
+
+import "encoding/json"
+
+// Optional поле PATCH-запроса, позволяющее отличить отсутствующее в JSON поле от явного null.
+// Set равен true, если поле присутствовало в теле запроса; Value равен nil, если передан null.
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
+
+// Or значение поля, если оно передано, иначе current
+func (o Optional[T]) Or(current *T) *T {
+	if o.Set {
+		return o.Value
+	}
+	return current
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// DefaultPageLimit размер страницы, если limit не передан; максимальный размер ограничен валидацией
+const DefaultPageLimit = 50
+
+// PageRequest общие параметры постраничной выборки: размер страницы, курсор и сортировка.
+// Курсор выдаётся в ответе на предыдущую страницу и действителен только для той же сортировки.
+type PageRequest struct {
+	Limit  int    `json:"limit" validate:"min=1,max=500"`
+	Cursor string `json:"cursor"`
+	Sort   string `json:"sort" validate:"oneof=id name created_at updated_at"`
+	Order  string `json:"order" validate:"oneof=asc desc"`
+}
+
+// Defaults заполнить незаданные параметры значениями по умолчанию
+func (r *PageRequest) Defaults() {
+	if r.Limit == 0 {
+		r.Limit = DefaultPageLimit
+	}
+	if r.Sort == "" {
+		r.Sort = "id"
+	}
+	if r.Order == "" {
+		r.Order = "asc"
+	}
+}
+
+// Desc сортировка по убыванию
+func (r PageRequest) Desc() bool {
+	return r.Order == "desc"
+}
+
+// Cursor позиция последней записи предыдущей страницы
+type Cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+// After разобрать курсор запроса; nil означает первую страницу
+func (r PageRequest) After() (*Cursor, error) {
+	if r.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(r.Cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var cursor Cursor
+	if err = json.Unmarshal(raw, &cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if cursor.Sort != r.Sort || cursor.Order != r.Order {
+		return nil, errors.New("cursor does not match requested sort")
+	}
+	return &cursor, nil
+}
+
+// Next курсор страницы, следующей за записью с данными значением сортировки и id
+func (r PageRequest) Next(value string, id int64) string {
+	raw, _ := json.Marshal(Cursor{Sort: r.Sort, Order: r.Order, Value: value, Id: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// PageInfo сведения о странице: курсор следующей страницы (пустой на последней) и общее число записей
+type PageInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+type Page[T any] struct {
+	Items []T
+	PageInfo
+}
+
+// ParsePageRequest прочитать параметры страницы из query: limit, cursor, sort, order
+func ParsePageRequest(c *fiber.Ctx) (PageRequest, error) {
+	request := PageRequest{Cursor: c.Query("cursor"), Sort: c.Query("sort"), Order: c.Query("order")}
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return request, fmt.Errorf("invalid limit %q", limit)
+		}
+		request.Limit = value
+	}
+	return request, nil
+}
+
+// QueryTime прочитать необязательный параметр query в формате RFC 3339
+func QueryTime(c *fiber.Ctx, key string) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: expected RFC 3339 time", key, raw)
+	}
+	return &value, nil
+}
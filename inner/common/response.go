@@ -3,12 +3,32 @@ package common
 import "github.com/gofiber/fiber/v2"
 
 type Response[T any] struct {
-	Success bool   `json:"success"`
-	Message string `json:"error"`
-	Data    T      `json:"data"`
+	Success bool      `json:"success"`
+	Message string    `json:"error"`
+	Data    T         `json:"data"`
+	Page    *PageInfo `json:"page,omitempty"`
+}
+
+// ErrorWriter пишет ответ об ошибке в формате, которого ждут клиенты группы маршрутов
+type ErrorWriter func(c *fiber.Ctx, code int, message string) error
+
+// errorWriterKey ключ c.Locals, под которым лежит ErrorWriter группы маршрутов
+const errorWriterKey = "errorWriter"
+
+// WithErrorWriter middleware группы маршрутов со своим форматом ошибок, например SCIM: ErrResponse
+// в этой группе, в том числе в аутентификации и авторизации, пишет ответ через writer.
+// Регистрируется в группе раньше остальных middleware.
+func WithErrorWriter(writer ErrorWriter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(errorWriterKey, writer)
+		return c.Next()
+	}
 }
 
 func ErrResponse(c *fiber.Ctx, code int, message string) error {
+	if writer, ok := c.Locals(errorWriterKey).(ErrorWriter); ok {
+		return writer(c, code, message)
+	}
 	return c.Status(code).JSON(&Response[any]{
 		Success: false,
 		Message: message,
@@ -22,3 +42,12 @@ func OkResponse[T any](c *fiber.Ctx, data T) error {
 		Data:    data,
 	})
 }
+
+// PageResponse ответ со списком, где сведения о странице передаются рядом с данными
+func PageResponse[T any](c *fiber.Ctx, page Page[T]) error {
+	return c.JSON(&Response[[]T]{
+		Success: true,
+		Data:    page.Items,
+		Page:    &page.PageInfo,
+	})
+}
@@ -0,0 +1,20 @@
+package common
+
+import "time"
+
+// RetryPolicy повторы неудавшихся фоновых операций: пауза перед n-й повторной попыткой равна Backoff * 2^(n-1),
+// но не больше MaxBackoff; после MaxAttempts попыток операция считается неудавшейся
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Delay пауза после attempts неудачных попыток
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
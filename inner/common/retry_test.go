@@ -0,0 +1,20 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	a := assert.New(t)
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+
+	t.Run("should double delay up to maximum", func(t *testing.T) {
+		a.Equal(30*time.Second, policy.Delay(1))
+		a.Equal(time.Minute, policy.Delay(2))
+		a.Equal(4*time.Minute, policy.Delay(4))
+		a.Equal(5*time.Minute, policy.Delay(5))
+		a.Equal(5*time.Minute, policy.Delay(100))
+	})
+}
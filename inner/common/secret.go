@@ -0,0 +1,41 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// saltBytes длина соли для хеширования секретов
+const saltBytes = 16
+
+// NewSecret случайный секрет из size байт в кодировке base64url
+func NewSecret(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// NewSalt случайная соль для HashSecret
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltBytes)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// HashSecret хеш секрета с солью. Секреты, которые выдаёт IDM, случайные и длинные,
+// поэтому медленная функция хеширования паролей для них не нужна.
+func HashSecret(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+// SecretMatches сравнение за постоянное время, чтобы не подсказывать секрет по времени ответа
+func SecretMatches(hash, salt []byte, secret string) bool {
+	return subtle.ConstantTimeCompare(hash, HashSecret(salt, secret)) == 1
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"idm/inner/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Conditions собирает условия where и их аргументы для запросов с необязательными фильтрами.
+// В условиях вместо $N используется ?, номера параметров проставляются при добавлении.
+type Conditions struct {
+	parts []string
+	args  []any
+}
+
+// Add добавить условие; количество ? в condition должно совпадать с количеством args
+func (c *Conditions) Add(condition string, args ...any) {
+	var b strings.Builder
+	next := len(c.args) + 1
+	for _, ch := range condition {
+		if ch == '?' {
+			b.WriteString("$" + strconv.Itoa(next))
+			next++
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	c.parts = append(c.parts, b.String())
+	c.args = append(c.args, args...)
+}
+
+// Where условие целиком вместе с ключевым словом where либо пустая строка
+func (c *Conditions) Where() string {
+	if len(c.parts) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(c.parts, " and ")
+}
+
+func (c *Conditions) Args() []any {
+	return c.args
+}
+
+// AsOf оставить в выборке из таблицы истории только версии строк, действовавшие в момент at
+func (c *Conditions) AsOf(at time.Time) {
+	c.Add("version_from <= ? and (version_to is null or version_to > ?)", at, at)
+}
+
+// Keyset добавить условие "после курсора" и вернуть order by и limit для выборки страницы.
+// Выбирается на одну запись больше page.Limit, чтобы понять, есть ли следующая страница.
+// Колонка сортировки должна быть проверена вызывающим кодом: она подставляется в запрос как есть.
+func (c *Conditions) Keyset(page common.PageRequest, after *common.Cursor) string {
+	op, dir := ">", "asc"
+	if page.Desc() {
+		op, dir = "<", "desc"
+	}
+	if after != nil {
+		c.Add(fmt.Sprintf("(%s, id) %s (?, ?)", page.Sort, op), after.Value, after.Id)
+	}
+	c.args = append(c.args, page.Limit+1)
+	return fmt.Sprintf(" order by %s %s, id %s limit $%d", page.Sort, dir, dir, len(c.args))
+}
+
+// Offset пропустить первые offset записей; вызывается после Keyset, чтобы offset шёл за limit
+func (c *Conditions) Offset(offset int) string {
+	c.args = append(c.args, offset)
+	return fmt.Sprintf(" offset $%d", len(c.args))
+}
+
+// Filter условие, собранное вызывающим кодом (например, из фильтра SCIM), с ? вместо номеров параметров
+type Filter struct {
+	Condition string
+	Args      []any
+}
+
+// LikePrefix экранировать спецсимволы like и получить шаблон для поиска по префиксу
+func LikePrefix(prefix string) string {
+	return LikeEscape(prefix) + "%"
+}
+
+// LikeEscape экранировать спецсимволы like, чтобы значение сравнивалось буквально
+func LikeEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
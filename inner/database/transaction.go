@@ -0,0 +1,38 @@
+package database
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+// InTransaction выполнить fn в транзакции, полученной из begin.
+// При ошибке или панике внутри fn транзакция откатывается, иначе фиксируется.
+// Параметр operation используется только в текстах ошибок, например "granting role".
+func InTransaction(begin func() (*sqlx.Tx, error), operation string, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := begin()
+	if err != nil {
+		return fmt.Errorf("error creating transaction: %w", err)
+	}
+
+	defer func() {
+		if tx == nil {
+			return
+		}
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panic: %v", operation, r)
+			if errTx := tx.Rollback(); errTx != nil {
+				err = fmt.Errorf("%s: rolling back transaction errors: %w, %w", operation, err, errTx)
+			}
+		} else if err != nil {
+			if errTx := tx.Rollback(); errTx != nil {
+				err = fmt.Errorf("%s: rolling back transaction errors: %w, %w", operation, err, errTx)
+			}
+		} else {
+			if errTx := tx.Commit(); errTx != nil {
+				err = fmt.Errorf("%s: commiting transaction error: %w", operation, errTx)
+			}
+		}
+	}()
+
+	return fn(tx)
+}
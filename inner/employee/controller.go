@@ -1,7 +1,9 @@
 package employee
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 	"idm/inner/common"
@@ -9,6 +11,18 @@ import (
 	"strconv"
 )
 
+// Разрешения, которые требуют маршруты сотрудников
+const (
+	permissionRead   = "employees:read"
+	permissionCreate = "employees:create"
+	permissionUpdate = "employees:update"
+	permissionDelete = "employees:delete"
+	// permissionLifecycle переходы сотрудников по жизненному циклу
+	permissionLifecycle = "employees:lifecycle"
+	// permissionReadDeleted список сотрудников вместе с удалёнными (include_deleted)
+	permissionReadDeleted = "employees:read_deleted"
+)
+
 type Controller struct {
 	server          *web.Server
 	employeeService Svc
@@ -16,12 +30,26 @@ type Controller struct {
 }
 
 type Svc interface {
-	Create(request CreateRequest) (int64, error)
+	Create(ctx context.Context, request CreateRequest) (int64, error)
 	FindById(request IdRequest) (Response, error)
-	FindAll() ([]Response, error)
+	FindAll(request ListRequest) (common.Page[Response], error)
 	FindAllByIds(request IdsRequest) ([]Response, error)
-	DeleteById(request IdRequest) error
-	DeleteAllByIds(request IdsRequest) error
+	DeleteById(ctx context.Context, request IdRequest) error
+	DeleteAllByIds(ctx context.Context, request IdsRequest) error
+	SetRole(ctx context.Context, request SetRoleRequest) error
+	RemoveRole(ctx context.Context, request IdRequest) error
+	SetOrgUnit(ctx context.Context, request SetOrgUnitRequest) error
+	RemoveOrgUnit(ctx context.Context, request IdRequest) error
+	SetManager(ctx context.Context, request SetManagerRequest) error
+	RemoveManager(ctx context.Context, request IdRequest) error
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Patch(ctx context.Context, request PatchRequest) (Response, error)
+	Search(request SearchRequest) ([]SearchResponse, error)
+	Restore(ctx context.Context, request IdRequest) error
+	History(request IdRequest) ([]HistoryResponse, error)
+	Transition(ctx context.Context, request TransitionRequest) (TransitionResponse, error)
+	FindTransitions(request IdRequest) ([]TransitionResponse, error)
+	CancelTransition(ctx context.Context, request IdRequest) (TransitionResponse, error)
 }
 
 func NewController(server *web.Server, employeeService Svc, logger *common.Logger) *Controller {
@@ -33,12 +61,32 @@ func NewController(server *web.Server, employeeService Svc, logger *common.Logge
 }
 
 func (c *Controller) RegisterRoutes() {
-	c.server.GroupApiV1.Post("/employees", c.CreateEmployee)
-	c.server.GroupApiV1.Get("/employees/:id", c.FindById)
-	c.server.GroupApiV1.Get("/employees", c.FindAll)
-	c.server.GroupApiV1.Post("/employees/ids", c.FindAllByIds)
-	c.server.GroupApiV1.Delete("/employees/:id", c.DeleteById)
-	c.server.GroupApiV1.Delete("/employees", c.DeleteAllByIds)
+	c.server.GroupApiV1.Post("/employees", c.server.Require(permissionCreate), c.CreateEmployee)
+	c.server.GroupApiV1.Get("/employees/search", c.server.Require(permissionRead), c.Search)
+	c.server.GroupApiV1.Get("/employees/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/employees/:id/history", c.server.Require(permissionRead), c.History)
+	c.server.GroupApiV1.Get("/employees", c.server.Require(permissionRead),
+		c.server.RequireIf(permissionReadDeleted, web.QueryFlag("include_deleted")), c.FindAll)
+	c.server.GroupApiV1.Post("/employees/ids", c.server.Require(permissionRead), c.FindAllByIds)
+	c.server.GroupApiV1.Put("/employees/:id", c.server.Require(permissionUpdate), c.Update)
+	c.server.GroupApiV1.Patch("/employees/:id", c.server.Require(permissionUpdate), c.Patch)
+	c.server.GroupApiV1.Delete("/employees/:id", c.server.Require(permissionDelete), c.DeleteById)
+	c.server.GroupApiV1.Delete("/employees", c.server.Require(permissionDelete), c.DeleteAllByIds)
+	c.server.GroupApiV1.Put("/employees/:id/role", c.server.Require(permissionUpdate), c.SetRole)
+	c.server.GroupApiV1.Delete("/employees/:id/role", c.server.Require(permissionUpdate), c.RemoveRole)
+	c.server.GroupApiV1.Put("/employees/:id/org-unit", c.server.Require(permissionUpdate), c.SetOrgUnit)
+	c.server.GroupApiV1.Delete("/employees/:id/org-unit", c.server.Require(permissionUpdate), c.RemoveOrgUnit)
+	c.server.GroupApiV1.Put("/employees/:id/manager", c.server.Require(permissionUpdate), c.SetManager)
+	c.server.GroupApiV1.Delete("/employees/:id/manager", c.server.Require(permissionUpdate), c.RemoveManager)
+	c.server.GroupApiV1.Post("/employees/:id/restore", c.server.Require(permissionUpdate), c.Restore)
+	c.server.GroupApiV1.Post("/employees/:id/hire", c.server.Require(permissionLifecycle), c.transition(TransitionHire))
+	c.server.GroupApiV1.Post("/employees/:id/suspend", c.server.Require(permissionLifecycle), c.transition(TransitionSuspend))
+	c.server.GroupApiV1.Post("/employees/:id/resume", c.server.Require(permissionLifecycle), c.transition(TransitionResume))
+	c.server.GroupApiV1.Post("/employees/:id/terminate", c.server.Require(permissionLifecycle), c.transition(TransitionTerminate))
+	c.server.GroupApiV1.Post("/employees/:id/rehire", c.server.Require(permissionLifecycle), c.transition(TransitionRehire))
+	c.server.GroupApiV1.Get("/employees/:id/transitions", c.server.Require(permissionRead), c.FindTransitions)
+	c.server.GroupApiV1.Delete("/employees/:id/transitions/pending",
+		c.server.Require(permissionLifecycle), c.CancelTransition)
 }
 
 func (c *Controller) CreateEmployee(ctx *fiber.Ctx) error {
@@ -49,7 +97,7 @@ func (c *Controller) CreateEmployee(ctx *fiber.Ctx) error {
 	}
 
 	c.logger.Debug("create employee: received request", zap.Any("request", request))
-	var newEmployeeId, err = c.employeeService.Create(request)
+	var newEmployeeId, err = c.employeeService.Create(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("create employee: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -71,24 +119,158 @@ func (c *Controller) FindById(ctx *fiber.Ctx) error {
 		c.logger.Error("find employee by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
 	}
-	request := IdRequest{Id: id}
+	asOf, err := common.QueryTime(ctx, "as_of")
+	if err != nil {
+		c.logger.Error("find employee by id: invalid as_of parameter", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request := IdRequest{Id: id, AsOf: asOf}
 	response, err := c.employeeService.FindById(request)
 	if err != nil {
 		c.logger.Error("find employee by id: service error", zap.Int64("id", id), zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
 	}
 	c.logger.Debug("find employee by id: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) History(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("employee history: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("employee history: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	versions, err := c.employeeService.History(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("employee history: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("employee history: success", zap.Int64("id", id), zap.Int("count", len(versions)))
+	return common.OkResponse(ctx, versions)
+}
+
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update employee: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update employee: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	version, err := common.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		c.logger.Error("update employee: invalid If-Match header", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update employee: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	request.Version = version
+	c.logger.Debug("update employee: received request", zap.Any("request", request))
+	response, err := c.employeeService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update employee: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update employee: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Patch(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("patch employee: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("patch employee: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	version, err := common.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		c.logger.Error("patch employee: invalid If-Match header", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	var request PatchRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("patch employee: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	request.Version = version
+	c.logger.Debug("patch employee: received request", zap.Any("request", request))
+	response, err := c.employeeService.Patch(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("patch employee: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("patch employee: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
 	return common.OkResponse(ctx, response)
 }
 
 func (c *Controller) FindAll(ctx *fiber.Ctx) error {
-	c.logger.Debug("find all employees: received request")
-	responses, err := c.employeeService.FindAll()
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		c.logger.Error("find all employees: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find all employees: received request", zap.Any("request", request))
+	page, err := c.employeeService.FindAll(request)
 	if err != nil {
 		c.logger.Error("find all employees: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
 	}
-	c.logger.Debug("find all employees: success", zap.Int("count", len(responses)))
+	c.logger.Debug("find all employees: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+// parseListRequest прочитать параметры списка сотрудников из query
+func parseListRequest(ctx *fiber.Ctx) (request ListRequest, err error) {
+	if request.PageRequest, err = common.ParsePageRequest(ctx); err != nil {
+		return request, err
+	}
+	request.NamePrefix = ctx.Query("name_prefix")
+	request.IncludeDeleted = ctx.QueryBool("include_deleted")
+	request.Status = ctx.Query("status")
+	if request.CreatedAfter, err = common.QueryTime(ctx, "created_after"); err != nil {
+		return request, err
+	}
+	if request.AsOf, err = common.QueryTime(ctx, "as_of"); err != nil {
+		return request, err
+	}
+	if raw := ctx.Query("role_id"); raw != "" {
+		roleId, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return request, fmt.Errorf("invalid role_id %q", raw)
+		}
+		request.RoleId = &roleId
+	}
+	return request, nil
+}
+
+func (c *Controller) Search(ctx *fiber.Ctx) error {
+	request := SearchRequest{Query: ctx.Query("q")}
+	if raw := ctx.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			c.logger.Error("search employees: query parse error", zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("invalid limit %q", raw))
+		}
+		request.Limit = limit
+	}
+	c.logger.Debug("search employees: received request", zap.Any("request", request))
+	responses, err := c.employeeService.Search(request)
+	if err != nil {
+		c.logger.Error("search employees: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("search employees: success", zap.Int("count", len(responses)))
 	return common.OkResponse(ctx, responses)
 }
 
@@ -117,7 +299,7 @@ func (c *Controller) DeleteById(ctx *fiber.Ctx) error {
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
 	}
 	request := IdRequest{Id: id}
-	err = c.employeeService.DeleteById(request)
+	err = c.employeeService.DeleteById(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("delete employee by id: service error", zap.Int64("id", id), zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -133,7 +315,7 @@ func (c *Controller) DeleteAllByIds(ctx *fiber.Ctx) error {
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
 	}
 	c.logger.Debug("delete all employees by ids: received request", zap.Any("request", request))
-	err := c.employeeService.DeleteAllByIds(request)
+	err := c.employeeService.DeleteAllByIds(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("delete all employees by ids: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -142,13 +324,222 @@ func (c *Controller) DeleteAllByIds(ctx *fiber.Ctx) error {
 	return common.OkResponse[any](ctx, nil)
 }
 
+func (c *Controller) SetRole(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("set employee role: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("set employee role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request SetRoleRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("set employee role: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	c.logger.Debug("set employee role: received request", zap.Any("request", request))
+	err = c.employeeService.SetRole(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("set employee role: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("set employee role: success", zap.Int64("id", id), zap.Int64("role_id", request.RoleId))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) RemoveRole(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("remove employee role: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("remove employee role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := IdRequest{Id: id}
+	err = c.employeeService.RemoveRole(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("remove employee role: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("remove employee role: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) SetOrgUnit(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("set employee org unit: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("set employee org unit: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request SetOrgUnitRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("set employee org unit: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	err = c.employeeService.SetOrgUnit(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("set employee org unit: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("set employee org unit: success", zap.Int64("id", id), zap.Int64("org_unit_id", request.OrgUnitId))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) RemoveOrgUnit(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("remove employee org unit: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("remove employee org unit: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.employeeService.RemoveOrgUnit(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("remove employee org unit: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("remove employee org unit: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) SetManager(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("set employee manager: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("set employee manager: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request SetManagerRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("set employee manager: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	err = c.employeeService.SetManager(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("set employee manager: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("set employee manager: success", zap.Int64("id", id), zap.Int64("manager_id", request.ManagerId))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) RemoveManager(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("remove employee manager: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("remove employee manager: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.employeeService.RemoveManager(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("remove employee manager: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("remove employee manager: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+// transition обработчик перехода по жизненному циклу; тело с effective_at и reason необязательно
+func (c *Controller) transition(name string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		idStr := ctx.Params("id")
+		c.logger.Debug(name+" employee: received id", zap.String("id", idStr))
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			c.logger.Error(name+" employee: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+		}
+		var request TransitionRequest
+		if len(ctx.Body()) > 0 {
+			if err = ctx.BodyParser(&request); err != nil {
+				c.logger.Error(name+" employee: failed to parse request body", zap.Error(err))
+				return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+			}
+		}
+		request.Id = id
+		request.Transition = name
+		response, err := c.employeeService.Transition(ctx.UserContext(), request)
+		if err != nil {
+			c.logger.Error(name+" employee: service error", zap.Int64("id", id), zap.Error(err))
+			return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+		}
+		c.logger.Debug(name+" employee: success", zap.Int64("id", id), zap.String("status", response.Status))
+		return common.OkResponse(ctx, response)
+	}
+}
+
+func (c *Controller) FindTransitions(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee transitions: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee transitions: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.employeeService.FindTransitions(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find employee transitions: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee transitions: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) CancelTransition(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("cancel employee transition: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("cancel employee transition: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.employeeService.CancelTransition(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("cancel employee transition: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("cancel employee transition: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
 func resolveHttpStatusCode(err error) int {
 	switch {
 	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
 		return fiber.StatusBadRequest
 	case errors.As(err, &common.NotFoundError{}):
 		return fiber.StatusNotFound
+	case errors.As(err, &common.ForbiddenError{}):
+		return fiber.StatusForbidden
+	case errors.As(err, &common.PreconditionFailedError{}):
+		return fiber.StatusPreconditionFailed
+	case errors.As(err, &common.SodConflictError{}):
+		// роль, которую правило назначения выдало бы сотруднику, нарушает разделение обязанностей
+		return fiber.StatusConflict
 	default:
 		return fiber.StatusInternalServerError
 	}
 }
+
+func (c *Controller) Restore(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("restore employee: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("restore employee: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.employeeService.Restore(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("restore employee: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("restore employee: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
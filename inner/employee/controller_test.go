@@ -1,6 +1,7 @@
 package employee
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/gofiber/fiber/v2"
@@ -8,7 +9,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 	"idm/inner/common"
-	"idm/inner/web"
+	"idm/inner/web/webtest"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -21,7 +22,7 @@ type MockService struct {
 	mock.Mock
 }
 
-func (svc *MockService) Create(request CreateRequest) (int64, error) {
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (int64, error) {
 	args := svc.Called(request)
 	return args.Get(0).(int64), args.Error(1)
 }
@@ -31,9 +32,9 @@ func (svc *MockService) FindById(request IdRequest) (Response, error) {
 	return args.Get(0).(Response), args.Error(1)
 }
 
-func (svc *MockService) FindAll() ([]Response, error) {
-	args := svc.Called()
-	return args.Get(0).([]Response), args.Error(1)
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
 }
 
 func (svc *MockService) FindAllByIds(request IdsRequest) ([]Response, error) {
@@ -41,23 +42,93 @@ func (svc *MockService) FindAllByIds(request IdsRequest) ([]Response, error) {
 	return args.Get(0).([]Response), args.Error(1)
 }
 
-func (svc *MockService) DeleteById(request IdRequest) error {
+func (svc *MockService) DeleteById(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) DeleteAllByIds(ctx context.Context, request IdsRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) SetRole(ctx context.Context, request SetRoleRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) RemoveRole(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) SetOrgUnit(ctx context.Context, request SetOrgUnitRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) RemoveOrgUnit(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) SetManager(ctx context.Context, request SetManagerRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) RemoveManager(ctx context.Context, request IdRequest) error {
 	args := svc.Called(request)
 	return args.Error(0)
 }
 
-func (svc *MockService) DeleteAllByIds(request IdsRequest) error {
+func (svc *MockService) Search(request SearchRequest) ([]SearchResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]SearchResponse), args.Error(1)
+}
+
+func (svc *MockService) Restore(ctx context.Context, request IdRequest) error {
 	args := svc.Called(request)
 	return args.Error(0)
 }
 
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Patch(ctx context.Context, request PatchRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) History(request IdRequest) ([]HistoryResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]HistoryResponse), args.Error(1)
+}
+
+func (svc *MockService) Transition(ctx context.Context, request TransitionRequest) (TransitionResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(TransitionResponse), args.Error(1)
+}
+
+func (svc *MockService) FindTransitions(request IdRequest) ([]TransitionResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]TransitionResponse), args.Error(1)
+}
+
+func (svc *MockService) CancelTransition(ctx context.Context, request IdRequest) (TransitionResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(TransitionResponse), args.Error(1)
+}
+
 func TestControllerCreateEmployee(t *testing.T) {
 	a := assert.New(t)
 
 	url := "/api/v1/employees"
 
 	t.Run("should return created employee id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -85,8 +156,52 @@ func TestControllerCreateEmployee(t *testing.T) {
 		a.Empty(responseBody.Message)
 	})
 
+	t.Run("should parse profile fields and custom attributes", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		body := strings.NewReader(`{"name":"john doe","email":"john@example.com","hire_date":"2025-02-03",` +
+			`"attributes":{"cost_center":"CC-42","remote":true}}`)
+		req := httptest.NewRequest(fiber.MethodPost, url, body)
+		req.Header.Set("Content-Type", "application/json")
+
+		email := "john@example.com"
+		hireDate, err := common.ParseDate("2025-02-03")
+		a.Nil(err)
+		svc.On("Create", CreateRequest{
+			Name:       "john doe",
+			Email:      &email,
+			HireDate:   &hireDate,
+			Attributes: Attributes{"cost_center": "CC-42", "remote": true},
+		}).Return(int64(123), nil)
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on malformed hire date", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		body := strings.NewReader(`{"name":"john doe","hire_date":"03.02.2025"}`)
+		req := httptest.NewRequest(fiber.MethodPost, url, body)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.Empty(svc.Calls)
+	})
+
 	t.Run("should return bad request on invalid json", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -102,7 +217,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return bad request on validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -130,7 +245,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return bad request on already exists error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -156,7 +271,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -187,7 +302,7 @@ func TestControllerFindById(t *testing.T) {
 	url := "/api/v1/employees/1"
 
 	t.Run("should return employee by id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -202,6 +317,7 @@ func TestControllerFindById(t *testing.T) {
 		a.Nil(err)
 		a.NotEmpty(resp)
 		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(common.ETag(employee.UpdatedAt), resp.Header.Get("ETag"))
 
 		bytesData, err := io.ReadAll(resp.Body)
 		a.Nil(err)
@@ -218,7 +334,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -243,7 +359,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return bad request on invalid id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -266,7 +382,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -295,7 +411,7 @@ func TestControllerFindAll(t *testing.T) {
 	url := "/api/v1/employees"
 
 	t.Run("should return all employees", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -305,7 +421,7 @@ func TestControllerFindAll(t *testing.T) {
 			{Id: 1, Name: "Alice", CreatedAt: time.Now(), UpdatedAt: time.Now()},
 			{Id: 2, Name: "Bob", CreatedAt: time.Now(), UpdatedAt: time.Now()},
 		}
-		svc.On("FindAll").Return(employees, nil)
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{Items: employees}, nil)
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -332,14 +448,14 @@ func TestControllerFindAll(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
 		controller.RegisterRoutes()
 
 		errNotFound := common.NotFoundError{Message: "no employees found"}
-		svc.On("FindAll").Return([]Response(nil), errNotFound)
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, errNotFound)
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -357,13 +473,13 @@ func TestControllerFindAll(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
 		controller.RegisterRoutes()
 
-		svc.On("FindAll").Return([]Response(nil), errors.New("unexpected server error"))
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, errors.New("unexpected server error"))
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -388,7 +504,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	invalidBody := `{"ids":[]}`
 
 	t.Run("should return all employees by ids", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -429,7 +545,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -457,7 +573,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -485,7 +601,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -518,7 +634,7 @@ func TestControllerDeleteById(t *testing.T) {
 	url := "/api/v1/employees/1"
 
 	t.Run("should delete employee by id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -544,7 +660,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return bad request on invalid id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -567,7 +683,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -593,7 +709,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -625,7 +741,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	invalidBody := `{"ids":[]}`
 
 	t.Run("should delete all employees by ids", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -653,7 +769,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -681,7 +797,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -709,7 +825,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -735,3 +851,729 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 		a.Equal("unexpected server error", responseBody.Message)
 	})
 }
+
+func TestControllerSetRole(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1/role"
+
+	t.Run("should set employee role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("SetRole", SetRoleRequest{Id: 1, RoleId: 7}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"role_id":7}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "SetRole", 1))
+	})
+
+	t.Run("should return bad request on invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/employees/abc/role", strings.NewReader(`{"role_id":7}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return not found when role does not exist", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		notFoundErr := common.NotFoundError{Message: "role with id 7 not found"}
+		svc.On("SetRole", SetRoleRequest{Id: 1, RoleId: 7}).Return(notFoundErr)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"role_id":7}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+
+		var responseBody common.Response[any]
+		err = json.Unmarshal(bytesData, &responseBody)
+		a.Nil(err)
+		a.False(responseBody.Success)
+		a.Equal("role with id 7 not found", responseBody.Message)
+	})
+}
+
+func TestControllerRemoveRole(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1/role"
+
+	t.Run("should remove employee role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("RemoveRole", IdRequest{Id: 1}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodDelete, url, nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "RemoveRole", 1))
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("RemoveRole", IdRequest{Id: 1}).Return(common.NotFoundError{Message: "employee not found"})
+
+		req := httptest.NewRequest(fiber.MethodDelete, url, nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerSetOrgUnit(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1/org-unit"
+
+	t.Run("should move employee to org unit", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("SetOrgUnit", SetOrgUnitRequest{Id: 1, OrgUnitId: 3}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"org_unit_id":3}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "SetOrgUnit", 1))
+	})
+
+	t.Run("should remove employee from org unit", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("RemoveOrgUnit", IdRequest{Id: 1}).Return(nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, url, nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "RemoveOrgUnit", 1))
+	})
+}
+
+func TestControllerSetManager(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1/manager"
+
+	t.Run("should set employee manager", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("SetManager", SetManagerRequest{Id: 1, ManagerId: 2}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"manager_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "SetManager", 1))
+	})
+
+	t.Run("should return bad request when manager would create a cycle", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		cycleErr := common.RequestValidationError{Message: "employee 2 reports to employee 1"}
+		svc.On("SetManager", SetManagerRequest{Id: 1, ManagerId: 2}).Return(cycleErr)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"manager_id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should remove employee manager", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("RemoveManager", IdRequest{Id: 1}).Return(nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, url, nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "RemoveManager", 1))
+	})
+}
+
+func TestControllerUpdate(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1"
+
+	t.Run("should update employee and return new etag", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		version := time.UnixMicro(1700000000000000)
+		updated := Response{Id: 1, Name: "Alice Smith", UpdatedAt: time.UnixMicro(1700000000000001)}
+		svc.On("Update", UpdateRequest{Id: 1, Name: "Alice Smith", Version: &version}).Return(updated, nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"name":"Alice Smith"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"1700000000000000"`)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(`"1700000000000001"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("should return precondition failed on stale version", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Update", mock.AnythingOfType("UpdateRequest")).
+			Return(Response{}, common.PreconditionFailedError{Message: "modified concurrently"})
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"name":"Alice Smith"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"1"`)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on malformed If-Match", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodPut, url, strings.NewReader(`{"name":"Alice Smith"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "not-an-etag")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Update", mock.Anything))
+	})
+}
+
+func TestControllerPatch(t *testing.T) {
+	a := assert.New(t)
+	url := "/api/v1/employees/1"
+
+	t.Run("should distinguish null from missing fields", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Patch", mock.MatchedBy(func(r PatchRequest) bool {
+			return r.Id == 1 && !r.Name.Set && r.RoleId.Set && r.RoleId.Value == nil && r.Version == nil
+		})).Return(Response{Id: 1, Name: "Alice", UpdatedAt: time.Now()}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPatch, url, strings.NewReader(`{"role_id":null}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.NotEmpty(resp.Header.Get("ETag"))
+	})
+}
+
+func TestControllerFindAllPage(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters and return page info", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		roleId := int64(7)
+		createdAfter := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		request := ListRequest{
+			PageRequest:  common.PageRequest{Limit: 10, Cursor: "abc", Sort: "name", Order: "desc"},
+			NamePrefix:   "Al",
+			CreatedAfter: &createdAfter,
+			RoleId:       &roleId,
+		}
+		page := common.Page[Response]{
+			Items:    []Response{{Id: 1, Name: "Alice"}},
+			PageInfo: common.PageInfo{NextCursor: "next", Total: 11},
+		}
+		svc.On("FindAll", request).Return(page, nil)
+
+		url := "/api/v1/employees?limit=10&cursor=abc&sort=name&order=desc" +
+			"&name_prefix=Al&created_after=2025-01-01T00:00:00Z&role_id=7"
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, url, nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal(&common.PageInfo{NextCursor: "next", Total: 11}, responseBody.Page)
+	})
+
+	t.Run("should return bad request on malformed query", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, query := range []string{"limit=ten", "created_after=yesterday", "role_id=admin"} {
+			resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees?"+query, nil))
+			a.Nil(err)
+			a.Equal(http.StatusBadRequest, resp.StatusCode, query)
+		}
+		a.True(svc.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
+
+func TestControllerSearch(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should search instead of treating search as id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		results := []SearchResponse{{
+			Response:   Response{Id: 1, Name: "Alice"},
+			Rank:       0.8,
+			Highlights: []common.Match{{Start: 0, End: 3}},
+		}}
+		svc.On("Search", SearchRequest{Query: "ali", Limit: 10}).Return(results, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/search?q=ali&limit=10", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]SearchResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(results[0].Highlights, responseBody.Data[0].Highlights)
+		a.Equal("Alice", responseBody.Data[0].Name)
+	})
+
+	t.Run("should return bad request on validation error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Search", SearchRequest{}).Return([]SearchResponse(nil), common.RequestValidationError{Message: "q is required"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/search", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerRestore(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should restore employee", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Restore", IdRequest{Id: 1}).Return(nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/restore", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return not found for unknown employee", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Restore", IdRequest{Id: 1}).Return(common.NotFoundError{Message: "employee with id 1 not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/restore", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("should pass include_deleted to list", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		deletedAt := time.Now()
+		page := common.Page[Response]{Items: []Response{{Id: 1, Name: "Alice", DeletedAt: &deletedAt}}}
+		svc.On("FindAll", ListRequest{IncludeDeleted: true}).Return(page, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees?include_deleted=true", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.NotNil(responseBody.Data[0].DeletedAt)
+	})
+
+	t.Run("should require permission to include deleted employees", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = GrantAuthorizer{"employees:read"}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees?include_deleted=true", nil))
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+		a.Empty(svc.Calls)
+
+		resp, err = server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestControllerAsOf(t *testing.T) {
+	a := assert.New(t)
+	asOf := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should pass as_of to find by id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindById", IdRequest{Id: 1, AsOf: &asOf}).Return(Response{Id: 1, Name: "Old Name"}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1?as_of=2025-08-01T12:00:00Z", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should pass as_of to list", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll", ListRequest{AsOf: &asOf}).Return(common.Page[Response]{Items: []Response{}}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees?as_of=2025-08-01T12:00:00Z", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid as_of", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1?as_of=yesterday", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindById", mock.Anything))
+	})
+}
+
+func TestControllerHistory(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return versions of employee", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		from := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+		to := from.Add(time.Hour)
+		versions := []HistoryResponse{
+			{Id: 1, Name: "Bob", VersionFrom: from, VersionTo: &to},
+			{Id: 1, Name: "Bobby", VersionFrom: to},
+		}
+		svc.On("History", IdRequest{Id: 1}).Return(versions, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/history", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]HistoryResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 2)
+		a.Equal("Bobby", responseBody.Data[1].Name)
+		a.Nil(responseBody.Data[1].VersionTo)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("History", IdRequest{Id: 1}).
+			Return([]HistoryResponse(nil), common.NotFoundError{Message: "employee with id 1 not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/history", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/abc/history", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "History", mock.Anything))
+	})
+}
+
+func TestControllerTransition(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should terminate employee without body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Transition", TransitionRequest{Id: 1, Transition: TransitionTerminate}).
+			Return(TransitionResponse{Id: 5, EmployeeId: 1, Transition: TransitionTerminate, Status: TransitionApplied}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/terminate", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[TransitionResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(TransitionApplied, responseBody.Data.Status)
+	})
+
+	t.Run("should pass effective date and reason", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		effectiveAt := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+		svc.On("Transition", TransitionRequest{Id: 1, Transition: TransitionHire, EffectiveAt: &effectiveAt, Reason: "onboarding"}).
+			Return(TransitionResponse{Id: 5, Status: TransitionPending}, nil)
+
+		body := strings.NewReader(`{"effective_at": "2025-10-01T09:00:00Z", "reason": "onboarding"}`)
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/hire", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "Transition", 1))
+	})
+
+	t.Run("should return bad request when transition is not allowed", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Transition", TransitionRequest{Id: 1, Transition: TransitionResume}).
+			Return(TransitionResponse{}, common.RequestValidationError{Message: "cannot resume employee with id 1 in status active"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/employees/1/resume", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return transitions of employee", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindTransitions", IdRequest{Id: 1}).Return([]TransitionResponse{{Id: 6}, {Id: 5}}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/transitions", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]TransitionResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 2)
+	})
+
+	t.Run("should return not found when no transition is pending", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("CancelTransition", IdRequest{Id: 1}).
+			Return(TransitionResponse{}, common.NotFoundError{Message: "employee with id 1 has no pending transition"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, "/api/v1/employees/1/transitions/pending", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// DenyAuthorizer отклоняет любой запрос и сообщает, какое разрешение потребовал маршрут
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+// GrantAuthorizer пропускает запрос, только если разрешение есть в списке разрешений автора запроса
+type GrantAuthorizer []string
+
+func (g GrantAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, granted := range g {
+			if granted == permission {
+				return c.Next()
+			}
+		}
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	routes := []struct {
+		method     string
+		url        string
+		permission string
+	}{
+		{fiber.MethodPost, "/api/v1/employees", "employees:create"},
+		{fiber.MethodGet, "/api/v1/employees/search?q=ab", "employees:read"},
+		{fiber.MethodGet, "/api/v1/employees/1", "employees:read"},
+		{fiber.MethodGet, "/api/v1/employees/1/history", "employees:read"},
+		{fiber.MethodGet, "/api/v1/employees", "employees:read"},
+		{fiber.MethodPost, "/api/v1/employees/ids", "employees:read"},
+		{fiber.MethodPut, "/api/v1/employees/1", "employees:update"},
+		{fiber.MethodPatch, "/api/v1/employees/1", "employees:update"},
+		{fiber.MethodDelete, "/api/v1/employees/1", "employees:delete"},
+		{fiber.MethodDelete, "/api/v1/employees", "employees:delete"},
+		{fiber.MethodPut, "/api/v1/employees/1/role", "employees:update"},
+		{fiber.MethodDelete, "/api/v1/employees/1/role", "employees:update"},
+		{fiber.MethodPut, "/api/v1/employees/1/org-unit", "employees:update"},
+		{fiber.MethodDelete, "/api/v1/employees/1/org-unit", "employees:update"},
+		{fiber.MethodPut, "/api/v1/employees/1/manager", "employees:update"},
+		{fiber.MethodDelete, "/api/v1/employees/1/manager", "employees:update"},
+		{fiber.MethodPost, "/api/v1/employees/1/restore", "employees:update"},
+		{fiber.MethodPost, "/api/v1/employees/1/hire", "employees:lifecycle"},
+		{fiber.MethodPost, "/api/v1/employees/1/suspend", "employees:lifecycle"},
+		{fiber.MethodPost, "/api/v1/employees/1/resume", "employees:lifecycle"},
+		{fiber.MethodPost, "/api/v1/employees/1/terminate", "employees:lifecycle"},
+		{fiber.MethodPost, "/api/v1/employees/1/rehire", "employees:lifecycle"},
+		{fiber.MethodGet, "/api/v1/employees/1/transitions", "employees:read"},
+		{fiber.MethodDelete, "/api/v1/employees/1/transitions/pending", "employees:lifecycle"},
+	}
+
+	t.Run("should require permission on every route", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = DenyAuthorizer{}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, route := range routes {
+			resp, err := server.App.Test(httptest.NewRequest(route.method, route.url, nil))
+			a.Nil(err)
+			a.Equal(http.StatusForbidden, resp.StatusCode, route.url)
+
+			bytesData, err := io.ReadAll(resp.Body)
+			a.Nil(err)
+			var responseBody common.Response[any]
+			a.Nil(json.Unmarshal(bytesData, &responseBody))
+			a.Equal("missing permission "+route.permission, responseBody.Message, route.method+" "+route.url)
+		}
+		a.Empty(svc.Calls)
+	})
+}
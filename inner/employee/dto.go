@@ -1,27 +1,342 @@
 package employee
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"idm/inner/common"
+	"strconv"
+	"time"
+)
+
+// Состояния сотрудника в жизненном цикле
+const (
+	StatusPreHire    = "pre_hire"
+	StatusActive     = "active"
+	StatusSuspended  = "suspended"
+	StatusTerminated = "terminated"
+)
+
+// Переходы между состояниями
+const (
+	TransitionHire      = "hire"
+	TransitionSuspend   = "suspend"
+	TransitionResume    = "resume"
+	TransitionTerminate = "terminate"
+	TransitionRehire    = "rehire"
+)
+
+// Состояния перехода: ожидает даты вступления в силу, применён или отменён
+const (
+	TransitionPending   = "pending"
+	TransitionApplied   = "applied"
+	TransitionCancelled = "cancelled"
+)
+
+// lifecycle из каких состояний и в какое ведёт каждый переход
+var lifecycle = map[string]struct {
+	from []string
+	to   string
+}{
+	TransitionHire:      {from: []string{StatusPreHire}, to: StatusActive},
+	TransitionSuspend:   {from: []string{StatusActive}, to: StatusSuspended},
+	TransitionResume:    {from: []string{StatusSuspended}, to: StatusActive},
+	TransitionTerminate: {from: []string{StatusPreHire, StatusActive, StatusSuspended}, to: StatusTerminated},
+	TransitionRehire:    {from: []string{StatusTerminated}, to: StatusPreHire},
+}
 
 type Entity struct {
-	Id        int64     `db:"id"`
-	Name      string    `db:"name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-	RoleId    *int64    `db:"role_id"`
+	Id        int64      `db:"id"`
+	Name      string     `db:"name"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+	RoleId    *int64     `db:"role_id"`
+	DeletedAt *time.Time `db:"deleted_at"`
+	// Login субъект токена доступа (claim sub), под которым сотрудник обращается к API
+	Login *string `db:"login"`
+	// Status состояние в жизненном цикле; меняется только переходами
+	Status string `db:"status"`
+	// OrgUnitId подразделение сотрудника, ManagerId - его непосредственный руководитель
+	OrgUnitId *int64 `db:"org_unit_id"`
+	ManagerId *int64 `db:"manager_id"`
+	// Анкетные данные; EmployeeNumber уникален среди неудалённых сотрудников
+	Email          *string      `db:"email"`
+	Title          *string      `db:"title"`
+	Phone          *string      `db:"phone"`
+	HireDate       *common.Date `db:"hire_date"`
+	EmployeeNumber *string      `db:"employee_number"`
+	// Attributes пользовательские атрибуты по схеме employee_attribute
+	Attributes Attributes `db:"attributes"`
+}
+
+// Attributes пользовательские атрибуты сотрудника: значения из JSON, ключи и типы задаёт схема атрибутов.
+// В базе хранятся в колонке jsonb; пустой набор сохраняется как {}
+type Attributes map[string]any
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *Attributes) Scan(src any) error {
+	var data []byte
+	switch value := src.(type) {
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	case nil:
+		*a = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into attributes", src)
+	}
+	return json.Unmarshal(data, a)
+}
+
+// IsActive работает ли сотрудник: только у работающих сотрудников есть доступ к API
+func (e *Entity) IsActive() bool {
+	return e.Status == StatusActive && e.DeletedAt == nil
 }
 
 func (e *Entity) toResponse() Response {
 	return Response{
-		Id:        e.Id,
-		Name:      e.Name,
-		CreatedAt: e.CreatedAt,
-		UpdatedAt: e.UpdatedAt,
+		Id:             e.Id,
+		Name:           e.Name,
+		Login:          e.Login,
+		Status:         e.Status,
+		OrgUnitId:      e.OrgUnitId,
+		ManagerId:      e.ManagerId,
+		Email:          e.Email,
+		Title:          e.Title,
+		Phone:          e.Phone,
+		HireDate:       e.HireDate,
+		EmployeeNumber: e.EmployeeNumber,
+		Attributes:     e.Attributes,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.UpdatedAt,
+		DeletedAt:      e.DeletedAt,
+	}
+}
+
+// auditSnapshot состояние сотрудника в журнале аудита: только поля, которые меняют пользователи
+type auditSnapshot struct {
+	Id             int64        `json:"id"`
+	Name           string       `json:"name"`
+	RoleId         *int64       `json:"role_id"`
+	Login          *string      `json:"login"`
+	Status         string       `json:"status"`
+	OrgUnitId      *int64       `json:"org_unit_id"`
+	ManagerId      *int64       `json:"manager_id"`
+	Email          *string      `json:"email"`
+	Title          *string      `json:"title"`
+	Phone          *string      `json:"phone"`
+	HireDate       *common.Date `json:"hire_date"`
+	EmployeeNumber *string      `json:"employee_number"`
+	Attributes     Attributes   `json:"attributes,omitempty"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{
+		Id:             e.Id,
+		Name:           e.Name,
+		RoleId:         e.RoleId,
+		Login:          e.Login,
+		Status:         e.Status,
+		OrgUnitId:      e.OrgUnitId,
+		ManagerId:      e.ManagerId,
+		Email:          e.Email,
+		Title:          e.Title,
+		Phone:          e.Phone,
+		HireDate:       e.HireDate,
+		EmployeeNumber: e.EmployeeNumber,
+		Attributes:     e.Attributes,
+		DeletedAt:      e.DeletedAt,
 	}
 }
 
+// sortValue значение колонки сортировки sort для курсора страницы
+func (e *Entity) sortValue(sort string) string {
+	switch sort {
+	case "name":
+		return e.Name
+	case "created_at":
+		return e.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return e.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return strconv.FormatInt(e.Id, 10)
+}
+
+// SearchEntity сотрудник, найденный поиском, и его релевантность запросу
+type SearchEntity struct {
+	Entity
+	Rank float64 `db:"rank"`
+}
+
+// HistoryEntity версия строки сотрудника из employee_history, действовавшая в [VersionFrom, VersionTo)
+type HistoryEntity struct {
+	Entity
+	VersionFrom time.Time  `db:"version_from"`
+	VersionTo   *time.Time `db:"version_to"`
+}
+
+func (e *HistoryEntity) toResponse() HistoryResponse {
+	return HistoryResponse{
+		Id:             e.Id,
+		Name:           e.Name,
+		Login:          e.Login,
+		RoleId:         e.RoleId,
+		Status:         e.Status,
+		OrgUnitId:      e.OrgUnitId,
+		ManagerId:      e.ManagerId,
+		Email:          e.Email,
+		Title:          e.Title,
+		Phone:          e.Phone,
+		HireDate:       e.HireDate,
+		EmployeeNumber: e.EmployeeNumber,
+		Attributes:     e.Attributes,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.UpdatedAt,
+		DeletedAt:      e.DeletedAt,
+		VersionFrom:    e.VersionFrom,
+		VersionTo:      e.VersionTo,
+	}
+}
+
+// entitiesOf состояния сотрудников из их версий
+func entitiesOf(versions []HistoryEntity) []Entity {
+	entities := make([]Entity, 0, len(versions))
+	for _, version := range versions {
+		entities = append(entities, version.Entity)
+	}
+	return entities
+}
+
 type Response struct {
-	Id        int64     `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Login     *string `json:"login,omitempty"`
+	Status    string  `json:"status"`
+	OrgUnitId *int64  `json:"org_unit_id,omitempty"`
+	ManagerId *int64  `json:"manager_id,omitempty"`
+	// Анкетные данные и пользовательские атрибуты
+	Email          *string       `json:"email,omitempty"`
+	Title          *string       `json:"title,omitempty"`
+	Phone          *string       `json:"phone,omitempty"`
+	HireDate       *common.Date  `json:"hire_date,omitempty"`
+	EmployeeNumber *string       `json:"employee_number,omitempty"`
+	Attributes     Attributes    `json:"attributes"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	Role           *RoleResponse `json:"role,omitempty"`
+	// DeletedAt момент мягкого удаления; заполнен только в списках с include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Roles роли, действующие у сотрудника по назначениям employee_role; заполняется только при поиске по id
+	Roles []RoleResponse `json:"roles,omitempty"`
+}
+
+// UpdateRequest запрос полной замены, который сохраняет все текущие данные сотрудника:
+// вызывающему остаётся поменять только то, что он действительно меняет
+func (r *Response) UpdateRequest() UpdateRequest {
+	request := UpdateRequest{
+		Id:             r.Id,
+		Name:           r.Name,
+		Login:          r.Login,
+		Email:          r.Email,
+		Title:          r.Title,
+		Phone:          r.Phone,
+		HireDate:       r.HireDate,
+		EmployeeNumber: r.EmployeeNumber,
+		Attributes:     r.Attributes,
+	}
+	if r.Role != nil {
+		request.RoleId = &r.Role.Id
+	}
+	return request
+}
+
+// RoleResponse краткое представление роли сотрудника, чтобы клиентам не требовался отдельный запрос к /roles
+type RoleResponse struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// SearchResponse сотрудник в результатах поиска. Highlights — совпавшие с запросом фрагменты имени
+// в символах, чтобы клиент мог их выделить; Rank от 0 до 1, выше — ближе к запросу.
+type SearchResponse struct {
+	Response
+	Rank       float64        `json:"rank"`
+	Highlights []common.Match `json:"highlights"`
+}
+
+// HistoryResponse версия сотрудника; у текущей версии version_to отсутствует
+type HistoryResponse struct {
+	Id             int64        `json:"id"`
+	Name           string       `json:"name"`
+	Login          *string      `json:"login,omitempty"`
+	RoleId         *int64       `json:"role_id"`
+	Status         string       `json:"status"`
+	OrgUnitId      *int64       `json:"org_unit_id,omitempty"`
+	ManagerId      *int64       `json:"manager_id,omitempty"`
+	Email          *string      `json:"email,omitempty"`
+	Title          *string      `json:"title,omitempty"`
+	Phone          *string      `json:"phone,omitempty"`
+	HireDate       *common.Date `json:"hire_date,omitempty"`
+	EmployeeNumber *string      `json:"employee_number,omitempty"`
+	Attributes     Attributes   `json:"attributes"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"`
+	VersionFrom    time.Time    `json:"version_from"`
+	VersionTo      *time.Time   `json:"version_to,omitempty"`
+}
+
+// TransitionEntity переход сотрудника из состояния FromStatus в ToStatus с даты EffectiveAt
+type TransitionEntity struct {
+	Id          int64      `db:"id"`
+	EmployeeId  int64      `db:"employee_id"`
+	Transition  string     `db:"transition"`
+	FromStatus  string     `db:"from_status"`
+	ToStatus    string     `db:"to_status"`
+	EffectiveAt time.Time  `db:"effective_at"`
+	Reason      string     `db:"reason"`
+	Status      string     `db:"status"`
+	CreatedBy   string     `db:"created_by"`
+	AppliedAt   *time.Time `db:"applied_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+func (e *TransitionEntity) toResponse() TransitionResponse {
+	return TransitionResponse{
+		Id:          e.Id,
+		EmployeeId:  e.EmployeeId,
+		Transition:  e.Transition,
+		FromStatus:  e.FromStatus,
+		ToStatus:    e.ToStatus,
+		EffectiveAt: e.EffectiveAt,
+		Reason:      e.Reason,
+		Status:      e.Status,
+		CreatedBy:   e.CreatedBy,
+		AppliedAt:   e.AppliedAt,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+type TransitionResponse struct {
+	Id          int64      `json:"id"`
+	EmployeeId  int64      `json:"employee_id"`
+	Transition  string     `json:"transition"`
+	FromStatus  string     `json:"from_status"`
+	ToStatus    string     `json:"to_status"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`
+	CreatedBy   string     `json:"created_by"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
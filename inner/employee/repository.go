@@ -3,6 +3,9 @@ package employee
 import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
 )
 
 type Repository struct {
@@ -13,46 +16,162 @@ func NewRepository(database *sqlx.DB) *Repository {
 	return &Repository{db: database}
 }
 
+const insertQuery = `insert into employee
+	(name, role_id, login, status, email, title, phone, hire_date, employee_number, attributes)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id`
+
+func insertArgs(e Entity) []any {
+	return []any{e.Name, e.RoleId, e.Login, e.Status, e.Email, e.Title, e.Phone, e.HireDate, e.EmployeeNumber, e.Attributes}
+}
+
 func (r *Repository) Save(employee *Entity) (id int64, err error) {
-	query := "insert into employee (name) values ($1) returning id"
-	err = r.db.QueryRowx(query, employee.Name).Scan(&id)
+	err = r.db.QueryRowx(insertQuery, insertArgs(*employee)...).Scan(&id)
 	return id, err
 }
 
 func (r *Repository) FindById(id int64) (employee Entity, err error) {
-	query := "select * from employee where id = $1"
+	query := "select * from employee where id = $1 and deleted_at is null"
 	err = r.db.Get(&employee, query, id)
 	return employee, err
 }
 
+// FindByIdAsOf найти сотрудника в том состоянии, в котором он был в момент at
+func (r *Repository) FindByIdAsOf(id int64, at time.Time) (employee Entity, err error) {
+	var version HistoryEntity
+	query := `select * from employee_history where id = $1 and deleted_at is null
+		and version_from <= $2 and (version_to is null or version_to > $2)`
+	err = r.db.Get(&version, query, id, at)
+	return version.Entity, err
+}
+
+// FindHistory все версии сотрудника, в том числе после мягкого удаления, от первой к последней
+func (r *Repository) FindHistory(id int64) (versions []HistoryEntity, err error) {
+	query := "select * from employee_history where id = $1 order by version_from"
+	err = r.db.Select(&versions, query, id)
+	return versions, err
+}
+
 func (r *Repository) FindAll() (employees []Entity, err error) {
-	query := "select * from employee"
+	query := "select * from employee where deleted_at is null"
 	err = r.db.Select(&employees, query)
 	return employees, err
 }
 
+// FindPage найти страницу сотрудников по фильтру; возвращает до Limit+1 записей,
+// лишняя запись означает, что есть следующая страница
+// Если задан request.AsOf, страница строится по версиям из employee_history на этот момент.
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (employees []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	if request.Offset > 0 {
+		order += conditions.Offset(request.Offset)
+	}
+	query := "select * from " + listTable(request) + conditions.Where() + order
+	if request.AsOf == nil {
+		err = r.db.Select(&employees, query, conditions.Args()...)
+		return employees, err
+	}
+	var versions []HistoryEntity
+	err = r.db.Select(&versions, query, conditions.Args()...)
+	return entitiesOf(versions), err
+}
+
+// Count количество сотрудников, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from " + listTable(request) + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+// listTable таблица, из которой строится список: текущие строки или их история
+func listTable(request ListRequest) string {
+	if request.AsOf != nil {
+		return "employee_history"
+	}
+	return "employee"
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.AsOf != nil {
+		conditions.AsOf(*request.AsOf)
+	}
+	if !request.IncludeDeleted {
+		conditions.Add("deleted_at is null")
+	}
+	if request.NamePrefix != "" {
+		conditions.Add("name like ?", database.LikePrefix(request.NamePrefix))
+	}
+	if request.CreatedAfter != nil {
+		conditions.Add("created_at > ?", *request.CreatedAfter)
+	}
+	if request.Filter != nil {
+		conditions.Add("("+request.Filter.Condition+")", request.Filter.Args...)
+	}
+	if request.RoleId != nil {
+		condition, args := heldRole(request, *request.RoleId)
+		conditions.Add(condition, args...)
+	}
+	if request.Status != "" {
+		conditions.Add("status = ?", request.Status)
+	}
+	return conditions
+}
+
+// heldRole роль основная или выдана действующим назначением; для списка на момент AsOf назначения
+// берутся из версий employee_role_history, действовавших в тот момент
+func heldRole(request ListRequest, roleId int64) (string, []any) {
+	if request.AsOf == nil {
+		return `(role_id = ? or exists(select 1 from employee_role er
+			where er.employee_id = employee.id and er.role_id = ?
+			and er.valid_from <= now() and (er.valid_to is null or er.valid_to > now())))`, []any{roleId, roleId}
+	}
+	at := *request.AsOf
+	return `(role_id = ? or exists(select 1 from employee_role_history er
+		where er.employee_id = employee_history.id and er.role_id = ?
+		and er.valid_from <= ? and (er.valid_to is null or er.valid_to > ?)
+		and er.version_from <= ? and (er.version_to is null or er.version_to > ?)))`,
+		[]any{roleId, roleId, at, at, at, at}
+}
+
+// Search найти сотрудников, чьё имя похоже на query, в порядке убывания схожести.
+// Сравнение идёт по normalize_name (без регистра, диакритики и различия ё/е): совпадают имена,
+// содержащие запрос, похожие на него целиком или содержащие похожее слово.
+func (r *Repository) Search(query string, limit int) (employees []SearchEntity, err error) {
+	sql := `select e.*, greatest(similarity(n.name, q.query), word_similarity(q.query, n.name)) as rank
+		from employee e
+		cross join lateral (select normalize_name(e.name) as name) n
+		cross join (select normalize_name($1) as query) q
+		where e.deleted_at is null and (n.name % q.query or q.query <% n.name or n.name like '%' || normalize_name($2) || '%')
+		order by rank desc, e.name, e.id
+		limit $3`
+	err = r.db.Select(&employees, sql, query, database.LikeEscape(query), limit)
+	return employees, err
+}
+
 func (r *Repository) FindAllByIds(ids []int64) (employees []Entity, err error) {
 	if len(ids) == 0 {
 		return []Entity{}, nil
 	}
-	query := "select * from employee where id = ANY($1)"
+	query := "select * from employee where id = ANY($1) and deleted_at is null"
 	err = r.db.Select(&employees, query, pq.Array(ids))
 	return employees, err
 }
 
-func (r *Repository) DeleteById(id int64) (err error) {
-	query := "delete from employee where id=$1"
-	_, err = r.db.Exec(query, id)
-	return err
+// LockByIdTx найти сотрудника, в том числе удалённого, и заблокировать строку до конца транзакции
+func (r *Repository) LockByIdTx(tx *sqlx.Tx, id int64) (employee Entity, err error) {
+	query := "select * from employee where id = $1 for update"
+	err = tx.Get(&employee, query, id)
+	return employee, err
 }
 
-func (r *Repository) DeleteAllByIds(ids []int64) (err error) {
-	if len(ids) == 0 {
-		return nil
-	}
-	query := "delete from employee where id = ANY($1)"
-	_, err = r.db.Exec(query, pq.Array(ids))
-	return err
+// DeleteTx мягко удалить сотрудников: строки остаются в таблице до очистки Purge.
+// Возвращает только те строки, которые были удалены этим вызовом.
+func (r *Repository) DeleteTx(tx *sqlx.Tx, ids []int64) (deleted []Entity, err error) {
+	query := "update employee set deleted_at = now() where id = ANY($1) and deleted_at is null returning *"
+	err = tx.Select(&deleted, query, pq.Array(ids))
+	return deleted, err
 }
 
 func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
@@ -60,13 +179,167 @@ func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
 }
 
 func (r *Repository) FindByNameTx(tx *sqlx.Tx, name string) (exists bool, err error) {
-	query := "select exists(select 1 from employee where name = $1)"
+	query := "select exists(select 1 from employee where name = $1 and deleted_at is null)"
 	err = tx.Get(&exists, query, name)
 	return exists, err
 }
 
 func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (id int64, err error) {
-	query := "insert into employee (name) values ($1) returning id"
-	err = tx.QueryRowx(query, e.Name).Scan(&id)
+	err = tx.QueryRowx(insertQuery, insertArgs(e)...).Scan(&id)
 	return id, err
 }
+
+// UpdateRoleTx назначить сотруднику роль или снять её, если roleId равен nil
+func (r *Repository) UpdateRoleTx(tx *sqlx.Tx, id int64, roleId *int64) (err error) {
+	query := "update employee set role_id = $1, updated_at = now() where id = $2 and deleted_at is null"
+	_, err = tx.Exec(query, roleId, id)
+	return err
+}
+
+// FindByNameExceptTx есть ли другой сотрудник с таким именем
+func (r *Repository) FindByNameExceptTx(tx *sqlx.Tx, name string, id int64) (exists bool, err error) {
+	query := "select exists(select 1 from employee where name = $1 and id <> $2 and deleted_at is null)"
+	err = tx.Get(&exists, query, name, id)
+	return exists, err
+}
+
+// FindByLoginExceptTx занят ли login другим неудалённым сотрудником; при создании id равен 0
+func (r *Repository) FindByLoginExceptTx(tx *sqlx.Tx, login string, id int64) (exists bool, err error) {
+	query := "select exists(select 1 from employee where login = $1 and id <> $2 and deleted_at is null)"
+	err = tx.Get(&exists, query, login, id)
+	return exists, err
+}
+
+// FindByEmployeeNumberExceptTx занят ли табельный номер другим неудалённым сотрудником; при создании id равен 0
+func (r *Repository) FindByEmployeeNumberExceptTx(tx *sqlx.Tx, number string, id int64) (exists bool, err error) {
+	query := "select exists(select 1 from employee where employee_number = $1 and id <> $2 and deleted_at is null)"
+	err = tx.Get(&exists, query, number, id)
+	return exists, err
+}
+
+// FindByLogin найти неудалённого сотрудника по субъекту токена доступа
+func (r *Repository) FindByLogin(login string) (employee Entity, err error) {
+	query := "select * from employee where login = $1 and deleted_at is null"
+	err = r.db.Get(&employee, query, login)
+	return employee, err
+}
+
+// UpdateTx обновить сотрудника. Если version не nil, обновление выполняется только при совпадении
+// updated_at с version; updated равен false, если подходящая строка не найдена.
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (updated bool, err error) {
+	query := `update employee set name = $1, role_id = $2, login = $3, email = $4, title = $5, phone = $6,
+		hire_date = $7, employee_number = $8, attributes = $9, updated_at = now()
+		where id = $10 and deleted_at is null and ($11::timestamptz is null or updated_at = $11)`
+	result, err := tx.Exec(query, e.Name, e.RoleId, e.Login, e.Email, e.Title, e.Phone,
+		e.HireDate, e.EmployeeNumber, e.Attributes, e.Id, version)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RestoreTx снять пометку удаления с сотрудника и вернуть его; sql.ErrNoRows, если сотрудника нет
+func (r *Repository) RestoreTx(tx *sqlx.Tx, id int64) (employee Entity, err error) {
+	query := "update employee set deleted_at = null, updated_at = now() where id = $1 returning *"
+	err = tx.Get(&employee, query, id)
+	return employee, err
+}
+
+// UpdateStatusTx перевести сотрудника в состояние status с основной ролью roleId
+func (r *Repository) UpdateStatusTx(tx *sqlx.Tx, id int64, status string, roleId *int64) (err error) {
+	query := "update employee set status = $2, role_id = $3, updated_at = now() where id = $1 and deleted_at is null"
+	_, err = tx.Exec(query, id, status, roleId)
+	return err
+}
+
+func (r *Repository) SaveTransitionTx(tx *sqlx.Tx, e TransitionEntity) (saved TransitionEntity, err error) {
+	query := `insert into employee_transition
+		(employee_id, transition, from_status, to_status, effective_at, reason, status, created_by, applied_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		returning *`
+	err = tx.Get(&saved, query, e.EmployeeId, e.Transition, e.FromStatus, e.ToStatus, e.EffectiveAt, e.Reason,
+		e.Status, e.CreatedBy, e.AppliedAt)
+	return saved, err
+}
+
+// FindTransitions переходы сотрудника, от новых к старым
+func (r *Repository) FindTransitions(employeeId int64) (transitions []TransitionEntity, err error) {
+	query := "select * from employee_transition where employee_id = $1 order by id desc"
+	err = r.db.Select(&transitions, query, employeeId)
+	return transitions, err
+}
+
+// FindPendingTransitionTx ожидающий переход сотрудника с блокировкой; sql.ErrNoRows, если его нет
+func (r *Repository) FindPendingTransitionTx(tx *sqlx.Tx, employeeId int64) (transition TransitionEntity, err error) {
+	query := "select * from employee_transition where employee_id = $1 and status = 'pending' for update"
+	err = tx.Get(&transition, query, employeeId)
+	return transition, err
+}
+
+// FindDueTransitions ожидающие переходы, дата которых наступила к моменту at, в порядке дат
+func (r *Repository) FindDueTransitions(at time.Time) (transitions []TransitionEntity, err error) {
+	query := "select * from employee_transition where status = 'pending' and effective_at <= $1 order by effective_at, id"
+	err = r.db.Select(&transitions, query, at)
+	return transitions, err
+}
+
+// CompleteTransitionTx перевести ожидающий переход в конечное состояние status
+func (r *Repository) CompleteTransitionTx(
+	tx *sqlx.Tx,
+	id int64,
+	status string,
+	appliedAt *time.Time,
+) (completed TransitionEntity, err error) {
+	query := `update employee_transition set status = $2, applied_at = $3
+		where id = $1 and status = 'pending' returning *`
+	err = tx.Get(&completed, query, id, status, appliedAt)
+	return completed, err
+}
+
+func (r *Repository) UpdateOrgUnitTx(tx *sqlx.Tx, id int64, orgUnitId *int64) (err error) {
+	query := "update employee set org_unit_id = $1, updated_at = now() where id = $2 and deleted_at is null"
+	_, err = tx.Exec(query, orgUnitId, id)
+	return err
+}
+
+func (r *Repository) UpdateManagerTx(tx *sqlx.Tx, id int64, managerId *int64) (err error) {
+	query := "update employee set manager_id = $1, updated_at = now() where id = $2 and deleted_at is null"
+	_, err = tx.Exec(query, managerId, id)
+	return err
+}
+
+// reportingLinesLock ключ advisory-блокировки линий подчинения
+const reportingLinesLock = 20251005
+
+// LockReportingLinesTx заблокировать смену руководителей до конца транзакции, чтобы параллельные изменения
+// не смогли создать цикл в обход проверки. Остальные изменения сотрудников блокировка не задерживает
+func (r *Repository) LockReportingLinesTx(tx *sqlx.Tx) (err error) {
+	_, err = tx.Exec("select pg_advisory_xact_lock($1)", reportingLinesLock)
+	return err
+}
+
+// IsReportTx подчиняется ли сотрудник candidateId сотруднику managerId напрямую или через других
+func (r *Repository) IsReportTx(tx *sqlx.Tx, managerId, candidateId int64) (found bool, err error) {
+	query := `with recursive report(id) as (
+		select id from employee where manager_id = $1
+		union
+		select e.id from employee e join report r on e.manager_id = r.id
+	)
+	select exists(select 1 from report where id = $2)`
+	err = tx.Get(&found, query, managerId, candidateId)
+	return found, err
+}
+
+// Purge окончательно удалить сотрудников, мягко удалённых раньше before. Сотрудники, на которых ссылаются
+// запросы доступа или исключения из правил разделения обязанностей, остаются: эти записи нужны аудиту
+func (r *Repository) Purge(before time.Time) (purged int64, err error) {
+	query := `delete from employee e where e.deleted_at < $1
+		and not exists(select 1 from access_request ar where ar.employee_id = e.id)
+		and not exists(select 1 from sod_override so where so.employee_id = e.id)`
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
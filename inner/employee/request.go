@@ -1,17 +1,150 @@
 package employee
 
+import (
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
 type CreateRequest struct {
-	Name string `json:"name" validate:"required,min=2,max=155"`
+	Name   string `json:"name" validate:"required,min=2,max=155"`
+	RoleId *int64 `json:"role_id" validate:"omitempty,gt=0"`
+	// Login субъект токена доступа сотрудника; уникален среди неудалённых сотрудников
+	Login *string `json:"login" validate:"omitempty,min=1,max=255"`
+	// Status начальное состояние: pre_hire для будущих сотрудников, по умолчанию active
+	Status         string       `json:"status" validate:"omitempty,oneof=pre_hire active"`
+	Email          *string      `json:"email" validate:"omitempty,email,max=255"`
+	Title          *string      `json:"title" validate:"omitempty,min=1,max=155"`
+	Phone          *string      `json:"phone" validate:"omitempty,e164"`
+	HireDate       *common.Date `json:"hire_date"`
+	EmployeeNumber *string      `json:"employee_number" validate:"omitempty,min=1,max=64"`
+	// Attributes пользовательские атрибуты; проверяются по схеме атрибутов
+	Attributes Attributes `json:"attributes"`
 }
 
 func (r *CreateRequest) ToEntity() Entity {
-	return Entity{Name: r.Name}
+	status := r.Status
+	if status == "" {
+		status = StatusActive
+	}
+	return Entity{
+		Name:           r.Name,
+		RoleId:         r.RoleId,
+		Login:          r.Login,
+		Status:         status,
+		Email:          r.Email,
+		Title:          r.Title,
+		Phone:          r.Phone,
+		HireDate:       r.HireDate,
+		EmployeeNumber: r.EmployeeNumber,
+		Attributes:     r.Attributes,
+	}
 }
 
 type IdRequest struct {
 	Id int64 `json:"id" validate:"required,gt=0"`
+	// AsOf момент, на который нужно восстановить состояние сотрудника; nil - текущее состояние
+	AsOf *time.Time `json:"as_of"`
 }
 
 type IdsRequest struct {
 	Ids []int64 `json:"ids" validate:"required,min=1,dive,gt=0"`
 }
+
+// ListRequest параметры списка сотрудников: страница и необязательные фильтры
+type ListRequest struct {
+	common.PageRequest
+	NamePrefix   string     `json:"name_prefix" validate:"max=155"`
+	CreatedAfter *time.Time `json:"created_after"`
+	// RoleId фильтр по роли: основной (employee.role_id) или выданной действующим назначением
+	RoleId *int64 `json:"role_id" validate:"omitempty,gt=0"`
+	// Status фильтр по состоянию в жизненном цикле
+	Status string `json:"status" validate:"omitempty,oneof=pre_hire active suspended terminated"`
+	// IncludeDeleted включить в список мягко удалённых сотрудников
+	IncludeDeleted bool `json:"include_deleted"`
+	// AsOf построить список по состоянию на этот момент; nil - текущее состояние
+	AsOf *time.Time `json:"as_of"`
+	// Filter дополнительное условие на колонки таблицы; задаётся только кодом, например SCIM
+	Filter *database.Filter `json:"-"`
+	// Offset пропустить столько записей от начала выборки; для нумерованных страниц SCIM
+	Offset int `json:"-" validate:"min=0"`
+}
+
+// SearchRequest нечёткий поиск сотрудников по имени
+type SearchRequest struct {
+	Query string `json:"q" validate:"required,min=2,max=155"`
+	Limit int    `json:"limit" validate:"min=1,max=100"`
+}
+
+type SetRoleRequest struct {
+	Id     int64 `json:"-" validate:"required,gt=0"`
+	RoleId int64 `json:"role_id" validate:"required,gt=0"`
+}
+
+type SetOrgUnitRequest struct {
+	Id        int64 `json:"-" validate:"required,gt=0"`
+	OrgUnitId int64 `json:"org_unit_id" validate:"required,gt=0"`
+}
+
+// SetManagerRequest сотрудник не может быть руководителем сам себе
+type SetManagerRequest struct {
+	Id        int64 `json:"-" validate:"required,gt=0"`
+	ManagerId int64 `json:"manager_id" validate:"required,gt=0,nefield=Id"`
+}
+
+// UpdateRequest полная замена данных сотрудника (PUT)
+type UpdateRequest struct {
+	Id             int64        `json:"-" validate:"required,gt=0"`
+	Name           string       `json:"name" validate:"required,min=2,max=155"`
+	RoleId         *int64       `json:"role_id" validate:"omitempty,gt=0"`
+	Login          *string      `json:"login" validate:"omitempty,min=1,max=255"`
+	Email          *string      `json:"email" validate:"omitempty,email,max=255"`
+	Title          *string      `json:"title" validate:"omitempty,min=1,max=155"`
+	Phone          *string      `json:"phone" validate:"omitempty,e164"`
+	HireDate       *common.Date `json:"hire_date"`
+	EmployeeNumber *string      `json:"employee_number" validate:"omitempty,min=1,max=64"`
+	Attributes     Attributes   `json:"attributes"`
+	// Version ожидаемое время последнего изменения из заголовка If-Match; nil - без проверки
+	Version *time.Time `json:"-"`
+}
+
+func (r *UpdateRequest) ToEntity() Entity {
+	return Entity{
+		Id:             r.Id,
+		Name:           r.Name,
+		RoleId:         r.RoleId,
+		Login:          r.Login,
+		Email:          r.Email,
+		Title:          r.Title,
+		Phone:          r.Phone,
+		HireDate:       r.HireDate,
+		EmployeeNumber: r.EmployeeNumber,
+		Attributes:     r.Attributes,
+	}
+}
+
+// PatchRequest частичное изменение сотрудника (PATCH): меняются только переданные поля,
+// "role_id": null снимает роль. Attributes сливаются с текущими: переданные ключи заменяются,
+// ключ со значением null удаляется, а "attributes": null удаляет все атрибуты
+type PatchRequest struct {
+	Id             int64                        `json:"-" validate:"required,gt=0"`
+	Name           common.Optional[string]      `json:"name"`
+	RoleId         common.Optional[int64]       `json:"role_id"`
+	Login          common.Optional[string]      `json:"login"`
+	Email          common.Optional[string]      `json:"email"`
+	Title          common.Optional[string]      `json:"title"`
+	Phone          common.Optional[string]      `json:"phone"`
+	HireDate       common.Optional[common.Date] `json:"hire_date"`
+	EmployeeNumber common.Optional[string]      `json:"employee_number"`
+	Attributes     common.Optional[Attributes]  `json:"attributes"`
+	Version        *time.Time                   `json:"-"`
+}
+
+// TransitionRequest перевести сотрудника в другое состояние. Без EffectiveAt переход применяется сразу,
+// с датой в будущем - ожидает её
+type TransitionRequest struct {
+	Id          int64      `json:"-" validate:"required,gt=0"`
+	Transition  string     `json:"-" validate:"required,oneof=hire suspend resume terminate rehire"`
+	EffectiveAt *time.Time `json:"effective_at"`
+	Reason      string     `json:"reason" validate:"max=1000"`
+}
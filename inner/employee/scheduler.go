@@ -0,0 +1,48 @@
+package employee
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"time"
+)
+
+// schedulerActor автор переходов, применённых в дату вступления в силу, в журнале аудита
+const schedulerActor = "system:employee-lifecycle"
+
+type ApplySvc interface {
+	ApplyDue(ctx context.Context, now time.Time) (int, error)
+}
+
+// Scheduler применяет переходы по жизненному циклу, дата вступления в силу которых наступила
+type Scheduler struct {
+	svc    ApplySvc
+	logger *common.Logger
+}
+
+func NewScheduler(svc ApplySvc, logger *common.Logger) *Scheduler {
+	return &Scheduler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+// Run применять наступившие переходы сразу и затем каждые interval, пока не отменён ctx
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		applied, err := s.svc.ApplyDue(common.WithActor(ctx, schedulerActor), time.Now())
+		if err != nil {
+			s.logger.Error("employee transitions: applying failed", zap.Error(err))
+		}
+		if applied > 0 {
+			s.logger.Info("employee transitions: applied", zap.Int("count", applied))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,45 +1,191 @@
 package employee
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"idm/inner/attribute"
+	"idm/inner/audit"
 	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/orgunit"
+	"idm/inner/role"
+	"idm/inner/sod"
+	"idm/inner/validator"
+	"slices"
+	"strings"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "employee"
+
+// auditEntityTypeTransition ожидающий переход по жизненному циклу в журнале аудита;
+// применённый переход записывается как действие над сотрудником
+const auditEntityTypeTransition = "employee_transition"
+
+// auditActionCancel отмена ожидающего перехода в журнале аудита
+const auditActionCancel = "cancel"
+
+// defaultSearchLimit количество результатов поиска, если limit не передан
+const defaultSearchLimit = 20
+
+// Разрешения, которые сервис требует сверх разрешения маршрута
+const (
+	// permissionLogin смена логина сотрудника
+	permissionLogin = "employees:login"
+	// permissionAssign и permissionUnassign основная роль даёт права наравне с назначениями
+	permissionAssign   = "assignments:create"
+	permissionUnassign = "assignments:delete"
 )
 
 type Service struct {
-	repo      Repo
-	validator Validator
+	repo           Repo
+	roleRepo       RoleRepo
+	orgUnitRepo    OrgUnitRepo
+	attributeRepo  AttributeRepo
+	assignmentRepo AssignmentRepo
+	revoker        Revoker
+	rules          RuleApplier
+	sod            SodChecker
+	access         AccessChecker
+	auditor        Auditor
+	validator      Validator
 }
 
 type Repo interface {
 	Save(e *Entity) (int64, error)
 	SaveTx(tx *sqlx.Tx, e Entity) (int64, error)
 	FindById(id int64) (Entity, error)
-	FindAll() ([]Entity, error)
+	FindByIdAsOf(id int64, at time.Time) (Entity, error)
+	FindHistory(id int64) ([]HistoryEntity, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
+	Search(query string, limit int) ([]SearchEntity, error)
+	RestoreTx(tx *sqlx.Tx, id int64) (Entity, error)
 	FindAllByIds(ids []int64) ([]Entity, error)
-	DeleteById(id int64) error
-	DeleteAllByIds(ids []int64) error
+	DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error)
+	LockByIdTx(tx *sqlx.Tx, id int64) (Entity, error)
 	FindByNameTx(tx *sqlx.Tx, name string) (bool, error)
 	BeginTransaction() (*sqlx.Tx, error)
+	UpdateRoleTx(tx *sqlx.Tx, id int64, roleId *int64) error
+	FindByNameExceptTx(tx *sqlx.Tx, name string, id int64) (bool, error)
+	FindByLoginExceptTx(tx *sqlx.Tx, login string, id int64) (bool, error)
+	FindByEmployeeNumberExceptTx(tx *sqlx.Tx, number string, id int64) (bool, error)
+	UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (bool, error)
+	UpdateStatusTx(tx *sqlx.Tx, id int64, status string, roleId *int64) error
+	SaveTransitionTx(tx *sqlx.Tx, e TransitionEntity) (TransitionEntity, error)
+	FindTransitions(employeeId int64) ([]TransitionEntity, error)
+	FindPendingTransitionTx(tx *sqlx.Tx, employeeId int64) (TransitionEntity, error)
+	FindDueTransitions(at time.Time) ([]TransitionEntity, error)
+	CompleteTransitionTx(tx *sqlx.Tx, id int64, status string, appliedAt *time.Time) (TransitionEntity, error)
+	UpdateOrgUnitTx(tx *sqlx.Tx, id int64, orgUnitId *int64) error
+	UpdateManagerTx(tx *sqlx.Tx, id int64, managerId *int64) error
+	LockReportingLinesTx(tx *sqlx.Tx) error
+	IsReportTx(tx *sqlx.Tx, managerId, candidateId int64) (bool, error)
+}
+
+// RoleRepo источник ролей, на которые ссылаются сотрудники
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+	FindAllByIds(ids []int64) ([]role.Entity, error)
+	FindByIdAsOf(id int64, at time.Time) (role.Entity, error)
+	FindAllByIdsAsOf(ids []int64, at time.Time) ([]role.Entity, error)
+}
+
+// OrgUnitRepo подразделения, в которые переводятся сотрудники
+type OrgUnitRepo interface {
+	FindById(id int64) (orgunit.Entity, error)
+}
+
+// AttributeRepo схема пользовательских атрибутов, по которой проверяется каждое изменение сотрудника.
+// Схема читается в транзакции изменения, чтобы её не изменили между проверкой и записью
+type AttributeRepo interface {
+	FindAllTx(tx *sqlx.Tx) ([]attribute.Entity, error)
+}
+
+// AssignmentRepo источник назначений ролей сотрудникам (таблица employee_role)
+type AssignmentRepo interface {
+	FindEffectiveRoles(employeeId int64, at time.Time) ([]role.Entity, error)
+	FindEffectiveRolesAsOf(employeeId int64, at time.Time) ([]role.Entity, error)
+}
+
+// Revoker отзывает назначения ролей уволенного сотрудника в транзакции перехода
+type Revoker interface {
+	RevokeAllTx(ctx context.Context, tx *sqlx.Tx, employeeId int64) error
+}
+
+// RuleApplier выдаёт и отзывает назначения по правилам назначения в транзакции изменения сотрудника
+type RuleApplier interface {
+	ApplyTx(ctx context.Context, tx *sqlx.Tx, e Entity) error
+}
+
+// SodChecker правила разделения обязанностей: основная роль даёт разрешения наравне с назначениями
+// и проверяется по ним так же
+type SodChecker interface {
+	FindConflictsTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) ([]sod.Entity, error)
+}
+
+// AccessChecker разрешения автора запроса: смена основной роли и логина требует разрешений
+// сверх employees:update
+type AccessChecker interface {
+	Holds(ctx context.Context, permission string) (bool, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
 }
 
 type Validator interface {
 	Validate(request any) error
+	ValidateAttributes(values map[string]any, schema []validator.Attribute) error
 }
 
-func NewService(repo Repo, validator Validator) *Service {
+func NewService(
+	repo Repo,
+	roleRepo RoleRepo,
+	orgUnitRepo OrgUnitRepo,
+	attributeRepo AttributeRepo,
+	assignmentRepo AssignmentRepo,
+	revoker Revoker,
+	rules RuleApplier,
+	sod SodChecker,
+	access AccessChecker,
+	auditor Auditor,
+	validator Validator,
+) *Service {
 	return &Service{
-		repo:      repo,
-		validator: validator,
+		repo:           repo,
+		roleRepo:       roleRepo,
+		orgUnitRepo:    orgUnitRepo,
+		attributeRepo:  attributeRepo,
+		assignmentRepo: assignmentRepo,
+		revoker:        revoker,
+		rules:          rules,
+		sod:            sod,
+		access:         access,
+		auditor:        auditor,
+		validator:      validator,
 	}
 }
 
-func (svc *Service) Create(request CreateRequest) (int64, error) {
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (int64, error) {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		// возвращаем кастомную ошибку в случае, если запрос не прошёл валидацию
 		return 0, common.RequestValidationError{Message: err.Error()}
 	}
+	if request.RoleId != nil {
+		if _, err = svc.findRole(*request.RoleId); err != nil {
+			return 0, err
+		}
+		if err = svc.require(ctx, permissionAssign, "create employee with role"); err != nil {
+			return 0, err
+		}
+	}
 
 	tx, err := svc.repo.BeginTransaction()
 
@@ -78,42 +224,174 @@ func (svc *Service) Create(request CreateRequest) (int64, error) {
 		return 0, common.AlreadyExistsError{
 			Message: fmt.Sprintf("employee with name %s already exists", request.Name)}
 	}
+	if err = svc.checkLogin(tx, request.Login, 0); err != nil {
+		return 0, err
+	}
+	if err = svc.checkEmployeeNumber(tx, request.EmployeeNumber, 0); err != nil {
+		return 0, err
+	}
+	if err = svc.checkAttributes(tx, request.Attributes); err != nil {
+		return 0, err
+	}
 
-	newEmployeeId, err := svc.repo.SaveTx(tx, request.ToEntity())
+	entity := request.ToEntity()
+	newEmployeeId, err := svc.repo.SaveTx(tx, entity)
 	if err != nil {
 		return 0, fmt.Errorf("error saving employee with name: %s %w", request.Name, err)
 	}
+	entity.Id = newEmployeeId
+	if err = svc.checkSodTx(tx, newEmployeeId, nil, entity.RoleId); err != nil {
+		return 0, err
+	}
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionCreate,
+		EntityType: auditEntityType,
+		EntityId:   newEmployeeId,
+		After:      entity.auditSnapshot(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = svc.applyRulesTx(ctx, tx, entity); err != nil {
+		return 0, err
+	}
 	return newEmployeeId, nil
 }
 
+// FindById найти сотрудника вместе с ролями; если задан request.AsOf, сотрудник, его основная
+// роль и действующие назначения восстанавливаются по истории на этот момент
 func (svc *Service) FindById(request IdRequest) (Response, error) {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		return Response{}, common.RequestValidationError{Message: err.Error()}
 	}
-	entity, err := svc.repo.FindById(request.Id)
+	var entity Entity
+	if request.AsOf != nil {
+		entity, err = svc.repo.FindByIdAsOf(request.Id, *request.AsOf)
+	} else {
+		entity, err = svc.repo.FindById(request.Id)
+	}
 	if err != nil {
 		return Response{}, common.NotFoundError{
 			Message: fmt.Sprintf("error finding employee with id %d: %v", request.Id, err),
 		}
 	}
-	return entity.toResponse(), nil
+	response := entity.toResponse()
+	if entity.RoleId != nil {
+		var found role.Entity
+		if request.AsOf != nil {
+			found, err = svc.roleRepo.FindByIdAsOf(*entity.RoleId, *request.AsOf)
+		} else {
+			found, err = svc.roleRepo.FindById(*entity.RoleId)
+		}
+		// удалённая основная роль не даёт прав и, как в списке сотрудников, не показывается
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return Response{}, fmt.Errorf("error finding role of employee with id %d: %w", request.Id, err)
+		}
+		if err == nil {
+			response.Role = &RoleResponse{Id: found.Id, Name: found.Name}
+		}
+	}
+	var effective []role.Entity
+	if request.AsOf != nil {
+		effective, err = svc.assignmentRepo.FindEffectiveRolesAsOf(request.Id, *request.AsOf)
+	} else {
+		effective, err = svc.assignmentRepo.FindEffectiveRoles(request.Id, time.Now())
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("error finding effective roles of employee with id %d: %w", request.Id, err)
+	}
+	response.Roles = make([]RoleResponse, 0, len(effective))
+	for _, r := range effective {
+		response.Roles = append(response.Roles, RoleResponse{Id: r.Id, Name: r.Name})
+	}
+	return response, nil
 }
 
-func (svc *Service) FindAll() ([]Response, error) {
-	entities, err := svc.repo.FindAll()
+// FindAll найти страницу сотрудников по фильтру. Без параметров возвращается первая страница
+// размером common.DefaultPageLimit в порядке id.
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Defaults()
+	err := svc.validator.Validate(request)
 	if err != nil {
-		return nil, common.NotFoundError{
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entities, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, common.NotFoundError{
 			Message: fmt.Sprintf("error retrieving all employees: %v", err),
 		}
 	}
-	responses := make([]Response, 0, len(entities))
-	for _, entity := range entities {
-		responses = append(responses, entity.toResponse())
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting employees: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(entities) > request.Limit {
+		entities = entities[:request.Limit]
+		last := entities[len(entities)-1]
+		page.NextCursor = request.Next(last.sortValue(request.Sort), last.Id)
+	}
+	page.Items, err = svc.toResponses(entities, request.AsOf)
+	return page, err
+}
+
+// History все версии сотрудника от первой к последней
+func (svc *Service) History(request IdRequest) ([]HistoryResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	versions, err := svc.repo.FindHistory(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error finding history of employee with id %d: %w", request.Id, err)
+	}
+	if len(versions) == 0 {
+		return nil, common.NotFoundError{Message: fmt.Sprintf("employee with id %d not found", request.Id)}
+	}
+	responses := make([]HistoryResponse, 0, len(versions))
+	for _, version := range versions {
+		responses = append(responses, version.toResponse())
 	}
 	return responses, nil
 }
 
+// Search нечёткий поиск сотрудников по имени с ранжированием и подсветкой совпадений
+func (svc *Service) Search(request SearchRequest) ([]SearchResponse, error) {
+	if request.Limit == 0 {
+		request.Limit = defaultSearchLimit
+	}
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	found, err := svc.repo.Search(request.Query, request.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching employees by %q: %w", request.Query, err)
+	}
+	entities := make([]Entity, 0, len(found))
+	for _, f := range found {
+		entities = append(entities, f.Entity)
+	}
+	responses, err := svc.toResponses(entities, nil)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResponse, 0, len(found))
+	for i, f := range found {
+		results = append(results, SearchResponse{
+			Response:   responses[i],
+			Rank:       f.Rank,
+			Highlights: common.Highlight(f.Name, request.Query),
+		})
+	}
+	return results, nil
+}
+
 func (svc *Service) FindAllByIds(request IdsRequest) ([]Response, error) {
 	err := svc.validator.Validate(request)
 	if err != nil {
@@ -125,19 +403,15 @@ func (svc *Service) FindAllByIds(request IdsRequest) ([]Response, error) {
 			Message: fmt.Sprintf("error retrieving employees by ids %v: %v", request.Ids, err),
 		}
 	}
-	responses := make([]Response, 0, len(entities))
-	for _, entity := range entities {
-		responses = append(responses, entity.toResponse())
-	}
-	return responses, nil
+	return svc.toResponses(entities, nil)
 }
 
-func (svc *Service) DeleteById(request IdRequest) error {
+func (svc *Service) DeleteById(ctx context.Context, request IdRequest) error {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		return common.RequestValidationError{Message: err.Error()}
 	}
-	err = svc.repo.DeleteById(request.Id)
+	err = svc.delete(ctx, []int64{request.Id})
 	if err != nil {
 		return common.NotFoundError{
 			Message: fmt.Sprintf("error deleting employee with id %d: %v", request.Id, err),
@@ -146,12 +420,12 @@ func (svc *Service) DeleteById(request IdRequest) error {
 	return nil
 }
 
-func (svc *Service) DeleteAllByIds(request IdsRequest) error {
+func (svc *Service) DeleteAllByIds(ctx context.Context, request IdsRequest) error {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		return common.RequestValidationError{Message: err.Error()}
 	}
-	err = svc.repo.DeleteAllByIds(request.Ids)
+	err = svc.delete(ctx, request.Ids)
 	if err != nil {
 		return common.NotFoundError{
 			Message: fmt.Sprintf("error deleting employees by ids %v: %v", request.Ids, err),
@@ -159,3 +433,804 @@ func (svc *Service) DeleteAllByIds(request IdsRequest) error {
 	}
 	return nil
 }
+
+// delete мягко удалить сотрудников и записать в журнал каждого действительно удалённого
+func (svc *Service) delete(ctx context.Context, ids []int64) error {
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting employees", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, entity := range deleted {
+			before := entity
+			before.DeletedAt = nil
+			err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+				Action:     audit.ActionDelete,
+				EntityType: auditEntityType,
+				EntityId:   entity.Id,
+				Before:     before.auditSnapshot(),
+				After:      entity.auditSnapshot(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update заменить данные сотрудника. При заданной версии изменение применяется, только если сотрудник
+// не менялся с этого момента, иначе возвращается PreconditionFailedError.
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating employee", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		return svc.updateTx(ctx, tx, before, request)
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return svc.FindById(IdRequest{Id: request.Id})
+}
+
+// Patch изменить только переданные поля сотрудника. Поля накладываются на строку, заблокированную
+// в той же транзакции, поэтому параллельный PATCH не затирает уже сохранённые изменения
+func (svc *Service) Patch(ctx context.Context, request PatchRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	err = database.InTransaction(svc.repo.BeginTransaction, "patching employee", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		name := request.Name.Or(&before.Name)
+		if name == nil {
+			return common.RequestValidationError{Message: "name must not be null"}
+		}
+		merged := UpdateRequest{
+			Id:             request.Id,
+			Name:           *name,
+			RoleId:         request.RoleId.Or(before.RoleId),
+			Login:          request.Login.Or(before.Login),
+			Email:          request.Email.Or(before.Email),
+			Title:          request.Title.Or(before.Title),
+			Phone:          request.Phone.Or(before.Phone),
+			HireDate:       request.HireDate.Or(before.HireDate),
+			EmployeeNumber: request.EmployeeNumber.Or(before.EmployeeNumber),
+			Attributes:     mergeAttributes(before.Attributes, request.Attributes),
+			Version:        request.Version,
+		}
+		if err = svc.validator.Validate(merged); err != nil {
+			return common.RequestValidationError{Message: err.Error()}
+		}
+		return svc.updateTx(ctx, tx, before, merged)
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return svc.FindById(IdRequest{Id: request.Id})
+}
+
+// updateTx сохранить новые данные сотрудника before, заблокированного в транзакции tx; версия
+// из If-Match сравнивается с заблокированной строкой
+func (svc *Service) updateTx(ctx context.Context, tx *sqlx.Tx, before Entity, request UpdateRequest) error {
+	if request.Version != nil && common.ETag(before.UpdatedAt) != common.ETag(*request.Version) {
+		return common.PreconditionFailedError{
+			Message: fmt.Sprintf("employee with id %d was modified concurrently", request.Id),
+		}
+	}
+	err := svc.checkNewRole(before, request.RoleId)
+	if err != nil {
+		return err
+	}
+	exists, err := svc.repo.FindByNameExceptTx(tx, request.Name, request.Id)
+	if err != nil {
+		return fmt.Errorf("error finding employee by name: %s %w", request.Name, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{
+			Message: fmt.Sprintf("employee with name %s already exists", request.Name)}
+	}
+	if err = svc.checkLogin(tx, request.Login, request.Id); err != nil {
+		return err
+	}
+	if err = svc.checkEmployeeNumber(tx, request.EmployeeNumber, request.Id); err != nil {
+		return err
+	}
+	if err = svc.checkAttributes(tx, request.Attributes); err != nil {
+		return err
+	}
+	if err = svc.checkAccess(ctx, before, request.RoleId, request.Login); err != nil {
+		return err
+	}
+	after := request.ToEntity()
+	after.Status = before.Status
+	after.OrgUnitId = before.OrgUnitId
+	after.ManagerId = before.ManagerId
+	updated, err := svc.repo.UpdateTx(tx, after, request.Version)
+	if err != nil {
+		return fmt.Errorf("error updating employee with id %d: %w", request.Id, err)
+	}
+	if !updated {
+		// строка заблокирована и существует, значит, не совпала версия
+		return common.PreconditionFailedError{
+			Message: fmt.Sprintf("employee with id %d was modified concurrently", request.Id),
+		}
+	}
+	if err = svc.checkSodTx(tx, request.Id, before.RoleId, after.RoleId); err != nil {
+		return err
+	}
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionUpdate,
+		EntityType: auditEntityType,
+		EntityId:   request.Id,
+		Before:     before.auditSnapshot(),
+		After:      after.auditSnapshot(),
+	})
+	if err != nil {
+		return err
+	}
+	return svc.applyRulesTx(ctx, tx, after)
+}
+
+// mergeAttributes наложить атрибуты из PATCH на текущие: ключ со значением null удаляется,
+// null вместо всего набора удаляет все атрибуты
+func mergeAttributes(current Attributes, patch common.Optional[Attributes]) Attributes {
+	if !patch.Set {
+		return current
+	}
+	if patch.Value == nil {
+		return nil
+	}
+	merged := make(Attributes, len(current)+len(*patch.Value))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range *patch.Value {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// checkLogin login, если он задан, не должен быть занят другим сотрудником (не id)
+func (svc *Service) checkLogin(tx *sqlx.Tx, login *string, id int64) error {
+	if login == nil {
+		return nil
+	}
+	exists, err := svc.repo.FindByLoginExceptTx(tx, *login, id)
+	if err != nil {
+		return fmt.Errorf("error finding employee by login: %s %w", *login, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{Message: fmt.Sprintf("employee with login %s already exists", *login)}
+	}
+	return nil
+}
+
+// checkEmployeeNumber табельный номер, если он задан, не должен быть занят другим сотрудником (не id)
+func (svc *Service) checkEmployeeNumber(tx *sqlx.Tx, number *string, id int64) error {
+	if number == nil {
+		return nil
+	}
+	exists, err := svc.repo.FindByEmployeeNumberExceptTx(tx, *number, id)
+	if err != nil {
+		return fmt.Errorf("error finding employee by number: %s %w", *number, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{Message: fmt.Sprintf("employee with number %s already exists", *number)}
+	}
+	return nil
+}
+
+// checkAttributes проверить пользовательские атрибуты по текущей схеме атрибутов
+func (svc *Service) checkAttributes(tx *sqlx.Tx, values Attributes) error {
+	definitions, err := svc.attributeRepo.FindAllTx(tx)
+	if err != nil {
+		return fmt.Errorf("error retrieving employee attributes: %w", err)
+	}
+	schema := make([]validator.Attribute, 0, len(definitions))
+	for _, definition := range definitions {
+		schema = append(schema, definition.Rule())
+	}
+	if err = svc.validator.ValidateAttributes(values, schema); err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
+// lock заблокировать сотрудника до конца транзакции и вернуть его текущее состояние;
+// удалённый сотрудник считается отсутствующим
+func (svc *Service) lock(tx *sqlx.Tx, id int64) (Entity, error) {
+	entity, err := svc.repo.LockByIdTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && entity.DeletedAt != nil {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("employee with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding employee with id %d: %w", id, err)
+	}
+	return entity, nil
+}
+
+// SetRole назначить сотруднику роль; и сотрудник, и роль должны существовать
+func (svc *Service) SetRole(ctx context.Context, request SetRoleRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.updateRole(ctx, request.Id, &request.RoleId, "setting role")
+}
+
+// Restore вернуть мягко удалённого сотрудника. Если его имя за это время занял другой сотрудник,
+// возвращается AlreadyExistsError, и сотрудник остаётся удалённым.
+func (svc *Service) Restore(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "restoring employee", func(tx *sqlx.Tx) error {
+		before, err := svc.repo.LockByIdTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("employee with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding employee with id %d: %w", request.Id, err)
+		}
+		if before.DeletedAt == nil {
+			return nil
+		}
+		restored, err := svc.repo.RestoreTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error restoring employee with id %d: %w", request.Id, err)
+		}
+		exists, err := svc.repo.FindByNameExceptTx(tx, restored.Name, restored.Id)
+		if err != nil {
+			return fmt.Errorf("error finding employee by name: %s %w", restored.Name, err)
+		}
+		if exists {
+			return common.AlreadyExistsError{
+				Message: fmt.Sprintf("employee with name %s already exists", restored.Name)}
+		}
+		if err = svc.checkLogin(tx, restored.Login, restored.Id); err != nil {
+			return err
+		}
+		if err = svc.checkEmployeeNumber(tx, restored.EmployeeNumber, restored.Id); err != nil {
+			return err
+		}
+		err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionRestore,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      restored.auditSnapshot(),
+		})
+		if err != nil {
+			return err
+		}
+		return svc.applyRulesTx(ctx, tx, restored)
+	})
+}
+
+// RemoveRole снять с сотрудника роль
+func (svc *Service) RemoveRole(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.updateRole(ctx, request.Id, nil, "removing role")
+}
+
+// updateRole сменить основную роль сотрудника и записать изменение в журнал
+func (svc *Service) updateRole(ctx context.Context, id int64, roleId *int64, operation string) error {
+	return database.InTransaction(svc.repo.BeginTransaction, operation, func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if err = svc.checkNewRole(before, roleId); err != nil {
+			return err
+		}
+		if err = svc.checkAccess(ctx, before, roleId, before.Login); err != nil {
+			return err
+		}
+		if err = svc.repo.UpdateRoleTx(tx, id, roleId); err != nil {
+			return fmt.Errorf("error %s of employee with id %d: %w", operation, id, err)
+		}
+		if err = svc.checkSodTx(tx, id, before.RoleId, roleId); err != nil {
+			return err
+		}
+		after := before
+		after.RoleId = roleId
+		return svc.recordUpdateTx(ctx, tx, before, after)
+	})
+}
+
+// SetOrgUnit перевести сотрудника в подразделение
+func (svc *Service) SetOrgUnit(ctx context.Context, request SetOrgUnitRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.updateOrgUnit(ctx, request.Id, &request.OrgUnitId, "setting org unit")
+}
+
+// RemoveOrgUnit вывести сотрудника из подразделения
+func (svc *Service) RemoveOrgUnit(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return svc.updateOrgUnit(ctx, request.Id, nil, "removing org unit")
+}
+
+func (svc *Service) updateOrgUnit(ctx context.Context, id int64, orgUnitId *int64, operation string) error {
+	return database.InTransaction(svc.repo.BeginTransaction, operation, func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, id)
+		if err != nil {
+			return err
+		}
+		if orgUnitId != nil {
+			if _, err = svc.orgUnitRepo.FindById(*orgUnitId); err != nil {
+				return common.NotFoundError{
+					Message: fmt.Sprintf("error finding org unit with id %d: %v", *orgUnitId, err),
+				}
+			}
+		}
+		if err = svc.repo.UpdateOrgUnitTx(tx, id, orgUnitId); err != nil {
+			return fmt.Errorf("error %s of employee with id %d: %w", operation, id, err)
+		}
+		after := before
+		after.OrgUnitId = orgUnitId
+		return svc.recordUpdateTx(ctx, tx, before, after)
+	})
+}
+
+// SetManager назначить сотруднику непосредственного руководителя. Руководителем не может стать
+// подчинённый сотрудника, прямой или через других: такая связь замкнула бы цикл
+func (svc *Service) SetManager(ctx context.Context, request SetManagerRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "setting manager", func(tx *sqlx.Tx) error {
+		if err := svc.repo.LockReportingLinesTx(tx); err != nil {
+			return fmt.Errorf("error locking reporting lines: %w", err)
+		}
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		manager, err := svc.repo.FindById(request.ManagerId)
+		if err != nil {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("error finding manager with id %d: %v", request.ManagerId, err),
+			}
+		}
+		if manager.Status == StatusTerminated {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("manager with id %d is terminated", request.ManagerId),
+			}
+		}
+		cycle, err := svc.repo.IsReportTx(tx, request.Id, request.ManagerId)
+		if err != nil {
+			return fmt.Errorf("error checking reporting lines: %w", err)
+		}
+		if cycle {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("employee %d reports to employee %d, making them a manager would create a cycle",
+					request.ManagerId, request.Id),
+			}
+		}
+		return svc.updateManagerTx(ctx, tx, before, &request.ManagerId)
+	})
+}
+
+// RemoveManager снять с сотрудника руководителя
+func (svc *Service) RemoveManager(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "removing manager", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		return svc.updateManagerTx(ctx, tx, before, nil)
+	})
+}
+
+func (svc *Service) updateManagerTx(ctx context.Context, tx *sqlx.Tx, before Entity, managerId *int64) error {
+	if err := svc.repo.UpdateManagerTx(tx, before.Id, managerId); err != nil {
+		return fmt.Errorf("error updating manager of employee with id %d: %w", before.Id, err)
+	}
+	after := before
+	after.ManagerId = managerId
+	return svc.recordUpdateTx(ctx, tx, before, after)
+}
+
+// recordUpdateTx записать изменение сотрудника в журнал и пересчитать его назначения по правилам
+func (svc *Service) recordUpdateTx(ctx context.Context, tx *sqlx.Tx, before, after Entity) error {
+	err := svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionUpdate,
+		EntityType: auditEntityType,
+		EntityId:   before.Id,
+		Before:     before.auditSnapshot(),
+		After:      after.auditSnapshot(),
+	})
+	if err != nil {
+		return err
+	}
+	return svc.applyRulesTx(ctx, tx, after)
+}
+
+// applyRulesTx сотрудник, подошедший под правило назначения, получает его роль, переставший подходить - теряет
+func (svc *Service) applyRulesTx(ctx context.Context, tx *sqlx.Tx, e Entity) error {
+	if err := svc.rules.ApplyTx(ctx, tx, e); err != nil {
+		return fmt.Errorf("error applying assignment rules to employee with id %d: %w", e.Id, err)
+	}
+	return nil
+}
+
+// Transition перевести сотрудника по жизненному циклу. Переход без даты или с наступившей датой применяется сразу,
+// с датой в будущем - ждёт её; у сотрудника может быть только один ожидающий переход.
+// При увольнении у сотрудника снимается основная роль и отзываются все назначения ролей.
+func (svc *Service) Transition(ctx context.Context, request TransitionRequest) (TransitionResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return TransitionResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	now := time.Now()
+	effectiveAt := now
+	if request.EffectiveAt != nil {
+		effectiveAt = *request.EffectiveAt
+	}
+	var saved TransitionEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "transitioning employee", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		to, err := checkTransition(before, request.Transition)
+		if err != nil {
+			return err
+		}
+		entity := TransitionEntity{
+			EmployeeId:  request.Id,
+			Transition:  request.Transition,
+			FromStatus:  before.Status,
+			ToStatus:    to,
+			EffectiveAt: effectiveAt,
+			Reason:      request.Reason,
+			Status:      TransitionPending,
+			CreatedBy:   common.ActorFrom(ctx),
+		}
+		if effectiveAt.After(now) {
+			saved, err = svc.scheduleTx(ctx, tx, entity)
+			return err
+		}
+		entity.Status = TransitionApplied
+		entity.AppliedAt = &now
+		saved, err = svc.repo.SaveTransitionTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving transition of employee with id %d: %w", request.Id, err)
+		}
+		return svc.applyTx(ctx, tx, before, saved)
+	})
+	if err != nil {
+		return TransitionResponse{}, err
+	}
+	return saved.toResponse(), nil
+}
+
+// scheduleTx сохранить переход, который применится в дату вступления в силу
+func (svc *Service) scheduleTx(ctx context.Context, tx *sqlx.Tx, entity TransitionEntity) (TransitionEntity, error) {
+	_, err := svc.repo.FindPendingTransitionTx(tx, entity.EmployeeId)
+	if err == nil {
+		return TransitionEntity{}, common.AlreadyExistsError{
+			Message: fmt.Sprintf("employee with id %d already has a pending transition", entity.EmployeeId),
+		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return TransitionEntity{}, fmt.Errorf("error finding pending transition of employee with id %d: %w", entity.EmployeeId, err)
+	}
+	saved, err := svc.repo.SaveTransitionTx(tx, entity)
+	if err != nil {
+		return TransitionEntity{}, fmt.Errorf("error saving transition of employee with id %d: %w", entity.EmployeeId, err)
+	}
+	err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     audit.ActionCreate,
+		EntityType: auditEntityTypeTransition,
+		EntityId:   saved.Id,
+		After:      saved.toResponse(),
+	})
+	if err != nil {
+		return TransitionEntity{}, err
+	}
+	return saved, nil
+}
+
+// applyTx перевести сотрудника в состояние перехода; событие журнала называется по переходу, например terminate
+func (svc *Service) applyTx(ctx context.Context, tx *sqlx.Tx, before Entity, transition TransitionEntity) error {
+	after := before
+	after.Status = transition.ToStatus
+	if after.Status == StatusTerminated {
+		after.RoleId = nil
+		if err := svc.revoker.RevokeAllTx(ctx, tx, before.Id); err != nil {
+			return fmt.Errorf("error revoking roles of employee with id %d: %w", before.Id, err)
+		}
+	}
+	if err := svc.repo.UpdateStatusTx(tx, before.Id, after.Status, after.RoleId); err != nil {
+		return fmt.Errorf("error updating status of employee with id %d: %w", before.Id, err)
+	}
+	err := svc.auditor.RecordTx(ctx, tx, audit.Event{
+		Action:     transition.Transition,
+		EntityType: auditEntityType,
+		EntityId:   before.Id,
+		Before:     before.auditSnapshot(),
+		After:      after.auditSnapshot(),
+	})
+	if err != nil {
+		return err
+	}
+	return svc.applyRulesTx(ctx, tx, after)
+}
+
+// FindTransitions переходы сотрудника, от новых к старым, включая ожидающий
+func (svc *Service) FindTransitions(request IdRequest) ([]TransitionResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.repo.FindById(request.Id); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee with id %d: %v", request.Id, err),
+		}
+	}
+	entities, err := svc.repo.FindTransitions(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving transitions of employee with id %d: %w", request.Id, err)
+	}
+	responses := make([]TransitionResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// CancelTransition отменить ожидающий переход сотрудника
+func (svc *Service) CancelTransition(ctx context.Context, request IdRequest) (TransitionResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return TransitionResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	var cancelled TransitionEntity
+	err = database.InTransaction(svc.repo.BeginTransaction, "cancelling employee transition", func(tx *sqlx.Tx) error {
+		pending, err := svc.repo.FindPendingTransitionTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("employee with id %d has no pending transition", request.Id),
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding pending transition of employee with id %d: %w", request.Id, err)
+		}
+		cancelled, err = svc.repo.CompleteTransitionTx(tx, pending.Id, TransitionCancelled, nil)
+		if err != nil {
+			return fmt.Errorf("error cancelling transition with id %d: %w", pending.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionCancel,
+			EntityType: auditEntityTypeTransition,
+			EntityId:   pending.Id,
+			Before:     pending.toResponse(),
+			After:      cancelled.toResponse(),
+		})
+	})
+	if err != nil {
+		return TransitionResponse{}, err
+	}
+	return cancelled.toResponse(), nil
+}
+
+// ApplyDue применить ожидающие переходы, дата которых наступила к моменту now, каждый в своей транзакции.
+// Переход, который из текущего состояния сотрудника уже невозможен, отменяется. Возвращает число применённых.
+func (svc *Service) ApplyDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := svc.repo.FindDueTransitions(now)
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving due employee transitions: %w", err)
+	}
+	applied := 0
+	var errs []error
+	for _, transition := range due {
+		var ok bool
+		err = database.InTransaction(svc.repo.BeginTransaction, "applying employee transition", func(tx *sqlx.Tx) (err error) {
+			ok, err = svc.applyDueTx(ctx, tx, transition, now)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("employee transition %d: %w", transition.Id, err))
+			continue
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, errors.Join(errs...)
+}
+
+func (svc *Service) applyDueTx(ctx context.Context, tx *sqlx.Tx, transition TransitionEntity, now time.Time) (bool, error) {
+	before, err := svc.repo.LockByIdTx(tx, transition.EmployeeId)
+	if err != nil {
+		return false, fmt.Errorf("error finding employee with id %d: %w", transition.EmployeeId, err)
+	}
+	status, appliedAt := TransitionApplied, &now
+	if _, err = checkTransition(before, transition.Transition); err != nil || before.DeletedAt != nil {
+		status, appliedAt = TransitionCancelled, nil
+	}
+	completed, err := svc.repo.CompleteTransitionTx(tx, transition.Id, status, appliedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// переход отменили, пока он ждал в очереди
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error completing transition with id %d: %w", transition.Id, err)
+	}
+	if status == TransitionCancelled {
+		return false, svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionCancel,
+			EntityType: auditEntityTypeTransition,
+			EntityId:   transition.Id,
+			Before:     transition.toResponse(),
+			After:      completed.toResponse(),
+		})
+	}
+	return true, svc.applyTx(ctx, tx, before, completed)
+}
+
+// checkTransition допустим ли переход из текущего состояния сотрудника; возвращает новое состояние
+func checkTransition(before Entity, transition string) (string, error) {
+	rule, ok := lifecycle[transition]
+	if !ok || !slices.Contains(rule.from, before.Status) {
+		return "", common.RequestValidationError{
+			Message: fmt.Sprintf("cannot %s employee with id %d in status %s", transition, before.Id, before.Status),
+		}
+	}
+	return rule.to, nil
+}
+
+// checkAccess смена основной роли равносильна назначению и отзыву роли, а по логину субъект запроса
+// получает права сотрудника, поэтому оба изменения требуют отдельных разрешений
+func (svc *Service) checkAccess(ctx context.Context, before Entity, roleId *int64, login *string) error {
+	if !equal(before.RoleId, roleId) {
+		if before.RoleId != nil {
+			if err := svc.require(ctx, permissionUnassign, fmt.Sprintf("remove role of employee %d", before.Id)); err != nil {
+				return err
+			}
+		}
+		if roleId != nil {
+			if err := svc.require(ctx, permissionAssign, fmt.Sprintf("set role of employee %d", before.Id)); err != nil {
+				return err
+			}
+		}
+	}
+	if !equal(before.Login, login) {
+		return svc.require(ctx, permissionLogin, fmt.Sprintf("change login of employee %d", before.Id))
+	}
+	return nil
+}
+
+// require у автора запроса должно быть разрешение permission на действие action
+func (svc *Service) require(ctx context.Context, permission, action string) error {
+	held, err := svc.access.Holds(ctx, permission)
+	if err != nil {
+		return fmt.Errorf("error checking permission %s: %w", permission, err)
+	}
+	if !held {
+		return common.ForbiddenError{Message: fmt.Sprintf("permission %s is required to %s", permission, action)}
+	}
+	return nil
+}
+
+// equal оба указателя nil или указывают на равные значения
+func equal[T comparable](a, b *T) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// checkSodTx новая основная роль не должна нарушать правила разделения обязанностей. Проверяется после
+// записи роли, чтобы заменяемая основная роль уже не считалась ролью сотрудника
+func (svc *Service) checkSodTx(tx *sqlx.Tx, id int64, before, after *int64) error {
+	if after == nil || before != nil && *before == *after {
+		return nil
+	}
+	conflicts, err := svc.sod.FindConflictsTx(tx, id, *after, time.Now(), nil)
+	if err != nil {
+		return fmt.Errorf("error checking sod rules for employee %d: %w", id, err)
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	descriptions := make([]string, 0, len(conflicts))
+	for _, rule := range conflicts {
+		descriptions = append(descriptions, fmt.Sprintf("%s (roles %d and %d)", rule.Name, rule.RoleAId, rule.RoleBId))
+	}
+	return common.SodConflictError{
+		Message: fmt.Sprintf("setting role %d of employee %d violates sod rules: %s",
+			*after, id, strings.Join(descriptions, ", ")),
+	}
+}
+
+// checkNewRole новая основная роль должна существовать, а уволенному сотруднику роли не назначаются,
+// как и через назначения. Прежняя роль не проверяется: она могла быть удалена, и это не мешает
+// менять другие поля
+func (svc *Service) checkNewRole(before Entity, roleId *int64) error {
+	if roleId == nil || equal(before.RoleId, roleId) {
+		return nil
+	}
+	if before.Status == StatusTerminated {
+		return common.RequestValidationError{
+			Message: fmt.Sprintf("employee with id %d is terminated", before.Id),
+		}
+	}
+	_, err := svc.findRole(*roleId)
+	return err
+}
+
+func (svc *Service) findRole(id int64) (role.Entity, error) {
+	found, err := svc.roleRepo.FindById(id)
+	if err != nil {
+		return role.Entity{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", id, err),
+		}
+	}
+	return found, nil
+}
+
+// toResponses преобразовать сущности в ответы, подгрузив роли одним запросом;
+// если задан asOf, роли берутся в том состоянии, в котором были в этот момент
+func (svc *Service) toResponses(entities []Entity, asOf *time.Time) ([]Response, error) {
+	roleIds := make([]int64, 0, len(entities))
+	for _, entity := range entities {
+		if entity.RoleId != nil {
+			roleIds = append(roleIds, *entity.RoleId)
+		}
+	}
+	roles := make(map[int64]RoleResponse, len(roleIds))
+	if len(roleIds) > 0 {
+		var found []role.Entity
+		var err error
+		if asOf != nil {
+			found, err = svc.roleRepo.FindAllByIdsAsOf(roleIds, *asOf)
+		} else {
+			found, err = svc.roleRepo.FindAllByIds(roleIds)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving roles of employees: %w", err)
+		}
+		for _, r := range found {
+			roles[r.Id] = RoleResponse{Id: r.Id, Name: r.Name}
+		}
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		response := entity.toResponse()
+		if entity.RoleId != nil {
+			if r, ok := roles[*entity.RoleId]; ok {
+				response.Role = &r
+			}
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
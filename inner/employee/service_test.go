@@ -1,14 +1,22 @@
 package employee
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/78bits/go-sqlmock-sqlx"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert" // импортируем библиотеку с ассерт-функциями
 	"github.com/stretchr/testify/mock"   // импортируем пакет для создания моков
+	"idm/inner/attribute"
+	"idm/inner/audit"
 	"idm/inner/common"
+	"idm/inner/orgunit"
+	"idm/inner/role"
+	"idm/inner/sod"
 	"idm/inner/validator"
+	"slices"
 	"testing"
 	"time"
 )
@@ -38,24 +46,39 @@ func (m *MockRepo) Save(e *Entity) (int64, error) {
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepo) FindAll() ([]Entity, error) {
-	args := m.Called()
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
+func (m *MockRepo) Search(query string, limit int) ([]SearchEntity, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]SearchEntity), args.Error(1)
+}
+
+func (m *MockRepo) RestoreTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepo) FindAllByIds(ids []int64) ([]Entity, error) {
 	args := m.Called(ids)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteById(id int64) error {
-	args := m.Called(id)
-	return args.Error(0)
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error) {
+	args := m.Called(tx, ids)
+	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteAllByIds(ids []int64) error {
-	args := m.Called(ids)
-	return args.Error(0)
+func (m *MockRepo) LockByIdTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
 }
 
 // реализуем интерфейс репозитория у мока
@@ -66,6 +89,199 @@ func (m *MockRepo) FindById(id int64) (employee Entity, err error) {
 	return args.Get(0).(Entity), args.Error(1)
 }
 
+func (m *MockRepo) FindByIdAsOf(id int64, at time.Time) (Entity, error) {
+	args := m.Called(id, at)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindHistory(id int64) ([]HistoryEntity, error) {
+	args := m.Called(id)
+	return args.Get(0).([]HistoryEntity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateRoleTx(tx *sqlx.Tx, id int64, roleId *int64) error {
+	args := m.Called(tx, id, roleId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) FindByNameExceptTx(tx *sqlx.Tx, name string, id int64) (bool, error) {
+	args := m.Called(tx, name, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindByLoginExceptTx(tx *sqlx.Tx, login string, id int64) (bool, error) {
+	args := m.Called(tx, login, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindByEmployeeNumberExceptTx(tx *sqlx.Tx, number string, id int64) (bool, error) {
+	args := m.Called(tx, number, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) UpdateStatusTx(tx *sqlx.Tx, id int64, status string, roleId *int64) error {
+	args := m.Called(tx, id, status, roleId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) SaveTransitionTx(tx *sqlx.Tx, e TransitionEntity) (TransitionEntity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(TransitionEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindTransitions(employeeId int64) ([]TransitionEntity, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).([]TransitionEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindPendingTransitionTx(tx *sqlx.Tx, employeeId int64) (TransitionEntity, error) {
+	args := m.Called(tx, employeeId)
+	return args.Get(0).(TransitionEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindDueTransitions(at time.Time) ([]TransitionEntity, error) {
+	args := m.Called(at)
+	return args.Get(0).([]TransitionEntity), args.Error(1)
+}
+
+func (m *MockRepo) CompleteTransitionTx(tx *sqlx.Tx, id int64, status string, appliedAt *time.Time) (TransitionEntity, error) {
+	args := m.Called(tx, id, status, appliedAt)
+	return args.Get(0).(TransitionEntity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (bool, error) {
+	args := m.Called(tx, e, version)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) UpdateOrgUnitTx(tx *sqlx.Tx, id int64, orgUnitId *int64) error {
+	args := m.Called(tx, id, orgUnitId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) UpdateManagerTx(tx *sqlx.Tx, id int64, managerId *int64) error {
+	args := m.Called(tx, id, managerId)
+	return args.Error(0)
+}
+
+func (m *MockRepo) LockReportingLinesTx(tx *sqlx.Tx) error {
+	args := m.Called(tx)
+	return args.Error(0)
+}
+
+func (m *MockRepo) IsReportTx(tx *sqlx.Tx, managerId, candidateId int64) (bool, error) {
+	args := m.Called(tx, managerId, candidateId)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+func (m *MockRoleRepo) FindAllByIds(ids []int64) ([]role.Entity, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+func (m *MockRoleRepo) FindByIdAsOf(id int64, at time.Time) (role.Entity, error) {
+	args := m.Called(id, at)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+func (m *MockRoleRepo) FindAllByIdsAsOf(ids []int64, at time.Time) ([]role.Entity, error) {
+	args := m.Called(ids, at)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+type MockOrgUnitRepo struct {
+	mock.Mock
+}
+
+func (m *MockOrgUnitRepo) FindById(id int64) (orgunit.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(orgunit.Entity), args.Error(1)
+}
+
+// StubAttributeRepo схема пользовательских атрибутов; по умолчанию пустая
+type StubAttributeRepo struct {
+	definitions []attribute.Entity
+}
+
+func (r *StubAttributeRepo) FindAllTx(tx *sqlx.Tx) ([]attribute.Entity, error) {
+	return r.definitions, nil
+}
+
+type MockAssignmentRepo struct {
+	mock.Mock
+}
+
+func (m *MockAssignmentRepo) FindEffectiveRoles(employeeId int64, at time.Time) ([]role.Entity, error) {
+	args := m.Called(employeeId, at)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+func (m *MockAssignmentRepo) FindEffectiveRolesAsOf(employeeId int64, at time.Time) ([]role.Entity, error) {
+	args := m.Called(employeeId, at)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+// StubRevoker запоминает сотрудников, у которых отозваны все роли
+type StubRevoker struct {
+	employees []int64
+}
+
+func (r *StubRevoker) RevokeAllTx(ctx context.Context, tx *sqlx.Tx, employeeId int64) error {
+	r.employees = append(r.employees, employeeId)
+	return nil
+}
+
+// StubRules запоминает сотрудников, для которых пересчитывались назначения по правилам
+type StubRules struct {
+	employees []Entity
+	err       error
+}
+
+func (r *StubRules) ApplyTx(ctx context.Context, tx *sqlx.Tx, e Entity) error {
+	r.employees = append(r.employees, e)
+	return r.err
+}
+
+// StubSod запоминает проверенные основные роли и возвращает для них заданные конфликты
+type StubSod struct {
+	roleIds   []int64
+	conflicts []sod.Entity
+}
+
+func (s *StubSod) FindConflictsTx(tx *sqlx.Tx, employeeId, roleId int64, from time.Time, to *time.Time) ([]sod.Entity, error) {
+	s.roleIds = append(s.roleIds, roleId)
+	return s.conflicts, nil
+}
+
+// StubAccess разрешает автору запроса всё, кроме denied
+type StubAccess struct {
+	denied []string
+}
+
+func (s *StubAccess) Holds(ctx context.Context, permission string) (bool, error) {
+	return !slices.Contains(s.denied, permission), nil
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
 func TestServiceCreate(t *testing.T) {
 	a := assert.New(t)
 
@@ -75,7 +291,7 @@ func TestServiceCreate(t *testing.T) {
 		sqlxDB := sqlx.NewDb(db, "sqlmock")
 
 		repo := &Repository{db: sqlxDB}
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		// создаём ошибку, которую должен вернуть Begin
 		dbErr := errors.New("transaction begin error")
@@ -84,7 +300,7 @@ func TestServiceCreate(t *testing.T) {
 		// sqlmock должен сымитировать ошибку начала транзакции
 		sqlMock.ExpectBegin().WillReturnError(dbErr)
 
-		id, err := svc.Create(CreateRequest{Name: "test"})
+		id, err := svc.Create(context.Background(), CreateRequest{Name: "test"})
 		a.Equal(int64(0), id)
 		a.NotNil(err)
 		a.EqualError(err, want.Error())
@@ -101,9 +317,9 @@ func TestServiceCreate(t *testing.T) {
 		a.NoError(err)
 
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
-		entity := Entity{Name: "Alice"}
+		entity := Entity{Name: "Alice", Status: StatusActive}
 		want := common.AlreadyExistsError{
 			Message: fmt.Sprintf("employee with name %s already exists", entity.Name),
 		}
@@ -111,7 +327,7 @@ func TestServiceCreate(t *testing.T) {
 		repo.On("BeginTransaction").Return(tx, nil)
 		repo.On("FindByNameTx", tx, entity.Name).Return(true, nil)
 
-		id, err := svc.Create(CreateRequest{Name: entity.Name})
+		id, err := svc.Create(context.Background(), CreateRequest{Name: entity.Name})
 
 		a.Equal(int64(0), id)
 		a.NotNil(err)
@@ -127,9 +343,9 @@ func TestServiceCreate(t *testing.T) {
 		defer db.Close()
 
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
-		entity := Entity{Name: "Alice"}
+		entity := Entity{Name: "Alice", Status: StatusActive}
 		tx, _ := db.Beginx()
 		dbErr := errors.New("save error")
 		want := fmt.Errorf("error saving employee with name: %s %w", entity.Name, dbErr)
@@ -138,7 +354,7 @@ func TestServiceCreate(t *testing.T) {
 		repo.On("FindByNameTx", tx, entity.Name).Return(false, nil)
 		repo.On("SaveTx", tx, entity).Return(int64(0), dbErr)
 
-		_, err = svc.Create(CreateRequest{Name: entity.Name})
+		_, err = svc.Create(context.Background(), CreateRequest{Name: entity.Name})
 		a.NotNil(err)
 		a.EqualError(err, want.Error())
 		a.True(repo.AssertNumberOfCalls(t, "BeginTransaction", 1))
@@ -152,21 +368,28 @@ func TestServiceCreate(t *testing.T) {
 		defer db.Close()
 
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
 
-		entity := Entity{Name: "Alice"}
+		entity := Entity{Name: "Alice", Status: StatusActive}
 		tx, _ := db.Beginx()
 
 		repo.On("BeginTransaction").Return(tx, nil)
 		repo.On("FindByNameTx", tx, entity.Name).Return(false, nil)
 		repo.On("SaveTx", tx, entity).Return(int64(1), nil)
 
-		id, err := svc.Create(CreateRequest{Name: entity.Name})
+		id, err := svc.Create(context.Background(), CreateRequest{Name: entity.Name})
 		a.NoError(err)
 		a.Equal(int64(1), id)
 		a.True(repo.AssertNumberOfCalls(t, "BeginTransaction", 1))
 		a.True(repo.AssertNumberOfCalls(t, "FindByNameTx", 1))
 		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "employee",
+			EntityId:   1,
+			After:      auditSnapshot{Id: 1, Name: "Alice", Status: StatusActive},
+		}}, auditor.events)
 	})
 }
 
@@ -175,12 +398,15 @@ func TestServiceFindById(t *testing.T) {
 
 	t.Run("should return found employee", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		entity := Entity{Id: 1, Name: "John Doe", CreatedAt: time.Now(), UpdatedAt: time.Now()}
 		want := entity.toResponse()
+		want.Roles = []RoleResponse{}
 
 		repo.On("FindById", int64(1)).Return(entity, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
 
 		got, err := svc.FindById(IdRequest{Id: 1})
 		a.Nil(err)
@@ -190,7 +416,7 @@ func TestServiceFindById(t *testing.T) {
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		// создаём пустую структуру employee.Entity, которую сервис вернёт вместе с ошибкой
 		entity := Entity{}
@@ -218,7 +444,7 @@ func TestServiceFindAll(t *testing.T) {
 
 	t.Run("should return all employees", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		entities := []Entity{
 			{Id: 1, Name: "First", CreatedAt: time.Now(), UpdatedAt: time.Now()},
@@ -228,30 +454,33 @@ func TestServiceFindAll(t *testing.T) {
 			entities[0].toResponse(),
 			entities[1].toResponse(),
 		}
-		repo.On("FindAll").Return(entities, nil)
+		repo.On("FindPage", mock.AnythingOfType("ListRequest"), (*common.Cursor)(nil)).Return(entities, nil)
+		repo.On("Count", mock.AnythingOfType("ListRequest")).Return(int64(len(entities)), nil)
 
-		got, err := svc.FindAll()
+		got, err := svc.FindAll(ListRequest{})
 		a.Nil(err)
-		a.Equal(want, got)
-		a.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
+		a.Equal(want, got.Items)
+		a.Equal(int64(2), got.Total)
+		a.Empty(got.NextCursor)
+		a.True(repo.AssertNumberOfCalls(t, "FindPage", 1))
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		dbErr := errors.New("database error")
 		want := common.NotFoundError{
 			Message: fmt.Sprintf("error retrieving all employees: %v", dbErr),
 		}
 
-		repo.On("FindAll").Return([]Entity{}, dbErr)
+		repo.On("FindPage", mock.AnythingOfType("ListRequest"), (*common.Cursor)(nil)).Return([]Entity{}, dbErr)
 
-		got, err := svc.FindAll()
-		a.Nil(got)
+		got, err := svc.FindAll(ListRequest{})
+		a.Nil(got.Items)
 		a.NotNil(err)
 		a.Equal(err, want)
-		a.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
+		a.True(repo.AssertNumberOfCalls(t, "FindPage", 1))
 	})
 }
 
@@ -260,7 +489,7 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 	t.Run("should return employees by ids", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		entities := []Entity{
@@ -282,7 +511,7 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		dbErr := errors.New("database error")
@@ -302,54 +531,72 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 func TestServiceDeleteById(t *testing.T) {
 	a := assert.New(t)
+	var noTx *sqlx.Tx
 
-	t.Run("should delete employee by id", func(t *testing.T) {
+	t.Run("should delete employee by id and audit it", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
 
-		repo.On("DeleteById", int64(1)).Return(nil)
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{1}).Return([]Entity{{Id: 1, Name: "Alice", DeletedAt: &deletedAt}}, nil)
 
-		err := svc.DeleteById(IdRequest{Id: 1})
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 1})
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionDelete,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice"},
+			After:      auditSnapshot{Id: 1, Name: "Alice", DeletedAt: &deletedAt},
+		}}, auditor.events)
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		dbErr := errors.New("database error")
 		want := common.NotFoundError{
 			Message: fmt.Sprintf("error deleting employee with id %d: %v", 1, dbErr),
 		}
 
-		repo.On("DeleteById", int64(1)).Return(dbErr)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{1}).Return([]Entity(nil), dbErr)
 
-		err := svc.DeleteById(IdRequest{Id: 1})
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 1})
 		a.NotNil(err)
 		a.Equal(err, want)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
 	})
 }
 
 func TestServiceDeleteAllByIds(t *testing.T) {
 	a := assert.New(t)
+	var noTx *sqlx.Tx
 
-	t.Run("should delete all employees by ids", func(t *testing.T) {
+	t.Run("should delete all employees by ids and audit each of them", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
 
 		ids := []int64{1, 2}
-		repo.On("DeleteAllByIds", ids).Return(nil)
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, ids).Return([]Entity{{Id: 1, DeletedAt: &deletedAt}, {Id: 2, DeletedAt: &deletedAt}}, nil)
 
-		err := svc.DeleteAllByIds(IdsRequest{Ids: ids})
+		err := svc.DeleteAllByIds(context.Background(), IdsRequest{Ids: ids})
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteAllByIds", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+		a.Len(auditor.events, 2)
+		a.Equal(int64(2), auditor.events[1].EntityId)
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		dbErr := errors.New("database error")
@@ -357,11 +604,1464 @@ func TestServiceDeleteAllByIds(t *testing.T) {
 			Message: fmt.Sprintf("error deleting employees by ids %v: %v", ids, dbErr),
 		}
 
-		repo.On("DeleteAllByIds", ids).Return(dbErr)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, ids).Return([]Entity(nil), dbErr)
 
-		err := svc.DeleteAllByIds(IdsRequest{Ids: ids})
+		err := svc.DeleteAllByIds(context.Background(), IdsRequest{Ids: ids})
 		a.NotNil(err)
 		a.Equal(err, want)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteAllByIds", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+	})
+}
+
+func TestServiceCreateWithRole(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return not found error when role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		dbErr := errors.New("no rows")
+		want := common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", roleId, dbErr),
+		}
+		roleRepo.On("FindById", roleId).Return(role.Entity{}, dbErr)
+
+		id, err := svc.Create(context.Background(), CreateRequest{Name: "Alice", RoleId: &roleId})
+		a.Equal(int64(0), id)
+		a.Equal(want, err)
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should save employee with role", func(t *testing.T) {
+		db, _, err := sqlmock.Newx()
+		a.NoError(err)
+		defer db.Close()
+
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		entity := Entity{Name: "Alice", RoleId: &roleId, Status: StatusActive}
+		tx, _ := db.Beginx()
+
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Admin"}, nil)
+		repo.On("BeginTransaction").Return(tx, nil)
+		repo.On("FindByNameTx", tx, entity.Name).Return(false, nil)
+		repo.On("SaveTx", tx, entity).Return(int64(1), nil)
+
+		id, err := svc.Create(context.Background(), CreateRequest{Name: entity.Name, RoleId: &roleId})
+		a.NoError(err)
+		a.Equal(int64(1), id)
+		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+	})
+}
+
+func TestServiceFindByIdWithRole(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return employee with role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		entity := Entity{Id: 1, Name: "John Doe", RoleId: &roleId}
+		repo.On("FindById", int64(1)).Return(entity, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Admin"}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.FindById(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal(&RoleResponse{Id: roleId, Name: "Admin"}, got.Role)
+	})
+
+	t.Run("should return employee without role when primary role is deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "John Doe", RoleId: &roleId}, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{}, sql.ErrNoRows)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.FindById(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Nil(got.Role)
+	})
+
+	t.Run("should return currently effective roles", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "John Doe"}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{
+			{Id: 3, Name: "Accountant"},
+			{Id: 4, Name: "Auditor"},
+		}, nil)
+
+		got, err := svc.FindById(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal([]RoleResponse{{Id: 3, Name: "Accountant"}, {Id: 4, Name: "Auditor"}}, got.Roles)
+	})
+
+	t.Run("should return error when effective roles lookup fails", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		dbErr := errors.New("database error")
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "John Doe"}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, dbErr)
+
+		_, err := svc.FindById(IdRequest{Id: 1})
+		a.ErrorIs(err, dbErr)
+	})
+
+	t.Run("should load roles of all employees with one query", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		adminId, userId := int64(7), int64(8)
+		entities := []Entity{
+			{Id: 1, Name: "First", RoleId: &adminId},
+			{Id: 2, Name: "Second"},
+			{Id: 3, Name: "Third", RoleId: &userId},
+		}
+		repo.On("FindPage", mock.AnythingOfType("ListRequest"), (*common.Cursor)(nil)).Return(entities, nil)
+		repo.On("Count", mock.AnythingOfType("ListRequest")).Return(int64(len(entities)), nil)
+		roleRepo.On("FindAllByIds", []int64{adminId, userId}).Return([]role.Entity{
+			{Id: adminId, Name: "Admin"},
+			{Id: userId, Name: "User"},
+		}, nil)
+
+		got, err := svc.FindAll(ListRequest{})
+		a.NoError(err)
+		a.Len(got.Items, 3)
+		a.Equal(&RoleResponse{Id: adminId, Name: "Admin"}, got.Items[0].Role)
+		a.Nil(got.Items[1].Role)
+		a.Equal(&RoleResponse{Id: userId, Name: "User"}, got.Items[2].Role)
+		a.True(roleRepo.AssertNumberOfCalls(t, "FindAllByIds", 1))
+	})
+}
+
+func TestServiceSetRole(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should set role and audit previous and new role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Admin"}, nil)
+		repo.On("UpdateRoleTx", noTx, int64(1), &roleId).Return(nil)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: roleId})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateRoleTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice"},
+			After:      auditSnapshot{Id: 1, Name: "Alice", RoleId: &roleId},
+		}}, auditor.events)
+	})
+
+	t.Run("should return not found error when employee does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: 7})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(roleRepo.AssertNotCalled(t, "FindById", int64(7)))
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should return not found error when role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		dbErr := errors.New("no rows")
+		want := common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", 7, dbErr),
+		}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		roleRepo.On("FindById", int64(7)).Return(role.Entity{}, dbErr)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: 7})
+		a.Equal(want, err)
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should reject role conflicting with sod rules", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		sodRules := &StubSod{conflicts: []sod.Entity{{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7}}}
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), sodRules, new(StubAccess), auditor, validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Approver"}, nil)
+		repo.On("UpdateRoleTx", noTx, int64(1), &roleId).Return(nil)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: roleId})
+		a.Equal(common.SodConflictError{
+			Message: "setting role 7 of employee 1 violates sod rules: payments (roles 3 and 7)",
+		}, err)
+		a.Equal([]int64{7}, sodRules.roleIds)
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should reject role for terminated employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", Status: StatusTerminated}, nil)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: 7})
+		a.Equal(common.RequestValidationError{Message: "employee with id 1 is terminated"}, err)
+		a.True(roleRepo.AssertNotCalled(t, "FindById", mock.Anything))
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should forbid setting role without permission to assign roles", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		access := &StubAccess{denied: []string{"assignments:create"}}
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), access, new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Approver"}, nil)
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1, RoleId: roleId})
+		a.Equal(common.ForbiddenError{Message: "permission assignments:create is required to set role of employee 1"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		err := svc.SetRole(context.Background(), SetRoleRequest{Id: 1})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceRemoveRole(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should remove role", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", RoleId: &roleId}, nil)
+		repo.On("UpdateRoleTx", noTx, int64(1), (*int64)(nil)).Return(nil)
+
+		err := svc.RemoveRole(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateRoleTx", 1))
+	})
+
+	t.Run("should forbid removing role without permission to revoke roles", func(t *testing.T) {
+		repo := new(MockRepo)
+		access := &StubAccess{denied: []string{"assignments:delete"}}
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), access, new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", RoleId: &roleId}, nil)
+
+		err := svc.RemoveRole(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.ForbiddenError{})
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should return not found error when employee does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.RemoveRole(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+
+	t.Run("should return not found error when employee is deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, DeletedAt: &deletedAt}, nil)
+
+		err := svc.RemoveRole(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateRoleTx"))
+	})
+}
+
+func TestServiceAssignmentRules(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should apply rules to created employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		rules := new(StubRules)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), rules, new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		entity := Entity{Name: "Alice", Status: StatusActive}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, "Alice").Return(false, nil)
+		repo.On("SaveTx", noTx, entity).Return(int64(7), nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Alice"})
+		a.NoError(err)
+		a.Equal([]Entity{{Id: 7, Name: "Alice", Status: StatusActive}}, rules.employees)
+	})
+
+	t.Run("should apply rules to employee moved to org unit", func(t *testing.T) {
+		repo := new(MockRepo)
+		orgUnitRepo := new(MockOrgUnitRepo)
+		rules := new(StubRules)
+		svc := NewService(repo, new(MockRoleRepo), orgUnitRepo, new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), rules, new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		orgUnitId := int64(3)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		orgUnitRepo.On("FindById", orgUnitId).Return(orgunit.Entity{Id: orgUnitId, Name: "Finance"}, nil)
+		repo.On("UpdateOrgUnitTx", noTx, int64(1), &orgUnitId).Return(nil)
+
+		err := svc.SetOrgUnit(context.Background(), SetOrgUnitRequest{Id: 1, OrgUnitId: orgUnitId})
+		a.NoError(err)
+		a.Equal([]Entity{{Id: 1, Name: "Alice", OrgUnitId: &orgUnitId}}, rules.employees)
+	})
+
+	t.Run("should fail update when rule grant conflicts with sod rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		conflict := common.SodConflictError{Message: "granting role 2 to employee 1 violates sod rules: Pay and approve (roles 2 and 4)"}
+		rules := &StubRules{err: conflict}
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), rules, new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		title := "Accountant"
+		after := Entity{Id: 1, Name: "Alice", Title: &title}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, after, (*time.Time)(nil)).Return(true, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Alice", Title: &title})
+		a.ErrorAs(err, &common.SodConflictError{})
+		a.ErrorContains(err, "error applying assignment rules to employee with id 1")
+		a.True(repo.AssertNotCalled(t, "FindById", int64(1)))
+	})
+}
+
+func TestServiceSetOrgUnit(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should move employee to org unit and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		orgUnitRepo := new(MockOrgUnitRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), orgUnitRepo, new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		orgUnitId := int64(3)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		orgUnitRepo.On("FindById", orgUnitId).Return(orgunit.Entity{Id: orgUnitId, Name: "Sales"}, nil)
+		repo.On("UpdateOrgUnitTx", noTx, int64(1), &orgUnitId).Return(nil)
+
+		err := svc.SetOrgUnit(context.Background(), SetOrgUnitRequest{Id: 1, OrgUnitId: orgUnitId})
+		a.NoError(err)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice"},
+			After:      auditSnapshot{Id: 1, Name: "Alice", OrgUnitId: &orgUnitId},
+		}}, auditor.events)
+	})
+
+	t.Run("should return not found error when org unit does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		orgUnitRepo := new(MockOrgUnitRepo)
+		svc := NewService(repo, new(MockRoleRepo), orgUnitRepo, new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		orgUnitRepo.On("FindById", int64(3)).Return(orgunit.Entity{}, sql.ErrNoRows)
+
+		err := svc.SetOrgUnit(context.Background(), SetOrgUnitRequest{Id: 1, OrgUnitId: 3})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateOrgUnitTx"))
+	})
+
+	t.Run("should remove employee from org unit", func(t *testing.T) {
+		repo := new(MockRepo)
+		orgUnitRepo := new(MockOrgUnitRepo)
+		svc := NewService(repo, new(MockRoleRepo), orgUnitRepo, new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		orgUnitId := int64(3)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", OrgUnitId: &orgUnitId}, nil)
+		repo.On("UpdateOrgUnitTx", noTx, int64(1), (*int64)(nil)).Return(nil)
+
+		err := svc.RemoveOrgUnit(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(orgUnitRepo.AssertNotCalled(t, "FindById"))
+	})
+}
+
+func TestServiceSetManager(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should set manager and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		managerId := int64(2)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockReportingLinesTx", noTx).Return(nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindById", managerId).Return(Entity{Id: managerId, Name: "Bob", Status: StatusActive}, nil)
+		repo.On("IsReportTx", noTx, int64(1), managerId).Return(false, nil)
+		repo.On("UpdateManagerTx", noTx, int64(1), &managerId).Return(nil)
+
+		err := svc.SetManager(context.Background(), SetManagerRequest{Id: 1, ManagerId: managerId})
+		a.NoError(err)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice"},
+			After:      auditSnapshot{Id: 1, Name: "Alice", ManagerId: &managerId},
+		}}, auditor.events)
+	})
+
+	t.Run("should reject manager who reports to the employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockReportingLinesTx", noTx).Return(nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2, Name: "Bob", Status: StatusActive}, nil)
+		repo.On("IsReportTx", noTx, int64(1), int64(2)).Return(true, nil)
+
+		err := svc.SetManager(context.Background(), SetManagerRequest{Id: 1, ManagerId: 2})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "UpdateManagerTx"))
+	})
+
+	t.Run("should reject terminated manager", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockReportingLinesTx", noTx).Return(nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2, Name: "Bob", Status: StatusTerminated}, nil)
+
+		err := svc.SetManager(context.Background(), SetManagerRequest{Id: 1, ManagerId: 2})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "IsReportTx"))
+		a.True(repo.AssertNotCalled(t, "UpdateManagerTx"))
+	})
+
+	t.Run("should return not found error when manager does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockReportingLinesTx", noTx).Return(nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.SetManager(context.Background(), SetManagerRequest{Id: 1, ManagerId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateManagerTx"))
+	})
+
+	t.Run("should return validation error when employee is their own manager", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		err := svc.SetManager(context.Background(), SetManagerRequest{Id: 1, ManagerId: 1})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should remove manager", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		managerId := int64(2)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", ManagerId: &managerId}, nil)
+		repo.On("UpdateManagerTx", noTx, int64(1), (*int64)(nil)).Return(nil)
+
+		err := svc.RemoveManager(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateManagerTx", 1))
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should update employee and return fresh state", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		version := time.Now().Add(-time.Minute)
+		updatedAt := time.Now()
+		request := UpdateRequest{Id: 1, Name: "Alice Smith", Version: &version}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", UpdatedAt: version}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice Smith", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, request.ToEntity(), &version).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Alice Smith", UpdatedAt: updatedAt}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.Update(context.Background(), request)
+		a.NoError(err)
+		a.Equal("Alice Smith", got.Name)
+		a.Equal(updatedAt, got.UpdatedAt)
+	})
+
+	t.Run("should reject new role conflicting with sod rules", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		sodRules := &StubSod{conflicts: []sod.Entity{{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7}}}
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), sodRules, new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		request := UpdateRequest{Id: 1, Name: "Alice", RoleId: &roleId}
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Approver"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, request.ToEntity(), (*time.Time)(nil)).Return(true, nil)
+
+		_, err := svc.Update(context.Background(), request)
+		a.ErrorAs(err, &common.SodConflictError{})
+		a.Equal([]int64{7}, sodRules.roleIds)
+	})
+
+	t.Run("should not check sod rules when role is unchanged", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		sodRules := &StubSod{conflicts: []sod.Entity{{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7}}}
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), sodRules, new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		request := UpdateRequest{Id: 1, Name: "Alice Smith", RoleId: &roleId}
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Approver"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", RoleId: &roleId}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice Smith", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, request.ToEntity(), (*time.Time)(nil)).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Alice Smith", RoleId: &roleId}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		_, err := svc.Update(context.Background(), request)
+		a.NoError(err)
+		a.Empty(sodRules.roleIds)
+	})
+
+	t.Run("should keep deleted primary role and update other fields", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		request := UpdateRequest{Id: 1, Name: "Alice Smith", RoleId: &roleId}
+		roleRepo.On("FindById", roleId).Return(role.Entity{}, sql.ErrNoRows)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", RoleId: &roleId}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice Smith", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, request.ToEntity(), (*time.Time)(nil)).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Alice Smith", RoleId: &roleId}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.Update(context.Background(), request)
+		a.NoError(err)
+		a.Equal("Alice Smith", got.Name)
+		a.Nil(got.Role)
+	})
+
+	t.Run("should reject role for terminated employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", Status: StatusTerminated}, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Alice", RoleId: &roleId})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should reject new role that is deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(8)
+		request := UpdateRequest{Id: 1, Name: "Alice", RoleId: &roleId}
+		roleRepo.On("FindById", roleId).Return(role.Entity{}, sql.ErrNoRows)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+
+		_, err := svc.Update(context.Background(), request)
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should forbid changing login without permission", func(t *testing.T) {
+		repo := new(MockRepo)
+		access := &StubAccess{denied: []string{"employees:login"}}
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), access, new(StubAuditor), validator.New())
+
+		login := "root"
+		request := UpdateRequest{Id: 1, Name: "Alice", Login: &login}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+		repo.On("FindByLoginExceptTx", noTx, login, int64(1)).Return(false, nil)
+
+		_, err := svc.Update(context.Background(), request)
+		a.Equal(common.ForbiddenError{Message: "permission employees:login is required to change login of employee 1"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should update other fields without permissions for unchanged login and role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		access := &StubAccess{denied: []string{"employees:login", "assignments:create", "assignments:delete"}}
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), access, new(StubAuditor), validator.New())
+
+		login := "alice"
+		roleId := int64(7)
+		request := UpdateRequest{Id: 1, Name: "Alice Smith", Login: &login, RoleId: &roleId}
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Approver"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", Login: &login, RoleId: &roleId}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice Smith", int64(1)).Return(false, nil)
+		repo.On("FindByLoginExceptTx", noTx, login, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, request.ToEntity(), (*time.Time)(nil)).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Alice Smith", Login: &login, RoleId: &roleId}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.Update(context.Background(), request)
+		a.NoError(err)
+		a.Equal("Alice Smith", got.Name)
+	})
+
+	t.Run("should return precondition failed when version is stale", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		version := time.Now().Add(-time.Minute)
+		request := UpdateRequest{Id: 1, Name: "Alice Smith", Version: &version}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", UpdatedAt: time.Now()}, nil)
+
+		_, err := svc.Update(context.Background(), request)
+		a.Equal(common.PreconditionFailedError{Message: "employee with id 1 was modified concurrently"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return not found when employee does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		request := UpdateRequest{Id: 1, Name: "Alice Smith"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), request)
+		a.Equal(common.NotFoundError{Message: "employee with id 1 not found"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should return already exists when name is taken", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Bob", int64(1)).Return(true, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Bob"})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+}
+
+func TestServicePatch(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should keep fields that are not passed", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		current := Entity{Id: 1, Name: "Alice", RoleId: &roleId}
+		newName := "Alice Smith"
+		repo.On("FindById", int64(1)).Return(current, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{Id: roleId, Name: "Admin"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(current, nil)
+		repo.On("FindByNameExceptTx", noTx, newName, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: newName, RoleId: &roleId}, (*time.Time)(nil)).Return(true, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Name: common.Optional[string]{Set: true, Value: &newName}})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+
+	t.Run("should change other fields when primary role is deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		current := Entity{Id: 1, Name: "Alice", RoleId: &roleId}
+		newName := "Alice Smith"
+		repo.On("FindById", int64(1)).Return(current, nil)
+		roleRepo.On("FindById", roleId).Return(role.Entity{}, sql.ErrNoRows)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(current, nil)
+		repo.On("FindByNameExceptTx", noTx, newName, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: newName, RoleId: &roleId}, (*time.Time)(nil)).Return(true, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		got, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Name: common.Optional[string]{Set: true, Value: &newName}})
+		a.NoError(err)
+		a.Nil(got.Role)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+
+	t.Run("should clear role on explicit null", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", RoleId: &roleId}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: "Alice"}, (*time.Time)(nil)).Return(true, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, RoleId: common.Optional[int64]{Set: true}})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+
+	t.Run("should reject null name", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Name: common.Optional[string]{Set: true}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should merge onto employee locked in transaction", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		// параллельный запрос уже сохранил должность: она не должна потеряться
+		title := "Engineer"
+		locked := Entity{Id: 1, Name: "Alice", Title: &title}
+		newName := "Alice Smith"
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(locked, nil)
+		repo.On("FindByNameExceptTx", noTx, newName, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: newName, Title: &title}, (*time.Time)(nil)).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: newName, Title: &title}, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Name: common.Optional[string]{Set: true, Value: &newName}})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+
+	t.Run("should compare version with employee locked in transaction", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		version := time.Now().Add(-time.Minute)
+		newName := "Alice Smith"
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", UpdatedAt: time.Now()}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{
+			Id: 1, Name: common.Optional[string]{Set: true, Value: &newName}, Version: &version,
+		})
+		a.ErrorAs(err, &common.PreconditionFailedError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+}
+
+func TestServiceProfile(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	schema := &StubAttributeRepo{definitions: []attribute.Entity{
+		{Id: 1, Key: "cost_center", Type: "string", Required: true},
+		{Id: 2, Key: "remote", Type: "boolean"},
+	}}
+
+	t.Run("should save profile and custom attributes", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), schema, new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		email, number := "alice@example.com", "E-001"
+		hireDate, err := common.ParseDate("2025-02-03")
+		a.NoError(err)
+		request := CreateRequest{
+			Name:           "Alice",
+			Email:          &email,
+			HireDate:       &hireDate,
+			EmployeeNumber: &number,
+			Attributes:     Attributes{"cost_center": "CC-42", "remote": true},
+		}
+		entity := request.ToEntity()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, "Alice").Return(false, nil)
+		repo.On("FindByEmployeeNumberExceptTx", noTx, number, int64(0)).Return(false, nil)
+		repo.On("SaveTx", noTx, entity).Return(int64(1), nil)
+
+		id, err := svc.Create(context.Background(), request)
+		a.NoError(err)
+		a.Equal(int64(1), id)
+		a.Len(auditor.events, 1)
+		after := auditor.events[0].After.(auditSnapshot)
+		a.Equal(&email, after.Email)
+		a.Equal(Attributes{"cost_center": "CC-42", "remote": true}, after.Attributes)
+	})
+
+	t.Run("should reject attributes that do not match the schema", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), schema, new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		// схема читается в транзакции, поэтому проверка идёт после неё
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, "Alice").Return(false, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{
+			Name:       "Alice",
+			Attributes: Attributes{"remote": "yes", "shoe_size": float64(38)},
+		})
+		a.Equal(common.RequestValidationError{Message: `attribute "remote" must be a boolean` + "\n" +
+			`attribute "shoe_size" is not defined` + "\n" +
+			`attribute "cost_center" is required`}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should reject invalid email and phone", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		email, phone := "alice", "8 (900) 000-00-00"
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Alice", Email: &email, Phone: &phone})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return already exists error for taken employee number", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		number := "E-001"
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(2)).Return(Entity{Id: 2, Name: "Bob"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Bob", int64(2)).Return(false, nil)
+		repo.On("FindByEmployeeNumberExceptTx", noTx, number, int64(2)).Return(true, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 2, Name: "Bob", EmployeeNumber: &number})
+		a.Equal(common.AlreadyExistsError{Message: "employee with number E-001 already exists"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should merge patched attributes into current ones", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), schema, assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		title := "Engineer"
+		current := Entity{Id: 1, Name: "Alice", Title: &title, Attributes: Attributes{"cost_center": "CC-42", "remote": true}}
+		merged := Entity{Id: 1, Name: "Alice", Title: &title, Attributes: Attributes{"cost_center": "CC-7"}}
+		repo.On("FindById", int64(1)).Return(current, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(current, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, merged, (*time.Time)(nil)).Return(true, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		patch := Attributes{"cost_center": "CC-7", "remote": nil}
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Attributes: common.Optional[Attributes]{Set: true, Value: &patch}})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+}
+
+func TestServiceFindAllPage(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return next cursor when more employees exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		request := ListRequest{PageRequest: common.PageRequest{Limit: 2, Sort: "name"}, NamePrefix: "A"}
+		want := request
+		want.Order = "asc"
+		repo.On("FindPage", want, (*common.Cursor)(nil)).Return([]Entity{
+			{Id: 3, Name: "Aaron"},
+			{Id: 1, Name: "Alice"},
+			{Id: 2, Name: "Anna"},
+		}, nil)
+		repo.On("Count", want).Return(int64(5), nil)
+
+		got, err := svc.FindAll(request)
+		a.NoError(err)
+		a.Len(got.Items, 2)
+		a.Equal(int64(5), got.Total)
+		a.Equal(want.Next("Alice", 1), got.NextCursor)
+	})
+
+	t.Run("should pass decoded cursor to repository", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		page := common.PageRequest{Limit: 2, Sort: "name", Order: "desc"}
+		page.Cursor = page.Next("Alice", 1)
+		request := ListRequest{PageRequest: page}
+		repo.On("FindPage", request, &common.Cursor{Sort: "name", Order: "desc", Value: "Alice", Id: 1}).
+			Return([]Entity{{Id: 2, Name: "Aaron"}}, nil)
+		repo.On("Count", request).Return(int64(3), nil)
+
+		got, err := svc.FindAll(request)
+		a.NoError(err)
+		a.Len(got.Items, 1)
+		a.Empty(got.NextCursor)
+	})
+
+	t.Run("should reject cursor issued for another sort", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		issued := common.PageRequest{Sort: "name", Order: "asc"}
+		request := ListRequest{PageRequest: common.PageRequest{Cursor: issued.Next("Alice", 1), Sort: "created_at"}}
+
+		_, err := svc.FindAll(request)
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "FindPage", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should reject unknown sort and too large limit", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		_, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Sort: "password"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		_, err = svc.FindAll(ListRequest{PageRequest: common.PageRequest{Limit: 501}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceSearch(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should rank results and highlight matches", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("Search", "фёдор", 20).Return([]SearchEntity{
+			{Entity: Entity{Id: 1, Name: "Федоров Фёдор", RoleId: &roleId}, Rank: 1},
+			{Entity: Entity{Id: 2, Name: "Fedor Ivanov"}, Rank: 0.2},
+		}, nil)
+		roleRepo.On("FindAllByIds", []int64{roleId}).Return([]role.Entity{{Id: roleId, Name: "Admin"}}, nil)
+
+		got, err := svc.Search(SearchRequest{Query: "фёдор"})
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal(int64(1), got[0].Id)
+		a.Equal(&RoleResponse{Id: roleId, Name: "Admin"}, got[0].Role)
+		a.Equal(1.0, got[0].Rank)
+		a.Equal([]common.Match{{Start: 0, End: 5}, {Start: 8, End: 13}}, got[0].Highlights)
+		a.Empty(got[1].Highlights)
+	})
+
+	t.Run("should highlight accented and misspelled words", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("Search", "jose ivanof", 5).Return([]SearchEntity{
+			{Entity: Entity{Id: 1, Name: "José Ivanov"}, Rank: 0.5},
+		}, nil)
+
+		got, err := svc.Search(SearchRequest{Query: "jose ivanof", Limit: 5})
+		a.NoError(err)
+		a.Equal([]common.Match{{Start: 0, End: 4}, {Start: 5, End: 11}}, got[0].Highlights)
+	})
+
+	t.Run("should reject too short query", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		_, err := svc.Search(SearchRequest{Query: "a"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything))
+	})
+}
+
+func TestServiceRestore(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should restore deleted employee and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", DeletedAt: &deletedAt}, nil)
+		repo.On("RestoreTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(false, nil)
+
+		a.NoError(svc.Restore(context.Background(), IdRequest{Id: 1}))
+		a.True(repo.AssertNumberOfCalls(t, "RestoreTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionRestore,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice", DeletedAt: &deletedAt},
+			After:      auditSnapshot{Id: 1, Name: "Alice"},
+		}}, auditor.events)
+	})
+
+	t.Run("should not restore or audit employee that is not deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+
+		a.NoError(svc.Restore(context.Background(), IdRequest{Id: 1}))
+		a.True(repo.AssertNotCalled(t, "RestoreTx"))
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should return not found when employee does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.Restore(context.Background(), IdRequest{Id: 1})
+		a.Equal(common.NotFoundError{Message: "employee with id 1 not found"}, err)
+	})
+
+	t.Run("should keep employee deleted when name is taken", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice", DeletedAt: &deletedAt}, nil)
+		repo.On("RestoreTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindByNameExceptTx", noTx, "Alice", int64(1)).Return(true, nil)
+
+		err := svc.Restore(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+	})
+}
+
+func TestServiceFindByIdAsOf(t *testing.T) {
+	a := assert.New(t)
+	asOf := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should reconstruct employee and roles from history", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(2)
+		repo.On("FindByIdAsOf", int64(1), asOf).Return(Entity{Id: 1, Name: "Old Name", RoleId: &roleId}, nil)
+		roleRepo.On("FindByIdAsOf", roleId, asOf).Return(role.Entity{Id: 2, Name: "Old Role"}, nil)
+		assignments.On("FindEffectiveRolesAsOf", int64(1), asOf).Return([]role.Entity{{Id: 3, Name: "Auditor"}}, nil)
+
+		got, err := svc.FindById(IdRequest{Id: 1, AsOf: &asOf})
+		a.NoError(err)
+		a.Equal("Old Name", got.Name)
+		a.Equal(&RoleResponse{Id: 2, Name: "Old Role"}, got.Role)
+		a.Equal([]RoleResponse{{Id: 3, Name: "Auditor"}}, got.Roles)
+		a.True(repo.AssertNotCalled(t, "FindById", mock.Anything))
+		a.True(roleRepo.AssertNotCalled(t, "FindById", mock.Anything))
+		a.True(assignments.AssertNotCalled(t, "FindEffectiveRoles", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should return not found error if employee did not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("FindByIdAsOf", int64(1), asOf).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.FindById(IdRequest{Id: 1, AsOf: &asOf})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceFindAllAsOf(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should take roles of listed employees as of the same time", func(t *testing.T) {
+		repo := new(MockRepo)
+		roleRepo := new(MockRoleRepo)
+		svc := NewService(repo, roleRepo, new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		asOf := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+		roleId := int64(2)
+		request := ListRequest{AsOf: &asOf}
+		request.Defaults()
+		repo.On("FindPage", request, (*common.Cursor)(nil)).Return([]Entity{{Id: 1, Name: "Alice", RoleId: &roleId}}, nil)
+		repo.On("Count", request).Return(int64(1), nil)
+		roleRepo.On("FindAllByIdsAsOf", []int64{roleId}, asOf).Return([]role.Entity{{Id: 2, Name: "Old Role"}}, nil)
+
+		got, err := svc.FindAll(ListRequest{AsOf: &asOf})
+		a.NoError(err)
+		a.Len(got.Items, 1)
+		a.Equal(&RoleResponse{Id: 2, Name: "Old Role"}, got.Items[0].Role)
+		a.True(roleRepo.AssertNotCalled(t, "FindAllByIds", mock.Anything))
+	})
+}
+
+func TestServiceHistory(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return versions in order", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		created := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+		renamed := created.Add(time.Hour)
+		repo.On("FindHistory", int64(1)).Return([]HistoryEntity{
+			{Entity: Entity{Id: 1, Name: "Bob"}, VersionFrom: created, VersionTo: &renamed},
+			{Entity: Entity{Id: 1, Name: "Bobby"}, VersionFrom: renamed},
+		}, nil)
+
+		got, err := svc.History(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal([]HistoryResponse{
+			{Id: 1, Name: "Bob", VersionFrom: created, VersionTo: &renamed},
+			{Id: 1, Name: "Bobby", VersionFrom: renamed},
+		}, got)
+	})
+
+	t.Run("should return not found error if employee never existed", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("FindHistory", int64(1)).Return([]HistoryEntity{}, nil)
+
+		_, err := svc.History(IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		_, err := svc.History(IdRequest{Id: 0})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "FindHistory", mock.Anything))
+	})
+}
+
+func TestServiceLogin(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should reject login of another employee on create", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		login := "alice@example.com"
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, "Alice").Return(false, nil)
+		repo.On("FindByLoginExceptTx", noTx, login, int64(0)).Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Alice", Login: &login})
+		a.Equal(common.AlreadyExistsError{Message: "employee with login alice@example.com already exists"}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should keep login on patch", func(t *testing.T) {
+		repo := new(MockRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), assignments, new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		login := "alice@example.com"
+		newName := "Alice Smith"
+		current := Entity{Id: 1, Name: "Alice", Login: &login}
+		repo.On("FindById", int64(1)).Return(current, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(current, nil)
+		repo.On("FindByNameExceptTx", noTx, newName, int64(1)).Return(false, nil)
+		repo.On("FindByLoginExceptTx", noTx, login, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: newName, Login: &login}, (*time.Time)(nil)).Return(true, nil)
+		assignments.On("FindEffectiveRoles", int64(1), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1, Name: common.Optional[string]{Set: true, Value: &newName}})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+}
+
+func TestServiceTransition(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should suspend active employee immediately and audit transition", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		current := Entity{Id: 1, Name: "Alice", Status: StatusActive}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(current, nil)
+		repo.On("SaveTransitionTx", noTx, mock.MatchedBy(func(e TransitionEntity) bool {
+			return e.Transition == TransitionSuspend && e.Status == TransitionApplied && e.AppliedAt != nil
+		})).Return(TransitionEntity{Id: 5, EmployeeId: 1, Transition: TransitionSuspend, ToStatus: StatusSuspended}, nil)
+		repo.On("UpdateStatusTx", noTx, int64(1), StatusSuspended, (*int64)(nil)).Return(nil)
+
+		got, err := svc.Transition(context.Background(), TransitionRequest{Id: 1, Transition: TransitionSuspend})
+		a.NoError(err)
+		a.Equal(int64(5), got.Id)
+		a.Equal([]audit.Event{{
+			Action:     TransitionSuspend,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Alice", Status: StatusActive},
+			After:      auditSnapshot{Id: 1, Name: "Alice", Status: StatusSuspended},
+		}}, auditor.events)
+	})
+
+	t.Run("should revoke all roles and clear primary role on termination", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(StubRevoker)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), revoker, new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		roleId := int64(7)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusSuspended, RoleId: &roleId}, nil)
+		repo.On("SaveTransitionTx", noTx, mock.Anything).
+			Return(TransitionEntity{Id: 5, EmployeeId: 1, Transition: TransitionTerminate, ToStatus: StatusTerminated}, nil)
+		repo.On("UpdateStatusTx", noTx, int64(1), StatusTerminated, (*int64)(nil)).Return(nil)
+
+		_, err := svc.Transition(context.Background(), TransitionRequest{Id: 1, Transition: TransitionTerminate})
+		a.NoError(err)
+		a.Equal([]int64{1}, revoker.employees)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateStatusTx", 1))
+	})
+
+	t.Run("should schedule transition with future effective date", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(StubRevoker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), revoker, new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		effectiveAt := time.Now().Add(24 * time.Hour)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusActive}, nil)
+		repo.On("FindPendingTransitionTx", noTx, int64(1)).Return(TransitionEntity{}, sql.ErrNoRows)
+		repo.On("SaveTransitionTx", noTx, mock.MatchedBy(func(e TransitionEntity) bool {
+			return e.Status == TransitionPending && e.EffectiveAt.Equal(effectiveAt) && e.AppliedAt == nil
+		})).Return(TransitionEntity{Id: 5, EmployeeId: 1, Transition: TransitionTerminate, Status: TransitionPending}, nil)
+
+		got, err := svc.Transition(context.Background(),
+			TransitionRequest{Id: 1, Transition: TransitionTerminate, EffectiveAt: &effectiveAt})
+		a.NoError(err)
+		a.Equal(TransitionPending, got.Status)
+		a.Empty(revoker.employees)
+		a.True(repo.AssertNotCalled(t, "UpdateStatusTx"))
+		a.Len(auditor.events, 1)
+		a.Equal("employee_transition", auditor.events[0].EntityType)
+	})
+
+	t.Run("should return already exists when transition is pending", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		effectiveAt := time.Now().Add(24 * time.Hour)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusActive}, nil)
+		repo.On("FindPendingTransitionTx", noTx, int64(1)).Return(TransitionEntity{Id: 4}, nil)
+
+		_, err := svc.Transition(context.Background(),
+			TransitionRequest{Id: 1, Transition: TransitionSuspend, EffectiveAt: &effectiveAt})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "SaveTransitionTx"))
+	})
+
+	t.Run("should reject transition not allowed from current status", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusTerminated}, nil)
+
+		_, err := svc.Transition(context.Background(), TransitionRequest{Id: 1, Transition: TransitionResume})
+		a.Equal(common.RequestValidationError{Message: "cannot resume employee with id 1 in status terminated"}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTransitionTx"))
+	})
+
+	t.Run("should return validation error for unknown transition", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		_, err := svc.Transition(context.Background(), TransitionRequest{Id: 1, Transition: "promote"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+}
+
+func TestServiceCancelTransition(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should cancel pending transition", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindPendingTransitionTx", noTx, int64(1)).Return(TransitionEntity{Id: 5, Status: TransitionPending}, nil)
+		repo.On("CompleteTransitionTx", noTx, int64(5), TransitionCancelled, (*time.Time)(nil)).
+			Return(TransitionEntity{Id: 5, Status: TransitionCancelled}, nil)
+
+		got, err := svc.CancelTransition(context.Background(), IdRequest{Id: 1})
+		a.NoError(err)
+		a.Equal(TransitionCancelled, got.Status)
+		a.Len(auditor.events, 1)
+		a.Equal("cancel", auditor.events[0].Action)
+	})
+
+	t.Run("should return not found when nothing is pending", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindPendingTransitionTx", noTx, int64(1)).Return(TransitionEntity{}, sql.ErrNoRows)
+
+		_, err := svc.CancelTransition(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceApplyDue(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should apply due transitions and cancel impossible ones", func(t *testing.T) {
+		repo := new(MockRepo)
+		revoker := new(StubRevoker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), revoker, new(StubRules), new(StubSod), new(StubAccess), auditor, validator.New())
+
+		now := time.Now()
+		hire := TransitionEntity{Id: 5, EmployeeId: 1, Transition: TransitionHire, ToStatus: StatusActive}
+		resume := TransitionEntity{Id: 6, EmployeeId: 2, Transition: TransitionResume, ToStatus: StatusActive}
+		repo.On("FindDueTransitions", now).Return([]TransitionEntity{hire, resume}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusPreHire}, nil)
+		repo.On("LockByIdTx", noTx, int64(2)).Return(Entity{Id: 2, Status: StatusTerminated}, nil)
+		repo.On("CompleteTransitionTx", noTx, int64(5), TransitionApplied, &now).Return(hire, nil)
+		repo.On("CompleteTransitionTx", noTx, int64(6), TransitionCancelled, (*time.Time)(nil)).Return(resume, nil)
+		repo.On("UpdateStatusTx", noTx, int64(1), StatusActive, (*int64)(nil)).Return(nil)
+
+		applied, err := svc.ApplyDue(context.Background(), now)
+		a.NoError(err)
+		a.Equal(1, applied)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateStatusTx", 1))
+		a.Len(auditor.events, 2)
+		a.Equal(TransitionHire, auditor.events[0].Action)
+		a.Equal("cancel", auditor.events[1].Action)
+	})
+
+	t.Run("should skip transition cancelled while waiting", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(MockOrgUnitRepo), new(StubAttributeRepo), new(MockAssignmentRepo), new(StubRevoker), new(StubRules), new(StubSod), new(StubAccess), new(StubAuditor), validator.New())
+
+		now := time.Now()
+		suspend := TransitionEntity{Id: 5, EmployeeId: 1, Transition: TransitionSuspend, ToStatus: StatusSuspended}
+		repo.On("FindDueTransitions", now).Return([]TransitionEntity{suspend}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Status: StatusActive}, nil)
+		repo.On("CompleteTransitionTx", noTx, int64(5), TransitionApplied, &now).Return(TransitionEntity{}, sql.ErrNoRows)
+
+		applied, err := svc.ApplyDue(context.Background(), now)
+		a.NoError(err)
+		a.Equal(0, applied)
+		a.True(repo.AssertNotCalled(t, "UpdateStatusTx"))
 	})
 }
@@ -0,0 +1,166 @@
+package ldapsync
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"idm/inner/common"
+	"strings"
+)
+
+// Settings подключение к каталогу LDAP и соответствие его атрибутов данным IDM
+type Settings struct {
+	Url          string
+	BindDn       string
+	BindPassword string
+	BaseDn       string
+	Filter       string
+	PageSize     uint32
+	Mapping      Mapping
+}
+
+// Mapping из каких атрибутов записи берутся имя, логин и группы сотрудника и какие группы становятся ролями
+type Mapping struct {
+	NameAttr   string
+	LoginAttr  string
+	GroupsAttr string
+	// GroupRoles имя роли по cn или DN группы в нижнем регистре; пустое - роль для каждой группы по её cn
+	GroupRoles map[string]string
+}
+
+// Entry запись каталога, прочитанная в соответствии с Mapping
+type Entry struct {
+	Dn     string
+	Name   string
+	Login  string
+	Groups []string
+}
+
+// SettingsFromConfig настройки импорта из конфигурации приложения
+func SettingsFromConfig(cfg common.Config) (Settings, error) {
+	if cfg.LdapUrl == "" {
+		return Settings{}, errors.New("LDAP_URL is not set")
+	}
+	if cfg.LdapBaseDn == "" {
+		return Settings{}, errors.New("LDAP_BASE_DN is not set")
+	}
+	if cfg.LdapPageSize <= 0 {
+		return Settings{}, fmt.Errorf("invalid LDAP_PAGE_SIZE %d", cfg.LdapPageSize)
+	}
+	groupRoles, err := ParseGroupRoles(cfg.LdapGroupRoles)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		Url:          cfg.LdapUrl,
+		BindDn:       cfg.LdapBindDn,
+		BindPassword: cfg.LdapBindPassword,
+		BaseDn:       cfg.LdapBaseDn,
+		Filter:       cfg.LdapFilter,
+		PageSize:     uint32(cfg.LdapPageSize),
+		Mapping: Mapping{
+			NameAttr:   cfg.LdapNameAttr,
+			LoginAttr:  cfg.LdapLoginAttr,
+			GroupsAttr: cfg.LdapGroupsAttr,
+			GroupRoles: groupRoles,
+		},
+	}, nil
+}
+
+// ParseGroupRoles разобрать "группа=роль;группа=роль". Группа - cn или DN; DN содержит '=',
+// поэтому роль отделяется последним '='
+func ParseGroupRoles(raw string) (map[string]string, error) {
+	groupRoles := map[string]string{}
+	for _, pair := range strings.Split(raw, ";") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		invalid := fmt.Errorf("invalid LDAP_GROUP_ROLES entry %q, expected group=role", pair)
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, invalid
+		}
+		group, roleName := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if group == "" || roleName == "" {
+			return nil, invalid
+		}
+		groupRoles[normalizeGroup(group)] = roleName
+	}
+	return groupRoles, nil
+}
+
+// RoleName роль для группы с DN groupDn; false - группа не сопоставлена роли
+func (m Mapping) RoleName(groupDn string) (string, bool) {
+	cn := groupCn(groupDn)
+	if len(m.GroupRoles) == 0 {
+		return cn, cn != ""
+	}
+	if roleName, ok := m.GroupRoles[normalizeGroup(groupDn)]; ok {
+		return roleName, true
+	}
+	roleName, ok := m.GroupRoles[strings.ToLower(cn)]
+	return roleName, ok
+}
+
+// groupCn значение первого RDN группы, обычно её cn; для строки, не являющейся DN, - сама строка
+func groupCn(groupDn string) string {
+	dn, err := ldap.ParseDN(groupDn)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return strings.TrimSpace(groupDn)
+	}
+	return dn.RDNs[0].Attributes[0].Value
+}
+
+// normalizeGroup ключ группы для сравнения: DN приводится к каноническому виду, всё - к нижнему регистру
+func normalizeGroup(group string) string {
+	if dn, err := ldap.ParseDN(group); err == nil && len(dn.RDNs) > 0 {
+		group = dn.String()
+	}
+	return strings.ToLower(group)
+}
+
+// LdapDirectory каталог LDAP, из которого читаются сотрудники
+type LdapDirectory struct {
+	settings Settings
+}
+
+func NewLdapDirectory(settings Settings) *LdapDirectory {
+	return &LdapDirectory{settings: settings}
+}
+
+// Entries прочитать все записи под BaseDn, подходящие под Filter, страницами по PageSize
+func (d *LdapDirectory) Entries() ([]Entry, error) {
+	conn, err := ldap.DialURL(d.settings.Url)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", d.settings.Url, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if d.settings.BindDn != "" {
+		if err = conn.Bind(d.settings.BindDn, d.settings.BindPassword); err != nil {
+			return nil, fmt.Errorf("error binding as %s: %w", d.settings.BindDn, err)
+		}
+	}
+	mapping := d.settings.Mapping
+	request := ldap.NewSearchRequest(
+		d.settings.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		d.settings.Filter,
+		[]string{mapping.NameAttr, mapping.LoginAttr, mapping.GroupsAttr},
+		nil,
+	)
+	result, err := conn.SearchWithPaging(request, d.settings.PageSize)
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s: %w", d.settings.BaseDn, err)
+	}
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entries = append(entries, Entry{
+			Dn:     e.DN,
+			Name:   strings.TrimSpace(e.GetEqualFoldAttributeValue(mapping.NameAttr)),
+			Login:  strings.TrimSpace(e.GetEqualFoldAttributeValue(mapping.LoginAttr)),
+			Groups: e.GetEqualFoldAttributeValues(mapping.GroupsAttr),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,238 @@
+package ldapsync
+
+import (
+	"errors"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeLdap каталог LDAP в памяти теста: принимает простую привязку и поиск с постраничной
+// выдачей (RFC 2696). Фильтр не вычисляется - возвращаются все записи, а сам фильтр запоминается
+type fakeLdap struct {
+	listener net.Listener
+	dn       string
+	password string
+	entries  []*ldap.Entry
+
+	mu       sync.Mutex
+	filters  []string
+	searches int
+}
+
+func newFakeLdap(t *testing.T, dn, password string, entries ...*ldap.Entry) *fakeLdap {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeLdap{listener: listener, dn: dn, password: password, entries: entries}
+	go server.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return server
+}
+
+func (s *fakeLdap) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLdap) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLdap) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	for {
+		request, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageId := request.Children[0].Value.(int64)
+		operation := request.Children[1]
+		switch operation.Tag {
+		case ldap.ApplicationBindRequest:
+			code := uint16(ldap.LDAPResultSuccess)
+			password := operation.Children[2].Data.String()
+			if operation.Children[1].Value.(string) != s.dn || password != s.password {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			s.send(conn, messageId, result(ldap.ApplicationBindResponse, code), nil)
+		case ldap.ApplicationSearchRequest:
+			s.search(conn, messageId, request)
+		default:
+			return
+		}
+	}
+}
+
+func (s *fakeLdap) search(conn net.Conn, messageId int64, request *ber.Packet) {
+	filter, _ := ldap.DecompileFilter(request.Children[1].Children[6])
+	pageSize, start := len(s.entries), 0
+	var paging *ldap.ControlPaging
+	if len(request.Children) > 2 {
+		for _, child := range request.Children[2].Children {
+			control, err := ldap.DecodeControl(child)
+			if err == nil && control.GetControlType() == ldap.ControlTypePaging {
+				paging = control.(*ldap.ControlPaging)
+			}
+		}
+	}
+	if paging != nil {
+		pageSize = int(paging.PagingSize)
+		if len(paging.Cookie) > 0 {
+			start, _ = strconv.Atoi(string(paging.Cookie))
+		}
+	}
+	s.mu.Lock()
+	s.filters = append(s.filters, filter)
+	s.searches++
+	s.mu.Unlock()
+
+	end := min(start+pageSize, len(s.entries))
+	for _, entry := range s.entries[start:end] {
+		packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+		attributes := ber.NewSequence("")
+		for _, attribute := range entry.Attributes {
+			item := ber.NewSequence("")
+			item.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute.Name, ""))
+			values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+			for _, value := range attribute.Values {
+				values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+			}
+			item.AppendChild(values)
+			attributes.AppendChild(item)
+		}
+		packet.AppendChild(attributes)
+		s.send(conn, messageId, packet, nil)
+	}
+	var controls *ber.Packet
+	if paging != nil {
+		response := ldap.NewControlPaging(paging.PagingSize)
+		if end < len(s.entries) {
+			response.SetCookie([]byte(strconv.Itoa(end)))
+		}
+		controls = ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "")
+		controls.AppendChild(response.Encode())
+	}
+	s.send(conn, messageId, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess), controls)
+}
+
+func (s *fakeLdap) send(conn net.Conn, messageId int64, operation, controls *ber.Packet) {
+	envelope := ber.NewSequence("")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, ""))
+	envelope.AppendChild(operation)
+	if controls != nil {
+		envelope.AppendChild(controls)
+	}
+	_, _ = conn.Write(envelope.Bytes())
+}
+
+func result(tag ber.Tag, code uint16) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return packet
+}
+
+func person(dn, cn, mail string, groups ...string) *ldap.Entry {
+	return ldap.NewEntry(dn, map[string][]string{"cn": {cn}, "mail": {mail}, "memberOf": groups})
+}
+
+func testSettings(url string) Settings {
+	return Settings{
+		Url:          url,
+		BindDn:       "cn=reader,dc=example,dc=com",
+		BindPassword: "secret",
+		BaseDn:       "ou=people,dc=example,dc=com",
+		Filter:       "(objectClass=person)",
+		PageSize:     2,
+		Mapping:      Mapping{NameAttr: "cn", LoginAttr: "mail", GroupsAttr: "memberOf"},
+	}
+}
+
+func TestLdapDirectoryEntries(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should read all pages and map attributes", func(t *testing.T) {
+		server := newFakeLdap(t, "cn=reader,dc=example,dc=com", "secret",
+			person("uid=alice,ou=people,dc=example,dc=com", "Alice Smith", "alice@example.com", "cn=devs,ou=groups,dc=example,dc=com"),
+			person("uid=bob,ou=people,dc=example,dc=com", "Bob Brown", "bob@example.com"),
+			person("uid=carol,ou=people,dc=example,dc=com", "Carol White", "carol@example.com"),
+		)
+
+		entries, err := NewLdapDirectory(testSettings(server.url())).Entries()
+		a.Nil(err)
+		a.Equal([]Entry{
+			{
+				Dn:     "uid=alice,ou=people,dc=example,dc=com",
+				Name:   "Alice Smith",
+				Login:  "alice@example.com",
+				Groups: []string{"cn=devs,ou=groups,dc=example,dc=com"},
+			},
+			{Dn: "uid=bob,ou=people,dc=example,dc=com", Name: "Bob Brown", Login: "bob@example.com", Groups: []string{}},
+			{Dn: "uid=carol,ou=people,dc=example,dc=com", Name: "Carol White", Login: "carol@example.com", Groups: []string{}},
+		}, entries)
+		// три записи страницами по две
+		a.Equal(2, server.searches)
+		a.Equal("(objectClass=person)", server.filters[0])
+	})
+
+	t.Run("should return error on invalid credentials", func(t *testing.T) {
+		server := newFakeLdap(t, "cn=reader,dc=example,dc=com", "other")
+
+		_, err := NewLdapDirectory(testSettings(server.url())).Entries()
+		a.NotNil(err)
+		var ldapErr *ldap.Error
+		a.True(errors.As(err, &ldapErr))
+		a.Equal(uint16(ldap.LDAPResultInvalidCredentials), ldapErr.ResultCode)
+	})
+}
+
+func TestMappingRoleName(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should use cn of every group without configured roles", func(t *testing.T) {
+		roleName, ok := Mapping{}.RoleName("CN=Developers,OU=Groups,DC=example,DC=com")
+		a.True(ok)
+		a.Equal("Developers", roleName)
+	})
+
+	t.Run("should map only configured groups by cn or dn", func(t *testing.T) {
+		groupRoles, err := ParseGroupRoles("devs=developers; cn=Ops Team,ou=groups,dc=example,dc=com=operations")
+		a.Nil(err)
+		mapping := Mapping{GroupRoles: groupRoles}
+
+		roleName, ok := mapping.RoleName("cn=DEVS,ou=groups,dc=example,dc=com")
+		a.True(ok)
+		a.Equal("developers", roleName)
+
+		roleName, ok = mapping.RoleName("CN=Ops Team,OU=Groups,DC=example,DC=com")
+		a.True(ok)
+		a.Equal("operations", roleName)
+
+		_, ok = mapping.RoleName("cn=sales,ou=groups,dc=example,dc=com")
+		a.False(ok)
+	})
+
+	t.Run("should reject malformed group roles", func(t *testing.T) {
+		_, err := ParseGroupRoles("devs")
+		a.NotNil(err)
+		_, err = ParseGroupRoles("devs=")
+		a.NotNil(err)
+	})
+}
@@ -0,0 +1,80 @@
+package ldapsync
+
+import (
+	"fmt"
+	"io"
+)
+
+// Виды действий импорта
+const (
+	ActionCreateEmployee = "create employee"
+	ActionUpdateEmployee = "update employee"
+	ActionCreateRole     = "create role"
+	ActionGrantRole      = "grant role"
+)
+
+// Action изменение в IDM: выполненное или, при пробном запуске, запланированное
+type Action struct {
+	Kind   string
+	Target string
+}
+
+// Issue запись каталога, которая пропущена или не импортирована из-за ошибки
+type Issue struct {
+	Dn     string
+	Reason string
+}
+
+// Report итог импорта. При DryRun действия только перечисляются, в IDM ничего не меняется
+type Report struct {
+	DryRun    bool
+	Entries   int
+	Unchanged int
+	Actions   []Action
+	Skipped   []Issue
+	Failed    []Issue
+}
+
+func (r *Report) add(kind, target string) {
+	r.Actions = append(r.Actions, Action{Kind: kind, Target: target})
+}
+
+// Count число действий вида kind
+func (r *Report) Count(kind string) int {
+	count := 0
+	for _, action := range r.Actions {
+		if action.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Print вывести отчёт: действия, пропущенные и неудавшиеся записи, затем сводку
+func (r *Report) Print(w io.Writer) error {
+	var lines []string
+	for _, action := range r.Actions {
+		lines = append(lines, fmt.Sprintf("%s %s", action.Kind, action.Target))
+	}
+	for _, issue := range r.Skipped {
+		lines = append(lines, fmt.Sprintf("skip %s: %s", issue.Dn, issue.Reason))
+	}
+	for _, issue := range r.Failed {
+		lines = append(lines, fmt.Sprintf("fail %s: %s", issue.Dn, issue.Reason))
+	}
+	mode := ""
+	if r.DryRun {
+		mode = " (dry run, nothing changed)"
+	}
+	lines = append(lines, fmt.Sprintf(
+		"ldap sync%s: %d entries, %d employees created, %d updated, %d unchanged, %d roles created, %d roles granted, %d skipped, %d failed",
+		mode, r.Entries, r.Count(ActionCreateEmployee), r.Count(ActionUpdateEmployee), r.Unchanged,
+		r.Count(ActionCreateRole), r.Count(ActionGrantRole), len(r.Skipped), len(r.Failed),
+	))
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
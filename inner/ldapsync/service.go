@@ -0,0 +1,265 @@
+package ldapsync
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// loadPageLimit размер страницы, которой читаются сотрудники и роли IDM
+const loadPageLimit = 500
+
+// Directory источник записей о сотрудниках
+type Directory interface {
+	Entries() ([]Entry, error)
+}
+
+type EmployeeSvc interface {
+	Create(ctx context.Context, request employee.CreateRequest) (int64, error)
+	FindAll(request employee.ListRequest) (common.Page[employee.Response], error)
+	Update(ctx context.Context, request employee.UpdateRequest) (employee.Response, error)
+}
+
+type RoleSvc interface {
+	Create(ctx context.Context, request role.CreateRequest) (int64, error)
+	FindAll(request role.ListRequest) (common.Page[role.Response], error)
+}
+
+type AssignmentSvc interface {
+	Grant(ctx context.Context, request assignment.GrantRequest) (int64, error)
+	FindByEmployee(request assignment.EmployeeRequest) ([]assignment.Response, error)
+}
+
+// Service импортирует сотрудников из каталога через сервисы IDM, поэтому изменения проходят
+// ту же валидацию и попадают в журнал аудита. Импорт только добавляет: сотрудники, которых
+// нет в каталоге, и назначения ролей, которых нет в группах, не удаляются.
+type Service struct {
+	directory   Directory
+	mapping     Mapping
+	employees   EmployeeSvc
+	roles       RoleSvc
+	assignments AssignmentSvc
+	logger      *common.Logger
+}
+
+func NewService(
+	directory Directory,
+	mapping Mapping,
+	employees EmployeeSvc,
+	roles RoleSvc,
+	assignments AssignmentSvc,
+	logger *common.Logger,
+) *Service {
+	return &Service{
+		directory:   directory,
+		mapping:     mapping,
+		employees:   employees,
+		roles:       roles,
+		assignments: assignments,
+		logger:      logger,
+	}
+}
+
+// state сотрудники и роли IDM, известные импорту; пополняется по мере создания
+type state struct {
+	byLogin map[string]*employee.Response
+	byName  map[string]*employee.Response
+	// roles id роли по имени в нижнем регистре; 0 - роль, которая будет создана (при пробном запуске)
+	roles map[string]int64
+}
+
+// Sync сопоставить записи каталога сотрудникам по логину и создать или обновить их, создать роли
+// для групп и назначить их. Сотрудник без логина с тем же именем получает логин из каталога.
+// Ошибка одной записи не останавливает импорт и попадает в Report.Failed; ошибка возвращается,
+// только если не удалось прочитать каталог или данные IDM.
+func (svc *Service) Sync(ctx context.Context, dryRun bool) (Report, error) {
+	entries, err := svc.directory.Entries()
+	if err != nil {
+		return Report{}, fmt.Errorf("error reading ldap directory: %w", err)
+	}
+	st, err := svc.loadState()
+	if err != nil {
+		return Report{}, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Dn < entries[j].Dn
+	})
+	report := Report{DryRun: dryRun, Entries: len(entries)}
+	seen := map[string]string{}
+	for _, entry := range entries {
+		if entry.Login == "" {
+			report.Skipped = append(report.Skipped, Issue{Dn: entry.Dn, Reason: fmt.Sprintf("no %s attribute", svc.mapping.LoginAttr)})
+			continue
+		}
+		if entry.Name == "" {
+			report.Skipped = append(report.Skipped, Issue{Dn: entry.Dn, Reason: fmt.Sprintf("no %s attribute", svc.mapping.NameAttr)})
+			continue
+		}
+		key := strings.ToLower(entry.Login)
+		if dn, ok := seen[key]; ok {
+			report.Skipped = append(report.Skipped, Issue{Dn: entry.Dn, Reason: fmt.Sprintf("login %s is already used by %s", entry.Login, dn)})
+			continue
+		}
+		seen[key] = entry.Dn
+		if err = svc.syncEntry(ctx, entry, st, &report); err != nil {
+			svc.logger.Warn("ldap sync: entry failed", zap.String("dn", entry.Dn), zap.Error(err))
+			report.Failed = append(report.Failed, Issue{Dn: entry.Dn, Reason: err.Error()})
+		}
+	}
+	return report, nil
+}
+
+func (svc *Service) syncEntry(ctx context.Context, entry Entry, st *state, report *Report) error {
+	existing := st.byLogin[strings.ToLower(entry.Login)]
+	if existing == nil {
+		if named := st.byName[entry.Name]; named != nil {
+			if named.Login != nil {
+				report.Skipped = append(report.Skipped, Issue{
+					Dn:     entry.Dn,
+					Reason: fmt.Sprintf("name %q is used by employee %d with login %s", entry.Name, named.Id, *named.Login),
+				})
+				return nil
+			}
+			existing = named
+		}
+	}
+	var employeeId int64
+	switch {
+	case existing == nil:
+		report.add(ActionCreateEmployee, fmt.Sprintf("%s (%s)", entry.Login, entry.Name))
+		if !report.DryRun {
+			id, err := svc.employees.Create(ctx, employee.CreateRequest{Name: entry.Name, Login: &entry.Login})
+			if err != nil {
+				return fmt.Errorf("error creating employee: %w", err)
+			}
+			employeeId = id
+		}
+		created := &employee.Response{Id: employeeId, Name: entry.Name, Login: &entry.Login}
+		st.byLogin[strings.ToLower(entry.Login)] = created
+		st.byName[entry.Name] = created
+	case existing.Name != entry.Name || existing.Login == nil || *existing.Login != entry.Login:
+		report.add(ActionUpdateEmployee, fmt.Sprintf("%s (%s)", entry.Login, entry.Name))
+		employeeId = existing.Id
+		if !report.DryRun {
+			request := existing.UpdateRequest()
+			request.Name = entry.Name
+			request.Login = &entry.Login
+			_, err := svc.employees.Update(ctx, request)
+			if err != nil {
+				return fmt.Errorf("error updating employee %d: %w", existing.Id, err)
+			}
+		}
+	default:
+		report.Unchanged++
+		employeeId = existing.Id
+	}
+	return svc.syncRoles(ctx, entry, employeeId, st, report)
+}
+
+// syncRoles создать недостающие роли для групп записи и назначить те, что у сотрудника ещё не действуют.
+// employeeId 0 - сотрудник, который будет создан при обычном запуске
+func (svc *Service) syncRoles(ctx context.Context, entry Entry, employeeId int64, st *state, report *Report) error {
+	roleNames := svc.roleNames(entry.Groups)
+	if len(roleNames) == 0 {
+		return nil
+	}
+	var held []int64
+	if employeeId != 0 {
+		current, err := svc.assignments.FindByEmployee(assignment.EmployeeRequest{EmployeeId: employeeId})
+		if err != nil {
+			return fmt.Errorf("error finding roles of employee %d: %w", employeeId, err)
+		}
+		for _, a := range current {
+			held = append(held, a.RoleId)
+		}
+	}
+	for _, roleName := range roleNames {
+		roleId, ok := st.roles[strings.ToLower(roleName)]
+		if !ok {
+			report.add(ActionCreateRole, roleName)
+			if !report.DryRun {
+				id, err := svc.roles.Create(ctx, role.CreateRequest{Name: roleName})
+				if err != nil {
+					return fmt.Errorf("error creating role %s: %w", roleName, err)
+				}
+				roleId = id
+			}
+			st.roles[strings.ToLower(roleName)] = roleId
+		}
+		if roleId != 0 && slices.Contains(held, roleId) {
+			continue
+		}
+		report.add(ActionGrantRole, fmt.Sprintf("%s to %s", roleName, entry.Login))
+		if report.DryRun {
+			continue
+		}
+		_, err := svc.assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: employeeId, RoleId: roleId})
+		if err != nil {
+			return fmt.Errorf("error granting role %s: %w", roleName, err)
+		}
+	}
+	return nil
+}
+
+// roleNames роли для групп записи без повторов; группы без роли пропускаются
+func (svc *Service) roleNames(groups []string) []string {
+	var names []string
+	for _, group := range groups {
+		roleName, ok := svc.mapping.RoleName(group)
+		if !ok {
+			continue
+		}
+		if !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, roleName) }) {
+			names = append(names, roleName)
+		}
+	}
+	return names
+}
+
+func (svc *Service) loadState() (*state, error) {
+	st := &state{
+		byLogin: map[string]*employee.Response{},
+		byName:  map[string]*employee.Response{},
+		roles:   map[string]int64{},
+	}
+	employeeRequest := employee.ListRequest{PageRequest: common.PageRequest{Limit: loadPageLimit}}
+	for {
+		page, err := svc.employees.FindAll(employeeRequest)
+		if err != nil {
+			return nil, fmt.Errorf("error loading employees: %w", err)
+		}
+		for i := range page.Items {
+			e := &page.Items[i]
+			if e.Login != nil {
+				st.byLogin[strings.ToLower(*e.Login)] = e
+			}
+			st.byName[e.Name] = e
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		employeeRequest.Cursor = page.NextCursor
+	}
+	roleRequest := role.ListRequest{PageRequest: common.PageRequest{Limit: loadPageLimit}}
+	for {
+		page, err := svc.roles.FindAll(roleRequest)
+		if err != nil {
+			return nil, fmt.Errorf("error loading roles: %w", err)
+		}
+		for _, r := range page.Items {
+			st.roles[strings.ToLower(r.Name)] = r.Id
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		roleRequest.Cursor = page.NextCursor
+	}
+	return st, nil
+}
@@ -0,0 +1,211 @@
+package ldapsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"testing"
+)
+
+type StubDirectory struct {
+	entries []Entry
+	err     error
+}
+
+func (d *StubDirectory) Entries() ([]Entry, error) {
+	return d.entries, d.err
+}
+
+type MockEmployeeSvc struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeSvc) Create(ctx context.Context, request employee.CreateRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) FindAll(request employee.ListRequest) (common.Page[employee.Response], error) {
+	args := m.Called(request)
+	return args.Get(0).(common.Page[employee.Response]), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) Update(ctx context.Context, request employee.UpdateRequest) (employee.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).(employee.Response), args.Error(1)
+}
+
+type MockRoleSvc struct {
+	mock.Mock
+}
+
+func (m *MockRoleSvc) Create(ctx context.Context, request role.CreateRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoleSvc) FindAll(request role.ListRequest) (common.Page[role.Response], error) {
+	args := m.Called(request)
+	return args.Get(0).(common.Page[role.Response]), args.Error(1)
+}
+
+type MockAssignmentSvc struct {
+	mock.Mock
+}
+
+func (m *MockAssignmentSvc) Grant(ctx context.Context, request assignment.GrantRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAssignmentSvc) FindByEmployee(request assignment.EmployeeRequest) ([]assignment.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).([]assignment.Response), args.Error(1)
+}
+
+var (
+	allEmployees = employee.ListRequest{PageRequest: common.PageRequest{Limit: loadPageLimit}}
+	allRoles     = role.ListRequest{PageRequest: common.PageRequest{Limit: loadPageLimit}}
+	mapping      = Mapping{NameAttr: "cn", LoginAttr: "mail", GroupsAttr: "memberOf"}
+	logger       = &common.Logger{Logger: zap.NewNop()}
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+// directoryFixture каталог: новый сотрудник, сотрудник с изменившимся именем, сотрудник без изменений
+// и сотрудник без логина в IDM, которого нужно связать по имени
+func directoryFixture() *StubDirectory {
+	return &StubDirectory{entries: []Entry{
+		{Dn: "uid=dave,dc=example", Name: "Dave Green", Login: "dave@example.com", Groups: []string{"cn=devs,dc=example"}},
+		{Dn: "uid=alice,dc=example", Name: "Alice Jones", Login: "alice@example.com", Groups: []string{"cn=devs,dc=example"}},
+		{Dn: "uid=bob,dc=example", Name: "Bob Brown", Login: "BOB@example.com"},
+		{Dn: "uid=carol,dc=example", Name: "Carol White", Login: "carol@example.com", Groups: []string{"cn=ops,dc=example"}},
+	}}
+}
+
+func employeesFixture() common.Page[employee.Response] {
+	return common.Page[employee.Response]{Items: []employee.Response{
+		{Id: 1, Name: "Alice Smith", Login: ptr("alice@example.com"), Role: &employee.RoleResponse{Id: 7, Name: "staff"}},
+		{Id: 2, Name: "Bob Brown", Login: ptr("BOB@example.com")},
+		{Id: 3, Name: "Carol White"},
+	}}
+}
+
+func rolesFixture() common.Page[role.Response] {
+	return common.Page[role.Response]{Items: []role.Response{{Id: 4, Name: "devs"}}}
+}
+
+func TestServiceSync(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should only report changes on dry run", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindAll", allEmployees).Return(employeesFixture(), nil)
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", allRoles).Return(rolesFixture(), nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByEmployee", assignment.EmployeeRequest{EmployeeId: 1}).
+			Return([]assignment.Response{{EmployeeId: 1, RoleId: 4}}, nil)
+		assignments.On("FindByEmployee", assignment.EmployeeRequest{EmployeeId: 3}).Return([]assignment.Response{}, nil)
+		svc := NewService(directoryFixture(), mapping, employees, roles, assignments, logger)
+
+		report, err := svc.Sync(context.Background(), true)
+		a.Nil(err)
+		a.Equal([]Action{
+			{Kind: ActionUpdateEmployee, Target: "alice@example.com (Alice Jones)"},
+			{Kind: ActionUpdateEmployee, Target: "carol@example.com (Carol White)"},
+			{Kind: ActionCreateRole, Target: "ops"},
+			{Kind: ActionGrantRole, Target: "ops to carol@example.com"},
+			{Kind: ActionCreateEmployee, Target: "dave@example.com (Dave Green)"},
+			{Kind: ActionGrantRole, Target: "devs to dave@example.com"},
+		}, report.Actions)
+		a.Equal(1, report.Unchanged)
+		a.True(employees.AssertNotCalled(t, "Create", mock.Anything))
+		a.True(employees.AssertNotCalled(t, "Update", mock.Anything))
+		a.True(roles.AssertNotCalled(t, "Create", mock.Anything))
+		a.True(assignments.AssertNotCalled(t, "Grant", mock.Anything))
+
+		var out bytes.Buffer
+		a.Nil(report.Print(&out))
+		a.Contains(out.String(), "create employee dave@example.com (Dave Green)\n")
+		a.Contains(out.String(), "ldap sync (dry run, nothing changed): 4 entries, 1 employees created, 2 updated, 1 unchanged, "+
+			"1 roles created, 2 roles granted, 0 skipped, 0 failed")
+	})
+
+	t.Run("should apply changes through services", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindAll", allEmployees).Return(employeesFixture(), nil)
+		roleId := int64(7)
+		employees.On("Update", employee.UpdateRequest{Id: 1, Name: "Alice Jones", RoleId: &roleId, Login: ptr("alice@example.com")}).
+			Return(employee.Response{}, nil)
+		employees.On("Update", employee.UpdateRequest{Id: 3, Name: "Carol White", Login: ptr("carol@example.com")}).
+			Return(employee.Response{}, nil)
+		employees.On("Create", employee.CreateRequest{Name: "Dave Green", Login: ptr("dave@example.com")}).Return(int64(5), nil)
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", allRoles).Return(rolesFixture(), nil)
+		roles.On("Create", role.CreateRequest{Name: "ops"}).Return(int64(6), nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByEmployee", assignment.EmployeeRequest{EmployeeId: 1}).
+			Return([]assignment.Response{{EmployeeId: 1, RoleId: 4}}, nil)
+		assignments.On("FindByEmployee", assignment.EmployeeRequest{EmployeeId: 3}).Return([]assignment.Response{}, nil)
+		assignments.On("FindByEmployee", assignment.EmployeeRequest{EmployeeId: 5}).Return([]assignment.Response{}, nil)
+		assignments.On("Grant", assignment.GrantRequest{EmployeeId: 3, RoleId: 6}).Return(int64(20), nil)
+		assignments.On("Grant", assignment.GrantRequest{EmployeeId: 5, RoleId: 4}).Return(int64(21), nil)
+		svc := NewService(directoryFixture(), mapping, employees, roles, assignments, logger)
+
+		report, err := svc.Sync(context.Background(), false)
+		a.Nil(err)
+		a.Empty(report.Failed)
+		a.Len(report.Actions, 6)
+		employees.AssertExpectations(t)
+		roles.AssertExpectations(t)
+		assignments.AssertExpectations(t)
+	})
+
+	t.Run("should skip invalid entries and continue after failures", func(t *testing.T) {
+		directory := &StubDirectory{entries: []Entry{
+			{Dn: "uid=a,dc=example", Name: "Anna"},
+			{Dn: "uid=b,dc=example", Name: "Boris", Login: "boris@example.com"},
+			{Dn: "uid=c,dc=example", Name: "Boris II", Login: "BORIS@example.com"},
+			{Dn: "uid=d,dc=example", Name: "Alice Smith", Login: "other@example.com"},
+			{Dn: "uid=e,dc=example", Name: "Eve", Login: "eve@example.com"},
+		}}
+		employees := new(MockEmployeeSvc)
+		employees.On("FindAll", allEmployees).Return(employeesFixture(), nil)
+		employees.On("Create", employee.CreateRequest{Name: "Boris", Login: ptr("boris@example.com")}).
+			Return(int64(0), common.RequestValidationError{Message: "invalid name"})
+		employees.On("Create", employee.CreateRequest{Name: "Eve", Login: ptr("eve@example.com")}).Return(int64(8), nil)
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", allRoles).Return(rolesFixture(), nil)
+		svc := NewService(directory, mapping, employees, roles, new(MockAssignmentSvc), logger)
+
+		report, err := svc.Sync(context.Background(), false)
+		a.Nil(err)
+		a.Equal([]Issue{
+			{Dn: "uid=a,dc=example", Reason: "no mail attribute"},
+			{Dn: "uid=c,dc=example", Reason: "login BORIS@example.com is already used by uid=b,dc=example"},
+			{Dn: "uid=d,dc=example", Reason: `name "Alice Smith" is used by employee 1 with login alice@example.com`},
+		}, report.Skipped)
+		a.Equal([]Issue{{Dn: "uid=b,dc=example", Reason: "error creating employee: invalid name"}}, report.Failed)
+		employees.AssertCalled(t, "Create", employee.CreateRequest{Name: "Eve", Login: ptr("eve@example.com")})
+	})
+
+	t.Run("should return error when directory is unavailable", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		directory := &StubDirectory{err: errors.New("connection refused")}
+		svc := NewService(directory, mapping, employees, new(MockRoleSvc), new(MockAssignmentSvc), logger)
+
+		_, err := svc.Sync(context.Background(), true)
+		a.EqualError(err, "error reading ldap directory: connection refused")
+		a.True(employees.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
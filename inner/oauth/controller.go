@@ -0,0 +1,212 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Разрешения, которые требуют маршруты управления клиентами
+const (
+	permissionRead   = "clients:read"
+	permissionManage = "clients:manage"
+)
+
+type Controller struct {
+	server       *web.Server
+	oauthService Svc
+	logger       *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (SecretResponse, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	DeleteById(ctx context.Context, request IdRequest) error
+	Token(request TokenRequest) (TokenResponse, error)
+	Jwks() ([]byte, error)
+}
+
+func NewController(server *web.Server, oauthService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:       server,
+		oauthService: oauthService,
+		logger:       logger,
+	}
+}
+
+// RegisterRoutes точка выдачи токенов и JWKS открыты: клиент ещё не имеет токена,
+// а другим сервисам ключи нужны для проверки токенов IDM
+func (c *Controller) RegisterRoutes() {
+	c.server.App.Post("/oauth2/token", c.Token)
+	c.server.App.Get("/.well-known/jwks.json", c.Jwks)
+	c.server.GroupApiV1.Post("/oauth-clients", c.server.Require(permissionManage), c.CreateClient)
+	c.server.GroupApiV1.Get("/oauth-clients/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/oauth-clients", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Delete("/oauth-clients/:id", c.server.Require(permissionManage), c.DeleteById)
+}
+
+// Token ответы в формате RFC 6749, а не в общем конверте API: их разбирают стандартные клиенты OAuth2
+func (c *Controller) Token(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderPragma, "no-cache")
+	request := TokenRequest{
+		GrantType:    ctx.FormValue("grant_type"),
+		ClientId:     ctx.FormValue("client_id"),
+		ClientSecret: ctx.FormValue("client_secret"),
+		Scope:        ctx.FormValue("scope"),
+	}
+	if header := ctx.Get(fiber.HeaderAuthorization); header != "" {
+		if request.ClientId != "" || request.ClientSecret != "" {
+			return c.tokenError(ctx, TokenError{
+				Code:        errorInvalidRequest,
+				Description: "client credentials must be sent either in header or in body",
+			})
+		}
+		var ok bool
+		request.ClientId, request.ClientSecret, ok = basicCredentials(header)
+		if !ok {
+			return c.tokenError(ctx, TokenError{Code: errorInvalidClient, Description: "malformed basic credentials"})
+		}
+	}
+	c.logger.Debug("issue token: received request",
+		zap.String("grant_type", request.GrantType), zap.String("client_id", request.ClientId))
+	response, err := c.oauthService.Token(request)
+	if err != nil {
+		return c.tokenError(ctx, err)
+	}
+	c.logger.Info("issue token: success", zap.String("client_id", request.ClientId), zap.String("scope", response.Scope))
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
+
+// tokenError ответ об ошибке по RFC 6749: неверный клиент - 401 с WWW-Authenticate, прочие ошибки запроса - 400
+func (c *Controller) tokenError(ctx *fiber.Ctx, err error) error {
+	var tokenErr TokenError
+	if !errors.As(err, &tokenErr) {
+		c.logger.Error("issue token: service error", zap.Error(err))
+		return ctx.Status(fiber.StatusInternalServerError).JSON(TokenError{Code: errorServerError})
+	}
+	c.logger.Warn("issue token: rejected", zap.Error(err))
+	if tokenErr.Code == errorInvalidClient {
+		ctx.Set(fiber.HeaderWWWAuthenticate, `Basic realm="idm"`)
+		return ctx.Status(fiber.StatusUnauthorized).JSON(tokenErr)
+	}
+	return ctx.Status(fiber.StatusBadRequest).JSON(tokenErr)
+}
+
+func (c *Controller) Jwks(ctx *fiber.Ctx) error {
+	data, err := c.oauthService.Jwks()
+	if err != nil {
+		c.logger.Error("get jwks: service error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusInternalServerError, "error returning signing keys")
+	}
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.Send(data)
+}
+
+func (c *Controller) CreateClient(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create oauth client: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create oauth client: received request",
+		zap.String("client_id", request.ClientId), zap.Strings("scopes", request.Scopes))
+	response, err := c.oauthService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create oauth client: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create oauth client: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find oauth client by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find oauth client by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.oauthService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find oauth client by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find oauth client by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all oauth clients: received request")
+	responses, err := c.oauthService.FindAll()
+	if err != nil {
+		c.logger.Error("find all oauth clients: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all oauth clients: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) DeleteById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete oauth client by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete oauth client by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.oauthService.DeleteById(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete oauth client by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete oauth client by id: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+// basicCredentials идентификатор и секрет клиента из заголовка Authorization: Basic;
+// по RFC 6749 обе части закодированы как application/x-www-form-urlencoded
+func basicCredentials(header string) (clientId, clientSecret string, ok bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	rawId, rawSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	if clientId, err = url.QueryUnescape(rawId); err != nil {
+		return "", "", false
+	}
+	if clientSecret, err = url.QueryUnescape(rawSecret); err != nil {
+		return "", "", false
+	}
+	return clientId, clientSecret, true
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	case errors.As(err, &common.ForbiddenError{}):
+		return fiber.StatusForbidden
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
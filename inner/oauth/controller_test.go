@@ -0,0 +1,281 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(SecretResponse), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) DeleteById(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) Token(request TokenRequest) (TokenResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(TokenResponse), args.Error(1)
+}
+
+func (svc *MockService) Jwks() ([]byte, error) {
+	args := svc.Called()
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func tokenRequest(form, authorization string) *http.Request {
+	req := httptest.NewRequest(fiber.MethodPost, "/oauth2/token", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return req
+}
+
+func TestControllerToken(t *testing.T) {
+	a := assert.New(t)
+	issued := TokenResponse{AccessToken: "token", TokenType: "Bearer", ExpiresIn: 900, Scope: "employees:read"}
+
+	t.Run("should accept client credentials in basic header", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Token", TokenRequest{
+			GrantType: "client_credentials", ClientId: "reports", ClientSecret: "s3cr=t", Scope: "employees:read",
+		}).Return(issued, nil)
+
+		// секрет в заголовке закодирован как form-urlencoded
+		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("reports:s3cr%3Dt"))
+		resp, err := server.App.Test(tokenRequest("grant_type=client_credentials&scope=employees%3Aread", basic))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal("no-store", resp.Header.Get("Cache-Control"))
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody TokenResponse
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(issued, responseBody)
+	})
+
+	t.Run("should accept client credentials in body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Token", TokenRequest{GrantType: "client_credentials", ClientId: "reports", ClientSecret: "secret"}).
+			Return(issued, nil)
+
+		resp, err := server.App.Test(tokenRequest("grant_type=client_credentials&client_id=reports&client_secret=secret", ""))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return invalid_client with 401", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Token", mock.Anything).Return(TokenResponse{}, TokenError{Code: errorInvalidClient})
+
+		resp, err := server.App.Test(tokenRequest("grant_type=client_credentials&client_id=reports&client_secret=guess", ""))
+		a.Nil(err)
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal(`Basic realm="idm"`, resp.Header.Get("WWW-Authenticate"))
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.JSONEq(`{"error":"invalid_client"}`, string(bytesData))
+	})
+
+	t.Run("should return other token errors with 400", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Token", mock.Anything).Return(TokenResponse{}, TokenError{Code: errorUnsupportedGrantType})
+
+		resp, err := server.App.Test(tokenRequest("grant_type=password&client_id=reports&client_secret=secret", ""))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should reject credentials in both header and body", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("reports:secret"))
+		resp, err := server.App.Test(tokenRequest("grant_type=client_credentials&client_id=reports", basic))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Token", mock.Anything))
+	})
+
+	t.Run("should hide internal errors", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Token", mock.Anything).Return(TokenResponse{}, errors.New("database error"))
+
+		resp, err := server.App.Test(tokenRequest("grant_type=client_credentials&client_id=reports&client_secret=secret", ""))
+		a.Nil(err)
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.JSONEq(`{"error":"server_error"}`, string(bytesData))
+	})
+}
+
+func TestControllerJwks(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should publish signing keys", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Jwks").Return([]byte(`{"keys":[{"kty":"EC","kid":"2025-02"}]}`), nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/.well-known/jwks.json", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(fiber.MIMEApplicationJSON, resp.Header.Get("Content-Type"))
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		a.JSONEq(`{"keys":[{"kty":"EC","kid":"2025-02"}]}`, string(bytesData))
+	})
+}
+
+func TestControllerCreateClient(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return client with secret", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{ClientId: "reports", Scopes: []string{"employees:read"}}).
+			Return(SecretResponse{Response: Response{Id: 2, ClientId: "reports"}, ClientSecret: "secret"}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/oauth-clients",
+			strings.NewReader(`{"client_id":"reports","scopes":["employees:read"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[SecretResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal("secret", responseBody.Data.ClientSecret)
+	})
+}
+
+// GrantAuthorizer пропускает запрос, только если разрешение есть в списке разрешений автора запроса
+type GrantAuthorizer []string
+
+func (g GrantAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, granted := range g {
+			if granted == permission {
+				return c.Next()
+			}
+		}
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should forbid managing clients without manage permission", func(t *testing.T) {
+		server := web.NewServer()
+		server.Authorizer = GrantAuthorizer{"clients:read"}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/oauth-clients",
+			strings.NewReader(`{"client_id":"reports","scopes":["employees:read"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+
+		resp, err = server.App.Test(httptest.NewRequest(fiber.MethodDelete, "/api/v1/oauth-clients/2", nil))
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+		a.Empty(svc.Calls)
+	})
+
+	t.Run("should allow reading clients with read permission", func(t *testing.T) {
+		server := web.NewServer()
+		server.Authorizer = GrantAuthorizer{"clients:read"}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll").Return([]Response{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/oauth-clients", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should refuse to register routes before authorizer is set", func(t *testing.T) {
+		controller := NewController(web.NewServer(), new(MockService), &common.Logger{Logger: zap.NewNop()})
+		a.Panics(controller.RegisterRoutes)
+	})
+}
@@ -0,0 +1,65 @@
+package oauth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Entity зарегистрированный клиент OAuth2
+type Entity struct {
+	Id         int64          `db:"id"`
+	ClientId   string         `db:"client_id"`
+	SecretHash []byte         `db:"secret_hash"`
+	Salt       []byte         `db:"salt"`
+	Scopes     pq.StringArray `db:"scopes"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:        e.Id,
+		ClientId:  e.ClientId,
+		Scopes:    append([]string{}, e.Scopes...),
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// auditSnapshot состояние клиента в журнале аудита; хеш и соль в журнал не попадают
+type auditSnapshot struct {
+	Id       int64    `json:"id"`
+	ClientId string   `json:"client_id"`
+	Scopes   []string `json:"scopes"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, ClientId: e.ClientId, Scopes: e.Scopes}
+}
+
+type Response struct {
+	Id        int64     `json:"id"`
+	ClientId  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SecretResponse клиент вместе с секретом; секрет возвращается только при регистрации
+type SecretResponse struct {
+	Response
+	ClientSecret string `json:"client_secret"`
+}
+
+// TokenResponse успешный ответ точки выдачи токенов (RFC 6749, раздел 5.1)
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// tokenClaims claims токена доступа IDM; scope - разрешения IDM через пробел
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope    string `json:"scope"`
+	ClientId string `json:"client_id"`
+}
@@ -0,0 +1,23 @@
+package oauth
+
+// Коды ошибок точки выдачи токенов (RFC 6749, раздел 5.2)
+const (
+	errorInvalidRequest       = "invalid_request"
+	errorInvalidClient        = "invalid_client"
+	errorInvalidScope         = "invalid_scope"
+	errorUnsupportedGrantType = "unsupported_grant_type"
+	errorServerError          = "server_error"
+)
+
+// TokenError отказ в выдаче токена; отдаётся клиенту в формате RFC 6749
+type TokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (err TokenError) Error() string {
+	if err.Description == "" {
+		return err.Code
+	}
+	return err.Code + ": " + err.Description
+}
@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) FindByClientIdTx(tx *sqlx.Tx, clientId string) (exists bool, err error) {
+	query := "select exists(select 1 from oauth_client where client_id = $1)"
+	err = tx.Get(&exists, query, clientId)
+	return exists, err
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := "insert into oauth_client (client_id, secret_hash, salt, scopes) values ($1, $2, $3, $4) returning *"
+	err = tx.Get(&saved, query, e.ClientId, e.SecretHash, e.Salt, e.Scopes)
+	return saved, err
+}
+
+func (r *Repository) FindById(id int64) (client Entity, err error) {
+	query := "select * from oauth_client where id = $1"
+	err = r.db.Get(&client, query, id)
+	return client, err
+}
+
+func (r *Repository) FindByClientId(clientId string) (client Entity, err error) {
+	query := "select * from oauth_client where client_id = $1"
+	err = r.db.Get(&client, query, clientId)
+	return client, err
+}
+
+func (r *Repository) FindAll() (clients []Entity, err error) {
+	query := "select * from oauth_client order by client_id"
+	err = r.db.Select(&clients, query)
+	return clients, err
+}
+
+// DeleteTx удалить клиентов и вернуть удалённые строки
+func (r *Repository) DeleteTx(tx *sqlx.Tx, ids []int64) (deleted []Entity, err error) {
+	query := "delete from oauth_client where id = ANY($1) returning *"
+	err = tx.Select(&deleted, query, pq.Array(ids))
+	return deleted, err
+}
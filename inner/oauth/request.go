@@ -0,0 +1,22 @@
+package oauth
+
+// grantClientCredentials единственный поддерживаемый тип выдачи токенов
+const grantClientCredentials = "client_credentials"
+
+type CreateRequest struct {
+	ClientId string   `json:"client_id" validate:"required,min=2,max=100,printascii,excludesall=: "`
+	Scopes   []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// TokenRequest запрос токена (RFC 6749, раздел 4.4); учётные данные клиента приходят
+// в заголовке Authorization: Basic или в теле запроса
+type TokenRequest struct {
+	GrantType    string
+	ClientId     string
+	ClientSecret string
+	Scope        string
+}
@@ -0,0 +1,264 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"slices"
+	"strings"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "oauth_client"
+
+// subjectPrefix отличает клиентов OAuth2 от логинов сотрудников в контексте запроса и журнале аудита
+const subjectPrefix = "client:"
+
+// Длины случайных значений: секрет клиента и идентификатор токена (claim jti)
+const (
+	secretBytes  = 32
+	tokenIdBytes = 16
+)
+
+// unknownClient секрет незарегистрированного клиента сверяется с этой записью, чтобы по времени ответа
+// нельзя было узнать, существует ли client_id
+var unknownClient = Entity{SecretHash: make([]byte, 32), Salt: make([]byte, 16)}
+
+type Service struct {
+	repo        Repo
+	permissions PermissionChecker
+	signer      Signer
+	settings    TokenSettings
+	auditor     Auditor
+	validator   Validator
+}
+
+// TokenSettings параметры выпускаемых токенов
+type TokenSettings struct {
+	Issuer   string
+	Audience string
+	Ttl      time.Duration
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	FindByClientIdTx(tx *sqlx.Tx, clientId string) (bool, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	FindById(id int64) (Entity, error)
+	FindByClientId(clientId string) (Entity, error)
+	FindAll() ([]Entity, error)
+	DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error)
+}
+
+// PermissionChecker клиент может получить только существующие разрешения, которые есть у автора запроса
+type PermissionChecker interface {
+	CheckGrantable(ctx context.Context, names []string) error
+}
+
+// Signer ключи подписи токенов IDM
+type Signer interface {
+	Sign(claims jwt.Claims) (string, error)
+	Jwks() ([]byte, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(
+	repo Repo,
+	permissions PermissionChecker,
+	signer Signer,
+	settings TokenSettings,
+	auditor Auditor,
+	validator Validator,
+) *Service {
+	return &Service{
+		repo:        repo,
+		permissions: permissions,
+		signer:      signer,
+		settings:    settings,
+		auditor:     auditor,
+		validator:   validator,
+	}
+}
+
+// Create зарегистрировать клиента; секрет есть только в ответе
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return SecretResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = svc.permissions.CheckGrantable(ctx, request.Scopes); err != nil {
+		return SecretResponse{}, err
+	}
+	secret, err := common.NewSecret(secretBytes)
+	if err != nil {
+		return SecretResponse{}, fmt.Errorf("error generating client secret: %w", err)
+	}
+	salt, err := common.NewSalt()
+	if err != nil {
+		return SecretResponse{}, fmt.Errorf("error generating client secret: %w", err)
+	}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating oauth client", func(tx *sqlx.Tx) error {
+		exists, err := svc.repo.FindByClientIdTx(tx, request.ClientId)
+		if err != nil {
+			return fmt.Errorf("error finding oauth client %s: %w", request.ClientId, err)
+		}
+		if exists {
+			return common.AlreadyExistsError{
+				Message: fmt.Sprintf("oauth client %s already exists", request.ClientId),
+			}
+		}
+		saved, err = svc.repo.SaveTx(tx, Entity{
+			ClientId:   request.ClientId,
+			SecretHash: common.HashSecret(salt, secret),
+			Salt:       salt,
+			Scopes:     request.Scopes,
+		})
+		if err != nil {
+			return fmt.Errorf("error saving oauth client %s: %w", request.ClientId, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return SecretResponse{}, err
+	}
+	return SecretResponse{Response: saved.toResponse(), ClientSecret: secret}, nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding oauth client with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all oauth clients: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// DeleteById удалить клиента; уже выданные ему токены действуют до истечения срока
+func (svc *Service) DeleteById(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting oauth client", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, []int64{request.Id})
+		if err != nil {
+			return fmt.Errorf("error deleting oauth client with id %d: %w", request.Id, err)
+		}
+		if len(deleted) == 0 {
+			return common.NotFoundError{Message: fmt.Sprintf("oauth client with id %d not found", request.Id)}
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted[0].Id,
+			Before:     deleted[0].auditSnapshot(),
+		})
+	})
+}
+
+// Token выдать токен доступа по client_credentials. Без scope токен получает все разрешения клиента,
+// иначе - только запрошенные, и каждое из них должно быть разрешено клиенту.
+func (svc *Service) Token(request TokenRequest) (TokenResponse, error) {
+	if request.GrantType != grantClientCredentials {
+		return TokenResponse{}, TokenError{
+			Code:        errorUnsupportedGrantType,
+			Description: "only client_credentials grant is supported",
+		}
+	}
+	if request.ClientId == "" || request.ClientSecret == "" {
+		return TokenResponse{}, TokenError{Code: errorInvalidClient, Description: "client credentials are required"}
+	}
+	client, err := svc.repo.FindByClientId(request.ClientId)
+	known := err == nil
+	if errors.Is(err, sql.ErrNoRows) {
+		client = unknownClient
+	} else if err != nil {
+		return TokenResponse{}, fmt.Errorf("error finding oauth client %s: %w", request.ClientId, err)
+	}
+	if !common.SecretMatches(client.SecretHash, client.Salt, request.ClientSecret) || !known {
+		return TokenResponse{}, TokenError{Code: errorInvalidClient}
+	}
+	scopes := strings.Fields(request.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return TokenResponse{}, TokenError{
+				Code:        errorInvalidScope,
+				Description: fmt.Sprintf("scope %s is not allowed for client", scope),
+			}
+		}
+	}
+	tokenId, err := common.NewSecret(tokenIdBytes)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("error generating token id: %w", err)
+	}
+	now := time.Now()
+	scope := strings.Join(scopes, " ")
+	token, err := svc.signer.Sign(tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    svc.settings.Issuer,
+			Subject:   subjectPrefix + client.ClientId,
+			Audience:  jwt.ClaimStrings{svc.settings.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(svc.settings.Ttl)),
+			ID:        tokenId,
+		},
+		Scope:    scope,
+		ClientId: client.ClientId,
+	})
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("error signing token for oauth client %s: %w", client.ClientId, err)
+	}
+	return TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(svc.settings.Ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// Jwks открытые ключи, которыми проверяются токены IDM
+func (svc *Service) Jwks() ([]byte, error) {
+	return svc.signer.Jwks()
+}
@@ -0,0 +1,286 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) FindByClientIdTx(tx *sqlx.Tx, clientId string) (bool, error) {
+	args := m.Called(tx, clientId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByClientId(clientId string) (Entity, error) {
+	args := m.Called(clientId)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error) {
+	args := m.Called(tx, ids)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+type MockPermissionChecker struct {
+	mock.Mock
+}
+
+func (m *MockPermissionChecker) CheckGrantable(ctx context.Context, names []string) error {
+	args := m.Called(names)
+	return args.Error(0)
+}
+
+// StubSigner запоминает подписанные claims и возвращает их вместо токена
+type StubSigner struct {
+	claims []jwt.Claims
+	err    error
+}
+
+func (s *StubSigner) Sign(claims jwt.Claims) (string, error) {
+	s.claims = append(s.claims, claims)
+	return "signed-token", s.err
+}
+
+func (s *StubSigner) Jwks() ([]byte, error) {
+	return []byte(`{"keys":[]}`), s.err
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+var settings = TokenSettings{Issuer: "https://idm.example.com", Audience: "idm", Ttl: 15 * time.Minute}
+
+// registeredClient клиент reports с секретом secret
+func registeredClient() Entity {
+	salt := []byte("0123456789abcdef")
+	return Entity{
+		Id:         2,
+		ClientId:   "reports",
+		Salt:       salt,
+		SecretHash: common.HashSecret(salt, "secret"),
+		Scopes:     []string{"employees:read", "roles:read"},
+	}
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should register client and return secret once", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, permissions, new(StubSigner), settings, auditor, validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByClientIdTx", noTx, "reports").Return(false, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.MatchedBy(func(e Entity) bool {
+			stored = e
+			return e.ClientId == "reports"
+		})).Return(Entity{Id: 2, ClientId: "reports", Scopes: []string{"employees:read"}}, nil)
+
+		response, err := svc.Create(context.Background(), CreateRequest{ClientId: "reports", Scopes: []string{"employees:read"}})
+		a.NoError(err)
+		a.Equal(int64(2), response.Id)
+		a.NotEmpty(response.ClientSecret)
+		a.True(common.SecretMatches(stored.SecretHash, stored.Salt, response.ClientSecret))
+		a.Len(auditor.events, 1)
+		a.Equal(auditEntityType, auditor.events[0].EntityType)
+	})
+
+	t.Run("should reject duplicate client", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read"}).Return(nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByClientIdTx", noTx, "reports").Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{ClientId: "reports", Scopes: []string{"employees:read"}})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+	})
+
+	t.Run("should reject unknown scope and colon in client id", func(t *testing.T) {
+		permissions := new(MockPermissionChecker)
+		svc := NewService(new(MockRepo), permissions, new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:purge"}).
+			Return(common.RequestValidationError{Message: "unknown permission employees:purge"})
+
+		_, err := svc.Create(context.Background(), CreateRequest{ClientId: "reports", Scopes: []string{"employees:purge"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		_, err = svc.Create(context.Background(), CreateRequest{ClientId: "re:ports", Scopes: []string{"employees:read"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should reject scope the caller does not hold", func(t *testing.T) {
+		repo := new(MockRepo)
+		permissions := new(MockPermissionChecker)
+		svc := NewService(repo, permissions, new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		permissions.On("CheckGrantable", []string{"employees:read", "clients:manage"}).
+			Return(common.ForbiddenError{Message: "cannot grant permission clients:manage: alice does not hold it"})
+
+		_, err := svc.Create(common.WithActor(context.Background(), "alice"),
+			CreateRequest{ClientId: "reports", Scopes: []string{"employees:read", "clients:manage"}})
+		a.ErrorAs(err, &common.ForbiddenError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+}
+
+func TestServiceToken(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should issue token with all client scopes", func(t *testing.T) {
+		repo := new(MockRepo)
+		signer := new(StubSigner)
+		svc := NewService(repo, new(MockPermissionChecker), signer, settings, new(StubAuditor), validator.New())
+
+		repo.On("FindByClientId", "reports").Return(registeredClient(), nil)
+
+		before := time.Now()
+		response, err := svc.Token(TokenRequest{GrantType: "client_credentials", ClientId: "reports", ClientSecret: "secret"})
+		a.NoError(err)
+		a.Equal(TokenResponse{
+			AccessToken: "signed-token",
+			TokenType:   "Bearer",
+			ExpiresIn:   900,
+			Scope:       "employees:read roles:read",
+		}, response)
+
+		a.Len(signer.claims, 1)
+		claims := signer.claims[0].(tokenClaims)
+		a.Equal("https://idm.example.com", claims.Issuer)
+		a.Equal("client:reports", claims.Subject)
+		a.Equal(jwt.ClaimStrings{"idm"}, claims.Audience)
+		a.Equal("reports", claims.ClientId)
+		a.NotEmpty(claims.ID)
+		a.WithinDuration(before.Add(15*time.Minute), claims.ExpiresAt.Time, 2*time.Second)
+	})
+
+	t.Run("should narrow token to requested scopes", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		repo.On("FindByClientId", "reports").Return(registeredClient(), nil)
+
+		response, err := svc.Token(TokenRequest{
+			GrantType: "client_credentials", ClientId: "reports", ClientSecret: "secret", Scope: "roles:read",
+		})
+		a.NoError(err)
+		a.Equal("roles:read", response.Scope)
+	})
+
+	t.Run("should reject scope not allowed for client", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		repo.On("FindByClientId", "reports").Return(registeredClient(), nil)
+
+		_, err := svc.Token(TokenRequest{
+			GrantType: "client_credentials", ClientId: "reports", ClientSecret: "secret", Scope: "employees:delete",
+		})
+		var tokenErr TokenError
+		a.ErrorAs(err, &tokenErr)
+		a.Equal(errorInvalidScope, tokenErr.Code)
+	})
+
+	t.Run("should reject unknown client and wrong secret alike", func(t *testing.T) {
+		repo := new(MockRepo)
+		signer := new(StubSigner)
+		svc := NewService(repo, new(MockPermissionChecker), signer, settings, new(StubAuditor), validator.New())
+
+		repo.On("FindByClientId", "reports").Return(registeredClient(), nil)
+		repo.On("FindByClientId", "unknown").Return(Entity{}, sql.ErrNoRows)
+
+		for _, request := range []TokenRequest{
+			{GrantType: "client_credentials", ClientId: "reports", ClientSecret: "guess"},
+			{GrantType: "client_credentials", ClientId: "unknown", ClientSecret: "secret"},
+			{GrantType: "client_credentials", ClientId: "reports"},
+		} {
+			_, err := svc.Token(request)
+			a.Equal(errorInvalidClient, err.(TokenError).Code, request.ClientId)
+		}
+		a.Empty(signer.claims)
+	})
+
+	t.Run("should reject other grant types", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockPermissionChecker), new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		_, err := svc.Token(TokenRequest{GrantType: "password", ClientId: "reports", ClientSecret: "secret"})
+		a.Equal(errorUnsupportedGrantType, err.(TokenError).Code)
+	})
+
+	t.Run("should return internal error if signing fails", func(t *testing.T) {
+		repo := new(MockRepo)
+		signer := &StubSigner{err: errors.New("no keys")}
+		svc := NewService(repo, new(MockPermissionChecker), signer, settings, new(StubAuditor), validator.New())
+
+		repo.On("FindByClientId", "reports").Return(registeredClient(), nil)
+
+		_, err := svc.Token(TokenRequest{GrantType: "client_credentials", ClientId: "reports", ClientSecret: "secret"})
+		a.Error(err)
+		a.False(errors.As(err, &TokenError{}))
+	})
+}
+
+func TestServiceDeleteById(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockPermissionChecker), new(StubSigner), settings, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{5}).Return([]Entity{}, nil)
+
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 5})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
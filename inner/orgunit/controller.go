@@ -0,0 +1,223 @@
+package orgunit
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты подразделений; руководителей и подчинённых видно с правом чтения сотрудников
+const (
+	permissionRead          = "org_units:read"
+	permissionManage        = "org_units:manage"
+	permissionEmployeesRead = "employees:read"
+)
+
+type Controller struct {
+	server         *web.Server
+	orgUnitService Svc
+	logger         *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Delete(ctx context.Context, request IdRequest) error
+	FindSubtree(request IdRequest) ([]TreeResponse, error)
+	FindMembers(request MembersRequest) ([]EmployeeResponse, error)
+	FindManagers(request IdRequest) ([]EmployeeResponse, error)
+	FindReports(request ReportsRequest) ([]EmployeeResponse, error)
+}
+
+func NewController(server *web.Server, orgUnitService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:         server,
+		orgUnitService: orgUnitService,
+		logger:         logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/org-units", c.server.Require(permissionManage), c.CreateUnit)
+	c.server.GroupApiV1.Get("/org-units", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/org-units/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Put("/org-units/:id", c.server.Require(permissionManage), c.UpdateUnit)
+	c.server.GroupApiV1.Delete("/org-units/:id", c.server.Require(permissionManage), c.DeleteUnit)
+	c.server.GroupApiV1.Get("/org-units/:id/subtree", c.server.Require(permissionRead), c.FindSubtree)
+	c.server.GroupApiV1.Get("/org-units/:id/members", c.server.Require(permissionRead), c.FindMembers)
+	c.server.GroupApiV1.Get("/employees/:id/managers", c.server.Require(permissionEmployeesRead), c.FindManagers)
+	c.server.GroupApiV1.Get("/employees/:id/reports", c.server.Require(permissionEmployeesRead), c.FindReports)
+}
+
+func (c *Controller) CreateUnit(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create org unit: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create org unit: received request", zap.Any("request", request))
+	response, err := c.orgUnitService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create org unit: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create org unit: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find org unit by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find org unit by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.orgUnitService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find org unit by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find org unit by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all org units: received request")
+	responses, err := c.orgUnitService.FindAll()
+	if err != nil {
+		c.logger.Error("find all org units: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all org units: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) UpdateUnit(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update org unit: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update org unit: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update org unit: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.orgUnitService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update org unit: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update org unit: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DeleteUnit(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete org unit: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete org unit: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.orgUnitService.Delete(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete org unit: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete org unit: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) FindSubtree(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find org unit subtree: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find org unit subtree: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.orgUnitService.FindSubtree(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find org unit subtree: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find org unit subtree: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+// FindMembers сотрудники подразделения; subtree=true добавляет сотрудников вложенных подразделений
+func (c *Controller) FindMembers(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find org unit members: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find org unit members: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := MembersRequest{Id: id, Subtree: ctx.QueryBool("subtree")}
+	responses, err := c.orgUnitService.FindMembers(request)
+	if err != nil {
+		c.logger.Error("find org unit members: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find org unit members: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) FindManagers(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee managers: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee managers: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.orgUnitService.FindManagers(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find employee managers: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee managers: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+// FindReports подчинённые сотрудника; indirect=true добавляет подчинённых подчинённых
+func (c *Controller) FindReports(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee reports: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee reports: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := ReportsRequest{Id: id, Indirect: ctx.QueryBool("indirect")}
+	responses, err := c.orgUnitService.FindReports(request)
+	if err != nil {
+		c.logger.Error("find employee reports: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee reports: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,319 @@
+package orgunit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Delete(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindSubtree(request IdRequest) ([]TreeResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]TreeResponse), args.Error(1)
+}
+
+func (svc *MockService) FindMembers(request MembersRequest) ([]EmployeeResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]EmployeeResponse), args.Error(1)
+}
+
+func (svc *MockService) FindManagers(request IdRequest) ([]EmployeeResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]EmployeeResponse), args.Error(1)
+}
+
+func (svc *MockService) FindReports(request ReportsRequest) ([]EmployeeResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]EmployeeResponse), args.Error(1)
+}
+
+func TestControllerCreateUnit(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should create org unit", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		parentId := int64(1)
+		svc.On("Create", CreateRequest{Name: "Sales", ParentId: &parentId}).
+			Return(Response{Id: 2, Name: "Sales", ParentId: &parentId}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/org-units",
+			strings.NewReader(`{"name":"Sales","parent_id":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.True(responseBody.Success)
+		a.Equal(int64(2), responseBody.Data.Id)
+	})
+
+	t.Run("should return bad request when name is taken", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Name: "Sales"}).
+			Return(Response{}, common.AlreadyExistsError{Message: "org unit named Sales already exists"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/org-units", strings.NewReader(`{"name":"Sales"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerUpdateUnit(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return bad request when move would create a cycle", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		parentId := int64(4)
+		svc.On("Update", UpdateRequest{Id: 2, Name: "Sales", ParentId: &parentId}).
+			Return(Response{}, common.RequestValidationError{Message: "cycle"})
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/org-units/2",
+			strings.NewReader(`{"name":"Sales","parent_id":4}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerDeleteUnit(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Delete", IdRequest{Id: 2}).Return(common.NotFoundError{Message: "org unit with id 2 not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodDelete, "/api/v1/org-units/2", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerFindSubtree(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return subtree", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindSubtree", IdRequest{Id: 1}).Return([]TreeResponse{
+			{Response: Response{Id: 1, Name: "Company"}, Depth: 0},
+		}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/org-units/1/subtree", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "FindSubtree", 1))
+	})
+
+	t.Run("should return bad request on invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/org-units/abc/subtree", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.Empty(svc.Calls)
+	})
+}
+
+func TestControllerFindMembers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass subtree flag", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindMembers", MembersRequest{Id: 1, Subtree: true}).Return([]EmployeeResponse{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/org-units/1/members?subtree=true", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "FindMembers", 1))
+	})
+}
+
+func TestControllerFindManagers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return management chain", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindManagers", IdRequest{Id: 1}).Return([]EmployeeResponse{
+			{Id: 2, Name: "Bob", Status: "active", Level: 1},
+		}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/managers", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]EmployeeResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal(1, responseBody.Data[0].Level)
+	})
+}
+
+func TestControllerFindReports(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return only direct reports by default", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindReports", ReportsRequest{Id: 1}).Return([]EmployeeResponse{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/reports", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertCalled(t, "FindReports", ReportsRequest{Id: 1}))
+	})
+
+	t.Run("should pass indirect flag", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindReports", ReportsRequest{Id: 1, Indirect: true}).Return([]EmployeeResponse{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/1/reports?indirect=true", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.True(svc.AssertNumberOfCalls(t, "FindReports", 1))
+	})
+}
+
+// DenyAuthorizer отклоняет любой запрос и сообщает, какое разрешение потребовал маршрут
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	routes := []struct {
+		method     string
+		url        string
+		permission string
+	}{
+		{fiber.MethodPost, "/api/v1/org-units", "org_units:manage"},
+		{fiber.MethodGet, "/api/v1/org-units", "org_units:read"},
+		{fiber.MethodGet, "/api/v1/org-units/1", "org_units:read"},
+		{fiber.MethodPut, "/api/v1/org-units/1", "org_units:manage"},
+		{fiber.MethodDelete, "/api/v1/org-units/1", "org_units:manage"},
+		{fiber.MethodGet, "/api/v1/org-units/1/subtree", "org_units:read"},
+		{fiber.MethodGet, "/api/v1/org-units/1/members", "org_units:read"},
+		{fiber.MethodGet, "/api/v1/employees/1/managers", "employees:read"},
+		{fiber.MethodGet, "/api/v1/employees/1/reports", "employees:read"},
+	}
+
+	t.Run("should require permission on every route", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = DenyAuthorizer{}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, route := range routes {
+			resp, err := server.App.Test(httptest.NewRequest(route.method, route.url, nil))
+			a.Nil(err)
+			a.Equal(http.StatusForbidden, resp.StatusCode, route.url)
+
+			bytesData, err := io.ReadAll(resp.Body)
+			a.Nil(err)
+			var responseBody common.Response[any]
+			a.Nil(json.Unmarshal(bytesData, &responseBody))
+			a.Equal("missing permission "+route.permission, responseBody.Message, route.method+" "+route.url)
+		}
+		a.Empty(svc.Calls)
+	})
+}
@@ -0,0 +1,90 @@
+package orgunit
+
+import "time"
+
+// Entity подразделение; у корневого подразделения ParentId пуст
+type Entity struct {
+	Id        int64     `db:"id"`
+	Name      string    `db:"name"`
+	ParentId  *int64    `db:"parent_id"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:        e.Id,
+		Name:      e.Name,
+		ParentId:  e.ParentId,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние подразделения в журнале аудита
+type auditSnapshot struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	ParentId *int64 `json:"parent_id"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Name: e.Name, ParentId: e.ParentId}
+}
+
+type Response struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	ParentId  *int64    `json:"parent_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TreeEntity подразделение поддерева и его глубина: у корня поддерева 0, у дочерних 1 и так далее
+type TreeEntity struct {
+	Entity
+	Depth int `db:"depth"`
+}
+
+func (e *TreeEntity) toResponse() TreeResponse {
+	return TreeResponse{Response: e.Entity.toResponse(), Depth: e.Depth}
+}
+
+type TreeResponse struct {
+	Response
+	Depth int `json:"depth"`
+}
+
+// EmployeeEntity сотрудник в оргструктуре. Level - удалённость от того, для кого строилась выборка:
+// для участников - глубина их подразделения в поддереве, для руководителей и подчинённых - число ступеней
+type EmployeeEntity struct {
+	Id        int64   `db:"id"`
+	Name      string  `db:"name"`
+	Login     *string `db:"login"`
+	Status    string  `db:"status"`
+	OrgUnitId *int64  `db:"org_unit_id"`
+	ManagerId *int64  `db:"manager_id"`
+	Level     int     `db:"level"`
+}
+
+func (e *EmployeeEntity) toResponse() EmployeeResponse {
+	return EmployeeResponse{
+		Id:        e.Id,
+		Name:      e.Name,
+		Login:     e.Login,
+		Status:    e.Status,
+		OrgUnitId: e.OrgUnitId,
+		ManagerId: e.ManagerId,
+		Level:     e.Level,
+	}
+}
+
+type EmployeeResponse struct {
+	Id        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Login     *string `json:"login,omitempty"`
+	Status    string  `json:"status"`
+	OrgUnitId *int64  `json:"org_unit_id"`
+	ManagerId *int64  `json:"manager_id"`
+	Level     int     `json:"level"`
+}
@@ -0,0 +1,153 @@
+package orgunit
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+// subtree подразделение $1 и все вложенные в него с глубиной относительно него
+const subtree = `subtree(id, depth) as (
+		select id, 0 from org_unit where id = $1
+		union all
+		select u.id, s.depth + 1 from org_unit u join subtree s on u.parent_id = s.id
+	)`
+
+const selectEmployees = "select e.id, e.name, e.login, e.status, e.org_unit_id, e.manager_id"
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := "insert into org_unit (name, parent_id) values ($1, $2) returning *"
+	err = tx.Get(&saved, query, e.Name, e.ParentId)
+	return saved, err
+}
+
+// ExistsTx есть ли у того же родителя, кроме подразделения exceptId, подразделение с тем же именем
+func (r *Repository) ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (exists bool, err error) {
+	query := `select exists(select 1 from org_unit
+		where id <> $3 and name = $1 and parent_id is not distinct from $2)`
+	err = tx.Get(&exists, query, e.Name, e.ParentId, exceptId)
+	return exists, err
+}
+
+func (r *Repository) FindById(id int64) (unit Entity, err error) {
+	err = r.db.Get(&unit, "select * from org_unit where id = $1", id)
+	return unit, err
+}
+
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (unit Entity, err error) {
+	err = tx.Get(&unit, "select * from org_unit where id = $1 for update", id)
+	return unit, err
+}
+
+func (r *Repository) FindAll() (units []Entity, err error) {
+	err = r.db.Select(&units, "select * from org_unit order by id")
+	return units, err
+}
+
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := "update org_unit set name = $2, parent_id = $3 where id = $1 returning *"
+	err = tx.Get(&updated, query, e.Id, e.Name, e.ParentId)
+	return updated, err
+}
+
+// DeleteTx удалить подразделение; сотрудники, удалённые мягко, остаются без подразделения
+func (r *Repository) DeleteTx(tx *sqlx.Tx, id int64) (deleted Entity, err error) {
+	err = tx.Get(&deleted, "delete from org_unit where id = $1 returning *", id)
+	return deleted, err
+}
+
+// LockTreeTx заблокировать дерево подразделений до конца транзакции,
+// чтобы параллельные переносы не смогли создать цикл в обход проверки
+func (r *Repository) LockTreeTx(tx *sqlx.Tx) (err error) {
+	_, err = tx.Exec("lock table org_unit in share row exclusive mode")
+	return err
+}
+
+// IsInSubtreeTx входит ли подразделение candidateId в поддерево подразделения rootId, включая его само
+func (r *Repository) IsInSubtreeTx(tx *sqlx.Tx, rootId, candidateId int64) (found bool, err error) {
+	query := `with recursive ` + subtree + ` select exists(select 1 from subtree where id = $2)`
+	err = tx.Get(&found, query, rootId, candidateId)
+	return found, err
+}
+
+func (r *Repository) HasChildrenTx(tx *sqlx.Tx, id int64) (exists bool, err error) {
+	err = tx.Get(&exists, "select exists(select 1 from org_unit where parent_id = $1)", id)
+	return exists, err
+}
+
+// HasMembersTx есть ли в подразделении сотрудники, кроме мягко удалённых
+func (r *Repository) HasMembersTx(tx *sqlx.Tx, id int64) (exists bool, err error) {
+	query := "select exists(select 1 from employee where org_unit_id = $1 and deleted_at is null)"
+	err = tx.Get(&exists, query, id)
+	return exists, err
+}
+
+// FindSubtree подразделение id и все вложенные в него, по глубине и затем по имени
+func (r *Repository) FindSubtree(id int64) (units []TreeEntity, err error) {
+	query := `with recursive ` + subtree + `
+		select u.*, s.depth from org_unit u join subtree s on s.id = u.id order by s.depth, u.name, u.id`
+	err = r.db.Select(&units, query, id)
+	return units, err
+}
+
+// FindMembers сотрудники подразделения id; с subtree - и вложенных подразделений. Удалённые не возвращаются
+func (r *Repository) FindMembers(id int64, withSubtree bool) (members []EmployeeEntity, err error) {
+	query := `with recursive ` + subtree + `
+		` + selectEmployees + `, s.depth as level from employee e
+		join subtree s on s.id = e.org_unit_id
+		where e.deleted_at is null and ($2 or s.depth = 0)
+		order by s.depth, e.name, e.id`
+	err = r.db.Select(&members, query, id, withSubtree)
+	return members, err
+}
+
+// FindEmployee сотрудник, если он существует и не удалён
+func (r *Repository) FindEmployee(id int64) (employee EmployeeEntity, err error) {
+	query := selectEmployees + ", 0 as level from employee e where e.id = $1 and e.deleted_at is null"
+	err = r.db.Get(&employee, query, id)
+	return employee, err
+}
+
+// FindManagers цепочка руководителей сотрудника id от непосредственного вверх. Удалённый руководитель
+// обрывает цепочку; path защищает от цикла, если он всё же оказался в данных
+func (r *Repository) FindManagers(id int64) (managers []EmployeeEntity, err error) {
+	query := `with recursive chain(id, level, path) as (
+			select m.id, 1, array[e.id, m.id] from employee e
+				join employee m on m.id = e.manager_id and m.deleted_at is null
+			where e.id = $1
+			union all
+			select m.id, c.level + 1, c.path || m.id from chain c
+				join employee e on e.id = c.id
+				join employee m on m.id = e.manager_id and m.deleted_at is null
+			where not m.id = any(c.path)
+		)
+		` + selectEmployees + `, c.level from employee e join chain c on c.id = e.id order by c.level`
+	err = r.db.Select(&managers, query, id)
+	return managers, err
+}
+
+// FindReports подчинённые сотрудника id: прямые, а с indirect - и подчинённые подчинённых.
+// Через удалённых сотрудников подчинение не передаётся
+func (r *Repository) FindReports(id int64, indirect bool) (reports []EmployeeEntity, err error) {
+	query := `with recursive report(id, level, path) as (
+			select e.id, 1, array[$1::bigint, e.id] from employee e
+			where e.manager_id = $1 and e.deleted_at is null
+			union all
+			select e.id, r.level + 1, r.path || e.id from report r
+				join employee e on e.manager_id = r.id and e.deleted_at is null
+			where $2 and not e.id = any(r.path)
+		)
+		` + selectEmployees + `, r.level from employee e join report r on r.id = e.id order by r.level, e.name, e.id`
+	err = r.db.Select(&reports, query, id, indirect)
+	return reports, err
+}
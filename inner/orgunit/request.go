@@ -0,0 +1,30 @@
+package orgunit
+
+// CreateRequest завести подразделение; без ParentId оно станет корневым
+type CreateRequest struct {
+	Name     string `json:"name" validate:"required,min=2,max=155"`
+	ParentId *int64 `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+// UpdateRequest переименовать подразделение или перенести его вместе с поддеревом к другому родителю
+type UpdateRequest struct {
+	Id       int64  `json:"id" validate:"required,gt=0"`
+	Name     string `json:"name" validate:"required,min=2,max=155"`
+	ParentId *int64 `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// MembersRequest сотрудники подразделения; с Subtree - и всех вложенных в него подразделений
+type MembersRequest struct {
+	Id      int64 `json:"id" validate:"required,gt=0"`
+	Subtree bool  `json:"subtree"`
+}
+
+// ReportsRequest подчинённые сотрудника; по умолчанию только прямые, с Indirect - все по цепочке
+type ReportsRequest struct {
+	Id       int64 `json:"id" validate:"required,gt=0"`
+	Indirect bool  `json:"indirect"`
+}
@@ -0,0 +1,331 @@
+package orgunit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "org_unit"
+
+type Service struct {
+	repo      Repo
+	auditor   Auditor
+	validator Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (bool, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, id int64) (Entity, error)
+	LockTreeTx(tx *sqlx.Tx) error
+	IsInSubtreeTx(tx *sqlx.Tx, rootId, candidateId int64) (bool, error)
+	HasChildrenTx(tx *sqlx.Tx, id int64) (bool, error)
+	HasMembersTx(tx *sqlx.Tx, id int64) (bool, error)
+	FindSubtree(id int64) ([]TreeEntity, error)
+	FindMembers(id int64, withSubtree bool) ([]EmployeeEntity, error)
+	FindEmployee(id int64) (EmployeeEntity, error)
+	FindManagers(id int64) ([]EmployeeEntity, error)
+	FindReports(id int64, indirect bool) ([]EmployeeEntity, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, auditor Auditor, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		auditor:   auditor,
+		validator: validator,
+	}
+}
+
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity := Entity{Name: request.Name, ParentId: request.ParentId}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating org unit", func(tx *sqlx.Tx) error {
+		if err := svc.checkParent(tx, entity); err != nil {
+			return err
+		}
+		if err := svc.checkUnique(tx, entity, 0); err != nil {
+			return err
+		}
+		saved, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving org unit %s: %w", request.Name, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.findUnit(request.Id)
+	if err != nil {
+		return Response{}, err
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all org units: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// Update переименовать подразделение и/или перенести его к другому родителю.
+// Перенос внутрь собственного поддерева замкнул бы цикл и отклоняется.
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity := Entity{Id: request.Id, Name: request.Name, ParentId: request.ParentId}
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating org unit", func(tx *sqlx.Tx) error {
+		if err := svc.repo.LockTreeTx(tx); err != nil {
+			return fmt.Errorf("error locking org unit tree: %w", err)
+		}
+		before, err := svc.repo.FindByIdForUpdateTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("org unit with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding org unit with id %d: %w", request.Id, err)
+		}
+		if err = svc.checkParent(tx, entity); err != nil {
+			return err
+		}
+		if err = svc.checkUnique(tx, entity, request.Id); err != nil {
+			return err
+		}
+		updated, err = svc.repo.UpdateTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error updating org unit with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// Delete удалить пустое подразделение: без дочерних подразделений и работающих в нём сотрудников
+func (svc *Service) Delete(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting org unit", func(tx *sqlx.Tx) error {
+		if _, err := svc.repo.FindByIdForUpdateTx(tx, request.Id); errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("org unit with id %d not found", request.Id)}
+		} else if err != nil {
+			return fmt.Errorf("error finding org unit with id %d: %w", request.Id, err)
+		}
+		hasChildren, err := svc.repo.HasChildrenTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error finding child units of org unit with id %d: %w", request.Id, err)
+		}
+		if hasChildren {
+			return common.RequestValidationError{Message: fmt.Sprintf("org unit with id %d has child units", request.Id)}
+		}
+		hasMembers, err := svc.repo.HasMembersTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error finding members of org unit with id %d: %w", request.Id, err)
+		}
+		if hasMembers {
+			return common.RequestValidationError{Message: fmt.Sprintf("org unit with id %d has employees", request.Id)}
+		}
+		deleted, err := svc.repo.DeleteTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error deleting org unit with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted.Id,
+			Before:     deleted.auditSnapshot(),
+		})
+	})
+}
+
+// FindSubtree подразделение и все вложенные в него
+func (svc *Service) FindSubtree(request IdRequest) ([]TreeResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.findUnit(request.Id); err != nil {
+		return nil, err
+	}
+	entities, err := svc.repo.FindSubtree(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving subtree of org unit with id %d: %w", request.Id, err)
+	}
+	responses := make([]TreeResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// FindMembers сотрудники подразделения, а по запросу - и вложенных в него подразделений
+func (svc *Service) FindMembers(request MembersRequest) ([]EmployeeResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.findUnit(request.Id); err != nil {
+		return nil, err
+	}
+	entities, err := svc.repo.FindMembers(request.Id, request.Subtree)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving members of org unit with id %d: %w", request.Id, err)
+	}
+	return toEmployeeResponses(entities), nil
+}
+
+// FindManagers цепочка руководителей сотрудника от непосредственного до верхнего
+func (svc *Service) FindManagers(request IdRequest) ([]EmployeeResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = svc.checkEmployee(request.Id); err != nil {
+		return nil, err
+	}
+	entities, err := svc.repo.FindManagers(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving managers of employee with id %d: %w", request.Id, err)
+	}
+	return toEmployeeResponses(entities), nil
+}
+
+// FindReports прямые или, по запросу, все подчинённые сотрудника
+func (svc *Service) FindReports(request ReportsRequest) ([]EmployeeResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = svc.checkEmployee(request.Id); err != nil {
+		return nil, err
+	}
+	entities, err := svc.repo.FindReports(request.Id, request.Indirect)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving reports of employee with id %d: %w", request.Id, err)
+	}
+	return toEmployeeResponses(entities), nil
+}
+
+// checkParent родитель должен существовать и не лежать в поддереве самого подразделения
+func (svc *Service) checkParent(tx *sqlx.Tx, entity Entity) error {
+	if entity.ParentId == nil {
+		return nil
+	}
+	parentId := *entity.ParentId
+	if _, err := svc.repo.FindByIdForUpdateTx(tx, parentId); errors.Is(err, sql.ErrNoRows) {
+		return common.NotFoundError{Message: fmt.Sprintf("org unit with id %d not found", parentId)}
+	} else if err != nil {
+		return fmt.Errorf("error finding org unit with id %d: %w", parentId, err)
+	}
+	if entity.Id == 0 {
+		return nil
+	}
+	cycle, err := svc.repo.IsInSubtreeTx(tx, entity.Id, parentId)
+	if err != nil {
+		return fmt.Errorf("error checking org unit tree: %w", err)
+	}
+	if cycle {
+		return common.RequestValidationError{
+			Message: fmt.Sprintf("org unit %d is inside org unit %d, moving it there would create a cycle",
+				parentId, entity.Id),
+		}
+	}
+	return nil
+}
+
+func (svc *Service) checkUnique(tx *sqlx.Tx, entity Entity, exceptId int64) error {
+	exists, err := svc.repo.ExistsTx(tx, entity, exceptId)
+	if err != nil {
+		return fmt.Errorf("error finding org unit %s: %w", entity.Name, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{
+			Message: fmt.Sprintf("org unit named %s already exists under the same parent", entity.Name),
+		}
+	}
+	return nil
+}
+
+func (svc *Service) findUnit(id int64) (Entity, error) {
+	entity, err := svc.repo.FindById(id)
+	if err != nil {
+		return Entity{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding org unit with id %d: %v", id, err),
+		}
+	}
+	return entity, nil
+}
+
+func (svc *Service) checkEmployee(id int64) error {
+	if _, err := svc.repo.FindEmployee(id); err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee with id %d: %v", id, err),
+		}
+	}
+	return nil
+}
+
+func toEmployeeResponses(entities []EmployeeEntity) []EmployeeResponse {
+	responses := make([]EmployeeResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses
+}
@@ -0,0 +1,409 @@
+package orgunit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"testing"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (bool, error) {
+	args := m.Called(tx, e, exceptId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) LockTreeTx(tx *sqlx.Tx) error {
+	args := m.Called(tx)
+	return args.Error(0)
+}
+
+func (m *MockRepo) IsInSubtreeTx(tx *sqlx.Tx, rootId, candidateId int64) (bool, error) {
+	args := m.Called(tx, rootId, candidateId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) HasChildrenTx(tx *sqlx.Tx, id int64) (bool, error) {
+	args := m.Called(tx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) HasMembersTx(tx *sqlx.Tx, id int64) (bool, error) {
+	args := m.Called(tx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindSubtree(id int64) ([]TreeEntity, error) {
+	args := m.Called(id)
+	return args.Get(0).([]TreeEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindMembers(id int64, withSubtree bool) ([]EmployeeEntity, error) {
+	args := m.Called(id, withSubtree)
+	return args.Get(0).([]EmployeeEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindEmployee(id int64) (EmployeeEntity, error) {
+	args := m.Called(id)
+	return args.Get(0).(EmployeeEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindManagers(id int64) ([]EmployeeEntity, error) {
+	args := m.Called(id)
+	return args.Get(0).([]EmployeeEntity), args.Error(1)
+}
+
+func (m *MockRepo) FindReports(id int64, indirect bool) ([]EmployeeEntity, error) {
+	args := m.Called(id, indirect)
+	return args.Get(0).([]EmployeeEntity), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should create child org unit and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		parentId := int64(1)
+		entity := Entity{Name: "Sales", ParentId: &parentId}
+		saved := Entity{Id: 2, Name: "Sales", ParentId: &parentId}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, parentId).Return(Entity{Id: parentId, Name: "Company"}, nil)
+		repo.On("ExistsTx", noTx, entity, int64(0)).Return(false, nil)
+		repo.On("SaveTx", noTx, entity).Return(saved, nil)
+
+		got, err := svc.Create(context.Background(), CreateRequest{Name: "Sales", ParentId: &parentId})
+		a.NoError(err)
+		a.Equal(saved.toResponse(), got)
+		a.True(repo.AssertNotCalled(t, "IsInSubtreeTx"))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "org_unit",
+			EntityId:   2,
+			After:      auditSnapshot{Id: 2, Name: "Sales", ParentId: &parentId},
+		}}, auditor.events)
+	})
+
+	t.Run("should return not found error when parent does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		parentId := int64(1)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, parentId).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Sales", ParentId: &parentId})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+
+	t.Run("should return already exists error for sibling with the same name", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		entity := Entity{Name: "Sales"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, entity, int64(0)).Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Sales"})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "S"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should move org unit to another parent", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		oldParentId, newParentId := int64(1), int64(3)
+		before := Entity{Id: 2, Name: "Sales", ParentId: &oldParentId}
+		entity := Entity{Id: 2, Name: "Sales", ParentId: &newParentId}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockTreeTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(before, nil)
+		repo.On("FindByIdForUpdateTx", noTx, newParentId).Return(Entity{Id: newParentId, Name: "Europe"}, nil)
+		repo.On("IsInSubtreeTx", noTx, int64(2), newParentId).Return(false, nil)
+		repo.On("ExistsTx", noTx, entity, int64(2)).Return(false, nil)
+		repo.On("UpdateTx", noTx, entity).Return(entity, nil)
+
+		got, err := svc.Update(context.Background(), UpdateRequest{Id: 2, Name: "Sales", ParentId: &newParentId})
+		a.NoError(err)
+		a.Equal(&newParentId, got.ParentId)
+		a.Len(auditor.events, 1)
+		a.Equal(before.auditSnapshot(), auditor.events[0].Before)
+		a.Equal(entity.auditSnapshot(), auditor.events[0].After)
+	})
+
+	t.Run("should reject moving org unit inside its own subtree", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		childId := int64(4)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockTreeTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(Entity{Id: 2, Name: "Sales"}, nil)
+		repo.On("FindByIdForUpdateTx", noTx, childId).Return(Entity{Id: childId, Name: "Retail"}, nil)
+		repo.On("IsInSubtreeTx", noTx, int64(2), childId).Return(true, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 2, Name: "Sales", ParentId: &childId})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should return not found error when org unit does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockTreeTx", noTx).Return(nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 2, Name: "Sales"})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should delete empty org unit", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		unit := Entity{Id: 2, Name: "Sales"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(unit, nil)
+		repo.On("HasChildrenTx", noTx, int64(2)).Return(false, nil)
+		repo.On("HasMembersTx", noTx, int64(2)).Return(false, nil)
+		repo.On("DeleteTx", noTx, int64(2)).Return(unit, nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 2})
+		a.NoError(err)
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionDelete, auditor.events[0].Action)
+	})
+
+	t.Run("should reject deleting org unit with child units", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(Entity{Id: 2, Name: "Sales"}, nil)
+		repo.On("HasChildrenTx", noTx, int64(2)).Return(true, nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 2})
+		a.Equal(common.RequestValidationError{Message: "org unit with id 2 has child units"}, err)
+		a.True(repo.AssertNotCalled(t, "DeleteTx"))
+	})
+
+	t.Run("should reject deleting org unit with employees", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(2)).Return(Entity{Id: 2, Name: "Sales"}, nil)
+		repo.On("HasChildrenTx", noTx, int64(2)).Return(false, nil)
+		repo.On("HasMembersTx", noTx, int64(2)).Return(true, nil)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 2})
+		a.Equal(common.RequestValidationError{Message: "org unit with id 2 has employees"}, err)
+		a.True(repo.AssertNotCalled(t, "DeleteTx"))
+	})
+}
+
+func TestServiceFindSubtree(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return subtree with depths", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		rootId := int64(1)
+		entities := []TreeEntity{
+			{Entity: Entity{Id: 1, Name: "Company"}, Depth: 0},
+			{Entity: Entity{Id: 2, Name: "Sales", ParentId: &rootId}, Depth: 1},
+		}
+		repo.On("FindById", rootId).Return(entities[0].Entity, nil)
+		repo.On("FindSubtree", rootId).Return(entities, nil)
+
+		got, err := svc.FindSubtree(IdRequest{Id: rootId})
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal(1, got[1].Depth)
+		a.Equal(&rootId, got[1].ParentId)
+	})
+
+	t.Run("should return not found error when org unit does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.FindSubtree(IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "FindSubtree"))
+	})
+}
+
+func TestServiceFindMembers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return members of the whole subtree", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		unitId, childId := int64(1), int64(2)
+		members := []EmployeeEntity{
+			{Id: 10, Name: "Alice", Status: "active", OrgUnitId: &unitId, Level: 0},
+			{Id: 11, Name: "Bob", Status: "active", OrgUnitId: &childId, Level: 1},
+		}
+		repo.On("FindById", unitId).Return(Entity{Id: unitId, Name: "Company"}, nil)
+		repo.On("FindMembers", unitId, true).Return(members, nil)
+
+		got, err := svc.FindMembers(MembersRequest{Id: unitId, Subtree: true})
+		a.NoError(err)
+		a.Equal([]EmployeeResponse{members[0].toResponse(), members[1].toResponse()}, got)
+	})
+}
+
+func TestServiceFindManagers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return management chain", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		topId := int64(3)
+		chain := []EmployeeEntity{
+			{Id: 2, Name: "Bob", Status: "active", ManagerId: &topId, Level: 1},
+			{Id: 3, Name: "Carol", Status: "active", Level: 2},
+		}
+		repo.On("FindEmployee", int64(1)).Return(EmployeeEntity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindManagers", int64(1)).Return(chain, nil)
+
+		got, err := svc.FindManagers(IdRequest{Id: 1})
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal(int64(3), got[1].Id)
+		a.Equal(2, got[1].Level)
+	})
+
+	t.Run("should return not found error when employee does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindEmployee", int64(1)).Return(EmployeeEntity{}, sql.ErrNoRows)
+
+		_, err := svc.FindManagers(IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "FindManagers"))
+	})
+}
+
+func TestServiceFindReports(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return indirect reports", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		managerId, leadId := int64(1), int64(2)
+		reports := []EmployeeEntity{
+			{Id: 2, Name: "Bob", Status: "active", ManagerId: &managerId, Level: 1},
+			{Id: 3, Name: "Carol", Status: "active", ManagerId: &leadId, Level: 2},
+		}
+		repo.On("FindEmployee", managerId).Return(EmployeeEntity{Id: managerId, Name: "Alice"}, nil)
+		repo.On("FindReports", managerId, true).Return(reports, nil)
+
+		got, err := svc.FindReports(ReportsRequest{Id: managerId, Indirect: true})
+		a.NoError(err)
+		a.Len(got, 2)
+		a.True(repo.AssertCalled(t, "FindReports", managerId, true))
+	})
+
+	t.Run("should return empty list when employee has no reports", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindEmployee", int64(1)).Return(EmployeeEntity{Id: 1, Name: "Alice"}, nil)
+		repo.On("FindReports", int64(1), false).Return([]EmployeeEntity(nil), nil)
+
+		got, err := svc.FindReports(ReportsRequest{Id: 1})
+		a.NoError(err)
+		a.NotNil(got)
+		a.Empty(got)
+	})
+}
@@ -0,0 +1,203 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты разрешений
+const (
+	permissionRead   = "permissions:read"
+	permissionCreate = "permissions:create"
+	permissionDelete = "permissions:delete"
+	permissionGrant  = "permissions:grant"
+)
+
+type Controller struct {
+	server            *web.Server
+	permissionService Svc
+	logger            *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (int64, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	DeleteById(ctx context.Context, request IdRequest) error
+	GrantToRole(ctx context.Context, request RolePermissionRequest) error
+	RevokeFromRole(ctx context.Context, request RolePermissionRequest) error
+	FindByRole(request IdRequest) ([]Response, error)
+	FindEffectiveForEmployee(request IdRequest) ([]EffectiveResponse, error)
+}
+
+func NewController(server *web.Server, permissionService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:            server,
+		permissionService: permissionService,
+		logger:            logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/permissions", c.server.Require(permissionCreate), c.CreatePermission)
+	c.server.GroupApiV1.Get("/permissions/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/permissions", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Delete("/permissions/:id", c.server.Require(permissionDelete), c.DeleteById)
+	c.server.GroupApiV1.Get("/roles/:id/permissions", c.server.Require(permissionRead), c.FindByRole)
+	c.server.GroupApiV1.Put("/roles/:id/permissions/:permissionId", c.server.Require(permissionGrant), c.GrantToRole)
+	c.server.GroupApiV1.Delete("/roles/:id/permissions/:permissionId", c.server.Require(permissionGrant), c.RevokeFromRole)
+	c.server.GroupApiV1.Get("/employees/:id/permissions", c.server.Require(permissionRead), c.FindEffectiveForEmployee)
+}
+
+func (c *Controller) CreatePermission(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create permission: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create permission: received request", zap.Any("request", request))
+	var newPermissionId, err = c.permissionService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create permission: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create permission: success", zap.Int64("id", newPermissionId))
+	return common.OkResponse(ctx, newPermissionId)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find permission by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find permission by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.permissionService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find permission by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find permission by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all permissions: received request")
+	responses, err := c.permissionService.FindAll()
+	if err != nil {
+		c.logger.Error("find all permissions: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all permissions: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) DeleteById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete permission by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete permission by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.permissionService.DeleteById(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete permission by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete permission by id: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) FindByRole(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find role permissions: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find role permissions: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.permissionService.FindByRole(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find role permissions: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find role permissions: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) GrantToRole(ctx *fiber.Ctx) error {
+	request, err := parseRolePermissionRequest(ctx)
+	if err != nil {
+		c.logger.Error("grant permission to role: invalid parameters", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("grant permission to role: received request", zap.Any("request", request))
+	if err = c.permissionService.GrantToRole(ctx.UserContext(), request); err != nil {
+		c.logger.Error("grant permission to role: service error", zap.Any("request", request), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("grant permission to role: success", zap.Any("request", request))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) RevokeFromRole(ctx *fiber.Ctx) error {
+	request, err := parseRolePermissionRequest(ctx)
+	if err != nil {
+		c.logger.Error("revoke permission from role: invalid parameters", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("revoke permission from role: received request", zap.Any("request", request))
+	if err = c.permissionService.RevokeFromRole(ctx.UserContext(), request); err != nil {
+		c.logger.Error("revoke permission from role: service error", zap.Any("request", request), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("revoke permission from role: success", zap.Any("request", request))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) FindEffectiveForEmployee(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find employee permissions: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find employee permissions: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.permissionService.FindEffectiveForEmployee(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find employee permissions: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find employee permissions: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func parseRolePermissionRequest(ctx *fiber.Ctx) (RolePermissionRequest, error) {
+	roleId, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return RolePermissionRequest{}, errors.New("invalid id parameter")
+	}
+	permissionId, err := strconv.ParseInt(ctx.Params("permissionId"), 10, 64)
+	if err != nil {
+		return RolePermissionRequest{}, errors.New("invalid permission id parameter")
+	}
+	return RolePermissionRequest{RoleId: roleId, PermissionId: permissionId}, nil
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,177 @@
+package permission
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (int64, error) {
+	args := svc.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) DeleteById(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) GrantToRole(ctx context.Context, request RolePermissionRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) RevokeFromRole(ctx context.Context, request RolePermissionRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindByRole(request IdRequest) ([]Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) FindEffectiveForEmployee(request IdRequest) ([]EffectiveResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]EffectiveResponse), args.Error(1)
+}
+
+func TestControllerCreatePermission(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return created permission id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Name: "employees:read"}).Return(int64(4), nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/permissions", strings.NewReader(`{"name":"employees:read"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[int64]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(4), responseBody.Data)
+	})
+
+	t.Run("should return bad request when permission exists", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Name: "employees:read"}).
+			Return(int64(0), common.AlreadyExistsError{Message: "exists"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/permissions", strings.NewReader(`{"name":"employees:read"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerRolePermissions(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should grant permission to role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("GrantToRole", RolePermissionRequest{RoleId: 1, PermissionId: 2}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/roles/1/permissions/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid permission id", func(t *testing.T) {
+		server := webtest.NewServer()
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, new(MockService), logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/roles/1/permissions/abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return not found when revoking missing permission", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("RevokeFromRole", RolePermissionRequest{RoleId: 1, PermissionId: 2}).
+			Return(common.NotFoundError{Message: "role 1 has no permission 2"})
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/roles/1/permissions/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerFindEffectiveForEmployee(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return effective permissions", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		permissions := []EffectiveResponse{{Id: 1, Name: "employees:read", GrantedBy: []int64{2, 3}}}
+		svc.On("FindEffectiveForEmployee", IdRequest{Id: 5}).Return(permissions, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/employees/5/permissions", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]EffectiveResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(permissions, responseBody.Data)
+	})
+}
@@ -0,0 +1,60 @@
+package permission
+
+import "time"
+
+type Entity struct {
+	Id          int64     `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:          e.Id,
+		Name:        e.Name,
+		Description: e.Description,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние разрешения в журнале аудита
+type auditSnapshot struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Name: e.Name, Description: e.Description}
+}
+
+// grantSnapshot выдача разрешения роли в журнале аудита
+type grantSnapshot struct {
+	RoleId       int64 `json:"role_id"`
+	PermissionId int64 `json:"permission_id"`
+}
+
+// RoleGrantEntity разрешение вместе с ролью, которая его выдаёт
+type RoleGrantEntity struct {
+	RoleId int64 `db:"role_id"`
+	Entity
+}
+
+type Response struct {
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EffectiveResponse разрешение сотрудника и роли, через которые оно получено
+type EffectiveResponse struct {
+	Id          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	GrantedBy   []int64 `json:"granted_by_roles"`
+}
@@ -0,0 +1,90 @@
+package permission
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) FindByNameTx(tx *sqlx.Tx, name string) (exists bool, err error) {
+	query := "select exists(select 1 from permission where name = $1)"
+	err = tx.Get(&exists, query, name)
+	return exists, err
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (id int64, err error) {
+	query := "insert into permission (name, description) values ($1, $2) returning id"
+	err = tx.QueryRowx(query, e.Name, e.Description).Scan(&id)
+	return id, err
+}
+
+func (r *Repository) FindById(id int64) (permission Entity, err error) {
+	query := "select * from permission where id = $1"
+	err = r.db.Get(&permission, query, id)
+	return permission, err
+}
+
+func (r *Repository) FindAll() (permissions []Entity, err error) {
+	query := "select * from permission order by name"
+	err = r.db.Select(&permissions, query)
+	return permissions, err
+}
+
+// DeleteTx удалить разрешения и вернуть удалённые строки
+func (r *Repository) DeleteTx(tx *sqlx.Tx, ids []int64) (deleted []Entity, err error) {
+	query := "delete from permission where id = ANY($1) returning *"
+	err = tx.Select(&deleted, query, pq.Array(ids))
+	return deleted, err
+}
+
+// GrantToRoleTx выдать разрешение роли; повторная выдача не считается ошибкой и возвращает false
+func (r *Repository) GrantToRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (granted bool, err error) {
+	query := "insert into role_permission (role_id, permission_id) values ($1, $2) on conflict do nothing"
+	result, err := tx.Exec(query, roleId, permissionId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repository) RevokeFromRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (revoked bool, err error) {
+	query := "delete from role_permission where role_id = $1 and permission_id = $2"
+	result, err := tx.Exec(query, roleId, permissionId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repository) FindByRoleId(roleId int64) (permissions []Entity, err error) {
+	query := `select p.* from permission p
+		join role_permission rp on rp.permission_id = p.id
+		where rp.role_id = $1 order by p.name`
+	err = r.db.Select(&permissions, query, roleId)
+	return permissions, err
+}
+
+// FindGrantsByRoleIds разрешения перечисленных ролей; одно разрешение встречается столько раз, сколько ролей его выдают
+func (r *Repository) FindGrantsByRoleIds(roleIds []int64) (grants []RoleGrantEntity, err error) {
+	if len(roleIds) == 0 {
+		return []RoleGrantEntity{}, nil
+	}
+	query := `select rp.role_id, p.* from permission p
+		join role_permission rp on rp.permission_id = p.id
+		where rp.role_id = ANY($1) order by p.name, rp.role_id`
+	err = r.db.Select(&grants, query, pq.Array(roleIds))
+	return grants, err
+}
@@ -0,0 +1,19 @@
+package permission
+
+type CreateRequest struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+func (r *CreateRequest) ToEntity() Entity {
+	return Entity{Name: r.Name, Description: r.Description}
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+type RolePermissionRequest struct {
+	RoleId       int64 `json:"role_id" validate:"required,gt=0"`
+	PermissionId int64 `json:"permission_id" validate:"required,gt=0"`
+}
@@ -0,0 +1,419 @@
+package permission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"slices"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "permission"
+
+// Действия с выдачей разрешений в журнале аудита; событие относится к роли
+const (
+	auditEntityTypeRole = "role"
+	auditActionGrant    = "grant_permission"
+	auditActionRevoke   = "revoke_permission"
+)
+
+type Service struct {
+	repo           Repo
+	employeeRepo   EmployeeRepo
+	roleRepo       RoleRepo
+	assignmentRepo AssignmentRepo
+	admins         []string
+	auditor        Auditor
+	validator      Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	FindByNameTx(tx *sqlx.Tx, name string) (bool, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (int64, error)
+	FindById(id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error)
+	GrantToRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (bool, error)
+	RevokeFromRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (bool, error)
+	FindByRoleId(roleId int64) ([]Entity, error)
+	FindGrantsByRoleIds(roleIds []int64) ([]RoleGrantEntity, error)
+}
+
+type EmployeeRepo interface {
+	FindById(id int64) (employee.Entity, error)
+	FindByLogin(login string) (employee.Entity, error)
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+	ExpandInherited(ids []int64) ([]role.Entity, error)
+}
+
+type AssignmentRepo interface {
+	FindEffectiveRoles(employeeId int64, at time.Time) ([]role.Entity, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+// NewService admins - субъекты из конфигурации, которым разрешено всё без проверки ролей
+func NewService(
+	repo Repo,
+	employeeRepo EmployeeRepo,
+	roleRepo RoleRepo,
+	assignmentRepo AssignmentRepo,
+	admins []string,
+	auditor Auditor,
+	validator Validator,
+) *Service {
+	return &Service{
+		repo:           repo,
+		employeeRepo:   employeeRepo,
+		roleRepo:       roleRepo,
+		assignmentRepo: assignmentRepo,
+		admins:         admins,
+		auditor:        auditor,
+		validator:      validator,
+	}
+}
+
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (int64, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return 0, common.RequestValidationError{Message: err.Error()}
+	}
+	var id int64
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating permission", func(tx *sqlx.Tx) error {
+		exists, err := svc.repo.FindByNameTx(tx, request.Name)
+		if err != nil {
+			return fmt.Errorf("error finding permission by name: %s %w", request.Name, err)
+		}
+		if exists {
+			return common.AlreadyExistsError{
+				Message: fmt.Sprintf("permission with name %s already exists", request.Name),
+			}
+		}
+		entity := request.ToEntity()
+		id, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving permission with name: %s %w", request.Name, err)
+		}
+		entity.Id = id
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   id,
+			After:      entity.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding permission with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error retrieving all permissions: %v", err),
+		}
+	}
+	return toResponses(entities), nil
+}
+
+func (svc *Service) DeleteById(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	err = database.InTransaction(svc.repo.BeginTransaction, "deleting permission", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, []int64{request.Id})
+		if err != nil {
+			return err
+		}
+		for _, entity := range deleted {
+			err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+				Action:     audit.ActionDelete,
+				EntityType: auditEntityType,
+				EntityId:   entity.Id,
+				Before:     entity.auditSnapshot(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error deleting permission with id %d: %v", request.Id, err),
+		}
+	}
+	return nil
+}
+
+// GrantToRole выдать разрешение роли
+func (svc *Service) GrantToRole(ctx context.Context, request RolePermissionRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.roleRepo.FindById(request.RoleId); err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.RoleId, err),
+		}
+	}
+	if _, err = svc.repo.FindById(request.PermissionId); err != nil {
+		return common.NotFoundError{
+			Message: fmt.Sprintf("error finding permission with id %d: %v", request.PermissionId, err),
+		}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "granting permission", func(tx *sqlx.Tx) error {
+		granted, err := svc.repo.GrantToRoleTx(tx, request.RoleId, request.PermissionId)
+		if err != nil {
+			return fmt.Errorf("error granting permission %d to role %d: %w", request.PermissionId, request.RoleId, err)
+		}
+		if !granted {
+			return nil
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionGrant,
+			EntityType: auditEntityTypeRole,
+			EntityId:   request.RoleId,
+			After:      grantSnapshot{RoleId: request.RoleId, PermissionId: request.PermissionId},
+		})
+	})
+}
+
+// RevokeFromRole отозвать разрешение у роли
+func (svc *Service) RevokeFromRole(ctx context.Context, request RolePermissionRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "revoking permission", func(tx *sqlx.Tx) error {
+		revoked, err := svc.repo.RevokeFromRoleTx(tx, request.RoleId, request.PermissionId)
+		if err != nil {
+			return fmt.Errorf("error revoking permission %d from role %d: %w", request.PermissionId, request.RoleId, err)
+		}
+		if !revoked {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("role %d has no permission %d", request.RoleId, request.PermissionId),
+			}
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionRevoke,
+			EntityType: auditEntityTypeRole,
+			EntityId:   request.RoleId,
+			Before:     grantSnapshot{RoleId: request.RoleId, PermissionId: request.PermissionId},
+		})
+	})
+}
+
+// FindByRole разрешения, выданные роли напрямую
+func (svc *Service) FindByRole(request IdRequest) ([]Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.roleRepo.FindById(request.Id); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.Id, err),
+		}
+	}
+	entities, err := svc.repo.FindByRoleId(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving permissions of role %d: %w", request.Id, err)
+	}
+	return toResponses(entities), nil
+}
+
+// FindEffectiveForEmployee итоговый набор разрешений сотрудника: объединение разрешений основной роли,
+// всех действующих назначений ролей и унаследованных ими ролей без повторов
+func (svc *Service) FindEffectiveForEmployee(request IdRequest) ([]EffectiveResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	found, err := svc.employeeRepo.FindById(request.Id)
+	if err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding employee with id %d: %v", request.Id, err),
+		}
+	}
+	return svc.effective(found)
+}
+
+// HasPermission есть ли у сотрудника с логином subject разрешение с именем name.
+// Субъект, не связанный ни с одним сотрудником, и неработающий сотрудник разрешений не имеют.
+func (svc *Service) HasPermission(subject, name string) (bool, error) {
+	found, err := svc.employeeRepo.FindByLogin(subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error finding employee with login %s: %w", subject, err)
+	}
+	if !found.IsActive() {
+		return false, nil
+	}
+	permissions, err := svc.effective(found)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(permissions, func(p EffectiveResponse) bool { return p.Name == name }), nil
+}
+
+// CheckGrantable ключу доступа или клиенту OAuth2 можно выдать только существующие разрешения,
+// которые есть у самого автора запроса: иначе через них он получил бы больше прав, чем имеет
+func (svc *Service) CheckGrantable(ctx context.Context, names []string) error {
+	known, err := svc.repo.FindAll()
+	if err != nil {
+		return fmt.Errorf("error retrieving permissions: %w", err)
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(known, func(p Entity) bool { return p.Name == name }) {
+			return common.RequestValidationError{Message: fmt.Sprintf("unknown permission %s", name)}
+		}
+	}
+	actor := common.ActorFrom(ctx)
+	held, all, err := svc.held(ctx, actor)
+	if err != nil || all {
+		return err
+	}
+	for _, name := range names {
+		if !slices.Contains(held, name) {
+			return common.ForbiddenError{
+				Message: fmt.Sprintf("cannot grant permission %s: %s does not hold it", name, actor),
+			}
+		}
+	}
+	return nil
+}
+
+// Holds есть ли у автора запроса разрешение name по тем же правилам, что и при авторизации маршрутов
+func (svc *Service) Holds(ctx context.Context, name string) (bool, error) {
+	held, all, err := svc.held(ctx, common.ActorFrom(ctx))
+	if err != nil {
+		return false, err
+	}
+	return all || slices.Contains(held, name), nil
+}
+
+// held разрешения автора запроса по тем же правилам, что и при авторизации маршрутов: запрос по ключу
+// ограничен разрешениями ключа, администратору (all == true) разрешено всё, остальным - по ролям в IDM
+func (svc *Service) held(ctx context.Context, actor string) (names []string, all bool, err error) {
+	if scopes, ok := common.ScopesFrom(ctx); ok {
+		return scopes, false, nil
+	}
+	if slices.Contains(svc.admins, actor) {
+		return nil, true, nil
+	}
+	found, err := svc.employeeRepo.FindByLogin(actor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error finding employee with login %s: %w", actor, err)
+	}
+	if !found.IsActive() {
+		return nil, false, nil
+	}
+	permissions, err := svc.effective(found)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range permissions {
+		names = append(names, p.Name)
+	}
+	return names, false, nil
+}
+
+// effective разрешения сотрудника через основную роль, действующие назначения и унаследованные роли
+func (svc *Service) effective(found employee.Entity) ([]EffectiveResponse, error) {
+	roles, err := svc.assignmentRepo.FindEffectiveRoles(found.Id, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error finding effective roles of employee with id %d: %w", found.Id, err)
+	}
+	roleIds := make([]int64, 0, len(roles)+1)
+	if found.RoleId != nil {
+		roleIds = append(roleIds, *found.RoleId)
+	}
+	for _, r := range roles {
+		roleIds = append(roleIds, r.Id)
+	}
+	expanded, err := svc.roleRepo.ExpandInherited(roleIds)
+	if err != nil {
+		return nil, fmt.Errorf("error expanding inherited roles of employee with id %d: %w", found.Id, err)
+	}
+	roleIds = roleIds[:0]
+	for _, r := range expanded {
+		roleIds = append(roleIds, r.Id)
+	}
+	grants, err := svc.repo.FindGrantsByRoleIds(roleIds)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving permissions of employee with id %d: %w", found.Id, err)
+	}
+	return mergeGrants(grants), nil
+}
+
+// mergeGrants свернуть выдачи разрешений по ролям в уникальные разрешения, сохраняя порядок первого появления
+func mergeGrants(grants []RoleGrantEntity) []EffectiveResponse {
+	responses := make([]EffectiveResponse, 0, len(grants))
+	index := make(map[int64]int, len(grants))
+	for _, grant := range grants {
+		i, ok := index[grant.Id]
+		if !ok {
+			i = len(responses)
+			index[grant.Id] = i
+			responses = append(responses, EffectiveResponse{
+				Id:          grant.Id,
+				Name:        grant.Name,
+				Description: grant.Description,
+				GrantedBy:   []int64{},
+			})
+		}
+		if !slices.Contains(responses[i].GrantedBy, grant.RoleId) {
+			responses[i].GrantedBy = append(responses[i].GrantedBy, grant.RoleId)
+		}
+	}
+	return responses
+}
+
+func toResponses(entities []Entity) []Response {
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses
+}
@@ -0,0 +1,488 @@
+package permission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) FindByNameTx(tx *sqlx.Tx, name string) (bool, error) {
+	args := m.Called(tx, name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (int64, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error) {
+	args := m.Called(tx, ids)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) GrantToRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (bool, error) {
+	args := m.Called(tx, roleId, permissionId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) RevokeFromRoleTx(tx *sqlx.Tx, roleId, permissionId int64) (bool, error) {
+	args := m.Called(tx, roleId, permissionId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindByRoleId(roleId int64) ([]Entity, error) {
+	args := m.Called(roleId)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindGrantsByRoleIds(roleIds []int64) ([]RoleGrantEntity, error) {
+	args := m.Called(roleIds)
+	return args.Get(0).([]RoleGrantEntity), args.Error(1)
+}
+
+type MockEmployeeRepo struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeRepo) FindById(id int64) (employee.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(employee.Entity), args.Error(1)
+}
+
+func (m *MockEmployeeRepo) FindByLogin(login string) (employee.Entity, error) {
+	args := m.Called(login)
+	return args.Get(0).(employee.Entity), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+func (m *MockRoleRepo) ExpandInherited(ids []int64) ([]role.Entity, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+type MockAssignmentRepo struct {
+	mock.Mock
+}
+
+func (m *MockAssignmentRepo) FindEffectiveRoles(employeeId int64, at time.Time) ([]role.Entity, error) {
+	args := m.Called(employeeId, at)
+	return args.Get(0).([]role.Entity), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should create permission", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		request := CreateRequest{Name: "employees:read", Description: "read employees"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, request.Name).Return(false, nil)
+		repo.On("SaveTx", noTx, request.ToEntity()).Return(int64(3), nil)
+
+		id, err := svc.Create(context.Background(), request)
+		a.NoError(err)
+		a.Equal(int64(3), id)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "permission",
+			EntityId:   3,
+			After:      auditSnapshot{Id: 3, Name: "employees:read", Description: "read employees"},
+		}}, auditor.events)
+	})
+
+	t.Run("should return already exists error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByNameTx", noTx, "employees:read").Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "employees:read"})
+		a.Equal(common.AlreadyExistsError{Message: "permission with name employees:read already exists"}, err)
+		a.True(repo.AssertNotCalled(t, "SaveTx"))
+	})
+
+	t.Run("should return validation error", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: ""})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceGrantToRole(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should grant permission to role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), roles, new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		roles.On("FindById", int64(1)).Return(role.Entity{Id: 1}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("GrantToRoleTx", noTx, int64(1), int64(2)).Return(true, nil)
+
+		err := svc.GrantToRole(context.Background(), RolePermissionRequest{RoleId: 1, PermissionId: 2})
+		a.NoError(err)
+		a.Len(auditor.events, 1)
+		a.Equal("grant_permission", auditor.events[0].Action)
+		a.Equal(int64(1), auditor.events[0].EntityId)
+	})
+
+	t.Run("should not audit repeated grant", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), roles, new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		roles.On("FindById", int64(1)).Return(role.Entity{Id: 1}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("GrantToRoleTx", noTx, int64(1), int64(2)).Return(false, nil)
+
+		err := svc.GrantToRole(context.Background(), RolePermissionRequest{RoleId: 1, PermissionId: 2})
+		a.NoError(err)
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should return not found when permission does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, new(MockEmployeeRepo), roles, new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		dbErr := errors.New("no rows")
+		roles.On("FindById", int64(1)).Return(role.Entity{Id: 1}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{}, dbErr)
+
+		err := svc.GrantToRole(context.Background(), RolePermissionRequest{RoleId: 1, PermissionId: 2})
+		a.Equal(common.NotFoundError{Message: fmt.Sprintf("error finding permission with id 2: %v", dbErr)}, err)
+		a.True(repo.AssertNotCalled(t, "GrantToRoleTx"))
+	})
+}
+
+func TestServiceRevokeFromRole(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should return not found when role has no such permission", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RevokeFromRoleTx", noTx, int64(1), int64(2)).Return(false, nil)
+
+		err := svc.RevokeFromRole(context.Background(), RolePermissionRequest{RoleId: 1, PermissionId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceDeleteById(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should delete permission and audit its last state", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{3}).Return([]Entity{{Id: 3, Name: "employees:read"}}, nil)
+
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 3})
+		a.NoError(err)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionDelete,
+			EntityType: "permission",
+			EntityId:   3,
+			Before:     auditSnapshot{Id: 3, Name: "employees:read"},
+		}}, auditor.events)
+	})
+
+	t.Run("should fail deletion when audit record cannot be saved", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := &StubAuditor{err: errors.New("audit error")}
+		svc := NewService(repo, new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), nil, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{3}).Return([]Entity{{Id: 3}}, nil)
+
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 3})
+		a.ErrorContains(err, "audit error")
+	})
+}
+
+func TestServiceFindEffectiveForEmployee(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should merge permissions of primary, assigned and inherited roles", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, employees, roles, assignments, nil, new(StubAuditor), validator.New())
+
+		primary := int64(1)
+		employees.On("FindById", int64(10)).Return(employee.Entity{Id: 10, RoleId: &primary}, nil)
+		assignments.On("FindEffectiveRoles", int64(10), mock.AnythingOfType("time.Time")).
+			Return([]role.Entity{{Id: 2}, {Id: 3}}, nil)
+		// роль 3 включает в себя роль 4
+		roles.On("ExpandInherited", []int64{1, 2, 3}).Return([]role.Entity{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}, nil)
+		repo.On("FindGrantsByRoleIds", []int64{1, 2, 3, 4}).Return([]RoleGrantEntity{
+			{RoleId: 1, Entity: Entity{Id: 100, Name: "employees:read"}},
+			{RoleId: 2, Entity: Entity{Id: 100, Name: "employees:read"}},
+			{RoleId: 3, Entity: Entity{Id: 101, Name: "roles:read"}},
+			{RoleId: 4, Entity: Entity{Id: 102, Name: "roles:write"}},
+		}, nil)
+
+		got, err := svc.FindEffectiveForEmployee(IdRequest{Id: 10})
+		a.NoError(err)
+		a.Equal([]EffectiveResponse{
+			{Id: 100, Name: "employees:read", GrantedBy: []int64{1, 2}},
+			{Id: 101, Name: "roles:read", GrantedBy: []int64{3}},
+			{Id: 102, Name: "roles:write", GrantedBy: []int64{4}},
+		}, got)
+	})
+
+	t.Run("should return empty set for employee without roles", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, employees, roles, assignments, nil, new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(10)).Return(employee.Entity{Id: 10}, nil)
+		assignments.On("FindEffectiveRoles", int64(10), mock.AnythingOfType("time.Time")).Return([]role.Entity{}, nil)
+		roles.On("ExpandInherited", []int64{}).Return([]role.Entity{}, nil)
+		repo.On("FindGrantsByRoleIds", []int64{}).Return([]RoleGrantEntity{}, nil)
+
+		got, err := svc.FindEffectiveForEmployee(IdRequest{Id: 10})
+		a.NoError(err)
+		a.Empty(got)
+		a.NotNil(got)
+	})
+
+	t.Run("should return not found when employee does not exist", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		employees.On("FindById", int64(10)).Return(employee.Entity{}, errors.New("no rows"))
+
+		_, err := svc.FindEffectiveForEmployee(IdRequest{Id: 10})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceHasPermission(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should find permission among effective permissions of employee with login", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, employees, roles, assignments, nil, new(StubAuditor), validator.New())
+
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 10, Status: employee.StatusActive}, nil)
+		assignments.On("FindEffectiveRoles", int64(10), mock.AnythingOfType("time.Time")).
+			Return([]role.Entity{{Id: 2}}, nil)
+		roles.On("ExpandInherited", []int64{2}).Return([]role.Entity{{Id: 2}}, nil)
+		repo.On("FindGrantsByRoleIds", []int64{2}).Return([]RoleGrantEntity{
+			{RoleId: 2, Entity: Entity{Id: 100, Name: "employees:read"}},
+		}, nil)
+
+		allowed, err := svc.HasPermission("alice", "employees:read")
+		a.NoError(err)
+		a.True(allowed)
+
+		allowed, err = svc.HasPermission("alice", "employees:delete")
+		a.NoError(err)
+		a.False(allowed)
+	})
+
+	t.Run("should deny suspended employee", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), assignments, nil, new(StubAuditor), validator.New())
+
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 10, Status: employee.StatusSuspended}, nil)
+
+		allowed, err := svc.HasPermission("alice", "employees:read")
+		a.NoError(err)
+		a.False(allowed)
+		a.True(assignments.AssertNotCalled(t, "FindEffectiveRoles", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should deny subject without employee", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		employees.On("FindByLogin", "stranger").Return(employee.Entity{}, sql.ErrNoRows)
+
+		allowed, err := svc.HasPermission("stranger", "employees:read")
+		a.NoError(err)
+		a.False(allowed)
+	})
+
+	t.Run("should return wrapped error", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		dbErr := errors.New("database error")
+		employees.On("FindByLogin", "alice").Return(employee.Entity{}, dbErr)
+
+		_, err := svc.HasPermission("alice", "employees:read")
+		a.ErrorIs(err, dbErr)
+	})
+}
+
+func TestServiceCheckGrantable(t *testing.T) {
+	a := assert.New(t)
+	known := []Entity{{Id: 100, Name: "employees:read"}, {Id: 101, Name: "apikeys:manage"}}
+
+	t.Run("should allow permissions the employee holds", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		roles := new(MockRoleRepo)
+		assignments := new(MockAssignmentRepo)
+		svc := NewService(repo, employees, roles, assignments, nil, new(StubAuditor), validator.New())
+
+		repo.On("FindAll").Return(known, nil)
+		employees.On("FindByLogin", "alice").Return(employee.Entity{Id: 10, Status: employee.StatusActive}, nil)
+		assignments.On("FindEffectiveRoles", int64(10), mock.AnythingOfType("time.Time")).
+			Return([]role.Entity{{Id: 2}}, nil)
+		roles.On("ExpandInherited", []int64{2}).Return([]role.Entity{{Id: 2}}, nil)
+		repo.On("FindGrantsByRoleIds", []int64{2}).Return([]RoleGrantEntity{
+			{RoleId: 2, Entity: Entity{Id: 100, Name: "employees:read"}},
+		}, nil)
+		ctx := common.WithActor(context.Background(), "alice")
+
+		a.NoError(svc.CheckGrantable(ctx, []string{"employees:read"}))
+
+		err := svc.CheckGrantable(ctx, []string{"employees:read", "apikeys:manage"})
+		a.Equal(common.ForbiddenError{Message: "cannot grant permission apikeys:manage: alice does not hold it"}, err)
+	})
+
+	t.Run("should limit request by key to permissions of the key", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(MockAssignmentRepo), []string{"service:ci"}, new(StubAuditor), validator.New())
+
+		repo.On("FindAll").Return(known, nil)
+		ctx := common.WithScopes(common.WithActor(context.Background(), "service:ci"), []string{"employees:read"})
+
+		a.NoError(svc.CheckGrantable(ctx, []string{"employees:read"}))
+		a.ErrorAs(svc.CheckGrantable(ctx, []string{"apikeys:manage"}), &common.ForbiddenError{})
+		a.True(employees.AssertNotCalled(t, "FindByLogin", mock.Anything))
+	})
+
+	t.Run("should allow any known permission to configured admin", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(MockAssignmentRepo), []string{"root"}, new(StubAuditor), validator.New())
+
+		repo.On("FindAll").Return(known, nil)
+		ctx := common.WithActor(context.Background(), "root")
+
+		a.NoError(svc.CheckGrantable(ctx, []string{"employees:read", "apikeys:manage"}))
+		a.Equal(common.RequestValidationError{Message: "unknown permission employees:purge"},
+			svc.CheckGrantable(ctx, []string{"employees:purge"}))
+		a.True(employees.AssertNotCalled(t, "FindByLogin", mock.Anything))
+	})
+
+	t.Run("should deny subject without employee", func(t *testing.T) {
+		repo := new(MockRepo)
+		employees := new(MockEmployeeRepo)
+		svc := NewService(repo, employees, new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+
+		repo.On("FindAll").Return(known, nil)
+		employees.On("FindByLogin", "stranger").Return(employee.Entity{}, sql.ErrNoRows)
+
+		err := svc.CheckGrantable(common.WithActor(context.Background(), "stranger"), []string{"employees:read"})
+		a.ErrorAs(err, &common.ForbiddenError{})
+	})
+}
+
+func TestServiceHolds(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should check permission of request by key against its scopes", func(t *testing.T) {
+		employees := new(MockEmployeeRepo)
+		svc := NewService(new(MockRepo), employees, new(MockRoleRepo), new(MockAssignmentRepo), nil, new(StubAuditor), validator.New())
+		ctx := common.WithScopes(common.WithActor(context.Background(), "service:ci"), []string{"employees:read"})
+
+		held, err := svc.Holds(ctx, "employees:read")
+		a.NoError(err)
+		a.True(held)
+		held, err = svc.Holds(ctx, "employees:login")
+		a.NoError(err)
+		a.False(held)
+		a.True(employees.AssertNotCalled(t, "FindByLogin", mock.Anything))
+	})
+
+	t.Run("should grant everything to configured admin", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(MockEmployeeRepo), new(MockRoleRepo), new(MockAssignmentRepo), []string{"root"}, new(StubAuditor), validator.New())
+
+		held, err := svc.Holds(common.WithActor(context.Background(), "root"), "employees:login")
+		a.NoError(err)
+		a.True(held)
+	})
+}
@@ -0,0 +1,114 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Account учётная запись сотрудника во внешней системе; сотрудник определяется по EmployeeId
+type Account struct {
+	EmployeeId int64   `json:"employee_id"`
+	Name       string  `json:"name"`
+	Login      *string `json:"login"`
+}
+
+// Entitlement право во внешней системе, соответствующее роли IDM
+type Entitlement struct {
+	RoleId int64  `json:"role_id"`
+	Name   string `json:"name"`
+}
+
+// Connector внешняя система, в которую выгружаются учётные записи и их права.
+// Операция может быть выполнена повторно после ошибки или сбоя обработчика, поэтому должна быть идемпотентной.
+// CreateAccount также включает ранее отключённую запись.
+type Connector interface {
+	CreateAccount(ctx context.Context, account Account) error
+	UpdateAccount(ctx context.Context, account Account) error
+	DisableAccount(ctx context.Context, account Account) error
+	GrantEntitlement(ctx context.Context, account Account, entitlement Entitlement) error
+	RevokeEntitlement(ctx context.Context, account Account, entitlement Entitlement) error
+}
+
+// Factory создать коннектор по его настройкам из конфигурации
+type Factory func(settings json.RawMessage) (Connector, error)
+
+// ConnectorConfig настройка одного коннектора: имя, под которым его операции попадают в очередь,
+// тип из Registry и настройки, которые разбирает фабрика типа
+type ConnectorConfig struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+// connectorName имя коннектора хранится в очереди и передаётся в query, поэтому ограничено простыми символами
+var connectorName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// LoadConfig прочитать настройки коннекторов из JSON-файла вида {"connectors": [...]}
+func LoadConfig(path string) ([]ConnectorConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading provisioning config: %w", err)
+	}
+	var file struct {
+		Connectors []ConnectorConfig `json:"connectors"`
+	}
+	if err = json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("error parsing provisioning config %s: %w", path, err)
+	}
+	return file.Connectors, nil
+}
+
+// Registry типы коннекторов, которые можно указать в конфигурации
+type Registry struct {
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: map[string]Factory{}}
+}
+
+// Register добавить тип коннектора; повторная регистрация типа заменяет фабрику
+func (r *Registry) Register(connectorType string, factory Factory) {
+	r.factories[connectorType] = factory
+}
+
+// Build создать коннекторы по конфигурации; имена должны быть уникальными, типы - зарегистрированными
+func (r *Registry) Build(configs []ConnectorConfig) (map[string]Connector, error) {
+	connectors := make(map[string]Connector, len(configs))
+	for _, cfg := range configs {
+		if !connectorName.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid connector name %q: expected lowercase letters, digits, '-' and '_'", cfg.Name)
+		}
+		if _, ok := connectors[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate connector name %q", cfg.Name)
+		}
+		factory, ok := r.factories[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("connector %s: unknown type %q", cfg.Name, cfg.Type)
+		}
+		connector, err := factory(cfg.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("connector %s: %w", cfg.Name, err)
+		}
+		connectors[cfg.Name] = connector
+	}
+	return connectors, nil
+}
+
+// Names имена коннекторов в алфавитном порядке
+func Names(connectors map[string]Connector) []string {
+	names := make([]string, 0, len(connectors))
+	for name := range connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errNoSettings настройки коннектора обязательны, но не заданы
+var errNoSettings = errors.New("settings are required")
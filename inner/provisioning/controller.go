@@ -0,0 +1,122 @@
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты очереди выгрузки
+const (
+	permissionRead   = "provisioning:read"
+	permissionManage = "provisioning:manage"
+)
+
+type Controller struct {
+	server              *web.Server
+	provisioningService Svc
+	logger              *common.Logger
+}
+
+type Svc interface {
+	FindAll(request ListRequest) (common.Page[Response], error)
+	FindById(request IdRequest) (Response, error)
+	Retry(request IdRequest) (Response, error)
+}
+
+func NewController(server *web.Server, provisioningService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:              server,
+		provisioningService: provisioningService,
+		logger:              logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Get("/provisioning/operations", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Get("/provisioning/operations/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Post("/provisioning/operations/:id/retry", c.server.Require(permissionManage), c.Retry)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		c.logger.Error("find provisioning operations: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find provisioning operations: received request", zap.Any("request", request))
+	page, err := c.provisioningService.FindAll(request)
+	if err != nil {
+		c.logger.Error("find provisioning operations: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find provisioning operations: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find provisioning operation by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find provisioning operation by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.provisioningService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find provisioning operation by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find provisioning operation by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Retry(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("retry provisioning operation: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("retry provisioning operation: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.provisioningService.Retry(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("retry provisioning operation: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("retry provisioning operation: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+// parseListRequest прочитать фильтры очереди из query: connector, status, kind, employee_id
+func parseListRequest(ctx *fiber.Ctx) (request ListRequest, err error) {
+	if request.PageRequest, err = common.ParsePageRequest(ctx); err != nil {
+		return request, err
+	}
+	request.Connector = ctx.Query("connector")
+	request.Status = ctx.Query("status")
+	request.Kind = ctx.Query("kind")
+	if raw := ctx.Query("employee_id"); raw != "" {
+		employeeId, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return request, fmt.Errorf("invalid employee_id %q", raw)
+		}
+		request.EmployeeId = &employeeId
+	}
+	return request, nil
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,130 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Retry(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func TestControllerFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters and return page of operations", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		NewController(server, svc, logger).RegisterRoutes()
+
+		employeeId := int64(7)
+		request := ListRequest{
+			PageRequest: common.PageRequest{Limit: 10},
+			Connector:   "hr",
+			Status:      StatusFailed,
+			Kind:        KindGrantEntitlement,
+			EmployeeId:  &employeeId,
+		}
+		lastError := "target unavailable"
+		page := common.Page[Response]{
+			Items: []Response{{
+				Id:          1,
+				Connector:   "hr",
+				Kind:        KindGrantEntitlement,
+				EmployeeId:  7,
+				Entitlement: &Entitlement{RoleId: 4, Name: "devs"},
+				Status:      StatusFailed,
+				Attempts:    8,
+				LastError:   &lastError,
+			}},
+			PageInfo: common.PageInfo{Total: 1},
+		}
+		svc.On("FindAll", request).Return(page, nil)
+
+		url := "/api/v1/provisioning/operations?limit=10&connector=hr&status=failed&kind=grant_entitlement&employee_id=7"
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, url, nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal("target unavailable", *responseBody.Data[0].LastError)
+		a.Equal(int64(1), responseBody.Page.Total)
+	})
+
+	t.Run("should return bad request on invalid employee id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		NewController(server, svc, &common.Logger{Logger: zap.NewNop()}).RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/provisioning/operations?employee_id=x", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
+
+func TestControllerRetry(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should retry operation", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		NewController(server, svc, &common.Logger{Logger: zap.NewNop()}).RegisterRoutes()
+		svc.On("Retry", IdRequest{Id: 3}).Return(Response{Id: 3, Status: StatusPending}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/provisioning/operations/3/retry", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request for operation that has not failed", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		NewController(server, svc, &common.Logger{Logger: zap.NewNop()}).RegisterRoutes()
+		svc.On("Retry", IdRequest{Id: 3}).Return(Response{}, common.RequestValidationError{Message: "not failed"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/provisioning/operations/3/retry", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should return not found", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		NewController(server, svc, &common.Logger{Logger: zap.NewNop()}).RegisterRoutes()
+		svc.On("FindById", IdRequest{Id: 3}).Return(Response{}, common.NotFoundError{Message: "not found"})
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/provisioning/operations/3", nil))
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
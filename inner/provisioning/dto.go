@@ -0,0 +1,92 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Виды операций выгрузки
+const (
+	KindCreateAccount     = "create_account"
+	KindUpdateAccount     = "update_account"
+	KindDisableAccount    = "disable_account"
+	KindGrantEntitlement  = "grant_entitlement"
+	KindRevokeEntitlement = "revoke_entitlement"
+)
+
+// Состояния операции. skipped - к моменту выполнения выдача или отзыв права потеряли смысл:
+// роль уже отозвана или сотрудник всё ещё владеет ею по другому назначению
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+type Entity struct {
+	Id            int64     `db:"id"`
+	Connector     string    `db:"connector"`
+	Kind          string    `db:"kind"`
+	EmployeeId    int64     `db:"employee_id"`
+	RoleId        *int64    `db:"role_id"`
+	Payload       []byte    `db:"payload"`
+	Status        string    `db:"status"`
+	Attempts      int       `db:"attempts"`
+	ScheduledAt   time.Time `db:"scheduled_at"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	LastError     *string   `db:"last_error"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+// Payload данные, с которыми операция передаётся коннектору; запоминаются в момент изменения
+type Payload struct {
+	Account     Account      `json:"account"`
+	Entitlement *Entitlement `json:"entitlement,omitempty"`
+}
+
+func (e *Entity) payload() (payload Payload, err error) {
+	err = json.Unmarshal(e.Payload, &payload)
+	return payload, err
+}
+
+func (e *Entity) toResponse() Response {
+	response := Response{
+		Id:          e.Id,
+		Connector:   e.Connector,
+		Kind:        e.Kind,
+		EmployeeId:  e.EmployeeId,
+		RoleId:      e.RoleId,
+		Status:      e.Status,
+		Attempts:    e.Attempts,
+		ScheduledAt: e.ScheduledAt,
+		LastError:   e.LastError,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+	if payload, err := e.payload(); err == nil {
+		response.Account = &payload.Account
+		response.Entitlement = payload.Entitlement
+	}
+	if e.Status == StatusPending {
+		response.NextAttemptAt = &e.NextAttemptAt
+	}
+	return response
+}
+
+type Response struct {
+	Id            int64        `json:"id"`
+	Connector     string       `json:"connector"`
+	Kind          string       `json:"kind"`
+	EmployeeId    int64        `json:"employee_id"`
+	RoleId        *int64       `json:"role_id,omitempty"`
+	Account       *Account     `json:"account,omitempty"`
+	Entitlement   *Entitlement `json:"entitlement,omitempty"`
+	Status        string       `json:"status"`
+	Attempts      int          `json:"attempts"`
+	ScheduledAt   time.Time    `json:"scheduled_at"`
+	NextAttemptAt *time.Time   `json:"next_attempt_at,omitempty"`
+	LastError     *string      `json:"last_error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,254 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileConnectorType тип эталонного коннектора, который ведёт учётные записи в локальном файле
+const FileConnectorType = "file"
+
+// Форматы файла эталонного коннектора
+const (
+	FormatJson = "json"
+	FormatCsv  = "csv"
+)
+
+// csvHeader колонки CSV-файла; права хранятся в двух колонках через ';' - id ролей и их имена
+var csvHeader = []string{"employee_id", "login", "name", "active", "role_ids", "roles"}
+
+// FileSettings настройки файлового коннектора
+type FileSettings struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+// FileAccount учётная запись в файле коннектора
+type FileAccount struct {
+	EmployeeId   int64         `json:"employee_id"`
+	Login        *string       `json:"login"`
+	Name         string        `json:"name"`
+	Active       bool          `json:"active"`
+	Entitlements []Entitlement `json:"entitlements"`
+}
+
+// FileConnector эталонный коннектор для проверки выгрузки: хранит учётные записи в JSON или CSV файле
+// и переписывает его целиком при каждой операции. Отсутствующая запись создаётся любой операцией,
+// кроме отключения, поэтому операции можно безопасно повторять.
+type FileConnector struct {
+	settings FileSettings
+	mu       sync.Mutex
+}
+
+// NewFileConnector фабрика для Registry: настройки {"path": "...", "format": "json|csv"}, формат по умолчанию json
+func NewFileConnector(settings json.RawMessage) (Connector, error) {
+	if len(settings) == 0 {
+		return nil, errNoSettings
+	}
+	var s FileSettings
+	if err := json.Unmarshal(settings, &s); err != nil {
+		return nil, fmt.Errorf("invalid file connector settings: %w", err)
+	}
+	if s.Path == "" {
+		return nil, errors.New("file connector path is required")
+	}
+	if s.Format == "" {
+		s.Format = FormatJson
+	}
+	if s.Format != FormatJson && s.Format != FormatCsv {
+		return nil, fmt.Errorf("unsupported file connector format %q", s.Format)
+	}
+	return &FileConnector{settings: s}, nil
+}
+
+func (c *FileConnector) CreateAccount(_ context.Context, account Account) error {
+	return c.modify(account, true, func(a *FileAccount) {
+		a.Active = true
+	})
+}
+
+func (c *FileConnector) UpdateAccount(_ context.Context, account Account) error {
+	return c.modify(account, true, func(*FileAccount) {})
+}
+
+func (c *FileConnector) DisableAccount(_ context.Context, account Account) error {
+	return c.modify(account, false, func(a *FileAccount) {
+		a.Active = false
+	})
+}
+
+func (c *FileConnector) GrantEntitlement(_ context.Context, account Account, entitlement Entitlement) error {
+	return c.modify(account, true, func(a *FileAccount) {
+		a.Entitlements = slices.DeleteFunc(a.Entitlements, func(e Entitlement) bool { return e.RoleId == entitlement.RoleId })
+		a.Entitlements = append(a.Entitlements, entitlement)
+		sort.Slice(a.Entitlements, func(i, j int) bool { return a.Entitlements[i].RoleId < a.Entitlements[j].RoleId })
+	})
+}
+
+func (c *FileConnector) RevokeEntitlement(_ context.Context, account Account, entitlement Entitlement) error {
+	return c.modify(account, false, func(a *FileAccount) {
+		a.Entitlements = slices.DeleteFunc(a.Entitlements, func(e Entitlement) bool { return e.RoleId == entitlement.RoleId })
+	})
+}
+
+// Accounts прочитать все учётные записи файла, упорядоченные по id сотрудника
+func (c *FileConnector) Accounts() ([]FileAccount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load()
+}
+
+// modify применить change к записи сотрудника и сохранить файл; имя и логин обновляются из account.
+// Без create отсутствующая запись не создаётся и файл не меняется
+func (c *FileConnector) modify(account Account, create bool, change func(a *FileAccount)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	accounts, err := c.load()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(accounts, func(a FileAccount) bool { return a.EmployeeId == account.EmployeeId })
+	if i < 0 {
+		if !create {
+			return nil
+		}
+		accounts = append(accounts, FileAccount{EmployeeId: account.EmployeeId, Active: true, Entitlements: []Entitlement{}})
+		i = len(accounts) - 1
+	}
+	accounts[i].Name = account.Name
+	accounts[i].Login = account.Login
+	change(&accounts[i])
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].EmployeeId < accounts[j].EmployeeId })
+	return c.save(accounts)
+}
+
+func (c *FileConnector) load() ([]FileAccount, error) {
+	file, err := os.Open(c.settings.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []FileAccount{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", c.settings.Path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var accounts []FileAccount
+	if c.settings.Format == FormatCsv {
+		accounts, err = readCsv(file)
+	} else {
+		err = json.NewDecoder(file).Decode(&accounts)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading %s: %w", c.settings.Path, err)
+	}
+	return accounts, nil
+}
+
+// save записать файл целиком через временный файл, чтобы при сбое не остался наполовину записанный
+func (c *FileConnector) save(accounts []FileAccount) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.settings.Path), filepath.Base(c.settings.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file for %s: %w", c.settings.Path, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if c.settings.Format == FormatCsv {
+		err = writeCsv(tmp, accounts)
+	} else {
+		encoder := json.NewEncoder(tmp)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(accounts)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", c.settings.Path, err)
+	}
+	if err = os.Rename(tmp.Name(), c.settings.Path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", c.settings.Path, err)
+	}
+	return nil
+}
+
+func writeCsv(w io.Writer, accounts []FileAccount) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		login := ""
+		if a.Login != nil {
+			login = *a.Login
+		}
+		roleIds := make([]string, 0, len(a.Entitlements))
+		roles := make([]string, 0, len(a.Entitlements))
+		for _, e := range a.Entitlements {
+			roleIds = append(roleIds, strconv.FormatInt(e.RoleId, 10))
+			roles = append(roles, e.Name)
+		}
+		record := []string{
+			strconv.FormatInt(a.EmployeeId, 10), login, a.Name, strconv.FormatBool(a.Active),
+			strings.Join(roleIds, ";"), strings.Join(roles, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func readCsv(r io.Reader) ([]FileAccount, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]FileAccount, 0, len(records))
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		invalid := fmt.Errorf("invalid record on line %d", i+1)
+		a := FileAccount{Name: record[2], Entitlements: []Entitlement{}}
+		if a.EmployeeId, err = strconv.ParseInt(record[0], 10, 64); err != nil {
+			return nil, invalid
+		}
+		if record[1] != "" {
+			a.Login = &record[1]
+		}
+		if a.Active, err = strconv.ParseBool(record[3]); err != nil {
+			return nil, invalid
+		}
+		if record[4] != "" {
+			roleIds, roles := strings.Split(record[4], ";"), strings.Split(record[5], ";")
+			if len(roleIds) != len(roles) {
+				return nil, invalid
+			}
+			for j, raw := range roleIds {
+				roleId, err := strconv.ParseInt(raw, 10, 64)
+				if err != nil {
+					return nil, invalid
+				}
+				a.Entitlements = append(a.Entitlements, Entitlement{RoleId: roleId, Name: roles[j]})
+			}
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,139 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newFileConnector(t *testing.T, format string) (*FileConnector, string) {
+	path := filepath.Join(t.TempDir(), "accounts."+format)
+	settings, _ := json.Marshal(FileSettings{Path: path, Format: format})
+	connector, err := NewFileConnector(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return connector.(*FileConnector), path
+}
+
+func TestFileConnector(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	login := "alice@example.com"
+	alice := Account{EmployeeId: 1, Name: "Alice", Login: &login}
+	devs := Entitlement{RoleId: 4, Name: "devs"}
+	ops := Entitlement{RoleId: 5, Name: "ops"}
+
+	for _, format := range []string{FormatJson, FormatCsv} {
+		t.Run("should keep accounts and entitlements in "+format+" file", func(t *testing.T) {
+			connector, path := newFileConnector(t, format)
+
+			a.NoError(connector.CreateAccount(ctx, alice))
+			a.NoError(connector.GrantEntitlement(ctx, alice, ops))
+			a.NoError(connector.GrantEntitlement(ctx, alice, devs))
+			// повтор операции не меняет результат
+			a.NoError(connector.GrantEntitlement(ctx, alice, devs))
+			a.NoError(connector.CreateAccount(ctx, Account{EmployeeId: 2, Name: "Bob"}))
+			a.NoError(connector.RevokeEntitlement(ctx, alice, ops))
+			a.NoError(connector.DisableAccount(ctx, Account{EmployeeId: 2, Name: "Bob"}))
+
+			accounts, err := connector.Accounts()
+			a.NoError(err)
+			a.Equal([]FileAccount{
+				{EmployeeId: 1, Login: &login, Name: "Alice", Active: true, Entitlements: []Entitlement{devs}},
+				{EmployeeId: 2, Name: "Bob", Active: false, Entitlements: []Entitlement{}},
+			}, accounts)
+
+			_, err = os.Stat(path)
+			a.NoError(err)
+		})
+	}
+
+	t.Run("should write readable csv", func(t *testing.T) {
+		connector, path := newFileConnector(t, FormatCsv)
+		a.NoError(connector.GrantEntitlement(ctx, alice, devs))
+		a.NoError(connector.GrantEntitlement(ctx, alice, ops))
+
+		raw, err := os.ReadFile(path)
+		a.NoError(err)
+		a.Equal("employee_id,login,name,active,role_ids,roles\n1,alice@example.com,Alice,true,4;5,devs;ops\n", string(raw))
+	})
+
+	t.Run("should update name and re-enable account", func(t *testing.T) {
+		connector, _ := newFileConnector(t, FormatJson)
+		a.NoError(connector.CreateAccount(ctx, alice))
+		a.NoError(connector.DisableAccount(ctx, alice))
+		a.NoError(connector.UpdateAccount(ctx, Account{EmployeeId: 1, Name: "Alice Smith"}))
+		accounts, err := connector.Accounts()
+		a.NoError(err)
+		a.False(accounts[0].Active)
+		a.Equal("Alice Smith", accounts[0].Name)
+		a.Nil(accounts[0].Login)
+
+		a.NoError(connector.CreateAccount(ctx, alice))
+		accounts, err = connector.Accounts()
+		a.NoError(err)
+		a.True(accounts[0].Active)
+	})
+
+	t.Run("should not create account on disable or revoke", func(t *testing.T) {
+		connector, path := newFileConnector(t, FormatJson)
+		a.NoError(connector.DisableAccount(ctx, alice))
+		a.NoError(connector.RevokeEntitlement(ctx, alice, devs))
+		_, err := os.Stat(path)
+		a.True(os.IsNotExist(err))
+	})
+}
+
+func TestRegistryBuild(t *testing.T) {
+	a := assert.New(t)
+	registry := NewRegistry()
+	registry.Register(FileConnectorType, NewFileConnector)
+	settings := json.RawMessage(`{"path": "/tmp/accounts.json"}`)
+
+	t.Run("should build configured connectors", func(t *testing.T) {
+		connectors, err := registry.Build([]ConnectorConfig{
+			{Name: "hr-file", Type: FileConnectorType, Settings: settings},
+			{Name: "audit_csv", Type: FileConnectorType, Settings: json.RawMessage(`{"path": "/tmp/a.csv", "format": "csv"}`)},
+		})
+		a.NoError(err)
+		a.Equal([]string{"audit_csv", "hr-file"}, Names(connectors))
+	})
+
+	t.Run("should reject invalid configuration", func(t *testing.T) {
+		_, err := registry.Build([]ConnectorConfig{{Name: "hr", Type: "sap", Settings: settings}})
+		a.ErrorContains(err, `unknown type "sap"`)
+
+		_, err = registry.Build([]ConnectorConfig{
+			{Name: "hr", Type: FileConnectorType, Settings: settings},
+			{Name: "hr", Type: FileConnectorType, Settings: settings},
+		})
+		a.ErrorContains(err, "duplicate connector name")
+
+		_, err = registry.Build([]ConnectorConfig{{Name: "HR files", Type: FileConnectorType, Settings: settings}})
+		a.ErrorContains(err, "invalid connector name")
+
+		_, err = registry.Build([]ConnectorConfig{{Name: "hr", Type: FileConnectorType}})
+		a.ErrorContains(err, "settings are required")
+
+		_, err = registry.Build([]ConnectorConfig{
+			{Name: "hr", Type: FileConnectorType, Settings: json.RawMessage(`{"path": "/tmp/a", "format": "xml"}`)},
+		})
+		a.ErrorContains(err, "unsupported file connector format")
+	})
+
+	t.Run("should load connectors from config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "provisioning.json")
+		raw := `{"connectors": [{"name": "hr", "type": "file", "settings": {"path": "/tmp/hr.json"}}]}`
+		a.NoError(os.WriteFile(path, []byte(raw), 0o600))
+
+		configs, err := LoadConfig(path)
+		a.NoError(err)
+		a.Len(configs, 1)
+		a.Equal("hr", configs[0].Name)
+		a.JSONEq(`{"path": "/tmp/hr.json"}`, string(configs[0].Settings))
+	})
+}
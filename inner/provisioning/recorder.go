@@ -0,0 +1,233 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"time"
+)
+
+// Типы сущностей журнала аудита, изменения которых выгружаются во внешние системы
+const (
+	entityEmployee   = "employee"
+	entityAssignment = "assignment"
+)
+
+// Auditor журнал аудита, в который Recorder передаёт события дальше
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type RecorderRepo interface {
+	EnqueueTx(tx *sqlx.Tx, operations []Entity) error
+	FindAccountTx(tx *sqlx.Tx, employeeId int64) (Account, error)
+	FindEntitlementTx(tx *sqlx.Tx, roleId int64) (Entitlement, error)
+}
+
+// employeeSnapshot и assignmentSnapshot поля снимков сотрудника и назначения из журнала аудита
+type employeeSnapshot struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	RoleId    *int64     `json:"role_id"`
+	Login     *string    `json:"login"`
+	Status    string     `json:"status"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// enabled должна ли учётная запись сотрудника быть включена: удалённым, отстранённым и уволенным она отключается.
+// Сотруднику до выхода на работу запись готовится заранее.
+func (s *employeeSnapshot) enabled() bool {
+	return s.DeletedAt == nil && s.Status != "suspended" && s.Status != "terminated"
+}
+
+type assignmentSnapshot struct {
+	EmployeeId int64      `json:"employee_id"`
+	RoleId     int64      `json:"role_id"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ValidTo    *time.Time `json:"valid_to"`
+}
+
+// Recorder записывает событие в журнал аудита и ставит в очередь операции выгрузки, которые из него следуют,
+// по одной на каждый коннектор. Всё происходит в транзакции изменения: откатилось изменение - нет и операций.
+// Права соответствуют ролям, которыми сотрудник владеет сам (основная роль и назначения);
+// роли, включённые в них по иерархии, не выгружаются.
+type Recorder struct {
+	next       Auditor
+	repo       RecorderRepo
+	connectors []string
+}
+
+func NewRecorder(next Auditor, repo RecorderRepo, connectors []string) *Recorder {
+	return &Recorder{
+		next:       next,
+		repo:       repo,
+		connectors: connectors,
+	}
+}
+
+func (r *Recorder) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	if err := r.next.RecordTx(ctx, tx, event); err != nil {
+		return err
+	}
+	if len(r.connectors) == 0 {
+		return nil
+	}
+	var (
+		operations []Entity
+		err        error
+	)
+	switch event.EntityType {
+	case entityEmployee:
+		operations, err = r.employeeOperations(tx, event)
+	case entityAssignment:
+		operations, err = r.assignmentOperations(tx, event)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error preparing provisioning of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	fanned := make([]Entity, 0, len(operations)*len(r.connectors))
+	for _, connector := range r.connectors {
+		for _, o := range operations {
+			o.Connector = connector
+			fanned = append(fanned, o)
+		}
+	}
+	if err = r.repo.EnqueueTx(tx, fanned); err != nil {
+		return fmt.Errorf("error enqueueing provisioning of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	return nil
+}
+
+// employeeOperations создание - учётная запись и основная роль; удаление, отстранение и увольнение - отключение записи;
+// восстановление и возврат к работе - повторное включение; изменение - новые имя и логин,
+// отзыв прежней и выдача новой основной роли
+func (r *Recorder) employeeOperations(tx *sqlx.Tx, event audit.Event) ([]Entity, error) {
+	var before, after *employeeSnapshot
+	if err := decodeSnapshot(event.Before, &before); err != nil {
+		return nil, err
+	}
+	if err := decodeSnapshot(event.After, &after); err != nil {
+		return nil, err
+	}
+	if after == nil {
+		// окончательное удаление: учётная запись была отключена при мягком удалении
+		return nil, nil
+	}
+	now := time.Now()
+	account := Account{EmployeeId: after.Id, Name: after.Name, Login: after.Login}
+	var operations []Entity
+	switch {
+	case before == nil:
+		operations = append(operations, accountOperation(KindCreateAccount, account, now))
+	case !after.enabled() && before.enabled():
+		return []Entity{accountOperation(KindDisableAccount, account, now)}, nil
+	case after.enabled() && !before.enabled():
+		return []Entity{accountOperation(KindCreateAccount, account, now)}, nil
+	case !after.enabled():
+		return nil, nil
+	case before.Name != after.Name || !equalLogin(before.Login, after.Login):
+		operations = append(operations, accountOperation(KindUpdateAccount, account, now))
+	}
+	var beforeRole *int64
+	if before != nil {
+		beforeRole = before.RoleId
+	}
+	if equalRole(beforeRole, after.RoleId) {
+		return operations, nil
+	}
+	if beforeRole != nil {
+		o, err := r.entitlementOperation(tx, KindRevokeEntitlement, account, *beforeRole, now)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, o)
+	}
+	if after.RoleId != nil {
+		o, err := r.entitlementOperation(tx, KindGrantEntitlement, account, *after.RoleId, now)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, o)
+	}
+	return operations, nil
+}
+
+// assignmentOperations новое назначение выдаёт право с начала срока и, если срок ограничен, отзывает по его окончании;
+// досрочное завершение отзывает право сразу. Удаление будущего назначения операций не создаёт:
+// запланированная выдача будет пропущена, потому что сотрудник к её сроку ролью не владеет
+func (r *Recorder) assignmentOperations(tx *sqlx.Tx, event audit.Event) ([]Entity, error) {
+	var before, after *assignmentSnapshot
+	if err := decodeSnapshot(event.Before, &before); err != nil {
+		return nil, err
+	}
+	if err := decodeSnapshot(event.After, &after); err != nil {
+		return nil, err
+	}
+	if after == nil {
+		return nil, nil
+	}
+	account, err := r.repo.FindAccountTx(tx, after.EmployeeId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding employee %d: %w", after.EmployeeId, err)
+	}
+	var operations []Entity
+	if before == nil {
+		grant, err := r.entitlementOperation(tx, KindGrantEntitlement, account, after.RoleId, after.ValidFrom)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, grant)
+	}
+	if after.ValidTo != nil && (before == nil || before.ValidTo == nil || !before.ValidTo.Equal(*after.ValidTo)) {
+		revoke, err := r.entitlementOperation(tx, KindRevokeEntitlement, account, after.RoleId, *after.ValidTo)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, revoke)
+	}
+	return operations, nil
+}
+
+func (r *Recorder) entitlementOperation(tx *sqlx.Tx, kind string, account Account, roleId int64, at time.Time) (Entity, error) {
+	entitlement, err := r.repo.FindEntitlementTx(tx, roleId)
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding role %d: %w", roleId, err)
+	}
+	payload, err := json.Marshal(Payload{Account: account, Entitlement: &entitlement})
+	if err != nil {
+		return Entity{}, err
+	}
+	return Entity{Kind: kind, EmployeeId: account.EmployeeId, RoleId: &roleId, Payload: payload, ScheduledAt: at}, nil
+}
+
+func accountOperation(kind string, account Account, at time.Time) Entity {
+	payload, _ := json.Marshal(Payload{Account: account})
+	return Entity{Kind: kind, EmployeeId: account.EmployeeId, Payload: payload, ScheduledAt: at}
+}
+
+// decodeSnapshot перевести снимок из события (структуру пакета сущности) в target через JSON; nil оставляет target nil
+func decodeSnapshot(snapshot any, target any) error {
+	if snapshot == nil {
+		return nil
+	}
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+func equalLogin(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalRole(a, b *int64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,250 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"testing"
+	"time"
+)
+
+type MockAuditor struct {
+	mock.Mock
+}
+
+func (m *MockAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+type MockRecorderRepo struct {
+	mock.Mock
+	enqueued []Entity
+}
+
+func (m *MockRecorderRepo) EnqueueTx(tx *sqlx.Tx, operations []Entity) error {
+	m.enqueued = append(m.enqueued, operations...)
+	args := m.Called(len(operations))
+	return args.Error(0)
+}
+
+func (m *MockRecorderRepo) FindAccountTx(tx *sqlx.Tx, employeeId int64) (Account, error) {
+	args := m.Called(employeeId)
+	return args.Get(0).(Account), args.Error(1)
+}
+
+func (m *MockRecorderRepo) FindEntitlementTx(tx *sqlx.Tx, roleId int64) (Entitlement, error) {
+	args := m.Called(roleId)
+	return args.Get(0).(Entitlement), args.Error(1)
+}
+
+// operation вид, коннектор и данные операции без времени, для сравнения в тестах
+type operation struct {
+	Connector string
+	Kind      string
+	Payload   Payload
+}
+
+func operationsOf(t *testing.T, entities []Entity) []operation {
+	operations := make([]operation, 0, len(entities))
+	for _, e := range entities {
+		payload, err := e.payload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		operations = append(operations, operation{Connector: e.Connector, Kind: e.Kind, Payload: payload})
+	}
+	return operations
+}
+
+func TestRecorderRecordTx(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	login := "alice@example.com"
+	roleId := int64(4)
+	devs := Entitlement{RoleId: 4, Name: "devs"}
+	alice := Account{EmployeeId: 1, Name: "Alice", Login: &login}
+
+	t.Run("should create account and grant primary role in every connector", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr", "ldap"})
+		event := audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: "employee",
+			EntityId:   1,
+			After:      employeeSnapshot{Id: 1, Name: "Alice", Login: &login, RoleId: &roleId},
+		}
+		auditor.On("RecordTx", event).Return(nil)
+		repo.On("FindEntitlementTx", int64(4)).Return(devs, nil)
+		repo.On("EnqueueTx", 4).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, event))
+		a.Equal([]operation{
+			{Connector: "hr", Kind: KindCreateAccount, Payload: Payload{Account: alice}},
+			{Connector: "hr", Kind: KindGrantEntitlement, Payload: Payload{Account: alice, Entitlement: &devs}},
+			{Connector: "ldap", Kind: KindCreateAccount, Payload: Payload{Account: alice}},
+			{Connector: "ldap", Kind: KindGrantEntitlement, Payload: Payload{Account: alice, Entitlement: &devs}},
+		}, operationsOf(t, repo.enqueued))
+	})
+
+	t.Run("should update account and replace primary role", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr"})
+		otherRole := int64(5)
+		event := audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: "employee",
+			EntityId:   1,
+			Before:     employeeSnapshot{Id: 1, Name: "Alicia", Login: &login, RoleId: &otherRole},
+			After:      employeeSnapshot{Id: 1, Name: "Alice", Login: &login, RoleId: &roleId},
+		}
+		auditor.On("RecordTx", event).Return(nil)
+		ops := Entitlement{RoleId: 5, Name: "ops"}
+		repo.On("FindEntitlementTx", int64(5)).Return(ops, nil)
+		repo.On("FindEntitlementTx", int64(4)).Return(devs, nil)
+		repo.On("EnqueueTx", 3).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, event))
+		a.Equal([]operation{
+			{Connector: "hr", Kind: KindUpdateAccount, Payload: Payload{Account: alice}},
+			{Connector: "hr", Kind: KindRevokeEntitlement, Payload: Payload{Account: alice, Entitlement: &ops}},
+			{Connector: "hr", Kind: KindGrantEntitlement, Payload: Payload{Account: alice, Entitlement: &devs}},
+		}, operationsOf(t, repo.enqueued))
+	})
+
+	t.Run("should disable account on delete and enable on restore", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr"})
+		deletedAt := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+		active := employeeSnapshot{Id: 1, Name: "Alice", Login: &login, RoleId: &roleId}
+		deleted := active
+		deleted.DeletedAt = &deletedAt
+		auditor.On("RecordTx", mock.Anything).Return(nil)
+		repo.On("EnqueueTx", 1).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionDelete, EntityType: "employee", EntityId: 1, Before: active, After: deleted,
+		}))
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionRestore, EntityType: "employee", EntityId: 1, Before: deleted, After: active,
+		}))
+		a.Equal([]operation{
+			{Connector: "hr", Kind: KindDisableAccount, Payload: Payload{Account: alice}},
+			{Connector: "hr", Kind: KindCreateAccount, Payload: Payload{Account: alice}},
+		}, operationsOf(t, repo.enqueued))
+	})
+
+	t.Run("should disable account on suspension and enable on resumption", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr"})
+		active := employeeSnapshot{Id: 1, Name: "Alice", Login: &login, RoleId: &roleId, Status: "active"}
+		suspended := active
+		suspended.Status = "suspended"
+		terminated := active
+		terminated.Status = "terminated"
+		terminated.RoleId = nil
+		auditor.On("RecordTx", mock.Anything).Return(nil)
+		repo.On("EnqueueTx", 1).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: "suspend", EntityType: "employee", EntityId: 1, Before: active, After: suspended,
+		}))
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: "resume", EntityType: "employee", EntityId: 1, Before: suspended, After: active,
+		}))
+		// учётная запись отстранённого сотрудника уже отключена
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: "terminate", EntityType: "employee", EntityId: 1, Before: suspended, After: terminated,
+		}))
+		a.Equal([]operation{
+			{Connector: "hr", Kind: KindDisableAccount, Payload: Payload{Account: alice}},
+			{Connector: "hr", Kind: KindCreateAccount, Payload: Payload{Account: alice}},
+		}, operationsOf(t, repo.enqueued))
+	})
+
+	t.Run("should schedule grant and revoke by assignment validity", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr"})
+		validFrom := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+		validTo := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+		auditor.On("RecordTx", mock.Anything).Return(nil)
+		repo.On("FindAccountTx", int64(1)).Return(alice, nil)
+		repo.On("FindEntitlementTx", int64(4)).Return(devs, nil)
+		repo.On("EnqueueTx", 2).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: "assignment",
+			EntityId:   10,
+			After:      assignmentSnapshot{EmployeeId: 1, RoleId: 4, ValidFrom: validFrom, ValidTo: &validTo},
+		}))
+		a.Len(repo.enqueued, 2)
+		a.Equal(KindGrantEntitlement, repo.enqueued[0].Kind)
+		a.True(validFrom.Equal(repo.enqueued[0].ScheduledAt))
+		a.Equal(KindRevokeEntitlement, repo.enqueued[1].Kind)
+		a.True(validTo.Equal(repo.enqueued[1].ScheduledAt))
+		a.Equal(&roleId, repo.enqueued[1].RoleId)
+	})
+
+	t.Run("should revoke when assignment ends early and ignore deleted future assignment", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		recorder := NewRecorder(auditor, repo, []string{"hr"})
+		validFrom := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+		now := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+		before := assignmentSnapshot{EmployeeId: 1, RoleId: 4, ValidFrom: validFrom}
+		after := before
+		after.ValidTo = &now
+		auditor.On("RecordTx", mock.Anything).Return(nil)
+		repo.On("FindAccountTx", int64(1)).Return(alice, nil)
+		repo.On("FindEntitlementTx", int64(4)).Return(devs, nil)
+		repo.On("EnqueueTx", 1).Return(nil)
+
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionUpdate, EntityType: "assignment", EntityId: 10, Before: before, After: after,
+		}))
+		a.NoError(recorder.RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionDelete, EntityType: "assignment", EntityId: 11, Before: before,
+		}))
+		a.Equal([]operation{
+			{Connector: "hr", Kind: KindRevokeEntitlement, Payload: Payload{Account: alice, Entitlement: &devs}},
+		}, operationsOf(t, repo.enqueued))
+		a.True(repo.AssertNumberOfCalls(t, "EnqueueTx", 1))
+	})
+
+	t.Run("should only record audit without connectors or for other entities", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		auditor.On("RecordTx", mock.Anything).Return(nil)
+
+		a.NoError(NewRecorder(auditor, repo, nil).RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionCreate, EntityType: "employee", EntityId: 1, After: employeeSnapshot{Id: 1, Name: "Alice"},
+		}))
+		a.NoError(NewRecorder(auditor, repo, []string{"hr"}).RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionCreate, EntityType: "role", EntityId: 4, After: devs,
+		}))
+		a.True(auditor.AssertNumberOfCalls(t, "RecordTx", 2))
+		a.True(repo.AssertNotCalled(t, "EnqueueTx", mock.Anything))
+	})
+
+	t.Run("should not enqueue when audit fails", func(t *testing.T) {
+		auditor := new(MockAuditor)
+		repo := new(MockRecorderRepo)
+		auditErr := errors.New("database error")
+		auditor.On("RecordTx", mock.Anything).Return(auditErr)
+
+		err := NewRecorder(auditor, repo, []string{"hr"}).RecordTx(context.Background(), noTx, audit.Event{
+			Action: audit.ActionCreate, EntityType: "employee", EntityId: 1, After: employeeSnapshot{Id: 1, Name: "Alice"},
+		})
+		a.ErrorIs(err, auditErr)
+		a.True(repo.AssertNotCalled(t, "EnqueueTx", mock.Anything))
+	})
+}
@@ -0,0 +1,140 @@
+package provisioning
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+// EnqueueTx поставить операции в очередь в транзакции изменения, которое их породило
+func (r *Repository) EnqueueTx(tx *sqlx.Tx, operations []Entity) error {
+	query := `insert into provisioning_operation (connector, kind, employee_id, role_id, payload, scheduled_at, next_attempt_at)
+		values ($1, $2, $3, $4, $5, $6, $6)`
+	for _, o := range operations {
+		if _, err := tx.Exec(query, o.Connector, o.Kind, o.EmployeeId, o.RoleId, o.Payload, o.ScheduledAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAccountTx учётная запись сотрудника, в том числе мягко удалённого
+func (r *Repository) FindAccountTx(tx *sqlx.Tx, employeeId int64) (account Account, err error) {
+	query := "select id, name, login from employee where id = $1"
+	err = tx.QueryRowx(query, employeeId).Scan(&account.EmployeeId, &account.Name, &account.Login)
+	return account, err
+}
+
+// FindEntitlementTx право, соответствующее роли, в том числе мягко удалённой
+func (r *Repository) FindEntitlementTx(tx *sqlx.Tx, roleId int64) (entitlement Entitlement, err error) {
+	query := "select id, name from role where id = $1"
+	err = tx.QueryRowx(query, roleId).Scan(&entitlement.RoleId, &entitlement.Name)
+	return entitlement, err
+}
+
+// Claim взять до limit операций коннекторов connectors, которые пора выполнять, и отложить их
+// до leaseUntil: если обработчик упадёт, операции вернутся в работу после этого момента.
+// Операция не берётся, пока у того же сотрудника в том же коннекторе есть более ранняя незавершённая
+// операция, срок которой уже наступил, - так права не выдаются и не отзываются в обратном порядке.
+// Параллельные обработчики пропускают строки, заблокированные друг другом.
+func (r *Repository) Claim(connectors []string, now, leaseUntil time.Time, limit int) (claimed []Entity, err error) {
+	query := `update provisioning_operation set next_attempt_at = $3
+		where id in (
+			select o.id from provisioning_operation o
+			where o.status = 'pending' and o.next_attempt_at <= $2 and o.connector = any($1)
+				and not exists (
+					select 1 from provisioning_operation p
+					where p.connector = o.connector and p.employee_id = o.employee_id and p.id < o.id
+						and p.status = 'pending' and p.scheduled_at <= $2
+				)
+			order by o.id
+			limit $4
+			for update skip locked
+		)
+		returning *`
+	err = r.db.Select(&claimed, query, pq.Array(connectors), now, leaseUntil, limit)
+	return claimed, err
+}
+
+// HoldsRole владеет ли действующий сотрудник ролью в момент at: как основной или по назначению
+func (r *Repository) HoldsRole(employeeId, roleId int64, at time.Time) (holds bool, err error) {
+	query := `select exists (
+		select 1 from employee e
+		where e.id = $1 and e.deleted_at is null and (
+			e.role_id = $2 or exists (
+				select 1 from employee_role a
+				where a.employee_id = e.id and a.role_id = $2 and a.valid_from <= $3 and (a.valid_to is null or a.valid_to > $3)
+			)
+		)
+	)`
+	err = r.db.Get(&holds, query, employeeId, roleId, at)
+	return holds, err
+}
+
+// Complete сохранить результат попытки: состояние, число попыток, время следующей попытки и ошибку
+func (r *Repository) Complete(o Entity) error {
+	query := `update provisioning_operation set status = $2, attempts = $3, next_attempt_at = $4, last_error = $5
+		where id = $1`
+	_, err := r.db.Exec(query, o.Id, o.Status, o.Attempts, o.NextAttemptAt, o.LastError)
+	return err
+}
+
+// Retry вернуть неудавшуюся операцию в очередь с нулевым счётчиком попыток;
+// операция в другом состоянии не найдётся (sql.ErrNoRows)
+func (r *Repository) Retry(id int64, at time.Time) (retried Entity, err error) {
+	query := `update provisioning_operation set status = 'pending', attempts = 0, next_attempt_at = $2
+		where id = $1 and status = 'failed' returning *`
+	err = r.db.Get(&retried, query, id, at)
+	return retried, err
+}
+
+func (r *Repository) FindById(id int64) (operation Entity, err error) {
+	query := "select * from provisioning_operation where id = $1"
+	err = r.db.Get(&operation, query, id)
+	return operation, err
+}
+
+// FindPage найти страницу очереди по фильтру; возвращает до Limit+1 операций,
+// лишняя операция означает, что есть следующая страница
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (operations []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	query := "select * from provisioning_operation" + conditions.Where() + order
+	err = r.db.Select(&operations, query, conditions.Args()...)
+	return operations, err
+}
+
+// Count количество операций, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from provisioning_operation" + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.Connector != "" {
+		conditions.Add("connector = ?", request.Connector)
+	}
+	if request.Status != "" {
+		conditions.Add("status = ?", request.Status)
+	}
+	if request.Kind != "" {
+		conditions.Add("kind = ?", request.Kind)
+	}
+	if request.EmployeeId != nil {
+		conditions.Add("employee_id = ?", *request.EmployeeId)
+	}
+	return conditions
+}
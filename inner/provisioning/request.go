@@ -0,0 +1,16 @@
+package provisioning
+
+import "idm/inner/common"
+
+// ListRequest фильтры очереди выгрузки. Операции всегда упорядочены по id, по умолчанию от новых к старым.
+type ListRequest struct {
+	common.PageRequest
+	Connector  string `json:"connector" validate:"max=50"`
+	Status     string `json:"status" validate:"omitempty,oneof=pending succeeded failed skipped"`
+	Kind       string `json:"kind" validate:"omitempty,oneof=create_account update_account disable_account grant_entitlement revoke_entitlement"`
+	EmployeeId *int64 `json:"employee_id" validate:"omitempty,gt=0"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
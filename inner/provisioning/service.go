@@ -0,0 +1,108 @@
+package provisioning
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"strconv"
+	"time"
+)
+
+type Service struct {
+	repo      Repo
+	validator Validator
+}
+
+type Repo interface {
+	FindById(id int64) (Entity, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
+	Retry(id int64, at time.Time) (Entity, error)
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		validator: validator,
+	}
+}
+
+// FindAll найти страницу очереди выгрузки по фильтру
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Sort = "id"
+	if request.Order == "" {
+		request.Order = "desc"
+	}
+	request.Defaults()
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	operations, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error retrieving provisioning operations: %w", err)
+	}
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting provisioning operations: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(operations) > request.Limit {
+		operations = operations[:request.Limit]
+		last := operations[len(operations)-1]
+		page.NextCursor = request.Next(strconv.FormatInt(last.Id, 10), last.Id)
+	}
+	page.Items = make([]Response, 0, len(operations))
+	for _, operation := range operations {
+		page.Items = append(page.Items, operation.toResponse())
+	}
+	return page, nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	operation, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding provisioning operation with id %d: %v", request.Id, err),
+		}
+	}
+	return operation.toResponse(), nil
+}
+
+// Retry вернуть неудавшуюся операцию в очередь: она будет выполнена при следующем проходе обработчика
+// с новым запасом попыток. Повторить можно только операцию в состоянии failed.
+func (svc *Service) Retry(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	retried, err := svc.repo.Retry(request.Id, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		operation, findErr := svc.repo.FindById(request.Id)
+		if findErr != nil {
+			return Response{}, common.NotFoundError{
+				Message: fmt.Sprintf("provisioning operation with id %d not found", request.Id),
+			}
+		}
+		return Response{}, common.RequestValidationError{
+			Message: fmt.Sprintf("provisioning operation %d is %s, only failed operations can be retried", request.Id, operation.Status),
+		}
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("error retrying provisioning operation with id %d: %w", request.Id, err)
+	}
+	return retried.toResponse(), nil
+}
@@ -0,0 +1,110 @@
+package provisioning
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) Retry(id int64, at time.Time) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func TestServiceFindAll(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return newest operations first with cursor of next page", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+		request := ListRequest{
+			PageRequest: common.PageRequest{Limit: 2, Sort: "id", Order: "desc"},
+			Status:      StatusFailed,
+		}
+		repo.On("FindPage", request, (*common.Cursor)(nil)).Return([]Entity{
+			queued(9, "hr", KindCreateAccount, 1, nil, 8),
+			queued(7, "hr", KindUpdateAccount, 1, nil, 8),
+			queued(3, "hr", KindDisableAccount, 2, nil, 8),
+		}, nil)
+		repo.On("Count", request).Return(int64(3), nil)
+
+		page, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Limit: 2}, Status: StatusFailed})
+		a.NoError(err)
+		a.Len(page.Items, 2)
+		a.Equal(int64(7), page.Items[1].Id)
+		a.Equal("Alice", page.Items[0].Account.Name)
+		a.NotEmpty(page.NextCursor)
+		a.Equal(int64(3), page.Total)
+	})
+
+	t.Run("should reject unknown status", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+
+		_, err := svc.FindAll(ListRequest{Status: "done"})
+		a.True(errors.As(err, &common.RequestValidationError{}))
+		a.True(repo.AssertNotCalled(t, "FindPage", mock.Anything, mock.Anything))
+	})
+}
+
+func TestServiceRetry(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return failed operation to queue", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+		repo.On("Retry", int64(5)).Return(queued(5, "hr", KindCreateAccount, 1, nil, 0), nil)
+
+		response, err := svc.Retry(IdRequest{Id: 5})
+		a.NoError(err)
+		a.Equal(StatusPending, response.Status)
+		a.NotNil(response.NextAttemptAt)
+	})
+
+	t.Run("should reject operation that has not failed", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+		succeeded := queued(5, "hr", KindCreateAccount, 1, nil, 1)
+		succeeded.Status = StatusSucceeded
+		repo.On("Retry", int64(5)).Return(Entity{}, sql.ErrNoRows)
+		repo.On("FindById", int64(5)).Return(succeeded, nil)
+
+		_, err := svc.Retry(IdRequest{Id: 5})
+		a.True(errors.As(err, &common.RequestValidationError{}))
+		a.ErrorContains(err, "provisioning operation 5 is succeeded")
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, validator.New())
+		repo.On("Retry", int64(5)).Return(Entity{}, sql.ErrNoRows)
+		repo.On("FindById", int64(5)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Retry(IdRequest{Id: 5})
+		a.True(errors.As(err, &common.NotFoundError{}))
+	})
+}
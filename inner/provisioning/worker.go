@@ -0,0 +1,167 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"time"
+)
+
+// Параметры выборки операций обработчиком
+const (
+	claimLimit = 100
+	// claimLease на сколько откладывается взятая операция: после сбоя обработчика она вернётся в работу
+	claimLease = 5 * time.Minute
+)
+
+type WorkerRepo interface {
+	Claim(connectors []string, now, leaseUntil time.Time, limit int) ([]Entity, error)
+	HoldsRole(employeeId, roleId int64, at time.Time) (bool, error)
+	Complete(operation Entity) error
+}
+
+// Worker выполняет операции очереди через коннекторы
+type Worker struct {
+	repo       WorkerRepo
+	connectors map[string]Connector
+	names      []string
+	policy     common.RetryPolicy
+	logger     *common.Logger
+}
+
+func NewWorker(repo WorkerRepo, connectors map[string]Connector, policy common.RetryPolicy, logger *common.Logger) *Worker {
+	return &Worker{
+		repo:       repo,
+		connectors: connectors,
+		names:      Names(connectors),
+		policy:     policy,
+		logger:     logger,
+	}
+}
+
+// ProcessOnce выполнить операции, которые пора выполнять на момент now, и вернуть число обработанных.
+// Если операция не удалась, следующие операции того же сотрудника в том же коннекторе откладываются
+// до её повтора, чтобы сохранить порядок изменений.
+func (w *Worker) ProcessOnce(ctx context.Context, now time.Time) (int, error) {
+	processed := 0
+	for {
+		claimed, err := w.repo.Claim(w.names, now, now.Add(claimLease), claimLimit)
+		if err != nil {
+			return processed, fmt.Errorf("error claiming provisioning operations: %w", err)
+		}
+		var errs []error
+		blocked := map[string]bool{}
+		for _, o := range claimed {
+			key := fmt.Sprintf("%s/%d", o.Connector, o.EmployeeId)
+			if blocked[key] {
+				o.NextAttemptAt = now
+			} else {
+				w.execute(ctx, &o, now)
+				processed++
+				if o.Status == StatusPending {
+					blocked[key] = true
+				}
+			}
+			if err = w.repo.Complete(o); err != nil {
+				errs = append(errs, fmt.Errorf("error saving provisioning operation %d: %w", o.Id, err))
+			}
+		}
+		if len(errs) > 0 || len(claimed) < claimLimit {
+			return processed, errors.Join(errs...)
+		}
+	}
+}
+
+// execute выполнить операцию и записать в неё результат; ошибка коннектора планирует повтор
+func (w *Worker) execute(ctx context.Context, o *Entity, now time.Time) {
+	err := w.call(ctx, o, now)
+	if errors.Is(err, errSkipped) {
+		o.Status = StatusSkipped
+		o.LastError = nil
+		return
+	}
+	if err == nil {
+		o.Status = StatusSucceeded
+		o.LastError = nil
+		return
+	}
+	o.Attempts++
+	message := err.Error()
+	o.LastError = &message
+	if o.Attempts >= w.policy.MaxAttempts {
+		o.Status = StatusFailed
+		w.logger.Error("provisioning: operation failed",
+			zap.Int64("id", o.Id), zap.String("connector", o.Connector), zap.String("kind", o.Kind), zap.Error(err))
+		return
+	}
+	o.NextAttemptAt = now.Add(w.policy.Delay(o.Attempts))
+	w.logger.Warn("provisioning: operation will be retried",
+		zap.Int64("id", o.Id), zap.String("connector", o.Connector), zap.String("kind", o.Kind),
+		zap.Int("attempts", o.Attempts), zap.Time("next_attempt_at", o.NextAttemptAt), zap.Error(err))
+}
+
+// errSkipped выдача или отзыв права к моменту выполнения не нужны
+var errSkipped = errors.New("skipped")
+
+func (w *Worker) call(ctx context.Context, o *Entity, now time.Time) error {
+	connector, ok := w.connectors[o.Connector]
+	if !ok {
+		return fmt.Errorf("connector %s is not configured", o.Connector)
+	}
+	payload, err := o.payload()
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	switch o.Kind {
+	case KindCreateAccount:
+		return connector.CreateAccount(ctx, payload.Account)
+	case KindUpdateAccount:
+		return connector.UpdateAccount(ctx, payload.Account)
+	case KindDisableAccount:
+		return connector.DisableAccount(ctx, payload.Account)
+	case KindGrantEntitlement, KindRevokeEntitlement:
+		if payload.Entitlement == nil {
+			return errors.New("invalid payload: no entitlement")
+		}
+		// право выгружается по состоянию на момент выполнения: за время ожидания в очереди
+		// назначение могли отменить или роль могла остаться у сотрудника по другому назначению
+		holds, err := w.repo.HoldsRole(o.EmployeeId, payload.Entitlement.RoleId, now)
+		if err != nil {
+			return fmt.Errorf("error checking role %d of employee %d: %w", payload.Entitlement.RoleId, o.EmployeeId, err)
+		}
+		if o.Kind == KindGrantEntitlement {
+			if !holds {
+				return errSkipped
+			}
+			return connector.GrantEntitlement(ctx, payload.Account, *payload.Entitlement)
+		}
+		if holds {
+			return errSkipped
+		}
+		return connector.RevokeEntitlement(ctx, payload.Account, *payload.Entitlement)
+	default:
+		return fmt.Errorf("unknown operation kind %q", o.Kind)
+	}
+}
+
+// Run обрабатывать очередь сразу и затем каждые interval, пока не отменён ctx
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		processed, err := w.ProcessOnce(ctx, time.Now())
+		if err != nil {
+			w.logger.Error("provisioning: processing failed", zap.Error(err))
+		}
+		if processed > 0 {
+			w.logger.Info("provisioning: operations processed", zap.Int("count", processed))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type MockWorkerRepo struct {
+	mock.Mock
+	completed []Entity
+}
+
+func (m *MockWorkerRepo) Claim(connectors []string, now, leaseUntil time.Time, limit int) ([]Entity, error) {
+	args := m.Called(connectors, now, leaseUntil, limit)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockWorkerRepo) HoldsRole(employeeId, roleId int64, at time.Time) (bool, error) {
+	args := m.Called(employeeId, roleId, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWorkerRepo) Complete(operation Entity) error {
+	m.completed = append(m.completed, operation)
+	args := m.Called(operation.Id)
+	return args.Error(0)
+}
+
+// StubConnector запоминает вызовы вида "kind employee_id[ role_id]" и возвращает err
+type StubConnector struct {
+	calls []string
+	err   error
+}
+
+func (c *StubConnector) record(kind string, account Account, entitlement *Entitlement) error {
+	call := kind + " " + strconv.FormatInt(account.EmployeeId, 10)
+	if entitlement != nil {
+		call += " " + strconv.FormatInt(entitlement.RoleId, 10)
+	}
+	c.calls = append(c.calls, call)
+	return c.err
+}
+
+func (c *StubConnector) CreateAccount(_ context.Context, account Account) error {
+	return c.record(KindCreateAccount, account, nil)
+}
+
+func (c *StubConnector) UpdateAccount(_ context.Context, account Account) error {
+	return c.record(KindUpdateAccount, account, nil)
+}
+
+func (c *StubConnector) DisableAccount(_ context.Context, account Account) error {
+	return c.record(KindDisableAccount, account, nil)
+}
+
+func (c *StubConnector) GrantEntitlement(_ context.Context, account Account, entitlement Entitlement) error {
+	return c.record(KindGrantEntitlement, account, &entitlement)
+}
+
+func (c *StubConnector) RevokeEntitlement(_ context.Context, account Account, entitlement Entitlement) error {
+	return c.record(KindRevokeEntitlement, account, &entitlement)
+}
+
+func queued(id int64, connector, kind string, employeeId int64, entitlement *Entitlement, attempts int) Entity {
+	payload, _ := json.Marshal(Payload{Account: Account{EmployeeId: employeeId, Name: "Alice"}, Entitlement: entitlement})
+	return Entity{
+		Id:         id,
+		Connector:  connector,
+		Kind:       kind,
+		EmployeeId: employeeId,
+		Payload:    payload,
+		Status:     StatusPending,
+		Attempts:   attempts,
+	}
+}
+
+func TestWorkerProcessOnce(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+	now := time.Date(2025, 9, 5, 12, 0, 0, 0, time.UTC)
+	policy := common.RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour}
+	devs := &Entitlement{RoleId: 4, Name: "devs"}
+
+	t.Run("should execute operations through their connectors", func(t *testing.T) {
+		repo := new(MockWorkerRepo)
+		hr, crm := &StubConnector{}, &StubConnector{}
+		worker := NewWorker(repo, map[string]Connector{"hr": hr, "crm": crm}, policy, logger)
+		repo.On("Claim", []string{"crm", "hr"}, now, now.Add(claimLease), claimLimit).Return([]Entity{
+			queued(1, "hr", KindCreateAccount, 1, nil, 0),
+			queued(2, "crm", KindCreateAccount, 1, nil, 0),
+			queued(3, "hr", KindGrantEntitlement, 1, devs, 0),
+			queued(4, "hr", KindDisableAccount, 2, nil, 0),
+		}, nil)
+		repo.On("HoldsRole", int64(1), int64(4), now).Return(true, nil)
+		repo.On("Complete", mock.Anything).Return(nil)
+
+		processed, err := worker.ProcessOnce(context.Background(), now)
+		a.NoError(err)
+		a.Equal(4, processed)
+		a.Equal([]string{"create_account 1", "grant_entitlement 1 4", "disable_account 2"}, hr.calls)
+		a.Equal([]string{"create_account 1"}, crm.calls)
+		for _, o := range repo.completed {
+			a.Equal(StatusSucceeded, o.Status)
+		}
+	})
+
+	t.Run("should skip grant of role that is no longer held and revoke of role still held", func(t *testing.T) {
+		repo := new(MockWorkerRepo)
+		hr := &StubConnector{}
+		worker := NewWorker(repo, map[string]Connector{"hr": hr}, policy, logger)
+		repo.On("Claim", []string{"hr"}, now, now.Add(claimLease), claimLimit).Return([]Entity{
+			queued(1, "hr", KindGrantEntitlement, 1, devs, 0),
+			queued(2, "hr", KindRevokeEntitlement, 2, devs, 0),
+		}, nil)
+		repo.On("HoldsRole", int64(1), int64(4), now).Return(false, nil)
+		repo.On("HoldsRole", int64(2), int64(4), now).Return(true, nil)
+		repo.On("Complete", mock.Anything).Return(nil)
+
+		_, err := worker.ProcessOnce(context.Background(), now)
+		a.NoError(err)
+		a.Empty(hr.calls)
+		a.Equal(StatusSkipped, repo.completed[0].Status)
+		a.Equal(StatusSkipped, repo.completed[1].Status)
+	})
+
+	t.Run("should retry with backoff and hold later operations of the same employee", func(t *testing.T) {
+		repo := new(MockWorkerRepo)
+		hr := &StubConnector{err: errors.New("target unavailable")}
+		worker := NewWorker(repo, map[string]Connector{"hr": hr}, policy, logger)
+		repo.On("Claim", []string{"hr"}, now, now.Add(claimLease), claimLimit).Return([]Entity{
+			queued(1, "hr", KindCreateAccount, 1, nil, 1),
+			queued(2, "hr", KindUpdateAccount, 1, nil, 0),
+		}, nil)
+		repo.On("Complete", mock.Anything).Return(nil)
+
+		processed, err := worker.ProcessOnce(context.Background(), now)
+		a.NoError(err)
+		a.Equal(1, processed)
+		a.Equal([]string{"create_account 1"}, hr.calls)
+
+		failed := repo.completed[0]
+		a.Equal(StatusPending, failed.Status)
+		a.Equal(2, failed.Attempts)
+		a.Equal(now.Add(2*time.Minute), failed.NextAttemptAt)
+		a.Equal("target unavailable", *failed.LastError)
+
+		held := repo.completed[1]
+		a.Equal(StatusPending, held.Status)
+		a.Equal(0, held.Attempts)
+		a.Equal(now, held.NextAttemptAt)
+	})
+
+	t.Run("should fail operation after last attempt", func(t *testing.T) {
+		repo := new(MockWorkerRepo)
+		hr := &StubConnector{err: errors.New("target unavailable")}
+		worker := NewWorker(repo, map[string]Connector{"hr": hr}, policy, logger)
+		repo.On("Claim", []string{"hr"}, now, now.Add(claimLease), claimLimit).Return([]Entity{
+			queued(1, "hr", KindCreateAccount, 1, nil, 2),
+		}, nil)
+		repo.On("Complete", mock.Anything).Return(nil)
+
+		_, err := worker.ProcessOnce(context.Background(), now)
+		a.NoError(err)
+		a.Equal(StatusFailed, repo.completed[0].Status)
+		a.Equal(3, repo.completed[0].Attempts)
+	})
+
+	t.Run("should return error when queue is unavailable", func(t *testing.T) {
+		repo := new(MockWorkerRepo)
+		worker := NewWorker(repo, map[string]Connector{"hr": &StubConnector{}}, policy, logger)
+		repo.On("Claim", mock.Anything, now, mock.Anything, mock.Anything).Return([]Entity{}, errors.New("database error"))
+
+		_, err := worker.ProcessOnce(context.Background(), now)
+		a.ErrorContains(err, "database error")
+	})
+}
@@ -0,0 +1,68 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"time"
+)
+
+// Repo хранилище, из которого окончательно удаляются мягко удалённые записи
+type Repo interface {
+	Purge(before time.Time) (int64, error)
+}
+
+// Target таблица для очистки; Name используется только в логах и ошибках
+type Target struct {
+	Name string
+	Repo Repo
+}
+
+// Purger окончательно удаляет записи, мягко удалённые раньше срока хранения
+type Purger struct {
+	targets   []Target
+	retention time.Duration
+	logger    *common.Logger
+}
+
+func NewPurger(retention time.Duration, logger *common.Logger, targets ...Target) *Purger {
+	return &Purger{
+		targets:   targets,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// PurgeOnce удалить записи, мягко удалённые раньше now минус срок хранения.
+// Ошибка в одной таблице не мешает очистке остальных; возвращаются все ошибки сразу.
+func (p *Purger) PurgeOnce(now time.Time) error {
+	before := now.Add(-p.retention)
+	var errs []error
+	for _, target := range p.targets {
+		purged, err := target.Repo.Purge(before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error purging %s deleted before %s: %w", target.Name, before.Format(time.RFC3339), err))
+			continue
+		}
+		p.logger.Info("purge: removed soft-deleted rows", zap.String("target", target.Name), zap.Int64("count", purged))
+	}
+	return errors.Join(errs...)
+}
+
+// Run запускать очистку сразу и затем каждые interval, пока не отменён ctx
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.PurgeOnce(time.Now()); err != nil {
+			p.logger.Error("purge: failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package purge
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) Purge(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestPurgeOnce(t *testing.T) {
+	a := assert.New(t)
+	logger := &common.Logger{Logger: zap.NewNop()}
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should purge rows older than retention in every target", func(t *testing.T) {
+		employees := new(MockRepo)
+		roles := new(MockRepo)
+		purger := NewPurger(30*24*time.Hour, logger, Target{"employee", employees}, Target{"role", roles})
+
+		before := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+		employees.On("Purge", before).Return(int64(3), nil)
+		roles.On("Purge", before).Return(int64(0), nil)
+
+		a.NoError(purger.PurgeOnce(now))
+		a.True(employees.AssertNumberOfCalls(t, "Purge", 1))
+		a.True(roles.AssertNumberOfCalls(t, "Purge", 1))
+	})
+
+	t.Run("should continue after error in one target", func(t *testing.T) {
+		employees := new(MockRepo)
+		roles := new(MockRepo)
+		purger := NewPurger(time.Hour, logger, Target{"employee", employees}, Target{"role", roles})
+
+		dbErr := errors.New("database error")
+		employees.On("Purge", now.Add(-time.Hour)).Return(int64(0), dbErr)
+		roles.On("Purge", now.Add(-time.Hour)).Return(int64(1), nil)
+
+		err := purger.PurgeOnce(now)
+		a.ErrorIs(err, dbErr)
+		a.ErrorContains(err, "employee")
+		a.True(roles.AssertNumberOfCalls(t, "Purge", 1))
+	})
+}
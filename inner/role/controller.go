@@ -1,6 +1,7 @@
 package role
 
 import (
+	"context"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
@@ -9,6 +10,16 @@ import (
 	"strconv"
 )
 
+// Разрешения, которые требуют маршруты ролей
+const (
+	permissionRead   = "roles:read"
+	permissionCreate = "roles:create"
+	permissionUpdate = "roles:update"
+	permissionDelete = "roles:delete"
+	// permissionReadDeleted список ролей вместе с удалёнными (include_deleted)
+	permissionReadDeleted = "roles:read_deleted"
+)
+
 type Controller struct {
 	server      *web.Server
 	roleService Svc
@@ -16,12 +27,19 @@ type Controller struct {
 }
 
 type Svc interface {
-	Create(request CreateRequest) (int64, error)
+	Create(ctx context.Context, request CreateRequest) (int64, error)
 	FindById(request IdRequest) (Response, error)
-	FindAll() ([]Response, error)
+	FindAll(request ListRequest) (common.Page[Response], error)
 	FindAllByIds(request IdsRequest) ([]Response, error)
-	DeleteById(request IdRequest) error
-	DeleteAllByIds(request IdsRequest) error
+	DeleteById(ctx context.Context, request IdRequest) error
+	DeleteAllByIds(ctx context.Context, request IdsRequest) error
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Patch(ctx context.Context, request PatchRequest) (Response, error)
+	AddChild(ctx context.Context, request HierarchyRequest) error
+	RemoveChild(ctx context.Context, request HierarchyRequest) error
+	FindAncestors(request IdRequest) ([]Response, error)
+	FindDescendants(request IdRequest) ([]Response, error)
+	Restore(ctx context.Context, request IdRequest) error
 }
 
 func NewController(server *web.Server, roleService Svc, logger *common.Logger) *Controller {
@@ -33,12 +51,20 @@ func NewController(server *web.Server, roleService Svc, logger *common.Logger) *
 }
 
 func (c *Controller) RegisterRoutes() {
-	c.server.GroupApiV1.Post("/roles", c.CreateRole)
-	c.server.GroupApiV1.Get("/roles/:id", c.FindById)
-	c.server.GroupApiV1.Get("/roles", c.FindAll)
-	c.server.GroupApiV1.Post("/roles/ids", c.FindAllByIds)
-	c.server.GroupApiV1.Delete("/roles/:id", c.DeleteById)
-	c.server.GroupApiV1.Delete("/roles", c.DeleteAllByIds)
+	c.server.GroupApiV1.Post("/roles", c.server.Require(permissionCreate), c.CreateRole)
+	c.server.GroupApiV1.Get("/roles/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/roles", c.server.Require(permissionRead),
+		c.server.RequireIf(permissionReadDeleted, web.QueryFlag("include_deleted")), c.FindAll)
+	c.server.GroupApiV1.Post("/roles/ids", c.server.Require(permissionRead), c.FindAllByIds)
+	c.server.GroupApiV1.Put("/roles/:id", c.server.Require(permissionUpdate), c.Update)
+	c.server.GroupApiV1.Patch("/roles/:id", c.server.Require(permissionUpdate), c.Patch)
+	c.server.GroupApiV1.Delete("/roles/:id", c.server.Require(permissionDelete), c.DeleteById)
+	c.server.GroupApiV1.Delete("/roles", c.server.Require(permissionDelete), c.DeleteAllByIds)
+	c.server.GroupApiV1.Put("/roles/:id/children/:childId", c.server.Require(permissionUpdate), c.AddChild)
+	c.server.GroupApiV1.Delete("/roles/:id/children/:childId", c.server.Require(permissionUpdate), c.RemoveChild)
+	c.server.GroupApiV1.Get("/roles/:id/ancestors", c.server.Require(permissionRead), c.FindAncestors)
+	c.server.GroupApiV1.Get("/roles/:id/descendants", c.server.Require(permissionRead), c.FindDescendants)
+	c.server.GroupApiV1.Post("/roles/:id/restore", c.server.Require(permissionUpdate), c.Restore)
 }
 
 func (c *Controller) CreateRole(ctx *fiber.Ctx) error {
@@ -48,7 +74,7 @@ func (c *Controller) CreateRole(ctx *fiber.Ctx) error {
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
 	}
 	c.logger.Debug("create role: received request", zap.Any("request", request))
-	var newRoleId, err = c.roleService.Create(request)
+	var newRoleId, err = c.roleService.Create(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("create role: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -69,25 +95,112 @@ func (c *Controller) FindById(ctx *fiber.Ctx) error {
 		c.logger.Error("find role by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
 	}
-	request := IdRequest{Id: id}
+	asOf, err := common.QueryTime(ctx, "as_of")
+	if err != nil {
+		c.logger.Error("find role by id: invalid as_of parameter", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request := IdRequest{Id: id, AsOf: asOf}
 	response, err := c.roleService.FindById(request)
 	if err != nil {
 		c.logger.Error("find role by id: service error", zap.Int64("id", id), zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
 	}
 	c.logger.Debug("find role by id: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Update(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update role: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	version, err := common.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		c.logger.Error("update role: invalid If-Match header", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update role: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	request.Version = version
+	c.logger.Debug("update role: received request", zap.Any("request", request))
+	response, err := c.roleService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update role: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update role: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) Patch(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("patch role: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("patch role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	version, err := common.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		c.logger.Error("patch role: invalid If-Match header", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	var request PatchRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("patch role: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	request.Version = version
+	c.logger.Debug("patch role: received request", zap.Any("request", request))
+	response, err := c.roleService.Patch(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("patch role: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("patch role: success", zap.Int64("id", id))
+	ctx.Set(fiber.HeaderETag, common.ETag(response.UpdatedAt))
 	return common.OkResponse(ctx, response)
 }
 
 func (c *Controller) FindAll(ctx *fiber.Ctx) error {
-	c.logger.Debug("find all roles: received request")
-	responses, err := c.roleService.FindAll()
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		c.logger.Error("find all roles: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find all roles: received request", zap.Any("request", request))
+	page, err := c.roleService.FindAll(request)
 	if err != nil {
 		c.logger.Error("find all roles: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
 	}
-	c.logger.Debug("find all roles: success", zap.Int("count", len(responses)))
-	return common.OkResponse(ctx, responses)
+	c.logger.Debug("find all roles: success", zap.Int("count", len(page.Items)))
+	return common.PageResponse(ctx, page)
+}
+
+// parseListRequest прочитать параметры списка ролей из query
+func parseListRequest(ctx *fiber.Ctx) (request ListRequest, err error) {
+	if request.PageRequest, err = common.ParsePageRequest(ctx); err != nil {
+		return request, err
+	}
+	request.NamePrefix = ctx.Query("name_prefix")
+	request.IncludeDeleted = ctx.QueryBool("include_deleted")
+	if request.CreatedAfter, err = common.QueryTime(ctx, "created_after"); err != nil {
+		return request, err
+	}
+	request.AsOf, err = common.QueryTime(ctx, "as_of")
+	return request, err
 }
 
 func (c *Controller) FindAllByIds(ctx *fiber.Ctx) error {
@@ -115,7 +228,7 @@ func (c *Controller) DeleteById(ctx *fiber.Ctx) error {
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
 	}
 	request := IdRequest{Id: id}
-	err = c.roleService.DeleteById(request)
+	err = c.roleService.DeleteById(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("delete role by id: service error", zap.Int64("id", id), zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -131,7 +244,7 @@ func (c *Controller) DeleteAllByIds(ctx *fiber.Ctx) error {
 		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
 	}
 	c.logger.Debug("delete roles by ids: received request", zap.Any("request", request))
-	err := c.roleService.DeleteAllByIds(request)
+	err := c.roleService.DeleteAllByIds(ctx.UserContext(), request)
 	if err != nil {
 		c.logger.Error("delete roles by ids: service error", zap.Error(err))
 		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
@@ -140,13 +253,108 @@ func (c *Controller) DeleteAllByIds(ctx *fiber.Ctx) error {
 	return common.OkResponse[any](ctx, nil)
 }
 
+func (c *Controller) AddChild(ctx *fiber.Ctx) error {
+	request, err := parseHierarchyRequest(ctx)
+	if err != nil {
+		c.logger.Error("add child role: invalid parameters", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("add child role: received request", zap.Any("request", request))
+	if err = c.roleService.AddChild(ctx.UserContext(), request); err != nil {
+		c.logger.Error("add child role: service error", zap.Any("request", request), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("add child role: success", zap.Any("request", request))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) RemoveChild(ctx *fiber.Ctx) error {
+	request, err := parseHierarchyRequest(ctx)
+	if err != nil {
+		c.logger.Error("remove child role: invalid parameters", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("remove child role: received request", zap.Any("request", request))
+	if err = c.roleService.RemoveChild(ctx.UserContext(), request); err != nil {
+		c.logger.Error("remove child role: service error", zap.Any("request", request), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("remove child role: success", zap.Any("request", request))
+	return common.OkResponse[any](ctx, nil)
+}
+
+func (c *Controller) FindAncestors(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find role ancestors: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find role ancestors: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.roleService.FindAncestors(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find role ancestors: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find role ancestors: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) FindDescendants(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find role descendants: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find role descendants: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	responses, err := c.roleService.FindDescendants(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find role descendants: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find role descendants: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func parseHierarchyRequest(ctx *fiber.Ctx) (HierarchyRequest, error) {
+	parentId, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return HierarchyRequest{}, errors.New("invalid id parameter")
+	}
+	childId, err := strconv.ParseInt(ctx.Params("childId"), 10, 64)
+	if err != nil {
+		return HierarchyRequest{}, errors.New("invalid child id parameter")
+	}
+	return HierarchyRequest{ParentId: parentId, ChildId: childId}, nil
+}
+
 func resolveHttpStatusCode(err error) int {
 	switch {
 	case errors.As(err, &common.RequestValidationError{}):
 		return fiber.StatusBadRequest
 	case errors.As(err, &common.NotFoundError{}):
 		return fiber.StatusNotFound
+	case errors.As(err, &common.PreconditionFailedError{}):
+		return fiber.StatusPreconditionFailed
 	default:
 		return fiber.StatusInternalServerError
 	}
 }
+
+func (c *Controller) Restore(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("restore role: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("restore role: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.roleService.Restore(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("restore role: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("restore role: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
@@ -1,6 +1,7 @@
 package role
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/gofiber/fiber/v2"
@@ -8,7 +9,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 	"idm/inner/common"
-	"idm/inner/web"
+	"idm/inner/web/webtest"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -21,7 +22,7 @@ type MockService struct {
 	mock.Mock
 }
 
-func (svc *MockService) Create(request CreateRequest) (int64, error) {
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (int64, error) {
 	args := svc.Called(request)
 	return args.Get(0).(int64), args.Error(1)
 }
@@ -31,9 +32,9 @@ func (svc *MockService) FindById(request IdRequest) (Response, error) {
 	return args.Get(0).(Response), args.Error(1)
 }
 
-func (svc *MockService) FindAll() ([]Response, error) {
-	args := svc.Called()
-	return args.Get(0).([]Response), args.Error(1)
+func (svc *MockService) FindAll(request ListRequest) (common.Page[Response], error) {
+	args := svc.Called(request)
+	return args.Get(0).(common.Page[Response]), args.Error(1)
 }
 
 func (svc *MockService) FindAllByIds(request IdsRequest) ([]Response, error) {
@@ -41,23 +42,58 @@ func (svc *MockService) FindAllByIds(request IdsRequest) ([]Response, error) {
 	return args.Get(0).([]Response), args.Error(1)
 }
 
-func (svc *MockService) DeleteById(request IdRequest) error {
+func (svc *MockService) DeleteById(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) DeleteAllByIds(ctx context.Context, request IdsRequest) error {
 	args := svc.Called(request)
 	return args.Error(0)
 }
 
-func (svc *MockService) DeleteAllByIds(request IdsRequest) error {
+func (svc *MockService) AddChild(ctx context.Context, request HierarchyRequest) error {
 	args := svc.Called(request)
 	return args.Error(0)
 }
 
+func (svc *MockService) RemoveChild(ctx context.Context, request HierarchyRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindAncestors(request IdRequest) ([]Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) FindDescendants(request IdRequest) ([]Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Restore(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Patch(ctx context.Context, request PatchRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
 func TestControllerCreateEmployee(t *testing.T) {
 	a := assert.New(t)
 
 	url := "/api/v1/roles"
 
 	t.Run("should return created role id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -86,7 +122,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return bad request on invalid json", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -102,7 +138,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return bad request on validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -130,7 +166,7 @@ func TestControllerCreateEmployee(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -161,7 +197,7 @@ func TestControllerFindById(t *testing.T) {
 	url := "/api/v1/roles/1"
 
 	t.Run("should return role by id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -192,7 +228,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -217,7 +253,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return bad request on invalid id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -240,7 +276,7 @@ func TestControllerFindById(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -264,12 +300,26 @@ func TestControllerFindById(t *testing.T) {
 	})
 }
 
+// GrantAuthorizer пропускает запрос, только если разрешение есть в списке разрешений автора запроса
+type GrantAuthorizer []string
+
+func (g GrantAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, granted := range g {
+			if granted == permission {
+				return c.Next()
+			}
+		}
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
 func TestControllerFindAll(t *testing.T) {
 	a := assert.New(t)
 	url := "/api/v1/roles"
 
 	t.Run("should return all roles", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -279,7 +329,7 @@ func TestControllerFindAll(t *testing.T) {
 			{Id: 1, Name: "Alice", CreatedAt: time.Now(), UpdatedAt: time.Now()},
 			{Id: 2, Name: "Bob", CreatedAt: time.Now(), UpdatedAt: time.Now()},
 		}
-		svc.On("FindAll").Return(roles, nil)
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{Items: roles}, nil)
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -306,14 +356,14 @@ func TestControllerFindAll(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
 		controller.RegisterRoutes()
 
 		errNotFound := common.NotFoundError{Message: "no roles found"}
-		svc.On("FindAll").Return([]Response(nil), errNotFound)
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, errNotFound)
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -331,13 +381,13 @@ func TestControllerFindAll(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
 		controller.RegisterRoutes()
 
-		svc.On("FindAll").Return([]Response(nil), errors.New("unexpected server error"))
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, errors.New("unexpected server error"))
 
 		req := httptest.NewRequest(fiber.MethodGet, url, nil)
 		resp, err := server.App.Test(req)
@@ -353,6 +403,26 @@ func TestControllerFindAll(t *testing.T) {
 		a.False(responseBody.Success)
 		a.Equal("unexpected server error", responseBody.Message)
 	})
+
+	t.Run("should require permission to include deleted roles", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = GrantAuthorizer{"roles:read"}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll", ListRequest{}).Return(common.Page[Response]{}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/roles?include_deleted=true", nil))
+		a.Nil(err)
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+		a.Empty(svc.Calls)
+
+		resp, err = server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/roles", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
 }
 
 func TestControllerFindAllByIds(t *testing.T) {
@@ -362,7 +432,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	invalidBody := `{"ids":[]}`
 
 	t.Run("should return all roles by ids", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -403,7 +473,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -431,7 +501,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -459,7 +529,7 @@ func TestControllerFindAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -492,7 +562,7 @@ func TestControllerDeleteById(t *testing.T) {
 	url := "/api/v1/roles/1"
 
 	t.Run("should delete role by id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -518,7 +588,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return bad request on invalid id", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -541,7 +611,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -567,7 +637,7 @@ func TestControllerDeleteById(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -599,7 +669,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	invalidBody := `{"ids":[]}`
 
 	t.Run("should delete all roles by ids", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -627,7 +697,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return validation error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -655,7 +725,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -683,7 +753,7 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 	})
 
 	t.Run("should return internal server error on generic error", func(t *testing.T) {
-		server := web.NewServer()
+		server := webtest.NewServer()
 		svc := new(MockService)
 		logger := &common.Logger{Logger: zap.NewNop()}
 		controller := NewController(server, svc, logger)
@@ -709,3 +779,301 @@ func TestControllerDeleteAllByIds(t *testing.T) {
 		a.Equal("unexpected server error", responseBody.Message)
 	})
 }
+
+func TestControllerAddChild(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should add child role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("AddChild", HierarchyRequest{ParentId: 1, ChildId: 2}).Return(nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/roles/1/children/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on cycle", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		cycleErr := common.RequestValidationError{Message: "would create a cycle"}
+		svc.On("AddChild", HierarchyRequest{ParentId: 1, ChildId: 2}).Return(cycleErr)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/roles/1/children/2", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[any]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal("would create a cycle", responseBody.Message)
+	})
+
+	t.Run("should return bad request on invalid child id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/roles/1/children/abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerFindAncestors(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return ancestors", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		ancestors := []Response{{Id: 1, Name: "Senior Accountant", CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+		svc.On("FindAncestors", IdRequest{Id: 2}).Return(ancestors, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/roles/2/ancestors", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal("Senior Accountant", responseBody.Data[0].Name)
+	})
+
+	t.Run("should return internal server error on descendants lookup failure", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindDescendants", IdRequest{Id: 2}).Return([]Response{}, errors.New("db down"))
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/roles/2/descendants", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestControllerUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should update role and return new etag", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		version := time.UnixMicro(1700000000000000)
+		updated := Response{Id: 1, Name: "Manager", UpdatedAt: time.UnixMicro(1700000000000001)}
+		svc.On("Update", UpdateRequest{Id: 1, Name: "Manager", Version: &version}).Return(updated, nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/roles/1", strings.NewReader(`{"name":"Manager"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `W/"1700000000000000"`)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(`"1700000000000001"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("should return precondition failed on stale version", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		name := "Manager"
+		svc.On("Patch", mock.MatchedBy(func(r PatchRequest) bool { return r.Id == 1 && *r.Name == name })).
+			Return(Response{}, common.PreconditionFailedError{Message: "modified concurrently"})
+
+		req := httptest.NewRequest(fiber.MethodPatch, "/api/v1/roles/1", strings.NewReader(`{"name":"Manager"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"1"`)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
+
+func TestControllerFindAllPage(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters and return page info", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		request := ListRequest{PageRequest: common.PageRequest{Limit: 5, Sort: "updated_at"}, NamePrefix: "Adm"}
+		page := common.Page[Response]{Items: []Response{{Id: 1, Name: "Admin"}}, PageInfo: common.PageInfo{Total: 1}}
+		svc.On("FindAll", request).Return(page, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/roles?limit=5&sort=updated_at&name_prefix=Adm", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(1), responseBody.Page.Total)
+		a.Empty(responseBody.Page.NextCursor)
+	})
+}
+
+func TestControllerRestore(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should restore role", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Restore", IdRequest{Id: 1}).Return(nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/roles/1/restore", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodPost, "/api/v1/roles/abc/restore", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerAsOf(t *testing.T) {
+	a := assert.New(t)
+	asOf := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should pass as_of to find by id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindById", IdRequest{Id: 1, AsOf: &asOf}).Return(Response{Id: 1, Name: "Old Name"}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/roles/1?as_of=2025-08-01T12:00:00Z", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should pass as_of to list", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindAll", ListRequest{AsOf: &asOf}).Return(common.Page[Response]{Items: []Response{}}, nil)
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/roles?as_of=2025-08-01T12:00:00Z", nil))
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return bad request on invalid as_of", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		resp, err := server.App.Test(httptest.NewRequest(fiber.MethodGet, "/api/v1/roles?as_of=yesterday", nil))
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
+
+// DenyAuthorizer отклоняет любой запрос и сообщает, какое разрешение потребовал маршрут
+type DenyAuthorizer struct{}
+
+func (DenyAuthorizer) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return common.ErrResponse(c, fiber.StatusForbidden, "missing permission "+permission)
+	}
+}
+
+func TestControllerPermissions(t *testing.T) {
+	a := assert.New(t)
+
+	routes := []struct {
+		method     string
+		url        string
+		permission string
+	}{
+		{fiber.MethodPost, "/api/v1/roles", "roles:create"},
+		{fiber.MethodGet, "/api/v1/roles/1", "roles:read"},
+		{fiber.MethodGet, "/api/v1/roles", "roles:read"},
+		{fiber.MethodPost, "/api/v1/roles/ids", "roles:read"},
+		{fiber.MethodPut, "/api/v1/roles/1", "roles:update"},
+		{fiber.MethodPatch, "/api/v1/roles/1", "roles:update"},
+		{fiber.MethodDelete, "/api/v1/roles/1", "roles:delete"},
+		{fiber.MethodDelete, "/api/v1/roles", "roles:delete"},
+		{fiber.MethodPut, "/api/v1/roles/1/children/2", "roles:update"},
+		{fiber.MethodDelete, "/api/v1/roles/1/children/2", "roles:update"},
+		{fiber.MethodGet, "/api/v1/roles/1/ancestors", "roles:read"},
+		{fiber.MethodGet, "/api/v1/roles/1/descendants", "roles:read"},
+		{fiber.MethodPost, "/api/v1/roles/1/restore", "roles:update"},
+	}
+
+	t.Run("should require permission on every route", func(t *testing.T) {
+		server := webtest.NewServer()
+		server.Authorizer = DenyAuthorizer{}
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		for _, route := range routes {
+			resp, err := server.App.Test(httptest.NewRequest(route.method, route.url, nil))
+			a.Nil(err)
+			a.Equal(http.StatusForbidden, resp.StatusCode, route.url)
+
+			bytesData, err := io.ReadAll(resp.Body)
+			a.Nil(err)
+			var responseBody common.Response[any]
+			a.Nil(json.Unmarshal(bytesData, &responseBody))
+			a.Equal("missing permission "+route.permission, responseBody.Message, route.method+" "+route.url)
+		}
+		a.Empty(svc.Calls)
+	})
+}
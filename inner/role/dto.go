@@ -1,12 +1,16 @@
 package role
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 type Entity struct {
-	Id        int64     `db:"id"`
-	Name      string    `db:"name"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	Id        int64      `db:"id"`
+	Name      string     `db:"name"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 func (e *Entity) toResponse() Response {
@@ -15,12 +19,61 @@ func (e *Entity) toResponse() Response {
 		Name:      e.Name,
 		CreatedAt: e.CreatedAt,
 		UpdatedAt: e.UpdatedAt,
+		DeletedAt: e.DeletedAt,
 	}
 }
 
+// auditSnapshot состояние роли в журнале аудита: только поля, которые меняют пользователи
+type auditSnapshot struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Name: e.Name, DeletedAt: e.DeletedAt}
+}
+
+// hierarchySnapshot связь ролей в журнале аудита
+type hierarchySnapshot struct {
+	ParentId int64 `json:"parent_id"`
+	ChildId  int64 `json:"child_id"`
+}
+
+// sortValue значение колонки сортировки sort для курсора страницы
+func (e *Entity) sortValue(sort string) string {
+	switch sort {
+	case "name":
+		return e.Name
+	case "created_at":
+		return e.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return e.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return strconv.FormatInt(e.Id, 10)
+}
+
+// HistoryEntity версия строки роли из role_history, действовавшая в [VersionFrom, VersionTo)
+type HistoryEntity struct {
+	Entity
+	VersionFrom time.Time  `db:"version_from"`
+	VersionTo   *time.Time `db:"version_to"`
+}
+
+// EntitiesOf состояния ролей из их версий
+func EntitiesOf(versions []HistoryEntity) []Entity {
+	entities := make([]Entity, 0, len(versions))
+	for _, version := range versions {
+		entities = append(entities, version.Entity)
+	}
+	return entities
+}
+
 type Response struct {
 	Id        int64     `json:"id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt момент мягкого удаления; заполнен только в списках с include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
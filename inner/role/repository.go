@@ -3,6 +3,9 @@ package role
 import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
 )
 
 type Repository struct {
@@ -20,37 +23,248 @@ func (r *Repository) Save(role Entity) (id int64, err error) {
 }
 
 func (r *Repository) FindById(id int64) (role Entity, err error) {
-	query := "select * from role where id=$1"
+	query := "select * from role where id = $1 and deleted_at is null"
 	err = r.db.Get(&role, query, id)
 	return role, err
 }
 
+// FindByIdAsOf найти роль в том состоянии, в котором она была в момент at
+func (r *Repository) FindByIdAsOf(id int64, at time.Time) (role Entity, err error) {
+	var version HistoryEntity
+	query := `select * from role_history where id = $1 and deleted_at is null
+		and version_from <= $2 and (version_to is null or version_to > $2)`
+	err = r.db.Get(&version, query, id, at)
+	return version.Entity, err
+}
+
 func (r *Repository) FindAll() (roles []Entity, err error) {
-	query := "select * from role"
+	query := "select * from role where deleted_at is null"
 	err = r.db.Select(&roles, query)
 	return roles, err
 }
 
+// FindPage найти страницу ролей по фильтру; возвращает до Limit+1 записей,
+// лишняя запись означает, что есть следующая страница
+// Если задан request.AsOf, страница строится по версиям из role_history на этот момент.
+func (r *Repository) FindPage(request ListRequest, after *common.Cursor) (roles []Entity, err error) {
+	conditions := listConditions(request)
+	order := conditions.Keyset(request.PageRequest, after)
+	if request.Offset > 0 {
+		order += conditions.Offset(request.Offset)
+	}
+	query := "select * from " + listTable(request) + conditions.Where() + order
+	if request.AsOf == nil {
+		err = r.db.Select(&roles, query, conditions.Args()...)
+		return roles, err
+	}
+	var versions []HistoryEntity
+	err = r.db.Select(&versions, query, conditions.Args()...)
+	return EntitiesOf(versions), err
+}
+
+// Count количество ролей, подходящих под фильтр, без учёта курсора
+func (r *Repository) Count(request ListRequest) (total int64, err error) {
+	conditions := listConditions(request)
+	query := "select count(*) from " + listTable(request) + conditions.Where()
+	err = r.db.Get(&total, query, conditions.Args()...)
+	return total, err
+}
+
+// listTable таблица, из которой строится список: текущие строки или их история
+func listTable(request ListRequest) string {
+	if request.AsOf != nil {
+		return "role_history"
+	}
+	return "role"
+}
+
+func listConditions(request ListRequest) *database.Conditions {
+	conditions := &database.Conditions{}
+	if request.AsOf != nil {
+		conditions.AsOf(*request.AsOf)
+	}
+	if !request.IncludeDeleted {
+		conditions.Add("deleted_at is null")
+	}
+	if request.NamePrefix != "" {
+		conditions.Add("name like ?", database.LikePrefix(request.NamePrefix))
+	}
+	if request.CreatedAfter != nil {
+		conditions.Add("created_at > ?", *request.CreatedAfter)
+	}
+	if request.Filter != nil {
+		conditions.Add("("+request.Filter.Condition+")", request.Filter.Args...)
+	}
+	return conditions
+}
+
 func (r *Repository) FindAllByIds(ids []int64) (roles []Entity, err error) {
 	if len(ids) == 0 {
 		return []Entity{}, nil
 	}
-	query := "select * from role where id = ANY($1)"
+	query := "select * from role where id = ANY($1) and deleted_at is null"
 	err = r.db.Select(&roles, query, pq.Array(ids))
 	return roles, err
 }
 
-func (r *Repository) DeleteById(id int64) (err error) {
-	query := "delete from role where id=$1"
-	_, err = r.db.Exec(query, id)
+// FindAllByIdsAsOf найти роли в том состоянии, в котором они были в момент at
+func (r *Repository) FindAllByIdsAsOf(ids []int64, at time.Time) (roles []Entity, err error) {
+	if len(ids) == 0 {
+		return []Entity{}, nil
+	}
+	var versions []HistoryEntity
+	query := `select * from role_history where id = ANY($1) and deleted_at is null
+		and version_from <= $2 and (version_to is null or version_to > $2)`
+	err = r.db.Select(&versions, query, pq.Array(ids), at)
+	return EntitiesOf(versions), err
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, role Entity) (id int64, err error) {
+	query := "insert into role (name) values ($1) returning id"
+	err = tx.QueryRowx(query, role.Name).Scan(&id)
+	return id, err
+}
+
+// LockByIdTx найти роль, в том числе удалённую, и заблокировать строку до конца транзакции
+func (r *Repository) LockByIdTx(tx *sqlx.Tx, id int64) (role Entity, err error) {
+	query := "select * from role where id = $1 for update"
+	err = tx.Get(&role, query, id)
+	return role, err
+}
+
+// DeleteTx мягко удалить роли: строки остаются в таблице до очистки Purge.
+// Возвращает только те строки, которые были удалены этим вызовом.
+func (r *Repository) DeleteTx(tx *sqlx.Tx, ids []int64) (deleted []Entity, err error) {
+	query := "update role set deleted_at = now() where id = ANY($1) and deleted_at is null returning *"
+	err = tx.Select(&deleted, query, pq.Array(ids))
+	return deleted, err
+}
+
+// UpdateTx обновить роль. Если version не nil, обновление выполняется только при совпадении
+// updated_at с version; updated равен false, если подходящая строка не найдена.
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (updated bool, err error) {
+	query := `update role set name = $1, updated_at = now()
+		where id = $2 and deleted_at is null and ($3::timestamptz is null or updated_at = $3)`
+	result, err := tx.Exec(query, e.Name, e.Id, version)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+// LockHierarchyTx заблокировать иерархию ролей до конца транзакции,
+// чтобы параллельные изменения не смогли создать цикл в обход проверки
+func (r *Repository) LockHierarchyTx(tx *sqlx.Tx) (err error) {
+	_, err = tx.Exec("lock table role_hierarchy in share row exclusive mode")
 	return err
 }
 
-func (r *Repository) DeleteAllByIds(ids []int64) (err error) {
+// IsDescendantTx входит ли роль candidateId (напрямую или транзитивно) в роль ancestorId
+func (r *Repository) IsDescendantTx(tx *sqlx.Tx, ancestorId, candidateId int64) (found bool, err error) {
+	query := `with recursive descendant(id) as (
+		select child_id from role_hierarchy where parent_id = $1
+		union
+		select rh.child_id from role_hierarchy rh join descendant d on rh.parent_id = d.id
+	)
+	select exists(select 1 from descendant where id = $2)`
+	err = tx.Get(&found, query, ancestorId, candidateId)
+	return found, err
+}
+
+// AddChildTx добавить связь; added равен false, если она уже была
+func (r *Repository) AddChildTx(tx *sqlx.Tx, parentId, childId int64) (added bool, err error) {
+	query := "insert into role_hierarchy (parent_id, child_id) values ($1, $2) on conflict do nothing"
+	result, err := tx.Exec(query, parentId, childId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repository) RemoveChildTx(tx *sqlx.Tx, parentId, childId int64) (removed bool, err error) {
+	query := "delete from role_hierarchy where parent_id = $1 and child_id = $2"
+	result, err := tx.Exec(query, parentId, childId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FindAncestors роли, которые включают в себя роль id напрямую или транзитивно.
+// Удалённые роли не возвращаются и не передают наследование дальше.
+func (r *Repository) FindAncestors(id int64) (roles []Entity, err error) {
+	query := `with recursive ancestor(id) as (
+		select rh.parent_id from role_hierarchy rh
+			join role p on p.id = rh.parent_id and p.deleted_at is null
+		where rh.child_id = $1
+		union
+		select rh.parent_id from role_hierarchy rh
+			join ancestor a on rh.child_id = a.id
+			join role p on p.id = rh.parent_id and p.deleted_at is null
+	)
+	select r.* from role r join ancestor a on a.id = r.id order by r.id`
+	err = r.db.Select(&roles, query, id)
+	return roles, err
+}
+
+// FindDescendants роли, которые роль id включает в себя напрямую или транзитивно.
+// Удалённые роли не возвращаются и не передают наследование дальше.
+func (r *Repository) FindDescendants(id int64) (roles []Entity, err error) {
+	query := `with recursive descendant(id) as (
+		select rh.child_id from role_hierarchy rh
+			join role c on c.id = rh.child_id and c.deleted_at is null
+		where rh.parent_id = $1
+		union
+		select rh.child_id from role_hierarchy rh
+			join descendant d on rh.parent_id = d.id
+			join role c on c.id = rh.child_id and c.deleted_at is null
+	)
+	select r.* from role r join descendant d on d.id = r.id order by r.id`
+	err = r.db.Select(&roles, query, id)
+	return roles, err
+}
+
+// ExpandInherited роли ids вместе со всеми унаследованными ими ролями, без повторов и без удалённых
+func (r *Repository) ExpandInherited(ids []int64) (roles []Entity, err error) {
 	if len(ids) == 0 {
-		return nil
+		return []Entity{}, nil
 	}
-	query := "delete from role where id = ANY($1)"
-	_, err = r.db.Exec(query, pq.Array(ids))
-	return err
+	query := `with recursive expanded(id) as (
+		select id from role where id = any($1::bigint[]) and deleted_at is null
+		union
+		select rh.child_id from role_hierarchy rh
+			join expanded e on rh.parent_id = e.id
+			join role c on c.id = rh.child_id and c.deleted_at is null
+	)
+	select r.* from role r join expanded e on e.id = r.id order by r.id`
+	err = r.db.Select(&roles, query, pq.Array(ids))
+	return roles, err
+}
+
+// RestoreTx снять пометку удаления с роли и вернуть её; sql.ErrNoRows, если роли нет
+func (r *Repository) RestoreTx(tx *sqlx.Tx, id int64) (role Entity, err error) {
+	query := "update role set deleted_at = null, updated_at = now() where id = $1 returning *"
+	err = tx.Get(&role, query, id)
+	return role, err
+}
+
+// Purge окончательно удалить роли, мягко удалённые раньше before. Роли, на которые ссылаются запросы доступа,
+// правила разделения обязанностей или правила назначения, остаются: эти записи нужны аудиту
+func (r *Repository) Purge(before time.Time) (purged int64, err error) {
+	query := `delete from role r where r.deleted_at < $1
+		and not exists(select 1 from access_request ar where ar.role_id = r.id)
+		and not exists(select 1 from sod_rule sr where r.id in (sr.role_a_id, sr.role_b_id))
+		and not exists(select 1 from assignment_rule asr where asr.role_id = r.id)`
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
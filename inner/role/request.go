@@ -1,5 +1,11 @@
 package role
 
+import (
+	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
 type CreateRequest struct {
 	Name string `json:"name" validate:"required,min=2,max=55"`
 }
@@ -10,8 +16,50 @@ func (r *CreateRequest) ToEntity() Entity {
 
 type IdRequest struct {
 	Id int64 `json:"id" validate:"required,gt=0"`
+	// AsOf момент, на который нужно восстановить состояние роли; nil - текущее состояние
+	AsOf *time.Time `json:"as_of"`
 }
 
 type IdsRequest struct {
 	Ids []int64 `json:"ids" validate:"required,min=1,dive,gt=0"`
 }
+
+// ListRequest параметры списка ролей: страница и необязательные фильтры
+type ListRequest struct {
+	common.PageRequest
+	NamePrefix   string     `json:"name_prefix" validate:"max=55"`
+	CreatedAfter *time.Time `json:"created_after"`
+	// IncludeDeleted включить в список мягко удалённые роли
+	IncludeDeleted bool `json:"include_deleted"`
+	// AsOf построить список по состоянию на этот момент; nil - текущее состояние
+	AsOf *time.Time `json:"as_of"`
+	// Filter дополнительное условие на колонки таблицы; задаётся только кодом, например SCIM
+	Filter *database.Filter `json:"-"`
+	// Offset пропустить столько записей от начала выборки; для нумерованных страниц SCIM
+	Offset int `json:"-" validate:"min=0"`
+}
+
+// HierarchyRequest роль ParentId включает в себя роль ChildId
+type HierarchyRequest struct {
+	ParentId int64 `json:"parent_id" validate:"required,gt=0"`
+	ChildId  int64 `json:"child_id" validate:"required,gt=0,nefield=ParentId"`
+}
+
+// UpdateRequest полная замена данных роли (PUT)
+type UpdateRequest struct {
+	Id   int64  `json:"-" validate:"required,gt=0"`
+	Name string `json:"name" validate:"required,min=2,max=55"`
+	// Version ожидаемое время последнего изменения из заголовка If-Match; nil - без проверки
+	Version *time.Time `json:"-"`
+}
+
+func (r *UpdateRequest) ToEntity() Entity {
+	return Entity{Id: r.Id, Name: r.Name}
+}
+
+// PatchRequest частичное изменение роли (PATCH): меняются только переданные поля
+type PatchRequest struct {
+	Id      int64      `json:"-" validate:"required,gt=0"`
+	Name    *string    `json:"name" validate:"omitempty,min=2,max=55"`
+	Version *time.Time `json:"-"`
+}
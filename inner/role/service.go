@@ -1,44 +1,91 @@
 package role
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
 	"idm/inner/common"
+	"idm/inner/database"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "role"
+
+// Действия с иерархией ролей в журнале аудита
+const (
+	auditActionAddChild    = "add_child"
+	auditActionRemoveChild = "remove_child"
 )
 
 type Service struct {
 	repo      Repo
+	auditor   Auditor
 	validator Validator
 }
 
 type Repo interface {
-	Save(e Entity) (int64, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (int64, error)
 	FindById(id int64) (Entity, error)
-	FindAll() ([]Entity, error)
+	FindByIdAsOf(id int64, at time.Time) (Entity, error)
+	FindPage(request ListRequest, after *common.Cursor) ([]Entity, error)
+	Count(request ListRequest) (int64, error)
 	FindAllByIds(ids []int64) ([]Entity, error)
-	DeleteById(id int64) error
-	DeleteAllByIds(ids []int64) error
+	LockByIdTx(tx *sqlx.Tx, id int64) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (bool, error)
+	BeginTransaction() (*sqlx.Tx, error)
+	LockHierarchyTx(tx *sqlx.Tx) error
+	IsDescendantTx(tx *sqlx.Tx, ancestorId, candidateId int64) (bool, error)
+	AddChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error)
+	RemoveChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error)
+	FindAncestors(id int64) ([]Entity, error)
+	FindDescendants(id int64) ([]Entity, error)
+	RestoreTx(tx *sqlx.Tx, id int64) (Entity, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
 }
 
 type Validator interface {
 	Validate(request any) error
 }
 
-func NewService(repo Repo, validator Validator) *Service {
+func NewService(repo Repo, auditor Auditor, validator Validator) *Service {
 	return &Service{
 		repo:      repo,
+		auditor:   auditor,
 		validator: validator,
 	}
 }
 
-func (svc *Service) Create(request CreateRequest) (int64, error) {
-	err := svc.validator.Validate(request)
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (id int64, err error) {
+	err = svc.validator.Validate(request)
 	if err != nil {
 		// возвращаем кастомную ошибку в случае, если запрос не прошёл валидацию
 		return 0, common.RequestValidationError{Message: err.Error()}
 	}
-	id, err := svc.repo.Save(request.ToEntity())
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating role", func(tx *sqlx.Tx) error {
+		entity := request.ToEntity()
+		id, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving role: %v", err)
+		}
+		entity.Id = id
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   id,
+			After:      entity.auditSnapshot(),
+		})
+	})
 	if err != nil {
-		return 0, fmt.Errorf("error saving role: %v", err)
+		return 0, err
 	}
 	return id, nil
 }
@@ -48,7 +95,12 @@ func (svc *Service) FindById(request IdRequest) (Response, error) {
 	if err != nil {
 		return Response{}, common.RequestValidationError{Message: err.Error()}
 	}
-	entity, err := svc.repo.FindById(request.Id)
+	var entity Entity
+	if request.AsOf != nil {
+		entity, err = svc.repo.FindByIdAsOf(request.Id, *request.AsOf)
+	} else {
+		entity, err = svc.repo.FindById(request.Id)
+	}
 	if err != nil {
 		return Response{}, common.NotFoundError{
 			Message: fmt.Sprintf("error finding role with id %d: %v", request.Id, err),
@@ -57,18 +109,39 @@ func (svc *Service) FindById(request IdRequest) (Response, error) {
 	return entity.toResponse(), nil
 }
 
-func (svc *Service) FindAll() ([]Response, error) {
-	entities, err := svc.repo.FindAll()
+// FindAll найти страницу ролей по фильтру. Без параметров возвращается первая страница
+// размером common.DefaultPageLimit в порядке id.
+func (svc *Service) FindAll(request ListRequest) (common.Page[Response], error) {
+	request.Defaults()
+	err := svc.validator.Validate(request)
 	if err != nil {
-		return nil, common.NotFoundError{
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	after, err := request.After()
+	if err != nil {
+		return common.Page[Response]{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entities, err := svc.repo.FindPage(request, after)
+	if err != nil {
+		return common.Page[Response]{}, common.NotFoundError{
 			Message: fmt.Sprintf("error retrieving all roles: %v", err),
 		}
 	}
-	responses := make([]Response, 0, len(entities))
+	total, err := svc.repo.Count(request)
+	if err != nil {
+		return common.Page[Response]{}, fmt.Errorf("error counting roles: %w", err)
+	}
+	page := common.Page[Response]{PageInfo: common.PageInfo{Total: total}}
+	if len(entities) > request.Limit {
+		entities = entities[:request.Limit]
+		last := entities[len(entities)-1]
+		page.NextCursor = request.Next(last.sortValue(request.Sort), last.Id)
+	}
+	page.Items = make([]Response, 0, len(entities))
 	for _, entity := range entities {
-		responses = append(responses, entity.toResponse())
+		page.Items = append(page.Items, entity.toResponse())
 	}
-	return responses, nil
+	return page, nil
 }
 
 func (svc *Service) FindAllByIds(request IdsRequest) ([]Response, error) {
@@ -89,12 +162,12 @@ func (svc *Service) FindAllByIds(request IdsRequest) ([]Response, error) {
 	return responses, nil
 }
 
-func (svc *Service) DeleteById(request IdRequest) error {
+func (svc *Service) DeleteById(ctx context.Context, request IdRequest) error {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		return common.RequestValidationError{Message: err.Error()}
 	}
-	err = svc.repo.DeleteById(request.Id)
+	err = svc.delete(ctx, []int64{request.Id})
 	if err != nil {
 		return common.NotFoundError{
 			Message: fmt.Sprintf("error deleting role with id %d: %v", request.Id, err),
@@ -103,12 +176,12 @@ func (svc *Service) DeleteById(request IdRequest) error {
 	return nil
 }
 
-func (svc *Service) DeleteAllByIds(request IdsRequest) error {
+func (svc *Service) DeleteAllByIds(ctx context.Context, request IdsRequest) error {
 	err := svc.validator.Validate(request)
 	if err != nil {
 		return common.RequestValidationError{Message: err.Error()}
 	}
-	err = svc.repo.DeleteAllByIds(request.Ids)
+	err = svc.delete(ctx, request.Ids)
 	if err != nil {
 		return common.NotFoundError{
 			Message: fmt.Sprintf("error deleting roles by ids %v: %v", request.Ids, err),
@@ -116,3 +189,228 @@ func (svc *Service) DeleteAllByIds(request IdsRequest) error {
 	}
 	return nil
 }
+
+// delete мягко удалить роли и записать в журнал каждую действительно удалённую
+func (svc *Service) delete(ctx context.Context, ids []int64) error {
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting roles", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, entity := range deleted {
+			before := entity
+			before.DeletedAt = nil
+			err = svc.auditor.RecordTx(ctx, tx, audit.Event{
+				Action:     audit.ActionDelete,
+				EntityType: auditEntityType,
+				EntityId:   entity.Id,
+				Before:     before.auditSnapshot(),
+				After:      entity.auditSnapshot(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update заменить данные роли. При заданной версии изменение применяется, только если роль
+// не менялась с этого момента, иначе возвращается PreconditionFailedError.
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating role", func(tx *sqlx.Tx) error {
+		before, err := svc.lock(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		after := request.ToEntity()
+		updated, err := svc.repo.UpdateTx(tx, after, request.Version)
+		if err != nil {
+			return fmt.Errorf("error updating role with id %d: %w", request.Id, err)
+		}
+		if !updated {
+			// строка заблокирована и существует, значит, не совпала версия
+			return common.PreconditionFailedError{
+				Message: fmt.Sprintf("role with id %d was modified concurrently", request.Id),
+			}
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      after.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return svc.FindById(IdRequest{Id: request.Id})
+}
+
+// lock заблокировать роль до конца транзакции и вернуть её текущее состояние;
+// удалённая роль считается отсутствующей
+func (svc *Service) lock(tx *sqlx.Tx, id int64) (Entity, error) {
+	entity, err := svc.repo.LockByIdTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && entity.DeletedAt != nil {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("role with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding role with id %d: %w", id, err)
+	}
+	return entity, nil
+}
+
+// Patch изменить только переданные поля роли
+func (svc *Service) Patch(ctx context.Context, request PatchRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	current, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.Id, err),
+		}
+	}
+	update := UpdateRequest{Id: request.Id, Name: current.Name, Version: request.Version}
+	if request.Name != nil {
+		update.Name = *request.Name
+	}
+	return svc.Update(ctx, update)
+}
+
+// Restore вернуть мягко удалённую роль вместе с её связями в иерархии и назначениями
+func (svc *Service) Restore(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "restoring role", func(tx *sqlx.Tx) error {
+		before, err := svc.repo.LockByIdTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("role with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding role with id %d: %w", request.Id, err)
+		}
+		if before.DeletedAt == nil {
+			return nil
+		}
+		restored, err := svc.repo.RestoreTx(tx, request.Id)
+		if err != nil {
+			return fmt.Errorf("error restoring role with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionRestore,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      restored.auditSnapshot(),
+		})
+	})
+}
+
+// AddChild включить роль ChildId в роль ParentId. Связь, которая замкнула бы цикл, отклоняется.
+func (svc *Service) AddChild(ctx context.Context, request HierarchyRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	for _, id := range []int64{request.ParentId, request.ChildId} {
+		if _, err = svc.repo.FindById(id); err != nil {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("error finding role with id %d: %v", id, err),
+			}
+		}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "adding child role", func(tx *sqlx.Tx) error {
+		if err := svc.repo.LockHierarchyTx(tx); err != nil {
+			return fmt.Errorf("error locking role hierarchy: %w", err)
+		}
+		// цикл появится, если родитель уже входит в дочернюю роль
+		cycle, err := svc.repo.IsDescendantTx(tx, request.ChildId, request.ParentId)
+		if err != nil {
+			return fmt.Errorf("error checking role hierarchy: %w", err)
+		}
+		if cycle {
+			return common.RequestValidationError{
+				Message: fmt.Sprintf("role %d already includes role %d, adding it as a child would create a cycle",
+					request.ChildId, request.ParentId),
+			}
+		}
+		added, err := svc.repo.AddChildTx(tx, request.ParentId, request.ChildId)
+		if err != nil {
+			return fmt.Errorf("error adding role %d to role %d: %w", request.ChildId, request.ParentId, err)
+		}
+		if !added {
+			return nil
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionAddChild,
+			EntityType: auditEntityType,
+			EntityId:   request.ParentId,
+			After:      hierarchySnapshot{ParentId: request.ParentId, ChildId: request.ChildId},
+		})
+	})
+}
+
+// RemoveChild исключить роль ChildId из роли ParentId
+func (svc *Service) RemoveChild(ctx context.Context, request HierarchyRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "removing child role", func(tx *sqlx.Tx) error {
+		removed, err := svc.repo.RemoveChildTx(tx, request.ParentId, request.ChildId)
+		if err != nil {
+			return fmt.Errorf("error removing role %d from role %d: %w", request.ChildId, request.ParentId, err)
+		}
+		if !removed {
+			return common.NotFoundError{
+				Message: fmt.Sprintf("role %d does not include role %d", request.ParentId, request.ChildId),
+			}
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionRemoveChild,
+			EntityType: auditEntityType,
+			EntityId:   request.ParentId,
+			Before:     hierarchySnapshot{ParentId: request.ParentId, ChildId: request.ChildId},
+		})
+	})
+}
+
+// FindAncestors роли, которые включают в себя роль
+func (svc *Service) FindAncestors(request IdRequest) ([]Response, error) {
+	return svc.findRelatives(request, "ancestors", svc.repo.FindAncestors)
+}
+
+// FindDescendants роли, которые роль включает в себя
+func (svc *Service) FindDescendants(request IdRequest) ([]Response, error) {
+	return svc.findRelatives(request, "descendants", svc.repo.FindDescendants)
+}
+
+func (svc *Service) findRelatives(request IdRequest, kind string, find func(id int64) ([]Entity, error)) ([]Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.repo.FindById(request.Id); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding role with id %d: %v", request.Id, err),
+		}
+	}
+	entities, err := find(request.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving %s of role %d: %w", kind, request.Id, err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
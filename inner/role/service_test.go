@@ -1,10 +1,14 @@
 package role
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
 	"idm/inner/common"
 	"idm/inner/validator"
 	"testing"
@@ -16,7 +20,7 @@ type StubRepo struct {
 	FindByIdError  error
 }
 
-func (s *StubRepo) Save(e Entity) (int64, error) {
+func (s *StubRepo) SaveTx(tx *sqlx.Tx, e Entity) (int64, error) {
 	panic("implement me")
 }
 
@@ -24,7 +28,15 @@ func (s *StubRepo) FindById(id int64) (Entity, error) {
 	return s.FindByIdResult, s.FindByIdError
 }
 
-func (s *StubRepo) FindAll() ([]Entity, error) {
+func (s *StubRepo) FindByIdAsOf(id int64, at time.Time) (Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) Count(request ListRequest) (int64, error) {
 	panic("implement me")
 }
 
@@ -32,11 +44,47 @@ func (s *StubRepo) FindAllByIds(ids []int64) ([]Entity, error) {
 	panic("implement me")
 }
 
-func (s *StubRepo) DeleteById(id int64) error {
+func (s *StubRepo) DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) LockByIdTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) BeginTransaction() (*sqlx.Tx, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) LockHierarchyTx(tx *sqlx.Tx) error {
+	panic("implement me")
+}
+
+func (s *StubRepo) IsDescendantTx(tx *sqlx.Tx, ancestorId, candidateId int64) (bool, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) AddChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) RemoveChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) FindAncestors(id int64) ([]Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) FindDescendants(id int64) ([]Entity, error) {
+	panic("implement me")
+}
+
+func (s *StubRepo) RestoreTx(tx *sqlx.Tx, id int64) (Entity, error) {
 	panic("implement me")
 }
 
-func (s *StubRepo) DeleteAllByIds(ids []int64) error {
+func (s *StubRepo) UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (bool, error) {
 	panic("implement me")
 }
 
@@ -44,66 +92,156 @@ type MockRepo struct {
 	mock.Mock
 }
 
-func (m *MockRepo) Save(e Entity) (int64, error) {
-	args := m.Called(e)
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (int64, error) {
+	args := m.Called(tx, e)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepo) FindAll() ([]Entity, error) {
-	args := m.Called()
+func (m *MockRepo) FindPage(request ListRequest, after *common.Cursor) ([]Entity, error) {
+	args := m.Called(request, after)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
+func (m *MockRepo) Count(request ListRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepo) FindAllByIds(ids []int64) ([]Entity, error) {
 	args := m.Called(ids)
 	return args.Get(0).([]Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteById(id int64) error {
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, ids []int64) ([]Entity, error) {
+	args := m.Called(tx, ids)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) LockByIdTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (role Entity, err error) {
 	args := m.Called(id)
-	return args.Error(0)
+	return args.Get(0).(Entity), args.Error(1)
 }
 
-func (m *MockRepo) DeleteAllByIds(ids []int64) error {
-	args := m.Called(ids)
+func (m *MockRepo) FindByIdAsOf(id int64, at time.Time) (Entity, error) {
+	args := m.Called(id, at)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) LockHierarchyTx(tx *sqlx.Tx) error {
+	args := m.Called(tx)
 	return args.Error(0)
 }
 
-func (m *MockRepo) FindById(id int64) (role Entity, err error) {
+func (m *MockRepo) IsDescendantTx(tx *sqlx.Tx, ancestorId, candidateId int64) (bool, error) {
+	args := m.Called(tx, ancestorId, candidateId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) AddChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error) {
+	args := m.Called(tx, parentId, childId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) RemoveChildTx(tx *sqlx.Tx, parentId, childId int64) (bool, error) {
+	args := m.Called(tx, parentId, childId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindAncestors(id int64) ([]Entity, error) {
 	args := m.Called(id)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) RestoreTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
 	return args.Get(0).(Entity), args.Error(1)
 }
 
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity, version *time.Time) (bool, error) {
+	args := m.Called(tx, e, version)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindDescendants(id int64) ([]Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
 func TestServiceCreate(t *testing.T) {
 	a := assert.New(t)
+	var noTx *sqlx.Tx
 
-	t.Run("should create role", func(t *testing.T) {
+	t.Run("should create role and audit it", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
 
 		entity := Entity{Name: "Programmer"}
-		repo.On("Save", entity).Return(int64(11), nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("SaveTx", noTx, entity).Return(int64(11), nil)
 
-		id, err := svc.Create(CreateRequest{Name: entity.Name})
+		id, err := svc.Create(context.Background(), CreateRequest{Name: entity.Name})
 		a.Nil(err)
 		a.Equal(int64(11), id)
-		a.True(repo.AssertNumberOfCalls(t, "Save", 1))
+		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionCreate,
+			EntityType: "role",
+			EntityId:   11,
+			After:      auditSnapshot{Id: 11, Name: "Programmer"},
+		}}, auditor.events)
 	})
 
 	t.Run("should return wrapped error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
 
 		entity := Entity{Name: "Admin"}
 		dbErr := errors.New("database error")
 		want := fmt.Errorf("error saving role: %w", dbErr)
 
-		repo.On("Save", entity).Return(int64(0), dbErr)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("SaveTx", noTx, entity).Return(int64(0), dbErr)
 
-		id, err := svc.Create(CreateRequest{Name: entity.Name})
+		id, err := svc.Create(context.Background(), CreateRequest{Name: entity.Name})
 		a.Equal(int64(0), id)
 		a.EqualError(err, want.Error())
-		a.True(repo.AssertNumberOfCalls(t, "Save", 1))
+		a.True(repo.AssertNumberOfCalls(t, "SaveTx", 1))
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should fail when audit record cannot be saved", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditErr := errors.New("audit error")
+		svc := NewService(repo, &StubAuditor{err: auditErr}, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("SaveTx", noTx, Entity{Name: "Admin"}).Return(int64(11), nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "Admin"})
+		a.ErrorIs(err, auditErr)
 	})
 }
 
@@ -120,7 +258,7 @@ func TestServiceFindById(t *testing.T) {
 			},
 			FindByIdError: nil,
 		}
-		svc := NewService(stubRepo, validator.New())
+		svc := NewService(stubRepo, new(StubAuditor), validator.New())
 
 		want := stubRepo.FindByIdResult.toResponse()
 
@@ -134,7 +272,7 @@ func TestServiceFindById(t *testing.T) {
 			FindByIdResult: Entity{},
 			FindByIdError:  errors.New("database error"),
 		}
-		svc := NewService(stubRepo, validator.New())
+		svc := NewService(stubRepo, new(StubAuditor), validator.New())
 		want := common.NotFoundError{
 			Message: fmt.Sprintf("error finding role with id 1: %v", stubRepo.FindByIdError),
 		}
@@ -143,6 +281,19 @@ func TestServiceFindById(t *testing.T) {
 		a.NotNil(err)
 		a.Equal(want, err)
 	})
+
+	t.Run("should return role as of given time", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		asOf := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+		repo.On("FindByIdAsOf", int64(1), asOf).Return(Entity{Id: 1, Name: "Old Name"}, nil)
+
+		got, err := svc.FindById(IdRequest{Id: 1, AsOf: &asOf})
+		a.NoError(err)
+		a.Equal("Old Name", got.Name)
+		a.True(repo.AssertNotCalled(t, "FindById", mock.Anything))
+	})
 }
 
 func TestServiceFindAll(t *testing.T) {
@@ -150,7 +301,7 @@ func TestServiceFindAll(t *testing.T) {
 
 	t.Run("should return all roles", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		entities := []Entity{
 			{Id: 1, Name: "First", CreatedAt: time.Now(), UpdatedAt: time.Now()},
@@ -160,30 +311,33 @@ func TestServiceFindAll(t *testing.T) {
 			entities[0].toResponse(),
 			entities[1].toResponse(),
 		}
-		repo.On("FindAll").Return(entities, nil)
+		repo.On("FindPage", mock.AnythingOfType("ListRequest"), (*common.Cursor)(nil)).Return(entities, nil)
+		repo.On("Count", mock.AnythingOfType("ListRequest")).Return(int64(len(entities)), nil)
 
-		got, err := svc.FindAll()
+		got, err := svc.FindAll(ListRequest{})
 		a.Nil(err)
-		a.Equal(want, got)
-		a.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
+		a.Equal(want, got.Items)
+		a.Equal(int64(2), got.Total)
+		a.Empty(got.NextCursor)
+		a.True(repo.AssertNumberOfCalls(t, "FindPage", 1))
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		dbErr := errors.New("database error")
 		want := common.NotFoundError{
 			Message: fmt.Sprintf("error retrieving all roles: %v", dbErr),
 		}
 
-		repo.On("FindAll").Return([]Entity{}, dbErr)
+		repo.On("FindPage", mock.AnythingOfType("ListRequest"), (*common.Cursor)(nil)).Return([]Entity{}, dbErr)
 
-		got, err := svc.FindAll()
-		a.Nil(got)
+		got, err := svc.FindAll(ListRequest{})
+		a.Nil(got.Items)
 		a.NotNil(err)
 		a.Equal(err, want)
-		a.True(repo.AssertNumberOfCalls(t, "FindAll", 1))
+		a.True(repo.AssertNumberOfCalls(t, "FindPage", 1))
 	})
 }
 
@@ -192,7 +346,7 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 	t.Run("should return roles by ids", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		entities := []Entity{
@@ -214,7 +368,7 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		dbErr := errors.New("database error")
@@ -234,54 +388,73 @@ func TestServiceFindAllByIds(t *testing.T) {
 
 func TestServiceDeleteById(t *testing.T) {
 	a := assert.New(t)
+	var noTx *sqlx.Tx
 
-	t.Run("should delete role by id", func(t *testing.T) {
+	t.Run("should delete role by id and audit it", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
 
-		repo.On("DeleteById", int64(1)).Return(nil)
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{1}).Return([]Entity{{Id: 1, Name: "Admin", DeletedAt: &deletedAt}}, nil)
 
-		err := svc.DeleteById(IdRequest{Id: 1})
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 1})
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionDelete,
+			EntityType: "role",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Admin"},
+			After:      auditSnapshot{Id: 1, Name: "Admin", DeletedAt: &deletedAt},
+		}}, auditor.events)
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		dbErr := errors.New("database error")
 		want := common.NotFoundError{
 			Message: fmt.Sprintf("error deleting role with id %d: %v", 1, dbErr),
 		}
 
-		repo.On("DeleteById", int64(1)).Return(dbErr)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, []int64{1}).Return([]Entity(nil), dbErr)
 
-		err := svc.DeleteById(IdRequest{Id: 1})
+		err := svc.DeleteById(context.Background(), IdRequest{Id: 1})
 		a.NotNil(err)
 		a.Equal(err, want)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteById", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
 	})
 }
 
 func TestServiceDeleteAllByIds(t *testing.T) {
 	a := assert.New(t)
+	var noTx *sqlx.Tx
 
-	t.Run("should delete all roles by ids", func(t *testing.T) {
+	t.Run("should delete all roles by ids and audit only deleted ones", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
 
 		ids := []int64{1, 2}
-		repo.On("DeleteAllByIds", ids).Return(nil)
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		// роль 2 уже была удалена, поэтому репозиторий её не возвращает
+		repo.On("DeleteTx", noTx, ids).Return([]Entity{{Id: 1, DeletedAt: &deletedAt}}, nil)
 
-		err := svc.DeleteAllByIds(IdsRequest{Ids: ids})
+		err := svc.DeleteAllByIds(context.Background(), IdsRequest{Ids: ids})
 		a.Nil(err)
-		a.True(repo.AssertNumberOfCalls(t, "DeleteAllByIds", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+		a.Len(auditor.events, 1)
+		a.Equal(int64(1), auditor.events[0].EntityId)
 	})
 
 	t.Run("should return not found error", func(t *testing.T) {
 		repo := new(MockRepo)
-		svc := NewService(repo, validator.New())
+		svc := NewService(repo, new(StubAuditor), validator.New())
 
 		ids := []int64{1, 2}
 		dbErr := errors.New("database error")
@@ -289,11 +462,301 @@ func TestServiceDeleteAllByIds(t *testing.T) {
 			Message: fmt.Sprintf("error deleting roles by ids %v: %v", ids, dbErr),
 		}
 
-		repo.On("DeleteAllByIds", ids).Return(dbErr)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, ids).Return([]Entity(nil), dbErr)
 
-		err := svc.DeleteAllByIds(IdsRequest{Ids: ids})
+		err := svc.DeleteAllByIds(context.Background(), IdsRequest{Ids: ids})
 		a.NotNil(err)
 		a.EqualError(err, want.Error())
-		a.True(repo.AssertNumberOfCalls(t, "DeleteAllByIds", 1))
+		a.True(repo.AssertNumberOfCalls(t, "DeleteTx", 1))
+	})
+}
+
+func TestServiceAddChild(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should add child role", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Senior Accountant"}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2, Name: "Accountant"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockHierarchyTx", noTx).Return(nil)
+		repo.On("IsDescendantTx", noTx, int64(2), int64(1)).Return(false, nil)
+		repo.On("AddChildTx", noTx, int64(1), int64(2)).Return(true, nil)
+
+		err := svc.AddChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 2})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "AddChildTx", 1))
+		a.Equal([]audit.Event{{
+			Action:     "add_child",
+			EntityType: "role",
+			EntityId:   1,
+			After:      hierarchySnapshot{ParentId: 1, ChildId: 2},
+		}}, auditor.events)
+	})
+
+	t.Run("should reject cycle", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockHierarchyTx", noTx).Return(nil)
+		repo.On("IsDescendantTx", noTx, int64(2), int64(1)).Return(true, nil)
+
+		err := svc.AddChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 2})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "AddChildTx"))
+	})
+
+	t.Run("should reject role including itself", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		err := svc.AddChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 1})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return not found when child role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1}, nil)
+		repo.On("FindById", int64(2)).Return(Entity{}, errors.New("no rows"))
+
+		err := svc.AddChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceRemoveChild(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should remove child role and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RemoveChildTx", noTx, int64(1), int64(2)).Return(true, nil)
+
+		err := svc.RemoveChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 2})
+		a.NoError(err)
+		a.Equal([]audit.Event{{
+			Action:     "remove_child",
+			EntityType: "role",
+			EntityId:   1,
+			Before:     hierarchySnapshot{ParentId: 1, ChildId: 2},
+		}}, auditor.events)
+	})
+
+	t.Run("should return not found when roles are not related", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("RemoveChildTx", noTx, int64(1), int64(2)).Return(false, nil)
+
+		err := svc.RemoveChild(context.Background(), HierarchyRequest{ParentId: 1, ChildId: 2})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceFindAncestorsAndDescendants(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return ancestors", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		ancestors := []Entity{{Id: 1, Name: "Senior Accountant"}}
+		repo.On("FindById", int64(2)).Return(Entity{Id: 2}, nil)
+		repo.On("FindAncestors", int64(2)).Return(ancestors, nil)
+
+		got, err := svc.FindAncestors(IdRequest{Id: 2})
+		a.NoError(err)
+		a.Equal([]Response{ancestors[0].toResponse()}, got)
+	})
+
+	t.Run("should return wrapped error of descendants lookup", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		dbErr := errors.New("database error")
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1}, nil)
+		repo.On("FindDescendants", int64(1)).Return([]Entity{}, dbErr)
+
+		_, err := svc.FindDescendants(IdRequest{Id: 1})
+		a.EqualError(err, fmt.Errorf("error retrieving descendants of role 1: %w", dbErr).Error())
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should update role and audit both states", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		version := time.Now().Add(-time.Minute)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: "Manager"}, &version).Return(true, nil)
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Manager"}, nil)
+
+		got, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Manager", Version: &version})
+		a.NoError(err)
+		a.Equal("Manager", got.Name)
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionUpdate,
+			EntityType: "role",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Admin"},
+			After:      auditSnapshot{Id: 1, Name: "Manager"},
+		}}, auditor.events)
+	})
+
+	t.Run("should return precondition failed when version is stale", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		version := time.Now().Add(-time.Minute)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: "Manager"}, &version).Return(false, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Manager", Version: &version})
+		a.Equal(common.PreconditionFailedError{Message: "role with id 1 was modified concurrently"}, err)
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should return not found when role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Manager"})
+		a.Equal(common.NotFoundError{Message: "role with id 1 not found"}, err)
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should return not found when role is deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, DeletedAt: &deletedAt}, nil)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "Manager"})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "UpdateTx"))
+	})
+
+	t.Run("should keep name when patch does not pass it", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: "Admin"}, (*time.Time)(nil)).Return(true, nil)
+
+		_, err := svc.Patch(context.Background(), PatchRequest{Id: 1})
+		a.NoError(err)
+		a.True(repo.AssertNumberOfCalls(t, "UpdateTx", 1))
+	})
+}
+
+func TestServiceFindAllPage(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return next cursor when more roles exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		createdAt := time.Date(2025, 1, 1, 10, 0, 0, 123456000, time.UTC)
+		request := ListRequest{PageRequest: common.PageRequest{Limit: 1, Sort: "created_at", Order: "desc"}}
+		repo.On("FindPage", request, (*common.Cursor)(nil)).Return([]Entity{
+			{Id: 2, Name: "Manager", CreatedAt: createdAt},
+			{Id: 1, Name: "Admin", CreatedAt: createdAt.Add(-time.Hour)},
+		}, nil)
+		repo.On("Count", request).Return(int64(2), nil)
+
+		got, err := svc.FindAll(request)
+		a.NoError(err)
+		a.Len(got.Items, 1)
+		a.Equal(request.Next("2025-01-01T10:00:00.123456Z", 2), got.NextCursor)
+	})
+
+	t.Run("should reject malformed cursor", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		_, err := svc.FindAll(ListRequest{PageRequest: common.PageRequest{Cursor: "not a cursor"}})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
+
+func TestServiceRestore(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should restore deleted role and audit it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		deletedAt := time.Now()
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin", DeletedAt: &deletedAt}, nil)
+		repo.On("RestoreTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+
+		a.NoError(svc.Restore(context.Background(), IdRequest{Id: 1}))
+		a.Equal([]audit.Event{{
+			Action:     audit.ActionRestore,
+			EntityType: "role",
+			EntityId:   1,
+			Before:     auditSnapshot{Id: 1, Name: "Admin", DeletedAt: &deletedAt},
+			After:      auditSnapshot{Id: 1, Name: "Admin"},
+		}}, auditor.events)
+	})
+
+	t.Run("should do nothing when role is not deleted", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{Id: 1, Name: "Admin"}, nil)
+
+		a.NoError(svc.Restore(context.Background(), IdRequest{Id: 1}))
+		a.True(repo.AssertNotCalled(t, "RestoreTx"))
+		a.Empty(auditor.events)
+	})
+
+	t.Run("should return not found when role does not exist", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("LockByIdTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.Restore(context.Background(), IdRequest{Id: 1})
+		a.Equal(common.NotFoundError{Message: "role with id 1 not found"}, err)
 	})
 }
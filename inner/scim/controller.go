@@ -0,0 +1,396 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Разрешения, которые требуют маршруты SCIM: те же, что у REST API сотрудников, ролей и назначений
+const (
+	permissionEmployeesRead      = "employees:read"
+	permissionEmployeesCreate    = "employees:create"
+	permissionEmployeesUpdate    = "employees:update"
+	permissionEmployeesDelete    = "employees:delete"
+	permissionEmployeesLifecycle = "employees:lifecycle"
+	permissionRolesRead          = "roles:read"
+	permissionRolesCreate        = "roles:create"
+	permissionRolesUpdate        = "roles:update"
+	permissionRolesDelete        = "roles:delete"
+	permissionAssignmentsCreate  = "assignments:create"
+	permissionAssignmentsDelete  = "assignments:delete"
+)
+
+const (
+	mimeScimJson = "application/scim+json"
+	// pathPrefix путь группы маршрутов SCIM в web.Server
+	pathPrefix = "/scim/v2"
+)
+
+type Controller struct {
+	server      *web.Server
+	scimService Svc
+	logger      *common.Logger
+}
+
+type Svc interface {
+	FindUsers(request ListRequest) (ListResponse[User], error)
+	FindUser(request IdRequest) (User, error)
+	CreateUser(ctx context.Context, request CreateRequest) (User, error)
+	ReplaceUser(ctx context.Context, request ReplaceRequest) (User, error)
+	PatchUser(ctx context.Context, request PatchRequest) (User, error)
+	DeleteUser(ctx context.Context, request IdRequest) error
+	FindGroups(request ListRequest) (ListResponse[Group], error)
+	FindGroup(request IdRequest) (Group, error)
+	CreateGroup(ctx context.Context, request CreateRequest) (Group, error)
+	ReplaceGroup(ctx context.Context, request ReplaceRequest) (Group, error)
+	PatchGroup(ctx context.Context, request PatchRequest) (Group, error)
+	DeleteGroup(ctx context.Context, request IdRequest) error
+}
+
+func NewController(server *web.Server, scimService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:      server,
+		scimService: scimService,
+		logger:      logger,
+	}
+}
+
+// RegisterRoutes изменение пользователя может его приостановить или возобновить по жизненному циклу,
+// а изменение группы - назначить или отозвать роль, поэтому эти маршруты требуют и соответствующих разрешений
+func (c *Controller) RegisterRoutes() {
+	g := c.server.GroupScim
+	g.Get("/ServiceProviderConfig", c.ServiceProviderConfig)
+	g.Get("/ResourceTypes", c.ResourceTypes)
+	g.Get("/ResourceTypes/:id", c.ResourceTypes)
+	g.Get("/Schemas", c.Schemas)
+	g.Get("/Schemas/:id", c.Schemas)
+
+	g.Get("/Users", c.server.Require(permissionEmployeesRead), c.FindUsers)
+	g.Get("/Users/:id", c.server.Require(permissionEmployeesRead), c.FindUser)
+	g.Post("/Users", c.server.Require(permissionEmployeesCreate), c.CreateUser)
+	g.Put("/Users/:id", c.server.Require(permissionEmployeesUpdate), c.server.Require(permissionEmployeesLifecycle),
+		c.ReplaceUser)
+	g.Patch("/Users/:id", c.server.Require(permissionEmployeesUpdate), c.server.Require(permissionEmployeesLifecycle),
+		c.PatchUser)
+	g.Delete("/Users/:id", c.server.Require(permissionEmployeesDelete), c.DeleteUser)
+
+	g.Get("/Groups", c.server.Require(permissionRolesRead), c.FindGroups)
+	g.Get("/Groups/:id", c.server.Require(permissionRolesRead), c.FindGroup)
+	g.Post("/Groups", c.server.Require(permissionRolesCreate), c.server.Require(permissionAssignmentsCreate), c.CreateGroup)
+	g.Put("/Groups/:id", c.server.Require(permissionRolesUpdate), c.server.Require(permissionAssignmentsCreate),
+		c.server.Require(permissionAssignmentsDelete), c.ReplaceGroup)
+	g.Patch("/Groups/:id", c.server.Require(permissionRolesUpdate), c.server.Require(permissionAssignmentsCreate),
+		c.server.Require(permissionAssignmentsDelete), c.PatchGroup)
+	g.Delete("/Groups/:id", c.server.Require(permissionRolesDelete), c.DeleteGroup)
+}
+
+func (c *Controller) ServiceProviderConfig(ctx *fiber.Ctx) error {
+	return c.send(ctx, fiber.StatusOK, serviceProviderConfig(baseUrl(ctx)))
+}
+
+func (c *Controller) ResourceTypes(ctx *fiber.Ctx) error {
+	types := resourceTypes(baseUrl(ctx))
+	if id := ctx.Params("id"); id != "" {
+		for _, t := range types {
+			if t.Id == id {
+				return c.send(ctx, fiber.StatusOK, t)
+			}
+		}
+		return c.fail(ctx, "get resource type", notFound("resource type", id))
+	}
+	return c.send(ctx, fiber.StatusOK, listOf(types))
+}
+
+func (c *Controller) Schemas(ctx *fiber.Ctx) error {
+	all := schemas(baseUrl(ctx))
+	if id := ctx.Params("id"); id != "" {
+		for _, s := range all {
+			if s.Id == id {
+				return c.send(ctx, fiber.StatusOK, s)
+			}
+		}
+		return c.fail(ctx, "get schema", notFound("schema", id))
+	}
+	return c.send(ctx, fiber.StatusOK, listOf(all))
+}
+
+func (c *Controller) FindUsers(ctx *fiber.Ctx) error {
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "find scim users", err)
+	}
+	c.logger.Debug("find scim users: received request", zap.Any("request", request))
+	response, err := c.scimService.FindUsers(request)
+	if err != nil {
+		return c.fail(ctx, "find scim users", err)
+	}
+	for i := range response.Resources {
+		response.Resources[i].locate(baseUrl(ctx))
+	}
+	return c.send(ctx, fiber.StatusOK, response)
+}
+
+func (c *Controller) FindUser(ctx *fiber.Ctx) error {
+	user, err := c.scimService.FindUser(IdRequest{Id: ctx.Params("id")})
+	if err != nil {
+		return c.fail(ctx, "find scim user", err)
+	}
+	return c.sendUser(ctx, fiber.StatusOK, user)
+}
+
+func (c *Controller) CreateUser(ctx *fiber.Ctx) error {
+	resource, err := parseResource(ctx)
+	if err != nil {
+		return c.fail(ctx, "create scim user", err)
+	}
+	user, err := c.scimService.CreateUser(ctx.UserContext(), CreateRequest{Resource: resource})
+	if err != nil {
+		return c.fail(ctx, "create scim user", err)
+	}
+	c.logger.Debug("create scim user: success", zap.String("id", user.Id))
+	return c.sendUser(ctx, fiber.StatusCreated, user)
+}
+
+func (c *Controller) ReplaceUser(ctx *fiber.Ctx) error {
+	request, err := parseReplaceRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "replace scim user", err)
+	}
+	user, err := c.scimService.ReplaceUser(ctx.UserContext(), request)
+	if err != nil {
+		return c.fail(ctx, "replace scim user", err)
+	}
+	c.logger.Debug("replace scim user: success", zap.String("id", user.Id))
+	return c.sendUser(ctx, fiber.StatusOK, user)
+}
+
+func (c *Controller) PatchUser(ctx *fiber.Ctx) error {
+	request, err := parsePatchRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "patch scim user", err)
+	}
+	user, err := c.scimService.PatchUser(ctx.UserContext(), request)
+	if err != nil {
+		return c.fail(ctx, "patch scim user", err)
+	}
+	c.logger.Debug("patch scim user: success", zap.String("id", user.Id))
+	return c.sendUser(ctx, fiber.StatusOK, user)
+}
+
+func (c *Controller) DeleteUser(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if err := c.scimService.DeleteUser(ctx.UserContext(), IdRequest{Id: id}); err != nil {
+		return c.fail(ctx, "delete scim user", err)
+	}
+	c.logger.Debug("delete scim user: success", zap.String("id", id))
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (c *Controller) FindGroups(ctx *fiber.Ctx) error {
+	request, err := parseListRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "find scim groups", err)
+	}
+	c.logger.Debug("find scim groups: received request", zap.Any("request", request))
+	response, err := c.scimService.FindGroups(request)
+	if err != nil {
+		return c.fail(ctx, "find scim groups", err)
+	}
+	for i := range response.Resources {
+		response.Resources[i].locate(baseUrl(ctx))
+	}
+	return c.send(ctx, fiber.StatusOK, response)
+}
+
+func (c *Controller) FindGroup(ctx *fiber.Ctx) error {
+	group, err := c.scimService.FindGroup(IdRequest{Id: ctx.Params("id")})
+	if err != nil {
+		return c.fail(ctx, "find scim group", err)
+	}
+	return c.sendGroup(ctx, fiber.StatusOK, group)
+}
+
+func (c *Controller) CreateGroup(ctx *fiber.Ctx) error {
+	resource, err := parseResource(ctx)
+	if err != nil {
+		return c.fail(ctx, "create scim group", err)
+	}
+	group, err := c.scimService.CreateGroup(ctx.UserContext(), CreateRequest{Resource: resource})
+	if err != nil {
+		return c.fail(ctx, "create scim group", err)
+	}
+	c.logger.Debug("create scim group: success", zap.String("id", group.Id))
+	return c.sendGroup(ctx, fiber.StatusCreated, group)
+}
+
+func (c *Controller) ReplaceGroup(ctx *fiber.Ctx) error {
+	request, err := parseReplaceRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "replace scim group", err)
+	}
+	group, err := c.scimService.ReplaceGroup(ctx.UserContext(), request)
+	if err != nil {
+		return c.fail(ctx, "replace scim group", err)
+	}
+	c.logger.Debug("replace scim group: success", zap.String("id", group.Id))
+	return c.sendGroup(ctx, fiber.StatusOK, group)
+}
+
+func (c *Controller) PatchGroup(ctx *fiber.Ctx) error {
+	request, err := parsePatchRequest(ctx)
+	if err != nil {
+		return c.fail(ctx, "patch scim group", err)
+	}
+	group, err := c.scimService.PatchGroup(ctx.UserContext(), request)
+	if err != nil {
+		return c.fail(ctx, "patch scim group", err)
+	}
+	c.logger.Debug("patch scim group: success", zap.String("id", group.Id))
+	return c.sendGroup(ctx, fiber.StatusOK, group)
+}
+
+func (c *Controller) DeleteGroup(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+	if err := c.scimService.DeleteGroup(ctx.UserContext(), IdRequest{Id: id}); err != nil {
+		return c.fail(ctx, "delete scim group", err)
+	}
+	c.logger.Debug("delete scim group: success", zap.String("id", id))
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (c *Controller) sendUser(ctx *fiber.Ctx, status int, user User) error {
+	user.locate(baseUrl(ctx))
+	ctx.Set(fiber.HeaderETag, user.Meta.Version)
+	if status == fiber.StatusCreated {
+		ctx.Set(fiber.HeaderLocation, user.Meta.Location)
+	}
+	return c.send(ctx, status, user)
+}
+
+func (c *Controller) sendGroup(ctx *fiber.Ctx, status int, group Group) error {
+	group.locate(baseUrl(ctx))
+	ctx.Set(fiber.HeaderETag, group.Meta.Version)
+	if status == fiber.StatusCreated {
+		ctx.Set(fiber.HeaderLocation, group.Meta.Location)
+	}
+	return c.send(ctx, status, group)
+}
+
+// send ответ SCIM: тело без общего конверта API и с типом application/scim+json
+func (c *Controller) send(ctx *fiber.Ctx, status int, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return c.fail(ctx, "encode scim response", err)
+	}
+	ctx.Set(fiber.HeaderContentType, mimeScimJson)
+	return ctx.Status(status).Send(data)
+}
+
+// fail ответ об ошибке в формате SCIM; внутренние ошибки логируются, но клиенту не раскрываются
+func (c *Controller) fail(ctx *fiber.Ctx, operation string, err error) error {
+	scimErr := toError(err)
+	if scimErr.Status == fiber.StatusInternalServerError {
+		c.logger.Error(operation+": service error", zap.Error(err))
+	} else {
+		c.logger.Warn(operation+": rejected", zap.Error(err))
+	}
+	return sendError(ctx, scimErr)
+}
+
+func sendError(ctx *fiber.Ctx, scimErr Error) error {
+	data, _ := json.Marshal(scimErr.response())
+	ctx.Set(fiber.HeaderContentType, mimeScimJson)
+	return ctx.Status(scimErr.Status).Send(data)
+}
+
+// ErrorResponses middleware группы SCIM: отказы аутентификации и авторизации, которые пишутся через
+// common.ErrResponse, клиент получает в формате ошибки SCIM
+var ErrorResponses = common.WithErrorWriter(func(ctx *fiber.Ctx, code int, message string) error {
+	return sendError(ctx, Error{Status: code, Detail: message})
+})
+
+// baseUrl адрес /scim/v2 этого сервера для meta.location и $ref
+func baseUrl(ctx *fiber.Ctx) string {
+	return ctx.BaseURL() + pathPrefix
+}
+
+// listOf ответ ListResponse со всеми элементами, без постраничной разбивки
+func listOf[T any](items []T) ListResponse[T] {
+	return ListResponse[T]{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: len(items),
+		StartIndex:   1,
+		ItemsPerPage: len(items),
+		Resources:    items,
+	}
+}
+
+func parseListRequest(ctx *fiber.Ctx) (ListRequest, error) {
+	request := ListRequest{Filter: ctx.Query("filter")}
+	if value := ctx.Query("startIndex"); value != "" {
+		startIndex, err := strconv.Atoi(value)
+		if err != nil {
+			return ListRequest{}, invalidValue("invalid startIndex")
+		}
+		request.StartIndex = startIndex
+	}
+	if value := ctx.Query("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return ListRequest{}, invalidValue("invalid count")
+		}
+		request.Count = &count
+	}
+	if value := ctx.Query("excludedAttributes"); value != "" {
+		request.ExcludedAttributes = strings.Split(value, ",")
+	}
+	return request, nil
+}
+
+// parseResource тело разбирается вручную: BodyParser fiber не знает тип application/scim+json
+func parseResource(ctx *fiber.Ctx) (map[string]any, error) {
+	var resource map[string]any
+	if err := json.Unmarshal(ctx.Body(), &resource); err != nil || resource == nil {
+		return nil, Error{Status: fiber.StatusBadRequest, ScimType: scimTypeInvalidSyntax, Detail: "request body must be a json object"}
+	}
+	return resource, nil
+}
+
+func parseReplaceRequest(ctx *fiber.Ctx) (ReplaceRequest, error) {
+	version, err := parseVersion(ctx)
+	if err != nil {
+		return ReplaceRequest{}, err
+	}
+	resource, err := parseResource(ctx)
+	if err != nil {
+		return ReplaceRequest{}, err
+	}
+	return ReplaceRequest{Id: ctx.Params("id"), Resource: resource, Version: version}, nil
+}
+
+func parsePatchRequest(ctx *fiber.Ctx) (PatchRequest, error) {
+	version, err := parseVersion(ctx)
+	if err != nil {
+		return PatchRequest{}, err
+	}
+	var patch PatchOp
+	if err = json.Unmarshal(ctx.Body(), &patch); err != nil {
+		return PatchRequest{}, Error{Status: fiber.StatusBadRequest, ScimType: scimTypeInvalidSyntax, Detail: "invalid patch request body"}
+	}
+	return PatchRequest{Id: ctx.Params("id"), PatchOp: patch, Version: version}, nil
+}
+
+func parseVersion(ctx *fiber.Ctx) (*time.Time, error) {
+	version, err := common.ParseIfMatch(ctx.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		return nil, Error{Status: fiber.StatusBadRequest, ScimType: scimTypeInvalidValue, Detail: err.Error()}
+	}
+	return version, nil
+}
@@ -0,0 +1,367 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/auth"
+	"idm/inner/common"
+	"idm/inner/web"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) FindUsers(request ListRequest) (ListResponse[User], error) {
+	args := svc.Called(request)
+	return args.Get(0).(ListResponse[User]), args.Error(1)
+}
+
+func (svc *MockService) FindUser(request IdRequest) (User, error) {
+	args := svc.Called(request)
+	return args.Get(0).(User), args.Error(1)
+}
+
+func (svc *MockService) CreateUser(ctx context.Context, request CreateRequest) (User, error) {
+	args := svc.Called(request)
+	return args.Get(0).(User), args.Error(1)
+}
+
+func (svc *MockService) ReplaceUser(ctx context.Context, request ReplaceRequest) (User, error) {
+	args := svc.Called(request)
+	return args.Get(0).(User), args.Error(1)
+}
+
+func (svc *MockService) PatchUser(ctx context.Context, request PatchRequest) (User, error) {
+	args := svc.Called(request)
+	return args.Get(0).(User), args.Error(1)
+}
+
+func (svc *MockService) DeleteUser(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindGroups(request ListRequest) (ListResponse[Group], error) {
+	args := svc.Called(request)
+	return args.Get(0).(ListResponse[Group]), args.Error(1)
+}
+
+func (svc *MockService) FindGroup(request IdRequest) (Group, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Group), args.Error(1)
+}
+
+func (svc *MockService) CreateGroup(ctx context.Context, request CreateRequest) (Group, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Group), args.Error(1)
+}
+
+func (svc *MockService) ReplaceGroup(ctx context.Context, request ReplaceRequest) (Group, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Group), args.Error(1)
+}
+
+func (svc *MockService) PatchGroup(ctx context.Context, request PatchRequest) (Group, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Group), args.Error(1)
+}
+
+func (svc *MockService) DeleteGroup(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func newTestApp(svc Svc) *fiber.App {
+	server := webtest.NewServer()
+	controller := NewController(server, svc, &common.Logger{Logger: zap.NewNop()})
+	controller.RegisterRoutes()
+	return server.App
+}
+
+func send(t *testing.T, app *fiber.App, method, target, body string) (*http.Response, map[string]any) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set(fiber.HeaderContentType, mimeScimJson)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytesData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responseBody map[string]any
+	if len(bytesData) > 0 {
+		if err = json.Unmarshal(bytesData, &responseBody); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, responseBody
+}
+
+// StubApiKeys принимает любой ключ как ключ субъекта robot без разрешений
+type StubApiKeys struct{}
+
+func (StubApiKeys) VerifyApiKey(key string) (string, []string, error) {
+	return "robot", []string{}, nil
+}
+
+type DenyChecker struct{}
+
+func (DenyChecker) Holds(ctx context.Context, permission string) (bool, error) {
+	return false, nil
+}
+
+// newSecuredApp приложение с настоящими аутентификацией и авторизацией, как в cmd/main.go
+func newSecuredApp(svc Svc) *fiber.App {
+	logger := &common.Logger{Logger: zap.NewNop()}
+	server := web.NewServer()
+	authenticator := auth.NewAuthenticatorWithKeys(nil, "issuer", "audience", logger)
+	authenticator.AcceptApiKeys(StubApiKeys{})
+	server.GroupScim.Use(ErrorResponses, authenticator.Middleware)
+	server.Authorizer = auth.NewAuthorizer(DenyChecker{}, logger)
+	NewController(server, svc, logger).RegisterRoutes()
+	return server.App
+}
+
+func TestControllerAccess(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return scim error without credentials", func(t *testing.T) {
+		svc := new(MockService)
+
+		resp, body := send(t, newSecuredApp(svc), fiber.MethodGet, "/scim/v2/Users", "")
+		a.Equal(http.StatusUnauthorized, resp.StatusCode)
+		a.Equal(mimeScimJson, resp.Header.Get(fiber.HeaderContentType))
+		a.Equal([]any{schemaError}, body["schemas"])
+		a.Equal("401", body["status"])
+		a.Equal("missing credentials", body["detail"])
+		a.True(svc.AssertNotCalled(t, "FindUsers", mock.Anything))
+	})
+
+	t.Run("should return scim error without permission", func(t *testing.T) {
+		svc := new(MockService)
+		req := httptest.NewRequest(fiber.MethodGet, "/scim/v2/Users", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "ApiKey idm_0011.secret")
+
+		resp, err := newSecuredApp(svc).Test(req)
+		a.NoError(err)
+		var body map[string]any
+		a.NoError(json.NewDecoder(resp.Body).Decode(&body))
+		a.Equal(http.StatusForbidden, resp.StatusCode)
+		a.Equal([]any{schemaError}, body["schemas"])
+		a.Equal("403", body["status"])
+		a.Equal("missing permission employees:read", body["detail"])
+		a.True(svc.AssertNotCalled(t, "FindUsers", mock.Anything))
+	})
+}
+
+func TestControllerUsers(t *testing.T) {
+	a := assert.New(t)
+	user := User{Schemas: []string{schemaUser}, Id: "1", UserName: "alice", DisplayName: "Alice", Active: true,
+		Groups: []Reference{{Value: "4", Display: "devs"}}, Meta: Meta{ResourceType: resourceUser, Version: `"100"`}}
+
+	t.Run("should return user with location and etag", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("FindUser", IdRequest{Id: "1"}).Return(user, nil)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet, "/scim/v2/Users/1", "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(mimeScimJson, resp.Header.Get(fiber.HeaderContentType))
+		a.Equal(`"100"`, resp.Header.Get(fiber.HeaderETag))
+		a.Equal("alice", body["userName"])
+		a.Equal("http://example.com/scim/v2/Users/1", body["meta"].(map[string]any)["location"])
+		group := body["groups"].([]any)[0].(map[string]any)
+		a.Equal("http://example.com/scim/v2/Groups/4", group["$ref"])
+	})
+
+	t.Run("should return scim error when user not found", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("FindUser", IdRequest{Id: "9"}).Return(User{}, common.NotFoundError{Message: "employee not found"})
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet, "/scim/v2/Users/9", "")
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+		a.Equal([]any{schemaError}, body["schemas"])
+		a.Equal("404", body["status"])
+		a.Equal("employee not found", body["detail"])
+	})
+
+	t.Run("should parse list parameters", func(t *testing.T) {
+		svc := new(MockService)
+		count := 5
+		request := ListRequest{Filter: `userName eq "alice"`, StartIndex: 3, Count: &count}
+		svc.On("FindUsers", request).Return(ListResponse[User]{
+			Schemas: []string{schemaListResponse}, TotalResults: 3, StartIndex: 3, ItemsPerPage: 1, Resources: []User{user},
+		}, nil)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet,
+			"/scim/v2/Users?filter=userName+eq+%22alice%22&startIndex=3&count=5", "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(float64(3), body["totalResults"])
+		a.Len(body["Resources"], 1)
+	})
+
+	t.Run("should reject invalid count", func(t *testing.T) {
+		svc := new(MockService)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet, "/scim/v2/Users?count=many", "")
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.Equal(scimTypeInvalidValue, body["scimType"])
+		a.True(svc.AssertNotCalled(t, "FindUsers", mock.Anything))
+	})
+
+	t.Run("should return invalidFilter", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("FindUsers", ListRequest{Filter: "userName eq"}).
+			Return(ListResponse[User]{}, FilterError{Message: "expected value after eq"})
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet, "/scim/v2/Users?filter=userName+eq", "")
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.Equal(scimTypeInvalidFilter, body["scimType"])
+	})
+
+	t.Run("should create user", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("CreateUser", CreateRequest{Resource: map[string]any{"userName": "alice"}}).Return(user, nil)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodPost, "/scim/v2/Users", `{"userName": "alice"}`)
+		a.Equal(http.StatusCreated, resp.StatusCode)
+		a.Equal("http://example.com/scim/v2/Users/1", resp.Header.Get(fiber.HeaderLocation))
+		a.Equal("1", body["id"])
+	})
+
+	t.Run("should return conflict for existing user", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("CreateUser", mock.Anything).Return(User{}, common.AlreadyExistsError{Message: "employee already exists"})
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodPost, "/scim/v2/Users", `{"userName": "alice"}`)
+		a.Equal(http.StatusConflict, resp.StatusCode)
+		a.Equal(scimTypeUniqueness, body["scimType"])
+	})
+
+	t.Run("should reject malformed body", func(t *testing.T) {
+		svc := new(MockService)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodPost, "/scim/v2/Users", `[1, 2]`)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.Equal(scimTypeInvalidSyntax, body["scimType"])
+		a.True(svc.AssertNotCalled(t, "CreateUser", mock.Anything))
+	})
+
+	t.Run("should patch user", func(t *testing.T) {
+		svc := new(MockService)
+		request := PatchRequest{Id: "1", PatchOp: PatchOp{
+			Schemas:    []string{schemaPatchOp},
+			Operations: []PatchOperation{{Op: "replace", Path: "active", Value: false}},
+		}}
+		svc.On("PatchUser", request).Return(user, nil)
+
+		resp, _ := send(t, newTestApp(svc), fiber.MethodPatch, "/scim/v2/Users/1", `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "replace", "path": "active", "value": false}]
+		}`)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return internal server error without details", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("DeleteUser", IdRequest{Id: "1"}).Return(errors.New("database error"))
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodDelete, "/scim/v2/Users/1", "")
+		a.Equal(http.StatusInternalServerError, resp.StatusCode)
+		a.Equal("internal server error", body["detail"])
+	})
+
+	t.Run("should delete user", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("DeleteUser", IdRequest{Id: "1"}).Return(nil)
+
+		resp, _ := send(t, newTestApp(svc), fiber.MethodDelete, "/scim/v2/Users/1", "")
+		a.Equal(http.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestControllerGroups(t *testing.T) {
+	a := assert.New(t)
+	group := Group{Schemas: []string{schemaGroup}, Id: "4", DisplayName: "devs",
+		Members: []Reference{{Value: "1", Display: "Alice"}}, Meta: Meta{ResourceType: resourceGroup, Version: `"100"`}}
+
+	t.Run("should list groups without members", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("FindGroups", ListRequest{ExcludedAttributes: []string{"members"}}).Return(ListResponse[Group]{
+			Schemas: []string{schemaListResponse}, TotalResults: 1, StartIndex: 1, ItemsPerPage: 1,
+			Resources: []Group{{Schemas: []string{schemaGroup}, Id: "4", DisplayName: "devs"}},
+		}, nil)
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodGet, "/scim/v2/Groups?excludedAttributes=members", "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		resource := body["Resources"].([]any)[0].(map[string]any)
+		_, hasMembers := resource["members"]
+		a.False(hasMembers)
+	})
+
+	t.Run("should replace group with version", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("ReplaceGroup", mock.MatchedBy(func(request ReplaceRequest) bool {
+			return request.Id == "4" && request.Version != nil && request.Resource["displayName"] == "devs"
+		})).Return(group, nil)
+
+		req := httptest.NewRequest(fiber.MethodPut, "/scim/v2/Groups/4", strings.NewReader(`{"displayName": "devs"}`))
+		req.Header.Set(fiber.HeaderIfMatch, `W/"100"`)
+		resp, err := newTestApp(svc).Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should return precondition failed", func(t *testing.T) {
+		svc := new(MockService)
+		svc.On("PatchGroup", mock.Anything).Return(Group{}, common.PreconditionFailedError{Message: "role was modified"})
+
+		resp, body := send(t, newTestApp(svc), fiber.MethodPatch, "/scim/v2/Groups/4",
+			`{"Operations": [{"op": "add", "path": "members", "value": [{"value": "2"}]}]}`)
+		a.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+		a.Equal("412", body["status"])
+	})
+}
+
+func TestControllerDiscovery(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return service provider config", func(t *testing.T) {
+		resp, body := send(t, newTestApp(new(MockService)), fiber.MethodGet, "/scim/v2/ServiceProviderConfig", "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(true, body["patch"].(map[string]any)["supported"])
+		a.Equal(false, body["bulk"].(map[string]any)["supported"])
+		a.Equal(float64(maxCount), body["filter"].(map[string]any)["maxResults"])
+	})
+
+	t.Run("should return resource types and schemas", func(t *testing.T) {
+		app := newTestApp(new(MockService))
+
+		resp, body := send(t, app, fiber.MethodGet, "/scim/v2/ResourceTypes", "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(float64(2), body["totalResults"])
+
+		resp, body = send(t, app, fiber.MethodGet, "/scim/v2/Schemas/"+schemaGroup, "")
+		a.Equal(http.StatusOK, resp.StatusCode)
+		a.Equal(resourceGroup, body["name"])
+
+		resp, _ = send(t, app, fiber.MethodGet, "/scim/v2/ResourceTypes/Device", "")
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
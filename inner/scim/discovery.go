@@ -0,0 +1,181 @@
+package scim
+
+// Ресурсы обнаружения (RFC 7644, раздел 4): клиенты читают их, чтобы узнать, какие
+// возможности и атрибуты поддерживает сервер
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterConfig struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkConfig struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 supported              `json:"patch"`
+	Bulk                  bulkConfig             `json:"bulk"`
+	Filter                filterConfig           `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	Etag                  supported              `json:"etag"`
+	AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+	Meta                  discoveryMeta          `json:"meta"`
+}
+
+type discoveryMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+type ResourceType struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id"`
+	Name        string        `json:"name"`
+	Endpoint    string        `json:"endpoint"`
+	Description string        `json:"description"`
+	Schema      string        `json:"schema"`
+	Meta        discoveryMeta `json:"meta"`
+}
+
+type Schema struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Attributes  []Attribute   `json:"attributes"`
+	Meta        discoveryMeta `json:"meta"`
+}
+
+type Attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []Attribute `json:"subAttributes,omitempty"`
+}
+
+func stringAttribute(name string, required bool, mutability, uniqueness string) Attribute {
+	return Attribute{
+		Name:       name,
+		Type:       "string",
+		Required:   required,
+		Mutability: mutability,
+		Returned:   "default",
+		Uniqueness: uniqueness,
+	}
+}
+
+// referenceAttribute многозначная ссылка на другие ресурсы: members группы или groups пользователя
+func referenceAttribute(name, mutability string) Attribute {
+	return Attribute{
+		Name:        name,
+		Type:        "complex",
+		MultiValued: true,
+		Mutability:  mutability,
+		Returned:    "default",
+		Uniqueness:  "none",
+		SubAttributes: []Attribute{
+			stringAttribute("value", false, "immutable", "none"),
+			stringAttribute("display", false, "readOnly", "none"),
+			{Name: "$ref", Type: "reference", Mutability: "immutable", Returned: "default", Uniqueness: "none"},
+		},
+	}
+}
+
+func serviceProviderConfig(base string) ServiceProviderConfig {
+	return ServiceProviderConfig{
+		Schemas:        []string{schemaServiceProviderConfig},
+		Patch:          supported{Supported: true},
+		Bulk:           bulkConfig{Supported: false},
+		Filter:         filterConfig{Supported: true, MaxResults: maxCount},
+		ChangePassword: supported{Supported: false},
+		Sort:           supported{Supported: false},
+		Etag:           supported{Supported: true},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with a bearer token accepted by the IDM API",
+			Primary:     true,
+		}},
+		Meta: discoveryMeta{ResourceType: "ServiceProviderConfig", Location: base + "/ServiceProviderConfig"},
+	}
+}
+
+func resourceTypes(base string) []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{schemaResourceType},
+			Id:          resourceUser,
+			Name:        resourceUser,
+			Endpoint:    "/Users",
+			Description: "Employee",
+			Schema:      schemaUser,
+			Meta:        discoveryMeta{ResourceType: "ResourceType", Location: base + "/ResourceTypes/" + resourceUser},
+		},
+		{
+			Schemas:     []string{schemaResourceType},
+			Id:          resourceGroup,
+			Name:        resourceGroup,
+			Endpoint:    "/Groups",
+			Description: "Role with its current assignments",
+			Schema:      schemaGroup,
+			Meta:        discoveryMeta{ResourceType: "ResourceType", Location: base + "/ResourceTypes/" + resourceGroup},
+		},
+	}
+}
+
+func schemas(base string) []Schema {
+	return []Schema{
+		{
+			Schemas:     []string{schemaSchema},
+			Id:          schemaUser,
+			Name:        resourceUser,
+			Description: "Employee",
+			Attributes: []Attribute{
+				stringAttribute("userName", true, "readWrite", "server"),
+				{
+					Name:          "name",
+					Type:          "complex",
+					Mutability:    "readWrite",
+					Returned:      "default",
+					Uniqueness:    "none",
+					SubAttributes: []Attribute{stringAttribute("formatted", false, "readWrite", "none")},
+				},
+				stringAttribute("displayName", false, "readWrite", "server"),
+				{Name: "active", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+				referenceAttribute(attrGroups, "readOnly"),
+			},
+			Meta: discoveryMeta{ResourceType: "Schema", Location: base + "/Schemas/" + schemaUser},
+		},
+		{
+			Schemas:     []string{schemaSchema},
+			Id:          schemaGroup,
+			Name:        resourceGroup,
+			Description: "Role",
+			Attributes: []Attribute{
+				stringAttribute("displayName", true, "readWrite", "server"),
+				referenceAttribute(attrMembers, "readWrite"),
+			},
+			Meta: discoveryMeta{ResourceType: "Schema", Location: base + "/Schemas/" + schemaGroup},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"idm/inner/common"
+	"strconv"
+)
+
+// Значения scimType из RFC 7644, раздел 3.12
+const (
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeInvalidPath   = "invalidPath"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeNoTarget      = "noTarget"
+	scimTypeUniqueness    = "uniqueness"
+)
+
+// Error ошибка SCIM с HTTP-статусом и необязательным scimType
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (err Error) Error() string {
+	return err.Detail
+}
+
+// errorResponse тело ответа об ошибке; status по RFC передаётся строкой
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (err Error) response() errorResponse {
+	return errorResponse{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(err.Status),
+		ScimType: err.ScimType,
+		Detail:   err.Detail,
+	}
+}
+
+// toError привести ошибку сервисов IDM к ошибке SCIM; неизвестные ошибки - 500
+func toError(err error) Error {
+	var scimErr Error
+	var filterErr FilterError
+	switch {
+	case errors.As(err, &scimErr):
+		return scimErr
+	case errors.As(err, &filterErr):
+		return Error{Status: fiber.StatusBadRequest, ScimType: scimTypeInvalidFilter, Detail: filterErr.Message}
+	case errors.As(err, &common.AlreadyExistsError{}):
+		return Error{Status: fiber.StatusConflict, ScimType: scimTypeUniqueness, Detail: err.Error()}
+	case errors.As(err, &common.SodConflictError{}):
+		return Error{Status: fiber.StatusConflict, Detail: err.Error()}
+	case errors.As(err, &common.RequestValidationError{}):
+		return Error{Status: fiber.StatusBadRequest, ScimType: scimTypeInvalidValue, Detail: err.Error()}
+	case errors.As(err, &common.ForbiddenError{}):
+		return Error{Status: fiber.StatusForbidden, Detail: err.Error()}
+	case errors.As(err, &common.NotFoundError{}):
+		return Error{Status: fiber.StatusNotFound, Detail: err.Error()}
+	case errors.As(err, &common.PreconditionFailedError{}):
+		return Error{Status: fiber.StatusPreconditionFailed, Detail: err.Error()}
+	default:
+		return Error{Status: fiber.StatusInternalServerError, Detail: "internal server error"}
+	}
+}
+
+func notFound(resourceType, id string) error {
+	return Error{Status: fiber.StatusNotFound, Detail: fmt.Sprintf("%s %s not found", resourceType, id)}
+}
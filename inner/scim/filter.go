@@ -0,0 +1,536 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter условие фильтра SCIM (RFC 7644, раздел 3.4.2.2), проверяемое на ресурсе в JSON-представлении
+type Filter interface {
+	Matches(resource map[string]any) bool
+}
+
+// attrPath путь к атрибуту: attr или attr.sub; префикс схемы (urn:...:User:) отбрасывается
+type attrPath struct {
+	attr string
+	sub  string
+}
+
+func (p attrPath) String() string {
+	if p.sub == "" {
+		return p.attr
+	}
+	return p.attr + "." + p.sub
+}
+
+// values значения атрибута в ресурсе; у многозначного комплексного атрибута без податрибута
+// сравнивается его податрибут value, как требует RFC 7644
+func (p attrPath) values(resource map[string]any) []any {
+	value, ok := lookup(resource, p.attr)
+	if !ok || value == nil {
+		return nil
+	}
+	items, multi := value.([]any)
+	if !multi {
+		items = []any{value}
+	}
+	var values []any
+	for _, item := range items {
+		complexItem, isComplex := item.(map[string]any)
+		switch {
+		case p.sub != "" && isComplex:
+			if sub, ok := lookup(complexItem, p.sub); ok && sub != nil {
+				values = append(values, sub)
+			}
+		case p.sub == "" && isComplex && multi:
+			if sub, ok := lookup(complexItem, "value"); ok && sub != nil {
+				values = append(values, sub)
+			}
+		case p.sub == "":
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+type compareFilter struct {
+	path  attrPath
+	op    string
+	value any
+}
+
+func (f compareFilter) Matches(resource map[string]any) bool {
+	values := f.path.values(resource)
+	if f.value == nil {
+		// сравнение с null: атрибут отсутствует
+		return (len(values) == 0) == (f.op == "eq")
+	}
+	if f.op == "ne" {
+		for _, v := range values {
+			if compare(v, "eq", f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if compare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare сравнить значение атрибута с литералом фильтра; строки сравниваются без учёта регистра
+func compare(actual any, op string, expected any) bool {
+	switch want := expected.(type) {
+	case string:
+		got, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		got, want = strings.ToLower(got), strings.ToLower(want)
+		switch op {
+		case "eq":
+			return got == want
+		case "co":
+			return strings.Contains(got, want)
+		case "sw":
+			return strings.HasPrefix(got, want)
+		case "ew":
+			return strings.HasSuffix(got, want)
+		case "gt":
+			return got > want
+		case "ge":
+			return got >= want
+		case "lt":
+			return got < want
+		case "le":
+			return got <= want
+		}
+	case float64:
+		got, ok := number(actual)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return got == want
+		case "gt":
+			return got > want
+		case "ge":
+			return got >= want
+		case "lt":
+			return got < want
+		case "le":
+			return got <= want
+		}
+	case bool:
+		got, ok := actual.(bool)
+		return ok && op == "eq" && got == want
+	}
+	return false
+}
+
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+type presentFilter struct {
+	path attrPath
+}
+
+func (f presentFilter) Matches(resource map[string]any) bool {
+	for _, v := range f.path.values(resource) {
+		if s, ok := v.(string); !ok || s != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type andFilter struct {
+	left, right Filter
+}
+
+func (f andFilter) Matches(resource map[string]any) bool {
+	return f.left.Matches(resource) && f.right.Matches(resource)
+}
+
+type orFilter struct {
+	left, right Filter
+}
+
+func (f orFilter) Matches(resource map[string]any) bool {
+	return f.left.Matches(resource) || f.right.Matches(resource)
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+func (f notFilter) Matches(resource map[string]any) bool {
+	return !f.filter.Matches(resource)
+}
+
+// valuePathFilter attr[условие]: хотя бы один элемент многозначного атрибута удовлетворяет условию
+type valuePathFilter struct {
+	attr   string
+	filter Filter
+}
+
+func (f valuePathFilter) Matches(resource map[string]any) bool {
+	return len(f.elements(resource)) > 0
+}
+
+// elements индексы элементов атрибута, удовлетворяющих условию
+func (f valuePathFilter) elements(resource map[string]any) []int {
+	value, _ := lookup(resource, f.attr)
+	items, _ := value.([]any)
+	var matched []int
+	for i, item := range items {
+		if complexItem, ok := item.(map[string]any); ok && f.filter.Matches(complexItem) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+// FilterError фильтр или путь PATCH не соответствуют грамматике SCIM
+type FilterError struct {
+	Message string
+}
+
+func (err FilterError) Error() string {
+	return err.Message
+}
+
+// ParseFilter разобрать фильтр SCIM. Приоритет операций: not, затем and, затем or.
+func ParseFilter(input string) (Filter, error) {
+	p, err := newParser(input)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, FilterError{Message: fmt.Sprintf("unexpected %q in filter", p.peek().text)}
+	}
+	return filter, nil
+}
+
+// patchPath путь операции PATCH: attr, attr.sub, attr[условие] или attr[условие].sub
+type patchPath struct {
+	attr   string
+	filter *valuePathFilter
+	sub    string
+}
+
+// parsePatchPath разобрать путь операции PATCH (RFC 7644, раздел 3.5.2)
+func parsePatchPath(input string) (patchPath, error) {
+	p, err := newParser(input)
+	if err != nil {
+		return patchPath{}, err
+	}
+	if p.done() || p.peek().kind != tokenWord {
+		return patchPath{}, FilterError{Message: fmt.Sprintf("invalid path %q", input)}
+	}
+	path, err := parseAttrPath(p.next().text)
+	if err != nil {
+		return patchPath{}, err
+	}
+	result := patchPath{attr: path.attr, sub: path.sub}
+	if !p.done() && p.peek().kind == tokenOpenBracket {
+		if path.sub != "" {
+			return patchPath{}, FilterError{Message: fmt.Sprintf("invalid path %q", input)}
+		}
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return patchPath{}, err
+		}
+		if p.done() || p.next().kind != tokenCloseBracket {
+			return patchPath{}, FilterError{Message: fmt.Sprintf("missing ] in path %q", input)}
+		}
+		result.filter = &valuePathFilter{attr: path.attr, filter: filter}
+		// после ] может идти .sub
+		if !p.done() {
+			tok := p.next()
+			if tok.kind != tokenWord || !strings.HasPrefix(tok.text, ".") || len(tok.text) < 2 {
+				return patchPath{}, FilterError{Message: fmt.Sprintf("invalid path %q", input)}
+			}
+			result.sub = tok.text[1:]
+		}
+	}
+	if !p.done() {
+		return patchPath{}, FilterError{Message: fmt.Sprintf("invalid path %q", input)}
+	}
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpenParen
+	tokenCloseParen
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(input string) (*parser, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+// tokenize разбить фильтр на слова, строки в кавычках (по правилам JSON) и скобки
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpenParen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenCloseParen, text: ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{kind: tokenOpenBracket, text: "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{kind: tokenCloseBracket, text: "]"})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, FilterError{Message: "unterminated string in filter"}
+			}
+			var value string
+			if err := json.Unmarshal([]byte(input[i:end+1]), &value); err != nil {
+				return nil, FilterError{Message: fmt.Sprintf("invalid string %s in filter", input[i:end+1])}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = end + 1
+		default:
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t()[]\"", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: input[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+// keyword следующий токен - ключевое слово word (без учёта регистра)
+func (p *parser) keyword(word string) bool {
+	return !p.done() && p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, word)
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Filter, error) {
+	if p.done() {
+		return nil, FilterError{Message: "unexpected end of filter"}
+	}
+	if p.keyword("not") {
+		p.next()
+		if p.done() || p.peek().kind != tokenOpenParen {
+			return nil, FilterError{Message: "not must be followed by ("}
+		}
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{filter: filter}, nil
+	}
+	if p.peek().kind == tokenOpenParen {
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseParen {
+			return nil, FilterError{Message: "missing ) in filter"}
+		}
+		return filter, nil
+	}
+	return p.parseAttrExpression()
+}
+
+func (p *parser) parseAttrExpression() (Filter, error) {
+	tok := p.next()
+	if tok.kind != tokenWord {
+		return nil, FilterError{Message: fmt.Sprintf("expected attribute, got %q", tok.text)}
+	}
+	path, err := parseAttrPath(tok.text)
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() && p.peek().kind == tokenOpenBracket {
+		if path.sub != "" {
+			return nil, FilterError{Message: fmt.Sprintf("invalid value path %s", tok.text)}
+		}
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseBracket {
+			return nil, FilterError{Message: "missing ] in filter"}
+		}
+		return valuePathFilter{attr: path.attr, filter: filter}, nil
+	}
+	if p.done() || p.peek().kind != tokenWord {
+		return nil, FilterError{Message: fmt.Sprintf("expected operator after %s", path)}
+	}
+	op := strings.ToLower(p.next().text)
+	if op == "pr" {
+		return presentFilter{path: path}, nil
+	}
+	switch op {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, FilterError{Message: fmt.Sprintf("unknown operator %q", op)}
+	}
+	if p.done() {
+		return nil, FilterError{Message: fmt.Sprintf("expected value after %s %s", path, op)}
+	}
+	value, err := literal(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return compareFilter{path: path, op: op, value: value}, nil
+}
+
+// literal значение сравнения: строка, число, true, false или null
+func literal(tok token) (any, error) {
+	if tok.kind == tokenString {
+		return tok.text, nil
+	}
+	if tok.kind != tokenWord {
+		return nil, FilterError{Message: fmt.Sprintf("expected value, got %q", tok.text)}
+	}
+	switch strings.ToLower(tok.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return nil, FilterError{Message: fmt.Sprintf("invalid value %q", tok.text)}
+	}
+	return value, nil
+}
+
+// parseAttrPath разобрать attr или attr.sub, отбросив необязательный URN схемы
+func parseAttrPath(text string) (attrPath, error) {
+	if strings.HasPrefix(strings.ToLower(text), "urn:") {
+		i := strings.LastIndex(text, ":")
+		text = text[i+1:]
+	}
+	attr, sub, _ := strings.Cut(text, ".")
+	if attr == "" || strings.Contains(sub, ".") {
+		return attrPath{}, FilterError{Message: fmt.Sprintf("invalid attribute path %q", text)}
+	}
+	return attrPath{attr: attr, sub: sub}, nil
+}
+
+// lookup значение атрибута без учёта регистра имени, как требует SCIM
+func lookup(resource map[string]any, name string) (any, bool) {
+	if value, ok := resource[name]; ok {
+		return value, true
+	}
+	for key, value := range resource {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
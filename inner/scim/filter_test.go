@@ -0,0 +1,79 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func resourceOf(t *testing.T, data string) map[string]any {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(data), &resource); err != nil {
+		t.Fatal(err)
+	}
+	return resource
+}
+
+func TestParseFilter(t *testing.T) {
+	a := assert.New(t)
+	user := resourceOf(t, `{
+		"id": "7",
+		"userName": "Alice.Smith",
+		"displayName": "Alice Smith",
+		"active": true,
+		"name": {"formatted": "Alice Smith"},
+		"groups": [{"value": "1", "display": "admins"}, {"value": "2", "display": "devs"}],
+		"meta": {"created": "2025-01-10T10:00:00Z"}
+	}`)
+
+	cases := []struct {
+		filter  string
+		matches bool
+	}{
+		{`userName eq "alice.smith"`, true},
+		{`USERNAME Eq "alice.smith"`, true},
+		{`userName ne "alice.smith"`, false},
+		{`userName co "smith"`, true},
+		{`userName sw "bob"`, false},
+		{`userName ew ".SMITH"`, true},
+		{`name.formatted eq "Alice Smith"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice.smith"`, true},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`nickName pr`, false},
+		{`displayName pr`, true},
+		{`nickName eq null`, true},
+		{`id gt "6"`, true},
+		{`meta.created ge "2025-01-01T00:00:00Z"`, true},
+		{`groups eq "2"`, true},
+		{`groups[display eq "devs"]`, true},
+		{`groups[display eq "ops" or value eq "1"]`, true},
+		{`groups.display eq "ops"`, false},
+		{`userName eq "bob" or active eq true and displayName sw "Alice"`, true},
+		{`(userName eq "bob" or active eq true) and displayName sw "Bob"`, false},
+		{`not (userName eq "bob")`, true},
+	}
+	for _, c := range cases {
+		t.Run("should evaluate "+c.filter, func(t *testing.T) {
+			filter, err := ParseFilter(c.filter)
+			a.Nil(err)
+			a.Equal(c.matches, filter.Matches(user))
+		})
+	}
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		for _, input := range []string{
+			`userName`,
+			`userName eq`,
+			`userName like "a"`,
+			`userName eq "a" and`,
+			`(userName eq "a"`,
+			`userName eq "a")`,
+			`groups[value eq "1"`,
+			`userName eq "unterminated`,
+		} {
+			_, err := ParseFilter(input)
+			a.IsType(FilterError{}, err, input)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchOp тело запроса PATCH (RFC 7644, раздел 3.5.2)
+type PatchOp struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// applyPatch применить операции к ресурсу в JSON-представлении по порядку. Ресурс сохраняется,
+// только если применились все операции.
+func applyPatch(resource map[string]any, operations []PatchOperation) error {
+	if len(operations) == 0 {
+		return Error{Status: 400, ScimType: scimTypeInvalidSyntax, Detail: "no patch operations"}
+	}
+	for _, operation := range operations {
+		var err error
+		switch strings.ToLower(operation.Op) {
+		case "add":
+			err = patchAdd(resource, operation)
+		case "replace":
+			err = patchReplace(resource, operation)
+		case "remove":
+			err = patchRemove(resource, operation)
+		default:
+			err = Error{Status: 400, ScimType: scimTypeInvalidSyntax, Detail: fmt.Sprintf("unknown patch op %q", operation.Op)}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func patchAdd(resource map[string]any, operation PatchOperation) error {
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]any)
+		if !ok {
+			return invalidValue("add without path requires an object value")
+		}
+		for name, value := range values {
+			addValue(resource, name, value)
+		}
+		return nil
+	}
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return invalidPath(err)
+	}
+	if path.filter != nil {
+		return setFiltered(resource, path, operation.Value)
+	}
+	if path.sub != "" {
+		setSub(resource, path.attr, path.sub, operation.Value)
+		return nil
+	}
+	addValue(resource, path.attr, operation.Value)
+	return nil
+}
+
+func patchReplace(resource map[string]any, operation PatchOperation) error {
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]any)
+		if !ok {
+			return invalidValue("replace without path requires an object value")
+		}
+		for name, value := range values {
+			if sub, ok := value.(map[string]any); ok && !isMultiValued(resource, name) {
+				// комплексный атрибут заменяется по податрибутам
+				for subName, subValue := range sub {
+					setSub(resource, name, subName, subValue)
+				}
+				continue
+			}
+			set(resource, name, value)
+		}
+		return nil
+	}
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return invalidPath(err)
+	}
+	if path.filter != nil {
+		return setFiltered(resource, path, operation.Value)
+	}
+	if path.sub != "" {
+		setSub(resource, path.attr, path.sub, operation.Value)
+		return nil
+	}
+	set(resource, path.attr, operation.Value)
+	return nil
+}
+
+func patchRemove(resource map[string]any, operation PatchOperation) error {
+	if operation.Path == "" {
+		return Error{Status: 400, ScimType: scimTypeNoTarget, Detail: "remove requires path"}
+	}
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return invalidPath(err)
+	}
+	if path.filter != nil {
+		return removeFiltered(resource, path)
+	}
+	if path.sub != "" {
+		if sub, ok := get(resource, path.attr).(map[string]any); ok {
+			remove(sub, path.sub)
+		}
+		return nil
+	}
+	// некоторые клиенты удаляют элементы многозначного атрибута, передавая их в value
+	if items, ok := operation.Value.([]any); ok && isMultiValued(resource, path.attr) {
+		removeItems(resource, path.attr, items)
+		return nil
+	}
+	remove(resource, path.attr)
+	return nil
+}
+
+// addValue добавить значение: в многозначный атрибут - новые элементы без повторов, иначе - заменить
+func addValue(resource map[string]any, name string, value any) {
+	added, isList := value.([]any)
+	if !isList && !isMultiValued(resource, name) {
+		set(resource, name, value)
+		return
+	}
+	if !isList {
+		added = []any{value}
+	}
+	current, _ := get(resource, name).([]any)
+	for _, item := range added {
+		if !containsItem(current, item) {
+			current = append(current, item)
+		}
+	}
+	set(resource, name, current)
+}
+
+// setFiltered заменить элементы, подходящие под условие пути, или их податрибут
+func setFiltered(resource map[string]any, path patchPath, value any) error {
+	matched := path.filter.elements(resource)
+	if len(matched) == 0 {
+		return Error{Status: 400, ScimType: scimTypeNoTarget, Detail: fmt.Sprintf("no values match path %q", path.attr)}
+	}
+	items := get(resource, path.attr).([]any)
+	for _, i := range matched {
+		if path.sub == "" {
+			items[i] = value
+			continue
+		}
+		set(items[i].(map[string]any), path.sub, value)
+	}
+	return nil
+}
+
+// removeFiltered удалить элементы, подходящие под условие пути, или их податрибут
+func removeFiltered(resource map[string]any, path patchPath) error {
+	matched := path.filter.elements(resource)
+	if len(matched) == 0 {
+		return Error{Status: 400, ScimType: scimTypeNoTarget, Detail: fmt.Sprintf("no values match path %q", path.attr)}
+	}
+	items := get(resource, path.attr).([]any)
+	if path.sub != "" {
+		for _, i := range matched {
+			remove(items[i].(map[string]any), path.sub)
+		}
+		return nil
+	}
+	kept := make([]any, 0, len(items))
+	for i, item := range items {
+		if !containsIndex(matched, i) {
+			kept = append(kept, item)
+		}
+	}
+	set(resource, path.attr, kept)
+	return nil
+}
+
+func removeItems(resource map[string]any, name string, removed []any) {
+	current, _ := get(resource, name).([]any)
+	kept := make([]any, 0, len(current))
+	for _, item := range current {
+		if !containsItem(removed, item) {
+			kept = append(kept, item)
+		}
+	}
+	set(resource, name, kept)
+}
+
+// containsItem элементы многозначных атрибутов сравниваются по value, если он есть
+func containsItem(items []any, item any) bool {
+	id := itemKey(item)
+	for _, existing := range items {
+		if itemKey(existing) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func itemKey(item any) string {
+	if complexItem, ok := item.(map[string]any); ok {
+		if value, ok := lookup(complexItem, "value"); ok {
+			return fmt.Sprint(value)
+		}
+	}
+	return fmt.Sprint(item)
+}
+
+func containsIndex(indexes []int, i int) bool {
+	for _, index := range indexes {
+		if index == i {
+			return true
+		}
+	}
+	return false
+}
+
+func isMultiValued(resource map[string]any, name string) bool {
+	_, ok := get(resource, name).([]any)
+	return ok || strings.EqualFold(name, attrMembers) || strings.EqualFold(name, attrGroups)
+}
+
+func setSub(resource map[string]any, name, subName string, value any) {
+	sub, ok := get(resource, name).(map[string]any)
+	if !ok {
+		sub = map[string]any{}
+		set(resource, name, sub)
+	}
+	set(sub, subName, value)
+}
+
+// key имя существующего атрибута с тем же именем без учёта регистра, иначе само name
+func key(resource map[string]any, name string) string {
+	for existing := range resource {
+		if strings.EqualFold(existing, name) {
+			return existing
+		}
+	}
+	return name
+}
+
+func get(resource map[string]any, name string) any {
+	value, _ := lookup(resource, name)
+	return value
+}
+
+func set(resource map[string]any, name string, value any) {
+	resource[key(resource, name)] = value
+}
+
+func remove(resource map[string]any, name string) {
+	delete(resource, key(resource, name))
+}
+
+func invalidPath(err error) error {
+	return Error{Status: 400, ScimType: scimTypeInvalidPath, Detail: err.Error()}
+}
+
+func invalidValue(detail string) error {
+	return Error{Status: 400, ScimType: scimTypeInvalidValue, Detail: detail}
+}
@@ -0,0 +1,112 @@
+package scim
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func groupResource(t *testing.T) map[string]any {
+	return resourceOf(t, `{
+		"displayName": "devs",
+		"members": [{"value": "1", "display": "alice"}, {"value": "2", "display": "bob"}]
+	}`)
+}
+
+func memberValues(resource map[string]any) []string {
+	var values []string
+	for _, item := range resource["members"].([]any) {
+		values = append(values, item.(map[string]any)["value"].(string))
+	}
+	return values
+}
+
+func TestApplyPatch(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should add members without duplicates", func(t *testing.T) {
+		resource := groupResource(t)
+		err := applyPatch(resource, []PatchOperation{{
+			Op:    "Add",
+			Path:  "members",
+			Value: []any{map[string]any{"value": "2"}, map[string]any{"value": "3"}},
+		}})
+		a.Nil(err)
+		a.Equal([]string{"1", "2", "3"}, memberValues(resource))
+	})
+
+	t.Run("should add member to group without members", func(t *testing.T) {
+		resource := resourceOf(t, `{"displayName": "devs"}`)
+		err := applyPatch(resource, []PatchOperation{{Op: "add", Path: "members", Value: map[string]any{"value": "5"}}})
+		a.Nil(err)
+		a.Equal([]string{"5"}, memberValues(resource))
+	})
+
+	t.Run("should remove member by filter", func(t *testing.T) {
+		resource := groupResource(t)
+		err := applyPatch(resource, []PatchOperation{{Op: "remove", Path: `members[value eq "1"]`}})
+		a.Nil(err)
+		a.Equal([]string{"2"}, memberValues(resource))
+	})
+
+	t.Run("should remove members listed in value", func(t *testing.T) {
+		resource := groupResource(t)
+		err := applyPatch(resource, []PatchOperation{{
+			Op:    "remove",
+			Path:  "members",
+			Value: []any{map[string]any{"value": "2"}},
+		}})
+		a.Nil(err)
+		a.Equal([]string{"1"}, memberValues(resource))
+	})
+
+	t.Run("should remove all members", func(t *testing.T) {
+		resource := groupResource(t)
+		err := applyPatch(resource, []PatchOperation{{Op: "remove", Path: "members"}})
+		a.Nil(err)
+		_, ok := resource["members"]
+		a.False(ok)
+	})
+
+	t.Run("should replace attributes without path", func(t *testing.T) {
+		resource := resourceOf(t, `{"userName": "alice", "active": true, "name": {"formatted": "Alice", "givenName": "A"}}`)
+		err := applyPatch(resource, []PatchOperation{{
+			Op:    "Replace",
+			Value: map[string]any{"ACTIVE": false, "name": map[string]any{"formatted": "Alice Smith"}},
+		}})
+		a.Nil(err)
+		a.Equal(false, resource["active"])
+		a.Equal(map[string]any{"formatted": "Alice Smith", "givenName": "A"}, resource["name"])
+	})
+
+	t.Run("should replace sub-attribute and filtered value", func(t *testing.T) {
+		resource := groupResource(t)
+		err := applyPatch(resource, []PatchOperation{
+			{Op: "replace", Path: "displayName", Value: "developers"},
+			{Op: "replace", Path: `members[value eq "2"].display`, Value: "robert"},
+		})
+		a.Nil(err)
+		a.Equal("developers", resource["displayName"])
+		a.Equal("robert", resource["members"].([]any)[1].(map[string]any)["display"])
+	})
+
+	t.Run("should return noTarget when filter matches nothing", func(t *testing.T) {
+		err := applyPatch(groupResource(t), []PatchOperation{{Op: "remove", Path: `members[value eq "9"]`}})
+		a.Equal(Error{Status: 400, ScimType: scimTypeNoTarget, Detail: `no values match path "members"`}, err)
+	})
+
+	t.Run("should reject remove without path", func(t *testing.T) {
+		err := applyPatch(groupResource(t), []PatchOperation{{Op: "remove"}})
+		a.Equal(scimTypeNoTarget, err.(Error).ScimType)
+	})
+
+	t.Run("should reject invalid path and unknown op", func(t *testing.T) {
+		err := applyPatch(groupResource(t), []PatchOperation{{Op: "add", Path: "members[value eq"}})
+		a.Equal(scimTypeInvalidPath, err.(Error).ScimType)
+
+		err = applyPatch(groupResource(t), []PatchOperation{{Op: "move", Path: "members"}})
+		a.Equal(scimTypeInvalidSyntax, err.(Error).ScimType)
+
+		err = applyPatch(groupResource(t), nil)
+		a.Equal(scimTypeInvalidSyntax, err.(Error).ScimType)
+	})
+}
@@ -0,0 +1,299 @@
+package scim
+
+import (
+	"fmt"
+	"idm/inner/database"
+	"strings"
+	"time"
+)
+
+// columnKind тип значений колонки: от него зависит, как сравнение фильтра переводится в SQL
+type columnKind int
+
+const (
+	kindText columnKind = iota
+	kindBool
+	kindTime
+)
+
+// column выражение SQL, которым атрибут ресурса представлен в запросе; выражения не бывают null
+type column struct {
+	expr string
+	kind columnKind
+}
+
+// multiValued многозначный атрибут-ссылка: from выбирает его элементы для текущей строки
+// (продолжается условиями через and), elements - колонки податрибутов элемента
+type multiValued struct {
+	from     string
+	elements map[string]column
+}
+
+// resourceColumns атрибуты ресурса, доступные фильтру в запросе; ключи - пути в нижнем регистре
+type resourceColumns struct {
+	single map[string]column
+	multi  map[string]multiValued
+}
+
+// effectiveNow назначение действует сейчас, как в FindEffectiveRoles и FindEffectiveByRoleId
+const effectiveNow = "er.valid_from <= now() and (er.valid_to is null or er.valid_to > now())"
+
+// userColumns атрибуты пользователя как колонки employee; groups - действующие назначения ролей
+var userColumns = resourceColumns{
+	single: map[string]column{
+		"id":                {expr: "id::text"},
+		"username":          {expr: "coalesce(nullif(login, ''), name)"},
+		"displayname":       {expr: "name"},
+		"name.formatted":    {expr: "name"},
+		"active":            {expr: "(status = 'active')", kind: kindBool},
+		"meta.resourcetype": {expr: "'" + resourceUser + "'"},
+		"meta.created":      {expr: "created_at", kind: kindTime},
+		"meta.lastmodified": {expr: "updated_at", kind: kindTime},
+	},
+	multi: map[string]multiValued{
+		attrGroups: {
+			from: `from employee_role er join role r on r.id = er.role_id
+				where er.employee_id = employee.id and r.deleted_at is null and ` + effectiveNow,
+			elements: map[string]column{"value": {expr: "er.role_id::text"}, "display": {expr: "r.name"}},
+		},
+	},
+}
+
+// groupColumns атрибуты группы как колонки role; members - сотрудники с действующим назначением роли
+var groupColumns = resourceColumns{
+	single: map[string]column{
+		"id":                {expr: "id::text"},
+		"displayname":       {expr: "name"},
+		"meta.resourcetype": {expr: "'" + resourceGroup + "'"},
+		"meta.created":      {expr: "created_at", kind: kindTime},
+		"meta.lastmodified": {expr: "updated_at", kind: kindTime},
+	},
+	multi: map[string]multiValued{
+		attrMembers: {
+			from: `from employee_role er join employee e on e.id = er.employee_id
+				where er.role_id = role.id and e.deleted_at is null and ` + effectiveNow,
+			elements: map[string]column{"value": {expr: "er.employee_id::text"}, "display": {expr: "e.name"}},
+		},
+	},
+}
+
+// toQuery перевести фильтр в условие SQL с теми же правилами, что и Filter.Matches: строки
+// сравниваются без учёта регистра, атрибут, которого нет среди колонок, считается отсутствующим
+func toQuery(filter Filter, columns resourceColumns) (*database.Filter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	q := &queryBuilder{}
+	condition, err := q.build(filter, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &database.Filter{Condition: condition, Args: q.args}, nil
+}
+
+type queryBuilder struct {
+	args []any
+}
+
+func (q *queryBuilder) build(filter Filter, columns resourceColumns) (string, error) {
+	switch f := filter.(type) {
+	case andFilter:
+		return q.binary(f.left, "and", f.right, columns)
+	case orFilter:
+		return q.binary(f.left, "or", f.right, columns)
+	case notFilter:
+		condition, err := q.build(f.filter, columns)
+		if err != nil {
+			return "", err
+		}
+		return "not (" + condition + ")", nil
+	case compareFilter:
+		return q.compareAttr(f, columns)
+	case presentFilter:
+		return q.presentAttr(f.path, columns)
+	case valuePathFilter:
+		multi, ok := columns.multi[strings.ToLower(f.attr)]
+		if !ok {
+			return "false", nil
+		}
+		condition, err := q.build(f.filter, resourceColumns{single: multi.elements})
+		if err != nil {
+			return "", err
+		}
+		return "exists(select 1 " + multi.from + " and (" + condition + "))", nil
+	}
+	return "", FilterError{Message: fmt.Sprintf("unsupported filter %T", filter)}
+}
+
+func (q *queryBuilder) binary(left Filter, op string, right Filter, columns resourceColumns) (string, error) {
+	l, err := q.build(left, columns)
+	if err != nil {
+		return "", err
+	}
+	r, err := q.build(right, columns)
+	if err != nil {
+		return "", err
+	}
+	return "(" + l + " " + op + " " + r + ")", nil
+}
+
+// compareAttr сравнение атрибута; у многозначного атрибута достаточно одного подходящего элемента,
+// а ne означает, что равных элементов нет
+func (q *queryBuilder) compareAttr(f compareFilter, columns resourceColumns) (string, error) {
+	multi, ok := columns.multi[strings.ToLower(f.path.attr)]
+	if !ok {
+		col, ok := columns.single[strings.ToLower(f.path.String())]
+		if !ok {
+			return absent(f), nil
+		}
+		return q.compare(col, f)
+	}
+	sub := f.path.sub
+	if sub == "" {
+		sub = "value"
+	}
+	col, ok := multi.elements[strings.ToLower(sub)]
+	switch {
+	case !ok:
+		return absent(f), nil
+	case f.value == nil:
+		// сравнение с null: элементов нет
+		return negate(f.op == "eq", "exists(select 1 "+multi.from+")"), nil
+	}
+	eq := f
+	if f.op == "ne" {
+		eq.op = "eq"
+	}
+	condition, err := q.compare(col, eq)
+	if err != nil {
+		return "", err
+	}
+	return negate(f.op == "ne", "exists(select 1 "+multi.from+" and "+condition+")"), nil
+}
+
+func negate(not bool, condition string) string {
+	if not {
+		return "not " + condition
+	}
+	return condition
+}
+
+// absent сравнение с отсутствующим атрибутом: совпадают только ne со значением и eq null
+func absent(f compareFilter) string {
+	if (f.value == nil) == (f.op == "eq") {
+		return "true"
+	}
+	return "false"
+}
+
+func (q *queryBuilder) compare(col column, f compareFilter) (string, error) {
+	if f.value == nil {
+		// колонки не бывают null: атрибут всегда присутствует
+		if f.op == "ne" {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	switch col.kind {
+	case kindBool:
+		want, ok := f.value.(bool)
+		switch {
+		case !ok:
+			return absentMismatch(f.op), nil
+		case f.op == "eq":
+			return col.expr + " = " + q.arg(want), nil
+		case f.op == "ne":
+			return col.expr + " <> " + q.arg(want), nil
+		}
+		return "false", nil
+	case kindTime:
+		return q.compareTime(col, f)
+	}
+	want, ok := f.value.(string)
+	if !ok {
+		return absentMismatch(f.op), nil
+	}
+	want = strings.ToLower(want)
+	expr := "lower(" + col.expr + ")"
+	switch f.op {
+	case "eq":
+		return expr + " = " + q.arg(want), nil
+	case "ne":
+		return expr + " <> " + q.arg(want), nil
+	case "co":
+		return expr + " like " + q.arg("%"+database.LikeEscape(want)+"%"), nil
+	case "sw":
+		return expr + " like " + q.arg(database.LikePrefix(want)), nil
+	case "ew":
+		return expr + " like " + q.arg("%"+database.LikeEscape(want)), nil
+	}
+	return expr + " " + sqlOperator(f.op) + " " + q.arg(want), nil
+}
+
+// compareTime даты сравниваются как моменты времени; значение фильтра - строка в формате RFC 3339
+func (q *queryBuilder) compareTime(col column, f compareFilter) (string, error) {
+	raw, ok := f.value.(string)
+	if !ok {
+		return absentMismatch(f.op), nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return "", FilterError{Message: fmt.Sprintf("invalid date %q in filter", raw)}
+	}
+	switch f.op {
+	case "co", "sw", "ew":
+		return "", FilterError{Message: fmt.Sprintf("operator %s is not supported for dates", f.op)}
+	}
+	return col.expr + " " + sqlOperator(f.op) + " " + q.arg(at), nil
+}
+
+// absentMismatch значение другого типа ни с чем не совпадает, поэтому подходит только ne
+func absentMismatch(op string) string {
+	if op == "ne" {
+		return "true"
+	}
+	return "false"
+}
+
+func sqlOperator(op string) string {
+	switch op {
+	case "ne":
+		return "<>"
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	}
+	return "="
+}
+
+// presentAttr атрибут присутствует: непустая строка или хотя бы один элемент многозначного атрибута
+func (q *queryBuilder) presentAttr(path attrPath, columns resourceColumns) (string, error) {
+	if multi, ok := columns.multi[strings.ToLower(path.attr)]; ok {
+		sub := path.sub
+		if sub == "" {
+			sub = "value"
+		}
+		if _, ok = multi.elements[strings.ToLower(sub)]; !ok {
+			return "false", nil
+		}
+		return "exists(select 1 " + multi.from + ")", nil
+	}
+	col, ok := columns.single[strings.ToLower(path.String())]
+	if !ok {
+		return "false", nil
+	}
+	if col.kind == kindText {
+		return col.expr + " <> ''", nil
+	}
+	return "true", nil
+}
+
+func (q *queryBuilder) arg(value any) string {
+	q.args = append(q.args, value)
+	return "?"
+}
@@ -0,0 +1,74 @@
+package scim
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestToQuery(t *testing.T) {
+	a := assert.New(t)
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	groups := userColumns.multi[attrGroups].from
+
+	cases := []struct {
+		filter    string
+		condition string
+		args      []any
+	}{
+		{`userName eq "Alice.Smith"`, "lower(coalesce(nullif(login, ''), name)) = ?", []any{"alice.smith"}},
+		{`displayName co "50%"`, "lower(name) like ?", []any{`%50\%%`}},
+		{`displayName sw "Al"`, "lower(name) like ?", []any{"al%"}},
+		{`displayName ew "th"`, "lower(name) like ?", []any{"%th"}},
+		{`id gt "6"`, "lower(id::text) > ?", []any{"6"}},
+		{`active eq true`, "(status = 'active') = ?", []any{true}},
+		{`active eq "true"`, "false", nil},
+		{`meta.created ge "2025-01-01T00:00:00Z"`, "created_at >= ?", []any{created}},
+		{`displayName pr`, "name <> ''", nil},
+		{`nickName eq "al"`, "false", nil},
+		{`nickName ne "al"`, "true", nil},
+		{`nickName eq null`, "true", nil},
+		{`groups eq "2"`, "exists(select 1 " + groups + " and lower(er.role_id::text) = ?)", []any{"2"}},
+		{`groups.display ne "devs"`, "not exists(select 1 " + groups + " and lower(r.name) = ?)", []any{"devs"}},
+		{`groups pr`, "exists(select 1 " + groups + ")", nil},
+		{`groups eq null`, "not exists(select 1 " + groups + ")", nil},
+		{
+			`groups[display eq "devs" or value eq "1"]`,
+			"exists(select 1 " + groups + " and ((lower(r.name) = ? or lower(er.role_id::text) = ?)))",
+			[]any{"devs", "1"},
+		},
+		{
+			`userName eq "bob" or not (displayName sw "A")`,
+			"(lower(coalesce(nullif(login, ''), name)) = ? or not (lower(name) like ?))",
+			[]any{"bob", "a%"},
+		},
+	}
+	for _, c := range cases {
+		t.Run("should translate "+c.filter, func(t *testing.T) {
+			filter, err := ParseFilter(c.filter)
+			a.Nil(err)
+			got, err := toQuery(filter, userColumns)
+			a.Nil(err)
+			a.Equal(c.condition, got.Condition)
+			a.Equal(c.args, got.Args)
+		})
+	}
+
+	t.Run("should reject invalid dates and substring operators on dates", func(t *testing.T) {
+		for _, input := range []string{`meta.created gt "yesterday"`, `meta.lastModified sw "2025"`} {
+			filter, err := ParseFilter(input)
+			a.Nil(err)
+			_, err = toQuery(filter, userColumns)
+			a.IsType(FilterError{}, err, input)
+		}
+	})
+
+	t.Run("should translate members of groups", func(t *testing.T) {
+		filter, err := ParseFilter(`members[value eq "1"]`)
+		a.Nil(err)
+		got, err := toQuery(filter, groupColumns)
+		a.Nil(err)
+		a.Contains(got.Condition, "er.role_id = role.id")
+		a.Equal([]any{"1"}, got.Args)
+	})
+}
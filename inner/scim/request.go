@@ -0,0 +1,35 @@
+package scim
+
+import "time"
+
+// ListRequest параметры запроса списка: фильтр SCIM, страница с 1-based startIndex и исключаемые атрибуты
+type ListRequest struct {
+	Filter     string
+	StartIndex int
+	// Count размер страницы; nil - defaultCount
+	Count              *int
+	ExcludedAttributes []string
+}
+
+// IdRequest id ресурса SCIM - строковый идентификатор сотрудника или роли IDM
+type IdRequest struct {
+	Id string
+}
+
+type CreateRequest struct {
+	Resource map[string]any
+}
+
+// ReplaceRequest полная замена ресурса (PUT)
+type ReplaceRequest struct {
+	Id       string
+	Resource map[string]any
+	// Version ожидаемая версия из заголовка If-Match; nil - без проверки
+	Version *time.Time
+}
+
+type PatchRequest struct {
+	Id string
+	PatchOp
+	Version *time.Time
+}
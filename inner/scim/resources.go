@@ -0,0 +1,222 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"idm/inner/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Идентификаторы схем SCIM 2.0 (RFC 7643, RFC 7644)
+const (
+	schemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	schemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	schemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+const (
+	resourceUser  = "User"
+	resourceGroup = "Group"
+	// attrMembers и attrGroups многозначные атрибуты со ссылками на другие ресурсы
+	attrMembers = "members"
+	attrGroups  = "groups"
+)
+
+// User сотрудник IDM в виде ресурса SCIM. userName - логин сотрудника (без логина - имя),
+// displayName - имя; группы - действующие назначения ролей, только для чтения
+type User struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id"`
+	UserName    string      `json:"userName"`
+	Name        *Name       `json:"name,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Active      bool        `json:"active"`
+	Groups      []Reference `json:"groups,omitempty"`
+	Meta        Meta        `json:"meta"`
+}
+
+type Name struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// Group роль IDM в виде ресурса SCIM; участники - сотрудники с действующим назначением роли
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        Meta        `json:"meta"`
+}
+
+// Reference элемент members или groups
+type Reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+	Version      string    `json:"version"`
+}
+
+// ListResponse страница результатов запроса списка; startIndex нумеруется с 1
+type ListResponse[T any] struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []T      `json:"Resources"`
+}
+
+// locate заполнить meta.location и $ref ссылок; base - адрес /scim/v2 этого сервера
+func (u *User) locate(base string) {
+	u.Meta.Location = base + "/Users/" + u.Id
+	for i := range u.Groups {
+		u.Groups[i].Ref = base + "/Groups/" + u.Groups[i].Value
+	}
+}
+
+func (g *Group) locate(base string) {
+	g.Meta.Location = base + "/Groups/" + g.Id
+	for i := range g.Members {
+		g.Members[i].Ref = base + "/Users/" + g.Members[i].Value
+	}
+}
+
+func newMeta(resourceType string, createdAt, updatedAt time.Time) Meta {
+	return Meta{
+		ResourceType: resourceType,
+		Created:      createdAt,
+		LastModified: updatedAt,
+		Version:      common.ETag(updatedAt),
+	}
+}
+
+// toMap JSON-представление ресурса, к которому применяются фильтры и операции PATCH
+func toMap(resource any) (map[string]any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// userInput изменяемые атрибуты пользователя из тела запроса; прочие атрибуты игнорируются
+type userInput struct {
+	UserName    string
+	DisplayName string
+	Active      bool
+}
+
+// userFromResource прочитать пользователя из JSON-представления. Без displayName имя берётся
+// из name.formatted, затем из userName; active по умолчанию true
+func userFromResource(resource map[string]any) (userInput, error) {
+	input := userInput{Active: true}
+	var err error
+	if input.UserName, err = stringAttr(resource, "userName"); err != nil {
+		return userInput{}, err
+	}
+	if input.UserName == "" {
+		return userInput{}, invalidValue("userName is required")
+	}
+	if input.DisplayName, err = stringAttr(resource, "displayName"); err != nil {
+		return userInput{}, err
+	}
+	if input.DisplayName == "" {
+		if name, ok := get(resource, "name").(map[string]any); ok {
+			if input.DisplayName, err = stringAttr(name, "formatted"); err != nil {
+				return userInput{}, err
+			}
+		}
+	}
+	if input.DisplayName == "" {
+		input.DisplayName = input.UserName
+	}
+	switch active := get(resource, "active").(type) {
+	case nil:
+	case bool:
+		input.Active = active
+	case string:
+		// некоторые клиенты, например Entra ID, передают active строкой
+		if input.Active, err = strconv.ParseBool(strings.ToLower(active)); err != nil {
+			return userInput{}, invalidValue(fmt.Sprintf("invalid active value %q", active))
+		}
+	default:
+		return userInput{}, invalidValue("active must be boolean")
+	}
+	return input, nil
+}
+
+// groupInput изменяемые атрибуты группы: имя роли и идентификаторы сотрудников-участников
+type groupInput struct {
+	DisplayName string
+	Members     []int64
+}
+
+func groupFromResource(resource map[string]any) (groupInput, error) {
+	var input groupInput
+	var err error
+	if input.DisplayName, err = stringAttr(resource, "displayName"); err != nil {
+		return groupInput{}, err
+	}
+	if input.DisplayName == "" {
+		return groupInput{}, invalidValue("displayName is required")
+	}
+	members, ok := get(resource, attrMembers).([]any)
+	if !ok && get(resource, attrMembers) != nil {
+		return groupInput{}, invalidValue("members must be an array")
+	}
+	for _, member := range members {
+		item, ok := member.(map[string]any)
+		if !ok {
+			return groupInput{}, invalidValue("member must be an object")
+		}
+		value, err := stringAttr(item, "value")
+		if err != nil {
+			return groupInput{}, err
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return groupInput{}, invalidValue(fmt.Sprintf("invalid member value %q", value))
+		}
+		if !containsId(input.Members, id) {
+			input.Members = append(input.Members, id)
+		}
+	}
+	return input, nil
+}
+
+func stringAttr(resource map[string]any, name string) (string, error) {
+	switch value := get(resource, name).(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	default:
+		return "", invalidValue(fmt.Sprintf("%s must be a string", name))
+	}
+}
+
+func containsId(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,446 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultCount размер страницы, если клиент не передал count
+	defaultCount = 100
+	// maxCount наибольший размер страницы; публикуется в ServiceProviderConfig как filter.maxResults
+	maxCount = 500
+)
+
+// Service отображает ресурсы SCIM на сотрудников, роли и назначения через их сервисы, поэтому
+// валидация, проверка версии и журнал аудита работают так же, как в REST API. Операции,
+// затрагивающие несколько сущностей (участники группы, деактивация), выполняются
+// последовательными вызовами и не атомарны: при ошибке уже сделанные изменения остаются.
+type Service struct {
+	employees   EmployeeSvc
+	roles       RoleSvc
+	assignments AssignmentSvc
+}
+
+type EmployeeSvc interface {
+	Create(ctx context.Context, request employee.CreateRequest) (int64, error)
+	FindById(request employee.IdRequest) (employee.Response, error)
+	FindAll(request employee.ListRequest) (common.Page[employee.Response], error)
+	Update(ctx context.Context, request employee.UpdateRequest) (employee.Response, error)
+	DeleteById(ctx context.Context, request employee.IdRequest) error
+	Transition(ctx context.Context, request employee.TransitionRequest) (employee.TransitionResponse, error)
+}
+
+type RoleSvc interface {
+	Create(ctx context.Context, request role.CreateRequest) (int64, error)
+	FindById(request role.IdRequest) (role.Response, error)
+	FindAll(request role.ListRequest) (common.Page[role.Response], error)
+	Update(ctx context.Context, request role.UpdateRequest) (role.Response, error)
+	DeleteById(ctx context.Context, request role.IdRequest) error
+}
+
+type AssignmentSvc interface {
+	Grant(ctx context.Context, request assignment.GrantRequest) (int64, error)
+	Revoke(ctx context.Context, request assignment.RevokeRequest) error
+	FindByRole(request assignment.RoleRequest) ([]assignment.Response, error)
+}
+
+func NewService(employees EmployeeSvc, roles RoleSvc, assignments AssignmentSvc) *Service {
+	return &Service{
+		employees:   employees,
+		roles:       roles,
+		assignments: assignments,
+	}
+}
+
+// FindUsers список пользователей. Фильтр и страница переводятся в запрос к сотрудникам;
+// groups в списке не заполняются, они есть только у пользователя, запрошенного по id
+func (svc *Service) FindUsers(request ListRequest) (ListResponse[User], error) {
+	filter, err := parseListFilter(request.Filter, userColumns)
+	if err != nil {
+		return ListResponse[User]{}, err
+	}
+	startIndex, count := pageOf(request)
+	page, err := svc.employees.FindAll(employee.ListRequest{
+		PageRequest: common.PageRequest{Limit: max(count, 1)}, Filter: filter, Offset: startIndex - 1,
+	})
+	if err != nil {
+		return ListResponse[User]{}, err
+	}
+	users := make([]User, 0, len(page.Items))
+	for _, e := range page.Items[:min(count, len(page.Items))] {
+		users = append(users, toUser(e))
+	}
+	return listResponse(users, startIndex, page.Total), nil
+}
+
+func (svc *Service) FindUser(request IdRequest) (User, error) {
+	found, err := svc.findEmployee(request.Id)
+	if err != nil {
+		return User{}, err
+	}
+	return toUser(found), nil
+}
+
+// CreateUser создать сотрудника; userName становится логином, displayName - именем
+func (svc *Service) CreateUser(ctx context.Context, request CreateRequest) (User, error) {
+	input, err := userFromResource(request.Resource)
+	if err != nil {
+		return User{}, err
+	}
+	if !input.Active {
+		return User{}, invalidValue("inactive users cannot be created")
+	}
+	id, err := svc.employees.Create(ctx, employee.CreateRequest{Name: input.DisplayName, Login: &input.UserName})
+	if err != nil {
+		return User{}, err
+	}
+	return svc.FindUser(IdRequest{Id: strconv.FormatInt(id, 10)})
+}
+
+func (svc *Service) ReplaceUser(ctx context.Context, request ReplaceRequest) (User, error) {
+	current, err := svc.findEmployee(request.Id)
+	if err != nil {
+		return User{}, err
+	}
+	input, err := userFromResource(request.Resource)
+	if err != nil {
+		return User{}, err
+	}
+	return svc.saveUser(ctx, current, input, request.Version)
+}
+
+// PatchUser применить операции PATCH к текущему представлению пользователя и сохранить результат как при PUT
+func (svc *Service) PatchUser(ctx context.Context, request PatchRequest) (User, error) {
+	current, err := svc.findEmployee(request.Id)
+	if err != nil {
+		return User{}, err
+	}
+	resource, err := toMap(toUser(current))
+	if err != nil {
+		return User{}, fmt.Errorf("error converting user %d: %w", current.Id, err)
+	}
+	if err = applyPatch(resource, request.Operations); err != nil {
+		return User{}, err
+	}
+	input, err := userFromResource(resource)
+	if err != nil {
+		return User{}, err
+	}
+	return svc.saveUser(ctx, current, input, request.Version)
+}
+
+func (svc *Service) DeleteUser(ctx context.Context, request IdRequest) error {
+	id, err := parseId(resourceUser, request.Id)
+	if err != nil {
+		return err
+	}
+	return svc.employees.DeleteById(ctx, employee.IdRequest{Id: id})
+}
+
+// saveUser сохранить имя и логин, оставив основную роль и анкету сотрудника. active переводит сотрудника
+// по жизненному циклу: false приостанавливает работающего, true принимает на работу или возобновляет;
+// уволенного сотрудника можно только принять заново через REST API
+func (svc *Service) saveUser(ctx context.Context, current employee.Response, input userInput, version *time.Time) (User, error) {
+	saved := current
+	if version != nil || input.DisplayName != current.Name || current.Login == nil || input.UserName != *current.Login {
+		request := current.UpdateRequest()
+		request.Name = input.DisplayName
+		request.Login = &input.UserName
+		request.Version = version
+		updated, err := svc.employees.Update(ctx, request)
+		if err != nil {
+			return User{}, err
+		}
+		updated.Roles = current.Roles
+		saved = updated
+	}
+	if transition := activeTransition(saved.Status, input.Active); transition != "" {
+		_, err := svc.employees.Transition(ctx, employee.TransitionRequest{
+			Id: current.Id, Transition: transition, Reason: "SCIM active set to " + strconv.FormatBool(input.Active),
+		})
+		if err != nil {
+			return User{}, err
+		}
+		if saved, err = svc.employees.FindById(employee.IdRequest{Id: current.Id}); err != nil {
+			return User{}, err
+		}
+	}
+	return toUser(saved), nil
+}
+
+// activeTransition переход, который приводит сотрудника в статусе status к значению active;
+// пустая строка, если переход не нужен или недоступен
+func activeTransition(status string, active bool) string {
+	switch {
+	case active && status == employee.StatusPreHire:
+		return employee.TransitionHire
+	case active && status == employee.StatusSuspended:
+		return employee.TransitionResume
+	case !active && status == employee.StatusActive:
+		return employee.TransitionSuspend
+	default:
+		return ""
+	}
+}
+
+// FindGroups список групп. Фильтр и страница переводятся в запрос к ролям, участники
+// загружаются только для групп страницы
+func (svc *Service) FindGroups(request ListRequest) (ListResponse[Group], error) {
+	filter, err := parseListFilter(request.Filter, groupColumns)
+	if err != nil {
+		return ListResponse[Group]{}, err
+	}
+	startIndex, count := pageOf(request)
+	page, err := svc.roles.FindAll(role.ListRequest{
+		PageRequest: common.PageRequest{Limit: max(count, 1)}, Filter: filter, Offset: startIndex - 1,
+	})
+	if err != nil {
+		return ListResponse[Group]{}, err
+	}
+	withMembers := !excluded(request, attrMembers)
+	groups := make([]Group, 0, len(page.Items))
+	for _, r := range page.Items[:min(count, len(page.Items))] {
+		group := toGroup(r)
+		if withMembers {
+			if group.Members, err = svc.members(r.Id); err != nil {
+				return ListResponse[Group]{}, err
+			}
+		}
+		groups = append(groups, group)
+	}
+	return listResponse(groups, startIndex, page.Total), nil
+}
+
+func (svc *Service) FindGroup(request IdRequest) (Group, error) {
+	found, err := svc.findRole(request.Id)
+	if err != nil {
+		return Group{}, err
+	}
+	group := toGroup(found)
+	if group.Members, err = svc.members(found.Id); err != nil {
+		return Group{}, err
+	}
+	return group, nil
+}
+
+// CreateGroup создать роль и назначить её участникам группы с текущего момента
+func (svc *Service) CreateGroup(ctx context.Context, request CreateRequest) (Group, error) {
+	input, err := groupFromResource(request.Resource)
+	if err != nil {
+		return Group{}, err
+	}
+	id, err := svc.roles.Create(ctx, role.CreateRequest{Name: input.DisplayName})
+	if err != nil {
+		return Group{}, err
+	}
+	if err = svc.syncMembers(ctx, id, nil, input.Members); err != nil {
+		return Group{}, err
+	}
+	return svc.FindGroup(IdRequest{Id: strconv.FormatInt(id, 10)})
+}
+
+func (svc *Service) ReplaceGroup(ctx context.Context, request ReplaceRequest) (Group, error) {
+	current, err := svc.FindGroup(IdRequest{Id: request.Id})
+	if err != nil {
+		return Group{}, err
+	}
+	input, err := groupFromResource(request.Resource)
+	if err != nil {
+		return Group{}, err
+	}
+	return svc.saveGroup(ctx, current, input, request.Version)
+}
+
+// PatchGroup применить операции PATCH; так клиенты обычно добавляют и удаляют участников
+func (svc *Service) PatchGroup(ctx context.Context, request PatchRequest) (Group, error) {
+	current, err := svc.FindGroup(IdRequest{Id: request.Id})
+	if err != nil {
+		return Group{}, err
+	}
+	resource, err := toMap(current)
+	if err != nil {
+		return Group{}, fmt.Errorf("error converting group %s: %w", current.Id, err)
+	}
+	if err = applyPatch(resource, request.Operations); err != nil {
+		return Group{}, err
+	}
+	input, err := groupFromResource(resource)
+	if err != nil {
+		return Group{}, err
+	}
+	return svc.saveGroup(ctx, current, input, request.Version)
+}
+
+func (svc *Service) DeleteGroup(ctx context.Context, request IdRequest) error {
+	id, err := parseId(resourceGroup, request.Id)
+	if err != nil {
+		return err
+	}
+	return svc.roles.DeleteById(ctx, role.IdRequest{Id: id})
+}
+
+// saveGroup переименовать роль, если изменилось имя, и привести назначения к списку участников
+func (svc *Service) saveGroup(ctx context.Context, current Group, input groupInput, version *time.Time) (Group, error) {
+	id, _ := strconv.ParseInt(current.Id, 10, 64)
+	if version != nil || input.DisplayName != current.DisplayName {
+		_, err := svc.roles.Update(ctx, role.UpdateRequest{Id: id, Name: input.DisplayName, Version: version})
+		if err != nil {
+			return Group{}, err
+		}
+	}
+	members := make([]int64, 0, len(current.Members))
+	for _, member := range current.Members {
+		memberId, _ := strconv.ParseInt(member.Value, 10, 64)
+		members = append(members, memberId)
+	}
+	if err := svc.syncMembers(ctx, id, members, input.Members); err != nil {
+		return Group{}, err
+	}
+	return svc.FindGroup(IdRequest{Id: current.Id})
+}
+
+// syncMembers назначить роль новым участникам и отозвать у тех, кого нет в списке
+func (svc *Service) syncMembers(ctx context.Context, roleId int64, current, desired []int64) error {
+	for _, employeeId := range desired {
+		if containsId(current, employeeId) {
+			continue
+		}
+		_, err := svc.assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: employeeId, RoleId: roleId})
+		if err != nil {
+			return err
+		}
+	}
+	for _, employeeId := range current {
+		if containsId(desired, employeeId) {
+			continue
+		}
+		err := svc.assignments.Revoke(ctx, assignment.RevokeRequest{EmployeeId: employeeId, RoleId: roleId})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// members сотрудники с действующим назначением роли, каждый один раз
+func (svc *Service) members(roleId int64) ([]Reference, error) {
+	assignments, err := svc.assignments.FindByRole(assignment.RoleRequest{RoleId: roleId})
+	if err != nil {
+		return nil, err
+	}
+	members := make([]Reference, 0, len(assignments))
+	var seen []int64
+	for _, a := range assignments {
+		if containsId(seen, a.EmployeeId) {
+			continue
+		}
+		seen = append(seen, a.EmployeeId)
+		members = append(members, Reference{Value: strconv.FormatInt(a.EmployeeId, 10), Display: a.EmployeeName})
+	}
+	return members, nil
+}
+
+func (svc *Service) findEmployee(id string) (employee.Response, error) {
+	employeeId, err := parseId(resourceUser, id)
+	if err != nil {
+		return employee.Response{}, err
+	}
+	return svc.employees.FindById(employee.IdRequest{Id: employeeId})
+}
+
+func (svc *Service) findRole(id string) (role.Response, error) {
+	roleId, err := parseId(resourceGroup, id)
+	if err != nil {
+		return role.Response{}, err
+	}
+	return svc.roles.FindById(role.IdRequest{Id: roleId})
+}
+
+// toUser активен только работающий сотрудник: приостановленный, ещё не принятый и уволенный - нет
+func toUser(e employee.Response) User {
+	userName := e.Name
+	if e.Login != nil && *e.Login != "" {
+		userName = *e.Login
+	}
+	user := User{
+		Schemas:     []string{schemaUser},
+		Id:          strconv.FormatInt(e.Id, 10),
+		UserName:    userName,
+		Name:        &Name{Formatted: e.Name},
+		DisplayName: e.Name,
+		Active:      e.DeletedAt == nil && e.Status == employee.StatusActive,
+		Meta:        newMeta(resourceUser, e.CreatedAt, e.UpdatedAt),
+	}
+	for _, r := range e.Roles {
+		user.Groups = append(user.Groups, Reference{Value: strconv.FormatInt(r.Id, 10), Display: r.Name})
+	}
+	return user
+}
+
+func toGroup(r role.Response) Group {
+	return Group{
+		Schemas:     []string{schemaGroup},
+		Id:          strconv.FormatInt(r.Id, 10),
+		DisplayName: r.Name,
+		Meta:        newMeta(resourceGroup, r.CreatedAt, r.UpdatedAt),
+	}
+}
+
+// parseId идентификатор, который не является id IDM, означает несуществующий ресурс
+func parseId(resourceType, id string) (int64, error) {
+	parsed, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || parsed <= 0 {
+		return 0, notFound(resourceType, id)
+	}
+	return parsed, nil
+}
+
+// parseListFilter разобрать фильтр списка и перевести его в условие запроса по колонкам ресурса
+func parseListFilter(input string, columns resourceColumns) (*database.Filter, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	filter, err := ParseFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	return toQuery(filter, columns)
+}
+
+func excluded(request ListRequest, attr string) bool {
+	for _, name := range request.ExcludedAttributes {
+		if strings.EqualFold(strings.TrimSpace(name), attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageOf startIndex и размер страницы: startIndex меньше 1 считается 1, count ограничен maxCount;
+// count=0 возвращает только totalResults
+func pageOf(request ListRequest) (startIndex, count int) {
+	count = defaultCount
+	if request.Count != nil {
+		count = min(max(*request.Count, 0), maxCount)
+	}
+	return max(request.StartIndex, 1), count
+}
+
+func listResponse[T any](items []T, startIndex int, total int64) ListResponse[T] {
+	return ListResponse[T]{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: int(total),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(items),
+		Resources:    items,
+	}
+}
@@ -0,0 +1,478 @@
+package scim
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/assignment"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockEmployeeSvc struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeSvc) Create(ctx context.Context, request employee.CreateRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) FindById(request employee.IdRequest) (employee.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).(employee.Response), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) FindAll(request employee.ListRequest) (common.Page[employee.Response], error) {
+	args := m.Called(request)
+	return args.Get(0).(common.Page[employee.Response]), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) Update(ctx context.Context, request employee.UpdateRequest) (employee.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).(employee.Response), args.Error(1)
+}
+
+func (m *MockEmployeeSvc) DeleteById(ctx context.Context, request employee.IdRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+func (m *MockEmployeeSvc) Transition(ctx context.Context, request employee.TransitionRequest) (employee.TransitionResponse, error) {
+	args := m.Called(request)
+	return args.Get(0).(employee.TransitionResponse), args.Error(1)
+}
+
+type MockRoleSvc struct {
+	mock.Mock
+}
+
+func (m *MockRoleSvc) Create(ctx context.Context, request role.CreateRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoleSvc) FindById(request role.IdRequest) (role.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).(role.Response), args.Error(1)
+}
+
+func (m *MockRoleSvc) FindAll(request role.ListRequest) (common.Page[role.Response], error) {
+	args := m.Called(request)
+	return args.Get(0).(common.Page[role.Response]), args.Error(1)
+}
+
+func (m *MockRoleSvc) Update(ctx context.Context, request role.UpdateRequest) (role.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).(role.Response), args.Error(1)
+}
+
+func (m *MockRoleSvc) DeleteById(ctx context.Context, request role.IdRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+type MockAssignmentSvc struct {
+	mock.Mock
+}
+
+func (m *MockAssignmentSvc) Grant(ctx context.Context, request assignment.GrantRequest) (int64, error) {
+	args := m.Called(request)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAssignmentSvc) Revoke(ctx context.Context, request assignment.RevokeRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+func (m *MockAssignmentSvc) FindByRole(request assignment.RoleRequest) ([]assignment.Response, error) {
+	args := m.Called(request)
+	return args.Get(0).([]assignment.Response), args.Error(1)
+}
+
+var updatedAt = time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC)
+
+func alice() employee.Response {
+	login := "alice"
+	return employee.Response{
+		Id:        1,
+		Name:      "Alice Smith",
+		Login:     &login,
+		Status:    employee.StatusActive,
+		CreatedAt: updatedAt,
+		UpdatedAt: updatedAt,
+		Role:      &employee.RoleResponse{Id: 3, Name: "staff"},
+		Roles:     []employee.RoleResponse{{Id: 4, Name: "devs"}},
+	}
+}
+
+func devs() role.Response {
+	return role.Response{Id: 4, Name: "devs", CreatedAt: updatedAt, UpdatedAt: updatedAt}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestServiceFindUsers(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should query page by filter and startIndex", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		carol := employee.Response{Id: 3, Name: "Carol", CreatedAt: updatedAt, UpdatedAt: updatedAt}
+		employees.On("FindAll", employee.ListRequest{
+			PageRequest: common.PageRequest{Limit: 10},
+			Filter:      &database.Filter{Condition: "lower(name) <> ?", Args: []any{"alice smith"}},
+			Offset:      1,
+		}).Return(common.Page[employee.Response]{
+			Items: []employee.Response{carol}, PageInfo: common.PageInfo{Total: 2},
+		}, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.FindUsers(ListRequest{Filter: `displayName ne "Alice Smith"`, StartIndex: 2, Count: intPtr(10)})
+		a.Nil(err)
+		a.Equal(2, got.TotalResults)
+		a.Equal(2, got.StartIndex)
+		a.Equal(1, got.ItemsPerPage)
+		a.Equal("3", got.Resources[0].Id)
+		// без логина userName - имя сотрудника
+		a.Equal("Carol", got.Resources[0].UserName)
+	})
+
+	t.Run("should return only total with zero count", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindAll", employee.ListRequest{PageRequest: common.PageRequest{Limit: 1}}).
+			Return(common.Page[employee.Response]{
+				Items: []employee.Response{alice()}, PageInfo: common.PageInfo{Total: 3},
+			}, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.FindUsers(ListRequest{Count: intPtr(0)})
+		a.Nil(err)
+		a.Equal(3, got.TotalResults)
+		a.Empty(got.Resources)
+	})
+
+	t.Run("should return filter error without loading employees", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		_, err := svc.FindUsers(ListRequest{Filter: `userName eq`})
+		a.IsType(FilterError{}, err)
+		a.True(employees.AssertNotCalled(t, "FindAll", mock.Anything))
+	})
+}
+
+func TestServiceFindUser(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should map employee to user", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.FindUser(IdRequest{Id: "1"})
+		a.Nil(err)
+		a.Equal(User{
+			Schemas:     []string{schemaUser},
+			Id:          "1",
+			UserName:    "alice",
+			Name:        &Name{Formatted: "Alice Smith"},
+			DisplayName: "Alice Smith",
+			Active:      true,
+			Groups:      []Reference{{Value: "4", Display: "devs"}},
+			Meta: Meta{
+				ResourceType: resourceUser,
+				Created:      updatedAt,
+				LastModified: updatedAt,
+				Version:      common.ETag(updatedAt),
+			},
+		}, got)
+	})
+
+	t.Run("should return not found for non-numeric id", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		_, err := svc.FindUser(IdRequest{Id: "abc"})
+		a.Equal(404, toError(err).Status)
+		a.True(employees.AssertNotCalled(t, "FindById", mock.Anything))
+	})
+}
+
+func TestServiceCreateUser(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should create employee with login from userName", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		login := "alice"
+		employees.On("Create", employee.CreateRequest{Name: "Alice Smith", Login: &login}).Return(int64(1), nil)
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.CreateUser(context.Background(), CreateRequest{Resource: resourceOf(t, `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "alice",
+			"name": {"formatted": "Alice Smith"}
+		}`)})
+		a.Nil(err)
+		a.Equal("1", got.Id)
+	})
+
+	t.Run("should reject user without userName", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		_, err := svc.CreateUser(context.Background(), CreateRequest{Resource: resourceOf(t, `{"displayName": "Alice"}`)})
+		a.Equal(Error{Status: 400, ScimType: scimTypeInvalidValue, Detail: "userName is required"}, err)
+	})
+
+	t.Run("should return conflict when employee exists", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		login := "alice"
+		employees.On("Create", employee.CreateRequest{Name: "alice", Login: &login}).
+			Return(int64(0), common.AlreadyExistsError{Message: "employee already exists"})
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		_, err := svc.CreateUser(context.Background(), CreateRequest{Resource: resourceOf(t, `{"userName": "alice"}`)})
+		a.Equal(Error{Status: 409, ScimType: scimTypeUniqueness, Detail: "employee already exists"}, toError(err))
+	})
+}
+
+func TestServicePatchUser(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should rename employee keeping primary role", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil)
+		renamed := alice()
+		renamed.Name = "Alice Jones"
+		renamed.Roles = nil
+		roleId := int64(3)
+		login := "alice"
+		employees.On("Update", employee.UpdateRequest{Id: 1, Name: "Alice Jones", RoleId: &roleId, Login: &login}).
+			Return(renamed, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.PatchUser(context.Background(), PatchRequest{Id: "1", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "replace", Path: "displayName", Value: "Alice Jones"}},
+		}})
+		a.Nil(err)
+		a.Equal("Alice Jones", got.DisplayName)
+		a.Equal([]Reference{{Value: "4", Display: "devs"}}, got.Groups)
+		a.True(employees.AssertNotCalled(t, "DeleteById", mock.Anything))
+	})
+
+	t.Run("should suspend employee on deactivation", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		suspended := alice()
+		suspended.Status = employee.StatusSuspended
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil).Once()
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(suspended, nil)
+		employees.On("Transition", employee.TransitionRequest{
+			Id: 1, Transition: employee.TransitionSuspend, Reason: "SCIM active set to false",
+		}).Return(employee.TransitionResponse{}, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.PatchUser(context.Background(), PatchRequest{Id: "1", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "Replace", Value: map[string]any{"active": "False"}}},
+		}})
+		a.Nil(err)
+		a.False(got.Active)
+		// имя и логин не изменились, поэтому сотрудник не обновляется
+		a.True(employees.AssertNotCalled(t, "Update", mock.Anything))
+		a.True(employees.AssertNotCalled(t, "DeleteById", mock.Anything))
+	})
+
+	t.Run("should resume suspended employee on activation", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		suspended := alice()
+		suspended.Status = employee.StatusSuspended
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(suspended, nil).Once()
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil)
+		employees.On("Transition", employee.TransitionRequest{
+			Id: 1, Transition: employee.TransitionResume, Reason: "SCIM active set to true",
+		}).Return(employee.TransitionResponse{}, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.PatchUser(context.Background(), PatchRequest{Id: "1", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "replace", Path: "active", Value: true}},
+		}})
+		a.Nil(err)
+		a.True(got.Active)
+	})
+
+	t.Run("should not transition terminated employee", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		terminated := alice()
+		terminated.Status = employee.StatusTerminated
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(terminated, nil)
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		got, err := svc.PatchUser(context.Background(), PatchRequest{Id: "1", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "replace", Path: "active", Value: true}},
+		}})
+		a.Nil(err)
+		a.False(got.Active)
+		a.True(employees.AssertNotCalled(t, "Transition", mock.Anything))
+	})
+	t.Run("should pass version to update", func(t *testing.T) {
+		employees := new(MockEmployeeSvc)
+		employees.On("FindById", employee.IdRequest{Id: 1}).Return(alice(), nil)
+		version := updatedAt.Add(-time.Hour)
+		roleId := int64(3)
+		login := "alice"
+		employees.On("Update", employee.UpdateRequest{Id: 1, Name: "Alice Smith", RoleId: &roleId, Login: &login, Version: &version}).
+			Return(employee.Response{}, common.PreconditionFailedError{Message: "employee was modified"})
+		svc := NewService(employees, new(MockRoleSvc), new(MockAssignmentSvc))
+
+		_, err := svc.PatchUser(context.Background(), PatchRequest{Id: "1", Version: &version, PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "add", Path: "nickName", Value: "Al"}},
+		}})
+		a.Equal(412, toError(err).Status)
+	})
+}
+
+func TestServiceFindGroups(t *testing.T) {
+	a := assert.New(t)
+	firstPage := role.ListRequest{PageRequest: common.PageRequest{Limit: defaultCount}}
+	ops := role.Response{Id: 5, Name: "ops", CreatedAt: updatedAt, UpdatedAt: updatedAt}
+
+	t.Run("should load members only for returned groups", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", role.ListRequest{
+			PageRequest: common.PageRequest{Limit: defaultCount},
+			Filter:      &database.Filter{Condition: "lower(name) = ?", Args: []any{"ops"}},
+		}).Return(common.Page[role.Response]{Items: []role.Response{ops}, PageInfo: common.PageInfo{Total: 1}}, nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByRole", assignment.RoleRequest{RoleId: 5}).Return([]assignment.Response{
+			{Id: 10, EmployeeId: 2, EmployeeName: "Bob", RoleId: 5},
+			{Id: 11, EmployeeId: 2, EmployeeName: "Bob", RoleId: 5},
+		}, nil)
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		got, err := svc.FindGroups(ListRequest{Filter: `displayName eq "OPS"`})
+		a.Nil(err)
+		a.Equal(1, got.TotalResults)
+		a.Equal([]Reference{{Value: "2", Display: "Bob"}}, got.Resources[0].Members)
+		a.True(assignments.AssertNotCalled(t, "FindByRole", assignment.RoleRequest{RoleId: 4}))
+	})
+
+	t.Run("should filter by members in query", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", mock.MatchedBy(func(request role.ListRequest) bool {
+			return request.Filter != nil && strings.HasPrefix(request.Filter.Condition, "exists(") &&
+				assert.ObjectsAreEqual([]any{"1"}, request.Filter.Args)
+		})).Return(common.Page[role.Response]{Items: []role.Response{devs()}, PageInfo: common.PageInfo{Total: 1}}, nil)
+		svc := NewService(new(MockEmployeeSvc), roles, new(MockAssignmentSvc))
+
+		got, err := svc.FindGroups(ListRequest{Filter: `members[value eq "1"]`, ExcludedAttributes: []string{"members"}})
+		a.Nil(err)
+		a.Equal(1, got.TotalResults)
+		a.Equal("4", got.Resources[0].Id)
+	})
+
+	t.Run("should skip members when excluded", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindAll", firstPage).Return(common.Page[role.Response]{Items: []role.Response{devs()}}, nil)
+		assignments := new(MockAssignmentSvc)
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		got, err := svc.FindGroups(ListRequest{ExcludedAttributes: []string{"Members"}})
+		a.Nil(err)
+		a.Len(got.Resources, 1)
+		a.Nil(got.Resources[0].Members)
+		a.True(assignments.AssertNotCalled(t, "FindByRole", mock.Anything))
+	})
+}
+
+func TestServicePatchGroup(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should grant and revoke roles to match members", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindById", role.IdRequest{Id: 4}).Return(devs(), nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByRole", assignment.RoleRequest{RoleId: 4}).Return([]assignment.Response{
+			{Id: 10, EmployeeId: 1, EmployeeName: "Alice", RoleId: 4},
+			{Id: 11, EmployeeId: 2, EmployeeName: "Bob", RoleId: 4},
+		}, nil)
+		assignments.On("Grant", assignment.GrantRequest{EmployeeId: 3, RoleId: 4}).Return(int64(12), nil)
+		assignments.On("Revoke", assignment.RevokeRequest{EmployeeId: 1, RoleId: 4}).Return(nil)
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		_, err := svc.PatchGroup(context.Background(), PatchRequest{Id: "4", PatchOp: PatchOp{
+			Operations: []PatchOperation{
+				{Op: "add", Path: "members", Value: []any{map[string]any{"value": "3"}}},
+				{Op: "remove", Path: `members[value eq "1"]`},
+			},
+		}})
+		a.Nil(err)
+		assignments.AssertCalled(t, "Grant", assignment.GrantRequest{EmployeeId: 3, RoleId: 4})
+		assignments.AssertCalled(t, "Revoke", assignment.RevokeRequest{EmployeeId: 1, RoleId: 4})
+		a.True(roles.AssertNotCalled(t, "Update", mock.Anything))
+	})
+
+	t.Run("should rename role", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindById", role.IdRequest{Id: 4}).Return(devs(), nil)
+		roles.On("Update", role.UpdateRequest{Id: 4, Name: "developers"}).Return(role.Response{}, nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByRole", assignment.RoleRequest{RoleId: 4}).Return([]assignment.Response{}, nil)
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		_, err := svc.PatchGroup(context.Background(), PatchRequest{Id: "4", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "replace", Value: map[string]any{"displayName": "developers"}}},
+		}})
+		a.Nil(err)
+		roles.AssertCalled(t, "Update", role.UpdateRequest{Id: 4, Name: "developers"})
+	})
+
+	t.Run("should reject invalid member value", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindById", role.IdRequest{Id: 4}).Return(devs(), nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByRole", assignment.RoleRequest{RoleId: 4}).Return([]assignment.Response{}, nil)
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		_, err := svc.PatchGroup(context.Background(), PatchRequest{Id: "4", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "add", Path: "members", Value: map[string]any{"value": "abc"}}},
+		}})
+		a.Equal(scimTypeInvalidValue, toError(err).ScimType)
+		a.True(assignments.AssertNotCalled(t, "Grant", mock.Anything))
+	})
+
+	t.Run("should return grant error", func(t *testing.T) {
+		roles := new(MockRoleSvc)
+		roles.On("FindById", role.IdRequest{Id: 4}).Return(devs(), nil)
+		assignments := new(MockAssignmentSvc)
+		assignments.On("FindByRole", assignment.RoleRequest{RoleId: 4}).Return([]assignment.Response{}, nil)
+		assignments.On("Grant", assignment.GrantRequest{EmployeeId: 9, RoleId: 4}).
+			Return(int64(0), common.NotFoundError{Message: "error finding employee with id 9"})
+		svc := NewService(new(MockEmployeeSvc), roles, assignments)
+
+		_, err := svc.PatchGroup(context.Background(), PatchRequest{Id: "4", PatchOp: PatchOp{
+			Operations: []PatchOperation{{Op: "add", Path: "members", Value: map[string]any{"value": "9"}}},
+		}})
+		a.Equal(404, toError(err).Status)
+	})
+}
+
+func TestToError(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should hide internal errors", func(t *testing.T) {
+		a.Equal(Error{Status: 500, Detail: "internal server error"}, toError(errors.New("connection refused")))
+	})
+
+	t.Run("should map filter errors to invalidFilter", func(t *testing.T) {
+		a.Equal(Error{Status: 400, ScimType: scimTypeInvalidFilter, Detail: "bad"}, toError(FilterError{Message: "bad"}))
+	})
+}
@@ -0,0 +1,184 @@
+package sod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты правил разделения обязанностей
+const (
+	permissionRead   = "sod:read"
+	permissionManage = "sod:manage"
+)
+
+type Controller struct {
+	server     *web.Server
+	sodService Svc
+	logger     *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (Response, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Delete(ctx context.Context, request IdRequest) error
+	FindViolations(request ViolationsRequest) ([]ViolationResponse, error)
+}
+
+func NewController(server *web.Server, sodService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:     server,
+		sodService: sodService,
+		logger:     logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/sod-rules", c.server.Require(permissionManage), c.CreateRule)
+	c.server.GroupApiV1.Get("/sod-rules/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/sod-rules", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Put("/sod-rules/:id", c.server.Require(permissionManage), c.UpdateRule)
+	c.server.GroupApiV1.Delete("/sod-rules/:id", c.server.Require(permissionManage), c.DeleteRule)
+	c.server.GroupApiV1.Get("/sod-violations", c.server.Require(permissionRead), c.FindViolations)
+}
+
+func (c *Controller) CreateRule(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create sod rule: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create sod rule: received request", zap.Any("request", request))
+	response, err := c.sodService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create sod rule: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create sod rule: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find sod rule by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find sod rule by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.sodService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find sod rule by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find sod rule by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all sod rules: received request")
+	responses, err := c.sodService.FindAll()
+	if err != nil {
+		c.logger.Error("find all sod rules: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all sod rules: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) UpdateRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update sod rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update sod rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update sod rule: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.sodService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update sod rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update sod rule: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DeleteRule(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete sod rule: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete sod rule: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.sodService.Delete(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete sod rule: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete sod rule: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+// FindViolations отчёт о нарушениях правил; фильтры в query: rule_id, employee_id
+func (c *Controller) FindViolations(ctx *fiber.Ctx) error {
+	request, err := parseViolationsRequest(ctx)
+	if err != nil {
+		c.logger.Error("find sod violations: query parse error", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("find sod violations: received request", zap.Any("request", request))
+	responses, err := c.sodService.FindViolations(request)
+	if err != nil {
+		c.logger.Error("find sod violations: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find sod violations: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func parseViolationsRequest(ctx *fiber.Ctx) (request ViolationsRequest, err error) {
+	if request.RuleId, err = parseOptionalId(ctx, "rule_id"); err != nil {
+		return request, err
+	}
+	if request.EmployeeId, err = parseOptionalId(ctx, "employee_id"); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+func parseOptionalId(ctx *fiber.Ctx, name string) (*int64, error) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, raw)
+	}
+	return &id, nil
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}) || errors.As(err, &common.AlreadyExistsError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
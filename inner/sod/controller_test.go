@@ -0,0 +1,151 @@
+package sod
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Delete(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindViolations(request ViolationsRequest) ([]ViolationResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]ViolationResponse), args.Error(1)
+}
+
+func TestControllerCreateRule(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return created rule", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", CreateRequest{Name: "payments", RoleAId: 7, RoleBId: 3}).
+			Return(Response{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7}, nil)
+
+		body := strings.NewReader(`{"name":"payments","role_a_id":7,"role_b_id":3}`)
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/sod-rules", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[Response]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(1), responseBody.Data.Id)
+	})
+
+	t.Run("should return bad request for duplicate rule", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.Anything).Return(Response{}, common.AlreadyExistsError{Message: "already exists"})
+
+		body := strings.NewReader(`{"name":"payments","role_a_id":7,"role_b_id":3}`)
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/sod-rules", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerDeleteRule(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return not found error", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Delete", IdRequest{Id: 1}).Return(common.NotFoundError{Message: "sod rule with id 1 not found"})
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/sod-rules/1", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerFindViolations(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters from query", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindViolations", mock.MatchedBy(func(r ViolationsRequest) bool {
+			return r.RuleId != nil && *r.RuleId == 4 && r.EmployeeId == nil
+		})).Return([]ViolationResponse{{RuleId: 4, EmployeeId: 1}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/sod-violations?rule_id=4", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("should return bad request for invalid filter", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/sod-violations?employee_id=abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindViolations", mock.Anything))
+	})
+}
@@ -0,0 +1,100 @@
+package sod
+
+import "time"
+
+// Entity правило разделения обязанностей: роли RoleAId и RoleBId нельзя держать одновременно
+type Entity struct {
+	Id          int64     `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	RoleAId     int64     `db:"role_a_id"`
+	RoleBId     int64     `db:"role_b_id"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:          e.Id,
+		Name:        e.Name,
+		Description: e.Description,
+		RoleAId:     e.RoleAId,
+		RoleBId:     e.RoleBId,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние правила в журнале аудита
+type auditSnapshot struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	RoleAId     int64  `json:"role_a_id"`
+	RoleBId     int64  `json:"role_b_id"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Name: e.Name, Description: e.Description, RoleAId: e.RoleAId, RoleBId: e.RoleBId}
+}
+
+type Response struct {
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	RoleAId     int64     `json:"role_a_id"`
+	RoleBId     int64     `json:"role_b_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OverrideEntity назначение, выданное вопреки правилу, и обоснование этого решения
+type OverrideEntity struct {
+	Id            int64     `db:"id"`
+	RuleId        int64     `db:"rule_id"`
+	EmployeeId    int64     `db:"employee_id"`
+	AssignmentId  *int64    `db:"assignment_id"`
+	Justification string    `db:"justification"`
+	CreatedBy     string    `db:"created_by"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// ViolationEntity сотрудник, который сейчас держит обе роли правила, напрямую или через иерархию ролей
+type ViolationEntity struct {
+	RuleId       int64  `db:"rule_id"`
+	RuleName     string `db:"rule_name"`
+	EmployeeId   int64  `db:"employee_id"`
+	EmployeeName string `db:"employee_name"`
+	RoleAId      int64  `db:"role_a_id"`
+	RoleAName    string `db:"role_a_name"`
+	RoleBId      int64  `db:"role_b_id"`
+	RoleBName    string `db:"role_b_name"`
+	Overridden   bool   `db:"overridden"`
+}
+
+func (e *ViolationEntity) toResponse() ViolationResponse {
+	return ViolationResponse{
+		RuleId:       e.RuleId,
+		RuleName:     e.RuleName,
+		EmployeeId:   e.EmployeeId,
+		EmployeeName: e.EmployeeName,
+		RoleAId:      e.RoleAId,
+		RoleAName:    e.RoleAName,
+		RoleBId:      e.RoleBId,
+		RoleBName:    e.RoleBName,
+		Overridden:   e.Overridden,
+	}
+}
+
+// ViolationResponse нарушение правила; Overridden - сотруднику выдавалось назначение вопреки этому правилу
+type ViolationResponse struct {
+	RuleId       int64  `json:"rule_id"`
+	RuleName     string `json:"rule_name"`
+	EmployeeId   int64  `json:"employee_id"`
+	EmployeeName string `json:"employee_name"`
+	RoleAId      int64  `json:"role_a_id"`
+	RoleAName    string `json:"role_a_name"`
+	RoleBId      int64  `json:"role_b_id"`
+	RoleBName    string `json:"role_b_name"`
+	Overridden   bool   `json:"overridden"`
+}
@@ -0,0 +1,145 @@
+package sod
+
+import (
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+// grantedClosure роль $2 вместе с ролями, которые она включает; удалённые роли не передают наследование
+const grantedClosure = `granted(id) as (
+		select id from role where id = $2 and deleted_at is null
+		union
+		select rh.child_id from role_hierarchy rh
+			join granted g on rh.parent_id = g.id
+			join role c on c.id = rh.child_id and c.deleted_at is null
+	)`
+
+// heldClosure роли сотрудника $1 - основная роль и назначения, пересекающиеся с интервалом [$3, $4), -
+// вместе с включёнными в них; основная роль даёт разрешения наравне с назначениями
+const heldClosure = `held(id) as (
+		select direct.role_id from (
+			select er.role_id from employee_role er
+			where er.employee_id = $1
+				and (er.valid_to is null or er.valid_to > $3)
+				and ($4::timestamptz is null or er.valid_from < $4)
+			union
+			select e.role_id from employee e where e.id = $1 and e.role_id is not null
+		) direct
+			join role r on r.id = direct.role_id and r.deleted_at is null
+		union
+		select rh.child_id from role_hierarchy rh
+			join held h on rh.parent_id = h.id
+			join role c on c.id = rh.child_id and c.deleted_at is null
+	)`
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := `insert into sod_rule (name, description, role_a_id, role_b_id) values ($1, $2, $3, $4)
+		returning *`
+	err = tx.Get(&saved, query, e.Name, e.Description, e.RoleAId, e.RoleBId)
+	return saved, err
+}
+
+// ExistsTx есть ли, кроме правила exceptId, правило с тем же именем или той же парой ролей
+func (r *Repository) ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (exists bool, err error) {
+	query := `select exists(select 1 from sod_rule
+		where id <> $4 and (name = $1 or (role_a_id = $2 and role_b_id = $3)))`
+	err = tx.Get(&exists, query, e.Name, e.RoleAId, e.RoleBId, exceptId)
+	return exists, err
+}
+
+func (r *Repository) FindById(id int64) (rule Entity, err error) {
+	err = r.db.Get(&rule, "select * from sod_rule where id = $1", id)
+	return rule, err
+}
+
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (rule Entity, err error) {
+	err = tx.Get(&rule, "select * from sod_rule where id = $1 for update", id)
+	return rule, err
+}
+
+func (r *Repository) FindAll() (rules []Entity, err error) {
+	err = r.db.Select(&rules, "select * from sod_rule order by id")
+	return rules, err
+}
+
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := `update sod_rule set name = $2, description = $3, role_a_id = $4, role_b_id = $5
+		where id = $1 returning *`
+	err = tx.Get(&updated, query, e.Id, e.Name, e.Description, e.RoleAId, e.RoleBId)
+	return updated, err
+}
+
+func (r *Repository) DeleteTx(tx *sqlx.Tx, id int64) (deleted Entity, err error) {
+	err = tx.Get(&deleted, "delete from sod_rule where id = $1 returning *", id)
+	return deleted, err
+}
+
+// FindConflictsTx правила, которые нарушит назначение роли roleId сотруднику employeeId на интервал [from, to):
+// одна роль правила входит в назначаемую, другая - в неё же или в роли, которые сотрудник держит в этом интервале
+func (r *Repository) FindConflictsTx(
+	tx *sqlx.Tx,
+	employeeId, roleId int64,
+	from time.Time,
+	to *time.Time,
+) (rules []Entity, err error) {
+	query := `with recursive ` + grantedClosure + `, ` + heldClosure + `
+		select s.* from sod_rule s
+		where (s.role_a_id in (select id from granted)
+				and (s.role_b_id in (select id from granted) or s.role_b_id in (select id from held)))
+			or (s.role_b_id in (select id from granted) and s.role_a_id in (select id from held))
+		order by s.id`
+	err = tx.Select(&rules, query, employeeId, roleId, from, to)
+	return rules, err
+}
+
+func (r *Repository) SaveOverrideTx(tx *sqlx.Tx, e OverrideEntity) (id int64, err error) {
+	query := `insert into sod_override (rule_id, employee_id, assignment_id, justification, created_by)
+		values ($1, $2, $3, $4, $5) returning id`
+	err = tx.QueryRowx(query, e.RuleId, e.EmployeeId, e.AssignmentId, e.Justification, e.CreatedBy).Scan(&id)
+	return id, err
+}
+
+// FindViolations сотрудники, которые в момент at держат обе роли правила как основную роль, по назначению
+// или через иерархию ролей. Удалённые сотрудники и роли не учитываются
+func (r *Repository) FindViolations(ruleId, employeeId *int64, at time.Time) (violations []ViolationEntity, err error) {
+	query := `with recursive effective(employee_id, role_id) as (
+			select direct.employee_id, direct.role_id from (
+				select er.employee_id, er.role_id from employee_role er
+				where er.valid_from <= $1 and (er.valid_to is null or er.valid_to > $1)
+				union
+				select e.id, e.role_id from employee e where e.role_id is not null
+			) direct
+				join employee e on e.id = direct.employee_id and e.deleted_at is null
+				join role r on r.id = direct.role_id and r.deleted_at is null
+			where $3::bigint is null or direct.employee_id = $3
+			union
+			select ef.employee_id, rh.child_id from role_hierarchy rh
+				join effective ef on rh.parent_id = ef.role_id
+				join role c on c.id = rh.child_id and c.deleted_at is null
+		)
+		select s.id as rule_id, s.name as rule_name, e.id as employee_id, e.name as employee_name,
+			s.role_a_id, ra.name as role_a_name, s.role_b_id, rb.name as role_b_name,
+			exists(select 1 from sod_override o where o.rule_id = s.id and o.employee_id = e.id) as overridden
+		from sod_rule s
+		join effective a on a.role_id = s.role_a_id
+		join effective b on b.role_id = s.role_b_id and b.employee_id = a.employee_id
+		join employee e on e.id = a.employee_id
+		join role ra on ra.id = s.role_a_id
+		join role rb on rb.id = s.role_b_id
+		where $2::bigint is null or s.id = $2
+		order by s.id, e.id`
+	err = r.db.Select(&violations, query, at, ruleId, employeeId)
+	return violations, err
+}
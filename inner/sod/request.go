@@ -0,0 +1,27 @@
+package sod
+
+// CreateRequest запретить держать две роли одновременно; порядок ролей не важен
+type CreateRequest struct {
+	Name        string `json:"name" validate:"required,min=2,max=155"`
+	Description string `json:"description" validate:"max=2000"`
+	RoleAId     int64  `json:"role_a_id" validate:"required,gt=0"`
+	RoleBId     int64  `json:"role_b_id" validate:"required,gt=0,nefield=RoleAId"`
+}
+
+type UpdateRequest struct {
+	Id          int64  `json:"id" validate:"required,gt=0"`
+	Name        string `json:"name" validate:"required,min=2,max=155"`
+	Description string `json:"description" validate:"max=2000"`
+	RoleAId     int64  `json:"role_a_id" validate:"required,gt=0"`
+	RoleBId     int64  `json:"role_b_id" validate:"required,gt=0,nefield=RoleAId"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// ViolationsRequest фильтры отчёта о нарушениях; пустой фильтр не ограничивает выборку
+type ViolationsRequest struct {
+	RuleId     *int64 `json:"rule_id" validate:"omitempty,gt=0"`
+	EmployeeId *int64 `json:"employee_id" validate:"omitempty,gt=0"`
+}
@@ -0,0 +1,228 @@
+package sod
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/role"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "sod_rule"
+
+type Service struct {
+	repo      Repo
+	roleRepo  RoleRepo
+	auditor   Auditor
+	validator Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (bool, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindViolations(ruleId, employeeId *int64, at time.Time) ([]ViolationEntity, error)
+}
+
+type RoleRepo interface {
+	FindById(id int64) (role.Entity, error)
+}
+
+// Auditor журнал аудита: событие записывается в той же транзакции, что и изменение
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, roleRepo RoleRepo, auditor Auditor, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		roleRepo:  roleRepo,
+		auditor:   auditor,
+		validator: validator,
+	}
+}
+
+// Create завести правило; пара ролей сохраняется упорядоченной, поэтому правило A-B и B-A одно и то же
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.newEntity(request.Name, request.Description, request.RoleAId, request.RoleBId)
+	if err != nil {
+		return Response{}, err
+	}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating sod rule", func(tx *sqlx.Tx) error {
+		if err := svc.checkUnique(tx, entity, 0); err != nil {
+			return err
+		}
+		saved, err = svc.repo.SaveTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error saving sod rule %s: %w", request.Name, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return saved.toResponse(), nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding sod rule with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all sod rules: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// Update заменить имя, описание и пару ролей правила. Новое правило не отзывает уже выданные роли:
+// существующие нарушения видны в отчёте
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.newEntity(request.Name, request.Description, request.RoleAId, request.RoleBId)
+	if err != nil {
+		return Response{}, err
+	}
+	entity.Id = request.Id
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating sod rule", func(tx *sqlx.Tx) error {
+		before, err := svc.repo.FindByIdForUpdateTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("sod rule with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error finding sod rule with id %d: %w", request.Id, err)
+		}
+		if err = svc.checkUnique(tx, entity, request.Id); err != nil {
+			return err
+		}
+		updated, err = svc.repo.UpdateTx(tx, entity)
+		if err != nil {
+			return fmt.Errorf("error updating sod rule with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// Delete удалить правило вместе с записями о назначениях вопреки ему; обоснования остаются в журнале аудита
+func (svc *Service) Delete(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting sod rule", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("sod rule with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting sod rule with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted.Id,
+			Before:     deleted.auditSnapshot(),
+		})
+	})
+}
+
+// FindViolations сотрудники, которые сейчас держат обе роли какого-либо правила: в том числе получившие
+// роли до появления правила и назначенные вопреки ему с обоснованием
+func (svc *Service) FindViolations(request ViolationsRequest) ([]ViolationResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	entities, err := svc.repo.FindViolations(request.RuleId, request.EmployeeId, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving sod violations: %w", err)
+	}
+	responses := make([]ViolationResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// newEntity проверить, что обе роли существуют, и упорядочить пару
+func (svc *Service) newEntity(name, description string, roleAId, roleBId int64) (Entity, error) {
+	for _, roleId := range []int64{roleAId, roleBId} {
+		if _, err := svc.roleRepo.FindById(roleId); err != nil {
+			return Entity{}, common.NotFoundError{
+				Message: fmt.Sprintf("error finding role with id %d: %v", roleId, err),
+			}
+		}
+	}
+	if roleAId > roleBId {
+		roleAId, roleBId = roleBId, roleAId
+	}
+	return Entity{Name: name, Description: description, RoleAId: roleAId, RoleBId: roleBId}, nil
+}
+
+func (svc *Service) checkUnique(tx *sqlx.Tx, entity Entity, exceptId int64) error {
+	exists, err := svc.repo.ExistsTx(tx, entity, exceptId)
+	if err != nil {
+		return fmt.Errorf("error finding sod rule %s: %w", entity.Name, err)
+	}
+	if exists {
+		return common.AlreadyExistsError{
+			Message: fmt.Sprintf("sod rule named %s or for roles %d and %d already exists",
+				entity.Name, entity.RoleAId, entity.RoleBId),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package sod
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/role"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) ExistsTx(tx *sqlx.Tx, e Entity, exceptId int64) (bool, error) {
+	args := m.Called(tx, e, exceptId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindViolations(ruleId, employeeId *int64, at time.Time) ([]ViolationEntity, error) {
+	args := m.Called(ruleId, employeeId)
+	return args.Get(0).([]ViolationEntity), args.Error(1)
+}
+
+type MockRoleRepo struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepo) FindById(id int64) (role.Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(role.Entity), args.Error(1)
+}
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should store role pair ordered and record audit event", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roles, auditor, validator.New())
+
+		roles.On("FindById", int64(7)).Return(role.Entity{Id: 7}, nil)
+		roles.On("FindById", int64(3)).Return(role.Entity{Id: 3}, nil)
+		expected := Entity{Name: "payments", RoleAId: 3, RoleBId: 7}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, expected, int64(0)).Return(false, nil)
+		saved := expected
+		saved.Id = 1
+		repo.On("SaveTx", noTx, expected).Return(saved, nil)
+
+		response, err := svc.Create(context.Background(), CreateRequest{Name: "payments", RoleAId: 7, RoleBId: 3})
+		a.NoError(err)
+		a.Equal(int64(1), response.Id)
+		a.Equal(int64(3), response.RoleAId)
+		a.Len(auditor.events, 1)
+		a.Equal(auditEntityType, auditor.events[0].EntityType)
+		a.Equal(saved.auditSnapshot(), auditor.events[0].After)
+	})
+
+	t.Run("should reject rule for the same role", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "payments", RoleAId: 3, RoleBId: 3})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return not found for unknown role", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(StubAuditor), validator.New())
+
+		roles.On("FindById", int64(3)).Return(role.Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "payments", RoleAId: 3, RoleBId: 7})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.True(repo.AssertNotCalled(t, "BeginTransaction"))
+	})
+
+	t.Run("should return already exists for duplicate rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roles, auditor, validator.New())
+
+		roles.On("FindById", mock.Anything).Return(role.Entity{}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("ExistsTx", noTx, mock.Anything, int64(0)).Return(true, nil)
+
+		_, err := svc.Create(context.Background(), CreateRequest{Name: "payments", RoleAId: 3, RoleBId: 7})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should record before and after in audit event", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, roles, auditor, validator.New())
+
+		before := Entity{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7}
+		after := Entity{Id: 1, Name: "payments", Description: "four eyes", RoleAId: 3, RoleBId: 8}
+		roles.On("FindById", mock.Anything).Return(role.Entity{}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(before, nil)
+		repo.On("ExistsTx", noTx, mock.Anything, int64(1)).Return(false, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 1, Name: "payments", Description: "four eyes", RoleAId: 3, RoleBId: 8}).
+			Return(after, nil)
+
+		response, err := svc.Update(context.Background(), UpdateRequest{
+			Id: 1, Name: "payments", Description: "four eyes", RoleAId: 8, RoleBId: 3,
+		})
+		a.NoError(err)
+		a.Equal(int64(8), response.RoleBId)
+		a.Len(auditor.events, 1)
+		a.Equal(before.auditSnapshot(), auditor.events[0].Before)
+		a.Equal(after.auditSnapshot(), auditor.events[0].After)
+	})
+
+	t.Run("should return not found for unknown rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		roles := new(MockRoleRepo)
+		svc := NewService(repo, roles, new(StubAuditor), validator.New())
+
+		roles.On("FindById", mock.Anything).Return(role.Entity{}, nil)
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 1, Name: "payments", RoleAId: 3, RoleBId: 7})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should return not found for unknown rule", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, new(MockRoleRepo), auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(1)).Return(Entity{}, sql.ErrNoRows)
+
+		err := svc.Delete(context.Background(), IdRequest{Id: 1})
+		a.ErrorAs(err, &common.NotFoundError{})
+		a.Empty(auditor.events)
+	})
+}
+
+func TestServiceFindViolations(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass filters to repository", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAuditor), validator.New())
+
+		ruleId := int64(4)
+		repo.On("FindViolations", &ruleId, (*int64)(nil)).Return([]ViolationEntity{
+			{RuleId: 4, RuleName: "payments", EmployeeId: 1, EmployeeName: "Bob", Overridden: true},
+		}, nil)
+
+		responses, err := svc.FindViolations(ViolationsRequest{RuleId: &ruleId})
+		a.NoError(err)
+		a.Len(responses, 1)
+		a.Equal("Bob", responses[0].EmployeeName)
+		a.True(responses[0].Overridden)
+	})
+
+	t.Run("should wrap repository error", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(MockRoleRepo), new(StubAuditor), validator.New())
+
+		dbErr := errors.New("database error")
+		repo.On("FindViolations", mock.Anything, mock.Anything).Return([]ViolationEntity(nil), dbErr)
+
+		_, err := svc.FindViolations(ViolationsRequest{})
+		a.ErrorIs(err, dbErr)
+	})
+}
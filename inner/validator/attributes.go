@@ -0,0 +1,77 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"idm/inner/common"
+	"sort"
+)
+
+// Типы значений пользовательских атрибутов
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeDate    = "date"
+)
+
+// Attribute определение пользовательского атрибута из схемы атрибутов
+type Attribute struct {
+	Key      string
+	Type     string
+	Required bool
+}
+
+// ValidateAttributes проверить пользовательские атрибуты по схеме: каждый ключ должен быть определён в схеме,
+// значение - иметь объявленный тип, обязательные атрибуты - присутствовать. Значения приходят из JSON,
+// поэтому числа - float64, а даты - строки в формате YYYY-MM-DD. Возвращает все найденные нарушения сразу
+func (v Validator) ValidateAttributes(values map[string]any, schema []Attribute) error {
+	definitions := make(map[string]Attribute, len(schema))
+	for _, attribute := range schema {
+		definitions[attribute.Key] = attribute
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, key := range keys {
+		attribute, ok := definitions[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("attribute %q is not defined", key))
+			continue
+		}
+		if !hasType(values[key], attribute.Type) {
+			errs = append(errs, fmt.Errorf("attribute %q must be a %s", key, attribute.Type))
+		}
+	}
+	for _, attribute := range schema {
+		if _, ok := values[attribute.Key]; attribute.Required && !ok {
+			errs = append(errs, fmt.Errorf("attribute %q is required", attribute.Key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hasType(value any, attributeType string) bool {
+	switch attributeType {
+	case AttributeString:
+		_, ok := value.(string)
+		return ok
+	case AttributeNumber:
+		_, ok := value.(float64)
+		return ok
+	case AttributeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AttributeDate:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, err := common.ParseDate(s)
+		return err == nil
+	}
+	return false
+}
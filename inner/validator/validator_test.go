@@ -36,6 +36,20 @@ func TestValidatorEmployeeCreateRequest(t *testing.T) {
 		err := v.Validate(request)
 		a.Error(err)
 	})
+
+	t.Run("should pass with role id", func(t *testing.T) {
+		roleId := int64(1)
+		request := employee.CreateRequest{Name: "John", RoleId: &roleId}
+		err := v.Validate(request)
+		a.NoError(err)
+	})
+
+	t.Run("should fail for non-positive role id", func(t *testing.T) {
+		roleId := int64(0)
+		request := employee.CreateRequest{Name: "John", RoleId: &roleId}
+		err := v.Validate(request)
+		a.Error(err)
+	})
 }
 
 func TestValidatorEmployeeIdRequest(t *testing.T) {
@@ -126,3 +140,40 @@ func TestValidatorRoleIdsRequest(t *testing.T) {
 		a.Error(err)
 	})
 }
+
+func TestValidatorAttributes(t *testing.T) {
+	a := assert.New(t)
+	v := validator.New()
+	schema := []validator.Attribute{
+		{Key: "cost_center", Type: validator.AttributeString, Required: true},
+		{Key: "grade", Type: validator.AttributeNumber},
+		{Key: "remote", Type: validator.AttributeBoolean},
+		{Key: "badge_expires", Type: validator.AttributeDate},
+	}
+
+	t.Run("should pass for values of declared types", func(t *testing.T) {
+		err := v.ValidateAttributes(map[string]any{
+			"cost_center": "CC-42", "grade": float64(7), "remote": true, "badge_expires": "2026-03-01",
+		}, schema)
+		a.NoError(err)
+	})
+
+	t.Run("should fail for missing required attribute", func(t *testing.T) {
+		err := v.ValidateAttributes(map[string]any{"grade": float64(7)}, schema)
+		a.EqualError(err, `attribute "cost_center" is required`)
+	})
+
+	t.Run("should fail for undefined attribute", func(t *testing.T) {
+		err := v.ValidateAttributes(map[string]any{"cost_center": "CC-42", "shoe_size": float64(44)}, schema)
+		a.EqualError(err, `attribute "shoe_size" is not defined`)
+	})
+
+	t.Run("should report every value of wrong type", func(t *testing.T) {
+		err := v.ValidateAttributes(map[string]any{
+			"cost_center": "CC-42", "grade": "seven", "remote": "yes", "badge_expires": "01.03.2026",
+		}, schema)
+		a.EqualError(err, `attribute "badge_expires" must be a date`+"\n"+
+			`attribute "grade" must be a number`+"\n"+
+			`attribute "remote" must be a boolean`)
+	})
+}
@@ -1,22 +1,74 @@
 package web
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"idm/inner/common"
+)
 
 type Server struct {
 	App           *fiber.App
 	GroupApiV1    fiber.Router
 	GroupInternal fiber.Router
+	// GroupScim маршруты SCIM 2.0 (/scim/v2) для провиженинга из внешних каталогов
+	GroupScim fiber.Router
+	// Authorizer проверяет разрешения на маршрутах; задаётся до регистрации маршрутов
+	Authorizer Authorizer
+}
+
+// Authorizer выдаёт обработчик, который пропускает запрос дальше, только если
+// у автора запроса есть разрешение permission
+type Authorizer interface {
+	Require(permission string) fiber.Handler
 }
 
 func NewServer() *Server {
 	app := fiber.New()
+	app.Use(requestid.New(), withRequestId)
 	groupInternal := app.Group("/internal")
 	groupApi := app.Group("/api")
 	groupApiV1 := groupApi.Group("/v1")
+	groupScim := app.Group("/scim/v2")
 
 	return &Server{
 		App:           app,
 		GroupApiV1:    groupApiV1,
 		GroupInternal: groupInternal,
+		GroupScim:     groupScim,
+	}
+}
+
+// withRequestId передать идентификатор запроса (заголовок X-Request-ID) в контекст,
+// который контроллеры отдают сервисам, чтобы он попадал в журнал аудита
+func withRequestId(c *fiber.Ctx) error {
+	c.SetUserContext(common.WithRequestId(c.UserContext(), c.GetRespHeader(fiber.HeaderXRequestID)))
+	return c.Next()
+}
+
+// Require обработчик маршрута, требующий разрешение permission. Authorizer задаётся до
+// регистрации маршрутов: маршрут, зарегистрированный без него, остался бы без проверки
+func (s *Server) Require(permission string) fiber.Handler {
+	if s.Authorizer == nil {
+		panic("route requiring permission " + permission + " registered before authorizer is set")
+	}
+	return s.Authorizer.Require(permission)
+}
+
+// RequireIf обработчик маршрута, требующий разрешение permission только у запросов, для которых when
+// возвращает true, например когда параметр запроса расширяет выдачу сверх разрешения маршрута
+func (s *Server) RequireIf(permission string, when func(c *fiber.Ctx) bool) fiber.Handler {
+	require := s.Require(permission)
+	return func(c *fiber.Ctx) error {
+		if when(c) {
+			return require(c)
+		}
+		return c.Next()
+	}
+}
+
+// QueryFlag условие для RequireIf: в запросе передан параметр name со значением true
+func QueryFlag(name string) func(c *fiber.Ctx) bool {
+	return func(c *fiber.Ctx) bool {
+		return c.QueryBool(name)
 	}
 }
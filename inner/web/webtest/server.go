@@ -0,0 +1,22 @@
+package webtest
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"idm/inner/web"
+)
+
+// AllowAll пропускает любой запрос; разрешения маршрутов проверяются в тестах с отказывающим Authorizer
+type AllowAll struct{}
+
+func (AllowAll) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Next()
+	}
+}
+
+// NewServer сервер для тестов контроллеров: разрешения не проверяются
+func NewServer() *web.Server {
+	server := web.NewServer()
+	server.Authorizer = AllowAll{}
+	return server
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web"
+	"strconv"
+)
+
+// Разрешения, которые требуют маршруты подписок на события
+const (
+	permissionRead   = "webhooks:read"
+	permissionManage = "webhooks:manage"
+)
+
+type Controller struct {
+	server         *web.Server
+	webhookService Svc
+	logger         *common.Logger
+}
+
+type Svc interface {
+	Create(ctx context.Context, request CreateRequest) (SecretResponse, error)
+	FindById(request IdRequest) (Response, error)
+	FindAll() ([]Response, error)
+	Update(ctx context.Context, request UpdateRequest) (Response, error)
+	Delete(ctx context.Context, request IdRequest) error
+	FindDeliveries(request DeliveriesRequest) ([]DeliveryResponse, error)
+	Replay(ctx context.Context, request ReplayRequest) (ReplayResponse, error)
+}
+
+func NewController(server *web.Server, webhookService Svc, logger *common.Logger) *Controller {
+	return &Controller{
+		server:         server,
+		webhookService: webhookService,
+		logger:         logger,
+	}
+}
+
+func (c *Controller) RegisterRoutes() {
+	c.server.GroupApiV1.Post("/webhooks", c.server.Require(permissionManage), c.CreateWebhook)
+	c.server.GroupApiV1.Get("/webhooks/:id", c.server.Require(permissionRead), c.FindById)
+	c.server.GroupApiV1.Get("/webhooks", c.server.Require(permissionRead), c.FindAll)
+	c.server.GroupApiV1.Put("/webhooks/:id", c.server.Require(permissionManage), c.UpdateWebhook)
+	c.server.GroupApiV1.Delete("/webhooks/:id", c.server.Require(permissionManage), c.DeleteWebhook)
+	c.server.GroupApiV1.Get("/webhooks/:id/deliveries", c.server.Require(permissionRead), c.FindDeliveries)
+	c.server.GroupApiV1.Post("/webhooks/:id/replay", c.server.Require(permissionManage), c.Replay)
+}
+
+func (c *Controller) CreateWebhook(ctx *fiber.Ctx) error {
+	var request CreateRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		c.logger.Error("create webhook: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	c.logger.Debug("create webhook: received request",
+		zap.String("url", request.Url), zap.Strings("event_types", request.EventTypes))
+	response, err := c.webhookService.Create(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("create webhook: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("create webhook: success", zap.Int64("id", response.Id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindById(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find webhook by id: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find webhook by id: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	response, err := c.webhookService.FindById(IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("find webhook by id: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find webhook by id: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) FindAll(ctx *fiber.Ctx) error {
+	c.logger.Debug("find all webhooks: received request")
+	responses, err := c.webhookService.FindAll()
+	if err != nil {
+		c.logger.Error("find all webhooks: service error", zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find all webhooks: success", zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) UpdateWebhook(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("update webhook: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("update webhook: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request UpdateRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("update webhook: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.webhookService.Update(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("update webhook: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("update webhook: success", zap.Int64("id", id))
+	return common.OkResponse(ctx, response)
+}
+
+func (c *Controller) DeleteWebhook(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("delete webhook: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("delete webhook: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	err = c.webhookService.Delete(ctx.UserContext(), IdRequest{Id: id})
+	if err != nil {
+		c.logger.Error("delete webhook: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("delete webhook: success", zap.Int64("id", id))
+	return common.OkResponse[any](ctx, nil)
+}
+
+// FindDeliveries фильтры из query: status и limit
+func (c *Controller) FindDeliveries(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("find webhook deliveries: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("find webhook deliveries: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	request := DeliveriesRequest{Id: id, Status: ctx.Query("status")}
+	if raw := ctx.Query("limit"); raw != "" {
+		if request.Limit, err = strconv.Atoi(raw); err != nil {
+			c.logger.Error("find webhook deliveries: invalid limit parameter", zap.String("limit", raw), zap.Error(err))
+			return common.ErrResponse(ctx, fiber.StatusBadRequest, fmt.Sprintf("invalid limit %q", raw))
+		}
+	}
+	responses, err := c.webhookService.FindDeliveries(request)
+	if err != nil {
+		c.logger.Error("find webhook deliveries: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("find webhook deliveries: success", zap.Int64("id", id), zap.Int("count", len(responses)))
+	return common.OkResponse(ctx, responses)
+}
+
+func (c *Controller) Replay(ctx *fiber.Ctx) error {
+	idStr := ctx.Params("id")
+	c.logger.Debug("replay webhook: received id", zap.String("id", idStr))
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error("replay webhook: invalid id parameter", zap.String("id", idStr), zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, "invalid id parameter")
+	}
+	var request ReplayRequest
+	if err = ctx.BodyParser(&request); err != nil {
+		c.logger.Error("replay webhook: failed to parse request body", zap.Error(err))
+		return common.ErrResponse(ctx, fiber.StatusBadRequest, err.Error())
+	}
+	request.Id = id
+	response, err := c.webhookService.Replay(ctx.UserContext(), request)
+	if err != nil {
+		c.logger.Error("replay webhook: service error", zap.Int64("id", id), zap.Error(err))
+		return common.ErrResponse(ctx, resolveHttpStatusCode(err), err.Error())
+	}
+	c.logger.Debug("replay webhook: success", zap.Int64("id", id), zap.Int64("queued", response.Queued))
+	return common.OkResponse(ctx, response)
+}
+
+func resolveHttpStatusCode(err error) int {
+	switch {
+	case errors.As(err, &common.RequestValidationError{}):
+		return fiber.StatusBadRequest
+	case errors.As(err, &common.NotFoundError{}):
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
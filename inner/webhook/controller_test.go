@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"idm/inner/web/webtest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (svc *MockService) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(SecretResponse), args.Error(1)
+}
+
+func (svc *MockService) FindById(request IdRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) FindAll() ([]Response, error) {
+	args := svc.Called()
+	return args.Get(0).([]Response), args.Error(1)
+}
+
+func (svc *MockService) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	args := svc.Called(request)
+	return args.Get(0).(Response), args.Error(1)
+}
+
+func (svc *MockService) Delete(ctx context.Context, request IdRequest) error {
+	args := svc.Called(request)
+	return args.Error(0)
+}
+
+func (svc *MockService) FindDeliveries(request DeliveriesRequest) ([]DeliveryResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).([]DeliveryResponse), args.Error(1)
+}
+
+func (svc *MockService) Replay(ctx context.Context, request ReplayRequest) (ReplayResponse, error) {
+	args := svc.Called(request)
+	return args.Get(0).(ReplayResponse), args.Error(1)
+}
+
+func TestControllerCreateWebhook(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return subscription with secret", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		request := CreateRequest{Url: "https://hooks.example.com/idm", EventTypes: []string{"employee.created"}}
+		svc.On("Create", request).Return(SecretResponse{
+			Response: Response{Id: 3, Url: request.Url, EventTypes: request.EventTypes, Active: true},
+			Secret:   "whsec_secret",
+		}, nil)
+
+		body := `{"url":"https://hooks.example.com/idm","event_types":["employee.created"]}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[SecretResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(3), responseBody.Data.Id)
+		a.Equal("whsec_secret", responseBody.Data.Secret)
+	})
+
+	t.Run("should return bad request for unknown event type", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Create", mock.Anything).
+			Return(SecretResponse{}, common.RequestValidationError{Message: `unknown event type "employee.fired"`})
+
+		body := `{"url":"https://hooks.example.com/idm","event_types":["employee.fired"]}`
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerUpdateWebhook(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should take id from path", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		request := UpdateRequest{Id: 3, Url: "https://hooks.example.com/v2", EventTypes: []string{}, Active: false}
+		svc.On("Update", request).Return(Response{Id: 3, Url: request.Url}, nil)
+
+		body := `{"id":99,"url":"https://hooks.example.com/v2","event_types":[],"active":false}`
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/webhooks/3", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("should return not found for missing subscription", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Update", mock.Anything).Return(Response{}, common.NotFoundError{Message: "webhook with id 3 not found"})
+
+		req := httptest.NewRequest(fiber.MethodPut, "/api/v1/webhooks/3", strings.NewReader(`{"url":"https://hooks.example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestControllerFindDeliveries(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should pass status and limit from query", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("FindDeliveries", DeliveriesRequest{Id: 3, Status: StatusDead, Limit: 10}).
+			Return([]DeliveryResponse{{Id: 8, Status: StatusDead}}, nil)
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/webhooks/3/deliveries?status=dead&limit=10", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[[]DeliveryResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Len(responseBody.Data, 1)
+		a.Equal(int64(8), responseBody.Data[0].Id)
+	})
+
+	t.Run("should return bad request for invalid limit", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodGet, "/api/v1/webhooks/3/deliveries?limit=many", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "FindDeliveries", mock.Anything))
+	})
+}
+
+func TestControllerReplay(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should replay events since moment", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+		svc.On("Replay", ReplayRequest{Id: 3, From: &from}).Return(ReplayResponse{Queued: 12}, nil)
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/webhooks/3/replay", strings.NewReader(`{"from":"2025-09-01T00:00:00Z"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+
+		bytesData, err := io.ReadAll(resp.Body)
+		a.Nil(err)
+		var responseBody common.Response[ReplayResponse]
+		a.Nil(json.Unmarshal(bytesData, &responseBody))
+		a.Equal(int64(12), responseBody.Data.Queued)
+	})
+
+	t.Run("should return bad request without moment", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		svc.On("Replay", ReplayRequest{Id: 3}).
+			Return(ReplayResponse{}, common.RequestValidationError{Message: "from is required"})
+
+		req := httptest.NewRequest(fiber.MethodPost, "/api/v1/webhooks/3/replay", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestControllerDeleteWebhook(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should return bad request for invalid id", func(t *testing.T) {
+		server := webtest.NewServer()
+		svc := new(MockService)
+		logger := &common.Logger{Logger: zap.NewNop()}
+		controller := NewController(server, svc, logger)
+		controller.RegisterRoutes()
+
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/v1/webhooks/abc", nil)
+		resp, err := server.App.Test(req)
+		a.Nil(err)
+		a.Equal(http.StatusBadRequest, resp.StatusCode)
+		a.True(svc.AssertNotCalled(t, "Delete", mock.Anything))
+	})
+}
@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Idm-Event"
+	HeaderEventId   = "X-Idm-Event-Id"
+	HeaderDelivery  = "X-Idm-Delivery"
+	HeaderTimestamp = "X-Idm-Timestamp"
+	HeaderSignature = "X-Idm-Signature"
+)
+
+// Параметры выборки диспетчером
+const (
+	fanoutLimit = 500
+	claimLimit  = 100
+	// claimLease на сколько откладывается взятая доставка: после сбоя диспетчера она вернётся в работу
+	claimLease = 5 * time.Minute
+	// maxErrorBody сколько байт ответа подписчика сохранить в ошибке доставки
+	maxErrorBody = 512
+)
+
+type DispatcherRepo interface {
+	Fanout(now time.Time, limit int) (int64, error)
+	Claim(now, leaseUntil time.Time, limit int) ([]PendingDelivery, error)
+	Complete(delivery DeliveryEntity) error
+}
+
+// Sign подпись тела запроса: "sha256=" и HMAC-SHA256 от "timestamp.body" на секрете подписки в hex.
+// Метка времени входит в подпись, чтобы подписчик мог отвергать повторно отправленные старые запросы
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher рассылает события outbox подписчикам. Порядок доставки не гарантируется:
+// подписчик упорядочивает события по id и не должен полагаться на то, что каждое придёт один раз
+type Dispatcher struct {
+	repo   DispatcherRepo
+	client *http.Client
+	policy common.RetryPolicy
+	logger *common.Logger
+}
+
+func NewDispatcher(repo DispatcherRepo, client *http.Client, policy common.RetryPolicy, logger *common.Logger) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: client,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// DispatchOnce разослать новые события и выполнить доставки, которые пора выполнять на момент now;
+// возвращает число выполненных попыток доставки
+func (d *Dispatcher) DispatchOnce(ctx context.Context, now time.Time) (int, error) {
+	for {
+		events, err := d.repo.Fanout(now, fanoutLimit)
+		if err != nil {
+			return 0, fmt.Errorf("error dispatching outbox events: %w", err)
+		}
+		if events < fanoutLimit {
+			break
+		}
+	}
+	attempted := 0
+	for {
+		claimed, err := d.repo.Claim(now, now.Add(claimLease), claimLimit)
+		if err != nil {
+			return attempted, fmt.Errorf("error claiming webhook deliveries: %w", err)
+		}
+		var errs []error
+		for _, pending := range claimed {
+			result := d.deliver(ctx, pending, now)
+			attempted++
+			if err = d.repo.Complete(result); err != nil {
+				errs = append(errs, fmt.Errorf("error saving webhook delivery %d: %w", pending.Id, err))
+			}
+		}
+		if len(errs) > 0 || len(claimed) < claimLimit {
+			return attempted, errors.Join(errs...)
+		}
+	}
+}
+
+// deliver отправить событие подписчику; ответ 2xx - доставлено, иначе повтор с паузой,
+// а после последней попытки доставка становится dead
+func (d *Dispatcher) deliver(ctx context.Context, pending PendingDelivery, now time.Time) DeliveryEntity {
+	result := DeliveryEntity{Id: pending.Id, Attempts: pending.Attempts + 1, Status: StatusDelivered, NextAttemptAt: now}
+	status, err := d.post(ctx, pending, now)
+	if status != 0 {
+		result.ResponseStatus = &status
+	}
+	if err == nil {
+		result.DeliveredAt = &now
+		return result
+	}
+	message := err.Error()
+	result.LastError = &message
+	if result.Attempts >= d.policy.MaxAttempts {
+		result.Status = StatusDead
+		d.logger.Error("webhook: delivery is dead",
+			zap.Int64("id", pending.Id), zap.Int64("subscription_id", pending.SubscriptionId),
+			zap.Int64("event_id", pending.EventId), zap.Error(err))
+		return result
+	}
+	result.Status = StatusPending
+	result.NextAttemptAt = now.Add(d.policy.Delay(result.Attempts))
+	d.logger.Warn("webhook: delivery will be retried",
+		zap.Int64("id", pending.Id), zap.Int64("subscription_id", pending.SubscriptionId),
+		zap.Int("attempts", result.Attempts), zap.Time("next_attempt_at", result.NextAttemptAt), zap.Error(err))
+	return result
+}
+
+func (d *Dispatcher) post(ctx context.Context, pending PendingDelivery, now time.Time) (int, error) {
+	body, err := json.Marshal(pending.envelope())
+	if err != nil {
+		return 0, fmt.Errorf("error serializing event %d: %w", pending.EventId, err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, pending.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, pending.EventType)
+	request.Header.Set(HeaderEventId, strconv.FormatInt(pending.EventId, 10))
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(pending.Id, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(pending.Secret, timestamp, body))
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return response.StatusCode, nil
+	}
+	excerpt, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	return response.StatusCode, fmt.Errorf("unexpected response status %d: %s", response.StatusCode, bytes.TrimSpace(excerpt))
+}
+
+// Run рассылать события сразу и затем каждые interval, пока не отменён ctx
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		attempted, err := d.DispatchOnce(ctx, time.Now())
+		if err != nil {
+			d.logger.Error("webhook: dispatching failed", zap.Error(err))
+		}
+		if attempted > 0 {
+			d.logger.Info("webhook: deliveries attempted", zap.Int("count", attempted))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"idm/inner/common"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type MockDispatcherRepo struct {
+	mock.Mock
+	completed []DeliveryEntity
+}
+
+func (m *MockDispatcherRepo) Fanout(now time.Time, limit int) (int64, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDispatcherRepo) Claim(now, leaseUntil time.Time, limit int) ([]PendingDelivery, error) {
+	args := m.Called(now, leaseUntil, limit)
+	return args.Get(0).([]PendingDelivery), args.Error(1)
+}
+
+func (m *MockDispatcherRepo) Complete(delivery DeliveryEntity) error {
+	m.completed = append(m.completed, delivery)
+	args := m.Called(delivery.Id)
+	return args.Error(0)
+}
+
+func pendingDelivery(id int64, url string, attempts int) PendingDelivery {
+	return PendingDelivery{
+		Id:             id,
+		SubscriptionId: 1,
+		Attempts:       attempts,
+		Url:            url,
+		Secret:         "whsec_test-secret",
+		EventId:        42,
+		EventType:      "employee.created",
+		EntityId:       7,
+		Actor:          "alice",
+		Data:           []byte(`{"before":null,"after":{"name":"Alice"}}`),
+		OccurredAt:     time.Date(2025, 9, 10, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func newDispatcher(repo DispatcherRepo) *Dispatcher {
+	policy := common.RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour}
+	return NewDispatcher(repo, http.DefaultClient, policy, &common.Logger{Logger: zap.NewNop()})
+}
+
+func TestSign(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should sign timestamp and body with secret", func(t *testing.T) {
+		signature := Sign("secret", 1700000000, []byte(`{"id":1}`))
+		a.Equal("sha256=", signature[:7])
+		a.Len(signature, 7+64)
+		a.Equal(signature, Sign("secret", 1700000000, []byte(`{"id":1}`)))
+		a.NotEqual(signature, Sign("other", 1700000000, []byte(`{"id":1}`)))
+		a.NotEqual(signature, Sign("secret", 1700000001, []byte(`{"id":1}`)))
+		a.NotEqual(signature, Sign("secret", 1700000000, []byte(`{"id":2}`)))
+	})
+}
+
+func TestDispatcherDispatchOnce(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+
+	t.Run("should deliver signed event and mark delivery delivered", func(t *testing.T) {
+		var headers http.Header
+		var body []byte
+		subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header.Clone()
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer subscriber.Close()
+		repo := new(MockDispatcherRepo)
+		dispatcher := newDispatcher(repo)
+
+		repo.On("Fanout", now, fanoutLimit).Return(int64(1), nil)
+		repo.On("Claim", now, now.Add(claimLease), claimLimit).Return([]PendingDelivery{pendingDelivery(5, subscriber.URL, 0)}, nil)
+		repo.On("Complete", int64(5)).Return(nil)
+
+		attempted, err := dispatcher.DispatchOnce(context.Background(), now)
+		a.NoError(err)
+		a.Equal(1, attempted)
+
+		a.Equal("employee.created", headers.Get(HeaderEvent))
+		a.Equal("42", headers.Get(HeaderEventId))
+		a.Equal("5", headers.Get(HeaderDelivery))
+		timestamp, err := strconv.ParseInt(headers.Get(HeaderTimestamp), 10, 64)
+		a.NoError(err)
+		a.Equal(now.Unix(), timestamp)
+		a.Equal(Sign("whsec_test-secret", timestamp, body), headers.Get(HeaderSignature))
+
+		var envelope Envelope
+		a.NoError(json.Unmarshal(body, &envelope))
+		a.Equal(int64(42), envelope.Id)
+		a.Equal("employee.created", envelope.Type)
+		a.Equal(int64(7), envelope.EntityId)
+		a.Equal("alice", envelope.Actor)
+		a.JSONEq(`{"before":null,"after":{"name":"Alice"}}`, string(envelope.Data))
+
+		a.Len(repo.completed, 1)
+		a.Equal(StatusDelivered, repo.completed[0].Status)
+		a.Equal(1, repo.completed[0].Attempts)
+		a.Equal(http.StatusNoContent, *repo.completed[0].ResponseStatus)
+		a.NotNil(repo.completed[0].DeliveredAt)
+		a.Nil(repo.completed[0].LastError)
+	})
+
+	t.Run("should schedule retry with backoff after failed delivery", func(t *testing.T) {
+		subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("maintenance"))
+		}))
+		defer subscriber.Close()
+		repo := new(MockDispatcherRepo)
+		dispatcher := newDispatcher(repo)
+
+		repo.On("Fanout", now, fanoutLimit).Return(int64(0), nil)
+		repo.On("Claim", now, now.Add(claimLease), claimLimit).Return([]PendingDelivery{pendingDelivery(5, subscriber.URL, 1)}, nil)
+		repo.On("Complete", int64(5)).Return(nil)
+
+		_, err := dispatcher.DispatchOnce(context.Background(), now)
+		a.NoError(err)
+		a.Len(repo.completed, 1)
+		result := repo.completed[0]
+		a.Equal(StatusPending, result.Status)
+		a.Equal(2, result.Attempts)
+		a.Equal(now.Add(2*time.Minute), result.NextAttemptAt)
+		a.Equal(http.StatusServiceUnavailable, *result.ResponseStatus)
+		a.Contains(*result.LastError, "maintenance")
+		a.Nil(result.DeliveredAt)
+	})
+
+	t.Run("should dead-letter delivery after last attempt", func(t *testing.T) {
+		repo := new(MockDispatcherRepo)
+		dispatcher := newDispatcher(repo)
+
+		repo.On("Fanout", now, fanoutLimit).Return(int64(0), nil)
+		repo.On("Claim", now, now.Add(claimLease), claimLimit).
+			Return([]PendingDelivery{pendingDelivery(5, "http://127.0.0.1:1/unreachable", 2)}, nil)
+		repo.On("Complete", int64(5)).Return(nil)
+
+		_, err := dispatcher.DispatchOnce(context.Background(), now)
+		a.NoError(err)
+		a.Len(repo.completed, 1)
+		a.Equal(StatusDead, repo.completed[0].Status)
+		a.Equal(3, repo.completed[0].Attempts)
+		a.Nil(repo.completed[0].ResponseStatus)
+		a.NotNil(repo.completed[0].LastError)
+	})
+
+	t.Run("should keep fanning out while events remain", func(t *testing.T) {
+		repo := new(MockDispatcherRepo)
+		dispatcher := newDispatcher(repo)
+
+		repo.On("Fanout", now, fanoutLimit).Return(int64(fanoutLimit), nil).Once()
+		repo.On("Fanout", now, fanoutLimit).Return(int64(3), nil).Once()
+		repo.On("Claim", now, now.Add(claimLease), claimLimit).Return([]PendingDelivery{}, nil)
+
+		attempted, err := dispatcher.DispatchOnce(context.Background(), now)
+		a.NoError(err)
+		a.Equal(0, attempted)
+		repo.AssertNumberOfCalls(t, "Fanout", 2)
+	})
+
+	t.Run("should return error if fan-out failed", func(t *testing.T) {
+		repo := new(MockDispatcherRepo)
+		dispatcher := newDispatcher(repo)
+
+		repo.On("Fanout", now, fanoutLimit).Return(int64(0), errors.New("database is down"))
+
+		_, err := dispatcher.DispatchOnce(context.Background(), now)
+		a.ErrorContains(err, "database is down")
+		a.True(repo.AssertNotCalled(t, "Claim", mock.Anything, mock.Anything, mock.Anything))
+	})
+}
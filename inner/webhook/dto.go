@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Состояния доставки
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// EventTypes события, на которые можно подписаться
+var EventTypes = []string{
+	"employee.created", "employee.updated", "employee.deleted", "employee.restored",
+	"employee.hired", "employee.suspended", "employee.resumed", "employee.terminated", "employee.rehired",
+	"role.created", "role.updated", "role.deleted", "role.restored", "role.child_added", "role.child_removed",
+	"assignment.created", "assignment.updated", "assignment.deleted",
+}
+
+// EventEntity событие в outbox
+type EventEntity struct {
+	Id           int64      `db:"id"`
+	EventType    string     `db:"event_type"`
+	EntityId     int64      `db:"entity_id"`
+	Actor        string     `db:"actor"`
+	Data         []byte     `db:"data"`
+	CreatedAt    time.Time  `db:"created_at"`
+	DispatchedAt *time.Time `db:"dispatched_at"`
+}
+
+// Envelope тело запроса, которое получает подписчик
+type Envelope struct {
+	Id         int64           `json:"id"`
+	Type       string          `json:"type"`
+	EntityId   int64           `json:"entity_id"`
+	Actor      string          `json:"actor"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type Entity struct {
+	Id         int64          `db:"id"`
+	Url        string         `db:"url"`
+	Secret     string         `db:"secret"`
+	EventTypes pq.StringArray `db:"event_types"`
+	Active     bool           `db:"active"`
+	CreatedAt  time.Time      `db:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at"`
+}
+
+func (e *Entity) toResponse() Response {
+	return Response{
+		Id:         e.Id,
+		Url:        e.Url,
+		EventTypes: append([]string{}, e.EventTypes...),
+		Active:     e.Active,
+		CreatedAt:  e.CreatedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}
+}
+
+// auditSnapshot состояние подписки в журнале аудита; секрет в журнал не попадает
+type auditSnapshot struct {
+	Id         int64    `json:"id"`
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+}
+
+func (e *Entity) auditSnapshot() auditSnapshot {
+	return auditSnapshot{Id: e.Id, Url: e.Url, EventTypes: e.EventTypes, Active: e.Active}
+}
+
+type Response struct {
+	Id         int64     `json:"id"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SecretResponse подписка вместе с секретом подписи; секрет возвращается только при создании
+type SecretResponse struct {
+	Response
+	Secret string `json:"secret"`
+}
+
+type DeliveryEntity struct {
+	Id             int64      `db:"id"`
+	SubscriptionId int64      `db:"subscription_id"`
+	EventId        int64      `db:"event_id"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	ResponseStatus *int       `db:"response_status"`
+	LastError      *string    `db:"last_error"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+	// EventType заполняется при выборке доставок вместе с событием
+	EventType string `db:"event_type"`
+}
+
+func (e *DeliveryEntity) toResponse() DeliveryResponse {
+	response := DeliveryResponse{
+		Id:             e.Id,
+		SubscriptionId: e.SubscriptionId,
+		EventId:        e.EventId,
+		EventType:      e.EventType,
+		Status:         e.Status,
+		Attempts:       e.Attempts,
+		ResponseStatus: e.ResponseStatus,
+		LastError:      e.LastError,
+		DeliveredAt:    e.DeliveredAt,
+		CreatedAt:      e.CreatedAt,
+	}
+	if e.Status == StatusPending {
+		response.NextAttemptAt = &e.NextAttemptAt
+	}
+	return response
+}
+
+type DeliveryResponse struct {
+	Id             int64      `json:"id"`
+	SubscriptionId int64      `json:"subscription_id"`
+	EventId        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ReplayResponse сколько доставок поставлено в очередь повтором
+type ReplayResponse struct {
+	Queued int64 `json:"queued"`
+}
+
+// PendingDelivery доставка, взятая диспетчером, вместе с адресом подписки и событием
+type PendingDelivery struct {
+	Id             int64     `db:"id"`
+	SubscriptionId int64     `db:"subscription_id"`
+	Attempts       int       `db:"attempts"`
+	Url            string    `db:"url"`
+	Secret         string    `db:"secret"`
+	EventId        int64     `db:"event_id"`
+	EventType      string    `db:"event_type"`
+	EntityId       int64     `db:"entity_id"`
+	Actor          string    `db:"actor"`
+	Data           []byte    `db:"data"`
+	OccurredAt     time.Time `db:"occurred_at"`
+}
+
+func (d *PendingDelivery) envelope() Envelope {
+	return Envelope{
+		Id:         d.EventId,
+		Type:       d.EventType,
+		EntityId:   d.EntityId,
+		Actor:      d.Actor,
+		OccurredAt: d.OccurredAt,
+		Data:       d.Data,
+	}
+}
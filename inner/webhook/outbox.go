@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+)
+
+// anonymousActor автор события, если изменение сделано без аутентификации; как в журнале аудита
+const anonymousActor = "anonymous"
+
+// eventEntities типы сущностей журнала аудита, изменения которых публикуются как события
+var eventEntities = map[string]bool{"employee": true, "role": true, "assignment": true}
+
+// eventVerbs окончание имени события по действию журнала аудита; add_child и remove_child - действия иерархии ролей,
+// hire, suspend, resume, terminate и rehire - переходы жизненного цикла сотрудника
+var eventVerbs = map[string]string{
+	audit.ActionCreate:  "created",
+	audit.ActionUpdate:  "updated",
+	audit.ActionDelete:  "deleted",
+	audit.ActionRestore: "restored",
+	"add_child":         "child_added",
+	"remove_child":      "child_removed",
+	"hire":              "hired",
+	"suspend":           "suspended",
+	"resume":            "resumed",
+	"terminate":         "terminated",
+	"rehire":            "rehired",
+}
+
+// Auditor журнал аудита, в который Outbox передаёт события дальше
+type Auditor interface {
+	RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error
+}
+
+type OutboxRepo interface {
+	AppendTx(tx *sqlx.Tx, e EventEntity) error
+}
+
+// Outbox записывает событие в журнал аудита и публикует его в outbox в той же транзакции:
+// подписчики узнают только о зафиксированных изменениях и не пропустят ни одного
+type Outbox struct {
+	next Auditor
+	repo OutboxRepo
+}
+
+func NewOutbox(next Auditor, repo OutboxRepo) *Outbox {
+	return &Outbox{
+		next: next,
+		repo: repo,
+	}
+}
+
+func (o *Outbox) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	if err := o.next.RecordTx(ctx, tx, event); err != nil {
+		return err
+	}
+	if !eventEntities[event.EntityType] {
+		return nil
+	}
+	data, err := json.Marshal(struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}{event.Before, event.After})
+	if err != nil {
+		return fmt.Errorf("error serializing event of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	actor := common.ActorFrom(ctx)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	err = o.repo.AppendTx(tx, EventEntity{
+		EventType: eventType(event.EntityType, event.Action),
+		EntityId:  event.EntityId,
+		Actor:     actor,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing event of %s %d: %w", event.EntityType, event.EntityId, err)
+	}
+	return nil
+}
+
+// eventType имя события, например "employee.created"; неизвестное действие используется как есть
+func eventType(entityType, action string) string {
+	if verb, ok := eventVerbs[action]; ok {
+		return entityType + "." + verb
+	}
+	return entityType + "." + action
+}
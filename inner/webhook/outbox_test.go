@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"testing"
+)
+
+// StubAuditor запоминает события журнала аудита вместо записи в базу
+type StubAuditor struct {
+	events []audit.Event
+	err    error
+}
+
+func (a *StubAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	a.events = append(a.events, event)
+	return a.err
+}
+
+type MockOutboxRepo struct {
+	mock.Mock
+	appended []EventEntity
+}
+
+func (m *MockOutboxRepo) AppendTx(tx *sqlx.Tx, e EventEntity) error {
+	m.appended = append(m.appended, e)
+	args := m.Called(e.EventType)
+	return args.Error(0)
+}
+
+func TestOutboxRecordTx(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should publish event with actor and state before and after change", func(t *testing.T) {
+		next := new(StubAuditor)
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(next, repo)
+
+		repo.On("AppendTx", "employee.updated").Return(nil)
+		ctx := common.WithActor(context.Background(), "alice")
+		err := outbox.RecordTx(ctx, nil, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: "employee",
+			EntityId:   7,
+			Before:     map[string]any{"name": "Bob"},
+			After:      map[string]any{"name": "Robert"},
+		})
+		a.NoError(err)
+		a.Len(next.events, 1)
+		a.Len(repo.appended, 1)
+		event := repo.appended[0]
+		a.Equal(int64(7), event.EntityId)
+		a.Equal("alice", event.Actor)
+		a.JSONEq(`{"before":{"name":"Bob"},"after":{"name":"Robert"}}`, string(event.Data))
+	})
+
+	t.Run("should name events of role hierarchy and default actor", func(t *testing.T) {
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(new(StubAuditor), repo)
+
+		repo.On("AppendTx", mock.Anything).Return(nil)
+		for _, action := range []string{audit.ActionCreate, audit.ActionRestore, "add_child", "remove_child"} {
+			a.NoError(outbox.RecordTx(context.Background(), nil, audit.Event{Action: action, EntityType: "role", EntityId: 1}))
+		}
+		var names []string
+		for _, event := range repo.appended {
+			names = append(names, event.EventType)
+			a.Equal(anonymousActor, event.Actor)
+		}
+		a.Equal([]string{"role.created", "role.restored", "role.child_added", "role.child_removed"}, names)
+		for _, name := range names {
+			a.Contains(EventTypes, name)
+		}
+		var data map[string]json.RawMessage
+		a.NoError(json.Unmarshal(repo.appended[0].Data, &data))
+		a.Equal("null", string(data["before"]))
+	})
+
+	t.Run("should name events of employee lifecycle", func(t *testing.T) {
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(new(StubAuditor), repo)
+
+		repo.On("AppendTx", mock.Anything).Return(nil)
+		for _, action := range []string{"hire", "suspend", "resume", "terminate", "rehire"} {
+			a.NoError(outbox.RecordTx(context.Background(), nil, audit.Event{Action: action, EntityType: "employee", EntityId: 1}))
+		}
+		var names []string
+		for _, event := range repo.appended {
+			names = append(names, event.EventType)
+		}
+		a.Equal([]string{"employee.hired", "employee.suspended", "employee.resumed", "employee.terminated", "employee.rehired"}, names)
+		for _, name := range names {
+			a.Contains(EventTypes, name)
+		}
+	})
+
+	t.Run("should not publish changes of other entities", func(t *testing.T) {
+		next := new(StubAuditor)
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(next, repo)
+
+		err := outbox.RecordTx(context.Background(), nil, audit.Event{Action: audit.ActionCreate, EntityType: "api_key", EntityId: 1})
+		a.NoError(err)
+		a.Len(next.events, 1)
+		a.True(repo.AssertNotCalled(t, "AppendTx", mock.Anything))
+	})
+
+	t.Run("should not publish event if audit record failed", func(t *testing.T) {
+		next := &StubAuditor{err: errors.New("audit is down")}
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(next, repo)
+
+		err := outbox.RecordTx(context.Background(), nil, audit.Event{Action: audit.ActionDelete, EntityType: "assignment", EntityId: 1})
+		a.ErrorContains(err, "audit is down")
+		a.True(repo.AssertNotCalled(t, "AppendTx", mock.Anything))
+	})
+
+	t.Run("should fail mutation if event was not published", func(t *testing.T) {
+		repo := new(MockOutboxRepo)
+		outbox := NewOutbox(new(StubAuditor), repo)
+
+		repo.On("AppendTx", "assignment.created").Return(errors.New("outbox is down"))
+		err := outbox.RecordTx(context.Background(), nil, audit.Event{Action: audit.ActionCreate, EntityType: "assignment", EntityId: 3})
+		a.ErrorContains(err, "outbox is down")
+	})
+}
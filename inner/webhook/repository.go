@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"idm/inner/database"
+	"time"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(database *sqlx.DB) *Repository {
+	return &Repository{db: database}
+}
+
+func (r *Repository) BeginTransaction() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+// AppendTx записать событие в outbox в транзакции изменения, которое его породило
+func (r *Repository) AppendTx(tx *sqlx.Tx, e EventEntity) error {
+	query := "insert into outbox_event (event_type, entity_id, actor, data) values ($1, $2, $3, $4)"
+	_, err := tx.Exec(query, e.EventType, e.EntityId, e.Actor, e.Data)
+	return err
+}
+
+// Fanout создать доставки для не разосланных событий (не больше limit) всем активным подпискам на них
+// и отметить события разосланными; возвращает число разосланных событий.
+// Параллельные диспетчеры пропускают события, заблокированные друг другом.
+func (r *Repository) Fanout(now time.Time, limit int) (events int64, err error) {
+	err = database.InTransaction(r.BeginTransaction, "dispatching outbox events", func(tx *sqlx.Tx) error {
+		var ids []int64
+		query := `update outbox_event set dispatched_at = $1
+			where id in (
+				select id from outbox_event where dispatched_at is null
+				order by id
+				limit $2
+				for update skip locked
+			)
+			returning id`
+		if err := tx.Select(&ids, query, now, limit); err != nil || len(ids) == 0 {
+			return err
+		}
+		events = int64(len(ids))
+		_, err := tx.Exec(`insert into webhook_delivery (subscription_id, event_id, next_attempt_at)
+			select s.id, e.id, $2 from outbox_event e
+			join webhook_subscription s on s.active and (cardinality(s.event_types) = 0 or e.event_type = any(s.event_types))
+			where e.id = any($1)
+			order by e.id, s.id`, pq.Array(ids), now)
+		return err
+	})
+	return events, err
+}
+
+// Claim взять до limit доставок активным подпискам, которые пора выполнять, и отложить их до leaseUntil:
+// если диспетчер упадёт, доставки вернутся в работу после этого момента
+func (r *Repository) Claim(now, leaseUntil time.Time, limit int) (claimed []PendingDelivery, err error) {
+	query := `with claimed as (
+			update webhook_delivery set next_attempt_at = $2
+			where id in (
+				select d.id from webhook_delivery d
+				join webhook_subscription s on s.id = d.subscription_id and s.active
+				where d.status = 'pending' and d.next_attempt_at <= $1
+				order by d.id
+				limit $3
+				for update of d skip locked
+			)
+			returning id, subscription_id, event_id, attempts
+		)
+		select c.id, c.subscription_id, c.attempts, s.url, s.secret,
+			e.id as event_id, e.event_type, e.entity_id, e.actor, e.data, e.created_at as occurred_at
+		from claimed c
+		join webhook_subscription s on s.id = c.subscription_id
+		join outbox_event e on e.id = c.event_id
+		order by c.id`
+	err = r.db.Select(&claimed, query, now, leaseUntil, limit)
+	return claimed, err
+}
+
+// Complete сохранить результат попытки доставки
+func (r *Repository) Complete(d DeliveryEntity) error {
+	query := `update webhook_delivery
+		set status = $2, attempts = $3, next_attempt_at = $4, response_status = $5, last_error = $6, delivered_at = $7
+		where id = $1`
+	_, err := r.db.Exec(query, d.Id, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseStatus, d.LastError, d.DeliveredAt)
+	return err
+}
+
+func (r *Repository) SaveTx(tx *sqlx.Tx, e Entity) (saved Entity, err error) {
+	query := "insert into webhook_subscription (url, secret, event_types) values ($1, $2, $3) returning *"
+	err = tx.Get(&saved, query, e.Url, e.Secret, e.EventTypes)
+	return saved, err
+}
+
+func (r *Repository) FindById(id int64) (subscription Entity, err error) {
+	query := "select * from webhook_subscription where id = $1"
+	err = r.db.Get(&subscription, query, id)
+	return subscription, err
+}
+
+// FindByIdForUpdateTx найти подписку и заблокировать её до конца транзакции
+func (r *Repository) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (subscription Entity, err error) {
+	query := "select * from webhook_subscription where id = $1 for update"
+	err = tx.Get(&subscription, query, id)
+	return subscription, err
+}
+
+func (r *Repository) FindAll() (subscriptions []Entity, err error) {
+	query := "select * from webhook_subscription order by id"
+	err = r.db.Select(&subscriptions, query)
+	return subscriptions, err
+}
+
+func (r *Repository) UpdateTx(tx *sqlx.Tx, e Entity) (updated Entity, err error) {
+	query := `update webhook_subscription set url = $2, event_types = $3, active = $4
+		where id = $1 returning *`
+	err = tx.Get(&updated, query, e.Id, e.Url, e.EventTypes, e.Active)
+	return updated, err
+}
+
+func (r *Repository) DeleteTx(tx *sqlx.Tx, id int64) (deleted Entity, err error) {
+	query := "delete from webhook_subscription where id = $1 returning *"
+	err = tx.Get(&deleted, query, id)
+	return deleted, err
+}
+
+// FindDeliveries последние limit доставок подписки вместе с видом события; пустой status - в любом состоянии
+func (r *Repository) FindDeliveries(subscriptionId int64, status string, limit int) (deliveries []DeliveryEntity, err error) {
+	query := `select d.*, e.event_type from webhook_delivery d
+		join outbox_event e on e.id = d.event_id
+		where d.subscription_id = $1 and ($2::text = '' or d.status = $2)
+		order by d.id desc
+		limit $3`
+	err = r.db.Select(&deliveries, query, subscriptionId, status, limit)
+	return deliveries, err
+}
+
+// RequeueDeadTx вернуть в очередь доставки подписки, исчерпавшие попытки, с новым запасом попыток
+func (r *Repository) RequeueDeadTx(tx *sqlx.Tx, subscriptionId int64, at time.Time) (int64, error) {
+	query := `update webhook_delivery set status = 'pending', attempts = 0, next_attempt_at = $2
+		where subscription_id = $1 and status = 'dead'`
+	result, err := tx.Exec(query, subscriptionId, at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ReplayTx заново поставить подписке доставки разосланных событий начиная с from, на которые она подписана
+func (r *Repository) ReplayTx(tx *sqlx.Tx, subscription Entity, from, at time.Time) (int64, error) {
+	query := `insert into webhook_delivery (subscription_id, event_id, next_attempt_at)
+		select $1, e.id, $4 from outbox_event e
+		where e.created_at >= $2 and e.dispatched_at is not null and (cardinality($3::text[]) = 0 or e.event_type = any($3))
+		order by e.id`
+	result, err := tx.Exec(query, subscription.Id, from, subscription.EventTypes, at)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,38 @@
+package webhook
+
+import "time"
+
+// CreateRequest подписаться на события; без Secret секрет подписи генерируется.
+// Пустой EventTypes - подписка на все события
+type CreateRequest struct {
+	Url        string   `json:"url" validate:"required,http_url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"dive,required"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=255"`
+}
+
+// UpdateRequest заменить адрес, события и активность подписки; секрет не меняется
+type UpdateRequest struct {
+	Id         int64    `json:"id" validate:"required,gt=0"`
+	Url        string   `json:"url" validate:"required,http_url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"dive,required"`
+	Active     bool     `json:"active"`
+}
+
+type IdRequest struct {
+	Id int64 `json:"id" validate:"required,gt=0"`
+}
+
+// DeliveriesRequest доставки подписки, от новых к старым; Status фильтрует по состоянию
+type DeliveriesRequest struct {
+	Id     int64  `json:"id" validate:"required,gt=0"`
+	Status string `json:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `json:"limit" validate:"min=0,max=500"`
+}
+
+// ReplayRequest повторить доставку событий подписке: с DeadOnly - вернуть в очередь доставки,
+// исчерпавшие попытки; иначе заново отправить все подходящие под подписку события с момента From
+type ReplayRequest struct {
+	Id       int64      `json:"id" validate:"required,gt=0"`
+	From     *time.Time `json:"from" validate:"required_without=DeadOnly"`
+	DeadOnly bool       `json:"dead_only"`
+}
@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"slices"
+	"time"
+)
+
+// auditEntityType тип сущности в журнале аудита
+const auditEntityType = "webhook"
+
+// auditActionReplay повтор доставок в журнале аудита
+const auditActionReplay = "replay"
+
+// Параметры подписок
+const (
+	secretBytes          = 32
+	secretPrefix         = "whsec_"
+	defaultDeliveryLimit = 50
+)
+
+type Service struct {
+	repo      Repo
+	auditor   Auditor
+	validator Validator
+}
+
+type Repo interface {
+	BeginTransaction() (*sqlx.Tx, error)
+	SaveTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	FindById(id int64) (Entity, error)
+	FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindAll() ([]Entity, error)
+	UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error)
+	DeleteTx(tx *sqlx.Tx, id int64) (Entity, error)
+	FindDeliveries(subscriptionId int64, status string, limit int) ([]DeliveryEntity, error)
+	RequeueDeadTx(tx *sqlx.Tx, subscriptionId int64, at time.Time) (int64, error)
+	ReplayTx(tx *sqlx.Tx, subscription Entity, from, at time.Time) (int64, error)
+}
+
+type Validator interface {
+	Validate(request any) error
+}
+
+func NewService(repo Repo, auditor Auditor, validator Validator) *Service {
+	return &Service{
+		repo:      repo,
+		auditor:   auditor,
+		validator: validator,
+	}
+}
+
+// Create подписаться на события; секрет подписи есть только в ответе
+func (svc *Service) Create(ctx context.Context, request CreateRequest) (SecretResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return SecretResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = checkEventTypes(request.EventTypes); err != nil {
+		return SecretResponse{}, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		if secret, err = common.NewSecret(secretBytes); err != nil {
+			return SecretResponse{}, fmt.Errorf("error generating webhook secret: %w", err)
+		}
+		secret = secretPrefix + secret
+	}
+	var saved Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "creating webhook", func(tx *sqlx.Tx) error {
+		saved, err = svc.repo.SaveTx(tx, Entity{Url: request.Url, Secret: secret, EventTypes: nonNil(request.EventTypes)})
+		if err != nil {
+			return fmt.Errorf("error saving webhook for %s: %w", request.Url, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: auditEntityType,
+			EntityId:   saved.Id,
+			After:      saved.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return SecretResponse{}, err
+	}
+	return SecretResponse{Response: saved.toResponse(), Secret: secret}, nil
+}
+
+func (svc *Service) FindById(request IdRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	entity, err := svc.repo.FindById(request.Id)
+	if err != nil {
+		return Response{}, common.NotFoundError{
+			Message: fmt.Sprintf("error finding webhook with id %d: %v", request.Id, err),
+		}
+	}
+	return entity.toResponse(), nil
+}
+
+func (svc *Service) FindAll() ([]Response, error) {
+	entities, err := svc.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving all webhooks: %w", err)
+	}
+	responses := make([]Response, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, entity.toResponse())
+	}
+	return responses, nil
+}
+
+// Update заменить адрес, события и активность подписки. Доставки неактивной подписке не выполняются,
+// но и не теряются: после включения они будут отправлены
+func (svc *Service) Update(ctx context.Context, request UpdateRequest) (Response, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return Response{}, common.RequestValidationError{Message: err.Error()}
+	}
+	if err = checkEventTypes(request.EventTypes); err != nil {
+		return Response{}, err
+	}
+	var updated Entity
+	err = database.InTransaction(svc.repo.BeginTransaction, "updating webhook", func(tx *sqlx.Tx) error {
+		before, err := svc.findForUpdateTx(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		updated, err = svc.repo.UpdateTx(tx, Entity{
+			Id:         request.Id,
+			Url:        request.Url,
+			EventTypes: nonNil(request.EventTypes),
+			Active:     request.Active,
+		})
+		if err != nil {
+			return fmt.Errorf("error updating webhook with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionUpdate,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			Before:     before.auditSnapshot(),
+			After:      updated.auditSnapshot(),
+		})
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return updated.toResponse(), nil
+}
+
+// Delete удалить подписку вместе с её доставками
+func (svc *Service) Delete(ctx context.Context, request IdRequest) error {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return common.RequestValidationError{Message: err.Error()}
+	}
+	return database.InTransaction(svc.repo.BeginTransaction, "deleting webhook", func(tx *sqlx.Tx) error {
+		deleted, err := svc.repo.DeleteTx(tx, request.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: fmt.Sprintf("webhook with id %d not found", request.Id)}
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting webhook with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionDelete,
+			EntityType: auditEntityType,
+			EntityId:   deleted.Id,
+			Before:     deleted.auditSnapshot(),
+		})
+	})
+}
+
+// FindDeliveries последние доставки подписки; с фильтром dead - очередь недоставленных событий
+func (svc *Service) FindDeliveries(request DeliveriesRequest) ([]DeliveryResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return nil, common.RequestValidationError{Message: err.Error()}
+	}
+	if _, err = svc.repo.FindById(request.Id); err != nil {
+		return nil, common.NotFoundError{
+			Message: fmt.Sprintf("error finding webhook with id %d: %v", request.Id, err),
+		}
+	}
+	if request.Limit == 0 {
+		request.Limit = defaultDeliveryLimit
+	}
+	deliveries, err := svc.repo.FindDeliveries(request.Id, request.Status, request.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving deliveries of webhook %d: %w", request.Id, err)
+	}
+	responses := make([]DeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		responses = append(responses, delivery.toResponse())
+	}
+	return responses, nil
+}
+
+// Replay повторить доставку событий подписке: вернуть в очередь dead-доставки или отправить заново
+// все события с момента From. Повтор записывается в журнал аудита
+func (svc *Service) Replay(ctx context.Context, request ReplayRequest) (ReplayResponse, error) {
+	err := svc.validator.Validate(request)
+	if err != nil {
+		return ReplayResponse{}, common.RequestValidationError{Message: err.Error()}
+	}
+	var queued int64
+	err = database.InTransaction(svc.repo.BeginTransaction, "replaying webhook", func(tx *sqlx.Tx) error {
+		subscription, err := svc.findForUpdateTx(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if request.DeadOnly {
+			queued, err = svc.repo.RequeueDeadTx(tx, request.Id, now)
+		} else {
+			queued, err = svc.repo.ReplayTx(tx, subscription, *request.From, now)
+		}
+		if err != nil {
+			return fmt.Errorf("error replaying webhook with id %d: %w", request.Id, err)
+		}
+		return svc.auditor.RecordTx(ctx, tx, audit.Event{
+			Action:     auditActionReplay,
+			EntityType: auditEntityType,
+			EntityId:   request.Id,
+			After: map[string]any{
+				"from":      request.From,
+				"dead_only": request.DeadOnly,
+				"queued":    queued,
+			},
+		})
+	})
+	if err != nil {
+		return ReplayResponse{}, err
+	}
+	return ReplayResponse{Queued: queued}, nil
+}
+
+func (svc *Service) findForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	entity, err := svc.repo.FindByIdForUpdateTx(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, common.NotFoundError{Message: fmt.Sprintf("webhook with id %d not found", id)}
+	}
+	if err != nil {
+		return Entity{}, fmt.Errorf("error finding webhook with id %d: %w", id, err)
+	}
+	return entity, nil
+}
+
+// checkEventTypes подписаться можно только на известные события
+func checkEventTypes(eventTypes []string) error {
+	for _, eventType := range eventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return common.RequestValidationError{Message: fmt.Sprintf("unknown event type %q", eventType)}
+		}
+	}
+	return nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
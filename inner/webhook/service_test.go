@@ -0,0 +1,318 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/validator"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockRepo struct {
+	mock.Mock
+}
+
+func (m *MockRepo) BeginTransaction() (*sqlx.Tx, error) {
+	args := m.Called()
+	return args.Get(0).(*sqlx.Tx), args.Error(1)
+}
+
+// SaveTx результат можно задать функцией, чтобы вернуть подписку с тем секретом, который сгенерировал сервис
+func (m *MockRepo) SaveTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	if fn, ok := args.Get(0).(func(*sqlx.Tx, Entity) (Entity, error)); ok {
+		return fn(tx, e)
+	}
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindById(id int64) (Entity, error) {
+	args := m.Called(id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindByIdForUpdateTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindAll() ([]Entity, error) {
+	args := m.Called()
+	return args.Get(0).([]Entity), args.Error(1)
+}
+
+func (m *MockRepo) UpdateTx(tx *sqlx.Tx, e Entity) (Entity, error) {
+	args := m.Called(tx, e)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) DeleteTx(tx *sqlx.Tx, id int64) (Entity, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
+func (m *MockRepo) FindDeliveries(subscriptionId int64, status string, limit int) ([]DeliveryEntity, error) {
+	args := m.Called(subscriptionId, status, limit)
+	return args.Get(0).([]DeliveryEntity), args.Error(1)
+}
+
+func (m *MockRepo) RequeueDeadTx(tx *sqlx.Tx, subscriptionId int64, at time.Time) (int64, error) {
+	args := m.Called(tx, subscriptionId)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ReplayTx(tx *sqlx.Tx, subscription Entity, from, at time.Time) (int64, error) {
+	args := m.Called(tx, subscription.Id, from)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func subscription(id int64) Entity {
+	return Entity{
+		Id:         id,
+		Url:        "https://hooks.example.com/idm",
+		Secret:     "whsec_0123456789abcdef",
+		EventTypes: []string{"employee.created"},
+		Active:     true,
+	}
+}
+
+func TestServiceCreate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should generate secret and return it once", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		var stored Entity
+		repo.On("SaveTx", noTx, mock.Anything).Return(func(tx *sqlx.Tx, e Entity) (Entity, error) {
+			e.Id = 3
+			stored = e
+			return e, nil
+		})
+
+		response, err := svc.Create(context.Background(), CreateRequest{
+			Url:        "https://hooks.example.com/idm",
+			EventTypes: []string{"employee.created", "role.child_added"},
+		})
+		a.NoError(err)
+		a.Equal(int64(3), response.Id)
+		a.True(strings.HasPrefix(response.Secret, secretPrefix))
+		a.Equal(stored.Secret, response.Secret)
+		a.Equal([]string{"employee.created", "role.child_added"}, response.EventTypes)
+
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionCreate, auditor.events[0].Action)
+		a.Equal(auditEntityType, auditor.events[0].EntityType)
+		// в журнал попадает снимок подписки без секрета
+		a.Equal(stored.auditSnapshot(), auditor.events[0].After)
+	})
+
+	t.Run("should keep secret from request and subscribe to all events by default", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		expected := Entity{Url: "https://hooks.example.com/idm", Secret: "my-own-secret-value", EventTypes: []string{}}
+		repo.On("SaveTx", noTx, expected).Return(Entity{Id: 4, Url: expected.Url, Active: true}, nil)
+
+		response, err := svc.Create(context.Background(), CreateRequest{Url: expected.Url, Secret: expected.Secret})
+		a.NoError(err)
+		a.Equal("my-own-secret-value", response.Secret)
+		a.Empty(response.EventTypes)
+	})
+
+	t.Run("should reject unknown event type and invalid url", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		_, err := svc.Create(context.Background(), CreateRequest{
+			Url:        "https://hooks.example.com/idm",
+			EventTypes: []string{"employee.fired"},
+		})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.ErrorContains(err, "employee.fired")
+
+		_, err = svc.Create(context.Background(), CreateRequest{Url: "hooks.example.com"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		_, err = svc.Create(context.Background(), CreateRequest{Url: "https://hooks.example.com/idm", Secret: "short"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.True(repo.AssertNotCalled(t, "SaveTx", mock.Anything, mock.Anything))
+	})
+}
+
+func TestServiceUpdate(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should update subscription and record change", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		before := subscription(3)
+		after := before
+		after.Active = false
+		after.EventTypes = []string{"employee.created", "employee.deleted"}
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(before, nil)
+		repo.On("UpdateTx", noTx, Entity{Id: 3, Url: before.Url, EventTypes: after.EventTypes}).Return(after, nil)
+
+		response, err := svc.Update(context.Background(), UpdateRequest{
+			Id:         3,
+			Url:        before.Url,
+			EventTypes: []string{"employee.created", "employee.deleted"},
+		})
+		a.NoError(err)
+		a.False(response.Active)
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionUpdate, auditor.events[0].Action)
+		a.Equal(before.auditSnapshot(), auditor.events[0].Before)
+		a.Equal(after.auditSnapshot(), auditor.events[0].After)
+	})
+
+	t.Run("should return not found for missing subscription", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.Update(context.Background(), UpdateRequest{Id: 3, Url: "https://hooks.example.com/idm"})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+
+	t.Run("should delete subscription and record it", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(3)).Return(subscription(3), nil)
+
+		a.NoError(svc.Delete(context.Background(), IdRequest{Id: 3}))
+		a.Len(auditor.events, 1)
+		a.Equal(audit.ActionDelete, auditor.events[0].Action)
+		a.Equal(int64(3), auditor.events[0].EntityId)
+	})
+
+	t.Run("should return not found for missing subscription", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("DeleteTx", noTx, int64(3)).Return(Entity{}, sql.ErrNoRows)
+
+		a.ErrorAs(svc.Delete(context.Background(), IdRequest{Id: 3}), &common.NotFoundError{})
+	})
+}
+
+func TestServiceFindDeliveries(t *testing.T) {
+	a := assert.New(t)
+
+	t.Run("should use default limit and show next attempt of pending deliveries", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		next := time.Now().Add(time.Minute)
+		repo.On("FindById", int64(3)).Return(subscription(3), nil)
+		repo.On("FindDeliveries", int64(3), "", defaultDeliveryLimit).Return([]DeliveryEntity{
+			{Id: 2, Status: StatusPending, NextAttemptAt: next, EventType: "employee.created"},
+			{Id: 1, Status: StatusDead, NextAttemptAt: next, EventType: "employee.created"},
+		}, nil)
+
+		responses, err := svc.FindDeliveries(DeliveriesRequest{Id: 3})
+		a.NoError(err)
+		a.Len(responses, 2)
+		a.Equal(next, *responses[0].NextAttemptAt)
+		a.Nil(responses[1].NextAttemptAt)
+	})
+
+	t.Run("should reject unknown status", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(StubAuditor), validator.New())
+
+		_, err := svc.FindDeliveries(DeliveriesRequest{Id: 3, Status: "lost"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should return not found for missing subscription", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("FindById", int64(3)).Return(Entity{}, sql.ErrNoRows)
+
+		_, err := svc.FindDeliveries(DeliveriesRequest{Id: 3, Status: StatusDead})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+}
+
+func TestServiceReplay(t *testing.T) {
+	a := assert.New(t)
+	var noTx *sqlx.Tx
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should queue events since moment and record replay", func(t *testing.T) {
+		repo := new(MockRepo)
+		auditor := new(StubAuditor)
+		svc := NewService(repo, auditor, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(subscription(3), nil)
+		repo.On("ReplayTx", noTx, int64(3), from).Return(int64(12), nil)
+
+		response, err := svc.Replay(context.Background(), ReplayRequest{Id: 3, From: &from})
+		a.NoError(err)
+		a.Equal(int64(12), response.Queued)
+		a.Len(auditor.events, 1)
+		a.Equal(auditActionReplay, auditor.events[0].Action)
+		a.True(repo.AssertNotCalled(t, "RequeueDeadTx", mock.Anything, mock.Anything))
+	})
+
+	t.Run("should requeue only dead deliveries", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, new(StubAuditor), validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(subscription(3), nil)
+		repo.On("RequeueDeadTx", noTx, int64(3)).Return(int64(2), nil)
+
+		response, err := svc.Replay(context.Background(), ReplayRequest{Id: 3, DeadOnly: true})
+		a.NoError(err)
+		a.Equal(int64(2), response.Queued)
+		a.True(repo.AssertNotCalled(t, "ReplayTx", mock.Anything, mock.Anything, mock.Anything))
+	})
+
+	t.Run("should require moment unless dead deliveries are requeued", func(t *testing.T) {
+		svc := NewService(new(MockRepo), new(StubAuditor), validator.New())
+
+		_, err := svc.Replay(context.Background(), ReplayRequest{Id: 3})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("should roll back replay if audit failed", func(t *testing.T) {
+		repo := new(MockRepo)
+		svc := NewService(repo, &StubAuditor{err: errors.New("audit is down")}, validator.New())
+
+		repo.On("BeginTransaction").Return(noTx, nil)
+		repo.On("FindByIdForUpdateTx", noTx, int64(3)).Return(subscription(3), nil)
+		repo.On("RequeueDeadTx", noTx, int64(3)).Return(int64(2), nil)
+
+		_, err := svc.Replay(context.Background(), ReplayRequest{Id: 3, DeadOnly: true})
+		a.ErrorContains(err, "audit is down")
+	})
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE employee DROP CONSTRAINT IF EXISTS employee_role_id_fkey;
+ALTER TABLE employee ADD CONSTRAINT employee_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS employee_role_id_idx ON employee(role_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS employee_role_id_idx;
+ALTER TABLE employee DROP CONSTRAINT IF EXISTS employee_role_id_fkey;
+ALTER TABLE employee ADD CONSTRAINT employee_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id);
+-- +goose StatementEnd
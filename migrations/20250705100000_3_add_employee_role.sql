@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE employee_role (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id BIGINT NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT employee_role_valid_period CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX employee_role_employee_id_idx ON employee_role(employee_id, valid_from);
+CREATE INDEX employee_role_role_id_idx ON employee_role(role_id, valid_from);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS employee_role;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- parent_id включает в себя child_id: сотрудник с ролью parent_id получает и роль child_id
+CREATE TABLE role_hierarchy (
+    parent_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    child_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (parent_id, child_id),
+    CONSTRAINT role_hierarchy_not_self CHECK (parent_id <> child_id)
+);
+
+CREATE INDEX role_hierarchy_child_id_idx ON role_hierarchy(child_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS role_hierarchy;
+-- +goose StatementEnd
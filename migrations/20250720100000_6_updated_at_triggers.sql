@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- updated_at используется как версия записи для ETag/If-Match, поэтому обновляется при любом изменении строки.
+-- clock_timestamp вместо now, чтобы два изменения в одной транзакции не получили одинаковую версию.
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER employee_set_updated_at BEFORE UPDATE ON employee
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TRIGGER role_set_updated_at BEFORE UPDATE ON role
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS role_set_updated_at ON role;
+DROP TRIGGER IF EXISTS employee_set_updated_at ON employee;
+DROP FUNCTION IF EXISTS set_updated_at();
+-- +goose StatementEnd
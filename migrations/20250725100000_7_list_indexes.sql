@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Индексы под keyset-пагинацию списков: сортировка по колонке с id как вторым ключом
+CREATE INDEX employee_name_id_idx ON employee (name, id);
+CREATE INDEX employee_created_at_id_idx ON employee (created_at, id);
+CREATE INDEX employee_updated_at_id_idx ON employee (updated_at, id);
+-- text_pattern_ops нужен, чтобы фильтр name LIKE 'prefix%' использовал индекс независимо от collation
+CREATE INDEX employee_name_pattern_idx ON employee (name text_pattern_ops);
+
+CREATE INDEX role_name_id_idx ON role (name, id);
+CREATE INDEX role_created_at_id_idx ON role (created_at, id);
+CREATE INDEX role_updated_at_id_idx ON role (updated_at, id);
+CREATE INDEX role_name_pattern_idx ON role (name text_pattern_ops);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS role_name_pattern_idx;
+DROP INDEX IF EXISTS role_updated_at_id_idx;
+DROP INDEX IF EXISTS role_created_at_id_idx;
+DROP INDEX IF EXISTS role_name_id_idx;
+DROP INDEX IF EXISTS employee_name_pattern_idx;
+DROP INDEX IF EXISTS employee_updated_at_id_idx;
+DROP INDEX IF EXISTS employee_created_at_id_idx;
+DROP INDEX IF EXISTS employee_name_id_idx;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- normalize_name приводит имя к виду для нечёткого поиска: без регистра и диакритики, ё как е.
+-- unaccent объявлена STABLE, поэтому словарь указан явно, а обёртка помечена IMMUTABLE для индекса.
+CREATE OR REPLACE FUNCTION normalize_name(value TEXT) RETURNS TEXT AS $$
+    SELECT replace(lower(public.unaccent('public.unaccent'::regdictionary, value)), 'ё', 'е')
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE;
+
+CREATE INDEX employee_name_trgm_idx ON employee USING gin (normalize_name(name) gin_trgm_ops);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS employee_name_trgm_idx;
+DROP FUNCTION IF EXISTS normalize_name(TEXT);
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE employee ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE role ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Частичные индексы для очистки: удалённых строк обычно мало, и только они нужны purge
+CREATE INDEX employee_deleted_at_idx ON employee (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX role_deleted_at_idx ON role (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS role_deleted_at_idx;
+DROP INDEX IF EXISTS employee_deleted_at_idx;
+ALTER TABLE role DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE employee DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
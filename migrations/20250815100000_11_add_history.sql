@@ -0,0 +1,92 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Таблицы истории: каждая версия строки действовала в интервале [version_from, version_to),
+-- у текущей версии version_to пустой. Колонки повторяют исходную таблицу, поэтому при добавлении
+-- колонки в employee, role или employee_role её нужно добавить и в таблицу истории.
+CREATE TABLE employee_history (
+    id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    role_id BIGINT,
+    deleted_at TIMESTAMPTZ,
+    version_from TIMESTAMPTZ NOT NULL,
+    version_to TIMESTAMPTZ,
+    PRIMARY KEY (id, version_from)
+);
+
+CREATE TABLE role_history (
+    id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ,
+    version_from TIMESTAMPTZ NOT NULL,
+    version_to TIMESTAMPTZ,
+    PRIMARY KEY (id, version_from)
+);
+
+CREATE TABLE employee_role_history (
+    id BIGINT NOT NULL,
+    employee_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    version_from TIMESTAMPTZ NOT NULL,
+    version_to TIMESTAMPTZ,
+    PRIMARY KEY (id, version_from)
+);
+
+CREATE INDEX employee_role_history_employee_id_idx ON employee_role_history (employee_id, version_from);
+
+-- record_history закрывает текущую версию строки и добавляет новую. Строка переносится через jsonb
+-- по именам колонок, поэтому функция одна для всех таблиц <table> с историей в <table>_history.
+CREATE OR REPLACE FUNCTION record_history() RETURNS TRIGGER AS $$
+DECLARE
+    changed_at TIMESTAMPTZ := clock_timestamp();
+    history TEXT := TG_TABLE_NAME || '_history';
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        EXECUTE format('UPDATE %I SET version_to = $1 WHERE id = $2 AND version_to IS NULL', history)
+            USING changed_at, OLD.id;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        EXECUTE format('INSERT INTO %1$I SELECT * FROM jsonb_populate_record(NULL::%1$I, $1)', history)
+            USING to_jsonb(NEW) || jsonb_build_object('version_from', changed_at);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER employee_record_history AFTER INSERT OR UPDATE OR DELETE ON employee
+    FOR EACH ROW EXECUTE FUNCTION record_history();
+
+CREATE TRIGGER role_record_history AFTER INSERT OR UPDATE OR DELETE ON role
+    FOR EACH ROW EXECUTE FUNCTION record_history();
+
+CREATE TRIGGER employee_role_record_history AFTER INSERT OR UPDATE OR DELETE ON employee_role
+    FOR EACH ROW EXECUTE FUNCTION record_history();
+
+-- Прежние изменения не сохранились: считаем, что текущее состояние действует с момента создания
+INSERT INTO employee_history (id, name, created_at, updated_at, role_id, deleted_at, version_from)
+SELECT id, name, created_at, updated_at, role_id, deleted_at, created_at FROM employee;
+
+INSERT INTO role_history (id, name, created_at, updated_at, deleted_at, version_from)
+SELECT id, name, created_at, updated_at, deleted_at, created_at FROM role;
+
+INSERT INTO employee_role_history (id, employee_id, role_id, valid_from, valid_to, created_at, version_from)
+SELECT id, employee_id, role_id, valid_from, valid_to, created_at, created_at FROM employee_role;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS employee_role_record_history ON employee_role;
+DROP TRIGGER IF EXISTS role_record_history ON role;
+DROP TRIGGER IF EXISTS employee_record_history ON employee;
+DROP FUNCTION IF EXISTS record_history();
+DROP TABLE IF EXISTS employee_role_history;
+DROP TABLE IF EXISTS role_history;
+DROP TABLE IF EXISTS employee_history;
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+-- login связывает сотрудника с субъектом токена доступа (claim sub)
+ALTER TABLE employee ADD COLUMN login TEXT;
+ALTER TABLE employee_history ADD COLUMN login TEXT;
+
+CREATE UNIQUE INDEX employee_login_idx ON employee (login) WHERE deleted_at IS NULL;
+
+-- Разрешения, которые проверяет сам API; выдаются ролям так же, как любые другие
+INSERT INTO permission (name, description) VALUES
+    ('employees:read', 'View employees'),
+    ('employees:create', 'Create employees'),
+    ('employees:update', 'Update and restore employees'),
+    ('employees:delete', 'Delete employees'),
+    ('roles:read', 'View roles'),
+    ('roles:create', 'Create roles'),
+    ('roles:update', 'Update, nest and restore roles'),
+    ('roles:delete', 'Delete roles'),
+    ('assignments:read', 'View role assignments'),
+    ('assignments:create', 'Assign roles to employees'),
+    ('assignments:delete', 'Revoke roles from employees'),
+    ('permissions:read', 'View permissions'),
+    ('permissions:create', 'Create permissions'),
+    ('permissions:delete', 'Delete permissions'),
+    ('permissions:grant', 'Grant and revoke role permissions'),
+    ('audit:read', 'View audit log')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN (
+    'employees:read',
+    'employees:create',
+    'employees:update',
+    'employees:delete',
+    'roles:read',
+    'roles:create',
+    'roles:update',
+    'roles:delete',
+    'assignments:read',
+    'assignments:create',
+    'assignments:delete',
+    'permissions:read',
+    'permissions:create',
+    'permissions:delete',
+    'permissions:grant',
+    'audit:read'
+);
+DROP INDEX IF EXISTS employee_login_idx;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS login;
+ALTER TABLE employee DROP COLUMN IF EXISTS login;
+-- +goose StatementEnd
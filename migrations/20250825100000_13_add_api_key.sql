@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ключи доступа сервисных учётных записей; сам секрет не хранится, только его хеш с солью
+CREATE TABLE api_key (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    prefix TEXT NOT NULL UNIQUE,
+    account TEXT NOT NULL,
+    secret_hash BYTEA NOT NULL,
+    salt BYTEA NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    rotated_from BIGINT REFERENCES api_key(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX api_key_account_idx ON api_key (account);
+
+INSERT INTO permission (name, description) VALUES
+    ('apikeys:read', 'View API keys'),
+    ('apikeys:manage', 'Create, rotate and revoke API keys')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('apikeys:read', 'apikeys:manage');
+DROP TABLE IF EXISTS api_key;
+-- +goose StatementEnd
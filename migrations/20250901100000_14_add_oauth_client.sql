@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Клиенты OAuth2, которые получают токены IDM по client_credentials; секрет хранится только в виде хеша
+CREATE TABLE oauth_client (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    client_id TEXT NOT NULL UNIQUE,
+    secret_hash BYTEA NOT NULL,
+    salt BYTEA NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO permission (name, description) VALUES
+    ('clients:read', 'View OAuth2 clients'),
+    ('clients:manage', 'Register and delete OAuth2 clients')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('clients:read', 'clients:manage');
+DROP TABLE IF EXISTS oauth_client;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Очередь операций выгрузки во внешние системы: по одной операции на каждый коннектор.
+-- scheduled_at - когда операцию можно выполнять впервые (начало или конец срока назначения),
+-- next_attempt_at - когда её возьмёт обработчик: при повторе после ошибки сдвигается с нарастающей паузой
+CREATE TABLE provisioning_operation (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    connector TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    employee_id BIGINT NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+    role_id BIGINT,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    scheduled_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX provisioning_operation_due_idx ON provisioning_operation (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX provisioning_operation_employee_idx ON provisioning_operation (connector, employee_id, id);
+
+CREATE TRIGGER provisioning_operation_set_updated_at BEFORE UPDATE ON provisioning_operation
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO permission (name, description) VALUES
+    ('provisioning:read', 'View provisioning operations'),
+    ('provisioning:manage', 'Retry failed provisioning operations')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('provisioning:read', 'provisioning:manage');
+DROP TABLE IF EXISTS provisioning_operation;
+-- +goose StatementEnd
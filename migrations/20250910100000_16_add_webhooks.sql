@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Исходящие события (transactional outbox): пишутся в транзакции изменения, затем рассылаются подписчикам.
+-- dispatched_at - когда для события созданы доставки; события хранятся и после рассылки, чтобы их можно было повторить
+CREATE TABLE outbox_event (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    event_type TEXT NOT NULL,
+    entity_id BIGINT NOT NULL,
+    actor TEXT NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_event_undispatched_idx ON outbox_event (id) WHERE dispatched_at IS NULL;
+
+-- Подписки на события; секрет нужен для подписи каждой доставки, поэтому хранится как есть.
+-- Пустой event_types означает подписку на все события
+CREATE TABLE webhook_subscription (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER webhook_subscription_set_updated_at BEFORE UPDATE ON webhook_subscription
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Доставка события одному подписчику; dead - попытки исчерпаны, доставку можно повторить вручную
+CREATE TABLE webhook_delivery (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscription(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES outbox_event(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    response_status INT,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_delivery_due_idx ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_delivery_subscription_idx ON webhook_delivery (subscription_id, id);
+
+CREATE TRIGGER webhook_delivery_set_updated_at BEFORE UPDATE ON webhook_delivery
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO permission (name, description) VALUES
+    ('webhooks:read', 'View webhook subscriptions and deliveries'),
+    ('webhooks:manage', 'Manage webhook subscriptions and replay events')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('webhooks:read', 'webhooks:manage');
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook_subscription;
+DROP TABLE IF EXISTS outbox_event;
+-- +goose StatementEnd
@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Запросы доступа: сотрудник просит роль, назначенные согласующие одобряют или отклоняют запрос.
+-- Роль назначается, когда запрос одобрили все согласующие; assignment_id - созданное назначение.
+-- decided_by и decided_at - кто и когда перевёл запрос в конечное состояние
+CREATE TABLE access_request (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id BIGINT NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    justification TEXT NOT NULL,
+    valid_from TIMESTAMPTZ,
+    valid_to TIMESTAMPTZ,
+    status TEXT NOT NULL DEFAULT 'pending',
+    requested_by TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    decided_by TEXT,
+    decided_at TIMESTAMPTZ,
+    assignment_id BIGINT REFERENCES employee_role(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- на одну роль у сотрудника может быть только один нерассмотренный запрос
+CREATE UNIQUE INDEX access_request_pending_uidx ON access_request (employee_id, role_id) WHERE status = 'pending';
+CREATE INDEX access_request_expires_idx ON access_request (expires_at) WHERE status = 'pending';
+
+CREATE TRIGGER access_request_set_updated_at BEFORE UPDATE ON access_request
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Согласующие запроса и их решения; decision пуст, пока согласующий не ответил
+CREATE TABLE access_request_approver (
+    request_id BIGINT NOT NULL REFERENCES access_request(id) ON DELETE CASCADE,
+    approver TEXT NOT NULL,
+    decision TEXT,
+    comment TEXT,
+    decided_at TIMESTAMPTZ,
+    PRIMARY KEY (request_id, approver)
+);
+
+CREATE INDEX access_request_approver_idx ON access_request_approver (approver, request_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('access_requests:create', 'Request roles and cancel own requests'),
+    ('access_requests:read', 'View access requests'),
+    ('access_requests:approve', 'Approve or reject access requests assigned to you'),
+    ('access_requests:manage', 'Reassign approvers of access requests')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN (
+    'access_requests:create',
+    'access_requests:read',
+    'access_requests:approve',
+    'access_requests:manage'
+);
+DROP TABLE IF EXISTS access_request_approver;
+DROP TABLE IF EXISTS access_request;
+-- +goose StatementEnd
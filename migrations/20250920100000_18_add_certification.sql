@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Кампании пересмотра доступа: при создании действующие назначения ролей из role_ids копируются
+-- в позиции кампании, рецензенты подтверждают или отзывают каждую позицию до срока deadline.
+-- auto_revoke - отзывать ли после срока роли, по которым рецензент не принял решения
+CREATE TABLE certification_campaign (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    role_ids BIGINT[] NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',
+    deadline TIMESTAMPTZ NOT NULL,
+    auto_revoke BOOLEAN NOT NULL,
+    created_by TEXT NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX certification_campaign_deadline_idx ON certification_campaign (deadline) WHERE status = 'active';
+
+CREATE TRIGGER certification_campaign_set_updated_at BEFORE UPDATE ON certification_campaign
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Позиции кампании: снимок назначения на момент создания кампании. Ссылок на сотрудника, роль
+-- и назначение нет намеренно - позиция остаётся свидетельством пересмотра, даже если их удалят.
+-- decision пуст, пока рецензент не принял решения
+CREATE TABLE certification_item (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    campaign_id BIGINT NOT NULL REFERENCES certification_campaign(id) ON DELETE CASCADE,
+    assignment_id BIGINT NOT NULL,
+    employee_id BIGINT NOT NULL,
+    employee_name TEXT NOT NULL,
+    employee_login TEXT,
+    role_id BIGINT NOT NULL,
+    role_name TEXT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    reviewer TEXT NOT NULL,
+    decision TEXT,
+    comment TEXT,
+    decided_by TEXT,
+    decided_at TIMESTAMPTZ,
+    UNIQUE (campaign_id, assignment_id)
+);
+
+CREATE INDEX certification_item_reviewer_idx ON certification_item (reviewer, campaign_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('certifications:read', 'View certification campaigns, their items and reports'),
+    ('certifications:review', 'Certify or revoke certification items assigned to you'),
+    ('certifications:manage', 'Start certification campaigns and reassign reviewers')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('certifications:read', 'certifications:review', 'certifications:manage');
+DROP TABLE IF EXISTS certification_item;
+DROP TABLE IF EXISTS certification_campaign;
+-- +goose StatementEnd
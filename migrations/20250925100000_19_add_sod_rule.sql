@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Правила разделения обязанностей: пару ролей нельзя держать одновременно, в том числе через иерархию ролей.
+-- Пара хранится упорядоченной (role_a_id < role_b_id), чтобы одно правило не заводилось дважды
+CREATE TABLE sod_rule (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    role_a_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    role_b_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (role_a_id < role_b_id),
+    UNIQUE (role_a_id, role_b_id)
+);
+
+CREATE INDEX sod_rule_role_b_idx ON sod_rule (role_b_id);
+
+CREATE TRIGGER sod_rule_set_updated_at BEFORE UPDATE ON sod_rule
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Назначения, выданные вопреки правилу, с обоснованием; assignment_id обнуляется, если назначение удалено
+CREATE TABLE sod_override (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    rule_id BIGINT NOT NULL REFERENCES sod_rule(id) ON DELETE CASCADE,
+    employee_id BIGINT NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+    assignment_id BIGINT REFERENCES employee_role(id) ON DELETE SET NULL,
+    justification TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sod_override_rule_employee_idx ON sod_override (rule_id, employee_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('sod:read', 'View segregation-of-duties rules and violations'),
+    ('sod:manage', 'Manage segregation-of-duties rules'),
+    ('sod:override', 'Grant roles in conflict with segregation-of-duties rules with a justification')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('sod:read', 'sod:manage', 'sod:override');
+DROP TABLE IF EXISTS sod_override;
+DROP TABLE IF EXISTS sod_rule;
+-- +goose StatementEnd
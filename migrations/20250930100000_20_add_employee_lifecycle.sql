@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Состояние сотрудника в жизненном цикле: pre_hire, active, suspended, terminated.
+-- Сотрудники, заведённые до появления состояний, считаются работающими
+ALTER TABLE employee ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE employee_history ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+
+-- Переходы между состояниями с датой вступления в силу. Переход с датой в будущем ждёт в статусе pending,
+-- пока его не применит фоновая проверка; у сотрудника не больше одного ожидающего перехода
+CREATE TABLE employee_transition (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    employee_id BIGINT NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+    transition TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_by TEXT NOT NULL,
+    applied_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX employee_transition_employee_idx ON employee_transition (employee_id, id);
+CREATE UNIQUE INDEX employee_transition_pending_idx ON employee_transition (employee_id) WHERE status = 'pending';
+CREATE INDEX employee_transition_due_idx ON employee_transition (effective_at) WHERE status = 'pending';
+
+CREATE TRIGGER employee_transition_set_updated_at BEFORE UPDATE ON employee_transition
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO permission (name, description) VALUES
+    ('employees:lifecycle', 'Hire, suspend, resume, terminate and rehire employees')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'employees:lifecycle';
+DROP TABLE IF EXISTS employee_transition;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS status;
+ALTER TABLE employee DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Дерево подразделений: у корневых parent_id пуст. Имена уникальны среди подразделений одного родителя.
+-- Подразделение с дочерними подразделениями или работающими в нём сотрудниками удалить нельзя
+CREATE TABLE org_unit (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    parent_id BIGINT REFERENCES org_unit(id) ON DELETE RESTRICT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (parent_id <> id)
+);
+
+CREATE UNIQUE INDEX org_unit_name_idx ON org_unit (COALESCE(parent_id, 0), name);
+CREATE INDEX org_unit_parent_idx ON org_unit (parent_id);
+
+CREATE TRIGGER org_unit_set_updated_at BEFORE UPDATE ON org_unit
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Подразделение сотрудника и его непосредственный руководитель
+ALTER TABLE employee ADD COLUMN org_unit_id BIGINT REFERENCES org_unit(id) ON DELETE SET NULL;
+ALTER TABLE employee ADD COLUMN manager_id BIGINT REFERENCES employee(id) ON DELETE SET NULL;
+ALTER TABLE employee ADD CONSTRAINT employee_manager_check CHECK (manager_id <> id);
+ALTER TABLE employee_history ADD COLUMN org_unit_id BIGINT;
+ALTER TABLE employee_history ADD COLUMN manager_id BIGINT;
+
+CREATE INDEX employee_org_unit_idx ON employee (org_unit_id);
+CREATE INDEX employee_manager_idx ON employee (manager_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('org_units:read', 'View organizational units and their members'),
+    ('org_units:manage', 'Create, update and delete organizational units')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('org_units:read', 'org_units:manage');
+DROP INDEX IF EXISTS employee_manager_idx;
+DROP INDEX IF EXISTS employee_org_unit_idx;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS manager_id;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS org_unit_id;
+ALTER TABLE employee DROP CONSTRAINT IF EXISTS employee_manager_check;
+ALTER TABLE employee DROP COLUMN IF EXISTS manager_id;
+ALTER TABLE employee DROP COLUMN IF EXISTS org_unit_id;
+DROP TABLE IF EXISTS org_unit;
+-- +goose StatementEnd
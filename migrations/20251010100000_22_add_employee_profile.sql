@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Анкетные данные сотрудника. Табельный номер уникален среди неудалённых сотрудников, как и логин
+ALTER TABLE employee ADD COLUMN email TEXT;
+ALTER TABLE employee ADD COLUMN title TEXT;
+ALTER TABLE employee ADD COLUMN phone TEXT;
+ALTER TABLE employee ADD COLUMN hire_date DATE;
+ALTER TABLE employee ADD COLUMN employee_number TEXT;
+-- Пользовательские атрибуты: ключи и типы значений задаёт схема employee_attribute
+ALTER TABLE employee ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE employee_history ADD COLUMN email TEXT;
+ALTER TABLE employee_history ADD COLUMN title TEXT;
+ALTER TABLE employee_history ADD COLUMN phone TEXT;
+ALTER TABLE employee_history ADD COLUMN hire_date DATE;
+ALTER TABLE employee_history ADD COLUMN employee_number TEXT;
+ALTER TABLE employee_history ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE UNIQUE INDEX employee_number_idx ON employee (employee_number) WHERE deleted_at IS NULL;
+
+-- Схема пользовательских атрибутов: тип значения string, number, boolean или date
+CREATE TABLE employee_attribute (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    key TEXT NOT NULL UNIQUE,
+    type TEXT NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER employee_attribute_set_updated_at BEFORE UPDATE ON employee_attribute
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO permission (name, description) VALUES
+    ('employee_attributes:read', 'View the schema of custom employee attributes'),
+    ('employee_attributes:manage', 'Define, change and delete custom employee attributes')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('employee_attributes:read', 'employee_attributes:manage');
+DROP TABLE IF EXISTS employee_attribute;
+DROP INDEX IF EXISTS employee_number_idx;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS attributes;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS employee_number;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS hire_date;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS phone;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS title;
+ALTER TABLE employee_history DROP COLUMN IF EXISTS email;
+ALTER TABLE employee DROP COLUMN IF EXISTS attributes;
+ALTER TABLE employee DROP COLUMN IF EXISTS employee_number;
+ALTER TABLE employee DROP COLUMN IF EXISTS hire_date;
+ALTER TABLE employee DROP COLUMN IF EXISTS phone;
+ALTER TABLE employee DROP COLUMN IF EXISTS title;
+ALTER TABLE employee DROP COLUMN IF EXISTS email;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Правила назначения ролей по атрибутам сотрудника: сотрудник, подходящий под все условия включённого
+-- правила, получает роль правила автоматически и теряет её, когда перестаёт подходить.
+-- conditions - массив объектов {"attribute", "operator", "values"}; пустой массив означает всех сотрудников
+CREATE TABLE assignment_rule (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    role_id BIGINT NOT NULL REFERENCES role(id) ON DELETE CASCADE,
+    conditions JSONB NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER assignment_rule_set_updated_at BEFORE UPDATE ON assignment_rule
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Назначение, выданное правилом: вручную его не отозвать. После удаления правила закрытые
+-- назначения остаются в истории без ссылки на него
+ALTER TABLE employee_role ADD COLUMN rule_id BIGINT REFERENCES assignment_rule(id) ON DELETE SET NULL;
+ALTER TABLE employee_role_history ADD COLUMN rule_id BIGINT;
+
+CREATE INDEX employee_role_rule_idx ON employee_role (rule_id, employee_id) WHERE rule_id IS NOT NULL;
+
+INSERT INTO permission (name, description) VALUES
+    ('assignment_rules:read', 'View assignment rules and preview the employees they affect'),
+    ('assignment_rules:manage', 'Create, update, enable, disable and delete assignment rules')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('assignment_rules:read', 'assignment_rules:manage');
+DROP INDEX IF EXISTS employee_role_rule_idx;
+ALTER TABLE employee_role_history DROP COLUMN IF EXISTS rule_id;
+ALTER TABLE employee_role DROP COLUMN IF EXISTS rule_id;
+DROP TABLE IF EXISTS assignment_rule;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Логин связывает субъект запроса с сотрудником и его правами, поэтому его смена требует отдельного разрешения
+INSERT INTO permission (name, description) VALUES
+    ('employees:login', 'Change login of an existing employee')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'employees:login';
+-- +goose StatementEnd
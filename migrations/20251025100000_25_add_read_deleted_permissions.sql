@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Удалённые сотрудники и роли видны в списках (include_deleted) только с отдельным разрешением
+INSERT INTO permission (name, description) VALUES
+    ('employees:read_deleted', 'View deleted employees in the employee list'),
+    ('roles:read_deleted', 'View deleted roles in the role list')
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name IN ('employees:read_deleted', 'roles:read_deleted');
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Кампания может пересматривать назначения только сотрудников подразделения и вложенных в него
+ALTER TABLE certification_campaign ADD COLUMN org_unit_id BIGINT;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE certification_campaign DROP COLUMN IF EXISTS org_unit_id;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Запросы доступа, исключения из правил разделения обязанностей и правила назначения - свидетельства для аудита:
+-- окончательное удаление сотрудника или роли не должно их стирать, поэтому очистка пропускает такие записи,
+-- а прямое удаление завершается ошибкой
+ALTER TABLE access_request DROP CONSTRAINT IF EXISTS access_request_employee_id_fkey;
+ALTER TABLE access_request ADD CONSTRAINT access_request_employee_id_fkey
+    FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE RESTRICT;
+ALTER TABLE access_request DROP CONSTRAINT IF EXISTS access_request_role_id_fkey;
+ALTER TABLE access_request ADD CONSTRAINT access_request_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT;
+
+ALTER TABLE sod_override DROP CONSTRAINT IF EXISTS sod_override_employee_id_fkey;
+ALTER TABLE sod_override ADD CONSTRAINT sod_override_employee_id_fkey
+    FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE RESTRICT;
+ALTER TABLE sod_rule DROP CONSTRAINT IF EXISTS sod_rule_role_a_id_fkey;
+ALTER TABLE sod_rule ADD CONSTRAINT sod_rule_role_a_id_fkey
+    FOREIGN KEY (role_a_id) REFERENCES role(id) ON DELETE RESTRICT;
+ALTER TABLE sod_rule DROP CONSTRAINT IF EXISTS sod_rule_role_b_id_fkey;
+ALTER TABLE sod_rule ADD CONSTRAINT sod_rule_role_b_id_fkey
+    FOREIGN KEY (role_b_id) REFERENCES role(id) ON DELETE RESTRICT;
+
+ALTER TABLE assignment_rule DROP CONSTRAINT IF EXISTS assignment_rule_role_id_fkey;
+ALTER TABLE assignment_rule ADD CONSTRAINT assignment_rule_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE assignment_rule DROP CONSTRAINT IF EXISTS assignment_rule_role_id_fkey;
+ALTER TABLE assignment_rule ADD CONSTRAINT assignment_rule_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE;
+
+ALTER TABLE sod_rule DROP CONSTRAINT IF EXISTS sod_rule_role_b_id_fkey;
+ALTER TABLE sod_rule ADD CONSTRAINT sod_rule_role_b_id_fkey
+    FOREIGN KEY (role_b_id) REFERENCES role(id) ON DELETE CASCADE;
+ALTER TABLE sod_rule DROP CONSTRAINT IF EXISTS sod_rule_role_a_id_fkey;
+ALTER TABLE sod_rule ADD CONSTRAINT sod_rule_role_a_id_fkey
+    FOREIGN KEY (role_a_id) REFERENCES role(id) ON DELETE CASCADE;
+ALTER TABLE sod_override DROP CONSTRAINT IF EXISTS sod_override_employee_id_fkey;
+ALTER TABLE sod_override ADD CONSTRAINT sod_override_employee_id_fkey
+    FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE CASCADE;
+
+ALTER TABLE access_request DROP CONSTRAINT IF EXISTS access_request_role_id_fkey;
+ALTER TABLE access_request ADD CONSTRAINT access_request_role_id_fkey
+    FOREIGN KEY (role_id) REFERENCES role(id) ON DELETE CASCADE;
+ALTER TABLE access_request DROP CONSTRAINT IF EXISTS access_request_employee_id_fkey;
+ALTER TABLE access_request ADD CONSTRAINT access_request_employee_id_fkey
+    FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE CASCADE;
+-- +goose StatementEnd
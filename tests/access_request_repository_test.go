@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/accessrequest"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+func TestAccessRequestRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	svc := accessrequest.NewService(
+		fixture.requests, fixture.employees, fixture.roles, assignments, allowAccess{}, auditService, vld, time.Hour,
+	)
+	as := func(actor string) context.Context {
+		return common.WithActor(context.Background(), actor)
+	}
+
+	t.Run("role is granted after all approvers approve", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("devs")
+
+		created, err := svc.Create(as("manager"), accessrequest.CreateRequest{
+			EmployeeId:    &employeeId,
+			RoleId:        roleId,
+			Justification: "joins the team",
+			Approvers:     []string{"bob", "carol"},
+		})
+		a.NoError(err)
+		a.Equal(accessrequest.StatusPending, created.Status)
+		a.Len(created.Approvers, 2)
+
+		_, err = svc.Create(as("manager"), accessrequest.CreateRequest{
+			EmployeeId: &employeeId, RoleId: roleId, Justification: "again", Approvers: []string{"bob"},
+		})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		_, err = svc.Approve(as("mallory"), accessrequest.DecisionRequest{Id: created.Id})
+		a.ErrorAs(err, &common.ForbiddenError{})
+
+		first, err := svc.Approve(as("bob"), accessrequest.DecisionRequest{Id: created.Id, Comment: "ok"})
+		a.NoError(err)
+		a.Equal(accessrequest.StatusPending, first.Status)
+
+		_, err = svc.Approve(as("bob"), accessrequest.DecisionRequest{Id: created.Id})
+		a.ErrorAs(err, &common.ForbiddenError{})
+
+		final, err := svc.Approve(as("carol"), accessrequest.DecisionRequest{Id: created.Id})
+		a.NoError(err)
+		a.Equal(accessrequest.StatusApproved, final.Status)
+		a.NotNil(final.AssignmentId)
+		a.Equal("carol", *final.DecidedBy)
+
+		effective, err := fixture.assignments.FindEffectiveByEmployeeId(employeeId, time.Now())
+		a.NoError(err)
+		a.Len(effective, 1)
+		a.Equal(*final.AssignmentId, effective[0].Id)
+	})
+
+	t.Run("filters find requests waiting for approver", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		devs := fixture.Role("devs")
+		ops := fixture.Role("ops")
+		for _, roleId := range []int64{devs, ops} {
+			_, err := svc.Create(as("manager"), accessrequest.CreateRequest{
+				EmployeeId: &employeeId, RoleId: roleId, Justification: "joins the team", Approvers: []string{"bob"},
+			})
+			a.NoError(err)
+		}
+		rejected, err := svc.Reject(as("bob"), accessrequest.DecisionRequest{Id: 0})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		a.Empty(rejected.Status)
+
+		page := common.PageRequest{Limit: 10, Sort: "id", Order: "asc"}
+		found, err := fixture.requests.FindPage(accessrequest.ListRequest{PageRequest: page, Approver: "bob"}, nil)
+		a.NoError(err)
+		a.Len(found, 2)
+		total, err := fixture.requests.Count(accessrequest.ListRequest{Approver: "carol"})
+		a.NoError(err)
+		a.Equal(int64(0), total)
+		total, err = fixture.requests.Count(accessrequest.ListRequest{RoleId: &ops, Status: accessrequest.StatusPending})
+		a.NoError(err)
+		a.Equal(int64(1), total)
+	})
+
+	t.Run("overdue requests expire", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("devs")
+		created, err := svc.Create(as("manager"), accessrequest.CreateRequest{
+			EmployeeId: &employeeId, RoleId: roleId, Justification: "joins the team", Approvers: []string{"bob"},
+		})
+		a.NoError(err)
+
+		expired, err := svc.Expire(as("system"), time.Now())
+		a.NoError(err)
+		a.Equal(0, expired)
+		expired, err = svc.Expire(as("system"), time.Now().Add(2*time.Hour))
+		a.NoError(err)
+		a.Equal(1, expired)
+
+		found, err := svc.FindById(accessrequest.IdRequest{Id: created.Id})
+		a.NoError(err)
+		a.Equal(accessrequest.StatusExpired, found.Status)
+		_, err = svc.Approve(as("bob"), accessrequest.DecisionRequest{Id: created.Id})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+}
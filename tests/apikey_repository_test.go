@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/apikey"
+	"idm/inner/database"
+	"testing"
+	"time"
+)
+
+func TestApiKeyRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	newKey := func(prefix string, rotatedFrom *int64) apikey.Entity {
+		tx := fixture.db.MustBegin()
+		saved, err := fixture.apiKeys.SaveTx(tx, apikey.Entity{
+			Prefix:      prefix,
+			Account:     "hr-sync",
+			SecretHash:  []byte("hash"),
+			Salt:        []byte("salt"),
+			Permissions: []string{"employees:read", "employees:create"},
+			ExpiresAt:   time.Now().Add(24 * time.Hour),
+			RotatedFrom: rotatedFrom,
+		})
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		return saved
+	}
+
+	t.Run("save and find api key by prefix", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		saved := newKey("0011223344556677", nil)
+		a.NotZero(saved.Id)
+		a.NotEmpty(saved.CreatedAt)
+
+		got, err := fixture.apiKeys.FindByPrefix("0011223344556677")
+		a.NoError(err)
+		a.Equal(saved.Id, got.Id)
+		a.Equal([]byte("hash"), got.SecretHash)
+		a.Equal([]string{"employees:read", "employees:create"}, []string(got.Permissions))
+		a.Nil(got.RevokedAt)
+	})
+
+	t.Run("shorten old key after rotation", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		old := newKey("0011223344556677", nil)
+		rotated := newKey("8899aabbccddeeff", &old.Id)
+		a.Equal(old.Id, *rotated.RotatedFrom)
+
+		overlapEnd := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+		tx := fixture.db.MustBegin()
+		locked, err := fixture.apiKeys.FindByIdForUpdateTx(tx, old.Id)
+		a.NoError(err)
+		a.Equal(old.Prefix, locked.Prefix)
+		expired, err := fixture.apiKeys.ExpireTx(tx, old.Id, overlapEnd)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		a.True(overlapEnd.Equal(expired.ExpiresAt))
+	})
+
+	t.Run("revoke api key only once", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		saved := newKey("0011223344556677", nil)
+
+		tx := fixture.db.MustBegin()
+		revoked, err := fixture.apiKeys.RevokeTx(tx, saved.Id)
+		a.NoError(err)
+		a.NotNil(revoked.RevokedAt)
+		_, err = fixture.apiKeys.RevokeTx(tx, saved.Id)
+		a.ErrorIs(err, sql.ErrNoRows)
+		a.NoError(tx.Commit())
+
+		all, err := fixture.apiKeys.FindAll()
+		a.NoError(err)
+		a.Len(all, 1)
+	})
+}
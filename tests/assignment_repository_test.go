@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"testing"
+	"time"
+)
+
+func TestAssignmentRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	now := time.Now()
+	past := now.Add(-48 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	t.Run("find effective assignments of employee", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		current := fixture.Role("Accountant")
+		expired := fixture.Role("Intern")
+		future := fixture.Role("Manager")
+		fixture.Assignment(employeeId, current, yesterday, nil)
+		fixture.Assignment(employeeId, expired, past, &yesterday)
+		fixture.Assignment(employeeId, future, tomorrow, nil)
+
+		got, err := fixture.assignments.FindEffectiveByEmployeeId(employeeId, now)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal(current, got[0].RoleId)
+		a.Equal("Accountant", got[0].RoleName)
+		a.Equal("Alice", got[0].EmployeeName)
+
+		all, err := fixture.assignments.FindByEmployeeId(employeeId)
+		a.NoError(err)
+		a.Len(all, 3)
+
+		roles, err := fixture.assignments.FindEffectiveRoles(employeeId, now)
+		a.NoError(err)
+		a.Len(roles, 1)
+		a.Equal("Accountant", roles[0].Name)
+	})
+
+	t.Run("find effective assignments of role", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		roleId := fixture.Role("Accountant")
+		alice := fixture.Employee("Alice")
+		bob := fixture.Employee("Bob")
+		fixture.Assignment(alice, roleId, yesterday, nil)
+		fixture.Assignment(bob, roleId, past, &yesterday)
+
+		got, err := fixture.assignments.FindEffectiveByRoleId(roleId, now)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal(alice, got[0].EmployeeId)
+	})
+
+	t.Run("detect overlapping assignment", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("Accountant")
+		fixture.Assignment(employeeId, roleId, past, &yesterday)
+
+		tx := fixture.db.MustBegin()
+		defer func() { _ = tx.Rollback() }()
+
+		exists, err := fixture.assignments.ExistsOverlappingTx(tx, employeeId, roleId, now, nil)
+		a.NoError(err)
+		a.False(exists)
+
+		exists, err = fixture.assignments.ExistsOverlappingTx(tx, employeeId, roleId, past.Add(time.Hour), &now)
+		a.NoError(err)
+		a.True(exists)
+	})
+
+	t.Run("revoke closes current and removes future assignments", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("Accountant")
+		fixture.Assignment(employeeId, roleId, yesterday, &tomorrow)
+		fixture.Assignment(employeeId, roleId, tomorrow, nil)
+
+		tx := fixture.db.MustBegin()
+		revoked, err := fixture.assignments.RevokeTx(tx, employeeId, roleId, now)
+		a.NoError(err)
+		a.Len(revoked, 2)
+		a.NotNil(revoked[0].ValidTo)
+		a.WithinDuration(tomorrow, *revoked[0].ValidTo, time.Millisecond)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.assignments.FindByEmployeeId(employeeId)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.NotNil(got[0].ValidTo)
+		a.WithinDuration(now, *got[0].ValidTo, time.Millisecond)
+
+		effective, err := fixture.assignments.FindEffectiveByEmployeeId(employeeId, time.Now())
+		a.NoError(err)
+		a.Empty(effective)
+	})
+}
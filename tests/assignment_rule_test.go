@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/orgunit"
+	"idm/inner/validator"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAssignmentRules(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	units := orgunit.NewService(fixture.orgUnits, auditService, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	rules := birthright.NewService(fixture.rules, fixture.roles, fixture.attributes, assignments, auditService, vld)
+	employees := employee.NewService(
+		fixture.employees, fixture.roles, fixture.orgUnits, fixture.attributes, fixture.assignments, assignments, rules,
+		fixture.sodRules, allowAccess{}, auditService, vld,
+	)
+	ctx := common.WithActor(context.Background(), "alice")
+
+	t.Run("rule grants role on enable and follows org unit moves", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		finance, err := units.Create(ctx, orgunit.CreateRequest{Name: "Finance"})
+		a.NoError(err)
+		sales, err := units.Create(ctx, orgunit.CreateRequest{Name: "Sales"})
+		a.NoError(err)
+		reader := fixture.Role("finance-reader")
+		bob := fixture.Employee("Bob")
+		carol := fixture.Employee("Carol")
+		a.NoError(employees.SetOrgUnit(ctx, employee.SetOrgUnitRequest{Id: bob, OrgUnitId: finance.Id}))
+		a.NoError(employees.SetOrgUnit(ctx, employee.SetOrgUnitRequest{Id: carol, OrgUnitId: sales.Id}))
+
+		rule, err := rules.Create(ctx, birthright.CreateRequest{Name: "Finance readers", RoleId: reader, Conditions: []birthright.Condition{
+			{Attribute: birthright.AttributeOrgUnit, Operator: birthright.OperatorEquals, Values: []string{strconv.FormatInt(finance.Id, 10)}},
+		}})
+		a.NoError(err)
+		a.False(rule.Enabled)
+
+		preview, err := rules.Preview(birthright.IdRequest{Id: rule.Id})
+		a.NoError(err)
+		a.Equal([]birthright.EmployeeResponse{{Id: bob, Name: "Bob"}}, preview.Grant)
+		a.Empty(preview.Revoke)
+		held, err := fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now())
+		a.NoError(err)
+		a.Empty(held)
+
+		_, err = rules.Enable(ctx, birthright.IdRequest{Id: rule.Id})
+		a.NoError(err)
+		held, err = fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now())
+		a.NoError(err)
+		a.Len(held, 1)
+		a.Equal(reader, held[0].RoleId)
+		a.Equal(&rule.Id, held[0].RuleId)
+
+		err = assignments.Revoke(ctx, assignment.RevokeRequest{EmployeeId: bob, RoleId: reader})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		a.NoError(employees.SetOrgUnit(ctx, employee.SetOrgUnitRequest{Id: carol, OrgUnitId: finance.Id}))
+		held, err = fixture.assignments.FindEffectiveByEmployeeId(carol, time.Now())
+		a.NoError(err)
+		a.Len(held, 1)
+
+		a.NoError(employees.SetOrgUnit(ctx, employee.SetOrgUnitRequest{Id: bob, OrgUnitId: sales.Id}))
+		held, err = fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now())
+		a.NoError(err)
+		a.Empty(held)
+
+		_, err = rules.Disable(ctx, birthright.IdRequest{Id: rule.Id})
+		a.NoError(err)
+		held, err = fixture.assignments.FindEffectiveByEmployeeId(carol, time.Now())
+		a.NoError(err)
+		a.Empty(held)
+	})
+
+	t.Run("manual grant survives rule and rule grant is revoked on termination", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		reader := fixture.Role("reader")
+		auditor := fixture.Role("auditor")
+		bob := fixture.Employee("Bob")
+		fixture.Assignment(bob, auditor, time.Now().Add(-time.Hour), nil)
+
+		rule, err := rules.Create(ctx, birthright.CreateRequest{Name: "Everyone reads", RoleId: reader})
+		a.NoError(err)
+		_, err = rules.Enable(ctx, birthright.IdRequest{Id: rule.Id})
+		a.NoError(err)
+		held, err := fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now())
+		a.NoError(err)
+		a.Len(held, 2)
+
+		_, err = employees.Transition(ctx, employee.TransitionRequest{Id: bob, Transition: employee.TransitionTerminate})
+		a.NoError(err)
+		held, err = fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now())
+		a.NoError(err)
+		a.Empty(held)
+
+		a.NoError(rules.Delete(ctx, birthright.IdRequest{Id: rule.Id}))
+		_, err = rules.FindById(birthright.IdRequest{Id: rule.Id})
+		a.ErrorAs(err, &common.NotFoundError{})
+	})
+
+	t.Run("attribute used by rule cannot be deleted", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		schema := attribute.NewService(fixture.attributes, auditService, vld)
+		region, err := schema.Create(ctx, attribute.CreateRequest{Key: "region", Type: "string"})
+		a.NoError(err)
+		reader := fixture.Role("eu-reader")
+		rule, err := rules.Create(ctx, birthright.CreateRequest{Name: "EU readers", RoleId: reader, Conditions: []birthright.Condition{
+			{Attribute: "attributes.region", Operator: birthright.OperatorEquals, Values: []string{"EU"}},
+		}})
+		a.NoError(err)
+
+		err = schema.Delete(ctx, attribute.IdRequest{Id: region.Id})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		a.NoError(rules.Delete(ctx, birthright.IdRequest{Id: rule.Id}))
+		a.NoError(schema.Delete(ctx, attribute.IdRequest{Id: region.Id}))
+	})
+}
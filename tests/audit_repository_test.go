@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/validator"
+	"testing"
+)
+
+func TestAuditRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	t.Run("record is saved together with transaction and filtered by entity", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		svc := audit.NewService(fixture.audit, validator.New())
+		ctx := common.WithActor(context.Background(), "alice")
+
+		tx := fixture.db.MustBegin()
+		a.NoError(svc.RecordTx(ctx, tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: "employee",
+			EntityId:   1,
+			After:      map[string]string{"name": "Bob"},
+		}))
+		a.NoError(svc.RecordTx(ctx, tx, audit.Event{Action: audit.ActionCreate, EntityType: "role", EntityId: 1}))
+		a.NoError(tx.Commit())
+
+		// запись откатывается вместе с изменением
+		tx = fixture.db.MustBegin()
+		a.NoError(svc.RecordTx(ctx, tx, audit.Event{Action: audit.ActionDelete, EntityType: "employee", EntityId: 1}))
+		a.NoError(tx.Rollback())
+
+		entityId := int64(1)
+		page, err := svc.FindAll(audit.ListRequest{EntityType: "employee", EntityId: &entityId})
+		a.NoError(err)
+		a.Len(page.Items, 1)
+		a.Equal("alice", page.Items[0].Actor)
+		a.JSONEq(`{"name":"Bob"}`, string(page.Items[0].After))
+		a.Nil(page.Items[0].Before)
+	})
+
+	t.Run("page through records from newest to oldest", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		svc := audit.NewService(fixture.audit, validator.New())
+
+		tx := fixture.db.MustBegin()
+		for id := int64(1); id <= 3; id++ {
+			a.NoError(svc.RecordTx(context.Background(), tx, audit.Event{Action: audit.ActionCreate, EntityType: "role", EntityId: id}))
+		}
+		a.NoError(tx.Commit())
+
+		first, err := svc.FindAll(audit.ListRequest{PageRequest: common.PageRequest{Limit: 2}})
+		a.NoError(err)
+		a.Len(first.Items, 2)
+		a.Equal(int64(3), first.Items[0].EntityId)
+		a.Equal(int64(3), first.Total)
+		a.NotEmpty(first.NextCursor)
+
+		second, err := svc.FindAll(audit.ListRequest{PageRequest: common.PageRequest{Limit: 2, Cursor: first.NextCursor}})
+		a.NoError(err)
+		a.Len(second.Items, 1)
+		a.Equal(int64(1), second.Items[0].EntityId)
+		a.Equal("anonymous", second.Items[0].Actor)
+	})
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/certification"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+func TestCertificationRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	settings := certification.Settings{AutoRevoke: true}
+	svc := certification.NewService(fixture.campaigns, fixture.roles, fixture.orgUnits, assignments, auditService, nil, settings, vld)
+	as := func(actor string) context.Context {
+		return common.WithActor(context.Background(), actor)
+	}
+
+	t.Run("revoked item closes assignment and last decision completes campaign", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		alice := fixture.Employee("Alice")
+		bob := fixture.Employee("Bob")
+		roleId := fixture.Role("devs")
+		fixture.Assignment(alice, roleId, time.Now().Add(-time.Hour), nil)
+		fixture.Assignment(bob, roleId, time.Now().Add(-time.Hour), nil)
+
+		created, err := svc.Create(as("manager"), certification.CreateRequest{
+			Name:      "Q3 review",
+			RoleIds:   []int64{roleId},
+			Reviewers: []string{"carol"},
+			Deadline:  time.Now().Add(time.Hour),
+		})
+		a.NoError(err)
+		a.Equal(int64(2), created.Progress.Total)
+
+		page, err := svc.FindItems(certification.ItemListRequest{Id: created.Id, Reviewer: "carol"})
+		a.NoError(err)
+		a.Len(page.Items, 2)
+		a.Equal("Alice", page.Items[0].EmployeeName)
+
+		_, err = svc.Revoke(as("carol"), certification.DecisionRequest{
+			CampaignId: created.Id, ItemId: page.Items[0].Id, Comment: "left the team",
+		})
+		a.NoError(err)
+		effective, err := fixture.assignments.FindEffectiveByEmployeeId(alice, time.Now().Add(time.Second))
+		a.NoError(err)
+		a.Empty(effective)
+
+		_, err = svc.Certify(as("carol"), certification.DecisionRequest{CampaignId: created.Id, ItemId: page.Items[1].Id})
+		a.NoError(err)
+		found, err := svc.FindById(certification.IdRequest{Id: created.Id})
+		a.NoError(err)
+		a.Equal(certification.StatusCompleted, found.Status)
+		a.Equal(certification.ProgressResponse{Total: 2, Certified: 1, Revoked: 1}, found.Progress)
+
+		pending, err := fixture.campaigns.CountItems(certification.ItemListRequest{Id: created.Id, Decision: "pending"})
+		a.NoError(err)
+		a.Equal(int64(0), pending)
+	})
+
+	t.Run("org unit scope covers nested units only", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		var sales, retail, support int64
+		a.NoError(db.Get(&sales, "insert into org_unit (name) values ('Sales') returning id"))
+		a.NoError(db.Get(&retail, "insert into org_unit (name, parent_id) values ('Retail', $1) returning id", sales))
+		a.NoError(db.Get(&support, "insert into org_unit (name) values ('Support') returning id"))
+		alice := fixture.Employee("Alice")
+		bob := fixture.Employee("Bob")
+		db.MustExec("update employee set org_unit_id = $1 where id = $2", retail, alice)
+		db.MustExec("update employee set org_unit_id = $1 where id = $2", support, bob)
+		roleId := fixture.Role("devs")
+		fixture.Assignment(alice, roleId, time.Now().Add(-time.Hour), nil)
+		fixture.Assignment(bob, roleId, time.Now().Add(-time.Hour), nil)
+
+		created, err := svc.Create(as("manager"), certification.CreateRequest{
+			Name: "Sales review", RoleIds: []int64{roleId}, OrgUnitId: &sales, Reviewers: []string{"carol"},
+			Deadline: time.Now().Add(time.Hour),
+		})
+		a.NoError(err)
+		a.Equal(int64(1), created.Progress.Total)
+		a.Equal(&sales, created.OrgUnitId)
+
+		page, err := svc.FindItems(certification.ItemListRequest{Id: created.Id})
+		a.NoError(err)
+		a.Len(page.Items, 1)
+		a.Equal(alice, page.Items[0].EmployeeId)
+	})
+
+	t.Run("deadline revokes undecided items", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		alice := fixture.Employee("Alice")
+		roleId := fixture.Role("devs")
+		fixture.Assignment(alice, roleId, time.Now().Add(-time.Hour), nil)
+
+		created, err := svc.Create(as("manager"), certification.CreateRequest{
+			Name: "Q3 review", RoleIds: []int64{roleId}, Reviewers: []string{"carol"}, Deadline: time.Now().Add(time.Hour),
+		})
+		a.NoError(err)
+
+		closed, err := svc.Close(as("system:certification-deadline"), time.Now())
+		a.NoError(err)
+		a.Equal(0, closed)
+		closed, err = svc.Close(as("system:certification-deadline"), time.Now().Add(2*time.Hour))
+		a.NoError(err)
+		a.Equal(1, closed)
+
+		items, err := fixture.campaigns.FindAllItems(created.Id)
+		a.NoError(err)
+		a.Len(items, 1)
+		a.Equal(certification.DecisionRevoked, *items[0].Decision)
+		a.Equal("system:certification-deadline", *items[0].DecidedBy)
+		effective, err := fixture.assignments.FindEffectiveByEmployeeId(alice, time.Now().Add(time.Second))
+		a.NoError(err)
+		a.Empty(effective)
+	})
+}
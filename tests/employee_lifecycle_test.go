@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+func TestEmployeeLifecycle(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	rules := birthright.NewService(fixture.rules, fixture.roles, fixture.attributes, assignments, auditService, vld)
+	employees := employee.NewService(
+		fixture.employees, fixture.roles, fixture.orgUnits, fixture.attributes, fixture.assignments, assignments, rules,
+		fixture.sodRules, allowAccess{}, auditService, vld,
+	)
+	ctx := common.WithActor(context.Background(), "alice")
+
+	t.Run("termination revokes all roles and blocks new grants", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		bob := fixture.Employee("Bob")
+		accountant := fixture.Role("accountant")
+		auditor := fixture.Role("auditor")
+		fixture.Assignment(bob, accountant, time.Now().Add(-time.Hour), nil)
+		fixture.Assignment(bob, auditor, time.Now().Add(time.Hour), nil)
+
+		_, err := employees.Transition(ctx, employee.TransitionRequest{Id: bob, Transition: employee.TransitionTerminate})
+		a.NoError(err)
+
+		found, err := fixture.employees.FindById(bob)
+		a.NoError(err)
+		a.Equal(employee.StatusTerminated, found.Status)
+		effective, err := fixture.assignments.FindEffectiveByEmployeeId(bob, time.Now().Add(2*time.Hour))
+		a.NoError(err)
+		a.Empty(effective)
+
+		_, err = assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: bob, RoleId: accountant})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("scheduled transition waits for effective date", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		bob := fixture.Employee("Bob")
+		effectiveAt := time.Now().Add(time.Hour)
+
+		_, err := employees.Transition(ctx,
+			employee.TransitionRequest{Id: bob, Transition: employee.TransitionSuspend, EffectiveAt: &effectiveAt})
+		a.NoError(err)
+		_, err = employees.Transition(ctx,
+			employee.TransitionRequest{Id: bob, Transition: employee.TransitionTerminate, EffectiveAt: &effectiveAt})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		applied, err := employees.ApplyDue(ctx, time.Now())
+		a.NoError(err)
+		a.Equal(0, applied)
+
+		applied, err = employees.ApplyDue(ctx, effectiveAt.Add(time.Minute))
+		a.NoError(err)
+		a.Equal(1, applied)
+		found, err := fixture.employees.FindById(bob)
+		a.NoError(err)
+		a.Equal(employee.StatusSuspended, found.Status)
+
+		transitions, err := employees.FindTransitions(employee.IdRequest{Id: bob})
+		a.NoError(err)
+		a.Len(transitions, 1)
+		a.Equal(employee.TransitionApplied, transitions[0].Status)
+	})
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/validator"
+	"testing"
+)
+
+func TestEmployeeProfile(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	schema := attribute.NewService(fixture.attributes, auditService, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	rules := birthright.NewService(fixture.rules, fixture.roles, fixture.attributes, assignments, auditService, vld)
+	employees := employee.NewService(
+		fixture.employees, fixture.roles, fixture.orgUnits, fixture.attributes, fixture.assignments, assignments, rules,
+		fixture.sodRules, allowAccess{}, auditService, vld,
+	)
+	ctx := common.WithActor(context.Background(), "alice")
+
+	t.Run("profile and custom attributes round trip through the database", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		_, err := schema.Create(ctx, attribute.CreateRequest{Key: "cost_center", Type: "string", Required: true})
+		a.NoError(err)
+		_, err = schema.Create(ctx, attribute.CreateRequest{Key: "probation_end", Type: "date"})
+		a.NoError(err)
+
+		email, number := "bob@example.com", "E-001"
+		hireDate, err := common.ParseDate("2025-02-03")
+		a.NoError(err)
+		id, err := employees.Create(ctx, employee.CreateRequest{
+			Name:           "Bob",
+			Email:          &email,
+			HireDate:       &hireDate,
+			EmployeeNumber: &number,
+			Attributes:     employee.Attributes{"cost_center": "CC-42", "probation_end": "2025-05-03"},
+		})
+		a.NoError(err)
+
+		found, err := employees.FindById(employee.IdRequest{Id: id})
+		a.NoError(err)
+		a.Equal(&email, found.Email)
+		a.Equal("2025-02-03", found.HireDate.String())
+		a.Equal(employee.Attributes{"cost_center": "CC-42", "probation_end": "2025-05-03"}, found.Attributes)
+
+		_, err = employees.Create(ctx, employee.CreateRequest{
+			Name:           "Carol",
+			EmployeeNumber: &number,
+			Attributes:     employee.Attributes{"cost_center": "CC-7"},
+		})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		_, err = employees.Create(ctx, employee.CreateRequest{Name: "Dave"})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("deleting a definition removes its values", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		remote, err := schema.Create(ctx, attribute.CreateRequest{Key: "remote", Type: "boolean"})
+		a.NoError(err)
+		id, err := employees.Create(ctx, employee.CreateRequest{Name: "Bob", Attributes: employee.Attributes{"remote": true}})
+		a.NoError(err)
+
+		a.NoError(schema.Delete(ctx, attribute.IdRequest{Id: remote.Id}))
+
+		found, err := employees.FindById(employee.IdRequest{Id: id})
+		a.NoError(err)
+		a.Empty(found.Attributes)
+	})
+
+	t.Run("attribute becomes required only when every employee has a value", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		region, err := schema.Create(ctx, attribute.CreateRequest{Key: "region", Type: "string"})
+		a.NoError(err)
+		id, err := employees.Create(ctx, employee.CreateRequest{Name: "Bob"})
+		a.NoError(err)
+
+		_, err = schema.Update(ctx, attribute.UpdateRequest{Id: region.Id, Required: true})
+		a.ErrorAs(err, &common.RequestValidationError{})
+		_, err = employees.Patch(ctx, employee.PatchRequest{
+			Id: id, Attributes: common.Optional[employee.Attributes]{Set: true, Value: &employee.Attributes{"region": "EU"}},
+		})
+		a.NoError(err)
+		_, err = schema.Update(ctx, attribute.UpdateRequest{Id: region.Id, Required: true})
+		a.NoError(err)
+	})
+}
@@ -1,10 +1,15 @@
 package tests
 
 import (
+	"database/sql"
 	"github.com/stretchr/testify/assert"
+	"idm/inner/accessrequest"
+	"idm/inner/common"
 	"idm/inner/database"
 	"idm/inner/employee"
+	"idm/inner/sod"
 	"testing"
+	"time"
 )
 
 func TestEmployeeRepository(t *testing.T) {
@@ -56,8 +61,12 @@ func TestEmployeeRepository(t *testing.T) {
 		defer fixture.ClearDatabase()
 		id := fixture.Employee("Alice")
 
-		err := fixture.employees.DeleteById(id)
+		tx := fixture.db.MustBegin()
+		deleted, err := fixture.employees.DeleteTx(tx, []int64{id})
 		a.Nil(err)
+		a.NoError(tx.Commit())
+		a.Len(deleted, 1)
+		a.NotNil(deleted[0].DeletedAt)
 
 		_, err = fixture.employees.FindById(id)
 		a.NotNil(err)
@@ -68,8 +77,10 @@ func TestEmployeeRepository(t *testing.T) {
 		id1 := fixture.Employee("Bob")
 		id2 := fixture.Employee("Alice")
 
-		err := fixture.employees.DeleteAllByIds([]int64{id1, id2})
+		tx := fixture.db.MustBegin()
+		_, err := fixture.employees.DeleteTx(tx, []int64{id1, id2})
 		a.Nil(err)
+		a.NoError(tx.Commit())
 
 		got, _ := fixture.employees.FindAllByIds([]int64{id1, id2})
 		a.Len(got, 0)
@@ -101,4 +112,268 @@ func TestEmployeeRepository(t *testing.T) {
 		a.NoError(err)
 		a.Equal(entity.Name, saved.Name)
 	})
+
+	t.Run("set and remove employee role", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("Admin")
+
+		tx := fixture.db.MustBegin()
+		err := fixture.employees.UpdateRoleTx(tx, employeeId, &roleId)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.employees.FindById(employeeId)
+		a.NoError(err)
+		a.NotNil(got.RoleId)
+		a.Equal(roleId, *got.RoleId)
+
+		tx = fixture.db.MustBegin()
+		err = fixture.employees.UpdateRoleTx(tx, employeeId, nil)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+
+		got, err = fixture.employees.FindById(employeeId)
+		a.NoError(err)
+		a.Nil(got.RoleId)
+	})
+
+	t.Run("save employee with role", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		roleId := fixture.Role("Admin")
+
+		id, err := fixture.employees.Save(&employee.Entity{Name: "Bob", RoleId: &roleId})
+		a.NoError(err)
+
+		got, err := fixture.employees.FindById(id)
+		a.NoError(err)
+		a.NotNil(got.RoleId)
+		a.Equal(roleId, *got.RoleId)
+	})
+
+	t.Run("update employee only when version matches", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		current, err := fixture.employees.FindById(employeeId)
+		a.NoError(err)
+
+		stale := current.UpdatedAt.Add(-time.Second)
+		tx, err := fixture.employees.BeginTransaction()
+		a.NoError(err)
+		updated, err := fixture.employees.UpdateTx(tx, employee.Entity{Id: employeeId, Name: "Alice Smith"}, &stale)
+		a.NoError(err)
+		a.False(updated)
+		updated, err = fixture.employees.UpdateTx(tx, employee.Entity{Id: employeeId, Name: "Alice Smith"}, &current.UpdatedAt)
+		a.NoError(err)
+		a.True(updated)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.employees.FindById(employeeId)
+		a.NoError(err)
+		a.Equal("Alice Smith", got.Name)
+		a.True(got.UpdatedAt.After(current.UpdatedAt))
+	})
+
+	t.Run("page through employees with filter", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		fixture.Employee("Alice")
+		fixture.Employee("Bob")
+		fixture.Employee("Al_Capone")
+		fixture.Employee("Alfred")
+
+		request := employee.ListRequest{
+			PageRequest: common.PageRequest{Limit: 2, Sort: "name", Order: "asc"},
+			NamePrefix:  "Al",
+		}
+		total, err := fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(3), total)
+
+		first, err := fixture.employees.FindPage(request, nil)
+		a.NoError(err)
+		a.Len(first, 3)
+		a.Equal("Al_Capone", first[0].Name)
+		a.Equal("Alfred", first[1].Name)
+
+		after := &common.Cursor{Sort: "name", Order: "asc", Value: first[1].Name, Id: first[1].Id}
+		second, err := fixture.employees.FindPage(request, after)
+		a.NoError(err)
+		a.Len(second, 1)
+		a.Equal("Alice", second[0].Name)
+
+		request.NamePrefix = "Al_"
+		total, err = fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(1), total)
+	})
+
+	t.Run("filter employees by role held through assignment", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		roleId := fixture.Role("devs")
+		primaryId, err := fixture.employees.Save(&employee.Entity{Name: "Alice", RoleId: &roleId})
+		a.NoError(err)
+		assignedId := fixture.Employee("Bob")
+		fixture.Assignment(assignedId, roleId, time.Now().Add(-time.Hour), nil)
+		expiredId := fixture.Employee("Carol")
+		ended := time.Now().Add(-time.Minute)
+		fixture.Assignment(expiredId, roleId, time.Now().Add(-time.Hour), &ended)
+		fixture.Employee("Dave")
+
+		request := employee.ListRequest{PageRequest: common.PageRequest{Limit: 10, Sort: "id", Order: "asc"}, RoleId: &roleId}
+		total, err := fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(2), total)
+
+		got, err := fixture.employees.FindPage(request, nil)
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal(primaryId, got[0].Id)
+		a.Equal(assignedId, got[1].Id)
+
+		// по истории на текущий момент истёкшее назначение Carol уже не действует
+		asOf := time.Now()
+		request.AsOf = &asOf
+		total, err = fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(2), total)
+	})
+
+	t.Run("page through employees with condition and offset", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		fixture.Employee("Alice")
+		fixture.Employee("Bob")
+		fixture.Employee("Alex")
+		fixture.Employee("ALAN")
+
+		request := employee.ListRequest{
+			PageRequest: common.PageRequest{Limit: 1, Sort: "name", Order: "asc"},
+			Filter:      &database.Filter{Condition: "lower(name) like ?", Args: []any{"al%"}},
+			Offset:      1,
+		}
+		total, err := fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(3), total)
+
+		got, err := fixture.employees.FindPage(request, nil)
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal("Alex", got[0].Name)
+	})
+
+	t.Run("search employees ignoring case, accents and yo", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		fyodorId := fixture.Employee("Фёдоров Пётр")
+		joseId := fixture.Employee("José Álvarez")
+		fixture.Employee("Bob")
+
+		got, err := fixture.employees.Search("федоров", 10)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal(fyodorId, got[0].Id)
+		a.Greater(got[0].Rank, 0.0)
+
+		got, err = fixture.employees.Search("ALVAREZ", 10)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal(joseId, got[0].Id)
+
+		got, err = fixture.employees.Search("alvarse", 10)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal(joseId, got[0].Id)
+	})
+
+	t.Run("soft delete, restore and purge employee", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		id := fixture.Employee("Alice")
+
+		fixture.DeleteEmployee(id)
+		_, err := fixture.employees.FindById(id)
+		a.Error(err)
+
+		request := employee.ListRequest{PageRequest: common.PageRequest{Limit: 10, Sort: "id", Order: "asc"}}
+		total, err := fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(0), total)
+		request.IncludeDeleted = true
+		deleted, err := fixture.employees.FindPage(request, nil)
+		a.NoError(err)
+		a.Len(deleted, 1)
+		a.NotNil(deleted[0].DeletedAt)
+
+		tx, err := fixture.employees.BeginTransaction()
+		a.NoError(err)
+		restored, err := fixture.employees.RestoreTx(tx, id)
+		a.NoError(err)
+		a.Nil(restored.DeletedAt)
+		a.NoError(tx.Commit())
+		_, err = fixture.employees.FindById(id)
+		a.NoError(err)
+
+		fixture.DeleteEmployee(id)
+		purged, err := fixture.employees.Purge(time.Now().Add(-time.Hour))
+		a.NoError(err)
+		a.Equal(int64(0), purged)
+		purged, err = fixture.employees.Purge(time.Now().Add(time.Hour))
+		a.NoError(err)
+		a.Equal(int64(1), purged)
+	})
+
+	t.Run("purge keeps employees referenced by compliance records", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		requester := fixture.Employee("Alice")
+		overridden := fixture.Employee("Bob")
+		plain := fixture.Employee("Carol")
+		creator := fixture.Role("payment creator")
+		approver := fixture.Role("payment approver")
+		tx := fixture.db.MustBegin()
+		request, err := fixture.requests.SaveTx(tx, accessrequest.Entity{EmployeeId: requester, RoleId: creator,
+			Justification: "month end", RequestedBy: "alice", ExpiresAt: time.Now().Add(time.Hour)})
+		a.NoError(err)
+		rule, err := fixture.sodRules.SaveTx(tx, sod.Entity{Name: "payments", RoleAId: creator, RoleBId: approver})
+		a.NoError(err)
+		overrideId, err := fixture.sodRules.SaveOverrideTx(tx, sod.OverrideEntity{RuleId: rule.Id, EmployeeId: overridden,
+			Justification: "audit approved", CreatedBy: "alice"})
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		fixture.DeleteEmployee(requester)
+		fixture.DeleteEmployee(overridden)
+		fixture.DeleteEmployee(plain)
+
+		purged, err := fixture.employees.Purge(time.Now().Add(time.Hour))
+		a.NoError(err)
+		a.Equal(int64(1), purged)
+
+		_, err = fixture.requests.FindById(request.Id)
+		a.NoError(err)
+		var overrides int
+		a.NoError(fixture.db.Get(&overrides, "select count(*) from sod_override where id = $1", overrideId))
+		a.Equal(1, overrides)
+		var left []int64
+		a.NoError(fixture.db.Select(&left, "select id from employee order by id"))
+		a.Equal([]int64{requester, overridden}, left)
+	})
+
+	t.Run("find employee by login", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		login := "alice@example.com"
+		tx := fixture.db.MustBegin()
+		id, err := fixture.employees.SaveTx(tx, employee.Entity{Name: "Alice", Login: &login})
+		a.NoError(err)
+		taken, err := fixture.employees.FindByLoginExceptTx(tx, login, 0)
+		a.NoError(err)
+		a.True(taken)
+		taken, err = fixture.employees.FindByLoginExceptTx(tx, login, id)
+		a.NoError(err)
+		a.False(taken)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.employees.FindByLogin(login)
+		a.NoError(err)
+		a.Equal(id, got.Id)
+
+		fixture.DeleteEmployee(id)
+		_, err = fixture.employees.FindByLogin(login)
+		a.ErrorIs(err, sql.ErrNoRows)
+	})
 }
@@ -1,23 +1,64 @@
 package tests
 
 import (
+	"context"
 	"github.com/jmoiron/sqlx"
+	"idm/inner/accessrequest"
+	"idm/inner/apikey"
+	"idm/inner/assignment"
+	"idm/inner/attribute"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/certification"
 	"idm/inner/employee"
+	"idm/inner/oauth"
+	"idm/inner/orgunit"
+	"idm/inner/permission"
+	"idm/inner/provisioning"
 	"idm/inner/role"
+	"idm/inner/sod"
+	"idm/inner/webhook"
+	"time"
 )
 
 type Fixture struct {
-	db        *sqlx.DB
-	employees *employee.Repository
-	roles     *role.Repository
+	db          *sqlx.DB
+	employees   *employee.Repository
+	roles       *role.Repository
+	assignments *assignment.Repository
+	permissions *permission.Repository
+	audit       *audit.Repository
+	apiKeys     *apikey.Repository
+	clients     *oauth.Repository
+	operations  *provisioning.Repository
+	webhooks    *webhook.Repository
+	requests    *accessrequest.Repository
+	campaigns   *certification.Repository
+	sodRules    *sod.Repository
+	orgUnits    *orgunit.Repository
+	attributes  *attribute.Repository
+	rules       *birthright.Repository
 }
 
 func NewFixture(db *sqlx.DB) *Fixture {
 	initSchema(db)
 	return &Fixture{
-		db:        db,
-		employees: employee.NewRepository(db),
-		roles:     role.NewRepository(db),
+		db:          db,
+		employees:   employee.NewRepository(db),
+		roles:       role.NewRepository(db),
+		assignments: assignment.NewRepository(db),
+		permissions: permission.NewRepository(db),
+		audit:       audit.NewRepository(db),
+		apiKeys:     apikey.NewRepository(db),
+		clients:     oauth.NewRepository(db),
+		operations:  provisioning.NewRepository(db),
+		webhooks:    webhook.NewRepository(db),
+		requests:    accessrequest.NewRepository(db),
+		campaigns:   certification.NewRepository(db),
+		sodRules:    sod.NewRepository(db),
+		orgUnits:    orgunit.NewRepository(db),
+		attributes:  attribute.NewRepository(db),
+		rules:       birthright.NewRepository(db),
 	}
 }
 
@@ -27,15 +68,362 @@ func initSchema(db *sqlx.DB) {
     	id bigint primary key generated always as identity,
     	name text not null,
     	created_at timestamptz not null default now(),
-    	updated_at timestamptz not null default now()
+    	updated_at timestamptz not null default now(),
+    	deleted_at timestamptz
 	);
 
+	create table if not exists org_unit (
+    	id bigint primary key generated always as identity,
+    	name text not null,
+    	parent_id bigint references org_unit(id) on delete restrict,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now(),
+    	check (parent_id <> id)
+	);
+
+	create unique index if not exists org_unit_name_idx on org_unit (coalesce(parent_id, 0), name);
+
 	create table if not exists employee (
     	id bigint primary key generated always as identity,
     	name text not null,
     	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now(),
+    	role_id bigint references role(id) on delete set null,
+    	deleted_at timestamptz,
+    	login text,
+    	status text not null default 'active',
+    	org_unit_id bigint references org_unit(id) on delete set null,
+    	manager_id bigint references employee(id) on delete set null,
+    	email text,
+    	title text,
+    	phone text,
+    	hire_date date,
+    	employee_number text,
+    	attributes jsonb not null default '{}',
+    	check (manager_id <> id)
+	);
+
+	create unique index if not exists employee_login_idx on employee (login) where deleted_at is null;
+
+	create unique index if not exists employee_number_idx on employee (employee_number) where deleted_at is null;
+
+	create table if not exists employee_attribute (
+    	id bigint primary key generated always as identity,
+    	key text not null unique,
+    	type text not null,
+    	required boolean not null default false,
+    	description text not null default '',
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists assignment_rule (
+    	id bigint primary key generated always as identity,
+    	name text not null unique,
+    	description text not null default '',
+    	role_id bigint not null references role(id) on delete restrict,
+    	conditions jsonb not null default '[]',
+    	enabled boolean not null default false,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists employee_role (
+    	id bigint primary key generated always as identity,
+    	employee_id bigint not null references employee(id) on delete cascade,
+    	role_id bigint not null references role(id) on delete cascade,
+    	valid_from timestamptz not null default now(),
+    	valid_to timestamptz,
+    	created_at timestamptz not null default now(),
+    	rule_id bigint references assignment_rule(id) on delete set null,
+    	check (valid_to is null or valid_to > valid_from)
+	);
+
+	create table if not exists permission (
+    	id bigint primary key generated always as identity,
+    	name text not null unique,
+    	description text not null default '',
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists role_permission (
+    	role_id bigint not null references role(id) on delete cascade,
+    	permission_id bigint not null references permission(id) on delete cascade,
+    	created_at timestamptz not null default now(),
+    	primary key (role_id, permission_id)
+	);
+
+	create table if not exists role_hierarchy (
+    	parent_id bigint not null references role(id) on delete cascade,
+    	child_id bigint not null references role(id) on delete cascade,
+    	created_at timestamptz not null default now(),
+    	primary key (parent_id, child_id),
+    	check (parent_id <> child_id)
+	);
+
+	create table if not exists audit_log (
+    	id bigint primary key generated always as identity,
+    	actor text not null,
+    	action text not null,
+    	entity_type text not null,
+    	entity_id bigint not null,
+    	before jsonb,
+    	after jsonb,
+    	request_id text not null default '',
+    	created_at timestamptz not null default now()
+	);
+
+	create table if not exists api_key (
+    	id bigint primary key generated always as identity,
+    	prefix text not null unique,
+    	account text not null,
+    	secret_hash bytea not null,
+    	salt bytea not null,
+    	permissions text[] not null default '{}',
+    	expires_at timestamptz not null,
+    	revoked_at timestamptz,
+    	rotated_from bigint references api_key(id) on delete set null,
+    	created_at timestamptz not null default now()
+	);
+
+	create table if not exists oauth_client (
+    	id bigint primary key generated always as identity,
+    	client_id text not null unique,
+    	secret_hash bytea not null,
+    	salt bytea not null,
+    	scopes text[] not null default '{}',
+    	created_at timestamptz not null default now()
+	);
+
+	create table if not exists provisioning_operation (
+    	id bigint primary key generated always as identity,
+    	connector text not null,
+    	kind text not null,
+    	employee_id bigint not null references employee(id) on delete cascade,
+    	role_id bigint,
+    	payload jsonb not null,
+    	status text not null default 'pending',
+    	attempts int not null default 0,
+    	scheduled_at timestamptz not null default now(),
+    	next_attempt_at timestamptz not null default now(),
+    	last_error text,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists outbox_event (
+    	id bigint primary key generated always as identity,
+    	event_type text not null,
+    	entity_id bigint not null,
+    	actor text not null,
+    	data jsonb not null,
+    	created_at timestamptz not null default now(),
+    	dispatched_at timestamptz
+	);
+
+	create table if not exists webhook_subscription (
+    	id bigint primary key generated always as identity,
+    	url text not null,
+    	secret text not null,
+    	event_types text[] not null default '{}',
+    	active boolean not null default true,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists webhook_delivery (
+    	id bigint primary key generated always as identity,
+    	subscription_id bigint not null references webhook_subscription(id) on delete cascade,
+    	event_id bigint not null references outbox_event(id) on delete cascade,
+    	status text not null default 'pending',
+    	attempts int not null default 0,
+    	next_attempt_at timestamptz not null default now(),
+    	response_status int,
+    	last_error text,
+    	delivered_at timestamptz,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists access_request (
+    	id bigint primary key generated always as identity,
+    	employee_id bigint not null references employee(id) on delete restrict,
+    	role_id bigint not null references role(id) on delete restrict,
+    	justification text not null,
+    	valid_from timestamptz,
+    	valid_to timestamptz,
+    	status text not null default 'pending',
+    	requested_by text not null,
+    	expires_at timestamptz not null,
+    	decided_by text,
+    	decided_at timestamptz,
+    	assignment_id bigint references employee_role(id) on delete set null,
+    	created_at timestamptz not null default now(),
     	updated_at timestamptz not null default now()
-	);`
+	);
+
+	create unique index if not exists access_request_pending_uidx on access_request (employee_id, role_id)
+    	where status = 'pending';
+
+	create table if not exists access_request_approver (
+    	request_id bigint not null references access_request(id) on delete cascade,
+    	approver text not null,
+    	decision text,
+    	comment text,
+    	decided_at timestamptz,
+    	primary key (request_id, approver)
+	);
+
+	create table if not exists certification_campaign (
+    	id bigint primary key generated always as identity,
+    	name text not null,
+    	role_ids bigint[] not null,
+    	org_unit_id bigint,
+    	status text not null default 'active',
+    	deadline timestamptz not null,
+    	auto_revoke boolean not null,
+    	created_by text not null,
+    	completed_at timestamptz,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create table if not exists certification_item (
+    	id bigint primary key generated always as identity,
+    	campaign_id bigint not null references certification_campaign(id) on delete cascade,
+    	assignment_id bigint not null,
+    	employee_id bigint not null,
+    	employee_name text not null,
+    	employee_login text,
+    	role_id bigint not null,
+    	role_name text not null,
+    	valid_from timestamptz not null,
+    	valid_to timestamptz,
+    	reviewer text not null,
+    	decision text,
+    	comment text,
+    	decided_by text,
+    	decided_at timestamptz,
+    	unique (campaign_id, assignment_id)
+	);
+
+	create table if not exists sod_rule (
+    	id bigint primary key generated always as identity,
+    	name text not null unique,
+    	description text not null default '',
+    	role_a_id bigint not null references role(id) on delete restrict,
+    	role_b_id bigint not null references role(id) on delete restrict,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now(),
+    	check (role_a_id < role_b_id),
+    	unique (role_a_id, role_b_id)
+	);
+
+	create table if not exists sod_override (
+    	id bigint primary key generated always as identity,
+    	rule_id bigint not null references sod_rule(id) on delete cascade,
+    	employee_id bigint not null references employee(id) on delete restrict,
+    	assignment_id bigint references employee_role(id) on delete set null,
+    	justification text not null,
+    	created_by text not null,
+    	created_at timestamptz not null default now()
+	);
+
+	create table if not exists employee_transition (
+    	id bigint primary key generated always as identity,
+    	employee_id bigint not null references employee(id) on delete cascade,
+    	transition text not null,
+    	from_status text not null,
+    	to_status text not null,
+    	effective_at timestamptz not null,
+    	reason text not null default '',
+    	status text not null default 'pending',
+    	created_by text not null,
+    	applied_at timestamptz,
+    	created_at timestamptz not null default now(),
+    	updated_at timestamptz not null default now()
+	);
+
+	create unique index if not exists employee_transition_pending_idx on employee_transition (employee_id)
+		where status = 'pending';
+
+	create table if not exists employee_history (
+    	id bigint not null,
+    	name text not null,
+    	created_at timestamptz not null,
+    	updated_at timestamptz not null,
+    	role_id bigint,
+    	deleted_at timestamptz,
+    	version_from timestamptz not null,
+    	version_to timestamptz,
+    	login text,
+    	status text not null default 'active',
+    	org_unit_id bigint,
+    	manager_id bigint,
+    	email text,
+    	title text,
+    	phone text,
+    	hire_date date,
+    	employee_number text,
+    	attributes jsonb not null default '{}',
+    	primary key (id, version_from)
+	);
+
+	create table if not exists role_history (
+    	id bigint not null,
+    	name text not null,
+    	created_at timestamptz not null,
+    	updated_at timestamptz not null,
+    	deleted_at timestamptz,
+    	version_from timestamptz not null,
+    	version_to timestamptz,
+    	primary key (id, version_from)
+	);
+
+	create table if not exists employee_role_history (
+    	id bigint not null,
+    	employee_id bigint not null,
+    	role_id bigint not null,
+    	valid_from timestamptz not null,
+    	valid_to timestamptz,
+    	created_at timestamptz not null,
+    	rule_id bigint,
+    	version_from timestamptz not null,
+    	version_to timestamptz,
+    	primary key (id, version_from)
+	);
+
+	create or replace function record_history() returns trigger as $$
+	declare
+		changed_at timestamptz := clock_timestamp();
+		history text := tg_table_name || '_history';
+	begin
+		if tg_op <> 'INSERT' then
+			execute format('update %I set version_to = $1 where id = $2 and version_to is null', history)
+				using changed_at, old.id;
+		end if;
+		if tg_op <> 'DELETE' then
+			execute format('insert into %1$I select * from jsonb_populate_record(null::%1$I, $1)', history)
+				using to_jsonb(new) || jsonb_build_object('version_from', changed_at);
+		end if;
+		return null;
+	end;
+	$$ language plpgsql;
+
+	create or replace trigger employee_record_history after insert or update or delete on employee
+		for each row execute function record_history();
+	create or replace trigger role_record_history after insert or update or delete on role
+		for each row execute function record_history();
+	create or replace trigger employee_role_record_history after insert or update or delete on employee_role
+		for each row execute function record_history();
+
+	create extension if not exists pg_trgm;
+	create extension if not exists unaccent;
+
+	create or replace function normalize_name(value text) returns text as $$
+		select replace(lower(public.unaccent('public.unaccent'::regdictionary, value)), 'ё', 'е')
+	$$ language sql immutable strict parallel safe;`
 	db.MustExec(schema)
 }
 
@@ -61,7 +449,87 @@ func (f *Fixture) Role(name string) int64 {
 	return newId
 }
 
+func (f *Fixture) Assignment(employeeId, roleId int64, from time.Time, to *time.Time) int64 {
+	tx := f.db.MustBegin()
+	newId, err := f.assignments.SaveTx(tx, assignment.Entity{
+		EmployeeId: employeeId,
+		RoleId:     roleId,
+		ValidFrom:  from,
+		ValidTo:    to,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return newId
+}
+
+func (f *Fixture) Permission(name string) int64 {
+	tx := f.db.MustBegin()
+	newId, err := f.permissions.SaveTx(tx, permission.Entity{Name: name})
+	if err != nil {
+		_ = tx.Rollback()
+		panic(err)
+	}
+	if err = tx.Commit(); err != nil {
+		panic(err)
+	}
+	return newId
+}
+
+func (f *Fixture) GrantPermission(roleId, permissionId int64) {
+	f.db.MustExec("insert into role_permission (role_id, permission_id) values ($1, $2)", roleId, permissionId)
+}
+
+// DeleteEmployee мягко удалить сотрудника
+func (f *Fixture) DeleteEmployee(id int64) {
+	f.db.MustExec("update employee set deleted_at = now() where id = $1", id)
+}
+
+func (f *Fixture) IncludeRole(parentId, childId int64) {
+	f.db.MustExec("insert into role_hierarchy (parent_id, child_id) values ($1, $2)", parentId, childId)
+}
+
+// allowAccess тесты меняют роли и логины сотрудников и назначают согласующих без проверки разрешений
+type allowAccess struct{}
+
+func (allowAccess) Holds(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+func (allowAccess) HasPermission(string, string) (bool, error) {
+	return true, nil
+}
+
 func (f *Fixture) ClearDatabase() {
+	f.db.MustExec("delete from audit_log")
+	f.db.MustExec("delete from provisioning_operation")
+	f.db.MustExec("delete from webhook_delivery")
+	f.db.MustExec("delete from webhook_subscription")
+	f.db.MustExec("delete from outbox_event")
+	f.db.MustExec("delete from access_request_approver")
+	f.db.MustExec("delete from access_request")
+	f.db.MustExec("delete from certification_item")
+	f.db.MustExec("delete from certification_campaign")
+	f.db.MustExec("delete from sod_override")
+	f.db.MustExec("delete from sod_rule")
+	f.db.MustExec("delete from employee_transition")
+	f.db.MustExec("delete from api_key")
+	f.db.MustExec("delete from oauth_client")
+	f.db.MustExec("delete from role_hierarchy")
+	f.db.MustExec("delete from role_permission")
+	f.db.MustExec("delete from permission")
+	f.db.MustExec("delete from employee_role")
+	f.db.MustExec("delete from assignment_rule")
 	f.db.MustExec("delete from employee")
+	f.db.MustExec("update org_unit set parent_id = null")
+	f.db.MustExec("delete from org_unit")
+	f.db.MustExec("delete from employee_attribute")
 	f.db.MustExec("delete from role")
+	f.db.MustExec("delete from employee_role_history")
+	f.db.MustExec("delete from employee_history")
+	f.db.MustExec("delete from role_history")
 }
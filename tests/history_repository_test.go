@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/role"
+	"testing"
+	"time"
+)
+
+func TestHistoryRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	// now время базы: версии помечаются clock_timestamp, поэтому моменты берём оттуда же
+	now := func() (at time.Time) {
+		if err := fixture.db.Get(&at, "select clock_timestamp()"); err != nil {
+			panic(err)
+		}
+		return at
+	}
+
+	t.Run("employee is reconstructed as of moment before change", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		roleId := fixture.Role("Developer")
+		employeeId := fixture.Employee("Bob")
+		fixture.db.MustExec("update employee set role_id = $1 where id = $2", roleId, employeeId)
+		before := now()
+		fixture.db.MustExec("update employee set name = 'Bobby', role_id = null where id = $1", employeeId)
+		fixture.db.MustExec("update role set name = 'Senior Developer' where id = $1", roleId)
+
+		got, err := fixture.employees.FindByIdAsOf(employeeId, before)
+		a.NoError(err)
+		a.Equal("Bob", got.Name)
+		a.Equal(&roleId, got.RoleId)
+
+		current, err := fixture.employees.FindByIdAsOf(employeeId, now())
+		a.NoError(err)
+		a.Equal("Bobby", current.Name)
+		a.Nil(current.RoleId)
+
+		oldRole, err := fixture.roles.FindByIdAsOf(roleId, before)
+		a.NoError(err)
+		a.Equal("Developer", oldRole.Name)
+	})
+
+	t.Run("employee did not exist before creation and after deletion", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		before := now()
+		employeeId := fixture.Employee("Bob")
+		fixture.DeleteEmployee(employeeId)
+
+		_, err := fixture.employees.FindByIdAsOf(employeeId, before)
+		a.Error(err)
+		_, err = fixture.employees.FindByIdAsOf(employeeId, now())
+		a.Error(err)
+	})
+
+	t.Run("history lists every version in order", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Bob")
+		fixture.db.MustExec("update employee set name = 'Bobby' where id = $1", employeeId)
+		fixture.DeleteEmployee(employeeId)
+
+		versions, err := fixture.employees.FindHistory(employeeId)
+		a.NoError(err)
+		a.Len(versions, 3)
+		a.Equal("Bob", versions[0].Name)
+		a.Equal(versions[1].VersionFrom, *versions[0].VersionTo)
+		a.Equal("Bobby", versions[1].Name)
+		a.NotNil(versions[2].DeletedAt)
+		a.Nil(versions[2].VersionTo)
+
+		// окончательное удаление закрывает последнюю версию, но история остаётся
+		fixture.db.MustExec("delete from employee where id = $1", employeeId)
+		versions, err = fixture.employees.FindHistory(employeeId)
+		a.NoError(err)
+		a.Len(versions, 3)
+		a.NotNil(versions[2].VersionTo)
+	})
+
+	t.Run("list is built as of given moment", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		fixture.Employee("Alice")
+		bobId := fixture.Employee("Bob")
+		before := now()
+		fixture.Employee("Carol")
+		fixture.db.MustExec("update employee set name = 'Bobby' where id = $1", bobId)
+
+		request := employee.ListRequest{
+			PageRequest: common.PageRequest{Limit: 10, Sort: "name", Order: "asc"},
+			AsOf:        &before,
+		}
+		got, err := fixture.employees.FindPage(request, nil)
+		a.NoError(err)
+		a.Len(got, 2)
+		a.Equal("Alice", got[0].Name)
+		a.Equal("Bob", got[1].Name)
+
+		total, err := fixture.employees.Count(request)
+		a.NoError(err)
+		a.Equal(int64(2), total)
+
+		roles, err := fixture.roles.FindPage(role.ListRequest{
+			PageRequest: common.PageRequest{Limit: 10, Sort: "id", Order: "asc"},
+			AsOf:        &before,
+		}, nil)
+		a.NoError(err)
+		a.Empty(roles)
+	})
+
+	t.Run("effective roles are taken from assignment history", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Bob")
+		roleId := fixture.Role("Developer")
+		yesterday := time.Now().Add(-24 * time.Hour)
+		fixture.Assignment(employeeId, roleId, yesterday, nil)
+		assigned := now()
+		fixture.db.MustExec("delete from employee_role where employee_id = $1", employeeId)
+
+		got, err := fixture.assignments.FindEffectiveRolesAsOf(employeeId, assigned)
+		a.NoError(err)
+		a.Len(got, 1)
+		a.Equal("Developer", got[0].Name)
+
+		got, err = fixture.assignments.FindEffectiveRolesAsOf(employeeId, now())
+		a.NoError(err)
+		a.Empty(got)
+	})
+}
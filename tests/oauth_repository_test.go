@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"idm/inner/oauth"
+	"testing"
+)
+
+func TestOAuthClientRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	t.Run("save, find and delete client", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		tx := fixture.db.MustBegin()
+		saved, err := fixture.clients.SaveTx(tx, oauth.Entity{
+			ClientId:   "reports",
+			SecretHash: []byte("hash"),
+			Salt:       []byte("salt"),
+			Scopes:     []string{"employees:read"},
+		})
+		a.NoError(err)
+		exists, err := fixture.clients.FindByClientIdTx(tx, "reports")
+		a.NoError(err)
+		a.True(exists)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.clients.FindByClientId("reports")
+		a.NoError(err)
+		a.Equal(saved.Id, got.Id)
+		a.Equal([]string{"employees:read"}, []string(got.Scopes))
+
+		tx = fixture.db.MustBegin()
+		deleted, err := fixture.clients.DeleteTx(tx, []int64{saved.Id})
+		a.NoError(err)
+		a.Len(deleted, 1)
+		a.NoError(tx.Commit())
+
+		all, err := fixture.clients.FindAll()
+		a.NoError(err)
+		a.Empty(all)
+	})
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/birthright"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/employee"
+	"idm/inner/orgunit"
+	"idm/inner/validator"
+	"testing"
+)
+
+func TestOrgUnits(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	units := orgunit.NewService(fixture.orgUnits, auditService, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	rules := birthright.NewService(fixture.rules, fixture.roles, fixture.attributes, assignments, auditService, vld)
+	employees := employee.NewService(
+		fixture.employees, fixture.roles, fixture.orgUnits, fixture.attributes, fixture.assignments, assignments, rules,
+		fixture.sodRules, allowAccess{}, auditService, vld,
+	)
+	ctx := common.WithActor(context.Background(), "alice")
+
+	t.Run("subtree and members follow moves between parents", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		company, err := units.Create(ctx, orgunit.CreateRequest{Name: "Company"})
+		a.NoError(err)
+		sales, err := units.Create(ctx, orgunit.CreateRequest{Name: "Sales", ParentId: &company.Id})
+		a.NoError(err)
+		retail, err := units.Create(ctx, orgunit.CreateRequest{Name: "Retail", ParentId: &sales.Id})
+		a.NoError(err)
+		bob := fixture.Employee("Bob")
+		a.NoError(employees.SetOrgUnit(ctx, employee.SetOrgUnitRequest{Id: bob, OrgUnitId: retail.Id}))
+
+		subtree, err := units.FindSubtree(orgunit.IdRequest{Id: company.Id})
+		a.NoError(err)
+		a.Len(subtree, 3)
+		a.Equal(2, subtree[2].Depth)
+
+		direct, err := units.FindMembers(orgunit.MembersRequest{Id: company.Id})
+		a.NoError(err)
+		a.Empty(direct)
+		all, err := units.FindMembers(orgunit.MembersRequest{Id: company.Id, Subtree: true})
+		a.NoError(err)
+		a.Len(all, 1)
+		a.Equal(bob, all[0].Id)
+
+		_, err = units.Update(ctx, orgunit.UpdateRequest{Id: sales.Id, Name: "Sales", ParentId: &retail.Id})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		err = units.Delete(ctx, orgunit.IdRequest{Id: retail.Id})
+		a.ErrorAs(err, &common.RequestValidationError{})
+	})
+
+	t.Run("reporting lines reject cycles", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		alice := fixture.Employee("Alice")
+		bob := fixture.Employee("Bob")
+		carol := fixture.Employee("Carol")
+		a.NoError(employees.SetManager(ctx, employee.SetManagerRequest{Id: bob, ManagerId: alice}))
+		a.NoError(employees.SetManager(ctx, employee.SetManagerRequest{Id: carol, ManagerId: bob}))
+
+		err := employees.SetManager(ctx, employee.SetManagerRequest{Id: alice, ManagerId: carol})
+		a.ErrorAs(err, &common.RequestValidationError{})
+
+		managers, err := units.FindManagers(orgunit.IdRequest{Id: carol})
+		a.NoError(err)
+		a.Len(managers, 2)
+		a.Equal(bob, managers[0].Id)
+		a.Equal(alice, managers[1].Id)
+
+		direct, err := units.FindReports(orgunit.ReportsRequest{Id: alice})
+		a.NoError(err)
+		a.Len(direct, 1)
+		indirect, err := units.FindReports(orgunit.ReportsRequest{Id: alice, Indirect: true})
+		a.NoError(err)
+		a.Len(indirect, 2)
+		a.Equal(carol, indirect[1].Id)
+		a.Equal(2, indirect[1].Level)
+	})
+}
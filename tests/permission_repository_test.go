@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"github.com/stretchr/testify/assert"
+	"idm/inner/database"
+	"testing"
+)
+
+func TestPermissionRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+
+	t.Run("find a permission by id", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		id := fixture.Permission("employees:read")
+
+		got, err := fixture.permissions.FindById(id)
+		a.NoError(err)
+		a.Equal("employees:read", got.Name)
+		a.NotEmpty(got.CreatedAt)
+	})
+
+	t.Run("grant and revoke permission of role", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		roleId := fixture.Role("Admin")
+		permissionId := fixture.Permission("employees:read")
+
+		tx := fixture.db.MustBegin()
+		granted, err := fixture.permissions.GrantToRoleTx(tx, roleId, permissionId)
+		a.NoError(err)
+		a.True(granted)
+		// повторная выдача не должна приводить к ошибке
+		granted, err = fixture.permissions.GrantToRoleTx(tx, roleId, permissionId)
+		a.NoError(err)
+		a.False(granted)
+		a.NoError(tx.Commit())
+
+		got, err := fixture.permissions.FindByRoleId(roleId)
+		a.NoError(err)
+		a.Len(got, 1)
+
+		tx = fixture.db.MustBegin()
+		revoked, err := fixture.permissions.RevokeFromRoleTx(tx, roleId, permissionId)
+		a.NoError(err)
+		a.True(revoked)
+
+		revoked, err = fixture.permissions.RevokeFromRoleTx(tx, roleId, permissionId)
+		a.NoError(err)
+		a.False(revoked)
+		a.NoError(tx.Commit())
+	})
+
+	t.Run("find grants of several roles", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		admin := fixture.Role("Admin")
+		auditor := fixture.Role("Auditor")
+		read := fixture.Permission("employees:read")
+		write := fixture.Permission("employees:write")
+		fixture.GrantPermission(admin, read)
+		fixture.GrantPermission(admin, write)
+		fixture.GrantPermission(auditor, read)
+
+		got, err := fixture.permissions.FindGrantsByRoleIds([]int64{admin, auditor})
+		a.NoError(err)
+		a.Len(got, 3)
+		a.Equal("employees:read", got[0].Name)
+	})
+}
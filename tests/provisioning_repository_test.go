@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/provisioning"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+func TestProvisioningRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	recorder := func(connectors ...string) *provisioning.Recorder {
+		return provisioning.NewRecorder(audit.NewService(fixture.audit, validator.New()), fixture.operations, connectors)
+	}
+
+	t.Run("operations are enqueued per connector together with transaction", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("devs")
+
+		tx := fixture.db.MustBegin()
+		a.NoError(recorder("crm", "hr").RecordTx(context.Background(), tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: "assignment",
+			EntityId:   1,
+			After:      map[string]any{"employee_id": employeeId, "role_id": roleId, "valid_from": time.Now()},
+		}))
+		a.NoError(tx.Commit())
+
+		tx = fixture.db.MustBegin()
+		a.NoError(recorder("hr").RecordTx(context.Background(), tx, audit.Event{
+			Action:     audit.ActionCreate,
+			EntityType: "employee",
+			EntityId:   employeeId,
+			After:      map[string]any{"id": employeeId, "name": "Alice"},
+		}))
+		a.NoError(tx.Rollback())
+
+		page := common.PageRequest{Limit: 10, Sort: "id", Order: "asc"}
+		all, err := fixture.operations.FindPage(provisioning.ListRequest{PageRequest: page}, nil)
+		a.NoError(err)
+		a.Len(all, 2)
+		hr, err := fixture.operations.Count(provisioning.ListRequest{Connector: "hr", Kind: provisioning.KindGrantEntitlement})
+		a.NoError(err)
+		a.Equal(int64(1), hr)
+	})
+
+	t.Run("claim keeps order of operations of one employee", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		aliceId := fixture.Employee("Alice")
+		bobId := fixture.Employee("Bob")
+		now := time.Now()
+		tx := fixture.db.MustBegin()
+		a.NoError(fixture.operations.EnqueueTx(tx, []provisioning.Entity{
+			{Connector: "hr", Kind: provisioning.KindCreateAccount, EmployeeId: aliceId, Payload: []byte(`{}`), ScheduledAt: now.Add(-time.Minute)},
+			{Connector: "hr", Kind: provisioning.KindUpdateAccount, EmployeeId: aliceId, Payload: []byte(`{}`), ScheduledAt: now.Add(-time.Minute)},
+			// запланирована на будущее и не задерживает операции после неё
+			{Connector: "hr", Kind: provisioning.KindCreateAccount, EmployeeId: bobId, Payload: []byte(`{}`), ScheduledAt: now.Add(time.Hour)},
+			{Connector: "hr", Kind: provisioning.KindUpdateAccount, EmployeeId: bobId, Payload: []byte(`{}`), ScheduledAt: now.Add(-time.Minute)},
+			{Connector: "crm", Kind: provisioning.KindCreateAccount, EmployeeId: aliceId, Payload: []byte(`{}`), ScheduledAt: now.Add(-time.Minute)},
+		}))
+		a.NoError(tx.Commit())
+
+		claimed, err := fixture.operations.Claim([]string{"hr"}, now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Len(claimed, 2)
+		a.Equal(provisioning.KindCreateAccount, claimed[0].Kind)
+		a.Equal(aliceId, claimed[0].EmployeeId)
+		a.Equal(provisioning.KindUpdateAccount, claimed[1].Kind)
+		a.Equal(bobId, claimed[1].EmployeeId)
+
+		// взятые операции не выдаются повторно до конца аренды
+		again, err := fixture.operations.Claim([]string{"hr"}, now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Empty(again)
+
+		for _, o := range claimed {
+			o.Status = provisioning.StatusSucceeded
+			a.NoError(fixture.operations.Complete(o))
+		}
+		next, err := fixture.operations.Claim([]string{"hr"}, now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+		a.NoError(err)
+		a.Len(next, 1)
+		a.Equal(provisioning.KindUpdateAccount, next[0].Kind)
+		a.Equal(aliceId, next[0].EmployeeId)
+	})
+
+	t.Run("role is held as primary or by effective assignment", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		roleId := fixture.Role("devs")
+		now := time.Now()
+		validTo := now.Add(time.Hour)
+		fixture.Assignment(employeeId, roleId, now.Add(-time.Hour), &validTo)
+
+		holds, err := fixture.operations.HoldsRole(employeeId, roleId, now)
+		a.NoError(err)
+		a.True(holds)
+		holds, err = fixture.operations.HoldsRole(employeeId, roleId, now.Add(2*time.Hour))
+		a.NoError(err)
+		a.False(holds)
+
+		fixture.DeleteEmployee(employeeId)
+		holds, err = fixture.operations.HoldsRole(employeeId, roleId, now)
+		a.NoError(err)
+		a.False(holds)
+	})
+
+	t.Run("only failed operation can be retried", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		employeeId := fixture.Employee("Alice")
+		now := time.Now()
+		tx := fixture.db.MustBegin()
+		a.NoError(fixture.operations.EnqueueTx(tx, []provisioning.Entity{
+			{Connector: "hr", Kind: provisioning.KindCreateAccount, EmployeeId: employeeId, Payload: []byte(`{}`), ScheduledAt: now},
+		}))
+		a.NoError(tx.Commit())
+		claimed, err := fixture.operations.Claim([]string{"hr"}, now, now, 10)
+		a.NoError(err)
+		a.Len(claimed, 1)
+
+		_, err = fixture.operations.Retry(claimed[0].Id, now)
+		a.ErrorIs(err, sql.ErrNoRows)
+
+		failed := claimed[0]
+		failed.Status = provisioning.StatusFailed
+		failed.Attempts = 8
+		a.NoError(fixture.operations.Complete(failed))
+		retried, err := fixture.operations.Retry(failed.Id, now)
+		a.NoError(err)
+		a.Equal(provisioning.StatusPending, retried.Status)
+		a.Equal(0, retried.Attempts)
+	})
+}
@@ -2,8 +2,12 @@ package tests
 
 import (
 	"github.com/stretchr/testify/assert"
+	"idm/inner/accessrequest"
+	"idm/inner/birthright"
 	"idm/inner/database"
+	"idm/inner/sod"
 	"testing"
+	"time"
 )
 
 func TestRoleRepository(t *testing.T) {
@@ -55,8 +59,10 @@ func TestRoleRepository(t *testing.T) {
 		defer fixture.ClearDatabase()
 		id := fixture.Role("Admin")
 
-		err := fixture.roles.DeleteById(id)
+		tx := fixture.db.MustBegin()
+		_, err := fixture.roles.DeleteTx(tx, []int64{id})
 		a.Nil(err)
+		a.NoError(tx.Commit())
 
 		_, err = fixture.roles.FindById(id)
 		a.NotNil(err)
@@ -67,10 +73,69 @@ func TestRoleRepository(t *testing.T) {
 		id1 := fixture.Role("Admin")
 		id2 := fixture.Role("User")
 
-		err := fixture.roles.DeleteAllByIds([]int64{id1, id2})
+		tx := fixture.db.MustBegin()
+		_, err := fixture.roles.DeleteTx(tx, []int64{id1, id2})
 		a.Nil(err)
+		a.NoError(tx.Commit())
 
 		got, _ := fixture.roles.FindAllByIds([]int64{id1, id2})
 		a.Len(got, 0)
 	})
+
+	t.Run("walk role hierarchy", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		head := fixture.Role("Head of Finance")
+		senior := fixture.Role("Senior Accountant")
+		accountant := fixture.Role("Accountant")
+		fixture.IncludeRole(head, senior)
+		fixture.IncludeRole(senior, accountant)
+
+		ancestors, err := fixture.roles.FindAncestors(accountant)
+		a.NoError(err)
+		a.Len(ancestors, 2)
+
+		descendants, err := fixture.roles.FindDescendants(head)
+		a.NoError(err)
+		a.Len(descendants, 2)
+
+		expanded, err := fixture.roles.ExpandInherited([]int64{senior})
+		a.NoError(err)
+		a.Len(expanded, 2)
+
+		tx := fixture.db.MustBegin()
+		defer func() { _ = tx.Rollback() }()
+		cycle, err := fixture.roles.IsDescendantTx(tx, head, accountant)
+		a.NoError(err)
+		a.True(cycle)
+		cycle, err = fixture.roles.IsDescendantTx(tx, accountant, head)
+		a.NoError(err)
+		a.False(cycle)
+	})
+	t.Run("purge keeps roles referenced by compliance records", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		alice := fixture.Employee("Alice")
+		requested := fixture.Role("requested")
+		creator := fixture.Role("payment creator")
+		approver := fixture.Role("payment approver")
+		granted := fixture.Role("granted by rule")
+		fixture.Role("plain")
+		tx := fixture.db.MustBegin()
+		_, err := fixture.requests.SaveTx(tx, accessrequest.Entity{EmployeeId: alice, RoleId: requested,
+			Justification: "month end", RequestedBy: "alice", ExpiresAt: time.Now().Add(time.Hour)})
+		a.NoError(err)
+		_, err = fixture.sodRules.SaveTx(tx, sod.Entity{Name: "payments", RoleAId: creator, RoleBId: approver})
+		a.NoError(err)
+		_, err = fixture.rules.SaveTx(tx, birthright.Entity{Name: "everyone", RoleId: granted})
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		fixture.db.MustExec("update role set deleted_at = now()")
+
+		purged, err := fixture.roles.Purge(time.Now().Add(time.Hour))
+		a.NoError(err)
+		a.Equal(int64(1), purged)
+
+		var left []int64
+		a.NoError(fixture.db.Select(&left, "select id from role order by id"))
+		a.Equal([]int64{requested, creator, approver, granted}, left)
+	})
 }
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/assignment"
+	"idm/inner/audit"
+	"idm/inner/common"
+	"idm/inner/database"
+	"idm/inner/sod"
+	"idm/inner/validator"
+	"testing"
+	"time"
+)
+
+func TestSodRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	vld := validator.New()
+	auditService := audit.NewService(fixture.audit, vld)
+	rules := sod.NewService(fixture.sodRules, fixture.roles, auditService, vld)
+	assignments := assignment.NewService(fixture.assignments, fixture.employees, fixture.roles, fixture.sodRules, auditService, vld)
+	ctx := common.WithActor(context.Background(), "alice")
+
+	t.Run("grant of conflicting role through hierarchy is blocked", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		bob := fixture.Employee("Bob")
+		creator := fixture.Role("payment creator")
+		approver := fixture.Role("payment approver")
+		finance := fixture.Role("finance")
+		fixture.IncludeRole(finance, approver)
+		fixture.Assignment(bob, creator, time.Now().Add(-time.Hour), nil)
+
+		// порядок ролей в запросе не важен
+		_, err := rules.Create(ctx, sod.CreateRequest{Name: "payments", RoleAId: approver, RoleBId: creator})
+		a.NoError(err)
+		_, err = rules.Create(ctx, sod.CreateRequest{Name: "payments again", RoleAId: creator, RoleBId: approver})
+		a.ErrorAs(err, &common.AlreadyExistsError{})
+
+		_, err = assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: bob, RoleId: finance})
+		a.ErrorAs(err, &common.SodConflictError{})
+
+		// назначение, которое начнётся после окончания текущего, правило не нарушает
+		from := time.Now().Add(time.Hour)
+		fixture.db.MustExec("update employee_role set valid_to = $1 where employee_id = $2", from, bob)
+		_, err = assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: bob, RoleId: finance, ValidFrom: &from})
+		a.NoError(err)
+	})
+
+	t.Run("override records justification and violation is reported", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		bob := fixture.Employee("Bob")
+		carol := fixture.Employee("Carol")
+		creator := fixture.Role("payment creator")
+		approver := fixture.Role("payment approver")
+		fixture.Assignment(bob, creator, time.Now().Add(-time.Hour), nil)
+		fixture.Assignment(carol, creator, time.Now().Add(-time.Hour), nil)
+		fixture.Assignment(carol, approver, time.Now().Add(-time.Hour), nil)
+		rule, err := rules.Create(ctx, sod.CreateRequest{Name: "payments", RoleAId: creator, RoleBId: approver})
+		a.NoError(err)
+
+		id, err := assignments.GrantOverride(ctx, assignment.OverrideRequest{
+			GrantRequest:  assignment.GrantRequest{EmployeeId: bob, RoleId: approver},
+			Justification: "covering for vacation until Friday",
+		})
+		a.NoError(err)
+		a.Greater(id, int64(0))
+
+		violations, err := rules.FindViolations(sod.ViolationsRequest{RuleId: &rule.Id})
+		a.NoError(err)
+		a.Len(violations, 2)
+		a.Equal("Bob", violations[0].EmployeeName)
+		a.True(violations[0].Overridden)
+		// у Carol роли были выданы до появления правила
+		a.Equal("Carol", violations[1].EmployeeName)
+		a.False(violations[1].Overridden)
+
+		violations, err = rules.FindViolations(sod.ViolationsRequest{EmployeeId: &carol})
+		a.NoError(err)
+		a.Len(violations, 1)
+	})
+
+	t.Run("primary role counts as held role", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		bob := fixture.Employee("Bob")
+		carol := fixture.Employee("Carol")
+		creator := fixture.Role("payment creator")
+		approver := fixture.Role("payment approver")
+		fixture.db.MustExec("update employee set role_id = $1 where id in ($2, $3)", creator, bob, carol)
+		fixture.Assignment(carol, approver, time.Now().Add(-time.Hour), nil)
+		rule, err := rules.Create(ctx, sod.CreateRequest{Name: "payments", RoleAId: creator, RoleBId: approver})
+		a.NoError(err)
+
+		_, err = assignments.Grant(ctx, assignment.GrantRequest{EmployeeId: bob, RoleId: approver})
+		a.ErrorAs(err, &common.SodConflictError{})
+
+		tx := fixture.db.MustBegin()
+		defer func() { _ = tx.Rollback() }()
+		conflicts, err := fixture.sodRules.FindConflictsTx(tx, bob, approver, time.Now(), nil)
+		a.NoError(err)
+		a.Len(conflicts, 1)
+
+		violations, err := rules.FindViolations(sod.ViolationsRequest{RuleId: &rule.Id})
+		a.NoError(err)
+		a.Len(violations, 1)
+		a.Equal("Carol", violations[0].EmployeeName)
+	})
+}
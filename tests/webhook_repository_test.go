@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"idm/inner/audit"
+	"idm/inner/database"
+	"idm/inner/webhook"
+	"testing"
+	"time"
+)
+
+// nopAuditor журнал аудита, который ничего не пишет: проверяется только outbox
+type nopAuditor struct{}
+
+func (nopAuditor) RecordTx(ctx context.Context, tx *sqlx.Tx, event audit.Event) error {
+	return nil
+}
+
+func TestWebhookRepository(t *testing.T) {
+	a := assert.New(t)
+	db := database.ConnectDb()
+	fixture := NewFixture(db)
+	defer func() {
+		if r := recover(); r != nil {
+			fixture.ClearDatabase()
+		}
+	}()
+	outbox := webhook.NewOutbox(nopAuditor{}, fixture.webhooks)
+	publish := func(commit bool, entityType, action string) {
+		tx := fixture.db.MustBegin()
+		a.NoError(outbox.RecordTx(context.Background(), tx, audit.Event{Action: action, EntityType: entityType, EntityId: 1}))
+		if commit {
+			a.NoError(tx.Commit())
+		} else {
+			a.NoError(tx.Rollback())
+		}
+	}
+	subscribe := func(eventTypes ...string) webhook.Entity {
+		tx := fixture.db.MustBegin()
+		saved, err := fixture.webhooks.SaveTx(tx, webhook.Entity{
+			Url: "https://hooks.example.com/idm", Secret: "whsec_0123456789abcdef", EventTypes: eventTypes,
+		})
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		return saved
+	}
+
+	t.Run("events of committed changes are fanned out to matching subscriptions", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		all := subscribe()
+		roles := subscribe("role.created")
+		publish(true, "employee", audit.ActionCreate)
+		publish(false, "employee", audit.ActionDelete)
+		publish(true, "role", audit.ActionCreate)
+
+		now := time.Now()
+		events, err := fixture.webhooks.Fanout(now, 100)
+		a.NoError(err)
+		a.Equal(int64(2), events)
+		again, err := fixture.webhooks.Fanout(now, 100)
+		a.NoError(err)
+		a.Equal(int64(0), again)
+
+		allDeliveries, err := fixture.webhooks.FindDeliveries(all.Id, "", 10)
+		a.NoError(err)
+		a.Len(allDeliveries, 2)
+		roleDeliveries, err := fixture.webhooks.FindDeliveries(roles.Id, webhook.StatusPending, 10)
+		a.NoError(err)
+		a.Len(roleDeliveries, 1)
+		a.Equal("role.created", roleDeliveries[0].EventType)
+	})
+
+	t.Run("claimed deliveries are leased and carry event", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		subscription := subscribe()
+		publish(true, "assignment", audit.ActionCreate)
+		now := time.Now()
+		_, err := fixture.webhooks.Fanout(now, 100)
+		a.NoError(err)
+
+		claimed, err := fixture.webhooks.Claim(now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Len(claimed, 1)
+		a.Equal(subscription.Url, claimed[0].Url)
+		a.Equal(subscription.Secret, claimed[0].Secret)
+		a.Equal("assignment.created", claimed[0].EventType)
+
+		again, err := fixture.webhooks.Claim(now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Empty(again)
+
+		a.NoError(fixture.webhooks.Complete(webhook.DeliveryEntity{
+			Id: claimed[0].Id, Status: webhook.StatusDead, Attempts: 10, NextAttemptAt: now,
+		}))
+		later, err := fixture.webhooks.Claim(now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+		a.NoError(err)
+		a.Empty(later)
+
+		tx := fixture.db.MustBegin()
+		requeued, err := fixture.webhooks.RequeueDeadTx(tx, subscription.Id, now)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		a.Equal(int64(1), requeued)
+		later, err = fixture.webhooks.Claim(now.Add(2*time.Minute), now.Add(3*time.Minute), 10)
+		a.NoError(err)
+		a.Len(later, 1)
+		a.Equal(0, later[0].Attempts)
+	})
+
+	t.Run("deliveries of inactive subscription wait until it is enabled", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		subscription := subscribe()
+		publish(true, "employee", audit.ActionUpdate)
+		now := time.Now()
+		_, err := fixture.webhooks.Fanout(now, 100)
+		a.NoError(err)
+
+		tx := fixture.db.MustBegin()
+		subscription.Active = false
+		_, err = fixture.webhooks.UpdateTx(tx, subscription)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		claimed, err := fixture.webhooks.Claim(now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Empty(claimed)
+
+		tx = fixture.db.MustBegin()
+		subscription.Active = true
+		_, err = fixture.webhooks.UpdateTx(tx, subscription)
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		claimed, err = fixture.webhooks.Claim(now, now.Add(time.Minute), 10)
+		a.NoError(err)
+		a.Len(claimed, 1)
+	})
+
+	t.Run("replay queues dispatched events since moment", func(t *testing.T) {
+		defer fixture.ClearDatabase()
+		subscription := subscribe("employee.created")
+		from := time.Now().Add(-time.Minute)
+		publish(true, "employee", audit.ActionCreate)
+		publish(true, "employee", audit.ActionDelete)
+		publish(true, "employee", audit.ActionCreate)
+		_, err := fixture.webhooks.Fanout(time.Now(), 100)
+		a.NoError(err)
+
+		tx := fixture.db.MustBegin()
+		queued, err := fixture.webhooks.ReplayTx(tx, subscription, from, time.Now())
+		a.NoError(err)
+		a.NoError(tx.Commit())
+		a.Equal(int64(2), queued)
+
+		deliveries, err := fixture.webhooks.FindDeliveries(subscription.Id, webhook.StatusPending, 10)
+		a.NoError(err)
+		a.Len(deliveries, 4)
+	})
+}